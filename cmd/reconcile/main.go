@@ -0,0 +1,122 @@
+// Command reconcile compares the users stored in MySQL with their details stored in MongoDB.
+//
+//	reconcile [-tenant id] [-page n] [-missing-details action] [-orphan-details action] [-active-mismatch action]
+//
+// Every inconsistency is printed to the standard error and the summary is printed to the standard output as JSON.
+// The actions default to report, which only reports the inconsistency. The command exits with status 1
+// when the scan or a repair failed and with status 3 when inconsistencies remain.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/mauricioww/user_microsrv/reconcile"
+	"github.com/mauricioww/user_microsrv/tenant"
+	detailsrepo "github.com/mauricioww/user_microsrv/user_details_srv/repository"
+	userrepo "github.com/mauricioww/user_microsrv/user_srv/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	tenantID := flag.String("tenant", "", "only reconcile the given tenant, every tenant by default")
+	pageSize := flag.Int("page", reconcile.DefaultPageSize, "records read per page of every store")
+	rules := reconcile.Rules{}
+	for _, c := range reconcile.Classes {
+		c := c
+		flag.Func(strings.ReplaceAll(string(c), "_", "-"), fmt.Sprintf("action for %v, one of %v", c, reconcile.AllowedActions(c)), func(v string) error {
+			rules[c] = reconcile.Action(v)
+			return nil
+		})
+	}
+	flag.Parse()
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "service", "RECONCILE", "time", log.DefaultTimestampUTC)
+
+	cts := constants{}
+	if err := env.Parse(&cts); err != nil {
+		level.Error(logger).Log("env", err)
+		os.Exit(2)
+	}
+
+	if *tenantID != "" && !tenant.Valid(*tenantID) {
+		level.Error(logger).Log("tenant", "invalid tenant", "value", *tenantID)
+		os.Exit(2)
+	}
+
+	if err := rules.Validate(); err != nil {
+		level.Error(logger).Log("rules", err)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	mysqlAddr := fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=true", cts.MysqlUser, cts.MysqlPwd, cts.MysqlHost, cts.MysqlPort, cts.MysqlName)
+	db, err := sql.Open("mysql", mysqlAddr)
+	if err != nil {
+		level.Error(logger).Log("mysql", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	mongoURI := fmt.Sprintf("mongodb://%v:%v", cts.MongoHost, cts.MongoPort)
+	credentials := options.Credential{
+		Username: cts.MongoUser,
+		Password: cts.MongoPwd,
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI).SetAuth(credentials))
+	if err != nil {
+		level.Error(logger).Log("mongo", err)
+		os.Exit(1)
+	}
+	defer client.Disconnect(ctx)
+	mongoDb := client.Database(cts.MongoName)
+
+	users := reconcile.NewMySQLUsers(db, userrepo.NewUserRepository(db, logger), *tenantID)
+	details := reconcile.NewMongoDetails(mongoDb, detailsrepo.NewUserDetailsRepository(mongoDb, logger), *tenantID)
+	reconciler := reconcile.NewReconciler(users, details, rules, *pageSize, logger)
+
+	summary, err := reconciler.Run(ctx, func(f reconcile.Finding) {
+		level.Warn(logger).Log("class", f.Class, "id", f.ID, "user_tenant", f.UserTenant, "details_tenant", f.DetailsTenant,
+			"action", f.Action, "repaired", f.Repaired, "stale", f.Stale, "error", f.Error)
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(summary)
+
+	switch {
+	case err != nil:
+		level.Error(logger).Log("reconcile", err)
+		os.Exit(1)
+	case summary.Failed > 0:
+		os.Exit(1)
+	case summary.Remaining > 0:
+		os.Exit(3)
+	}
+}
+
+type constants struct {
+	MysqlUser string `env:"MYSQL_USER,required"`
+	MysqlPwd  string `env:"MYSQL_PASSWORD,required"`
+	MysqlHost string `env:"MYSQL_HOST,required"`
+	MysqlPort int    `env:"MYSQL_PORT" envDefault:"3306"`
+	MysqlName string `env:"MYSQL_DB" envDefault:"grpc_user"`
+
+	MongoUser string `env:"MONGO_USER,required"`
+	MongoPwd  string `env:"MONGO_PASSWORD,required"`
+	MongoHost string `env:"MONGO_HOST,required"`
+	MongoPort int    `env:"MONGO_PORT" envDefault:"27017"`
+	MongoName string `env:"MONGO_DB" envDefault:"grpc_details"`
+}
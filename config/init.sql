@@ -1,7 +1,33 @@
 CREATE TABLE IF NOT EXISTS USERS(
     id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
     email CHAR(60) NOT NULL,
     pwd_hash CHAR(60) NOT NULL,
-    age INT,
-    active BOOLEAN DEFAULT true
-);
\ No newline at end of file
+    date_of_birth DATE,
+    active BOOLEAN DEFAULT true,
+    UNIQUE KEY users_tenant_email (tenant_id, email)
+);
+
+CREATE TABLE IF NOT EXISTS OUTBOX(
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    event_id CHAR(32) NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    user_id INT NOT NULL,
+    payload JSON NOT NULL,
+    occurred_at DATETIME(3) NOT NULL,
+    published_at DATETIME(3) NULL,
+    position BIGINT NULL,
+    UNIQUE KEY outbox_event (event_id),
+    UNIQUE KEY outbox_position (position),
+    KEY outbox_pending (published_at, id),
+    KEY outbox_tenant (tenant_id, position),
+    KEY outbox_user (tenant_id, user_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS FEED_SEQUENCE(
+    id INT NOT NULL PRIMARY KEY,
+    seq BIGINT NOT NULL
+);
+
+INSERT IGNORE INTO FEED_SEQUENCE(id, seq) VALUES (1, 0);
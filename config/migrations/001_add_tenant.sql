@@ -0,0 +1,4 @@
+-- Scopes the existing users to the default tenant and makes emails unique per tenant
+ALTER TABLE USERS
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' AFTER id,
+    ADD UNIQUE KEY users_tenant_email (tenant_id, email);
@@ -0,0 +1,11 @@
+-- Replaces the static age with the date of birth, existing rows get January 1st of the
+-- year they were born according to the stored age so the computed age matches today
+ALTER TABLE USERS
+    ADD COLUMN date_of_birth DATE AFTER pwd_hash;
+
+UPDATE USERS
+    SET date_of_birth = MAKEDATE(YEAR(CURDATE()) - age, 1)
+    WHERE age IS NOT NULL;
+
+ALTER TABLE USERS
+    DROP COLUMN age;
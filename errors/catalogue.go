@@ -0,0 +1,98 @@
+package errors
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mauricioww/user_microsrv/locale"
+)
+
+// DefaultLanguage is the language of Error and the fallback of the messages missing within a translation
+const DefaultLanguage = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalogue stores the messages of each language keyed by the reason of the error, every file within locales
+// is a language named after its file
+var catalogue = loadCatalogue()
+
+// languages stores the languages of the catalogue, the default one first
+var languages = catalogueLanguages()
+
+func loadCatalogue() map[string]map[string]string {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	c := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		raw, err := locales.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			panic(err)
+		}
+		c[strings.TrimSuffix(f.Name(), ".json")] = messages
+	}
+
+	return c
+}
+
+func catalogueLanguages() []string {
+	langs := []string{DefaultLanguage}
+	for lang := range catalogue {
+		if lang != DefaultLanguage {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Languages returns the languages with a translation of the messages, the default one first
+func Languages() []string {
+	return append([]string(nil), languages...)
+}
+
+// MatchLanguage returns the language of the messages selected by an Accept-Language header value, the default
+// one when none of the accepted languages has a translation
+func MatchLanguage(acceptLanguage string) string {
+	if lang := locale.Match(acceptLanguage, languages); lang != "" {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// Message returns the message of the reason in the given language, falling back to the default language
+func Message(reason string, lang string) string {
+	if m, ok := catalogue[lang][reason]; ok {
+		return m
+	}
+	return catalogue[DefaultLanguage][reason]
+}
+
+// localizer is implemented by the errors whose messages carry values besides the one of their reason
+type localizer interface {
+	localize(lang string) string
+}
+
+// Localize returns the message of the error in the given language, the errors which are not custom errors keep
+// their own message
+func Localize(err error, lang string) string {
+	switch e := err.(type) {
+	case localizer:
+		return e.localize(lang)
+	case ErrorResolver:
+		return Message(e.ReasonCode(), lang)
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,152 @@
+package errors_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/status"
+)
+
+func TestCatalogue(t *testing.T) {
+	reasons := []string{
+		errors.NewUnknownError().ReasonCode(),
+		errors.NewUserNotFoundError().ReasonCode(),
+		errors.NewInvalidAddressError("", "").ReasonCode(),
+		errors.NewUnderMinimumAgeError(0).ReasonCode(),
+		errors.NewInvalidRequestError().ReasonCode(),
+		errors.NewForbiddenError().ReasonCode(),
+		errors.NewDetailsModifiedError().ReasonCode(),
+	}
+
+	for _, lang := range errors.Languages() {
+		t.Run(lang, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			for _, reason := range reasons {
+				// act
+				message := errors.Message(reason, lang)
+
+				// assert
+				assert.NotEmpty(message, reason)
+			}
+		})
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	testCases := []struct {
+		testName string
+		err      error
+		lang     string
+		res      string
+	}{
+		{
+			testName: "default language",
+			err:      errors.NewUserNotFoundError(),
+			lang:     "en",
+			res:      "User not found",
+		},
+		{
+			testName: "translated message",
+			err:      errors.NewUserNotFoundError(),
+			lang:     "es",
+			res:      "Usuario no encontrado",
+		},
+		{
+			testName: "translated message with its values",
+			err:      errors.NewUnderMinimumAgeError(18),
+			lang:     "pt",
+			res:      "O usuário não atinge a idade mínima de 18 anos",
+		},
+		{
+			testName: "translated message with its field",
+			err:      errors.NewInvalidAddressError("country_code", "not an ISO 3166-1 alpha-2 code"),
+			lang:     "es",
+			res:      "Dirección inválida 'country_code': not an ISO 3166-1 alpha-2 code",
+		},
+		{
+			testName: "unsupported language falls back to english",
+			err:      errors.NewUnderMinimumAgeError(18),
+			lang:     "fr",
+			res:      "User is under the minimum age of 18",
+		},
+		{
+			testName: "other errors keep their message",
+			err:      fmt.Errorf("connection reset"),
+			lang:     "es",
+			res:      "connection reset",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			res := errors.Localize(tc.err, tc.lang)
+
+			// assert
+			assert.Equal(tc.res, res)
+		})
+	}
+}
+
+func TestMatchLanguage(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+
+	// act
+	matched := errors.MatchLanguage("pt-BR, es;q=0.9")
+	fallback := errors.MatchLanguage("fr")
+
+	// assert
+	assert.Equal("pt", matched)
+	assert.Equal(errors.DefaultLanguage, fallback)
+}
+
+func TestLocalizedStatus(t *testing.T) {
+	testCases := []struct {
+		testName string
+		ctx      context.Context
+		lang     string
+		message  string
+	}{
+		{
+			testName: "message in the language of the context",
+			ctx:      locale.NewContext(context.Background(), "es"),
+			lang:     "es",
+			message:  "Usuario no encontrado",
+		},
+		{
+			testName: "no language within context",
+			ctx:      context.Background(),
+			lang:     "",
+			message:  "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			err := errors.LocalizedStatus(tc.ctx, errors.NewUserNotFoundError()).Err()
+			st, _ := status.FromError(err)
+			reason, _ := errors.Describe(st)
+			lang, message := errors.Localized(st)
+
+			// assert
+			assert.Equal("User not found", st.Message())
+			assert.Equal("USER_NOT_FOUND", reason)
+			assert.Equal(tc.lang, lang)
+			assert.Equal(tc.message, message)
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package errors
 
 import (
+	"fmt"
+	"strings"
+
 	"google.golang.org/grpc/codes"
 )
 
@@ -12,46 +15,118 @@ const (
 	unauthorized       = 3
 	userNotFound       = 4
 	serverFail         = 5
+	invalidAttribute   = 6
+	missingTenant      = 7
+	userAlreadyExists  = 8
+	addressNotFound    = 9
+	invalidAddress     = 10
+	invalidPhoneNumber = 11
+	invalidCode        = 12
+	invalidDateOfBirth = 13
+	underMinimumAge    = 14
+	avatarNotFound     = 15
+	invalidAvatar      = 16
+	avatarTooLarge     = 17
+	invalidFormat      = 18
+	invalidImport      = 19
+	webhookNotFound    = 20
+	invalidWebhook     = 21
+	deliveryNotFound   = 22
+	invalidCursor      = 23
+	invalidBatch       = 24
+	invalidRequest     = 25
+	forbidden          = 26
+	detailsModified    = 27
 )
 
-var message = map[int]string{
-	unknownError:       "Unsupported error",
-	badRequestEmail:    "Missing field 'email'",
-	badRequestPassword: "Missing field 'password'",
-	unauthenticated:    "Password or email error",
-	unauthorized:       "Unauthorized user",
-	userNotFound:       "User not found",
-	serverFail:         "Internal server error",
+// reason stores the machine-readable reason of each error, unlike the messages the reasons never change
+var reason = map[int]string{
+	unknownError:       "UNKNOWN",
+	badRequestEmail:    "EMAIL_REQUIRED",
+	badRequestPassword: "PASSWORD_REQUIRED",
+	unauthenticated:    "INVALID_CREDENTIALS",
+	unauthorized:       "UNAUTHORIZED",
+	userNotFound:       "USER_NOT_FOUND",
+	serverFail:         "INTERNAL",
+	invalidAttribute:   "INVALID_ATTRIBUTE",
+	missingTenant:      "MISSING_TENANT",
+	userAlreadyExists:  "USER_ALREADY_EXISTS",
+	addressNotFound:    "ADDRESS_NOT_FOUND",
+	invalidAddress:     "INVALID_ADDRESS",
+	invalidPhoneNumber: "INVALID_PHONE_NUMBER",
+	invalidCode:        "INVALID_VERIFICATION_CODE",
+	invalidDateOfBirth: "INVALID_DATE_OF_BIRTH",
+	underMinimumAge:    "UNDER_MINIMUM_AGE",
+	avatarNotFound:     "AVATAR_NOT_FOUND",
+	invalidAvatar:      "INVALID_AVATAR",
+	avatarTooLarge:     "AVATAR_TOO_LARGE",
+	invalidFormat:      "INVALID_FORMAT",
+	invalidImport:      "INVALID_IMPORT",
+	webhookNotFound:    "WEBHOOK_NOT_FOUND",
+	invalidWebhook:     "INVALID_WEBHOOK",
+	deliveryNotFound:   "DELIVERY_NOT_FOUND",
+	invalidCursor:      "INVALID_CURSOR",
+	invalidBatch:       "INVALID_BATCH",
+	invalidRequest:     "INVALID_REQUEST",
+	forbidden:          "FORBIDDEN",
+	detailsModified:    "DETAILS_MODIFIED",
 }
 
 func messageError(code int) string {
-	return message[code]
+	return Message(reason[code], DefaultLanguage)
 }
 
 func resolveGrpc(err int) codes.Code {
 	switch err {
 	case unknownError:
 		return codes.Unknown
-	case badRequestEmail, badRequestPassword:
+	case badRequestEmail, badRequestPassword, invalidAttribute, missingTenant, invalidAddress, invalidPhoneNumber, invalidCode,
+		invalidDateOfBirth, underMinimumAge, invalidAvatar, invalidFormat, invalidImport, invalidWebhook,
+		invalidCursor, invalidBatch, invalidRequest:
 		return codes.FailedPrecondition
+	case userAlreadyExists:
+		return codes.AlreadyExists
 	case unauthenticated, unauthorized:
 		return codes.Unauthenticated
-	case userNotFound:
+	case forbidden:
+		return codes.PermissionDenied
+	case userNotFound, addressNotFound, avatarNotFound, webhookNotFound, deliveryNotFound:
 		return codes.NotFound
+	case avatarTooLarge:
+		return codes.ResourceExhausted
+	case detailsModified:
+		return codes.Aborted
 	default:
 		return codes.Internal
 	}
 }
 
-// ResolveHTTP is a function to transform gRPC codes to HTTP codes
+// ResolveHTTP is a function to transform gRPC codes to HTTP codes, the validation errors of these services use
+// FailedPrecondition and the exhausted resources are quotas or rates, see HTTPStatus for the uploads
 func ResolveHTTP(c codes.Code) int {
 	switch c {
-	case codes.FailedPrecondition:
+	case codes.OK:
+		return 200
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
 		return 400
 	case codes.Unauthenticated:
 		return 401
+	case codes.PermissionDenied:
+		return 403
 	case codes.NotFound:
 		return 404
+	case codes.AlreadyExists, codes.Aborted:
+		return 409
+	case codes.ResourceExhausted:
+		return 429
+	case codes.Canceled:
+		return 499
+	case codes.Unimplemented:
+		return 501
+	case codes.Unavailable:
+		return 503
+	case codes.DeadlineExceeded:
+		return 504
 	default:
 		return 500
 	}
@@ -78,9 +153,105 @@ type UnauthorizedError int
 // UnauthenticatedError used when a login error happens
 type UnauthenticatedError int
 
+// MissingTenantError used when a request does not carry a valid tenant
+type MissingTenantError int
+
+// UserAlreadyExistsError used when the email is already registered within the tenant
+type UserAlreadyExistsError int
+
+// AddressNotFoundError used when an address does not exist for the user
+type AddressNotFoundError int
+
+// InvalidAddressError used when a field of a postal address is not valid
+type InvalidAddressError struct {
+	Field  string
+	Reason string
+}
+
+// InvalidPhoneNumberError used when a phone number can not be normalized to E.164
+type InvalidPhoneNumberError struct {
+	Field  string
+	Reason string
+}
+
+// InvalidCodeError used when a verification code does not match or has expired
+type InvalidCodeError int
+
+// InvalidDateOfBirthError used when the date of birth is missing, malformed or in the future
+type InvalidDateOfBirthError int
+
+// UnderMinimumAgeError used when the user does not reach the minimum age required to sign up
+type UnderMinimumAgeError struct {
+	MinimumAge int
+}
+
+// AvatarNotFoundError used when the user has not uploaded an avatar
+type AvatarNotFoundError int
+
+// InvalidAvatarError used when the uploaded avatar is not a supported image
+type InvalidAvatarError int
+
+// AvatarTooLargeError used when the uploaded avatar exceeds the maximum size
+type AvatarTooLargeError int
+
+// InvalidFormatError used when the format of a bulk import or export is not supported
+type InvalidFormatError int
+
+// InvalidImportError used when a bulk import file can not be read at all, errors of single rows are reported instead
+type InvalidImportError struct {
+	Reason string
+}
+
+// WebhookNotFoundError used when a webhook subscription does not exist for the tenant
+type WebhookNotFoundError int
+
+// InvalidWebhookError used when a field of a webhook subscription is not valid
+type InvalidWebhookError struct {
+	Field  string
+	Reason string
+}
+
+// DeliveryNotFoundError used when a delivery does not exist for the webhook
+type DeliveryNotFoundError int
+
+// InvalidCursorError used when a change feed cursor was not returned by the feed
+type InvalidCursorError int
+
+// InvalidBatchError used when a batch request is empty or asks for too many ids
+type InvalidBatchError int
+
+// ForbiddenError used when an authenticated user operates on the resources of another user
+type ForbiddenError int
+
+// DetailsModifiedError used when the details changed since the revision a conditional update expected
+type DetailsModifiedError int
+
+// InvalidAttributeError used when a custom attribute or its definition does not match the schema
+type InvalidAttributeError struct {
+	Attribute string
+	Reason    string
+}
+
+// InvalidRequestError used when one or more fields of a request are not valid
+type InvalidRequestError struct {
+	Fields []FieldViolation
+}
+
+// FieldViolation describes why a field of a request is not valid
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
 // ErrorResolver is an interfaz shared between my custom errors to handle errors in the services
 type ErrorResolver interface {
 	GrpcCode() codes.Code
+	ReasonCode() string
+}
+
+// Violator is implemented by the custom errors caused by the fields of the request
+type Violator interface {
+	Violations() []FieldViolation
 }
 
 // NewUnknownError returns a badRequestEmail error type
@@ -118,6 +289,134 @@ func NewUnauthenticatedError() UnauthenticatedError {
 	return unauthenticated
 }
 
+// NewMissingTenantError returns a missingTenant error type
+func NewMissingTenantError() MissingTenantError {
+	return missingTenant
+}
+
+// NewUserAlreadyExistsError returns a userAlreadyExists error type
+func NewUserAlreadyExistsError() UserAlreadyExistsError {
+	return userAlreadyExists
+}
+
+// NewAddressNotFoundError returns a addressNotFound error type
+func NewAddressNotFoundError() AddressNotFoundError {
+	return addressNotFound
+}
+
+// NewInvalidAddressError returns a invalidAddress error type for the given field
+func NewInvalidAddressError(field string, reason string) InvalidAddressError {
+	return InvalidAddressError{
+		Field:  field,
+		Reason: reason,
+	}
+}
+
+// NewInvalidPhoneNumberError returns a invalidPhoneNumber error type for the given field
+func NewInvalidPhoneNumberError(field string, reason string) InvalidPhoneNumberError {
+	return InvalidPhoneNumberError{
+		Field:  field,
+		Reason: reason,
+	}
+}
+
+// NewInvalidCodeError returns a invalidCode error type
+func NewInvalidCodeError() InvalidCodeError {
+	return invalidCode
+}
+
+// NewInvalidDateOfBirthError returns a invalidDateOfBirth error type
+func NewInvalidDateOfBirthError() InvalidDateOfBirthError {
+	return invalidDateOfBirth
+}
+
+// NewUnderMinimumAgeError returns a underMinimumAge error type for the given minimum age
+func NewUnderMinimumAgeError(minimumAge int) UnderMinimumAgeError {
+	return UnderMinimumAgeError{
+		MinimumAge: minimumAge,
+	}
+}
+
+// NewAvatarNotFoundError returns a avatarNotFound error type
+func NewAvatarNotFoundError() AvatarNotFoundError {
+	return avatarNotFound
+}
+
+// NewInvalidAvatarError returns a invalidAvatar error type
+func NewInvalidAvatarError() InvalidAvatarError {
+	return invalidAvatar
+}
+
+// NewAvatarTooLargeError returns a avatarTooLarge error type
+func NewAvatarTooLargeError() AvatarTooLargeError {
+	return avatarTooLarge
+}
+
+// NewInvalidFormatError returns a invalidFormat error type
+func NewInvalidFormatError() InvalidFormatError {
+	return invalidFormat
+}
+
+// NewInvalidImportError returns a invalidImport error type with the reason why the file can not be read
+func NewInvalidImportError(reason string) InvalidImportError {
+	return InvalidImportError{
+		Reason: reason,
+	}
+}
+
+// NewWebhookNotFoundError returns a webhookNotFound error type
+func NewWebhookNotFoundError() WebhookNotFoundError {
+	return webhookNotFound
+}
+
+// NewInvalidWebhookError returns a invalidWebhook error type for the given field
+func NewInvalidWebhookError(field string, reason string) InvalidWebhookError {
+	return InvalidWebhookError{
+		Field:  field,
+		Reason: reason,
+	}
+}
+
+// NewDeliveryNotFoundError returns a deliveryNotFound error type
+func NewDeliveryNotFoundError() DeliveryNotFoundError {
+	return deliveryNotFound
+}
+
+// NewInvalidCursorError returns a invalidCursor error type
+func NewInvalidCursorError() InvalidCursorError {
+	return invalidCursor
+}
+
+// NewInvalidBatchError returns a invalidBatch error type
+func NewInvalidBatchError() InvalidBatchError {
+	return invalidBatch
+}
+
+// NewForbiddenError returns a forbidden error type
+func NewForbiddenError() ForbiddenError {
+	return forbidden
+}
+
+// NewDetailsModifiedError returns a detailsModified error type
+func NewDetailsModifiedError() DetailsModifiedError {
+	return detailsModified
+}
+
+// NewInvalidAttributeError returns a invalidAttribute error type for the given attribute
+func NewInvalidAttributeError(attribute string, reason string) InvalidAttributeError {
+	return InvalidAttributeError{
+		Attribute: attribute,
+		Reason:    reason,
+	}
+}
+
+// NewInvalidRequestError returns a invalidRequest error type for the given fields
+func NewInvalidRequestError(fields ...FieldViolation) InvalidRequestError {
+	return InvalidRequestError{
+		Fields: fields,
+	}
+}
+
 func (e UnknownError) Error() string {
 	return messageError(int(e))
 }
@@ -146,6 +445,126 @@ func (e UnauthenticatedError) Error() string {
 	return messageError(int(e))
 }
 
+func (e MissingTenantError) Error() string {
+	return messageError(int(e))
+}
+
+func (e UserAlreadyExistsError) Error() string {
+	return messageError(int(e))
+}
+
+func (e AddressNotFoundError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidAddressError) Error() string {
+	return e.localize(DefaultLanguage)
+}
+
+func (e InvalidAddressError) localize(lang string) string {
+	return fmt.Sprintf("%v '%v': %v", Message(reason[invalidAddress], lang), e.Field, e.Reason)
+}
+
+func (e InvalidPhoneNumberError) Error() string {
+	return e.localize(DefaultLanguage)
+}
+
+func (e InvalidPhoneNumberError) localize(lang string) string {
+	return fmt.Sprintf("%v '%v': %v", Message(reason[invalidPhoneNumber], lang), e.Field, e.Reason)
+}
+
+func (e InvalidCodeError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidDateOfBirthError) Error() string {
+	return messageError(int(e))
+}
+
+func (e UnderMinimumAgeError) Error() string {
+	return e.localize(DefaultLanguage)
+}
+
+func (e UnderMinimumAgeError) localize(lang string) string {
+	return fmt.Sprintf(Message(reason[underMinimumAge], lang), e.MinimumAge)
+}
+
+func (e AvatarNotFoundError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidAvatarError) Error() string {
+	return messageError(int(e))
+}
+
+func (e AvatarTooLargeError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidFormatError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidImportError) Error() string {
+	return e.localize(DefaultLanguage)
+}
+
+func (e InvalidImportError) localize(lang string) string {
+	return fmt.Sprintf("%v: %v", Message(reason[invalidImport], lang), e.Reason)
+}
+
+func (e WebhookNotFoundError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidWebhookError) Error() string {
+	return e.localize(DefaultLanguage)
+}
+
+func (e InvalidWebhookError) localize(lang string) string {
+	return fmt.Sprintf("%v '%v': %v", Message(reason[invalidWebhook], lang), e.Field, e.Reason)
+}
+
+func (e DeliveryNotFoundError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidCursorError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidBatchError) Error() string {
+	return messageError(int(e))
+}
+
+func (e ForbiddenError) Error() string {
+	return messageError(int(e))
+}
+
+func (e DetailsModifiedError) Error() string {
+	return messageError(int(e))
+}
+
+func (e InvalidAttributeError) Error() string {
+	return e.localize(DefaultLanguage)
+}
+
+func (e InvalidAttributeError) localize(lang string) string {
+	return fmt.Sprintf("%v '%v': %v", Message(reason[invalidAttribute], lang), e.Attribute, e.Reason)
+}
+
+func (e InvalidRequestError) Error() string {
+	return e.localize(DefaultLanguage)
+}
+
+func (e InvalidRequestError) localize(lang string) string {
+	fields := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		fields[i] = fmt.Sprintf("'%v': %v", f.Field, f.Description)
+	}
+	return fmt.Sprintf("%v %v", Message(reason[invalidRequest], lang), strings.Join(fields, ", "))
+}
+
 // GrpcCode translate from HTTP code to gRPC code
 func (e UnknownError) GrpcCode() codes.Code {
 	return resolveGrpc(int(e))
@@ -180,3 +599,303 @@ func (e UnauthorizedError) GrpcCode() codes.Code {
 func (e UnauthenticatedError) GrpcCode() codes.Code {
 	return resolveGrpc(int(e))
 }
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e MissingTenantError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e UserAlreadyExistsError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e AddressNotFoundError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidAddressError) GrpcCode() codes.Code {
+	return resolveGrpc(invalidAddress)
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidAttributeError) GrpcCode() codes.Code {
+	return resolveGrpc(invalidAttribute)
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidPhoneNumberError) GrpcCode() codes.Code {
+	return resolveGrpc(invalidPhoneNumber)
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidCodeError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidDateOfBirthError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e UnderMinimumAgeError) GrpcCode() codes.Code {
+	return resolveGrpc(underMinimumAge)
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e AvatarNotFoundError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidAvatarError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e AvatarTooLargeError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidFormatError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidImportError) GrpcCode() codes.Code {
+	return resolveGrpc(invalidImport)
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e WebhookNotFoundError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidWebhookError) GrpcCode() codes.Code {
+	return resolveGrpc(invalidWebhook)
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e DeliveryNotFoundError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidCursorError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidBatchError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e ForbiddenError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e DetailsModifiedError) GrpcCode() codes.Code {
+	return resolveGrpc(int(e))
+}
+
+// GrpcCode translate from HTTP code to gRPC code
+func (e InvalidRequestError) GrpcCode() codes.Code {
+	return resolveGrpc(invalidRequest)
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e UnknownError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e BadRequestEmailError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e BadRequestPasswordError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InternalError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e UserNotFoundError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e UnauthorizedError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e UnauthenticatedError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e MissingTenantError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e UserAlreadyExistsError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e AddressNotFoundError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidAddressError) ReasonCode() string {
+	return reason[invalidAddress]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidAttributeError) ReasonCode() string {
+	return reason[invalidAttribute]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidPhoneNumberError) ReasonCode() string {
+	return reason[invalidPhoneNumber]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidCodeError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidDateOfBirthError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e UnderMinimumAgeError) ReasonCode() string {
+	return reason[underMinimumAge]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e AvatarNotFoundError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidAvatarError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e AvatarTooLargeError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidFormatError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidImportError) ReasonCode() string {
+	return reason[invalidImport]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e WebhookNotFoundError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidWebhookError) ReasonCode() string {
+	return reason[invalidWebhook]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e DeliveryNotFoundError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidCursorError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidBatchError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e ForbiddenError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e DetailsModifiedError) ReasonCode() string {
+	return reason[int(e)]
+}
+
+// ReasonCode returns the machine-readable reason of the error
+func (e InvalidRequestError) ReasonCode() string {
+	return reason[invalidRequest]
+}
+
+// Violations returns the field which caused the error
+func (e BadRequestEmailError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: "email", Description: "is required"}}
+}
+
+// Violations returns the field which caused the error
+func (e BadRequestPasswordError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: "password", Description: "is required"}}
+}
+
+// Violations returns the field which caused the error
+func (e InvalidAddressError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: e.Field, Description: e.Reason}}
+}
+
+// Violations returns the field which caused the error
+func (e InvalidAttributeError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: "attributes." + e.Attribute, Description: e.Reason}}
+}
+
+// Violations returns the field which caused the error
+func (e InvalidPhoneNumberError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: e.Field, Description: e.Reason}}
+}
+
+// Violations returns the field which caused the error
+func (e InvalidDateOfBirthError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: "date_of_birth", Description: "expected a past date as YYYY-MM-DD"}}
+}
+
+// Violations returns the field which caused the error
+func (e UnderMinimumAgeError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: "date_of_birth", Description: fmt.Sprintf("the user must be at least %v years old", e.MinimumAge)}}
+}
+
+// Violations returns the field which caused the error
+func (e InvalidWebhookError) Violations() []FieldViolation {
+	return []FieldViolation{{Field: e.Field, Description: e.Reason}}
+}
+
+// Violations returns the fields which caused the error
+func (e InvalidRequestError) Violations() []FieldViolation {
+	return e.Fields
+}
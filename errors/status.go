@@ -0,0 +1,101 @@
+package errors
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mauricioww/user_microsrv/locale"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// Domain identifies these services within the ErrorInfo details
+const Domain = "user_microsrv"
+
+// Status returns the gRPC status of the error carrying its reason as ErrorInfo details and its field violations
+// as BadRequest details, the errors which are not custom errors are reported as unknown ones
+func Status(err error) *status.Status {
+	return newStatus(err, "")
+}
+
+// LocalizedStatus is Status carrying also the message of the error in the language stored within the context as
+// LocalizedMessage details, the message of the status itself stays in the default language
+func LocalizedStatus(ctx context.Context, err error) *status.Status {
+	lang, _ := locale.FromContext(ctx)
+	return newStatus(err, lang)
+}
+
+func newStatus(err error, lang string) *status.Status {
+	e, ok := err.(ErrorResolver)
+	if !ok {
+		u := NewUnknownError()
+		e, err = u, u
+	}
+
+	st := status.New(e.GrpcCode(), err.Error())
+
+	details := []proto.Message{&errdetails.ErrorInfo{Reason: e.ReasonCode(), Domain: Domain}}
+	if v, ok := err.(Violator); ok {
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range v.Violations() {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	if lang != "" {
+		details = append(details, &errdetails.LocalizedMessage{Locale: lang, Message: Localize(err, lang)})
+	}
+
+	if withDetails, detailsErr := st.WithDetails(details...); detailsErr == nil {
+		return withDetails
+	}
+	return st
+}
+
+// Describe returns the reason and the field violations carried by the details of the status, the reason is empty
+// when the status was not built by Status
+func Describe(st *status.Status) (string, []FieldViolation) {
+	var reason string
+	var violations []FieldViolation
+
+	for _, d := range st.Details() {
+		switch detail := d.(type) {
+		case *errdetails.ErrorInfo:
+			reason = detail.GetReason()
+		case *errdetails.BadRequest:
+			for _, f := range detail.GetFieldViolations() {
+				violations = append(violations, FieldViolation{Field: f.GetField(), Description: f.GetDescription()})
+			}
+		}
+	}
+
+	return reason, violations
+}
+
+// HTTPStatus is ResolveHTTP for a status, the uploads which are too large exhaust no quota so they answer 413 and
+// the conditional updates of modified details answer 412
+func HTTPStatus(st *status.Status) int {
+	switch r, _ := Describe(st); r {
+	case reason[avatarTooLarge]:
+		return 413
+	case reason[detailsModified]:
+		return 412
+	}
+
+	return ResolveHTTP(st.Code())
+}
+
+// Localized returns the language and the message carried by the LocalizedMessage details of the status, both are
+// empty when the status was not built by LocalizedStatus
+func Localized(st *status.Status) (string, string) {
+	for _, d := range st.Details() {
+		if detail, ok := d.(*errdetails.LocalizedMessage); ok {
+			return detail.GetLocale(), detail.GetMessage()
+		}
+	}
+
+	return "", ""
+}
@@ -0,0 +1,120 @@
+package errors_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatus(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		err        error
+		code       codes.Code
+		message    string
+		reason     string
+		violations []errors.FieldViolation
+	}{
+		{
+			testName: "custom error carries its reason",
+			err:      errors.NewUserNotFoundError(),
+			code:     codes.NotFound,
+			message:  "User not found",
+			reason:   "USER_NOT_FOUND",
+		},
+		{
+			testName: "field error carries its violation",
+			err:      errors.NewInvalidAddressError("country_code", "not an ISO 3166-1 alpha-2 code"),
+			code:     codes.FailedPrecondition,
+			message:  "Invalid address 'country_code': not an ISO 3166-1 alpha-2 code",
+			reason:   "INVALID_ADDRESS",
+			violations: []errors.FieldViolation{
+				{Field: "country_code", Description: "not an ISO 3166-1 alpha-2 code"},
+			},
+		},
+		{
+			testName: "invalid request carries every violation",
+			err: errors.NewInvalidRequestError(
+				errors.FieldViolation{Field: "email", Description: "is required"},
+				errors.FieldViolation{Field: "password", Description: "is required"},
+			),
+			code:    codes.FailedPrecondition,
+			message: "Invalid request 'email': is required, 'password': is required",
+			reason:  "INVALID_REQUEST",
+			violations: []errors.FieldViolation{
+				{Field: "email", Description: "is required"},
+				{Field: "password", Description: "is required"},
+			},
+		},
+		{
+			testName: "other errors are unknown",
+			err:      fmt.Errorf("connection reset"),
+			code:     codes.Unknown,
+			message:  "Unsupported error",
+			reason:   "UNKNOWN",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			err := errors.Status(tc.err).Err()
+			st, _ := status.FromError(err)
+			reason, violations := errors.Describe(st)
+
+			// assert
+			assert.Equal(tc.code, st.Code())
+			assert.Equal(tc.message, st.Message())
+			assert.Equal(tc.reason, reason)
+			assert.Equal(tc.violations, violations)
+		})
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	testCases := []struct {
+		testName string
+		st       *status.Status
+		res      int
+	}{
+		{
+			testName: "avatar too large",
+			st:       errors.Status(errors.NewAvatarTooLargeError()),
+			res:      413,
+		},
+		{
+			testName: "details modified",
+			st:       errors.Status(errors.NewDetailsModifiedError()),
+			res:      412,
+		},
+		{
+			testName: "exhausted quota",
+			st:       status.New(codes.ResourceExhausted, "Quota exhausted"),
+			res:      429,
+		},
+		{
+			testName: "custom error",
+			st:       errors.Status(errors.NewUserNotFoundError()),
+			res:      404,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// act
+			res := errors.HTTPStatus(tc.st)
+
+			// assert
+			assert.Equal(tc.res, res)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned when a cursor was not produced by EncodeCursor
+var ErrInvalidCursor = errors.New("invalid change feed cursor")
+
+// cursorPrefix versions the format of the cursors, the consumers keep them so they must stay readable
+const cursorPrefix = "v1."
+
+// Change struct stores one entry of a change feed and the cursor which resumes the feed after it. The relays
+// assign the positions when they publish the events, in the order their writes become visible: once a feed
+// returns a position it returns every lower position too, so a cursor never skips a change. The positions may
+// have gaps and the feeds trail the data changes by the interval of the relays
+type Change struct {
+	Event
+	Cursor string
+}
+
+// EncodeCursor returns the opaque cursor pointing after the given position of a feed
+func EncodeCursor(position int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(position, 10)))
+}
+
+// DecodeCursor returns the position of the cursor, the empty cursor points to the start of the feed
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+
+	position, err := strconv.ParseInt(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil || position < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return position, nil
+}
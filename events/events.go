@@ -0,0 +1,121 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Type describes the kind of change an event announces
+type Type string
+
+// Types of the events published by the services
+const (
+	UserCreated    Type = "UserCreated"
+	UserUpdated    Type = "UserUpdated"
+	UserDeleted    Type = "UserDeleted"
+	DetailsChanged Type = "DetailsChanged"
+)
+
+// SubjectPrefix is the first token of every subject, the subjects look like users.<tenant>.<type>
+const SubjectPrefix = "users"
+
+// Event struct stores a change of one user, Data carries the new values without secrets
+type Event struct {
+	ID         string                 `json:"id" bson:"id"`
+	Type       Type                   `json:"type" bson:"type"`
+	Tenant     string                 `json:"tenant" bson:"tenant"`
+	UserID     int                    `json:"user_id" bson:"user_id"`
+	OccurredAt time.Time              `json:"occurred_at" bson:"occurred_at"`
+	Data       map[string]interface{} `json:"data,omitempty" bson:"data,omitempty"`
+}
+
+// New returns an Event with a random id which occurred now
+func New(t Type, tenantID string, userID int, data map[string]interface{}) Event {
+	return Event{
+		ID:         newID(),
+		Type:       t,
+		Tenant:     tenantID,
+		UserID:     userID,
+		OccurredAt: time.Now().UTC().Truncate(time.Millisecond),
+		Data:       data,
+	}
+}
+
+// Subject returns the subject where the event is published
+func (e Event) Subject() string {
+	return fmt.Sprintf("%v.%v.%v", SubjectPrefix, e.Tenant, e.Type)
+}
+
+// Kinds of bus, the services publish and subscribe from different processes so NewBus only accepts NATSKind
+const (
+	MemoryKind = "memory"
+	NATSKind   = "nats"
+)
+
+// NewBus returns the bus of the given kind for the services, the url is only used by the NATS bus. The memory
+// bus is refused since its events never leave the process, the services would lose every event silently
+func NewBus(kind string, url string) (Bus, error) {
+	switch kind {
+	case MemoryKind:
+		return nil, fmt.Errorf("the %q event bus only reaches the subscribers of its own process, use %q", MemoryKind, NATSKind)
+	case NATSKind:
+		b, err := NewNATSBus(url)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown event bus %q", kind)
+	}
+}
+
+// Handler is called once per event received by a subscription
+type Handler func(e Event)
+
+// Subscription describes an active subscription of a bus
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// Publisher describes the publishing side of a bus, Publish returns once the bus accepted the event
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Bus describes an event bus, subjects of the subscriptions may use the NATS wildcards
+// '*' to match one token and '>' to match every remaining token
+type Bus interface {
+	Publisher
+	Subscribe(subject string, h Handler) (Subscription, error)
+	Close() error
+}
+
+// Match reports whether the subject is matched by the pattern
+func Match(pattern string, subject string) bool {
+	p := strings.Split(pattern, ".")
+	s := strings.Split(subject, ".")
+
+	for i, token := range p {
+		if token == ">" {
+			return i < len(s)
+		}
+
+		if i >= len(s) || (token != "*" && token != s[i]) {
+			return false
+		}
+	}
+
+	return len(p) == len(s)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,511 @@
+package events_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		testName string
+		pattern  string
+		subject  string
+		res      bool
+	}{
+		{
+			testName: "same subject",
+			pattern:  "users.acme.UserCreated",
+			subject:  "users.acme.UserCreated",
+			res:      true,
+		},
+		{
+			testName: "different token",
+			pattern:  "users.acme.UserCreated",
+			subject:  "users.acme.UserDeleted",
+			res:      false,
+		},
+		{
+			testName: "single token wildcard",
+			pattern:  "users.*.UserCreated",
+			subject:  "users.acme.UserCreated",
+			res:      true,
+		},
+		{
+			testName: "single token wildcard does not match more tokens",
+			pattern:  "users.*",
+			subject:  "users.acme.UserCreated",
+			res:      false,
+		},
+		{
+			testName: "tail wildcard",
+			pattern:  "users.>",
+			subject:  "users.acme.UserCreated",
+			res:      true,
+		},
+		{
+			testName: "tail wildcard needs one token",
+			pattern:  "users.acme.>",
+			subject:  "users.acme",
+			res:      false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// act
+			res := events.Match(tc.pattern, tc.subject)
+
+			// assert
+			assert.Equal(tc.res, res)
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	testCases := []struct {
+		testName string
+		cursor   string
+		position int64
+		err      error
+	}{
+		{
+			testName: "start of the feed",
+			cursor:   "",
+			position: 0,
+		},
+		{
+			testName: "encoded position",
+			cursor:   events.EncodeCursor(4096),
+			position: 4096,
+		},
+		{
+			testName: "not base64",
+			cursor:   "%%%",
+			err:      events.ErrInvalidCursor,
+		},
+		{
+			testName: "unknown version",
+			cursor:   "djIuMTI",
+			err:      events.ErrInvalidCursor,
+		},
+		{
+			testName: "negative position",
+			cursor:   events.EncodeCursor(-1),
+			err:      events.ErrInvalidCursor,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// act
+			res, err := events.DecodeCursor(tc.cursor)
+
+			// assert
+			assert.Equal(tc.err, err)
+			if tc.err == nil {
+				assert.Equal(tc.position, res)
+			}
+		})
+	}
+}
+
+func TestMemoryBus(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	bus := events.NewMemoryBus()
+	var all, created []events.Event
+
+	_, err := bus.Subscribe("users.>", func(e events.Event) { all = append(all, e) })
+	assert.NoError(err)
+	sub, err := bus.Subscribe("users.*.UserCreated", func(e events.Event) { created = append(created, e) })
+	assert.NoError(err)
+
+	first := events.New(events.UserCreated, "acme", 1, nil)
+	second := events.New(events.UserDeleted, "acme", 1, nil)
+	third := events.New(events.UserCreated, "acme", 2, nil)
+
+	// act
+	assert.NoError(bus.Publish(context.Background(), first))
+	assert.NoError(bus.Publish(context.Background(), second))
+	assert.NoError(sub.Unsubscribe())
+	assert.NoError(bus.Publish(context.Background(), third))
+
+	// assert
+	assert.Equal([]events.Event{first, second, third}, all)
+	assert.Equal([]events.Event{first}, created)
+}
+
+type fakeOutbox struct {
+	pending   []events.Event
+	published []string
+	before    time.Time
+}
+
+func (o *fakeOutbox) Pending(ctx context.Context, limit int) ([]events.Event, error) {
+	if len(o.pending) < limit {
+		limit = len(o.pending)
+	}
+	return append([]events.Event(nil), o.pending[:limit]...), nil
+}
+
+func (o *fakeOutbox) MarkPublished(ctx context.Context, ids []string) error {
+	o.published = append(o.published, ids...)
+	o.pending = o.pending[len(ids):]
+	return nil
+}
+
+func (o *fakeOutbox) Prune(ctx context.Context, before time.Time) (int64, error) {
+	o.before = before
+	return 0, nil
+}
+
+type fakePublisher struct {
+	events []events.Event
+	failAt int
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, e events.Event) error {
+	if len(p.events) == p.failAt {
+		return errors.New("bus unavailable")
+	}
+	p.events = append(p.events, e)
+	return nil
+}
+
+func TestRelayFlush(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+
+	testCases := []struct {
+		testName  string
+		events    int
+		failAt    int
+		published int
+		err       bool
+	}{
+		{
+			testName:  "empty outbox",
+			events:    0,
+			failAt:    -1,
+			published: 0,
+		},
+		{
+			testName:  "several batches",
+			events:    7,
+			failAt:    -1,
+			published: 7,
+		},
+		{
+			testName:  "stops at the first failure",
+			events:    7,
+			failAt:    4,
+			published: 4,
+			err:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// prepare
+			outbox := &fakeOutbox{}
+			for i := 0; i < tc.events; i++ {
+				outbox.pending = append(outbox.pending, events.New(events.UserUpdated, "acme", i, nil))
+			}
+			expected := append([]events.Event(nil), outbox.pending[:tc.published]...)
+			publisher := &fakePublisher{failAt: tc.failAt}
+			relay := events.NewRelay(outbox, publisher, time.Second, 3, 0, logger)
+
+			// act
+			n, err := relay.Flush(context.Background())
+
+			// assert
+			assert.Equal(tc.published, n)
+			assert.Equal(tc.err, err != nil)
+			assert.Equal(len(expected), len(publisher.events))
+			for i, e := range expected {
+				assert.Equal(e.ID, publisher.events[i].ID)
+				assert.Equal(e.ID, outbox.published[i])
+			}
+			assert.Len(outbox.pending, tc.events-tc.published)
+		})
+	}
+}
+
+func TestRelayPrune(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+
+	testCases := []struct {
+		testName  string
+		retention time.Duration
+		res       time.Duration
+	}{
+		{
+			testName:  "given retention",
+			retention: time.Hour,
+			res:       time.Hour,
+		},
+		{
+			testName:  "default retention",
+			retention: 0,
+			res:       events.DefaultRelayRetention,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// prepare
+			outbox := &fakeOutbox{}
+			relay := events.NewRelay(outbox, &fakePublisher{failAt: -1}, time.Second, 3, tc.retention, logger)
+
+			// act
+			_, err := relay.Prune(context.Background())
+
+			// assert
+			assert.NoError(err)
+			assert.WithinDuration(time.Now().Add(-tc.res), outbox.before, time.Minute)
+		})
+	}
+}
+
+func TestNewBus(t *testing.T) {
+	testCases := []struct {
+		testName string
+		kind     string
+	}{
+		{
+			testName: "memory bus",
+			kind:     events.MemoryKind,
+		},
+		{
+			testName: "unknown bus",
+			kind:     "kafka",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// act
+			bus, err := events.NewBus(tc.kind, "nats://127.0.0.1:1")
+
+			// assert
+			assert.Nil(bus)
+			assert.Error(err)
+		})
+	}
+}
+
+// natsServer is a tiny subset of a nats-server which routes the messages to the subscriptions of every client
+type natsServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[string]net.Conn
+	subs     map[string]map[string]*bufio.Writer
+	reject   string
+}
+
+func newNATSServer(t *testing.T) *natsServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &natsServer{listener: l, conns: make(map[string]net.Conn), subs: make(map[string]map[string]*bufio.Writer)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *natsServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	client := conn.RemoteAddr().String()
+
+	s.mu.Lock()
+	s.conns[client] = conn
+	s.mu.Unlock()
+
+	write := func(format string, args ...interface{}) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		fmt.Fprintf(writer, format, args...)
+		writer.Flush()
+	}
+
+	write("INFO {\"server_id\":\"test\"}\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "PING":
+			write("PONG\r\n")
+		case "SUB":
+			s.mu.Lock()
+			if s.subs[client] == nil {
+				s.subs[client] = make(map[string]*bufio.Writer)
+			}
+			s.subs[client][fields[1]+" "+fields[2]] = writer
+			s.mu.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			if fields[1] == s.reject {
+				write("-ERR 'Permissions Violation for Publish to %v'\r\n", fields[1])
+				continue
+			}
+			s.route(fields[1], payload[:size])
+		}
+	}
+}
+
+// kick closes the connections of every client along with their subscriptions
+func (s *natsServer) kick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client, conn := range s.conns {
+		conn.Close()
+		delete(s.conns, client)
+		delete(s.subs, client)
+	}
+}
+
+func (s *natsServer) route(subject string, payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, subs := range s.subs {
+		for key, w := range subs {
+			parts := strings.Fields(key)
+			if events.Match(parts[0], subject) {
+				fmt.Fprintf(w, "MSG %v %v %v\r\n%s\r\n", subject, parts[1], len(payload), payload)
+				w.Flush()
+			}
+		}
+	}
+}
+
+func TestNATSBus(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	server := newNATSServer(t)
+	defer server.listener.Close()
+	server.reject = "users.blocked.UserCreated"
+
+	bus, err := events.NewNATSBus("nats://" + server.listener.Addr().String())
+	if !assert.NoError(err) {
+		return
+	}
+	defer bus.Close()
+
+	received := make(chan events.Event, 1)
+	_, err = bus.Subscribe("users.acme.*", func(e events.Event) { received <- e })
+	assert.NoError(err)
+
+	e := events.New(events.UserCreated, "acme", 1, map[string]interface{}{"email": "user@email.com"})
+
+	// act
+	publishErr := bus.Publish(context.Background(), e)
+	rejectedErr := bus.Publish(context.Background(), events.New(events.UserCreated, "blocked", 2, nil))
+
+	// assert
+	assert.NoError(publishErr)
+	assert.Error(rejectedErr)
+
+	select {
+	case got := <-received:
+		assert.Equal(e, got)
+	case <-time.After(time.Second):
+		t.Fatal("event not received")
+	}
+
+	assert.NoError(bus.Close())
+	assert.Equal(events.ErrClosed, bus.Publish(context.Background(), e))
+}
+
+func TestNATSBusReconnect(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	server := newNATSServer(t)
+	defer server.listener.Close()
+	addr := "nats://" + server.listener.Addr().String()
+
+	subscriber, err := events.NewNATSBus(addr)
+	if !assert.NoError(err) {
+		return
+	}
+	defer subscriber.Close()
+
+	received := make(chan events.Event, 16)
+	_, err = subscriber.Subscribe("users.acme.*", func(e events.Event) { received <- e })
+	assert.NoError(err)
+
+	// act
+	server.kick()
+
+	publisher, err := events.NewNATSBus(addr)
+	if !assert.NoError(err) {
+		return
+	}
+	defer publisher.Close()
+
+	// assert
+	e := events.New(events.UserUpdated, "acme", 1, nil)
+	deadline := time.After(3 * time.Second)
+	for {
+		assert.NoError(publisher.Publish(context.Background(), e))
+
+		select {
+		case got := <-received:
+			assert.Equal(e, got)
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the subscriber did not subscribe again")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBus implements the Bus interface within the process, the handlers are called synchronously by Publish
+type MemoryBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]memorySubscription
+}
+
+type memorySubscription struct {
+	subject string
+	handler Handler
+}
+
+// NewMemoryBus returns a MemoryBus pointer type without subscriptions
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subs: make(map[int]memorySubscription),
+	}
+}
+
+// Publish delivers the event to every subscription which matches its subject
+func (b *MemoryBus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	var handlers []Handler
+	for _, s := range b.subs {
+		if Match(s.subject, e.Subject()) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+
+	return ctx.Err()
+}
+
+// Subscribe registers the handler for the subjects matched by subject
+func (b *MemoryBus) Subscribe(subject string, h Handler) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.subs[id] = memorySubscription{subject: subject, handler: h}
+
+	return unsubscriber(func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subs, id)
+		return nil
+	}), nil
+}
+
+// Close removes every subscription
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs = make(map[int]memorySubscription)
+	return nil
+}
+
+type unsubscriber func() error
+
+func (u unsubscriber) Unsubscribe() error {
+	return u()
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Default settings of the relay
+const (
+	DefaultRelayInterval  = time.Second
+	DefaultRelayBatch     = 100
+	DefaultRelayRetention = 30 * 24 * time.Hour
+)
+
+// pruneInterval is how often the relay removes the published events older than the retention
+const pruneInterval = time.Hour
+
+// Outbox describes the storage where the services write the events in the same transaction as the data change
+type Outbox interface {
+	Pending(ctx context.Context, limit int) ([]Event, error)
+	MarkPublished(ctx context.Context, ids []string) error
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Relay moves the events from an outbox to a bus, every event is published at least once
+// and the events of one user keep their order
+type Relay struct {
+	outbox    Outbox
+	bus       Publisher
+	interval  time.Duration
+	batch     int
+	retention time.Duration
+	logger    log.Logger
+}
+
+// NewRelay returns a Relay pointer type, non positive settings fall back to the defaults. The published events
+// are kept for the retention as the history of changes, the cursors older than it skip the pruned events
+func NewRelay(o Outbox, p Publisher, interval time.Duration, batch int, retention time.Duration, logger log.Logger) *Relay {
+	if interval <= 0 {
+		interval = DefaultRelayInterval
+	}
+
+	if batch <= 0 {
+		batch = DefaultRelayBatch
+	}
+
+	if retention <= 0 {
+		retention = DefaultRelayRetention
+	}
+
+	return &Relay{
+		outbox:    o,
+		bus:       p,
+		interval:  interval,
+		batch:     batch,
+		retention: retention,
+		logger:    log.With(logger, "component", "relay"),
+	}
+}
+
+// Run flushes the outbox every interval and prunes it every hour until the context is canceled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	var pruned time.Time
+	for {
+		if n, err := r.Flush(ctx); err != nil {
+			level.Error(r.logger).Log("published", n, "err", err)
+		}
+
+		if time.Since(pruned) >= pruneInterval {
+			if n, err := r.Prune(ctx); err != nil {
+				level.Error(r.logger).Log("pruned", n, "err", err)
+			}
+			pruned = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes the pending events in order until the outbox is empty or a publication fails,
+// it returns the number of published events
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	total := 0
+
+	for {
+		pending, err := r.outbox.Pending(ctx, r.batch)
+		if err != nil || len(pending) == 0 {
+			return total, err
+		}
+
+		published := make([]string, 0, len(pending))
+		var publishErr error
+		for _, e := range pending {
+			if publishErr = r.bus.Publish(ctx, e); publishErr != nil {
+				break
+			}
+			published = append(published, e.ID)
+		}
+
+		if len(published) > 0 {
+			if err := r.outbox.MarkPublished(ctx, published); err != nil {
+				return total, err
+			}
+			total += len(published)
+		}
+
+		if publishErr != nil || len(pending) < r.batch {
+			return total, publishErr
+		}
+	}
+}
+
+// Prune removes the published events older than the retention, it returns the number of removed events
+func (r *Relay) Prune(ctx context.Context) (int64, error) {
+	return r.outbox.Prune(ctx, time.Now().UTC().Add(-r.retention))
+}
@@ -0,0 +1,208 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// connectCodes are the names and the HTTP statuses of the codes within the Connect protocol
+var connectCodes = map[codes.Code]struct {
+	name       string
+	httpStatus int
+}{
+	codes.Canceled:           {"canceled", 499},
+	codes.Unknown:            {"unknown", http.StatusInternalServerError},
+	codes.InvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	codes.DeadlineExceeded:   {"deadline_exceeded", http.StatusGatewayTimeout},
+	codes.NotFound:           {"not_found", http.StatusNotFound},
+	codes.AlreadyExists:      {"already_exists", http.StatusConflict},
+	codes.PermissionDenied:   {"permission_denied", http.StatusForbidden},
+	codes.ResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	codes.FailedPrecondition: {"failed_precondition", http.StatusBadRequest},
+	codes.Aborted:            {"aborted", http.StatusConflict},
+	codes.OutOfRange:         {"out_of_range", http.StatusBadRequest},
+	codes.Unimplemented:      {"unimplemented", http.StatusNotImplemented},
+	codes.Internal:           {"internal", http.StatusInternalServerError},
+	codes.Unavailable:        {"unavailable", http.StatusServiceUnavailable},
+	codes.DataLoss:           {"data_loss", http.StatusInternalServerError},
+	codes.Unauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+// connectDetail is a detail of the status, value holds the proto message and debug its JSON for the humans
+type connectDetail struct {
+	Type  string          `json:"type"`
+	Value string          `json:"value"`
+	Debug json.RawMessage `json:"debug,omitempty"`
+}
+
+func newConnectError(st *status.Status) *connectError {
+	e := &connectError{Code: connectCodes[st.Code()].name, Message: st.Message()}
+	if e.Code == "" {
+		e.Code = connectCodes[codes.Unknown].name
+	}
+
+	for _, detail := range st.Proto().GetDetails() {
+		d := connectDetail{
+			Type:  detail.GetTypeUrl()[strings.LastIndex(detail.GetTypeUrl(), "/")+1:],
+			Value: base64.RawStdEncoding.EncodeToString(detail.GetValue()),
+		}
+		if m, err := detail.UnmarshalNew(); err == nil {
+			d.Debug, _ = protojson.Marshal(m)
+		}
+		e.Details = append(e.Details, d)
+	}
+
+	return e
+}
+
+func writeConnectError(rw http.ResponseWriter, st *status.Status) {
+	httpStatus, ok := connectCodes[st.Code()]
+	if !ok {
+		httpStatus = connectCodes[codes.Unknown]
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(httpStatus.httpStatus)
+	json.NewEncoder(rw).Encode(newConnectError(st))
+}
+
+// timeout turns the Connect timeout into a gRPC one, the gRPC timeouts have at most 8 digits
+func timeout(r *http.Request) (string, error) {
+	v := r.Header.Get("Connect-Timeout-Ms")
+	if v == "" {
+		return "", nil
+	}
+
+	ms, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || len(v) > 10 {
+		return "", status.Errorf(codes.InvalidArgument, "invalid Connect-Timeout-Ms %q", v)
+	}
+	if ms > 99999999 {
+		return strconv.FormatUint((ms+999)/1000, 10) + "S", nil
+	}
+	return strconv.FormatUint(ms, 10) + "m", nil
+}
+
+// serveConnectUnary serves the Connect unary requests, their bodies are the bare messages and the errors are JSON
+// objects sent with the HTTP status of their code
+func (h *Handler) serveConnectUnary(rw http.ResponseWriter, r *http.Request, codec string) {
+	if enc := r.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		writeConnectError(rw, status.Newf(codes.Unimplemented, "unsupported content encoding %q", enc))
+		return
+	}
+
+	t, err := timeout(r)
+	if err != nil {
+		writeConnectError(rw, status.Convert(err))
+		return
+	}
+
+	message, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		writeConnectError(rw, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+	if len(message) > maxMessageSize {
+		writeConnectError(rw, status.Newf(codes.ResourceExhausted, "the message is larger than %v bytes", maxMessageSize))
+		return
+	}
+
+	w := &unaryWriter{header: http.Header{}}
+	h.forward(w, r, bytes.NewReader(frame(0, message)), codec, t)
+
+	for k, vv := range headers(w.header) {
+		rw.Header()[k] = vv
+	}
+	trailer := trailers(w.header)
+	for k, vv := range trailer {
+		if !strings.HasPrefix(k, "Grpc-") {
+			rw.Header()["Trailer-"+k] = vv
+		}
+	}
+
+	st := statusOf(trailer)
+	if st.Code() != codes.OK {
+		writeConnectError(rw, st)
+		return
+	}
+
+	body := w.body.Bytes()
+	if len(body) < 5 || len(body[5:]) < int(binary.BigEndian.Uint32(body[1:5])) {
+		writeConnectError(rw, status.New(codes.Internal, "the server sent no message"))
+		return
+	}
+	rw.Header().Set("Content-Type", "application/"+codec)
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(body[5 : 5+binary.BigEndian.Uint32(body[1:5])])
+}
+
+// unaryWriter is the ResponseWriter given to the gRPC server for the Connect unary requests, the response is kept
+// until the status is known since it decides the HTTP status
+type unaryWriter struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *unaryWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *unaryWriter) WriteHeader(int) {}
+
+func (w *unaryWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+func (w *unaryWriter) Flush() {}
+
+// serveConnectStream serves the Connect streaming requests, their messages are framed as the gRPC ones and the
+// status and the trailers are sent as a JSON object within the last frame
+func (h *Handler) serveConnectStream(rw http.ResponseWriter, r *http.Request, contentType string, codec string) {
+	w := &streamWriter{rw: rw, header: http.Header{}, contentType: contentType}
+
+	end := struct {
+		Error    *connectError       `json:"error,omitempty"`
+		Metadata map[string][]string `json:"metadata,omitempty"`
+	}{}
+
+	if enc := r.Header.Get("Connect-Content-Encoding"); enc != "" && enc != "identity" {
+		end.Error = newConnectError(status.Newf(codes.Unimplemented, "unsupported content encoding %q", enc))
+	} else if t, err := timeout(r); err != nil {
+		end.Error = newConnectError(status.Convert(err))
+	} else {
+		h.forward(w, r, r.Body, codec, t)
+
+		trailer := trailers(w.header)
+		if st := statusOf(trailer); st.Code() != codes.OK {
+			end.Error = newConnectError(st)
+		}
+		for k, vv := range trailer {
+			if !strings.HasPrefix(k, "Grpc-") {
+				if end.Metadata == nil {
+					end.Metadata = map[string][]string{}
+				}
+				end.Metadata[k] = vv
+			}
+		}
+	}
+
+	payload, _ := json.Marshal(end)
+	w.Write(frame(endStreamFlag, payload))
+	w.Flush()
+}
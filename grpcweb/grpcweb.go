@@ -0,0 +1,311 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	connectContentType     = "application/connect"
+
+	// maxMessageSize is the default size of the messages received by the gRPC server
+	maxMessageSize = 4 << 20
+
+	// trailerFlag marks the frame of the gRPC-Web trailers, endStreamFlag the last frame of a Connect stream
+	trailerFlag   = 0x80
+	endStreamFlag = 0x02
+)
+
+// exposedHeaders are the response headers the browsers let the clients read
+var exposedHeaders = strings.Join([]string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin", "Content-Type"}, ", ")
+
+// Handler serves the services registered on a gRPC server to the clients which can't speak native gRPC: the
+// gRPC-Web requests, binary or base64 text, and the Connect requests, unary or streaming, are translated into gRPC
+// requests handled in process, the messages are encoded as proto or as JSON. The native gRPC requests made over
+// HTTP/2 are handed to the server as they are
+type Handler struct {
+	server  *grpc.Server
+	origins map[string]bool
+	methods map[string]bool
+}
+
+// NewHandler returns a Handler of the server, the browsers are allowed to call it from the origins, "*" allows any
+// origin. Only the methods, given by their full name like /UserService/GetUser, are served, the other ones are not
+// found whatever the protocol is
+func NewHandler(server *grpc.Server, origins []string, methods []string) *Handler {
+	allowed := map[string]bool{}
+	for _, o := range origins {
+		allowed[strings.TrimSpace(o)] = true
+	}
+
+	exposed := map[string]bool{}
+	for _, m := range methods {
+		exposed[strings.TrimSpace(m)] = true
+	}
+
+	return &Handler{server: server, origins: allowed, methods: exposed}
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !h.origins["*"] && !h.origins[origin] {
+			http.Error(rw, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+		rw.Header().Add("Vary", "Origin")
+		rw.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			rw.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+			rw.Header().Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+			rw.Header().Set("Access-Control-Max-Age", "7200")
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "the procedures are called by POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// the clients of every protocol read 404 as an unimplemented procedure
+	if !h.methods[r.URL.Path] {
+		http.Error(rw, "procedure not found", http.StatusNotFound)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	base, codec := mediaType, "proto"
+	if i := strings.Index(mediaType, "+"); i >= 0 {
+		base, codec = mediaType[:i], mediaType[i+1:]
+	}
+	if codec != "proto" && codec != "json" {
+		unsupported(rw)
+		return
+	}
+
+	switch {
+	case base == "application/grpc" && r.ProtoMajor == 2:
+		h.server.ServeHTTP(rw, r)
+	case base == grpcWebContentType || base == grpcWebTextContentType:
+		h.serveGrpcWeb(rw, r, mediaType, codec, base == grpcWebTextContentType)
+	case base == connectContentType:
+		h.serveConnectStream(rw, r, mediaType, codec)
+	case mediaType == "application/proto" || mediaType == "application/json":
+		h.serveConnectUnary(rw, r, strings.TrimPrefix(mediaType, "application/"))
+	default:
+		unsupported(rw)
+	}
+}
+
+func unsupported(rw http.ResponseWriter) {
+	rw.Header().Set("Accept-Post", "application/grpc-web, application/grpc-web-text, application/connect, application/proto, application/json")
+	http.Error(rw, "unsupported content type", http.StatusUnsupportedMediaType)
+}
+
+// forward hands the request to the gRPC server as a native gRPC request whose body is the framed messages
+func (h *Handler) forward(rw http.ResponseWriter, r *http.Request, body io.Reader, codec string, timeout string) {
+	req := r.Clone(r.Context())
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2", 2, 0
+	req.Body = io.NopCloser(body)
+	req.ContentLength = -1
+	req.Header.Del("Content-Length")
+	req.Header.Del("Connect-Protocol-Version")
+	req.Header.Del("Connect-Timeout-Ms")
+
+	contentType := "application/grpc"
+	if codec == "json" {
+		contentType += "+json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if timeout != "" {
+		req.Header.Set("Grpc-Timeout", timeout)
+	}
+
+	h.server.ServeHTTP(rw, req)
+}
+
+func (h *Handler) serveGrpcWeb(rw http.ResponseWriter, r *http.Request, contentType string, codec string, text bool) {
+	var body io.Reader = r.Body
+	if text {
+		raw, err := io.ReadAll(io.LimitReader(r.Body, int64(base64.StdEncoding.EncodedLen(maxMessageSize+5))))
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		decoded, err := decodeText(raw)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = bytes.NewReader(decoded)
+	}
+
+	w := &streamWriter{rw: rw, header: http.Header{}, contentType: contentType, text: text}
+	h.forward(w, r, body, codec, r.Header.Get("Grpc-Timeout"))
+
+	// the trailers are sent as the last frame since the browsers can't read the HTTP trailers
+	trailer := trailers(w.header)
+	var b bytes.Buffer
+	keys := make([]string, 0, len(trailer))
+	for k := range trailer {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range trailer[k] {
+			fmt.Fprintf(&b, "%v: %v\r\n", strings.ToLower(k), v)
+		}
+	}
+	w.Write(frame(trailerFlag, b.Bytes()))
+	w.Flush()
+}
+
+// streamWriter is the ResponseWriter given to the gRPC server for the streaming protocols, the messages reach the
+// client as they are written while the HTTP trailers set by the server are kept to be sent as the last frame
+type streamWriter struct {
+	rw          http.ResponseWriter
+	header      http.Header
+	contentType string
+	text        bool
+
+	pending     bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *streamWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *streamWriter) WriteHeader(int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.rw.Header()
+	for k, vv := range headers(w.header) {
+		h[k] = vv
+	}
+	h.Set("Content-Type", w.contentType)
+	w.rw.WriteHeader(http.StatusOK)
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.text {
+		// the text chunks are encoded on flush so every message is written as whole base64 quanta
+		return w.pending.Write(p)
+	}
+	return w.rw.Write(p)
+}
+
+func (w *streamWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if w.text && w.pending.Len() > 0 {
+		io.WriteString(w.rw, base64.StdEncoding.EncodeToString(w.pending.Bytes()))
+		w.pending.Reset()
+	}
+	if f, ok := w.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// headers returns the headers set by the gRPC server without the ones sent as trailers
+func headers(h http.Header) http.Header {
+	res := http.Header{}
+	for k, vv := range h {
+		switch {
+		case k == "Trailer", k == "Content-Type", strings.HasPrefix(k, http.TrailerPrefix):
+		case k == "Grpc-Status", k == "Grpc-Message", k == "Grpc-Status-Details-Bin":
+		default:
+			res[k] = vv
+		}
+	}
+	return res
+}
+
+// trailers returns the trailers set by the gRPC server, the status and the metadata set with SetTrailer
+func trailers(h http.Header) http.Header {
+	res := http.Header{}
+	for k, vv := range h {
+		switch {
+		case k == "Grpc-Status", k == "Grpc-Message", k == "Grpc-Status-Details-Bin":
+			res[k] = vv
+		case strings.HasPrefix(k, http.TrailerPrefix):
+			res[http.CanonicalHeaderKey(strings.TrimPrefix(k, http.TrailerPrefix))] = vv
+		}
+	}
+	return res
+}
+
+// statusOf returns the status the gRPC server set within the trailers
+func statusOf(trailer http.Header) *status.Status {
+	code, err := strconv.Atoi(trailer.Get("Grpc-Status"))
+	if err != nil {
+		return status.New(codes.Internal, "the server sent no status")
+	}
+
+	if bin := trailer.Get("Grpc-Status-Details-Bin"); bin != "" {
+		raw, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(bin, "="))
+		s := &spb.Status{}
+		if err == nil && proto.Unmarshal(raw, s) == nil {
+			return status.FromProto(s)
+		}
+	}
+
+	message, err := url.PathUnescape(trailer.Get("Grpc-Message"))
+	if err != nil {
+		message = trailer.Get("Grpc-Message")
+	}
+	return status.New(codes.Code(code), message)
+}
+
+// frame prefixes the payload with its flags and its length as the gRPC, the gRPC-Web and the Connect frames are
+func frame(flags byte, payload []byte) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// decodeText decodes the body of a gRPC-Web text request, the clients may send several padded base64 chunks so
+// every quantum is decoded on its own
+func decodeText(raw []byte) ([]byte, error) {
+	raw = bytes.Join(bytes.Fields(raw), nil)
+	if len(raw)%4 != 0 {
+		return nil, fmt.Errorf("the body is not base64")
+	}
+
+	res := make([]byte, 0, len(raw)/4*3)
+	quantum := make([]byte, 3)
+	for i := 0; i < len(raw); i += 4 {
+		n, err := base64.StdEncoding.Decode(quantum, raw[i:i+4])
+		if err != nil {
+			return nil, fmt.Errorf("the body is not base64")
+		}
+		res = append(res, quantum[:n]...)
+	}
+	return res, nil
+}
@@ -0,0 +1,361 @@
+package grpcweb_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/grpcweb"
+	"github.com/mauricioww/user_microsrv/user_srv/userpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+type userServer struct {
+	userpb.UnimplementedUserServiceServer
+}
+
+func (userServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.GetUserResponse, error) {
+	grpc.SetTrailer(ctx, metadata.Pairs("served-by", "test"))
+	if req.GetId() != 1 {
+		return nil, errors.Status(errors.NewUserNotFoundError()).Err()
+	}
+	return &userpb.GetUserResponse{Email: "user@email.com", Age: 26, DateOfBirth: "1996-02-29"}, nil
+}
+
+func (userServer) WatchUser(req *userpb.WatchUserRequest, stream userpb.UserService_WatchUserServer) error {
+	for _, t := range []string{"user.created", "user.updated"} {
+		if err := stream.Send(&userpb.Change{Type: t, UserId: req.GetId()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newServer() *httptest.Server {
+	server := grpc.NewServer()
+	userpb.RegisterUserServiceServer(server, userServer{})
+	return httptest.NewServer(grpcweb.NewHandler(server, []string{"https://app.example.com"}, []string{"/UserService/GetUser", "/UserService/WatchUser"}))
+}
+
+func envelope(flags byte, payload []byte) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// frames splits the body into the flags and the payloads of its frames
+func frames(body []byte) ([]byte, [][]byte) {
+	var flags []byte
+	var payloads [][]byte
+	for len(body) >= 5 {
+		n := binary.BigEndian.Uint32(body[1:5])
+		flags = append(flags, body[0])
+		payloads = append(payloads, body[5:5+n])
+		body = body[5+n:]
+	}
+	return flags, payloads
+}
+
+// decodeChunks decodes the base64 chunks of a text response, every chunk is padded on its own
+func decodeChunks(body []byte) []byte {
+	var res []byte
+	quantum := make([]byte, 3)
+	for i := 0; i+4 <= len(body); i += 4 {
+		n, _ := base64.StdEncoding.Decode(quantum, body[i:i+4])
+		res = append(res, quantum[:n]...)
+	}
+	return res
+}
+
+func post(url string, contentType string, body []byte) (*http.Response, []byte) {
+	res, err := http.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	defer res.Body.Close()
+
+	read, _ := io.ReadAll(res.Body)
+	return res, read
+}
+
+func TestGrpcWeb(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	found, _ := proto.Marshal(&userpb.GetUserRequest{Id: 1})
+	missing, _ := proto.Marshal(&userpb.GetUserRequest{Id: 2})
+
+	test_cases := []struct {
+		testName    string
+		contentType string
+		body        []byte
+		text        bool
+		message     string
+		trailer     string
+	}{
+		{
+			testName:    "proto message success",
+			contentType: "application/grpc-web+proto",
+			body:        envelope(0, found),
+			message:     "user@email.com",
+			trailer:     "grpc-status: 0\r\nserved-by: test\r\n",
+		},
+		{
+			testName:    "json message success",
+			contentType: "application/grpc-web+json",
+			body:        envelope(0, []byte(`{"id":1}`)),
+			message:     `"dateOfBirth":`,
+			trailer:     "grpc-status: 0\r\nserved-by: test\r\n",
+		},
+		{
+			testName:    "text message success",
+			contentType: "application/grpc-web-text",
+			body:        []byte(base64.StdEncoding.EncodeToString(envelope(0, found))),
+			text:        true,
+			message:     "user@email.com",
+			trailer:     "grpc-status: 0\r\nserved-by: test\r\n",
+		},
+		{
+			testName:    "user not found error",
+			contentType: "application/grpc-web",
+			body:        envelope(0, missing),
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			res, body := post(server.URL+"/UserService/GetUser", tc.contentType, tc.body)
+
+			// assert
+			assert.Equal(http.StatusOK, res.StatusCode)
+			assert.Equal(tc.contentType, res.Header.Get("Content-Type"))
+			if tc.text {
+				body = decodeChunks(body)
+			}
+			flags, payloads := frames(body)
+			if tc.message == "" {
+				assert.Equal([]byte{0x80}, flags)
+				assert.Contains(string(payloads[0]), "grpc-status: 5\r\n")
+				assert.Contains(string(payloads[0]), "grpc-status-details-bin: ")
+				return
+			}
+			if assert.Equal([]byte{0, 0x80}, flags) {
+				assert.Contains(string(payloads[0]), tc.message)
+				assert.Equal(tc.trailer, string(payloads[1]))
+			}
+		})
+	}
+}
+
+func TestConnectUnary(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	found, _ := proto.Marshal(&userpb.GetUserRequest{Id: 1})
+
+	test_cases := []struct {
+		testName    string
+		contentType string
+		body        []byte
+		httpStatus  int
+		servedBy    string
+		res         string
+	}{
+		{
+			testName:    "json message success",
+			contentType: "application/json",
+			body:        []byte(`{"id":1}`),
+			httpStatus:  http.StatusOK,
+			servedBy:    "test",
+			res:         `{"email":"user@email.com","age":26,"dateOfBirth":"1996-02-29"}`,
+		},
+		{
+			testName:    "proto message success",
+			contentType: "application/proto",
+			body:        found,
+			httpStatus:  http.StatusOK,
+			servedBy:    "test",
+		},
+		{
+			testName:    "user not found error",
+			contentType: "application/json",
+			body:        []byte(`{"id":2}`),
+			httpStatus:  http.StatusNotFound,
+			servedBy:    "test",
+			res: `{"code":"not_found","message":"User not found","details":[{"type":"google.rpc.ErrorInfo","value":"Cg5VU0VSX05PVF9GT1VORBINdXNlcl9taWNyb3Nydg",` +
+				`"debug":{"reason":"USER_NOT_FOUND","domain":"user_microsrv"}}]}`,
+		},
+		{
+			testName:    "malformed json error",
+			contentType: "application/json",
+			body:        []byte(`{"id":`),
+			httpStatus:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			res, body := post(server.URL+"/UserService/GetUser", tc.contentType, tc.body)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Equal(tc.servedBy, res.Header.Get("Trailer-Served-By"))
+			switch {
+			case tc.res != "":
+				assert.JSONEq(tc.res, string(body))
+			case tc.httpStatus == http.StatusOK:
+				var user userpb.GetUserResponse
+				assert.NoError(proto.Unmarshal(body, &user))
+				assert.Equal("user@email.com", user.GetEmail())
+			default:
+				assert.Equal("application/json", res.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestConnectStream(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	server := newServer()
+	defer server.Close()
+
+	// act
+	res, body := post(server.URL+"/UserService/WatchUser", "application/connect+json", envelope(0, []byte(`{"id":7}`)))
+
+	// assert
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("application/connect+json", res.Header.Get("Content-Type"))
+	flags, payloads := frames(body)
+	if assert.Equal([]byte{0, 0, 2}, flags) {
+		assert.JSONEq(`{"type":"user.created","userId":7}`, string(payloads[0]))
+		assert.JSONEq(`{"type":"user.updated","userId":7}`, string(payloads[1]))
+		assert.JSONEq(`{}`, string(payloads[2]))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	test_cases := []struct {
+		testName    string
+		method      string
+		procedure   string
+		origin      string
+		contentType string
+		httpStatus  int
+		headers     map[string]string
+	}{
+		{
+			testName:   "preflight success",
+			method:     http.MethodOptions,
+			procedure:  "/UserService/GetUser",
+			origin:     "https://app.example.com",
+			httpStatus: http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "POST",
+				"Access-Control-Allow-Headers": "content-type,x-grpc-web",
+			},
+		},
+		{
+			testName:   "preflight from unknown origin error",
+			method:     http.MethodOptions,
+			procedure:  "/UserService/GetUser",
+			origin:     "https://evil.example.com",
+			httpStatus: http.StatusForbidden,
+		},
+		{
+			testName:    "unsupported content type error",
+			method:      http.MethodPost,
+			procedure:   "/UserService/GetUser",
+			contentType: "text/plain",
+			httpStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			testName:    "unsupported codec error",
+			method:      http.MethodPost,
+			procedure:   "/UserService/GetUser",
+			contentType: "application/grpc-web+thrift",
+			httpStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			testName:    "procedure not exposed error",
+			method:      http.MethodPost,
+			procedure:   "/UserService/DeleteUser",
+			contentType: "application/grpc-web+proto",
+			httpStatus:  http.StatusNotFound,
+		},
+		{
+			testName:   "method not allowed error",
+			method:     http.MethodGet,
+			procedure:  "/UserService/GetUser",
+			httpStatus: http.StatusMethodNotAllowed,
+			headers:    map[string]string{"Allow": "POST"},
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			req, _ := http.NewRequest(tc.method, server.URL+tc.procedure, strings.NewReader(""))
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+				req.Header.Set("Access-Control-Request-Method", "POST")
+				req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+			}
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			// act
+			res, err := http.DefaultClient.Do(req)
+
+			// assert
+			assert.NoError(err)
+			res.Body.Close()
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			for k, v := range tc.headers {
+				assert.Equal(v, res.Header.Get(k))
+			}
+		})
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	server := newServer()
+	defer server.Close()
+
+	// act
+	_, body := post(server.URL+"/UserService/GetUser", "application/json", []byte(`{"id":1,"unknown":true}`))
+	var user map[string]interface{}
+	err := json.Unmarshal(body, &user)
+
+	// assert
+	assert.NoError(err)
+	assert.Equal("user@email.com", user["email"])
+}
@@ -0,0 +1,48 @@
+package guard
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata key used to carry the token shared by the services
+const MetadataKey = "x-internal-token"
+
+// UnaryServerInterceptor rejects the calls to the given methods which do not carry the token, the methods are
+// meant for the other services only, like the purge of a user whose creation is undone
+func UnaryServerInterceptor(token string, methods ...string) grpc.UnaryServerInterceptor {
+	guarded := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		guarded[m] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if guarded[info.FullMethod] && !valid(ctx, token) {
+			return nil, errors.LocalizedStatus(ctx, errors.NewForbiddenError()).Err()
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func valid(ctx context.Context, token string) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+
+	return token != "" && len(values) == 1 && subtle.ConstantTimeCompare([]byte(values[0]), []byte(token)) == 1
+}
+
+// UnaryClientInterceptor sends the token as outgoing metadata of every call
+func UnaryClientInterceptor(token string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, token)
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
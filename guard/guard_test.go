@@ -0,0 +1,86 @@
+package guard_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/guard"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := guard.UnaryServerInterceptor("secret", "/UserService/PurgeUser")
+
+	testCases := []struct {
+		testName string
+		method   string
+		md       metadata.MD
+		res      interface{}
+		code     codes.Code
+	}{
+		{
+			testName: "guarded method with token",
+			method:   "/UserService/PurgeUser",
+			md:       metadata.Pairs(guard.MetadataKey, "secret"),
+			res:      "called",
+		},
+		{
+			testName: "open method without token",
+			method:   "/UserService/GetUser",
+			md:       metadata.MD{},
+			res:      "called",
+		},
+		{
+			testName: "guarded method without token error",
+			method:   "/UserService/PurgeUser",
+			md:       metadata.MD{},
+			code:     codes.PermissionDenied,
+		},
+		{
+			testName: "guarded method with wrong token error",
+			method:   "/UserService/PurgeUser",
+			md:       metadata.Pairs(guard.MetadataKey, "guess"),
+			code:     codes.PermissionDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return "called", nil
+			}
+
+			// act
+			res, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.code, status.Code(err))
+		})
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	var sent []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get(guard.MetadataKey)
+		return nil
+	}
+
+	// act
+	err := guard.UnaryClientInterceptor("secret")(context.Background(), "/UserService/PurgeUser", nil, nil, nil, invoker)
+
+	// assert
+	assert.Nil(err)
+	assert.Equal([]string{"secret"}, sent)
+}
@@ -3,6 +3,7 @@ package helpers
 import (
 	"context"
 
+	"github.com/mauricioww/user_microsrv/tenant"
 	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -19,13 +20,20 @@ func BuildUpdateBson(d entities.UserDetails) bson.D {
 func BuildInsertBson(d entities.UserDetails) bson.M {
 	b := injectFields(d)
 	b["_id"] = d.UserID
+	b["tenant_id"] = d.TenantID
 	return b
 }
 
+// TenantFilter returns a filter which matches the given id only within the tenant stored in the context
+func TenantFilter(ctx context.Context, id interface{}) bson.D {
+	t, _ := tenant.FromContext(ctx)
+	return bson.D{{"_id", id}, {"tenant_id", t}}
+}
+
 // NoExists returns true if the user is not into the database otherwise returns false
 func NoExists(ctx context.Context, coll *mongo.Collection, id int) bool {
 	var results entities.UserDetails
-	if err := coll.FindOne(ctx, bson.D{{"_id", id}}).Decode(&results); err == mongo.ErrNoDocuments {
+	if err := coll.FindOne(ctx, TenantFilter(ctx, id)).Decode(&results); err == mongo.ErrNoDocuments {
 		return true
 	}
 	return !results.Active
@@ -33,12 +41,14 @@ func NoExists(ctx context.Context, coll *mongo.Collection, id int) bool {
 
 func injectFields(d entities.UserDetails) bson.M {
 	return bson.M{
-		"country":       d.Country,
-		"city":          d.City,
-		"mobile_number": d.MobileNumber,
-		"married":       d.Married,
-		"height":        d.Height,
-		"weight":        d.Weight,
-		"active":        d.Active,
+		"country":         d.Country,
+		"city":            d.City,
+		"mobile_number":   d.MobileNumber,
+		"mobile_verified": d.MobileVerified,
+		"married":         d.Married,
+		"height":          d.Height,
+		"weight":          d.Weight,
+		"attributes":      d.Attributes,
+		"active":          d.Active,
 	}
 }
@@ -0,0 +1,39 @@
+package bulk
+
+import (
+	"context"
+
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/stretchr/testify/mock"
+)
+
+// CreatorMock type is used to mock the creation of the imported users
+type CreatorMock struct {
+	mock.Mock
+}
+
+// CreateUser is a mock of the real method
+func (c *CreatorMock) CreateUser(ctx context.Context, user entities.User) (int, error) {
+	args := c.Called(ctx, user)
+
+	return args.Int(0), args.Error(1)
+}
+
+// TakenEmails is a mock of the real method
+func (c *CreatorMock) TakenEmails(ctx context.Context, emails []string) ([]string, error) {
+	args := c.Called(ctx, emails)
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// ListerMock type is used to mock the pages of users of the exports
+type ListerMock struct {
+	mock.Mock
+}
+
+// ListUsers is a mock of the real method
+func (l *ListerMock) ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, int, error) {
+	args := l.Called(ctx, afterID, limit)
+
+	return args.Get(0).([]entities.User), args.Int(1), args.Error(2)
+}
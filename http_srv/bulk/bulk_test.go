@@ -0,0 +1,244 @@
+package bulk_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestImport(t *testing.T) {
+	csvFile := strings.Join([]string{
+		"email,password,date_of_birth,country,married,height_m",
+		"first@domain.com,secret,1990-01-02,Mexico,true,1.70",
+		"second@domain.com,secret,1991-03-04,Mexico,false,",
+		",secret,1992-05-06,Mexico,false,",
+		"first@domain.com,secret,1990-01-02,Mexico,false,",
+		"third@domain.com,secret,1993-07-08,Mexico,maybe,",
+		"taken@domain.com,secret,1994-09-10,Mexico,false,",
+	}, "\n")
+
+	jsonlFile := strings.Join([]string{
+		`{"email": "first@domain.com", "password": "secret", "date_of_birth": "1990-01-02", "information": {"country": "Mexico"}}`,
+		``,
+		`{"email": "second@domain.com", "password": "secret", "date_of_birth": "2990-01-02"}`,
+		`{"email": "third@domain.com"`,
+	}, "\n")
+
+	testCases := []struct {
+		testName  string
+		format    string
+		data      string
+		dryRun    bool
+		batchSize int
+		taken     []string
+		takenErr  error
+		rows      []bulk.RowResult
+		succeeded int
+	}{
+		{
+			testName:  "csv import with invalid rows",
+			format:    bulk.CSV,
+			data:      csvFile,
+			batchSize: 2,
+			rows: []bulk.RowResult{
+				{Line: 2, Email: "first@domain.com", UserID: 1},
+				{Line: 3, Email: "second@domain.com", UserID: 2},
+				{Line: 4, Error: "Missing field 'email'"},
+				{Line: 5, Email: "first@domain.com", Error: "User already exists"},
+				{Line: 6, Error: "invalid field 'married', expected true or false"},
+				{Line: 7, Email: "taken@domain.com", Error: "User already exists"},
+			},
+			succeeded: 2,
+		},
+		{
+			testName:  "csv dry run does not create users",
+			format:    bulk.CSV,
+			data:      csvFile,
+			dryRun:    true,
+			batchSize: 10,
+			taken:     []string{"TAKEN@domain.com"},
+			rows: []bulk.RowResult{
+				{Line: 2, Email: "first@domain.com"},
+				{Line: 3, Email: "second@domain.com"},
+				{Line: 4, Error: "Missing field 'email'"},
+				{Line: 5, Email: "first@domain.com", Error: "User already exists"},
+				{Line: 6, Error: "invalid field 'married', expected true or false"},
+				{Line: 7, Email: "taken@domain.com", Error: "User already exists"},
+			},
+			succeeded: 2,
+		},
+		{
+			testName:  "csv dry run with unavailable server",
+			format:    bulk.CSV,
+			data:      csvFile,
+			dryRun:    true,
+			batchSize: 10,
+			takenErr:  status.Error(codes.Unavailable, "connection refused"),
+			rows: []bulk.RowResult{
+				{Line: 2, Email: "first@domain.com", Error: "connection refused"},
+				{Line: 3, Email: "second@domain.com", Error: "connection refused"},
+				{Line: 4, Error: "Missing field 'email'"},
+				{Line: 5, Email: "first@domain.com", Error: "User already exists"},
+				{Line: 6, Error: "invalid field 'married', expected true or false"},
+				{Line: 7, Email: "taken@domain.com", Error: "connection refused"},
+			},
+			succeeded: 0,
+		},
+		{
+			testName:  "jsonl import with invalid rows",
+			format:    bulk.JSONL,
+			data:      jsonlFile,
+			batchSize: 10,
+			rows: []bulk.RowResult{
+				{Line: 1, Email: "first@domain.com", UserID: 1},
+				{Line: 3, Email: "second@domain.com", Error: "Invalid field 'date_of_birth', expected a past date as YYYY-MM-DD"},
+				{Line: 4, Error: "invalid JSON object"},
+			},
+			succeeded: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			creator := new(bulk.CreatorMock)
+			creator.On("CreateUser", ctx, mock.MatchedBy(func(u entities.User) bool { return u.Email == "first@domain.com" })).Return(1, nil)
+			creator.On("CreateUser", ctx, mock.MatchedBy(func(u entities.User) bool { return u.Email == "second@domain.com" })).Return(2, nil)
+			creator.On("CreateUser", ctx, mock.MatchedBy(func(u entities.User) bool { return u.Email == "taken@domain.com" })).
+				Return(-1, status.Error(codes.AlreadyExists, "User already exists"))
+			creator.On("TakenEmails", ctx, []string{"first@domain.com", "second@domain.com", "taken@domain.com"}).Return(tc.taken, tc.takenErr)
+			rows, err := bulk.NewReader(tc.format, strings.NewReader(tc.data))
+			assert.Nil(err)
+
+			// act
+			res, err := bulk.NewImporter(creator, tc.batchSize, 2).Import(ctx, rows, tc.dryRun)
+
+			// assert
+			assert.Nil(err)
+			assert.Equal(tc.rows, res.Rows)
+			assert.Equal(len(tc.rows), res.Total)
+			assert.Equal(tc.succeeded, res.Succeeded)
+			assert.Equal(len(tc.rows)-tc.succeeded, res.Failed)
+			if tc.dryRun {
+				creator.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+			} else {
+				creator.AssertNotCalled(t, "TakenEmails", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	testCases := []struct {
+		testName string
+		format   string
+		data     string
+		err      string
+	}{
+		{
+			testName: "unsupported format error",
+			format:   "xml",
+			err:      "Invalid format, expected 'csv' or 'jsonl'",
+		},
+		{
+			testName: "empty csv error",
+			format:   bulk.CSV,
+			err:      "Invalid import file: missing CSV header",
+		},
+		{
+			testName: "unknown column error",
+			format:   bulk.CSV,
+			data:     "email,password,date_of_birth,nickname\n",
+			err:      "Invalid import file: unknown column 'nickname'",
+		},
+		{
+			testName: "missing column error",
+			format:   bulk.CSV,
+			data:     "email,date_of_birth\n",
+			err:      "Invalid import file: missing column 'password'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			_, err := bulk.NewReader(tc.format, strings.NewReader(tc.data))
+
+			// assert
+			assert.EqualError(err, tc.err)
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	testCases := []struct {
+		testName string
+		format   string
+		res      string
+	}{
+		{
+			testName: "csv export over two pages",
+			format:   bulk.CSV,
+			res: "id,email,password,date_of_birth,country,city,mobile_number,mobile_verified,married,height_m,weight_kg,attributes\n" +
+				"1,first@domain.com,,1990-01-02,Mexico,CDMX,+525511223344,true,false,1.75,76,\"{\"\"plan\"\":\"\"pro\"\"}\"\n" +
+				"5,second@domain.com,,1991-03-04,,,,false,false,0,0,\n",
+		},
+		{
+			testName: "jsonl export over two pages",
+			format:   bulk.JSONL,
+			res: `{"id":1,"email":"first@domain.com","date_of_birth":"1990-01-02","information":{"country":"Mexico","city":"CDMX","mobile_number":"+525511223344","mobile_verified":true,"married":false,"height_m":1.75,"weight_kg":76,"attributes":{"plan":"pro"}}}` + "\n" +
+				`{"id":5,"email":"second@domain.com","date_of_birth":"1991-03-04","information":{"country":"","city":"","mobile_number":"","mobile_verified":false,"married":false,"height_m":0,"weight_kg":0}}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			lister := new(bulk.ListerMock)
+			lister.On("ListUsers", ctx, 0, 1).Return([]entities.User{
+				{
+					ID:          1,
+					Email:       "first@domain.com",
+					DateOfBirth: "1990-01-02",
+					Details: entities.Details{
+						Country:        "Mexico",
+						City:           "CDMX",
+						MobileNumber:   "+525511223344",
+						MobileVerified: true,
+						Height:         1.75,
+						Weight:         76,
+						Attributes:     map[string]interface{}{"plan": "pro"},
+					},
+				},
+			}, 1, nil)
+			lister.On("ListUsers", ctx, 1, 1).Return([]entities.User{
+				{ID: 5, Email: "second@domain.com", DateOfBirth: "1991-03-04"},
+			}, 0, nil)
+			var buf bytes.Buffer
+			w, _ := bulk.NewWriter(tc.format, &buf)
+
+			// act
+			total, err := bulk.Export(ctx, lister, w, 1)
+
+			// assert
+			assert.Nil(err)
+			assert.Equal(2, total)
+			assert.Equal(tc.res, buf.String())
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package bulk
+
+import (
+	"context"
+
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+)
+
+// DefaultPageSize is the number of users requested to the servers on every page of an export
+const DefaultPageSize = 200
+
+// Lister describes the operation used to page through the users of both stores, the HTTPRepository implements it
+type Lister interface {
+	ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, int, error)
+}
+
+// Export writes every user of the tenant to the writer page by page and returns the number of exported users
+func Export(ctx context.Context, l Lister, w Writer, pageSize int) (int, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	total := 0
+	after := 0
+	for {
+		users, next, err := l.ListUsers(ctx, after, pageSize)
+		if err != nil {
+			return total, err
+		}
+
+		for _, u := range users {
+			record := Record{
+				ID:          u.ID,
+				Email:       u.Email,
+				DateOfBirth: u.DateOfBirth,
+				Details:     u.Details,
+			}
+
+			if err := w.Write(record); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		if err := w.Flush(); err != nil {
+			return total, err
+		}
+
+		if next == 0 {
+			return total, nil
+		}
+		after = next
+	}
+}
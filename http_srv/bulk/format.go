@@ -0,0 +1,284 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+)
+
+// Supported formats of the import and export files
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+// maxLineSize is the longest JSONL row accepted by the reader
+const maxLineSize = 1 << 20
+
+// columns stores the CSV header written by the exports, imports accept any subset in any order
+// as long as email, password and date_of_birth are present, id and mobile_verified are ignored
+var columns = []string{
+	"id", "email", "password", "date_of_birth", "country", "city", "mobile_number",
+	"mobile_verified", "married", "height_m", "weight_kg", "attributes",
+}
+
+var requiredColumns = []string{"email", "password", "date_of_birth"}
+
+// Record struct stores one row of an import or export file, it uses the same JSON shape as the users endpoint
+type Record struct {
+	ID          int              `json:"id,omitempty"`
+	Email       string           `json:"email"`
+	Password    string           `json:"password,omitempty"`
+	DateOfBirth string           `json:"date_of_birth"`
+	Details     entities.Details `json:"information"`
+}
+
+// RowError is returned by the readers when a single row is malformed, the following rows can still be read
+type RowError struct {
+	Reason string
+}
+
+func (e RowError) Error() string {
+	return e.Reason
+}
+
+// Reader describes a stream of records, Read returns io.EOF once the stream is over
+type Reader interface {
+	Read() (Record, int, error)
+}
+
+// Writer describes a sink of records, Flush must be called once every record was written
+type Writer interface {
+	Write(record Record) error
+	Flush() error
+}
+
+// ValidFormat reports whether the format is supported
+func ValidFormat(format string) bool {
+	return format == CSV || format == JSONL
+}
+
+// NewReader returns the reader of the format, CSV readers consume the header immediately
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case JSONL:
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		return &jsonlReader{scanner: s}, nil
+	default:
+		return nil, errors.NewInvalidFormatError()
+	}
+}
+
+// NewWriter returns the writer of the format, CSV writers write the header immediately
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: cw}, nil
+	case JSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, errors.NewInvalidFormatError()
+	}
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	index  map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.NewInvalidImportError("missing CSV header")
+	}
+	if err != nil {
+		return nil, errors.NewInvalidImportError(err.Error())
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		known[c] = true
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			return nil, errors.NewInvalidImportError(fmt.Sprintf("unknown column '%v'", name))
+		}
+		index[name] = i
+	}
+
+	for _, name := range requiredColumns {
+		if _, ok := index[name]; !ok {
+			return nil, errors.NewInvalidImportError(fmt.Sprintf("missing column '%v'", name))
+		}
+	}
+
+	return &csvReader{
+		reader: reader,
+		index:  index,
+	}, nil
+}
+
+func (c *csvReader) Read() (Record, int, error) {
+	fields, err := c.reader.Read()
+
+	if e, ok := err.(*csv.ParseError); ok {
+		return Record{}, e.StartLine, RowError{Reason: e.Err.Error()}
+	}
+
+	if err != nil {
+		return Record{}, 0, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+
+	if len(fields) != len(c.index) {
+		return Record{}, line, RowError{Reason: fmt.Sprintf("expected %v fields, got %v", len(c.index), len(fields))}
+	}
+
+	get := func(name string) string {
+		if i, ok := c.index[name]; ok {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	record := Record{
+		Email:       get("email"),
+		Password:    get("password"),
+		DateOfBirth: get("date_of_birth"),
+		Details: entities.Details{
+			Country:      get("country"),
+			City:         get("city"),
+			MobileNumber: get("mobile_number"),
+		},
+	}
+
+	if v := get("married"); v != "" {
+		if record.Details.Married, err = strconv.ParseBool(v); err != nil {
+			return Record{}, line, RowError{Reason: "invalid field 'married', expected true or false"}
+		}
+	}
+
+	if v := get("height_m"); v != "" {
+		h, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return Record{}, line, RowError{Reason: "invalid field 'height_m', expected a number"}
+		}
+		record.Details.Height = float32(h)
+	}
+
+	if v := get("weight_kg"); v != "" {
+		w, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return Record{}, line, RowError{Reason: "invalid field 'weight_kg', expected a number"}
+		}
+		record.Details.Weight = float32(w)
+	}
+
+	if v := get("attributes"); v != "" {
+		if err := json.Unmarshal([]byte(v), &record.Details.Attributes); err != nil {
+			return Record{}, line, RowError{Reason: "invalid field 'attributes', expected a JSON object"}
+		}
+	}
+
+	return record, line, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlReader) Read() (Record, int, error) {
+	for j.scanner.Scan() {
+		j.line++
+
+		text := strings.TrimSpace(j.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return Record{}, j.line, RowError{Reason: "invalid JSON object"}
+		}
+
+		record.ID = 0
+		record.Details.MobileVerified = false
+		return record, j.line, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return Record{}, j.line, errors.NewInvalidImportError(err.Error())
+	}
+
+	return Record{}, j.line, io.EOF
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvWriter) Write(record Record) error {
+	var attributes string
+	if len(record.Details.Attributes) > 0 {
+		raw, err := json.Marshal(record.Details.Attributes)
+		if err != nil {
+			return err
+		}
+		attributes = string(raw)
+	}
+
+	return c.writer.Write([]string{
+		strconv.Itoa(record.ID),
+		record.Email,
+		"",
+		record.DateOfBirth,
+		record.Details.Country,
+		record.Details.City,
+		record.Details.MobileNumber,
+		strconv.FormatBool(record.Details.MobileVerified),
+		strconv.FormatBool(record.Details.Married),
+		strconv.FormatFloat(float64(record.Details.Height), 'f', -1, 32),
+		strconv.FormatFloat(float64(record.Details.Weight), 'f', -1, 32),
+		attributes,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonlWriter) Write(record Record) error {
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
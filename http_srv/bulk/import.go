@@ -0,0 +1,243 @@
+package bulk
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"google.golang.org/grpc/status"
+)
+
+// Default settings of the importer
+const (
+	DefaultBatchSize = 100
+	DefaultWorkers   = 8
+)
+
+// dateLayout is the layout expected within the date_of_birth field
+const dateLayout = "2006-01-02"
+
+// maxCheckSize is the most emails the user gRPC server checks within one call
+const maxCheckSize = 100
+
+// Creator describes the operations used to create every imported user and to find, on dry runs, the emails
+// which already belong to a user, the HTTPRepository implements it
+type Creator interface {
+	CreateUser(ctx context.Context, user entities.User) (int, error)
+	TakenEmails(ctx context.Context, emails []string) ([]string, error)
+}
+
+// RowResult struct stores the outcome of one row of the import file
+type RowResult struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email,omitempty"`
+	UserID int    `json:"user_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report struct stores the outcome of an import, on dry runs Succeeded counts the rows that passed the validations
+type Report struct {
+	DryRun    bool        `json:"dry_run"`
+	Total     int         `json:"total"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Rows      []RowResult `json:"rows"`
+}
+
+// Importer creates the users of an import file in batches. A batch only groups the rows whose emails are checked
+// together and bounds how many of them run at once, each row is still created on its own by one CreateUser call,
+// so a failed row does not undo the others
+type Importer struct {
+	creator   Creator
+	batchSize int
+	workers   int
+}
+
+// NewImporter returns an Importer pointer type, non positive settings fall back to the defaults
+func NewImporter(c Creator, batchSize int, workers int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	return &Importer{
+		creator:   c,
+		batchSize: batchSize,
+		workers:   workers,
+	}
+}
+
+// pending struct stores a validated row waiting to be created
+type pending struct {
+	result *RowResult
+	record Record
+}
+
+// Import validates every row of the reader and creates the valid ones, on dry runs their emails are only
+// checked against the existing users, the report keeps the order of the file
+func (i *Importer) Import(ctx context.Context, r Reader, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, Rows: []RowResult{}}
+	seen := make(map[string]bool)
+
+	for {
+		batch := make([]RowResult, 0, i.batchSize)
+		records := make([]Record, 0, i.batchSize)
+
+		eof := false
+		for len(batch) < i.batchSize {
+			record, line, err := r.Read()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+
+			if e, ok := err.(RowError); ok {
+				batch = append(batch, RowResult{Line: line, Error: e.Error()})
+				records = append(records, Record{})
+				continue
+			}
+
+			if err != nil {
+				return report, err
+			}
+
+			res := RowResult{Line: line, Email: record.Email}
+			if err := validate(record, seen); err != nil {
+				res.Error = err.Error()
+			}
+			batch = append(batch, res)
+			records = append(records, record)
+		}
+
+		var valid []pending
+		for j := range batch {
+			if batch[j].Error == "" {
+				valid = append(valid, pending{result: &batch[j], record: records[j]})
+			}
+		}
+
+		if dryRun {
+			i.check(ctx, valid)
+		} else {
+			i.create(ctx, valid)
+		}
+
+		for _, res := range batch {
+			report.Total++
+			if res.Error == "" {
+				report.Succeeded++
+			} else {
+				report.Failed++
+			}
+		}
+		report.Rows = append(report.Rows, batch...)
+
+		if eof {
+			return report, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+	}
+}
+
+// create sends the rows of one batch to the creator one at a time using a fixed number of workers
+func (i *Importer) create(ctx context.Context, rows []pending) {
+	jobs := make(chan pending)
+	var wg sync.WaitGroup
+
+	for w := 0; w < i.workers && w < len(rows); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				user := entities.User{
+					Email:       p.record.Email,
+					Password:    p.record.Password,
+					DateOfBirth: p.record.DateOfBirth,
+					Details:     p.record.Details,
+				}
+
+				id, err := i.creator.CreateUser(ctx, user)
+				if err != nil {
+					p.result.Error = status.Convert(err).Message()
+					continue
+				}
+				p.result.UserID = id
+			}
+		}()
+	}
+
+	for _, p := range rows {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// check marks the rows of one batch whose email already belongs to a user, the rows are sent to the creator
+// in chunks of up to maxCheckSize emails and a failed chunk fails each of its rows
+func (i *Importer) check(ctx context.Context, rows []pending) {
+	for start := 0; start < len(rows); start += maxCheckSize {
+		end := start + maxCheckSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		chunk := rows[start:end]
+
+		emails := make([]string, len(chunk))
+		for j, p := range chunk {
+			emails[j] = p.record.Email
+		}
+
+		taken, err := i.creator.TakenEmails(ctx, emails)
+		if err != nil {
+			for _, p := range chunk {
+				p.result.Error = status.Convert(err).Message()
+			}
+			continue
+		}
+
+		conflicts := make(map[string]bool, len(taken))
+		for _, e := range taken {
+			conflicts[strings.ToLower(e)] = true
+		}
+		for _, p := range chunk {
+			if conflicts[strings.ToLower(p.record.Email)] {
+				p.result.Error = errors.NewUserAlreadyExistsError().Error()
+			}
+		}
+	}
+}
+
+// validate runs the checks that do not need the servers, seen stores every email already read
+func validate(record Record, seen map[string]bool) error {
+	if record.Email == "" {
+		return errors.NewBadRequestEmailError()
+	}
+
+	if record.Password == "" {
+		return errors.NewBadRequestPasswordError()
+	}
+
+	dob, err := time.Parse(dateLayout, record.DateOfBirth)
+	if err != nil || !dob.Before(time.Now()) {
+		return errors.NewInvalidDateOfBirthError()
+	}
+
+	email := strings.ToLower(record.Email)
+	if seen[email] {
+		return errors.NewUserAlreadyExistsError()
+	}
+	seen[email] = true
+
+	return nil
+}
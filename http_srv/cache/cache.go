@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/repository"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"golang.org/x/sync/singleflight"
+)
+
+// Backend describes the storage of the cached entries, the values are opaque bytes so a shared cache
+// can replace the in-memory one, a missing or expired key is not an error
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Metrics stores the counters reported by the cache, nil counters are discarded
+type Metrics struct {
+	Hits      metrics.Counter
+	Misses    metrics.Counter
+	Evictions metrics.Counter
+}
+
+func (m Metrics) withDefaults() Metrics {
+	if m.Hits == nil {
+		m.Hits = discard.NewCounter()
+	}
+	if m.Misses == nil {
+		m.Misses = discard.NewCounter()
+	}
+	if m.Evictions == nil {
+		m.Evictions = discard.NewCounter()
+	}
+	return m
+}
+
+// Repository decorates an HTTPRepositorier with a read-through cache of GetUser, the writes which change
+// the aggregated user invalidate its entry and every other method goes straight to the decorated repository
+type Repository struct {
+	// generation counts the invalidations, a load started before any invalidation is returned but not stored
+	generation uint64
+
+	repository.HTTPRepositorier
+	backend Backend
+	ttl     time.Duration
+	metrics Metrics
+	group   singleflight.Group
+	logger  log.Logger
+}
+
+// NewRepository returns a Repository pointer type which keeps the users within the backend for ttl
+func NewRepository(r repository.HTTPRepositorier, backend Backend, ttl time.Duration, m Metrics, logger log.Logger) *Repository {
+	return &Repository{
+		HTTPRepositorier: r,
+		backend:          backend,
+		ttl:              ttl,
+		metrics:          m.withDefaults(),
+		logger:           log.With(logger, "http_service", "cache"),
+	}
+}
+
+// GetUser returns the cached user, on a miss the concurrent requests of the same user share one load
+func (c *Repository) GetUser(ctx context.Context, id int) (entities.User, error) {
+	logger := log.With(c.logger, "method", "get_user")
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return c.HTTPRepositorier.GetUser(ctx, id)
+	}
+	key := userKey(t, id)
+
+	if raw, found, err := c.backend.Get(ctx, key); err != nil {
+		level.Error(logger).Log("err_cache", err)
+	} else if found {
+		var u entities.User
+		decodeErr := gob.NewDecoder(bytes.NewReader(raw)).Decode(&u)
+		if decodeErr == nil {
+			c.metrics.Hits.Add(1)
+			return u, nil
+		}
+		level.Error(logger).Log("err_cache", decodeErr)
+	}
+
+	c.metrics.Misses.Add(1)
+
+	res, err, _ := c.group.Do(key, func() (interface{}, error) {
+		generation := atomic.LoadUint64(&c.generation)
+
+		u, err := c.HTTPRepositorier.GetUser(ctx, id)
+		if err != nil {
+			return u, err
+		}
+
+		// a user without its details is not stored so the details are fetched again once they are back
+		if u.DetailsStatus == "" && atomic.LoadUint64(&c.generation) == generation {
+			c.store(ctx, key, u)
+		}
+		return u, nil
+	})
+
+	u, _ := res.(entities.User)
+	return u, err
+}
+
+// UpdateUser updates the user and invalidates its entry
+func (c *Repository) UpdateUser(ctx context.Context, user entities.UserUpdate) (bool, error) {
+	defer c.invalidate(ctx, user.UserID)
+	return c.HTTPRepositorier.UpdateUser(ctx, user)
+}
+
+// DeleteUser deletes the user and invalidates its entry
+func (c *Repository) DeleteUser(ctx context.Context, id int) (bool, error) {
+	defer c.invalidate(ctx, id)
+	return c.HTTPRepositorier.DeleteUser(ctx, id)
+}
+
+// SetUserDetails replaces the details of the user and invalidates its entry
+func (c *Repository) SetUserDetails(ctx context.Context, userID int, details entities.Details, revision int64) (bool, error) {
+	defer c.invalidate(ctx, userID)
+	return c.HTTPRepositorier.SetUserDetails(ctx, userID, details, revision)
+}
+
+// DeleteUserDetails deletes the details of the user and invalidates its entry
+func (c *Repository) DeleteUserDetails(ctx context.Context, userID int) (bool, error) {
+	defer c.invalidate(ctx, userID)
+	return c.HTTPRepositorier.DeleteUserDetails(ctx, userID)
+}
+
+// VerifyPhone verifies the mobile number of the user and invalidates its entry
+func (c *Repository) VerifyPhone(ctx context.Context, userID int, code string) (bool, error) {
+	defer c.invalidate(ctx, userID)
+	return c.HTTPRepositorier.VerifyPhone(ctx, userID, code)
+}
+
+// SetAvatar sets the avatar of the user and invalidates its entry
+func (c *Repository) SetAvatar(ctx context.Context, userID int, ref string) (bool, error) {
+	defer c.invalidate(ctx, userID)
+	return c.HTTPRepositorier.SetAvatar(ctx, userID, ref)
+}
+
+// invalidate removes the entry of the user, it runs even when the write failed since the write may
+// have been applied partially
+func (c *Repository) invalidate(ctx context.Context, id int) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return
+	}
+	key := userKey(t, id)
+
+	atomic.AddUint64(&c.generation, 1)
+	c.group.Forget(key)
+	if err := c.backend.Delete(ctx, key); err != nil {
+		level.Error(c.logger).Log("method", "invalidate", "err_cache", err)
+	}
+}
+
+func (c *Repository) store(ctx context.Context, key string, u entities.User) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(u); err != nil {
+		level.Error(c.logger).Log("method", "store", "err_cache", err)
+		return
+	}
+
+	if err := c.backend.Set(ctx, key, buf.Bytes(), c.ttl); err != nil {
+		level.Error(c.logger).Log("method", "store", "err_cache", err)
+	}
+}
+
+func userKey(tenantID string, id int) string {
+	return fmt.Sprintf("user:%v:%v", tenantID, id)
+}
@@ -0,0 +1,180 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/go-kit/log"
+	"github.com/mauricioww/user_microsrv/http_srv/cache"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/repository"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeRepository counts the loads of each user, the loads wait for release when it is set
+type fakeRepository struct {
+	repository.HTTPRepositorier
+	loads   int32
+	release chan struct{}
+	email   string
+	err     error
+}
+
+func (f *fakeRepository) GetUser(ctx context.Context, id int) (entities.User, error) {
+	atomic.AddInt32(&f.loads, 1)
+	if f.release != nil {
+		<-f.release
+	}
+	return entities.User{ID: id, Email: f.email, Details: entities.Details{Avatar: "avatar.png"}}, f.err
+}
+
+func (f *fakeRepository) UpdateUser(ctx context.Context, user entities.UserUpdate) (bool, error) {
+	f.email = user.Email
+	return true, nil
+}
+
+func (f *fakeRepository) DeleteUser(ctx context.Context, id int) (bool, error) {
+	return true, nil
+}
+
+func newCache(f *fakeRepository, capacity int) (*cache.Repository, cache.Metrics) {
+	m := cache.Metrics{
+		Hits:      generic.NewCounter("hits"),
+		Misses:    generic.NewCounter("misses"),
+		Evictions: generic.NewCounter("evictions"),
+	}
+	backend := cache.NewMemoryBackend(capacity, m.Evictions)
+	return cache.NewRepository(f, backend, time.Minute, m, log.NewNopLogger()), m
+}
+
+func TestGetUser(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	f := &fakeRepository{email: "user@email.com"}
+	c, m := newCache(f, 10)
+	ctx := tenant.NewContext(context.Background(), "acme")
+
+	// act
+	first, firstErr := c.GetUser(ctx, 1)
+	second, secondErr := c.GetUser(ctx, 1)
+	_, _ = c.GetUser(tenant.NewContext(context.Background(), "other"), 1)
+
+	// assert
+	assert.NoError(firstErr)
+	assert.NoError(secondErr)
+	assert.Equal(first, second)
+	assert.Equal("avatar.png", second.Avatar)
+	assert.Equal(int32(2), f.loads)
+	assert.Equal(1.0, m.Hits.(*generic.Counter).Value())
+	assert.Equal(2.0, m.Misses.(*generic.Counter).Value())
+}
+
+func TestGetUserErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	f := &fakeRepository{err: status.Error(codes.NotFound, "User not found")}
+	c, _ := newCache(f, 10)
+	ctx := tenant.NewContext(context.Background(), "acme")
+
+	// act
+	_, firstErr := c.GetUser(ctx, 1)
+	_, secondErr := c.GetUser(ctx, 1)
+	_, _ = c.GetUser(context.Background(), 1)
+	_, _ = c.GetUser(context.Background(), 1)
+
+	// assert
+	assert.Equal(codes.NotFound, status.Code(firstErr))
+	assert.Equal(codes.NotFound, status.Code(secondErr))
+	assert.Equal(int32(4), f.loads)
+}
+
+func TestGetUserCoalescing(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	f := &fakeRepository{email: "user@email.com", release: make(chan struct{})}
+	c, m := newCache(f, 10)
+	ctx := tenant.NewContext(context.Background(), "acme")
+
+	// act
+	var wg sync.WaitGroup
+	results := make([]entities.User, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.GetUser(ctx, 1)
+		}(i)
+	}
+
+	for m.Misses.(*generic.Counter).Value() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(f.release)
+	wg.Wait()
+
+	// assert
+	assert.Equal(int32(1), f.loads)
+	for _, u := range results {
+		assert.Equal("user@email.com", u.Email)
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	f := &fakeRepository{email: "user@email.com"}
+	c, _ := newCache(f, 10)
+	ctx := tenant.NewContext(context.Background(), "acme")
+	_, _ = c.GetUser(ctx, 1)
+
+	// act
+	_, _ = c.UpdateUser(ctx, entities.UserUpdate{UserID: 1, User: entities.User{Email: "new@email.com"}})
+	updated, _ := c.GetUser(ctx, 1)
+	_, _ = c.DeleteUser(ctx, 1)
+	_, _ = c.GetUser(ctx, 1)
+
+	// assert
+	assert.Equal("new@email.com", updated.Email)
+	assert.Equal(int32(3), f.loads)
+}
+
+func TestMemoryBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	evictions := generic.NewCounter("evictions")
+	backend := cache.NewMemoryBackend(2, evictions)
+	ctx := context.Background()
+
+	// act
+	_ = backend.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = backend.Set(ctx, "b", []byte("2"), time.Minute)
+	_, _, _ = backend.Get(ctx, "a")
+	_ = backend.Set(ctx, "c", []byte("3"), time.Minute)
+	_ = backend.Set(ctx, "short", []byte("4"), 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	// assert
+	_, foundB, _ := backend.Get(ctx, "b")
+	assert.False(foundB)
+	_, foundA, _ := backend.Get(ctx, "a")
+	assert.False(foundA)
+	c, foundC, _ := backend.Get(ctx, "c")
+	assert.True(foundC)
+	assert.Equal([]byte("3"), c)
+	_, foundShort, _ := backend.Get(ctx, "short")
+	assert.False(foundShort)
+	assert.Equal(1, backend.Len())
+	assert.Equal(3.0, evictions.Value())
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// DefaultCapacity is the number of entries kept by a MemoryBackend created with a non positive capacity
+const DefaultCapacity = 10000
+
+// MemoryBackend implements the Backend interface with a least recently used list, the entries are
+// removed when they expire or when the capacity is reached
+type MemoryBackend struct {
+	mu        sync.Mutex
+	capacity  int
+	entries   map[string]*list.Element
+	order     *list.List
+	evictions metrics.Counter
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryBackend returns a MemoryBackend pointer type, evictions counts the entries removed to make room
+// and the expired ones, it may be nil
+func NewMemoryBackend(capacity int, evictions metrics.Counter) *MemoryBackend {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	if evictions == nil {
+		evictions = discard.NewCounter()
+	}
+
+	return &MemoryBackend{
+		capacity:  capacity,
+		entries:   make(map[string]*list.Element),
+		order:     list.New(),
+		evictions: evictions,
+		now:       time.Now,
+	}
+}
+
+// Get returns the value of the key and marks it as recently used
+func (m *MemoryBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := e.Value.(*memoryEntry)
+	if !m.now().Before(entry.expires) {
+		m.remove(e)
+		m.evictions.Add(1)
+		return nil, false, nil
+	}
+
+	m.order.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+// Set stores the value of the key for ttl, the least recently used entry is evicted when the backend is full
+func (m *MemoryBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value, expires: m.now().Add(ttl)}
+
+	if e, ok := m.entries[key]; ok {
+		e.Value = entry
+		m.order.MoveToFront(e)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+
+	for m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+		m.evictions.Add(1)
+	}
+
+	return nil
+}
+
+// Delete removes the key
+func (m *MemoryBackend) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		m.remove(e)
+	}
+
+	return nil
+}
+
+// Len returns the number of entries, expired or not
+func (m *MemoryBackend) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+func (m *MemoryBackend) remove(e *list.Element) {
+	m.order.Remove(e)
+	delete(m.entries, e.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,192 @@
+// Command bulk imports and exports the users of a tenant talking directly to both gRPC servers.
+//
+//	bulk import [-format csv|jsonl] [-dry-run] [-tenant id] [file]
+//	bulk export [-format csv|jsonl] [-tenant id] [file]
+//
+// The file defaults to the standard input or output, the format defaults to the extension of the file.
+// Imports print the per-row report as JSON and exit with status 1 when a row failed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/guard"
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
+	"github.com/mauricioww/user_microsrv/http_srv/repository"
+	"github.com/mauricioww/user_microsrv/http_srv/saga"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "import" && os.Args[1] != "export") {
+		fmt.Fprintln(os.Stderr, "usage: bulk import|export [flags] [file]")
+		os.Exit(2)
+	}
+
+	cmd := os.Args[1]
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
+	format := flags.String("format", "", "csv or jsonl, defaults to the extension of the file")
+	tenantID := flags.String("tenant", tenant.Default, "tenant of the users")
+	dryRun := flags.Bool("dry-run", false, "only validate the rows of the import")
+	batchSize := flags.Int("batch", bulk.DefaultBatchSize, "rows created per batch")
+	workers := flags.Int("workers", bulk.DefaultWorkers, "concurrent creations within a batch")
+	flags.Parse(os.Args[2:])
+
+	cts := constants{}
+	if err := env.Parse(&cts); err != nil {
+		fmt.Fprintf(os.Stderr, "%+v\n", err)
+		os.Exit(2)
+	}
+
+	logger := log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "service", "BULK", "time", log.DefaultTimestampUTC)
+
+	if !tenant.Valid(*tenantID) {
+		level.Error(logger).Log("tenant", "invalid tenant", "value", *tenantID)
+		os.Exit(2)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = formatFromName(path)
+	}
+
+	if !bulk.ValidFormat(*format) {
+		level.Error(logger).Log("format", "expected csv or jsonl", "value", *format)
+		os.Exit(2)
+	}
+
+	userGRPC, err := dial(cts.UserHost, cts.UserPort, cts.InternalToken)
+	if err != nil {
+		level.Error(logger).Log("gRPC", err)
+		os.Exit(1)
+	}
+	defer userGRPC.Close()
+
+	detailsGRPC, err := dial(cts.DetailsHost, cts.DetailsPort, cts.InternalToken)
+	if err != nil {
+		level.Error(logger).Log("gRPC", err)
+		os.Exit(1)
+	}
+	defer detailsGRPC.Close()
+
+	// the command keeps its own saga log so the HTTP server never resumes a saga which is still running here
+	sagaLog, err := saga.NewFileLog(filepath.Join(cts.SagaDir, "bulk"))
+	if err != nil {
+		level.Error(logger).Log("saga", err)
+		os.Exit(1)
+	}
+
+	ctx := tenant.NewContext(context.Background(), *tenantID)
+	repo := repository.NewHTTPRepository(userGRPC, detailsGRPC, sagaLog, logger)
+
+	if err := repo.ResumeSagas(ctx); err != nil {
+		level.Error(logger).Log("saga", err)
+	}
+
+	if cmd == "import" {
+		os.Exit(runImport(ctx, repo, logger, path, *format, *dryRun, *batchSize, *workers))
+	}
+	os.Exit(runExport(ctx, repo, logger, path, *format))
+}
+
+func runImport(ctx context.Context, repo *repository.HTTPRepository, logger log.Logger, path string, format string, dryRun bool, batchSize int, workers int) int {
+	var in io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			level.Error(logger).Log("file", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	rows, err := bulk.NewReader(format, in)
+	if err != nil {
+		level.Error(logger).Log("import", err)
+		return 1
+	}
+
+	report, err := bulk.NewImporter(repo, batchSize, workers).Import(ctx, rows, dryRun)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+
+	if err != nil {
+		level.Error(logger).Log("import", err)
+		return 1
+	}
+
+	level.Info(logger).Log("total", report.Total, "succeeded", report.Succeeded, "failed", report.Failed, "dry_run", dryRun)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runExport(ctx context.Context, repo *repository.HTTPRepository, logger log.Logger, path string, format string) int {
+	var out io.Writer = os.Stdout
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			level.Error(logger).Log("file", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	rows, err := bulk.NewWriter(format, out)
+	if err != nil {
+		level.Error(logger).Log("export", err)
+		return 1
+	}
+
+	total, err := bulk.Export(ctx, repo, rows, bulk.DefaultPageSize)
+	if err != nil {
+		level.Error(logger).Log("export", err, "exported", total)
+		return 1
+	}
+
+	level.Info(logger).Log("exported", total)
+	return 0
+}
+
+func dial(host string, port int, token string) (*grpc.ClientConn, error) {
+	addr := fmt.Sprintf("%v:%v", host, port)
+	return grpc.Dial(addr, grpc.WithInsecure(), grpc.WithChainUnaryInterceptor(tenant.UnaryClientInterceptor(), guard.UnaryClientInterceptor(token)))
+}
+
+func formatFromName(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return bulk.CSV
+	case ".jsonl", ".ndjson":
+		return bulk.JSONL
+	default:
+		return ""
+	}
+}
+
+type constants struct {
+	UserHost    string `env:"USER_SERVER,required"`
+	UserPort    int    `env:"USER_PORT" envDefault:"50051"`
+	DetailsHost string `env:"DETAILS_SERVER,required"`
+	DetailsPort int    `env:"DETAILS_PORT" envDefault:"50051"`
+	SagaDir     string `env:"SAGA_DIR" envDefault:"/var/lib/http_srv/sagas"`
+
+	InternalToken string `env:"INTERNAL_TOKEN,required"`
+}
@@ -1,21 +1,115 @@
 package entities
 
+import "time"
+
 // Details struct stores the user's extra information
 type Details struct {
-	Country      string  `json:"country"`
-	City         string  `json:"city"`
-	MobileNumber string  `json:"mobile_number"`
-	Married      bool    `json:"married"`
-	Height       float32 `json:"height_m"`
-	Weight       float32 `json:"weight_kg"`
+	Country        string  `json:"country" validate:"max=56"`
+	City           string  `json:"city" validate:"max=85"`
+	MobileNumber   string  `json:"mobile_number" validate:"max=32"`
+	MobileVerified bool    `json:"mobile_verified"`
+	Married        bool    `json:"married"`
+	Height         float32 `json:"height_m" validate:"omitempty,min=0.5,max=2.5"`
+	Weight         float32 `json:"weight_kg" validate:"omitempty,min=20,max=350"`
+
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	Avatar    string `json:"-"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+
+	// Revision counts the writes of the details, it is 0 while they are missing
+	Revision int64 `json:"-"`
+}
+
+// AnyRevision replaces the details whatever their revision is
+const AnyRevision int64 = -1
+
+// DetailsPatch struct stores a JSON merge patch of the user's extra information, the absent and null fields keep
+// their value except the null attributes which are removed
+type DetailsPatch struct {
+	Country      *string  `json:"country" validate:"max=56"`
+	City         *string  `json:"city" validate:"max=85"`
+	MobileNumber *string  `json:"mobile_number" validate:"max=32"`
+	Married      *bool    `json:"married"`
+	Height       *float32 `json:"height_m" validate:"omitempty,min=0.5,max=2.5"`
+	Weight       *float32 `json:"weight_kg" validate:"omitempty,min=20,max=350"`
+
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// Apply returns the details with the fields of the patch
+func (p DetailsPatch) Apply(d Details) Details {
+	if p.Country != nil {
+		d.Country = *p.Country
+	}
+	if p.City != nil {
+		d.City = *p.City
+	}
+	if p.MobileNumber != nil {
+		d.MobileNumber = *p.MobileNumber
+	}
+	if p.Married != nil {
+		d.Married = *p.Married
+	}
+	if p.Height != nil {
+		d.Height = *p.Height
+	}
+	if p.Weight != nil {
+		d.Weight = *p.Weight
+	}
+
+	if len(p.Attributes) > 0 {
+		attributes := make(map[string]interface{}, len(d.Attributes)+len(p.Attributes))
+		for k, v := range d.Attributes {
+			attributes[k] = v
+		}
+		for k, v := range p.Attributes {
+			if v == nil {
+				delete(attributes, k)
+				continue
+			}
+			attributes[k] = v
+		}
+		d.Attributes = attributes
+	}
+
+	return d
 }
 
 // User struct stores the user's basic information
 type User struct {
-	Email    string
-	Password string
-	Age      int
+	ID          int
+	Email       string
+	Password    string
+	DateOfBirth string
+	Age         int
 	Details
+
+	// DetailsStatus is empty when the details were fetched, otherwise the user is returned without them
+	DetailsStatus string
+}
+
+// UserInput struct stores the information of a user written by the clients, the REST requests and the GraphQL
+// mutations validate the same rules
+type UserInput struct {
+	Email       string `json:"email" validate:"required,email,max=254"`
+	Password    string `json:"password" validate:"required,max=72"`
+	DateOfBirth string `json:"date_of_birth" validate:"required,date"`
+	Details     `json:"information"`
+}
+
+// Reasons why a user is returned without its details, they are withheld from everyone but their owner
+const (
+	DetailsMissing     = "missing"
+	DetailsUnavailable = "unavailable"
+	DetailsWithheld    = "withheld"
+)
+
+// UserResult struct stores the user of one of the ids of a batch, Found is false when the user does not exist
+type UserResult struct {
+	ID    int
+	Found bool
+	User  User
 }
 
 // Session struct stores the credentials of the user which attempt to login
@@ -29,3 +123,32 @@ type UserUpdate struct {
 	UserID int
 	User
 }
+
+// Address struct stores one of the postal addresses of the user
+type Address struct {
+	ID          string   `json:"id"`
+	Lines       []string `json:"lines"`
+	Locality    string   `json:"locality"`
+	Region      string   `json:"region,omitempty"`
+	PostalCode  string   `json:"postal_code,omitempty"`
+	CountryCode string   `json:"country_code"`
+	Label       string   `json:"label,omitempty"`
+	Primary     bool     `json:"primary"`
+}
+
+// Sources of the changes within the change feed
+const (
+	UserSource    = "user"
+	DetailsSource = "details"
+)
+
+// Change struct stores one mutation of a user or its details, Cursor resumes the feed after the change
+type Change struct {
+	ID         string                 `json:"id"`
+	Source     string                 `json:"source"`
+	Type       string                 `json:"type"`
+	UserID     int                    `json:"user_id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Cursor     string                 `json:"cursor"`
+}
@@ -0,0 +1,6 @@
+// Package graphql serves the users over GraphQL next to the REST API.
+//
+// The schema of schema.graphql is executed by github.com/graph-gophers/graphql-go, this package adds the HTTP
+// handler, the resolvers over the repository and the Loader which batches the lookups of sibling fields. The
+// operations are bounded by the length of the query and the depth of the fields.
+package graphql
@@ -0,0 +1,434 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	gql "github.com/graph-gophers/graphql-go"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/graphql"
+	"github.com/mauricioww/user_microsrv/http_srv/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testResolver is the root resolver of testSchema
+type testResolver struct{}
+
+func (testResolver) Hello(args struct{ Name string }) string {
+	return fmt.Sprintf("hello %v", args.Name)
+}
+func (testResolver) Touch() bool { return true }
+
+func (testResolver) Broken(ctx context.Context) (*string, error) {
+	panic("broken resolver")
+}
+
+func testSchema() *gql.Schema {
+	return gql.MustParseSchema(`
+		schema { query: Query mutation: Mutation }
+		type Query { hello(name: String = "world"): String! broken: String }
+		type Mutation { touch: Boolean! }
+	`, &testResolver{}, gql.PanicHandler(graphql.PanicHandler{}), gql.Logger(graphql.PanicLogger{Logger: log.NewNopLogger()}))
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(graphql.Handler(testSchema(), nil))
+	defer server.Close()
+
+	test_cases := []struct {
+		testName   string
+		method     string
+		query      string
+		httpStatus int
+		res        string
+	}{
+		{
+			testName:   "query success",
+			method:     "POST",
+			query:      `{ hello }`,
+			httpStatus: 200,
+			res:        `{"data":{"hello":"hello world"}}`,
+		},
+		{
+			testName:   "mutation success",
+			method:     "POST",
+			query:      `mutation { touch }`,
+			httpStatus: 200,
+			res:        `{"data":{"touch":true}}`,
+		},
+		{
+			testName:   "panic is an internal error",
+			method:     "POST",
+			query:      `{ broken }`,
+			httpStatus: 200,
+			res:        `{"data":{"broken":null},"errors":[{"message":"Internal server error","path":["broken"],"extensions":{"code":"INTERNAL"}}]}`,
+		},
+		{
+			testName:   "query by GET error",
+			method:     "GET",
+			query:      `{ hello }`,
+			httpStatus: 405,
+		},
+		{
+			testName:   "missing query error",
+			method:     "POST",
+			query:      "",
+			httpStatus: 400,
+		},
+		{
+			testName:   "query too long error",
+			method:     "POST",
+			query:      "{ " + strings.Repeat("hello ", 2000) + "}",
+			httpStatus: 400,
+		},
+		{
+			testName:   "unknown field error",
+			method:     "POST",
+			query:      `{ goodbye }`,
+			httpStatus: 400,
+			res:        `{"errors":[{"message":"Cannot query field \"goodbye\" on type \"Query\".","locations":[{"line":1,"column":3}],"extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}]}`,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			var res *http.Response
+			if tc.method == "GET" {
+				res, _ = http.Get(server.URL + "?query=" + url.QueryEscape(tc.query))
+			} else {
+				body, _ := json.Marshal(graphql.Request{Query: tc.query})
+				res, _ = http.Post(server.URL, "application/json", strings.NewReader(string(body)))
+			}
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Equal("application/json", res.Header.Get("Content-Type"))
+			if tc.res != "" {
+				assert.JSONEq(tc.res, string(body))
+			}
+		})
+	}
+}
+
+func TestLoader(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	ctx := context.Background()
+	var calls int32
+	var batches [][]int
+	loader := graphql.NewLoader(func(ctx context.Context, keys []int) ([]interface{}, []error) {
+		atomic.AddInt32(&calls, 1)
+		batches = append(batches, append([]int{}, keys...))
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = k * 10
+		}
+		return values, make([]error, len(keys))
+	}, 50*time.Millisecond, 3)
+	loader.Prime(9, 99)
+
+	// act
+	values, errs := loader.LoadMany(ctx, []int{1, 2, 3, 4, 2})
+	cached, _ := loader.Load(ctx, 1)
+	primed, _ := loader.Load(ctx, 9)
+
+	// assert
+	assert.Equal([]interface{}{10, 20, 30, 40, 20}, values)
+	assert.Equal(make([]error, 5), errs)
+	assert.Equal(10, cached)
+	assert.Equal(99, primed)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	total := 0
+	for _, b := range batches {
+		assert.LessOrEqual(len(b), 3)
+		total += len(b)
+	}
+	assert.Equal(4, total)
+}
+
+func idsOf(want ...int) interface{} {
+	return mock.MatchedBy(func(ids []int) bool {
+		got := append([]int{}, ids...)
+		sort.Ints(got)
+		return fmt.Sprint(got) == fmt.Sprint(want)
+	})
+}
+
+func found(id int, email string) entities.UserResult {
+	return entities.UserResult{ID: id, Found: true, User: entities.User{
+		ID:          id,
+		Email:       email,
+		DateOfBirth: "1996-02-29",
+		Age:         26,
+		Details:     entities.Details{Country: "Mexico", City: "CDMX", Height: 1.8, Attributes: map[string]interface{}{"team": "red"}},
+	}}
+}
+
+// authorizeUser accepts the password qwerty of any email
+func authorizeUser(ctx context.Context, userID int, email string, pwd string) error {
+	if email == "" {
+		return errors.NewUnauthenticatedError()
+	}
+	if pwd != "qwerty" {
+		return errors.NewForbiddenError()
+	}
+	return nil
+}
+
+func postQuery(url string, email string, query string, variables map[string]interface{}) (int, string) {
+	body, _ := json.Marshal(graphql.Request{Query: query, Variables: variables})
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if email != "" {
+		req.SetBasicAuth(email, "qwerty")
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer res.Body.Close()
+
+	read, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(read)
+}
+
+func TestUsersQueries(t *testing.T) {
+	test_cases := []struct {
+		testName string
+		email    string
+		query    string
+		prepare  func(r *service.RepoMock)
+		code     int
+		res      string
+		batches  int
+	}{
+		{
+			testName: "user with details success",
+			email:    "User@email.com",
+			query:    `{ user(id: 1) { id email dateOfBirth age details { country height attributes avatarUrl } detailsStatus } }`,
+			prepare: func(r *service.RepoMock) {
+				r.On("BatchGetUsers", mock.Anything, idsOf(1)).Return([]entities.UserResult{found(1, "user@email.com")}, nil)
+			},
+			code:    200,
+			res:     `{"data":{"user":{"id":1,"email":"user@email.com","dateOfBirth":"1996-02-29","age":26,"details":{"country":"Mexico","height":1.8,"attributes":{"team":"red"},"avatarUrl":null},"detailsStatus":""}}}`,
+			batches: 1,
+		},
+		{
+			testName: "details withheld from anonymous success",
+			query:    `{ user(id: 1) { email details { country } detailsStatus } }`,
+			prepare: func(r *service.RepoMock) {
+				r.On("BatchGetUsers", mock.Anything, idsOf(1)).Return([]entities.UserResult{found(1, "user@email.com")}, nil)
+			},
+			code:    200,
+			res:     `{"data":{"user":{"email":"user@email.com","details":null,"detailsStatus":"withheld"}}}`,
+			batches: 1,
+		},
+		{
+			testName: "details withheld from other users success",
+			email:    "one@email.com",
+			query:    `{ a: user(id: 1) { details { city } detailsStatus } b: user(id: 2) { details { city } detailsStatus } }`,
+			prepare: func(r *service.RepoMock) {
+				r.On("BatchGetUsers", mock.Anything, idsOf(1, 2)).Return([]entities.UserResult{found(1, "one@email.com"), found(2, "two@email.com")}, nil)
+			},
+			code:    200,
+			res:     `{"data":{"a":{"details":{"city":"CDMX"},"detailsStatus":""},"b":{"details":null,"detailsStatus":"withheld"}}}`,
+			batches: 1,
+		},
+		{
+			testName: "sibling users batched success",
+			query:    `{ a: user(id: 1) { email } b: user(id: 2) { email } c: user(id: 3) { email } again: user(id: 1) { id } }`,
+			prepare: func(r *service.RepoMock) {
+				r.On("BatchGetUsers", mock.Anything, idsOf(1, 2, 3)).Return([]entities.UserResult{
+					found(1, "one@email.com"), found(2, "two@email.com"), {ID: 3, Found: false},
+				}, nil)
+			},
+			code:    200,
+			res:     `{"data":{"a":{"email":"one@email.com"},"b":{"email":"two@email.com"},"c":null,"again":{"id":1}}}`,
+			batches: 1,
+		},
+		{
+			testName: "users page success",
+			email:    "one@email.com",
+			query:    `{ users(page: {size: 2}) { users { id details { city } } nextAfter } }`,
+			prepare: func(r *service.RepoMock) {
+				r.On("ListUsers", mock.Anything, 0, 2).Return([]entities.User{
+					found(1, "one@email.com").User,
+					{ID: 2, Email: "two@email.com", Details: entities.Details{Country: "Peru", City: "Lima"}},
+				}, 2, nil)
+			},
+			code: 200,
+			res:  `{"data":{"users":{"users":[{"id":1,"details":{"city":"CDMX"}},{"id":2,"details":null}],"nextAfter":2}}}`,
+		},
+		{
+			testName: "users by ids batched success",
+			query:    `{ users(filter: {ids: [2, 1]}) { users { email } nextAfter } }`,
+			prepare: func(r *service.RepoMock) {
+				r.On("BatchGetUsers", mock.Anything, idsOf(1, 2)).Return([]entities.UserResult{found(1, "one@email.com"), found(2, "two@email.com")}, nil)
+			},
+			code:    200,
+			res:     `{"data":{"users":{"users":[{"email":"two@email.com"},{"email":"one@email.com"}],"nextAfter":null}}}`,
+			batches: 1,
+		},
+		{
+			testName: "too many ids error",
+			query:    fmt.Sprintf(`{ users(filter: {ids: [%v]}) { nextAfter } }`, strings.Repeat("1, ", 100)+"2"),
+			prepare:  func(r *service.RepoMock) {},
+			code:     200,
+			res:      `{"data":null,"errors":[{"message":"Invalid batch, expected between 1 and 100 ids","path":["users"],"extensions":{"code":"INVALID_BATCH"}}]}`,
+		},
+		{
+			testName: "query too long error",
+			query:    "{ " + strings.Repeat("user(id: 1) { id email age } ", 300) + "}",
+			prepare:  func(r *service.RepoMock) {},
+			code:     400,
+			res:      `{"errors":[{"message":"The query is longer than 8 KiB.","extensions":{"code":"BAD_USER_INPUT"}}]}`,
+		},
+		{
+			testName: "page too large error",
+			query:    `{ users(page: {size: 500}) { nextAfter } }`,
+			prepare:  func(r *service.RepoMock) {},
+			code:     200,
+			res:      `{"data":null,"errors":[{"message":"Invalid request 'page.size': must be between 1 and 100","path":["users"],"extensions":{"code":"INVALID_REQUEST","violations":[{"field":"page.size","description":"must be between 1 and 100"}]}}]}`,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			repository_mock := new(service.RepoMock)
+			tc.prepare(repository_mock)
+			server := httptest.NewServer(graphql.NewUsersHandler(repository_mock, authorizeUser, log.NewLogfmtLogger(os.Stderr)))
+			defer server.Close()
+
+			// act
+			code, res := postQuery(server.URL, tc.email, tc.query, nil)
+
+			// assert
+			assert.Equal(tc.code, code)
+			assert.JSONEq(tc.res, res)
+			repository_mock.AssertNumberOfCalls(t, "BatchGetUsers", tc.batches)
+		})
+	}
+}
+
+func TestUsersMutations(t *testing.T) {
+	input := map[string]interface{}{
+		"email":       "user@email.com",
+		"password":    "qwerty",
+		"dateOfBirth": "1996-02-29",
+		"details":     map[string]interface{}{"country": "Mexico", "height": 1.8},
+	}
+	user := entities.User{Email: "user@email.com", Password: "qwerty", DateOfBirth: "1996-02-29", Details: entities.Details{Country: "Mexico", Height: 1.8}}
+
+	test_cases := []struct {
+		testName  string
+		email     string
+		query     string
+		variables map[string]interface{}
+		prepare   func(r *service.RepoMock)
+		res       string
+	}{
+		{
+			testName:  "user created success",
+			email:     "user@email.com",
+			query:     `mutation ($input: UserInput!) { createUser(input: $input) { id email details { country height } } }`,
+			variables: map[string]interface{}{"input": input},
+			prepare: func(r *service.RepoMock) {
+				r.On("CreateUser", mock.Anything, user).Return(7, nil)
+				r.On("BatchGetUsers", mock.Anything, idsOf(7)).Return([]entities.UserResult{{ID: 7, Found: true, User: user}}, nil)
+			},
+			res: `{"data":{"createUser":{"id":7,"email":"user@email.com","details":{"country":"Mexico","height":1.8}}}}`,
+		},
+		{
+			testName: "invalid input error",
+			query:    `mutation { createUser(input: {email: "user", password: "", dateOfBirth: "1996-02-29", details: {height: 3}}) { id } }`,
+			prepare:  func(r *service.RepoMock) {},
+			res:      `{"data":null,"errors":[{"message":"Invalid request 'email': must be a valid email address, 'password': is required, 'details.height': must be at most 2.5","path":["createUser"],"extensions":{"code":"INVALID_REQUEST","violations":[{"field":"email","description":"must be a valid email address"},{"field":"password","description":"is required"},{"field":"details.height","description":"must be at most 2.5"}]}}]}`,
+		},
+		{
+			testName:  "user updated success",
+			email:     "user@email.com",
+			query:     `mutation ($input: UserInput!) { updateUser(id: 7, input: $input) { email } }`,
+			variables: map[string]interface{}{"input": input},
+			prepare: func(r *service.RepoMock) {
+				r.On("UpdateUser", mock.Anything, entities.UserUpdate{UserID: 7, User: user}).Return(true, nil)
+				r.On("BatchGetUsers", mock.Anything, idsOf(7)).Return([]entities.UserResult{{ID: 7, Found: true, User: user}}, nil)
+			},
+			res: `{"data":{"updateUser":{"email":"user@email.com"}}}`,
+		},
+		{
+			testName:  "update without credentials error",
+			query:     `mutation ($input: UserInput!) { updateUser(id: 7, input: $input) { email } }`,
+			variables: map[string]interface{}{"input": input},
+			prepare:   func(r *service.RepoMock) {},
+			res:       `{"data":null,"errors":[{"message":"Password or email error","path":["updateUser"],"extensions":{"code":"INVALID_CREDENTIALS"}}]}`,
+		},
+		{
+			testName: "user deleted success",
+			email:    "user@email.com",
+			query:    `mutation { deleteUser(id: 7) }`,
+			prepare: func(r *service.RepoMock) {
+				r.On("DeleteUser", mock.Anything, 7).Return(true, nil)
+			},
+			res: `{"data":{"deleteUser":true}}`,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			repository_mock := new(service.RepoMock)
+			tc.prepare(repository_mock)
+			server := httptest.NewServer(graphql.NewUsersHandler(repository_mock, authorizeUser, log.NewLogfmtLogger(os.Stderr)))
+			defer server.Close()
+
+			// act
+			code, res := postQuery(server.URL, tc.email, tc.query, tc.variables)
+
+			// assert
+			assert.Equal(200, code)
+			assert.JSONEq(tc.res, res)
+			repository_mock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersSchema(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	server := httptest.NewServer(graphql.NewUsersSchemaHandler())
+	defer server.Close()
+
+	// act
+	res, _ := http.Get(server.URL)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	// assert
+	assert.Equal("text/plain; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Contains(string(body), "type Query {\n  \"Null when the user does not exist\"\n  user(id: Int!): User\n")
+	assert.Contains(string(body), "input PageInput {\n  \"The users with a greater ID are listed\"\n  after: Int = 0\n")
+	assert.Contains(string(body), "scalar JSON\n")
+}
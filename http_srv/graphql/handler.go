@@ -0,0 +1,151 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	gql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/mauricioww/user_microsrv/errors"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxBodySize bounds the JSON bodies of the requests
+	maxBodySize = 1 << 20
+	// maxQueryLength bounds the query of one request, the aliases of user would read one user each otherwise
+	maxQueryLength = 8 << 10
+)
+
+// The codes of the errors, they follow the ones of the common GraphQL servers
+const (
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	CodeBadUserInput     = "BAD_USER_INPUT"
+)
+
+// Request stores one GraphQL request, the variables are JSON values
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Handler serves the schema over HTTP, the requests are JSON bodies sent by POST and prepare returns the context
+// of the execution so the loaders and the credentials of one request are stored there. The requests which fail
+// before their execution are bad requests
+func Handler(s *gql.Schema, prepare func(r *http.Request) context.Context) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			writeResponse(rw, http.StatusMethodNotAllowed, badRequest("GraphQL requests are sent by POST."))
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&req); err != nil {
+			writeResponse(rw, http.StatusBadRequest, badRequest("The body must be a JSON object with the query."))
+			return
+		}
+		if req.Query == "" {
+			writeResponse(rw, http.StatusBadRequest, badRequest("The query is required."))
+			return
+		}
+		if len(req.Query) > maxQueryLength {
+			writeResponse(rw, http.StatusBadRequest, badRequest("The query is longer than 8 KiB."))
+			return
+		}
+
+		ctx := r.Context()
+		if prepare != nil {
+			ctx = prepare(r)
+		}
+
+		res := s.Exec(ctx, req.Query, req.OperationName, req.Variables)
+		if res.Data != nil {
+			writeResponse(rw, http.StatusOK, res)
+			return
+		}
+
+		for _, e := range res.Errors {
+			if e.Extensions == nil {
+				e.Extensions = map[string]interface{}{"code": CodeValidationFailed}
+			}
+		}
+		writeResponse(rw, http.StatusBadRequest, res)
+	})
+}
+
+// SchemaHandler serves the schema in the schema definition language, the clients generate their types from it
+func SchemaHandler(sdl string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(rw, sdl)
+	})
+}
+
+func badRequest(message string) *gql.Response {
+	e := &gqlerrors.QueryError{Message: message, Extensions: map[string]interface{}{"code": CodeBadUserInput}}
+	return &gql.Response{Errors: []*gqlerrors.QueryError{e}}
+}
+
+func writeResponse(rw http.ResponseWriter, code int, res *gql.Response) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(res)
+}
+
+// fieldError is the error returned by a resolver, its message is localized and its extensions carry the reason and
+// the field violations of the status errors
+type fieldError struct {
+	message    string
+	extensions map[string]interface{}
+}
+
+func (e *fieldError) Error() string {
+	return e.message
+}
+
+// Extensions are added to the error of the response by the executor
+func (e *fieldError) Extensions() map[string]interface{} {
+	return e.extensions
+}
+
+// newFieldError describes the status errors and the errors of the errors package by their reason and their field
+// violations, the other errors are reported as internal errors
+func newFieldError(ctx context.Context, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		if _, resolver := err.(errors.ErrorResolver); !resolver {
+			err = errors.NewInternalError()
+		}
+		st = errors.LocalizedStatus(ctx, err)
+	}
+
+	message := st.Message()
+	if _, localized := errors.Localized(st); localized != "" {
+		message = localized
+	}
+
+	reason, violations := errors.Describe(st)
+	if reason == "" {
+		reason = st.Code().String()
+	}
+
+	extensions := map[string]interface{}{"code": reason}
+	if len(violations) > 0 {
+		extensions["violations"] = violations
+	}
+	return &fieldError{message: message, extensions: extensions}
+}
+
+// PanicHandler reports the panics of the resolvers as internal errors, the panic itself is only logged by the
+// PanicLogger
+type PanicHandler struct{}
+
+// MakePanicError returns the localized internal error
+func (PanicHandler) MakePanicError(ctx context.Context, value interface{}) *gqlerrors.QueryError {
+	e := newFieldError(ctx, errors.NewInternalError()).(*fieldError)
+	return &gqlerrors.QueryError{Message: e.message, Extensions: e.extensions}
+}
@@ -0,0 +1,141 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchFunc loads the values of the keys at once, it returns one value and one error for each key in the same order
+type BatchFunc func(ctx context.Context, keys []int) ([]interface{}, []error)
+
+// Loader gathers the keys loaded within the same wait window into one call of its batch function, the values are
+// cached for the life of the loader so one loader serves one request, the siblings of a query resolved concurrently
+// fetch their values with one call instead of one call each
+type Loader struct {
+	batch    BatchFunc
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	cache   map[int]*thunk
+	pending *pendingBatch
+}
+
+type thunk struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type pendingBatch struct {
+	keys   []int
+	thunks []*thunk
+	full   chan struct{}
+}
+
+// NewLoader returns a Loader which calls batch once wait elapsed since the first key of the batch or once maxBatch
+// keys are waiting
+func NewLoader(batch BatchFunc, wait time.Duration, maxBatch int) *Loader {
+	return &Loader{
+		batch:    batch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    map[int]*thunk{},
+	}
+}
+
+// Load returns the value of the key, the batch is called with the context of the first load of the batch
+func (l *Loader) Load(ctx context.Context, key int) (interface{}, error) {
+	l.mu.Lock()
+	t, ok := l.cache[key]
+	if !ok {
+		t = &thunk{done: make(chan struct{})}
+		l.cache[key] = t
+
+		if l.pending == nil {
+			l.pending = &pendingBatch{full: make(chan struct{})}
+			go l.dispatch(ctx, l.pending)
+		}
+		b := l.pending
+		b.keys = append(b.keys, key)
+		b.thunks = append(b.thunks, t)
+		if len(b.keys) >= l.maxBatch {
+			l.pending = nil
+			close(b.full)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return t.value, t.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadMany returns the values of the keys in the same order, the keys are loaded within the same batches
+func (l *Loader) LoadMany(ctx context.Context, keys []int) ([]interface{}, []error) {
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key int) {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(ctx, key)
+		}(i, key)
+	}
+	wg.Wait()
+
+	return values, errs
+}
+
+// Prime caches the value of the key unless it is already cached, the lists prime the values they fetched
+func (l *Loader) Prime(key int, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+	t := &thunk{done: make(chan struct{}), value: value}
+	close(t.done)
+	l.cache[key] = t
+}
+
+// Clear removes the key from the cache, the mutations clear the keys they change
+func (l *Loader) Clear(key int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.cache, key)
+}
+
+func (l *Loader) dispatch(ctx context.Context, b *pendingBatch) {
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		l.mu.Lock()
+		if l.pending == b {
+			l.pending = nil
+		}
+		l.mu.Unlock()
+	case <-b.full:
+	}
+
+	values, errs := l.batch(ctx, b.keys)
+	for i, t := range b.thunks {
+		if i < len(values) {
+			t.value = values[i]
+		}
+		if i < len(errs) {
+			t.err = errs[i]
+		}
+		close(t.done)
+	}
+}
@@ -0,0 +1,524 @@
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	gql "github.com/graph-gophers/graphql-go"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/repository"
+	"github.com/mauricioww/user_microsrv/http_srv/service"
+	"github.com/mauricioww/user_microsrv/validation"
+)
+
+const (
+	// loaderWait is how long the user loader gathers the ids of one batch, the siblings of a query are resolved
+	// concurrently so they all load within it
+	loaderWait = 2 * time.Millisecond
+	// maxLoaderBatch is the most ids sent within one batch, the limit of the batch reads of the user gRPC server
+	maxLoaderBatch = 100
+
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// maxQueryDepth bounds the nesting of the fields, the deepest field of the schema is users.users.details.city
+	maxQueryDepth = 5
+)
+
+// usersSchema is the schema of the users in the schema definition language
+//
+//go:embed schema.graphql
+var usersSchema string
+
+type (
+	loaderKey      struct{}
+	credentialsKey struct{}
+)
+
+// Authorizer checks the credentials belong to the user like service.HTTPServicer.AuthorizeUser, the missing or
+// wrong credentials are unauthenticated and the credentials of another user are forbidden
+type Authorizer func(ctx context.Context, userID int, email string, pwd string) error
+
+// users is the root resolver of the schema, the users are read through the repository by the loader stored within
+// the context of the request
+type users struct {
+	repository repository.HTTPRepositorier
+	authorizer Authorizer
+	logger     log.Logger
+}
+
+// credentials are the basic credentials of one request, the outcome of their authorization is kept by user
+type credentials struct {
+	email    string
+	password string
+
+	mu         sync.Mutex
+	authorized map[int]error
+}
+
+// NewUsersHandler serves the GraphQL schema of the users and their details, every request gets its own loader so
+// the users are cached only while the request is executed. The details are only resolved for the user of the basic
+// credentials of the request and the mutations of a user require them
+func NewUsersHandler(r repository.HTTPRepositorier, authorizer Authorizer, logger log.Logger) http.Handler {
+	u := &users{repository: r, authorizer: authorizer, logger: log.With(logger, "http_service", "graphql")}
+	s := gql.MustParseSchema(usersSchema, u,
+		gql.UseStringDescriptions(),
+		gql.MaxDepth(maxQueryDepth),
+		// the siblings of one batch must run at once for the loader to gather them
+		gql.MaxParallelism(maxLoaderBatch),
+		gql.Logger(PanicLogger{Logger: u.logger}),
+		gql.PanicHandler(PanicHandler{}),
+	)
+	return Handler(s, u.context)
+}
+
+// NewUsersSchemaHandler serves the schema of the users in the schema definition language
+func NewUsersSchemaHandler() http.Handler {
+	return SchemaHandler(usersSchema)
+}
+
+// PanicLogger logs the panics of the resolvers
+type PanicLogger struct {
+	Logger log.Logger
+}
+
+// LogPanic logs the value of the panic as an error
+func (l PanicLogger) LogPanic(ctx context.Context, value interface{}) {
+	level.Error(l.Logger).Log("panic", fmt.Sprint(value))
+}
+
+func (u *users) context(r *http.Request) context.Context {
+	email, password, _ := r.BasicAuth()
+	ctx := context.WithValue(r.Context(), loaderKey{}, NewLoader(u.batchGetUsers, loaderWait, maxLoaderBatch))
+	return context.WithValue(ctx, credentialsKey{}, &credentials{email: email, password: password, authorized: map[int]error{}})
+}
+
+func loaderFrom(ctx context.Context) *Loader {
+	return ctx.Value(loaderKey{}).(*Loader)
+}
+
+// authorize checks the credentials of the request belong to the user, once per user and request
+func (u *users) authorize(ctx context.Context, userID int) error {
+	c := ctx.Value(credentialsKey{}).(*credentials)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err, ok := c.authorized[userID]
+	if !ok {
+		err = u.authorizer(ctx, userID, c.email, c.password)
+		c.authorized[userID] = err
+	}
+	return err
+}
+
+// visible returns the user without its details unless they belong to the credentials of the request, only the
+// users with the email of the credentials are authorized
+func (u *users) visible(ctx context.Context, user entities.User) entities.User {
+	c := ctx.Value(credentialsKey{}).(*credentials)
+	if c.email == "" || !strings.EqualFold(c.email, user.Email) || u.authorize(ctx, user.ID) != nil {
+		user.Details = entities.Details{}
+		user.DetailsStatus = entities.DetailsWithheld
+	}
+	return user
+}
+
+// batchGetUsers is the batch function of the loader, the users which do not exist are nil values
+func (u *users) batchGetUsers(ctx context.Context, ids []int) ([]interface{}, []error) {
+	logger := log.With(u.logger, "method", "batch_get_users")
+
+	values := make([]interface{}, len(ids))
+	errs := make([]error, len(ids))
+
+	res, err := u.repository.BatchGetUsers(ctx, ids)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return values, errs
+	}
+
+	found := make(map[int]entities.User, len(res))
+	for _, r := range res {
+		if r.Found {
+			r.User.ID = r.ID
+			r.User.AvatarURL = service.AvatarURL(r.ID, r.User.Avatar)
+			found[r.ID] = r.User
+		}
+	}
+	for i, id := range ids {
+		if user, ok := found[id]; ok {
+			values[i] = user
+		}
+	}
+
+	return values, errs
+}
+
+// load returns the resolver of the user read by the loader, nil when the user does not exist
+func (u *users) load(ctx context.Context, id int) (*userResolver, error) {
+	v, err := loaderFrom(ctx).Load(ctx, id)
+	if err != nil {
+		return nil, newFieldError(ctx, err)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	return &userResolver{u: u, user: v.(entities.User)}, nil
+}
+
+func (u *users) User(ctx context.Context, args struct{ ID int32 }) (*userResolver, error) {
+	return u.load(ctx, int(args.ID))
+}
+
+type usersArgs struct {
+	Filter *struct {
+		IDs *[]int32
+	}
+	Page *struct {
+		After int32
+		Size  int32
+	}
+}
+
+func (u *users) Users(ctx context.Context, args usersArgs) (*userPageResolver, error) {
+	logger := log.With(u.logger, "method", "list_users")
+	page := &userPageResolver{users: []*userResolver{}}
+
+	if args.Filter != nil && args.Filter.IDs != nil {
+		ids := *args.Filter.IDs
+		if len(ids) == 0 || len(ids) > maxLoaderBatch {
+			e := errors.NewInvalidBatchError()
+			level.Error(logger).Log("validation: ", e)
+			return nil, newFieldError(ctx, e)
+		}
+
+		keys := make([]int, len(ids))
+		for i, id := range ids {
+			keys[i] = int(id)
+		}
+
+		values, errs := loaderFrom(ctx).LoadMany(ctx, keys)
+		for i, v := range values {
+			if errs[i] != nil {
+				return nil, newFieldError(ctx, errs[i])
+			}
+			if v != nil {
+				page.users = append(page.users, &userResolver{u: u, user: v.(entities.User)})
+			}
+		}
+		return page, nil
+	}
+
+	after, size := 0, defaultPageSize
+	if args.Page != nil {
+		after, size = int(args.Page.After), int(args.Page.Size)
+	}
+	if size <= 0 || size > maxPageSize {
+		e := errors.NewInvalidRequestError(errors.FieldViolation{Field: "page.size", Description: "must be between 1 and 100"})
+		level.Error(logger).Log("validation: ", e)
+		return nil, newFieldError(ctx, e)
+	}
+
+	res, nextAfter, err := u.repository.ListUsers(ctx, after, size)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, newFieldError(ctx, err)
+	}
+
+	// the users of the page are already read along with their details
+	loader := loaderFrom(ctx)
+	for _, user := range res {
+		user.AvatarURL = service.AvatarURL(user.ID, user.Avatar)
+		loader.Prime(user.ID, user)
+		page.users = append(page.users, &userResolver{u: u, user: user})
+	}
+	if nextAfter > 0 {
+		next := int32(nextAfter)
+		page.nextAfter = &next
+	}
+
+	return page, nil
+}
+
+// userInput is the UserInput of the mutations
+type userInput struct {
+	Email       string
+	Password    string
+	DateOfBirth string
+	Details     *struct {
+		Country      *string
+		City         *string
+		MobileNumber *string
+		Married      *bool
+		Height       *float64
+		Weight       *float64
+		Attributes   *JSON
+	}
+}
+
+// inputFields renames the fields of the violations of entities.UserInput to the fields of UserInput
+var inputFields = map[string]string{
+	"date_of_birth": "dateOfBirth",
+	"information":   "details",
+	"mobile_number": "mobileNumber",
+	"height_m":      "height",
+	"weight_kg":     "weight",
+}
+
+// user validates the input with the rules of entities.UserInput so both APIs share them
+func (in userInput) user() (entities.User, error) {
+	input := entities.UserInput{Email: in.Email, Password: in.Password, DateOfBirth: in.DateOfBirth}
+	var violations []errors.FieldViolation
+	if d := in.Details; d != nil {
+		input.Details = entities.Details{
+			Country:      stringOf(d.Country),
+			City:         stringOf(d.City),
+			MobileNumber: stringOf(d.MobileNumber),
+			Married:      d.Married != nil && *d.Married,
+			Height:       float32Of(d.Height),
+			Weight:       float32Of(d.Weight),
+		}
+		if d.Attributes != nil && d.Attributes.Value != nil {
+			attributes, ok := d.Attributes.Value.(map[string]interface{})
+			if !ok {
+				violations = append(violations, errors.FieldViolation{Field: "details.attributes", Description: "must be an object"})
+			}
+			input.Details.Attributes = attributes
+		}
+	}
+
+	if err := validation.Validate(input); err != nil {
+		for _, v := range err.(errors.InvalidRequestError).Fields {
+			parts := strings.Split(v.Field, ".")
+			for j, part := range parts {
+				if name, ok := inputFields[part]; ok {
+					parts[j] = name
+				}
+			}
+			v.Field = strings.Join(parts, ".")
+			violations = append(violations, v)
+		}
+	}
+	if len(violations) > 0 {
+		return entities.User{}, errors.NewInvalidRequestError(violations...)
+	}
+
+	return entities.User{Email: input.Email, Password: input.Password, DateOfBirth: input.DateOfBirth, Details: input.Details}, nil
+}
+
+func stringOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func float32Of(f *float64) float32 {
+	if f == nil {
+		return 0
+	}
+	return float32(*f)
+}
+
+func (u *users) CreateUser(ctx context.Context, args struct{ Input userInput }) (*userResolver, error) {
+	logger := log.With(u.logger, "method", "create_user")
+
+	user, err := args.Input.user()
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return nil, newFieldError(ctx, err)
+	}
+
+	id, err := u.repository.CreateUser(ctx, user)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, newFieldError(ctx, err)
+	}
+
+	logger.Log("action", "success")
+	return u.reload(ctx, id)
+}
+
+func (u *users) UpdateUser(ctx context.Context, args struct {
+	ID    int32
+	Input userInput
+}) (*userResolver, error) {
+	logger := log.With(u.logger, "method", "update_user")
+	id := int(args.ID)
+
+	// the details are written along with the user so only their owner updates it
+	if err := u.authorize(ctx, id); err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, newFieldError(ctx, err)
+	}
+
+	user, err := args.Input.user()
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return nil, newFieldError(ctx, err)
+	}
+
+	if _, err := u.repository.UpdateUser(ctx, entities.UserUpdate{UserID: id, User: user}); err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, newFieldError(ctx, err)
+	}
+
+	logger.Log("action", "success")
+	return u.reload(ctx, id)
+}
+
+func (u *users) DeleteUser(ctx context.Context, args struct{ ID int32 }) (bool, error) {
+	logger := log.With(u.logger, "method", "delete_user")
+	id := int(args.ID)
+
+	if err := u.authorize(ctx, id); err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, newFieldError(ctx, err)
+	}
+
+	res, err := u.repository.DeleteUser(ctx, id)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, newFieldError(ctx, err)
+	}
+	loaderFrom(ctx).Clear(id)
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// reload reads the user changed by a mutation again, the value cached by the loader is stale
+func (u *users) reload(ctx context.Context, id int) (*userResolver, error) {
+	loaderFrom(ctx).Clear(id)
+
+	user, err := u.load(ctx, id)
+	if err == nil && user == nil {
+		return nil, newFieldError(ctx, errors.NewUserNotFoundError())
+	}
+	return user, err
+}
+
+// userResolver resolves the fields of one user, its details are withheld from everyone but their owner
+type userResolver struct {
+	u    *users
+	user entities.User
+}
+
+func (r *userResolver) ID() int32           { return int32(r.user.ID) }
+func (r *userResolver) Email() string       { return r.user.Email }
+func (r *userResolver) DateOfBirth() string { return r.user.DateOfBirth }
+func (r *userResolver) Age() int32          { return int32(r.user.Age) }
+
+func (r *userResolver) Details(ctx context.Context) *detailsResolver {
+	user := r.u.visible(ctx, r.user)
+	if user.DetailsStatus != "" {
+		return nil
+	}
+	return &detailsResolver{details: user.Details}
+}
+
+func (r *userResolver) DetailsStatus(ctx context.Context) string {
+	return r.u.visible(ctx, r.user).DetailsStatus
+}
+
+type userPageResolver struct {
+	users     []*userResolver
+	nextAfter *int32
+}
+
+func (r *userPageResolver) Users() []*userResolver { return r.users }
+func (r *userPageResolver) NextAfter() *int32      { return r.nextAfter }
+
+type detailsResolver struct {
+	details entities.Details
+}
+
+func (r *detailsResolver) Country() string      { return r.details.Country }
+func (r *detailsResolver) City() string         { return r.details.City }
+func (r *detailsResolver) MobileNumber() string { return r.details.MobileNumber }
+func (r *detailsResolver) MobileVerified() bool { return r.details.MobileVerified }
+func (r *detailsResolver) Married() bool        { return r.details.Married }
+func (r *detailsResolver) Height() float64      { return float64Of(r.details.Height) }
+func (r *detailsResolver) Weight() float64      { return float64Of(r.details.Weight) }
+
+func (r *detailsResolver) Attributes() *JSON {
+	if r.details.Attributes == nil {
+		return nil
+	}
+	return &JSON{Value: r.details.Attributes}
+}
+
+func (r *detailsResolver) AvatarURL() *string {
+	if r.details.AvatarURL == "" {
+		return nil
+	}
+	return &r.details.AvatarURL
+}
+
+// float64Of keeps the shortest decimal of the float32 so 1.8 does not become 1.7999999523162842
+func float64Of(f float32) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return v
+}
+
+// JSON is the scalar of the attributes, any JSON value whose numbers are float64 as within encoding/json
+type JSON struct {
+	Value interface{}
+}
+
+// ImplementsGraphQLType maps the type to the JSON scalar of the schema
+func (JSON) ImplementsGraphQLType(name string) bool {
+	return name == "JSON"
+}
+
+// UnmarshalGraphQL takes the literals of the queries, whose integers are int32, and the JSON variables
+func (j *JSON) UnmarshalGraphQL(input interface{}) error {
+	v, err := plainJSON(input)
+	j.Value = v
+	return err
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.Value)
+}
+
+func plainJSON(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int32:
+		return float64(v), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			p, err := plainJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = p
+		}
+		return list, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			p, err := plainJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = p
+		}
+		return object, nil
+	case nil, bool, float64, string:
+		return v, nil
+	}
+	return nil, fmt.Errorf("JSON cannot represent value: %v", value)
+}
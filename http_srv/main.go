@@ -2,25 +2,39 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/caarlos0/env/v6"
+	kitExpvar "github.com/go-kit/kit/metrics/expvar"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/guard"
+	"github.com/mauricioww/user_microsrv/http_srv/blob"
+	"github.com/mauricioww/user_microsrv/http_srv/cache"
+	"github.com/mauricioww/user_microsrv/http_srv/graphql"
 	"github.com/mauricioww/user_microsrv/http_srv/repository"
+	"github.com/mauricioww/user_microsrv/http_srv/saga"
 	"github.com/mauricioww/user_microsrv/http_srv/service"
 	"github.com/mauricioww/user_microsrv/http_srv/transport"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/mauricioww/user_microsrv/tenant"
 	"google.golang.org/grpc"
 )
 
 func main() {
 	cts := constants{}
 
-	if err := env.Parse(&cts); err != nil {
+	if err := env.ParseWithFuncs(&cts, map[reflect.Type]env.ParserFunc{reflect.TypeOf(map[string]string(nil)): parsePairs}); err != nil {
 		fmt.Printf("%+v\n", err)
 	}
 
@@ -48,7 +62,9 @@ func main() {
 	{
 		// userGRPC
 		userAddr := fmt.Sprintf("%v:%v", cts.UserHost, cts.UserPort)
-		userGRPC, grpcErr = grpc.Dial(userAddr, grpc.WithInsecure())
+		userGRPC, grpcErr = grpc.Dial(userAddr, grpc.WithInsecure(), grpc.WithChainUnaryInterceptor(tenant.UnaryClientInterceptor(), locale.UnaryClientInterceptor(),
+			guard.UnaryClientInterceptor(cts.InternalToken)),
+			grpc.WithChainStreamInterceptor(tenant.StreamClientInterceptor(), locale.StreamClientInterceptor()))
 		if grpcErr != nil {
 			level.Error(logger).Log("gRPC", grpcErr)
 			os.Exit(-1)
@@ -56,18 +72,88 @@ func main() {
 
 		// detailsGRPC
 		detailsAddr := fmt.Sprintf("%v:%v", cts.DetailsHost, cts.DetailsPort)
-		detailsGRPC, grpcErr = grpc.Dial(detailsAddr, grpc.WithInsecure())
+		detailsGRPC, grpcErr = grpc.Dial(detailsAddr, grpc.WithInsecure(), grpc.WithChainUnaryInterceptor(tenant.UnaryClientInterceptor(), locale.UnaryClientInterceptor(),
+			guard.UnaryClientInterceptor(cts.InternalToken)),
+			grpc.WithChainStreamInterceptor(tenant.StreamClientInterceptor(), locale.StreamClientInterceptor()))
 		if grpcErr != nil {
 			level.Error(logger).Log("gRPC", grpcErr)
 			os.Exit(-1)
 		}
 	}
 
+	store, storeErr := blob.NewLocalStore(cts.AvatarDir)
+	if storeErr != nil {
+		level.Error(logger).Log("blob", storeErr)
+		os.Exit(-1)
+	}
+
+	sagaLog, sagaErr := saga.NewFileLog(cts.SagaDir)
+	if sagaErr != nil {
+		level.Error(logger).Log("saga", sagaErr)
+		os.Exit(-1)
+	}
+
+	webhookStore, webhookErr := webhook.NewFileStore(cts.WebhookDir)
+	if webhookErr != nil {
+		level.Error(logger).Log("webhook", webhookErr)
+		os.Exit(-1)
+	}
+
 	ctx := context.Background()
+	dispatcher := webhook.NewDispatcher(webhookStore, webhook.Options{
+		MaxAttempts: cts.WebhookAttempts,
+		BaseDelay:   cts.WebhookBaseDelay,
+		MaxDelay:    cts.WebhookMaxDelay,
+		Retention:   cts.WebhookRetention,
+	}, logger)
+	{
+		// the user lifecycle events published by the gRPC services feed the webhooks
+		bus, err := events.NewBus(cts.EventBus, cts.NatsURL)
+		if err != nil {
+			level.Error(logger).Log("events", err)
+			os.Exit(-1)
+		}
+		defer bus.Close()
+
+		if _, err := bus.Subscribe(events.SubjectPrefix+".>", dispatcher.Handle); err != nil {
+			level.Error(logger).Log("events", err)
+			os.Exit(-1)
+		}
+
+		go dispatcher.Run(ctx)
+	}
+
 	var httpSrv service.HTTPServicer
+	// GetUser reads go through the cache unless it is disabled with a zero TTL
+	var reads repository.HTTPRepositorier
 	{
-		repository := repository.NewHTTPRepository(userGRPC, detailsGRPC, logger)
-		httpSrv = service.NewHTTPService(repository, logger)
+		repository := repository.NewHTTPRepository(userGRPC, detailsGRPC, sagaLog, logger)
+
+		reads = repository
+		if cts.CacheTTL > 0 {
+			evictions := kitExpvar.NewCounter("cache_evictions")
+			backend := cache.NewMemoryBackend(cts.CacheSize, evictions)
+			reads = cache.NewRepository(repository, backend, cts.CacheTTL, cache.Metrics{
+				Hits:      kitExpvar.NewCounter("cache_hits"),
+				Misses:    kitExpvar.NewCounter("cache_misses"),
+				Evictions: evictions,
+			}, logger)
+		}
+
+		httpSrv = service.NewHTTPService(reads, store, dispatcher, logger)
+
+		// finish the sagas left by a previous run and keep retrying the ones whose compensation failed
+		if err := repository.ResumeSagas(ctx); err != nil {
+			level.Error(logger).Log("saga", err)
+		}
+
+		go func() {
+			for range time.Tick(cts.SagaRetry) {
+				if err := repository.ResumeSagas(ctx); err != nil {
+					level.Error(logger).Log("saga", err)
+				}
+			}
+		}()
 	}
 
 	err := make(chan error)
@@ -82,7 +168,16 @@ func main() {
 
 	go func() {
 		fmt.Println("Listengin on port: 8080")
-		httpHandler := transport.NewHTTPServer(ctx, httpEndpoints)
+		httpHandler := http.NewServeMux()
+		httpHandler.Handle("/debug/vars", expvar.Handler())
+		graphQL := transport.NewGraphQLServer(graphql.NewUsersHandler(reads, httpSrv.AuthorizeUser, logger), graphql.NewUsersSchemaHandler())
+		httpHandler.Handle("/graphql", transport.RecoveryMiddleware(logger)(graphQL))
+		httpHandler.Handle("/graphql/", transport.RecoveryMiddleware(logger)(graphQL))
+		var handler http.Handler = transport.NewHTTPServer(ctx, httpEndpoints, cts.AdminTokens)
+		if cts.OpenAPIValidation {
+			handler = transport.OpenAPIMiddleware()(handler)
+		}
+		httpHandler.Handle("/", transport.RecoveryMiddleware(logger)(handler))
 		err <- http.ListenAndServe(":8080", httpHandler)
 	}()
 
@@ -90,8 +185,44 @@ func main() {
 }
 
 type constants struct {
-	UserHost    string `env:"USER_SERVER,required"`
-	UserPort    int    `env:"USER_PORT" envDefault:"50051"`
-	DetailsHost string `env:"DETAILS_SERVER,required"`
-	DetailsPort int    `env:"DETAILS_PORT" envDefault:"50051"`
+	UserHost    string        `env:"USER_SERVER,required"`
+	UserPort    int           `env:"USER_PORT" envDefault:"50051"`
+	DetailsHost string        `env:"DETAILS_SERVER,required"`
+	DetailsPort int           `env:"DETAILS_PORT" envDefault:"50051"`
+	AvatarDir   string        `env:"AVATAR_DIR" envDefault:"/var/lib/http_srv/avatars"`
+	SagaDir     string        `env:"SAGA_DIR" envDefault:"/var/lib/http_srv/sagas"`
+	SagaRetry   time.Duration `env:"SAGA_RETRY_INTERVAL" envDefault:"1m"`
+
+	// AdminTokens maps every tenant to the token of its admin routes, as tenant:token pairs separated by commas
+	AdminTokens map[string]string `env:"ADMIN_TOKENS"`
+
+	// InternalToken is sent to the gRPC servers to call the methods which are not meant for other clients
+	InternalToken string `env:"INTERNAL_TOKEN,required"`
+
+	EventBus string `env:"EVENT_BUS,required"`
+	NatsURL  string `env:"NATS_URL" envDefault:"nats://nats:4222"`
+
+	WebhookDir       string        `env:"WEBHOOK_DIR" envDefault:"/var/lib/http_srv/webhooks"`
+	WebhookAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+	WebhookBaseDelay time.Duration `env:"WEBHOOK_BASE_DELAY" envDefault:"10s"`
+	WebhookMaxDelay  time.Duration `env:"WEBHOOK_MAX_DELAY" envDefault:"1h"`
+	WebhookRetention time.Duration `env:"WEBHOOK_RETENTION" envDefault:"720h"`
+
+	CacheSize int           `env:"CACHE_SIZE" envDefault:"10000"`
+	CacheTTL  time.Duration `env:"CACHE_TTL" envDefault:"30s"`
+
+	OpenAPIValidation bool `env:"OPENAPI_VALIDATION" envDefault:"false"`
+}
+
+// parsePairs parses the key:value pairs separated by commas of a map setting
+func parsePairs(v string) (interface{}, error) {
+	res := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		i := strings.Index(pair, ":")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("invalid pair %q, expected key:value", pair)
+		}
+		res[pair[:i]] = pair[i+1:]
+	}
+	return res, nil
 }
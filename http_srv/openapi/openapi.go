@@ -0,0 +1,237 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Version is the OpenAPI version of the documents
+const Version = "3.1.0"
+
+// JSON is the media type of the bodies when a route does not set its own
+const JSON = "application/json"
+
+var pathParam = regexp.MustCompile(`{([^}]+)}`)
+
+// Document is an OpenAPI document, only the objects used by these services are modeled
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	// parameters and responses are shared by every operation
+	parameters []Parameter
+	responses  map[string]Response
+	// types stores the type of each schema within the components so two types never share a name
+	types map[string]reflect.Type
+}
+
+// Info stores the metadata of the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is the URL the paths are relative to
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem stores the operations of one path keyed by their lower case method
+type PathItem map[string]*Operation
+
+// Operation describes one route
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a value taken from the path, the query or the headers
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the bodies accepted by an operation keyed by their media type
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one of the responses of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType stores the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components stores the objects referenced by the operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how the clients authenticate
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// Schema is a JSON Schema, Ref points to one of the components
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentMediaType     string             `json:"contentMediaType,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// Route describes one operation for Add, Request and Response are either a *Schema or a value whose type is reflected
+// into a schema, a nil Request means the operation takes no body
+type Route struct {
+	Method     string
+	Path       string
+	ID         string
+	Summary    string
+	Tag        string
+	Parameters []Parameter
+	Security   string
+
+	Request        interface{}
+	RequestContent []string
+
+	Response        interface{}
+	ResponseContent []string
+}
+
+// New returns an empty document
+func New(title string, version string) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+		responses:  map[string]Response{},
+		types:      map[string]reflect.Type{},
+	}
+}
+
+// Server adds a URL the paths are served under
+func (d *Document) Server(url string, description string) {
+	d.Servers = append(d.Servers, Server{URL: url, Description: description})
+}
+
+// Use adds the parameters to the operations added afterwards
+func (d *Document) Use(parameters ...Parameter) {
+	d.parameters = append(d.parameters, parameters...)
+}
+
+// DefaultResponse sets the body of the errors of the operations added afterwards
+func (d *Document) DefaultResponse(description string, contentType string, v interface{}) {
+	d.responses["default"] = Response{
+		Description: description,
+		Content:     map[string]MediaType{contentType: {Schema: d.Schema(v)}},
+	}
+}
+
+// SecurityScheme declares a scheme the routes can require by its name
+func (d *Document) SecurityScheme(name string, scheme SecurityScheme) {
+	if d.Components.SecuritySchemes == nil {
+		d.Components.SecuritySchemes = map[string]SecurityScheme{}
+	}
+	d.Components.SecuritySchemes[name] = scheme
+}
+
+// Add describes the route within the document, it panics when a parameter of the path is not declared since the
+// routes are declared within the code
+func (d *Document) Add(r Route) {
+	for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+		if !declared(r.Parameters, m[1]) {
+			panic(fmt.Sprintf("openapi: missing path parameter %v of %v %v", m[1], r.Method, r.Path))
+		}
+	}
+
+	op := &Operation{
+		OperationID: r.ID,
+		Summary:     r.Summary,
+		Parameters:  append(append([]Parameter(nil), r.Parameters...), d.parameters...),
+		Responses:   map[string]Response{},
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Security != "" {
+		op.Security = []map[string][]string{{r.Security: {}}}
+	}
+
+	if r.Request != nil {
+		op.RequestBody = &RequestBody{Required: true, Content: d.content(r.Request, r.RequestContent)}
+	}
+
+	ok := Response{Description: "OK"}
+	if r.Response != nil {
+		ok.Content = d.content(r.Response, r.ResponseContent)
+	}
+	op.Responses["200"] = ok
+	for code, res := range d.responses {
+		op.Responses[code] = res
+	}
+
+	item, exists := d.Paths[r.Path]
+	if !exists {
+		item = PathItem{}
+		d.Paths[r.Path] = item
+	}
+	item[strings.ToLower(r.Method)] = op
+}
+
+func (d *Document) content(v interface{}, contentTypes []string) map[string]MediaType {
+	if len(contentTypes) == 0 {
+		contentTypes = []string{JSON}
+	}
+
+	schema := d.Schema(v)
+	content := make(map[string]MediaType, len(contentTypes))
+	for _, ct := range contentTypes {
+		content[ct] = MediaType{Schema: schema}
+	}
+	return content
+}
+
+func declared(parameters []Parameter, name string) bool {
+	for _, p := range parameters {
+		if p.In == "path" && p.Name == name {
+			return true
+		}
+	}
+	return false
+}
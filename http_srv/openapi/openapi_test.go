@@ -0,0 +1,177 @@
+package openapi_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// Info is embedded by signUp and referenced by its schema
+type Info struct {
+	Height float32 `json:"height_m" validate:"omitempty,min=0.5,max=2.5"`
+}
+
+type signUp struct {
+	UserID int      `validate:"min=1"`
+	Email  string   `json:"email" validate:"required,email,max=254"`
+	Born   string   `json:"born" validate:"date"`
+	Tags   []string `json:"tags" validate:"max=2,oneof=red green"`
+	Secret string   `json:"-"`
+	Info   `json:"information"`
+}
+
+func document() *openapi.Document {
+	min := 1.0
+	doc := openapi.New("Test", "1.0.0")
+	doc.Add(openapi.Route{
+		Method:     "PUT",
+		Path:       "/users/{id}",
+		ID:         "updateUser",
+		Parameters: []openapi.Parameter{{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Minimum: &min}}},
+		Request:    signUp{},
+	})
+	doc.Add(openapi.Route{
+		Method:     "GET",
+		Path:       "/users/changes",
+		ID:         "listChanges",
+		Parameters: []openapi.Parameter{{Name: "page_size", In: "query", Schema: &openapi.Schema{Type: "integer"}}},
+	})
+	return doc
+}
+
+func TestSchema(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	doc := document()
+
+	// act
+	ref := doc.Paths["/users/{id}"]["put"].RequestBody.Content[openapi.JSON].Schema
+	s := doc.Components.Schemas["signUp"]
+
+	// assert
+	assert.Equal("#/components/schemas/signUp", ref.Ref)
+	assert.Equal([]string{"email"}, s.Required)
+	assert.NotContains(s.Properties, "user_id")
+	assert.NotContains(s.Properties, "-")
+	assert.Equal("email", s.Properties["email"].Format)
+	assert.Equal(254, *s.Properties["email"].MaxLength)
+	assert.Equal("date", s.Properties["born"].Format)
+	assert.Equal(2, *s.Properties["tags"].MaxItems)
+	assert.Equal([]interface{}{"red", "green"}, s.Properties["tags"].Items.Enum)
+	assert.Equal("#/components/schemas/Info", s.Properties["information"].Ref)
+	assert.Equal(2.5, *doc.Components.Schemas["Info"].Properties["height_m"].Maximum)
+}
+
+func TestAddMissingPathParameter(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	doc := openapi.New("Test", "1.0.0")
+
+	// act
+	add := func() { doc.Add(openapi.Route{Method: "GET", Path: "/users/{id}", ID: "getUser"}) }
+
+	// assert
+	assert.Panics(add)
+}
+
+func TestValidateRequest(t *testing.T) {
+	test_cases := []struct {
+		testName   string
+		method     string
+		target     string
+		body       string
+		violations []errors.FieldViolation
+	}{
+		{
+			testName:   "valid request",
+			method:     "PUT",
+			target:     "/users/1",
+			body:       `{"email":"user@email.com","born":"1996-02-29","tags":["red"],"information":{"height_m":1.8}}`,
+			violations: nil,
+		},
+		{
+			testName: "every violation collected",
+			method:   "PUT",
+			target:   "/users/0",
+			body:     `{"email":"","born":"29/02/1996","tags":["red","blue"],"information":{"height_m":3}}`,
+			violations: []errors.FieldViolation{
+				{Field: "id", Description: "must be at least 1"},
+				{Field: "email", Description: "is required"},
+				{Field: "born", Description: "expected a date as YYYY-MM-DD"},
+				{Field: "information.height_m", Description: "must be at most 2.5"},
+				{Field: "tags[1]", Description: "must be one of red, green"},
+			},
+		},
+		{
+			testName:   "wrong type",
+			method:     "PUT",
+			target:     "/users/abc",
+			body:       `{"email":7}`,
+			violations: []errors.FieldViolation{{Field: "id", Description: "must be an integer"}, {Field: "email", Description: "must be a string"}},
+		},
+		{
+			testName:   "missing body",
+			method:     "PUT",
+			target:     "/users/1",
+			body:       "",
+			violations: []errors.FieldViolation{{Field: "body", Description: "is required"}},
+		},
+		{
+			testName:   "malformed body",
+			method:     "PUT",
+			target:     "/users/1",
+			body:       `{"email":`,
+			violations: []errors.FieldViolation{{Field: "body", Description: "malformed JSON"}},
+		},
+		{
+			testName:   "literal segments win over parameters",
+			method:     "GET",
+			target:     "/users/changes?page_size=ten",
+			violations: []errors.FieldViolation{{Field: "page_size", Description: "must be an integer"}},
+		},
+		{
+			testName:   "unknown route",
+			method:     "POST",
+			target:     "/unknown",
+			violations: nil,
+		},
+	}
+
+	doc := document()
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			r := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", "application/json")
+
+			// act
+			violations := doc.ValidateRequest(r)
+
+			// assert
+			assert.ElementsMatch(tc.violations, violations)
+		})
+	}
+}
+
+func TestValidateRequestKeepsBody(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	body := `{"email":"user@email.com"}`
+	r := httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	// act
+	document().ValidateRequest(r)
+	read, err := ioutil.ReadAll(r.Body)
+
+	// assert
+	assert.Nil(err)
+	assert.Equal(body, string(read))
+}
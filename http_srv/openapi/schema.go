@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mauricioww/user_microsrv/validation"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns the schema of the value, the named structs are stored within the components and referenced, the
+// fields follow their json tag, the ones without it are filled from the path, the query or the headers so they stay
+// out of the bodies, and the validate tag adds the constraints of each field
+func (d *Document) Schema(v interface{}) *Schema {
+	if s, ok := v.(*Schema); ok {
+		return s
+	}
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentMediaType: "application/octet-stream"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return &Schema{Type: "object", AdditionalProperties: true}
+		}
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return d.structRef(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (d *Document) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return d.structSchema(t)
+	}
+
+	name := t.Name()
+	if other, ok := d.types[name]; ok && other != t {
+		name = strings.Title(pkgName(t)) + name
+	}
+
+	if _, ok := d.Components.Schemas[name]; !ok {
+		// the placeholder ends the recursion of the types which reference themselves
+		s := &Schema{}
+		d.types[name] = t
+		d.Components.Schemas[name] = s
+		*s = *d.structSchema(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(s, t)
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			if ft := indirect(f.Type); ft.Kind() == reflect.Struct {
+				d.addFields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" || name == "" {
+			continue
+		}
+
+		p := d.schemaOf(f.Type)
+		if constrain(p, f.Type, f.Tag.Get(validation.TagKey)) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+}
+
+// constrain adds the rules of the validate tag to the schema and returns whether the field is required
+func constrain(s *Schema, t reflect.Type, tag string) bool {
+	var required bool
+	kind := indirect(t).Kind()
+
+	for _, part := range strings.Split(tag, ",") {
+		name, arg := strings.TrimSpace(part), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, arg = name[:i], name[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			bound(s, kind, name == "min", limit)
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "date":
+			s.Format = "date"
+		case "oneof":
+			// the rule checks every item of the collections
+			target := s
+			if s.Type == "array" && s.Items != nil {
+				target = s.Items
+			}
+			for _, o := range strings.Fields(arg) {
+				target.Enum = append(target.Enum, enumValue(target.Type, o))
+			}
+		}
+	}
+
+	return required
+}
+
+func bound(s *Schema, kind reflect.Kind, min bool, limit float64) {
+	switch kind {
+	case reflect.String:
+		n := int(limit)
+		if min {
+			s.MinLength = &n
+		} else {
+			s.MaxLength = &n
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n := int(limit)
+		if min {
+			s.MinItems = &n
+		} else {
+			s.MaxItems = &n
+		}
+	default:
+		if min {
+			s.Minimum = &limit
+		} else {
+			s.Maximum = &limit
+		}
+	}
+}
+
+// enumValue keeps the options of the numeric enums as numbers
+func enumValue(schemaType string, option string) interface{} {
+	if schemaType == "integer" || schemaType == "number" {
+		if n, err := strconv.ParseFloat(option, 64); err == nil {
+			return n
+		}
+	}
+	return option
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func pkgName(t reflect.Type) string {
+	path := t.PkgPath()
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		path = path[i+1:]
+	}
+	return path
+}
@@ -0,0 +1,359 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mauricioww/user_microsrv/errors"
+)
+
+// ValidateRequest checks the request against the operation of its method and path, the violations use the wording of
+// the validation package so both layers answer alike, the requests of unknown routes and the bodies which are not
+// JSON are left to the handlers
+func (d *Document) ValidateRequest(r *http.Request) []errors.FieldViolation {
+	op, params := d.match(r.Method, r.URL.Path)
+	if op == nil {
+		return nil
+	}
+
+	var violations []errors.FieldViolation
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = params[p.Name]
+		case "query":
+			if values, ok := r.URL.Query()[p.Name]; ok {
+				value, present = values[0], true
+			}
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		}
+
+		if !present {
+			if p.Required {
+				violations = append(violations, errors.FieldViolation{Field: p.Name, Description: "is required"})
+			}
+			continue
+		}
+		if description := checkParameter(p.Schema, value); description != "" {
+			violations = append(violations, errors.FieldViolation{Field: p.Name, Description: description})
+		}
+	}
+
+	if op.RequestBody != nil {
+		violations = append(violations, d.checkBody(r, op.RequestBody)...)
+	}
+
+	return violations
+}
+
+// match returns the operation whose path template matches, the templates with more literal segments win so
+// /users/changes is not taken for /users/{id}, the paths may be prefixed by the URL of one of the servers
+func (d *Document) match(method string, path string) (*Operation, map[string]string) {
+	for _, s := range d.Servers {
+		if prefix := strings.TrimSuffix(s.URL, "/"); prefix != "" && strings.HasPrefix(path, prefix+"/") {
+			path = strings.TrimPrefix(path, prefix)
+			break
+		}
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var best *Operation
+	var bestParams map[string]string
+	bestLiterals := -1
+	for template, item := range d.Paths {
+		op, ok := item[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(strings.Trim(template, "/"), "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+
+		params := map[string]string{}
+		literals := 0
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				params[part[1:len(part)-1]] = segments[i]
+				continue
+			}
+			if part != segments[i] {
+				literals = -1
+				break
+			}
+			literals++
+		}
+
+		if literals > bestLiterals {
+			best, bestParams, bestLiterals = op, params, literals
+		}
+	}
+
+	return best, bestParams
+}
+
+func (d *Document) checkBody(r *http.Request, body *RequestBody) []errors.FieldViolation {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	content, ok := body.Content[mediaType]
+	if mediaType != JSON || !ok {
+		return nil
+	}
+
+	var data []byte
+	if r.Body != nil {
+		data, _ = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+	}
+	// the handler reads the body again
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return []errors.FieldViolation{{Field: "body", Description: "is required"}}
+		}
+		return nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return []errors.FieldViolation{{Field: "body", Description: "malformed JSON"}}
+	}
+
+	return d.check(content.Schema, value, "")
+}
+
+// check returns the violations of the value, name is the path of the value within the body
+func (d *Document) check(s *Schema, value interface{}, name string) []errors.FieldViolation {
+	s = d.resolve(s)
+	if s == nil {
+		return nil
+	}
+
+	field := name
+	if field == "" {
+		field = "body"
+	}
+	violation := func(description string) []errors.FieldViolation {
+		return []errors.FieldViolation{{Field: field, Description: description}}
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return violation("must be an object")
+		}
+		return d.checkObject(s, object, name)
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return violation("must be an array")
+		}
+		if description := itemsBound(s, len(array)); description != "" {
+			return violation(description)
+		}
+		var violations []errors.FieldViolation
+		for i, item := range array {
+			violations = append(violations, d.check(s.Items, item, fmt.Sprintf("%v[%v]", field, i))...)
+		}
+		return violations
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return violation("must be a string")
+		}
+		if description := checkString(s, str); description != "" {
+			return violation(description)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return violation(fmt.Sprintf("must be %v", article(s.Type)))
+		}
+		if description := checkNumber(s, string(number)); description != "" {
+			return violation(description)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return violation("must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) checkObject(s *Schema, object map[string]interface{}, name string) []errors.FieldViolation {
+	prefix := ""
+	if name != "" {
+		prefix = name + "."
+	}
+
+	var violations []errors.FieldViolation
+	for _, required := range s.Required {
+		if v, ok := object[required]; !ok || isZero(v) {
+			violations = append(violations, errors.FieldViolation{Field: prefix + required, Description: "is required"})
+		}
+	}
+
+	// the keys are sorted so the violations keep their order between requests
+	keys := make([]string, 0, len(s.Properties))
+	for key := range s.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// the empty required values are already reported
+		v, ok := object[key]
+		if !ok || isZero(v) {
+			continue
+		}
+		violations = append(violations, d.check(s.Properties[key], v, prefix+key)...)
+	}
+
+	return violations
+}
+
+func (d *Document) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// checkParameter parses the value of a path, query or header parameter by the type of its schema
+func checkParameter(s *Schema, value string) string {
+	if s == nil {
+		return ""
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		return checkNumber(s, value)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be a boolean"
+		}
+	case "string":
+		return checkString(s, value)
+	}
+	return ""
+}
+
+func checkString(s *Schema, value string) string {
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		return fmt.Sprintf("must be at least %v characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return fmt.Sprintf("must be at most %v characters", *s.MaxLength)
+	}
+
+	switch s.Format {
+	case "email":
+		if a, err := mail.ParseAddress(value); err != nil || a.Address != value {
+			return "must be a valid email address"
+		}
+	case "uri":
+		u, err := url.ParseRequestURI(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an http or https URL"
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "expected a date as YYYY-MM-DD"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "expected a date and time as RFC 3339"
+		}
+	}
+
+	return enum(s, value)
+}
+
+func checkNumber(s *Schema, value string) string {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || (s.Type == "integer" && n != float64(int64(n))) {
+		return fmt.Sprintf("must be %v", article(s.Type))
+	}
+
+	if s.Minimum != nil && n < *s.Minimum {
+		return fmt.Sprintf("must be at least %v", *s.Minimum)
+	}
+	if s.Maximum != nil && n > *s.Maximum {
+		return fmt.Sprintf("must be at most %v", *s.Maximum)
+	}
+
+	return enum(s, value)
+}
+
+func itemsBound(s *Schema, n int) string {
+	if s.MinItems != nil && n < *s.MinItems {
+		return fmt.Sprintf("must be at least %v items", *s.MinItems)
+	}
+	if s.MaxItems != nil && n > *s.MaxItems {
+		return fmt.Sprintf("must be at most %v items", *s.MaxItems)
+	}
+	return ""
+}
+
+func enum(s *Schema, value string) string {
+	if len(s.Enum) == 0 {
+		return ""
+	}
+
+	options := make([]string, len(s.Enum))
+	for i, o := range s.Enum {
+		options[i] = fmt.Sprint(o)
+		if options[i] == value {
+			return ""
+		}
+	}
+	return fmt.Sprintf("must be one of %v", strings.Join(options, ", "))
+}
+
+// isZero mirrors the zero values of Go so the required fields behave as within the validation package
+func isZero(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case json.Number:
+		n, err := v.Float64()
+		return err == nil && n == 0
+	}
+	return false
+}
+
+func article(schemaType string) string {
+	if schemaType == "integer" {
+		return "an integer"
+	}
+	return "a number"
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"math"
+
+	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
+)
+
+// attributesToProto translates the JSON decoded attributes, whole numbers are sent as ints
+// since encoding/json decodes every number as float64
+func attributesToProto(values map[string]interface{}) map[string]*detailspb.Value {
+	if len(values) == 0 {
+		return nil
+	}
+
+	res := make(map[string]*detailspb.Value, len(values))
+	for name, v := range values {
+		switch t := v.(type) {
+		case string:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_StringValue{StringValue: t}}
+		case bool:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_BoolValue{BoolValue: t}}
+		case int:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_IntValue{IntValue: int64(t)}}
+		case int64:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_IntValue{IntValue: t}}
+		case float64:
+			if t == math.Trunc(t) && math.Abs(t) < math.MaxInt64 {
+				res[name] = &detailspb.Value{Kind: &detailspb.Value_IntValue{IntValue: int64(t)}}
+			} else {
+				res[name] = &detailspb.Value{Kind: &detailspb.Value_FloatValue{FloatValue: t}}
+			}
+		default:
+			res[name] = &detailspb.Value{}
+		}
+	}
+	return res
+}
+
+func attributesFromProto(values map[string]*detailspb.Value) map[string]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	res := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		switch k := v.GetKind().(type) {
+		case *detailspb.Value_StringValue:
+			res[name] = k.StringValue
+		case *detailspb.Value_IntValue:
+			res[name] = k.IntValue
+		case *detailspb.Value_FloatValue:
+			res[name] = k.FloatValue
+		case *detailspb.Value_BoolValue:
+			res[name] = k.BoolValue
+		}
+	}
+	return res
+}
@@ -2,13 +2,19 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/saga"
 	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
 	"github.com/mauricioww/user_microsrv/user_srv/userpb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // HTTPRepositorier describes the necessary methods to send requests to both gRPC servers
@@ -17,59 +23,71 @@ type HTTPRepositorier interface {
 	Authenticate(ctx context.Context, session entities.Session) (bool, error)
 	UpdateUser(ctx context.Context, user entities.UserUpdate) (bool, error)
 	GetUser(ctx context.Context, id int) (entities.User, error)
+	BatchGetUsers(ctx context.Context, ids []int) ([]entities.UserResult, error)
 	DeleteUser(ctx context.Context, id int) (bool, error)
+	AddAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error)
+	ListAddresses(ctx context.Context, userID int) ([]entities.Address, error)
+	GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error)
+	UpdateAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error)
+	DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error)
+	SendPhoneVerification(ctx context.Context, userID int) (bool, error)
+	VerifyPhone(ctx context.Context, userID int, code string) (bool, error)
+	GetUserDetails(ctx context.Context, userID int) (entities.Details, error)
+	SetUserDetails(ctx context.Context, userID int, details entities.Details, revision int64) (bool, error)
+	DeleteUserDetails(ctx context.Context, userID int) (bool, error)
+	ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, int, error)
+	TakenEmails(ctx context.Context, emails []string) ([]string, error)
+	SetAvatar(ctx context.Context, userID int, ref string) (bool, error)
+	GetAvatar(ctx context.Context, userID int) (string, error)
+	ListUserChanges(ctx context.Context, cursor string, limit int) ([]entities.Change, string, error)
+	ListDetailsChanges(ctx context.Context, cursor string, limit int) ([]entities.Change, string, error)
+	WatchUserChanges(ctx context.Context, userID int, cursor string, send func(entities.Change) error) error
+	WatchDetailsChanges(ctx context.Context, userID int, cursor string, send func(entities.Change) error) error
 }
 
+// maxDetailsBatch is the most users whose details the details gRPC server returns within one call
+const maxDetailsBatch = 100
+
+// DefaultFanOutTimeout bounds the calls done to both gRPC servers to read a user
+const DefaultFanOutTimeout = 5 * time.Second
+
 // HTTPRepository type implement the HTTPRepositorier interface
 type HTTPRepository struct {
 	userClient    userpb.UserServiceClient
 	detailsClient detailspb.UserDetailsServiceClient
+	sagas         *saga.Coordinator
+	fanOutTimeout time.Duration
 	logger        log.Logger
 }
 
-// NewHTTPRepository returns an HTTPRepository tye pointer
-func NewHTTPRepository(conn1 *grpc.ClientConn, conn2 *grpc.ClientConn, logger log.Logger) *HTTPRepository {
-	return &HTTPRepository{
+// NewHTTPRepository returns an HTTPRepository tye pointer, the writes which span both gRPC servers are recorded within sagaLog
+func NewHTTPRepository(conn1 *grpc.ClientConn, conn2 *grpc.ClientConn, sagaLog saga.Log, logger log.Logger) *HTTPRepository {
+	r := &HTTPRepository{
 		userClient:    userpb.NewUserServiceClient(conn1),
 		detailsClient: detailspb.NewUserDetailsServiceClient(conn2),
+		sagas:         saga.NewCoordinator(sagaLog, logger),
+		fanOutTimeout: DefaultFanOutTimeout,
 		logger:        log.With(logger, "http_service", "repository"),
 	}
+
+	r.registerSagas()
+	return r
 }
 
-// CreateUser sends the original data to both gRPC servers
+// CreateUser sends the original data to both gRPC servers, the user is purged if its details can not be set
 func (r *HTTPRepository) CreateUser(ctx context.Context, user entities.User) (int, error) {
 	logger := log.With(r.logger, "method", "create_users")
 
-	userReq := userpb.CreateUserRequest{
-		Email:    user.Email,
-		Password: user.Password,
-		Age:      uint32(user.Age),
-	}
-
-	userRes, err := r.userClient.CreateUser(ctx, &userReq)
-	if err != nil {
-		level.Error(logger).Log("err_user", err)
-		return -1, err
-	}
-
-	detailsReq := detailspb.SetUserDetailsRequest{
-		UserId:       uint32(userRes.GetId()),
-		Country:      user.Details.Country,
-		City:         user.Details.City,
-		MobileNumber: user.Details.MobileNumber,
-		Married:      user.Details.Married,
-		Height:       user.Details.Height,
-		Weight:       user.Details.Weight,
-	}
+	var success bool
+	s := saga.New(ctx, createUserSaga, 0)
 
-	detailsRes, err := r.detailsClient.SetUserDetails(ctx, &detailsReq)
-	if err != nil {
-		level.Error(logger).Log("err_details", err)
+	if err := r.sagas.Run(ctx, s, r.createUserSteps(user, &success)); err != nil {
+		level.Error(logger).Log("err_saga", err)
 		return -1, err
 	}
 
-	if detailsRes.GetSuccess() {
-		return int(userRes.GetId()), nil
+	if success {
+		return s.UserID, nil
 	}
 
 	return -1, nil
@@ -92,39 +110,20 @@ func (r *HTTPRepository) Authenticate(ctx context.Context, session entities.Sess
 	return userRes.GetSuccess(), err
 }
 
-// UpdateUser sends the original request to both gRPC servers
+// UpdateUser sends the original request to both gRPC servers, the previous details are restored if the update of the
+// user is rejected and the update is finished later if its outcome is unknown
 func (r *HTTPRepository) UpdateUser(ctx context.Context, user entities.UserUpdate) (bool, error) {
 	logger := log.With(r.logger, "method", "update_user")
 
-	userReq := userpb.UpdateUserRequest{
-		Id:       uint32(user.UserID),
-		Email:    user.Email,
-		Password: user.Password,
-		Age:      uint32(user.Age),
-	}
-	detailsReq := detailspb.SetUserDetailsRequest{
-		UserId:       uint32(user.UserID),
-		Country:      user.Details.Country,
-		City:         user.Details.City,
-		MobileNumber: user.Details.MobileNumber,
-		Married:      user.Details.Married,
-		Height:       user.Details.Height,
-		Weight:       user.Details.Weight,
-	}
+	var success bool
+	s := saga.New(ctx, updateUserSaga, user.UserID)
 
-	userRes, err := r.userClient.UpdateUser(ctx, &userReq)
-	if err != nil {
-		level.Error(logger).Log("err_user", err)
-		return false, err
-	}
-
-	detailsRes, err := r.detailsClient.SetUserDetails(ctx, &detailsReq)
-	if err != nil {
-		level.Error(logger).Log("err_details", err)
+	if err := r.sagas.Run(ctx, s, r.updateUserSteps(user, &success, false)); err != nil {
+		level.Error(logger).Log("err_saga", err)
 		return false, err
 	}
 
-	return detailsRes.GetSuccess() && userRes.GetSuccess(), nil
+	return success, nil
 }
 
 // GetUser fetchs the information of a user from both gRPC servers
@@ -138,57 +137,605 @@ func (r *HTTPRepository) GetUser(ctx context.Context, id int) (entities.User, er
 		UserId: uint32(id),
 	}
 
+	// both servers are called at once under the same deadline, the user is still returned when its details fail
+	ctx, cancel := context.WithTimeout(ctx, r.fanOutTimeout)
+	defer cancel()
+
+	type detailsResult struct {
+		res *detailspb.GetUserDetailsResponse
+		err error
+	}
+	detailsCh := make(chan detailsResult, 1)
+	go func() {
+		res, err := r.detailsClient.GetUserDetails(ctx, &detailsReq)
+		detailsCh <- detailsResult{res: res, err: err}
+	}()
+
 	userRes, err := r.userClient.GetUser(ctx, &userReq)
 	if err != nil {
 		level.Error(logger).Log("err_user", err)
 		return entities.User{}, err
 	}
 
-	detailsRes, err := r.detailsClient.GetUserDetails(ctx, &detailsReq)
+	res := entities.User{
+		Email:       userRes.GetEmail(),
+		Password:    userRes.GetPassword(),
+		DateOfBirth: userRes.GetDateOfBirth(),
+		Age:         int(userRes.GetAge()),
+	}
+
+	details := <-detailsCh
+	switch {
+	case details.err == nil:
+		res.Details = detailsFromProto(details.res)
+	case status.Code(details.err) == codes.NotFound:
+		level.Warn(logger).Log("err_details", details.err)
+		res.DetailsStatus = entities.DetailsMissing
+	default:
+		level.Warn(logger).Log("err_details", details.err)
+		res.DetailsStatus = entities.DetailsUnavailable
+	}
+
+	return res, nil
+}
+
+// BatchGetUsers fetchs the users with the given ids with one call to each gRPC server, like GetUser the
+// users are still returned when their details fail
+func (r *HTTPRepository) BatchGetUsers(ctx context.Context, ids []int) ([]entities.UserResult, error) {
+	logger := log.With(r.logger, "method", "batch_get_users")
+
+	pbIDs := make([]uint32, len(ids))
+	for i, id := range ids {
+		pbIDs[i] = uint32(id)
+	}
+
+	userReq := userpb.BatchGetUsersRequest{
+		Ids: pbIDs,
+	}
+	detailsReq := detailspb.BatchGetUserDetailsRequest{
+		UserIds: pbIDs,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.fanOutTimeout)
+	defer cancel()
+
+	type detailsResult struct {
+		res *detailspb.BatchGetUserDetailsResponse
+		err error
+	}
+	detailsCh := make(chan detailsResult, 1)
+	go func() {
+		res, err := r.detailsClient.BatchGetUserDetails(ctx, &detailsReq)
+		detailsCh <- detailsResult{res: res, err: err}
+	}()
+
+	userRes, err := r.userClient.BatchGetUsers(ctx, &userReq)
 	if err != nil {
-		level.Error(logger).Log("err_details", err)
-		return entities.User{}, err
+		level.Error(logger).Log("err_user", err)
+		return nil, err
 	}
 
-	res := entities.User{
-		Email:    userRes.GetEmail(),
-		Password: userRes.GetPassword(),
-		Age:      int(userRes.GetAge()),
-		Details: entities.Details{
-			Country:      detailsRes.GetCountry(),
-			City:         detailsRes.GetCity(),
-			MobileNumber: detailsRes.GetMobileNumber(),
-			Married:      detailsRes.GetMarried(),
-			Height:       detailsRes.GetHeight(),
-			Weight:       detailsRes.GetWeight(),
-		},
+	details := <-detailsCh
+	found := make(map[uint32]*detailspb.GetUserDetailsResponse)
+	if details.err != nil {
+		level.Warn(logger).Log("err_details", details.err)
+	}
+	for _, d := range details.res.GetResults() {
+		if d.GetFound() {
+			found[d.GetUserId()] = d.GetDetails()
+		}
+	}
+
+	res := make([]entities.UserResult, len(userRes.GetResults()))
+	for i, u := range userRes.GetResults() {
+		res[i] = entities.UserResult{ID: int(u.GetId()), Found: u.GetFound()}
+		if !u.GetFound() {
+			continue
+		}
+
+		res[i].User = entities.User{
+			ID:          int(u.GetId()),
+			Email:       u.GetUser().GetEmail(),
+			DateOfBirth: u.GetUser().GetDateOfBirth(),
+			Age:         int(u.GetUser().GetAge()),
+		}
+
+		d, ok := found[u.GetId()]
+		switch {
+		case details.err != nil:
+			res[i].User.DetailsStatus = entities.DetailsUnavailable
+		case !ok:
+			res[i].User.DetailsStatus = entities.DetailsMissing
+		default:
+			res[i].User.Details = detailsFromProto(d)
+		}
 	}
 
 	return res, nil
 }
 
-// DeleteUser does a soft delete in both databases inside each gRPC server
+// DeleteUser does a soft delete in both databases inside each gRPC server,
+// once the user is deleted the deletion of its details is retried until it succeeds
 func (r *HTTPRepository) DeleteUser(ctx context.Context, id int) (bool, error) {
 	logger := log.With(r.logger, "method", "delete_user")
 
-	userReq := userpb.DeleteUserRequest{
-		Id: uint32(id),
+	var success bool
+	s := saga.New(ctx, deleteUserSaga, id)
+
+	if err := r.sagas.Run(ctx, s, r.deleteUserSteps(&success, false)); err != nil {
+		level.Error(logger).Log("err_saga", err)
+		return false, err
 	}
-	detailsReq := detailspb.DeleteUserDetailsRequest{
-		UserId: uint32(id),
+
+	return success, nil
+}
+
+// GetUserDetails fetchs the details of the user from the details gRPC server
+func (r *HTTPRepository) GetUserDetails(ctx context.Context, userID int) (entities.Details, error) {
+	logger := log.With(r.logger, "method", "get_user_details")
+
+	detailsReq := detailspb.GetUserDetailsRequest{
+		UserId: uint32(userID),
+	}
+
+	detailsRes, err := r.detailsClient.GetUserDetails(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return entities.Details{}, err
+	}
+
+	return detailsFromProto(detailsRes), nil
+}
+
+// SetUserDetails replaces the details of the user within the details gRPC server, they are created when missing,
+// unless the revision is entities.AnyRevision they are only replaced while they are at the revision
+func (r *HTTPRepository) SetUserDetails(ctx context.Context, userID int, details entities.Details, revision int64) (bool, error) {
+	logger := log.With(r.logger, "method", "set_user_details")
+
+	detailsReq := setDetailsRequest(userID, details)
+	if revision != entities.AnyRevision {
+		detailsReq.Conditional, detailsReq.Revision = true, revision
 	}
 
-	userRes, err := r.userClient.DeleteUser(ctx, &userReq)
+	detailsRes, err := r.detailsClient.SetUserDetails(ctx, detailsReq)
 	if err != nil {
-		level.Error(logger).Log("err_user", err)
+		level.Error(logger).Log("err_details", err)
 		return false, err
 	}
 
+	return detailsRes.GetSuccess(), nil
+}
+
+// DeleteUserDetails removes the details of the user from the details gRPC server, the user is kept
+func (r *HTTPRepository) DeleteUserDetails(ctx context.Context, userID int) (bool, error) {
+	logger := log.With(r.logger, "method", "delete_user_details")
+
+	detailsReq := detailspb.DeleteUserDetailsRequest{
+		UserId: uint32(userID),
+	}
+
 	detailsRes, err := r.detailsClient.DeleteUserDetails(ctx, &detailsReq)
 	if err != nil {
 		level.Error(logger).Log("err_details", err)
 		return false, err
 	}
 
-	return detailsRes.GetSuccess() && userRes.GetSuccess(), nil
+	return detailsRes.GetSuccess(), nil
+}
+
+// AddAddress sends a new address of the user to the details gRPC server
+func (r *HTTPRepository) AddAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	logger := log.With(r.logger, "method", "add_address")
+
+	detailsReq := detailspb.AddAddressRequest{
+		UserId:  uint32(userID),
+		Address: addressToProto(address),
+	}
+
+	detailsRes, err := r.detailsClient.AddAddress(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return entities.Address{}, err
+	}
+
+	return addressFromProto(detailsRes.GetAddress()), nil
+}
+
+// ListAddresses fetchs every address of the user from the details gRPC server
+func (r *HTTPRepository) ListAddresses(ctx context.Context, userID int) ([]entities.Address, error) {
+	logger := log.With(r.logger, "method", "list_addresses")
+
+	detailsReq := detailspb.ListAddressesRequest{
+		UserId: uint32(userID),
+	}
+
+	detailsRes, err := r.detailsClient.ListAddresses(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return nil, err
+	}
+
+	res := make([]entities.Address, len(detailsRes.GetAddresses()))
+	for i, a := range detailsRes.GetAddresses() {
+		res[i] = addressFromProto(a)
+	}
+
+	return res, nil
+}
+
+// GetAddress fetchs a specific address of the user from the details gRPC server
+func (r *HTTPRepository) GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error) {
+	logger := log.With(r.logger, "method", "get_address")
+
+	detailsReq := detailspb.GetAddressRequest{
+		UserId:    uint32(userID),
+		AddressId: addressID,
+	}
+
+	detailsRes, err := r.detailsClient.GetAddress(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return entities.Address{}, err
+	}
+
+	return addressFromProto(detailsRes.GetAddress()), nil
+}
+
+// UpdateAddress sends the new data of an address to the details gRPC server
+func (r *HTTPRepository) UpdateAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	logger := log.With(r.logger, "method", "update_address")
+
+	detailsReq := detailspb.UpdateAddressRequest{
+		UserId:  uint32(userID),
+		Address: addressToProto(address),
+	}
+
+	detailsRes, err := r.detailsClient.UpdateAddress(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return entities.Address{}, err
+	}
+
+	return addressFromProto(detailsRes.GetAddress()), nil
+}
+
+// DeleteAddress removes an address of the user within the details gRPC server
+func (r *HTTPRepository) DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error) {
+	logger := log.With(r.logger, "method", "delete_address")
+
+	detailsReq := detailspb.DeleteAddressRequest{
+		UserId:    uint32(userID),
+		AddressId: addressID,
+	}
+
+	detailsRes, err := r.detailsClient.DeleteAddress(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return false, err
+	}
+
+	return detailsRes.GetSuccess(), nil
+}
+
+// SendPhoneVerification asks the details gRPC server to send a one-time code to the mobile number of the user
+func (r *HTTPRepository) SendPhoneVerification(ctx context.Context, userID int) (bool, error) {
+	logger := log.With(r.logger, "method", "send_phone_verification")
+
+	detailsReq := detailspb.SendPhoneVerificationRequest{
+		UserId: uint32(userID),
+	}
+
+	detailsRes, err := r.detailsClient.SendPhoneVerification(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return false, err
+	}
+
+	return detailsRes.GetSuccess(), nil
+}
+
+// VerifyPhone sends the one-time code of the user to the details gRPC server
+func (r *HTTPRepository) VerifyPhone(ctx context.Context, userID int, code string) (bool, error) {
+	logger := log.With(r.logger, "method", "verify_phone")
+
+	detailsReq := detailspb.VerifyPhoneRequest{
+		UserId: uint32(userID),
+		Code:   code,
+	}
+
+	detailsRes, err := r.detailsClient.VerifyPhone(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return false, err
+	}
+
+	return detailsRes.GetSuccess(), nil
+}
+
+// SetAvatar sends the reference of the stored avatar to the details gRPC server, an empty reference removes it
+func (r *HTTPRepository) SetAvatar(ctx context.Context, userID int, ref string) (bool, error) {
+	logger := log.With(r.logger, "method", "set_avatar")
+
+	detailsReq := detailspb.SetAvatarRequest{
+		UserId: uint32(userID),
+		Avatar: ref,
+	}
+
+	detailsRes, err := r.detailsClient.SetAvatar(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return false, err
+	}
+
+	return detailsRes.GetSuccess(), nil
+}
+
+// GetAvatar fetchs the reference of the avatar of the user from the details gRPC server
+func (r *HTTPRepository) GetAvatar(ctx context.Context, userID int) (string, error) {
+	logger := log.With(r.logger, "method", "get_avatar")
+
+	detailsReq := detailspb.GetUserDetailsRequest{
+		UserId: uint32(userID),
+	}
+
+	detailsRes, err := r.detailsClient.GetUserDetails(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return "", err
+	}
+
+	return detailsRes.GetAvatar(), nil
+}
+
+// ListUsers fetchs one page of users from the user gRPC server along with their details, the details are
+// fetched in batches of up to maxDetailsBatch users and the users without details get empty details
+func (r *HTTPRepository) ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, int, error) {
+	logger := log.With(r.logger, "method", "list_users")
+
+	userReq := userpb.ListUsersRequest{
+		AfterId:  uint32(afterID),
+		PageSize: uint32(limit),
+	}
+
+	userRes, err := r.userClient.ListUsers(ctx, &userReq)
+	if err != nil {
+		level.Error(logger).Log("err_user", err)
+		return nil, 0, err
+	}
+
+	users := userRes.GetUsers()
+	found := make(map[uint32]*detailspb.GetUserDetailsResponse, len(users))
+	for start := 0; start < len(users); start += maxDetailsBatch {
+		end := start + maxDetailsBatch
+		if end > len(users) {
+			end = len(users)
+		}
+
+		ids := make([]uint32, 0, end-start)
+		for _, u := range users[start:end] {
+			ids = append(ids, u.GetId())
+		}
+
+		detailsRes, err := r.detailsClient.BatchGetUserDetails(ctx, &detailspb.BatchGetUserDetailsRequest{UserIds: ids})
+		if err != nil {
+			level.Error(logger).Log("err_details", err)
+			return nil, 0, err
+		}
+
+		for _, d := range detailsRes.GetResults() {
+			if d.GetFound() {
+				found[d.GetUserId()] = d.GetDetails()
+			}
+		}
+	}
+
+	res := make([]entities.User, len(users))
+	for i, u := range users {
+		res[i] = entities.User{
+			ID:          int(u.GetId()),
+			Email:       u.GetEmail(),
+			DateOfBirth: u.GetDateOfBirth(),
+			Age:         int(u.GetAge()),
+			Details:     detailsFromProto(found[u.GetId()]),
+		}
+	}
+
+	return res, int(userRes.GetNextAfterId()), nil
+}
+
+// TakenEmails returns the given emails which already belong to a user, the user gRPC server checks up to 100
+// emails within one call
+func (r *HTTPRepository) TakenEmails(ctx context.Context, emails []string) ([]string, error) {
+	logger := log.With(r.logger, "method", "taken_emails")
+
+	userRes, err := r.userClient.CheckEmails(ctx, &userpb.CheckEmailsRequest{Emails: emails})
+	if err != nil {
+		level.Error(logger).Log("err_user", err)
+		return nil, err
+	}
+
+	return userRes.GetTaken(), nil
+}
+
+// ListUserChanges fetchs one page of the changes of the users from the user gRPC server, the cursors belong to that server
+func (r *HTTPRepository) ListUserChanges(ctx context.Context, cursor string, limit int) ([]entities.Change, string, error) {
+	logger := log.With(r.logger, "method", "list_user_changes")
+
+	userReq := userpb.ListChangesRequest{
+		Cursor:   cursor,
+		PageSize: uint32(limit),
+	}
+
+	userRes, err := r.userClient.ListChanges(ctx, &userReq)
+	if err != nil {
+		level.Error(logger).Log("err_user", err)
+		return nil, "", err
+	}
+
+	res := make([]entities.Change, len(userRes.GetChanges()))
+	for i, c := range userRes.GetChanges() {
+		if res[i], err = newChange(entities.UserSource, c.GetId(), c.GetType(), c.GetUserId(), c.GetOccurredAt(), c.GetData(), c.GetCursor()); err != nil {
+			level.Error(logger).Log("err_user", err)
+			return nil, "", status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return res, userRes.GetNextCursor(), nil
+}
+
+// ListDetailsChanges fetchs one page of the changes of the details from the details gRPC server, the cursors belong to that server
+func (r *HTTPRepository) ListDetailsChanges(ctx context.Context, cursor string, limit int) ([]entities.Change, string, error) {
+	logger := log.With(r.logger, "method", "list_details_changes")
+
+	detailsReq := detailspb.ListDetailsChangesRequest{
+		Cursor:   cursor,
+		PageSize: uint32(limit),
+	}
+
+	detailsRes, err := r.detailsClient.ListChanges(ctx, &detailsReq)
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return nil, "", err
+	}
+
+	res := make([]entities.Change, len(detailsRes.GetChanges()))
+	for i, c := range detailsRes.GetChanges() {
+		if res[i], err = newChange(entities.DetailsSource, c.GetId(), c.GetType(), c.GetUserId(), c.GetOccurredAt(), c.GetData(), c.GetCursor()); err != nil {
+			level.Error(logger).Log("err_details", err)
+			return nil, "", status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return res, detailsRes.GetNextCursor(), nil
+}
+
+// WatchUserChanges passes to send the changes of the user streamed by the user gRPC server until the stream or the
+// context ends, the cursors belong to that server
+func (r *HTTPRepository) WatchUserChanges(ctx context.Context, userID int, cursor string, send func(entities.Change) error) error {
+	logger := log.With(r.logger, "method", "watch_user_changes")
+
+	stream, err := r.userClient.WatchUser(ctx, &userpb.WatchUserRequest{Id: uint32(userID), Cursor: cursor})
+	if err != nil {
+		level.Error(logger).Log("err_user", err)
+		return err
+	}
+
+	for {
+		c, err := stream.Recv()
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			level.Error(logger).Log("err_user", err)
+			return err
+		}
+
+		change, err := newChange(entities.UserSource, c.GetId(), c.GetType(), c.GetUserId(), c.GetOccurredAt(), c.GetData(), c.GetCursor())
+		if err != nil {
+			level.Error(logger).Log("err_user", err)
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err := send(change); err != nil {
+			return err
+		}
+	}
+}
+
+// WatchDetailsChanges passes to send the changes of the details of the user streamed by the details gRPC server until
+// the stream or the context ends, the cursors belong to that server
+func (r *HTTPRepository) WatchDetailsChanges(ctx context.Context, userID int, cursor string, send func(entities.Change) error) error {
+	logger := log.With(r.logger, "method", "watch_details_changes")
+
+	stream, err := r.detailsClient.WatchUserDetails(ctx, &detailspb.WatchUserDetailsRequest{UserId: uint32(userID), Cursor: cursor})
+	if err != nil {
+		level.Error(logger).Log("err_details", err)
+		return err
+	}
+
+	for {
+		c, err := stream.Recv()
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			level.Error(logger).Log("err_details", err)
+			return err
+		}
+
+		change, err := newChange(entities.DetailsSource, c.GetId(), c.GetType(), c.GetUserId(), c.GetOccurredAt(), c.GetData(), c.GetCursor())
+		if err != nil {
+			level.Error(logger).Log("err_details", err)
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err := send(change); err != nil {
+			return err
+		}
+	}
+}
+
+func newChange(source string, id string, changeType string, userID uint32, occurredAt string, data string, cursor string) (entities.Change, error) {
+	c := entities.Change{
+		ID:     id,
+		Source: source,
+		Type:   changeType,
+		UserID: int(userID),
+		Cursor: cursor,
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, occurredAt)
+	if err != nil {
+		return c, err
+	}
+	c.OccurredAt = t
+
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &c.Data); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
+
+func detailsFromProto(d *detailspb.GetUserDetailsResponse) entities.Details {
+	return entities.Details{
+		Country:        d.GetCountry(),
+		City:           d.GetCity(),
+		MobileNumber:   d.GetMobileNumber(),
+		MobileVerified: d.GetMobileVerified(),
+		Married:        d.GetMarried(),
+		Height:         d.GetHeight(),
+		Weight:         d.GetWeight(),
+		Attributes:     attributesFromProto(d.GetAttributes()),
+		Avatar:         d.GetAvatar(),
+		Revision:       d.GetRevision(),
+	}
+}
+
+func addressToProto(a entities.Address) *detailspb.Address {
+	return &detailspb.Address{
+		Id:          a.ID,
+		Lines:       a.Lines,
+		Locality:    a.Locality,
+		Region:      a.Region,
+		PostalCode:  a.PostalCode,
+		CountryCode: a.CountryCode,
+		Label:       a.Label,
+		Primary:     a.Primary,
+	}
+}
+
+func addressFromProto(a *detailspb.Address) entities.Address {
+	return entities.Address{
+		ID:          a.GetId(),
+		Lines:       a.GetLines(),
+		Locality:    a.GetLocality(),
+		Region:      a.GetRegion(),
+		PostalCode:  a.GetPostalCode(),
+		CountryCode: a.GetCountryCode(),
+		Label:       a.GetLabel(),
+		Primary:     a.GetPrimary(),
+	}
 }
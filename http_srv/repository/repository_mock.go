@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/saga"
 	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
 	"github.com/mauricioww/user_microsrv/user_srv/userpb"
 	"github.com/stretchr/testify/mock"
@@ -35,7 +36,7 @@ func InitRepoMock(mock1 *GrpcUserMock, mock2 *GrpcDetailsMock) (*grpc.ClientConn
 	conn1, _ := grpc.DialContext(context.Background(), "", grpc.WithInsecure(), grpc.WithContextDialer(Dialer1(mock1)))
 	conn2, _ := grpc.DialContext(context.Background(), "", grpc.WithInsecure(), grpc.WithContextDialer(Dialer2(mock2)))
 
-	r := NewHTTPRepository(conn1, conn2, logger)
+	r := NewHTTPRepository(conn1, conn2, saga.NewMemoryLog(), logger)
 	return conn1, conn2, r
 }
 
@@ -108,6 +109,41 @@ func (m *GrpcUserMock) DeleteUser(ctx context.Context, req *userpb.DeleteUserReq
 	return args.Get(0).(*userpb.DeleteUserResponse), args.Error(1)
 }
 
+// ListUsers is a mock of the real method
+func (m *GrpcUserMock) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*userpb.ListUsersResponse), args.Error(1)
+}
+
+// PurgeUser is a mock of the real method
+func (m *GrpcUserMock) PurgeUser(ctx context.Context, req *userpb.PurgeUserRequest) (*userpb.PurgeUserResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*userpb.PurgeUserResponse), args.Error(1)
+}
+
+// ListChanges is a mock of the real method
+func (m *GrpcUserMock) ListChanges(ctx context.Context, req *userpb.ListChangesRequest) (*userpb.ListChangesResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*userpb.ListChangesResponse), args.Error(1)
+}
+
+// CheckEmails is a mock of the real method
+func (m *GrpcUserMock) CheckEmails(ctx context.Context, req *userpb.CheckEmailsRequest) (*userpb.CheckEmailsResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*userpb.CheckEmailsResponse), args.Error(1)
+}
+
+// BatchGetUsers is a mock of the real method
+func (m *GrpcUserMock) BatchGetUsers(ctx context.Context, req *userpb.BatchGetUsersRequest) (*userpb.BatchGetUsersResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*userpb.BatchGetUsersResponse), args.Error(1)
+}
+
 // SetUserDetails is a mock of the real method
 func (m *GrpcDetailsMock) SetUserDetails(ctx context.Context, req *detailspb.SetUserDetailsRequest) (*detailspb.SetUserDetailsResponse, error) {
 	args := m.Called(ctx, req)
@@ -129,6 +165,76 @@ func (m *GrpcDetailsMock) DeleteUserDetails(ctx context.Context, req *detailspb.
 	return args.Get(0).(*detailspb.DeleteUserDetailsResponse), args.Error(1)
 }
 
+// AddAddress is a mock of the real method
+func (m *GrpcDetailsMock) AddAddress(ctx context.Context, req *detailspb.AddAddressRequest) (*detailspb.AddAddressResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.AddAddressResponse), args.Error(1)
+}
+
+// ListAddresses is a mock of the real method
+func (m *GrpcDetailsMock) ListAddresses(ctx context.Context, req *detailspb.ListAddressesRequest) (*detailspb.ListAddressesResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.ListAddressesResponse), args.Error(1)
+}
+
+// GetAddress is a mock of the real method
+func (m *GrpcDetailsMock) GetAddress(ctx context.Context, req *detailspb.GetAddressRequest) (*detailspb.GetAddressResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.GetAddressResponse), args.Error(1)
+}
+
+// UpdateAddress is a mock of the real method
+func (m *GrpcDetailsMock) UpdateAddress(ctx context.Context, req *detailspb.UpdateAddressRequest) (*detailspb.UpdateAddressResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.UpdateAddressResponse), args.Error(1)
+}
+
+// DeleteAddress is a mock of the real method
+func (m *GrpcDetailsMock) DeleteAddress(ctx context.Context, req *detailspb.DeleteAddressRequest) (*detailspb.DeleteAddressResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.DeleteAddressResponse), args.Error(1)
+}
+
+// SendPhoneVerification is a mock of the real method
+func (m *GrpcDetailsMock) SendPhoneVerification(ctx context.Context, req *detailspb.SendPhoneVerificationRequest) (*detailspb.SendPhoneVerificationResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.SendPhoneVerificationResponse), args.Error(1)
+}
+
+// VerifyPhone is a mock of the real method
+func (m *GrpcDetailsMock) VerifyPhone(ctx context.Context, req *detailspb.VerifyPhoneRequest) (*detailspb.VerifyPhoneResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.VerifyPhoneResponse), args.Error(1)
+}
+
+// SetAvatar is a mock of the real method
+func (m *GrpcDetailsMock) SetAvatar(ctx context.Context, req *detailspb.SetAvatarRequest) (*detailspb.SetAvatarResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.SetAvatarResponse), args.Error(1)
+}
+
+// ListChanges is a mock of the real method
+func (m *GrpcDetailsMock) ListChanges(ctx context.Context, req *detailspb.ListDetailsChangesRequest) (*detailspb.ListDetailsChangesResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.ListDetailsChangesResponse), args.Error(1)
+}
+
+// BatchGetUserDetails is a mock of the real method
+func (m *GrpcDetailsMock) BatchGetUserDetails(ctx context.Context, req *detailspb.BatchGetUserDetailsRequest) (*detailspb.BatchGetUserDetailsResponse, error) {
+	args := m.Called(ctx, req)
+
+	return args.Get(0).(*detailspb.BatchGetUserDetailsResponse), args.Error(1)
+}
+
 // GenerateDetails returns mock data to use in the tests
 func GenerateDetails() entities.Details {
 	return entities.Details{
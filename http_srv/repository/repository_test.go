@@ -28,24 +28,47 @@ func TestCreateUser(t *testing.T) {
 		repositoryRes int
 		repositoryErr error
 		userErr       error
+		attributes    map[string]*detailspb.Value
 	}{
 		{
 			testName: "user created successfully",
 			user: entities.User{
-				Email:    "user@email.com",
-				Password: "qwerty",
-				Age:      23,
-				Details:  repository.GenerateDetails(),
+				Email:       "user@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
+				Details:     repository.GenerateDetails(),
 			},
 			repositoryRes: 1,
 			userErr:       nil,
 		},
+		{
+			testName: "user with custom attributes created successfully",
+			user: entities.User{
+				Email:       "attributes@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
+				Details: entities.Details{
+					Attributes: map[string]interface{}{
+						"nickname":  "mau",
+						"legal_age": float64(21),
+						"score":     9.5,
+					},
+				},
+			},
+			repositoryRes: 2,
+			userErr:       nil,
+			attributes: map[string]*detailspb.Value{
+				"nickname":  {Kind: &detailspb.Value_StringValue{StringValue: "mau"}},
+				"legal_age": {Kind: &detailspb.Value_IntValue{IntValue: 21}},
+				"score":     {Kind: &detailspb.Value_FloatValue{FloatValue: 9.5}},
+			},
+		},
 		{
 			testName: "no password error",
 			user: entities.User{
-				Email:   "user@email.com",
-				Age:     23,
-				Details: repository.GenerateDetails(),
+				Email:       "user@email.com",
+				DateOfBirth: "1998-05-10",
+				Details:     repository.GenerateDetails(),
 			},
 			repositoryRes: -1,
 			userErr:       status.Error(codes.FailedPrecondition, "Missing field 'password'"),
@@ -53,9 +76,9 @@ func TestCreateUser(t *testing.T) {
 		{
 			testName: "no email error",
 			user: entities.User{
-				Password: "qwerty",
-				Age:      23,
-				Details:  repository.GenerateDetails(),
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
+				Details:     repository.GenerateDetails(),
 			},
 			repositoryRes: -1,
 			userErr:       status.Error(codes.FailedPrecondition, "Missing field 'email'"),
@@ -70,9 +93,9 @@ func TestCreateUser(t *testing.T) {
 			var userRes *userpb.CreateUserResponse
 			var detailsRes *detailspb.SetUserDetailsResponse
 			userReq := &userpb.CreateUserRequest{
-				Email:    tc.user.Email,
-				Password: tc.user.Password,
-				Age:      uint32(tc.user.Age),
+				Email:       tc.user.Email,
+				Password:    tc.user.Password,
+				DateOfBirth: tc.user.DateOfBirth,
 			}
 			detailsReq := &detailspb.SetUserDetailsRequest{
 				UserId:       uint32(tc.repositoryRes),
@@ -82,6 +105,7 @@ func TestCreateUser(t *testing.T) {
 				Married:      tc.user.Married,
 				Height:       tc.user.Height,
 				Weight:       tc.user.Weight,
+				Attributes:   tc.attributes,
 			}
 
 			if tc.userErr == nil {
@@ -102,6 +126,106 @@ func TestCreateUser(t *testing.T) {
 	}
 }
 
+func TestCreateUserCompensation(t *testing.T) {
+	userMock := new(repository.GrpcUserMock)
+	detailsMock := new(repository.GrpcDetailsMock)
+	conn1, conn2, httpRepository := repository.InitRepoMock(userMock, detailsMock)
+
+	defer conn1.Close()
+	defer conn2.Close()
+
+	testCases := []struct {
+		testName   string
+		user       entities.User
+		userID     int
+		detailsErr error
+		purgeErr   error
+		removed    bool
+	}{
+		{
+			testName: "invalid details purge the user",
+			user: entities.User{
+				Email:       "invalid@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
+				Details:     entities.Details{Country: "Mexico", MobileNumber: "123"},
+			},
+			userID:     10,
+			detailsErr: status.Error(codes.FailedPrecondition, "Invalid field 'mobile_number': expected a valid phone number"),
+		},
+		{
+			testName: "unavailable details server purge the user and remove its details",
+			user: entities.User{
+				Email:       "unavailable@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
+				Details:     repository.GenerateDetails(),
+			},
+			userID:     11,
+			detailsErr: status.Error(codes.Unavailable, "connection refused"),
+			removed:    true,
+		},
+		{
+			testName: "user already purged",
+			user: entities.User{
+				Email:       "purged@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
+				Details:     repository.GenerateDetails(),
+			},
+			userID:     12,
+			detailsErr: status.Error(codes.Internal, "Internal server error"),
+			purgeErr:   status.Error(codes.NotFound, "User not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			//  prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			userReq := &userpb.CreateUserRequest{
+				Email:       tc.user.Email,
+				Password:    tc.user.Password,
+				DateOfBirth: tc.user.DateOfBirth,
+			}
+			detailsReq := &detailspb.SetUserDetailsRequest{
+				UserId:       uint32(tc.userID),
+				Country:      tc.user.Country,
+				City:         tc.user.City,
+				MobileNumber: tc.user.MobileNumber,
+				Married:      tc.user.Married,
+				Height:       tc.user.Height,
+				Weight:       tc.user.Weight,
+			}
+			purgeReq := &userpb.PurgeUserRequest{Id: uint32(tc.userID)}
+			removeReq := &detailspb.DeleteUserDetailsRequest{UserId: uint32(tc.userID)}
+			var purgeRes *userpb.PurgeUserResponse
+			if tc.purgeErr == nil {
+				purgeRes = &userpb.PurgeUserResponse{Success: true}
+			}
+
+			userMock.On("CreateUser", mock.Anything, userReq).Return(&userpb.CreateUserResponse{Id: int32(tc.userID)}, nil)
+			detailsMock.On("SetUserDetails", mock.Anything, detailsReq).Return((*detailspb.SetUserDetailsResponse)(nil), tc.detailsErr)
+			detailsMock.On("DeleteUserDetails", mock.Anything, removeReq).Return((*detailspb.DeleteUserDetailsResponse)(nil), status.Error(codes.NotFound, "User not found"))
+			userMock.On("PurgeUser", mock.Anything, purgeReq).Return(purgeRes, tc.purgeErr)
+
+			// act
+			res, err := httpRepository.CreateUser(ctx, tc.user)
+
+			// assert
+			assert.Equal(-1, res)
+			assert.True(repository.TestErrors(err, tc.detailsErr))
+			userMock.AssertCalled(t, "PurgeUser", mock.Anything, purgeReq)
+			if tc.removed {
+				detailsMock.AssertCalled(t, "DeleteUserDetails", mock.Anything, removeReq)
+			} else {
+				detailsMock.AssertNotCalled(t, "DeleteUserDetails", mock.Anything, removeReq)
+			}
+		})
+	}
+}
+
 func TestAuthenticate(t *testing.T) {
 	userMock := new(repository.GrpcUserMock)
 	detailsMock := new(repository.GrpcDetailsMock)
@@ -190,62 +314,79 @@ func TestUpdateUser(t *testing.T) {
 	defer conn1.Close()
 	defer conn2.Close()
 
+	previous := entities.Details{
+		Country:      "Mexico",
+		City:         "Guadalajara",
+		MobileNumber: "55667788",
+		Married:      true,
+		Height:       1.70,
+		Weight:       70.0,
+	}
+
 	testCases := []struct {
-		testName string
-		data     entities.UserUpdate
-		res      bool
-		err      error
+		testName    string
+		data        entities.UserUpdate
+		snapshot    *entities.Details
+		res         bool
+		err         error
+		compensated bool
 	}{
 		{
 			testName: "Update email successfully",
 			data: entities.UserUpdate{
 				UserID: 0,
 				User: entities.User{
-					Email:    "email@domian.com",
-					Password: "qwerty",
-					Age:      23,
-					Details:  repository.GenerateDetails(),
+					Email:       "email@domian.com",
+					Password:    "qwerty",
+					DateOfBirth: "1998-05-10",
+					Details:     repository.GenerateDetails(),
 				},
 			},
-			res: true,
-			err: nil,
+			snapshot: &previous,
+			res:      true,
+			err:      nil,
 		},
 		{
-			testName: "no email error",
+			testName: "no email error restores the previous details",
 			data: entities.UserUpdate{
 				UserID: 1,
 				User: entities.User{
-					Password: "qwerty",
-					Age:      23,
-					Details:  repository.GenerateDetails(),
+					Password:    "qwerty",
+					DateOfBirth: "1998-05-10",
+					Details:     repository.GenerateDetails(),
 				},
 			},
-			err: status.Error(codes.FailedPrecondition, "Missing field 'email'"),
+			snapshot:    &previous,
+			err:         status.Error(codes.FailedPrecondition, "Missing field 'email'"),
+			compensated: true,
 		},
 		{
-			testName: "no password error",
+			testName: "no password error restores the previous details",
 			data: entities.UserUpdate{
-				UserID: 0,
+				UserID: 2,
 				User: entities.User{
-					Email:   "email@domian.com",
-					Age:     23,
-					Details: repository.GenerateDetails(),
+					Email:       "email@domian.com",
+					DateOfBirth: "1998-05-10",
+					Details:     repository.GenerateDetails(),
 				},
 			},
-			err: status.Error(codes.FailedPrecondition, "Missing field 'password'"),
+			snapshot:    &previous,
+			err:         status.Error(codes.FailedPrecondition, "Missing field 'password'"),
+			compensated: true,
 		},
 		{
-			testName: "user not found error",
+			testName: "user not found error removes the new details",
 			data: entities.UserUpdate{
-				UserID: 1,
+				UserID: 3,
 				User: entities.User{
-					Email:    "email@domian.com",
-					Password: "qwerty",
-					Age:      23,
-					Details:  repository.GenerateDetails(),
+					Email:       "email@domian.com",
+					Password:    "qwerty",
+					DateOfBirth: "1998-05-10",
+					Details:     repository.GenerateDetails(),
 				},
 			},
-			err: status.Error(codes.NotFound, "User not found"),
+			err:         status.Error(codes.NotFound, "User not found"),
+			compensated: true,
 		},
 	}
 
@@ -255,13 +396,13 @@ func TestUpdateUser(t *testing.T) {
 			ctx := context.Background()
 			assert := assert.New(t)
 			var userRes *userpb.UpdateUserResponse
-			var detailsRes *detailspb.SetUserDetailsResponse
 			userReq := &userpb.UpdateUserRequest{
-				Id:       uint32(tc.data.UserID),
-				Email:    tc.data.Email,
-				Password: tc.data.Password,
-				Age:      uint32(tc.data.Age),
+				Id:          uint32(tc.data.UserID),
+				Email:       tc.data.Email,
+				Password:    tc.data.Password,
+				DateOfBirth: tc.data.DateOfBirth,
 			}
+			snapshotReq := &detailspb.GetUserDetailsRequest{UserId: uint32(tc.data.UserID)}
 			detailsReq := &detailspb.SetUserDetailsRequest{
 				UserId:       uint32(tc.data.UserID),
 				Country:      tc.data.Country,
@@ -271,23 +412,97 @@ func TestUpdateUser(t *testing.T) {
 				Height:       tc.data.Height,
 				Weight:       tc.data.Weight,
 			}
+			restoreReq := &detailspb.SetUserDetailsRequest{
+				UserId:       uint32(tc.data.UserID),
+				Country:      previous.Country,
+				City:         previous.City,
+				MobileNumber: previous.MobileNumber,
+				Married:      previous.Married,
+				Height:       previous.Height,
+				Weight:       previous.Weight,
+			}
+			removeReq := &detailspb.DeleteUserDetailsRequest{UserId: uint32(tc.data.UserID)}
 			if tc.err == nil {
 				userRes = &userpb.UpdateUserResponse{Success: tc.res}
-				detailsRes = &detailspb.SetUserDetailsResponse{Success: tc.res}
+			}
+
+			if tc.snapshot != nil {
+				detailsMock.On("GetUserDetails", mock.Anything, snapshotReq).Return(&detailspb.GetUserDetailsResponse{
+					Country:      tc.snapshot.Country,
+					City:         tc.snapshot.City,
+					MobileNumber: tc.snapshot.MobileNumber,
+					Married:      tc.snapshot.Married,
+					Height:       tc.snapshot.Height,
+					Weight:       tc.snapshot.Weight,
+				}, nil)
+			} else {
+				detailsMock.On("GetUserDetails", mock.Anything, snapshotReq).Return((*detailspb.GetUserDetailsResponse)(nil), status.Error(codes.NotFound, "User not found"))
 			}
 
 			// act
 			userMock.On("UpdateUser", mock.Anything, userReq).Return(userRes, tc.err)
-			detailsMock.On("SetUserDetails", mock.Anything, detailsReq).Return(detailsRes, nil)
+			detailsMock.On("SetUserDetails", mock.Anything, detailsReq).Return(&detailspb.SetUserDetailsResponse{Success: true}, nil)
+			detailsMock.On("SetUserDetails", mock.Anything, restoreReq).Return(&detailspb.SetUserDetailsResponse{Success: true}, nil)
+			detailsMock.On("DeleteUserDetails", mock.Anything, removeReq).Return(&detailspb.DeleteUserDetailsResponse{Success: true}, nil)
 			res, err := httpRepository.UpdateUser(ctx, tc.data)
 
 			// assert
 			assert.Equal(res, tc.res)
 			assert.True(repository.TestErrors(err, tc.err))
+			detailsMock.AssertCalled(t, "SetUserDetails", mock.Anything, detailsReq)
+			if tc.compensated && tc.snapshot != nil {
+				detailsMock.AssertCalled(t, "SetUserDetails", mock.Anything, restoreReq)
+			} else if tc.compensated {
+				detailsMock.AssertCalled(t, "DeleteUserDetails", mock.Anything, removeReq)
+			} else {
+				detailsMock.AssertNotCalled(t, "SetUserDetails", mock.Anything, restoreReq)
+			}
 		})
 	}
 }
 
+func TestUpdateUserResumed(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	ctx := context.Background()
+	userMock := new(repository.GrpcUserMock)
+	detailsMock := new(repository.GrpcDetailsMock)
+	conn1, conn2, httpRepository := repository.InitRepoMock(userMock, detailsMock)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	data := entities.UserUpdate{
+		UserID: 4,
+		User: entities.User{
+			Email:       "email@domian.com",
+			Password:    "qwerty",
+			DateOfBirth: "1998-05-10",
+			Details:     entities.Details{Country: "Mexico"},
+		},
+	}
+	userReq := &userpb.UpdateUserRequest{Id: 4, Email: "email@domian.com", Password: "qwerty", DateOfBirth: "1998-05-10"}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	detailsMock.On("GetUserDetails", mock.Anything, &detailspb.GetUserDetailsRequest{UserId: 4}).
+		Return(&detailspb.GetUserDetailsResponse{Country: "Chile"}, nil)
+	detailsMock.On("SetUserDetails", mock.Anything, &detailspb.SetUserDetailsRequest{UserId: 4, Country: "Mexico"}).
+		Return(&detailspb.SetUserDetailsResponse{Success: true}, nil)
+	userMock.On("UpdateUser", mock.Anything, userReq).Return((*userpb.UpdateUserResponse)(nil), unavailable).Once()
+	userMock.On("UpdateUser", mock.Anything, userReq).Return(&userpb.UpdateUserResponse{Success: true}, nil)
+	userMock.On("GetUser", mock.Anything, &userpb.GetUserRequest{Id: 4}).
+		Return(&userpb.GetUserResponse{Email: "old@domian.com", Password: "qwerty", DateOfBirth: "1990-01-01"}, nil)
+
+	// act
+	_, err := httpRepository.UpdateUser(ctx, data)
+	resumeErr := httpRepository.(*repository.HTTPRepository).ResumeSagas(ctx)
+
+	// assert
+	assert.True(repository.TestErrors(err, unavailable))
+	assert.Nil(resumeErr)
+	userMock.AssertNumberOfCalls(t, "UpdateUser", 2)
+	detailsMock.AssertNotCalled(t, "SetUserDetails", mock.Anything, &detailspb.SetUserDetailsRequest{UserId: 4, Country: "Chile"})
+}
+
 func TestGetUser(t *testing.T) {
 	userMock := new(repository.GrpcUserMock)
 	detailsMock := new(repository.GrpcDetailsMock)
@@ -297,18 +512,20 @@ func TestGetUser(t *testing.T) {
 	defer conn2.Close()
 
 	testCases := []struct {
-		testName string
-		data     int
-		res      entities.User
-		err      error
+		testName   string
+		data       int
+		res        entities.User
+		err        error
+		detailsErr error
 	}{
 		{
 			testName: "user found",
 			data:     0,
 			res: entities.User{
-				Email:    "email@domain.com",
-				Password: "password",
-				Age:      10,
+				Email:       "email@domain.com",
+				Password:    "password",
+				DateOfBirth: "2011-03-15",
+				Age:         10,
 			},
 			err: nil,
 		},
@@ -318,6 +535,26 @@ func TestGetUser(t *testing.T) {
 			res:      entities.User{},
 			err:      status.Error(codes.NotFound, "User not found"),
 		},
+		{
+			testName: "details missing",
+			data:     2,
+			res: entities.User{
+				Email:         "email@domain.com",
+				Password:      "password",
+				DetailsStatus: entities.DetailsMissing,
+			},
+			detailsErr: status.Error(codes.NotFound, "User details not found"),
+		},
+		{
+			testName: "details unavailable",
+			data:     3,
+			res: entities.User{
+				Email:         "email@domain.com",
+				Password:      "password",
+				DetailsStatus: entities.DetailsUnavailable,
+			},
+			detailsErr: status.Error(codes.Unavailable, "connection refused"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -335,16 +572,23 @@ func TestGetUser(t *testing.T) {
 			}
 			if tc.err == nil {
 				userRes = &userpb.GetUserResponse{
-					Email:    tc.res.Email,
-					Password: tc.res.Password,
-					Age:      uint32(tc.res.Age),
+					Email:       tc.res.Email,
+					Password:    tc.res.Password,
+					DateOfBirth: tc.res.DateOfBirth,
+					Age:         uint32(tc.res.Age),
 				}
+			}
+			if tc.err == nil && tc.detailsErr == nil {
 				detailsRes = &detailspb.GetUserDetailsResponse{}
 			}
+			detailsErr := tc.detailsErr
+			if tc.err != nil {
+				detailsErr = tc.err
+			}
 
 			// act
 			userMock.On("GetUser", mock.Anything, userReq).Return(userRes, tc.err)
-			detailsMock.On("GetUserDetails", mock.Anything, detailsReq).Return(detailsRes, tc.err)
+			detailsMock.On("GetUserDetails", mock.Anything, detailsReq).Return(detailsRes, detailsErr)
 			res, err := httpRepository.GetUser(ctx, tc.data)
 
 			// assert
@@ -354,6 +598,75 @@ func TestGetUser(t *testing.T) {
 	}
 }
 
+func TestBatchGetUsers(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		data       []int
+		userRes    *userpb.BatchGetUsersResponse
+		userErr    error
+		detailsRes *detailspb.BatchGetUserDetailsResponse
+		detailsErr error
+		res        []entities.UserResult
+	}{
+		{
+			testName: "users with and without details",
+			data:     []int{1, 2, 3},
+			userRes: &userpb.BatchGetUsersResponse{Results: []*userpb.UserResult{
+				{Id: 1, Found: true, User: &userpb.User{Id: 1, Email: "first@domain.com"}},
+				{Id: 2, Found: true, User: &userpb.User{Id: 2, Email: "second@domain.com"}},
+				{Id: 3, Found: false},
+			}},
+			detailsRes: &detailspb.BatchGetUserDetailsResponse{Results: []*detailspb.UserDetailsResult{
+				{UserId: 1, Found: true, Details: &detailspb.GetUserDetailsResponse{Country: "Mexico"}},
+				{UserId: 2, Found: false},
+				{UserId: 3, Found: false},
+			}},
+			res: []entities.UserResult{
+				{ID: 1, Found: true, User: entities.User{ID: 1, Email: "first@domain.com", Details: entities.Details{Country: "Mexico"}}},
+				{ID: 2, Found: true, User: entities.User{ID: 2, Email: "second@domain.com", DetailsStatus: entities.DetailsMissing}},
+				{ID: 3, Found: false},
+			},
+		},
+		{
+			testName: "details unavailable",
+			data:     []int{1},
+			userRes: &userpb.BatchGetUsersResponse{Results: []*userpb.UserResult{
+				{Id: 1, Found: true, User: &userpb.User{Id: 1, Email: "first@domain.com"}},
+			}},
+			detailsErr: status.Error(codes.Unavailable, "connection refused"),
+			res: []entities.UserResult{
+				{ID: 1, Found: true, User: entities.User{ID: 1, Email: "first@domain.com", DetailsStatus: entities.DetailsUnavailable}},
+			},
+		},
+		{
+			testName: "user server error",
+			data:     []int{1},
+			userErr:  status.Error(codes.FailedPrecondition, "Missing or invalid tenant"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			userMock := new(repository.GrpcUserMock)
+			detailsMock := new(repository.GrpcDetailsMock)
+			conn1, conn2, httpRepository := repository.InitRepoMock(userMock, detailsMock)
+			defer conn1.Close()
+			defer conn2.Close()
+
+			// act
+			userMock.On("BatchGetUsers", mock.Anything, mock.Anything).Return(tc.userRes, tc.userErr)
+			detailsMock.On("BatchGetUserDetails", mock.Anything, mock.Anything).Return(tc.detailsRes, tc.detailsErr)
+			res, err := httpRepository.BatchGetUsers(context.Background(), tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.True(repository.TestErrors(err, tc.userErr))
+		})
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	userMock := new(repository.GrpcUserMock)
 	detailsMock := new(repository.GrpcDetailsMock)
@@ -363,10 +676,11 @@ func TestDeleteUser(t *testing.T) {
 	defer conn2.Close()
 
 	testCases := []struct {
-		testName string
-		data     int
-		res      bool
-		err      error
+		testName   string
+		data       int
+		res        bool
+		err        error
+		detailsErr error
 	}{
 		{
 			testName: "user deleted success",
@@ -380,6 +694,20 @@ func TestDeleteUser(t *testing.T) {
 			res:      false,
 			err:      status.Error(codes.NotFound, "User not found"),
 		},
+		{
+			testName:   "details deletion is left for later",
+			data:       2,
+			res:        true,
+			err:        nil,
+			detailsErr: status.Error(codes.Unavailable, "connection refused"),
+		},
+		{
+			testName:   "user without details deleted success",
+			data:       3,
+			res:        true,
+			err:        nil,
+			detailsErr: status.Error(codes.NotFound, "User not found"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -393,17 +721,238 @@ func TestDeleteUser(t *testing.T) {
 			detailsReq := &detailspb.DeleteUserDetailsRequest{UserId: uint32(tc.data)}
 			if tc.err == nil {
 				userRes = &userpb.DeleteUserResponse{Success: tc.res}
+			}
+			if tc.detailsErr == nil {
 				detailsRes = &detailspb.DeleteUserDetailsResponse{Success: tc.res}
 			}
 
 			// act
 			userMock.On("DeleteUser", mock.Anything, userReq).Return(userRes, tc.err)
-			detailsMock.On("DeleteUserDetails", mock.Anything, detailsReq).Return(detailsRes, nil)
+			detailsMock.On("DeleteUserDetails", mock.Anything, detailsReq).Return(detailsRes, tc.detailsErr)
 			res, err := httpRepository.DeleteUser(ctx, tc.data)
 
 			// assert
 			assert.Equal(res, tc.res)
 			assert.True(repository.TestErrors(err, tc.err))
+			if tc.err != nil {
+				detailsMock.AssertNotCalled(t, "DeleteUserDetails", mock.Anything, detailsReq)
+			}
+		})
+	}
+}
+
+func TestAddAddress(t *testing.T) {
+	userMock := new(repository.GrpcUserMock)
+	detailsMock := new(repository.GrpcDetailsMock)
+	conn1, conn2, httpRepository := repository.InitRepoMock(userMock, detailsMock)
+
+	defer conn1.Close()
+	defer conn2.Close()
+
+	testCases := []struct {
+		testName string
+		userID   int
+		data     entities.Address
+		res      entities.Address
+		err      error
+	}{
+		{
+			testName: "address added success",
+			userID:   1,
+			data: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+			},
+			res: entities.Address{
+				ID:          "61b0c0f1e4b0a1a2b3c4d5e6",
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+				Primary:     true,
+			},
+			err: nil,
+		},
+		{
+			testName: "invalid address error",
+			userID:   2,
+			data: entities.Address{
+				Locality:    "CDMX",
+				CountryCode: "MX",
+			},
+			err: status.Error(codes.FailedPrecondition, "Invalid address 'lines': at least one line is required"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			//  prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			var detailsRes *detailspb.AddAddressResponse
+			detailsReq := &detailspb.AddAddressRequest{
+				UserId: uint32(tc.userID),
+				Address: &detailspb.Address{
+					Lines:       tc.data.Lines,
+					Locality:    tc.data.Locality,
+					CountryCode: tc.data.CountryCode,
+				},
+			}
+			if tc.err == nil {
+				detailsRes = &detailspb.AddAddressResponse{
+					Address: &detailspb.Address{
+						Id:          tc.res.ID,
+						Lines:       tc.res.Lines,
+						Locality:    tc.res.Locality,
+						CountryCode: tc.res.CountryCode,
+						Primary:     tc.res.Primary,
+					},
+				}
+			}
+
+			// act
+			detailsMock.On("AddAddress", mock.Anything, detailsReq).Return(detailsRes, tc.err)
+			res, err := httpRepository.AddAddress(ctx, tc.userID, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.True(repository.TestErrors(err, tc.err))
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		afterID    int
+		userRes    *userpb.ListUsersResponse
+		userErr    error
+		detailsRes *detailspb.BatchGetUserDetailsResponse
+		detailsErr error
+		res        []entities.User
+		next       int
+		err        error
+	}{
+		{
+			testName: "users listed with and without details",
+			afterID:  0,
+			userRes: &userpb.ListUsersResponse{
+				Users: []*userpb.User{
+					{Id: 1, Email: "first@domain.com", DateOfBirth: "1990-01-02", Age: 30},
+					{Id: 2, Email: "second@domain.com", DateOfBirth: "1991-03-04", Age: 29},
+				},
+				NextAfterId: 2,
+			},
+			detailsRes: &detailspb.BatchGetUserDetailsResponse{
+				Results: []*detailspb.UserDetailsResult{
+					{UserId: 1, Found: true, Details: &detailspb.GetUserDetailsResponse{Country: "Mexico"}},
+					{UserId: 2, Found: false},
+				},
+			},
+			res: []entities.User{
+				{ID: 1, Email: "first@domain.com", DateOfBirth: "1990-01-02", Age: 30, Details: entities.Details{Country: "Mexico"}},
+				{ID: 2, Email: "second@domain.com", DateOfBirth: "1991-03-04", Age: 29},
+			},
+			next: 2,
+			err:  nil,
+		},
+		{
+			testName: "details server error",
+			afterID:  0,
+			userRes: &userpb.ListUsersResponse{
+				Users: []*userpb.User{
+					{Id: 1, Email: "first@domain.com", DateOfBirth: "1990-01-02", Age: 30},
+				},
+			},
+			detailsRes: (*detailspb.BatchGetUserDetailsResponse)(nil),
+			detailsErr: status.Error(codes.Unavailable, "connection refused"),
+			err:        status.Error(codes.Unavailable, "connection refused"),
+		},
+		{
+			testName: "missing tenant error",
+			afterID:  2,
+			userRes:  (*userpb.ListUsersResponse)(nil),
+			userErr:  status.Error(codes.FailedPrecondition, "Missing or invalid tenant"),
+			err:      status.Error(codes.FailedPrecondition, "Missing or invalid tenant"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			//  prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			userMock := new(repository.GrpcUserMock)
+			detailsMock := new(repository.GrpcDetailsMock)
+			conn1, conn2, httpRepository := repository.InitRepoMock(userMock, detailsMock)
+			defer conn1.Close()
+			defer conn2.Close()
+			userReq := &userpb.ListUsersRequest{
+				AfterId:  uint32(tc.afterID),
+				PageSize: 2,
+			}
+
+			// act
+			userMock.On("ListUsers", mock.Anything, userReq).Return(tc.userRes, tc.userErr)
+			detailsMock.On("BatchGetUserDetails", mock.Anything, mock.Anything).Return(tc.detailsRes, tc.detailsErr)
+			res, next, err := httpRepository.ListUsers(ctx, tc.afterID, 2)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.next, next)
+			assert.True(repository.TestErrors(err, tc.err))
+			if tc.detailsRes != nil || tc.detailsErr != nil {
+				detailsMock.AssertNumberOfCalls(t, "BatchGetUserDetails", 1)
+			}
+			detailsMock.AssertNotCalled(t, "GetUserDetails", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestTakenEmails(t *testing.T) {
+	userMock := new(repository.GrpcUserMock)
+	detailsMock := new(repository.GrpcDetailsMock)
+	conn1, conn2, httpRepository := repository.InitRepoMock(userMock, detailsMock)
+
+	defer conn1.Close()
+	defer conn2.Close()
+
+	testCases := []struct {
+		testName string
+		emails   []string
+		userRes  *userpb.CheckEmailsResponse
+		userErr  error
+		res      []string
+		err      error
+	}{
+		{
+			testName: "taken email found",
+			emails:   []string{"first@domain.com", "second@domain.com"},
+			userRes:  &userpb.CheckEmailsResponse{Taken: []string{"second@domain.com"}},
+			res:      []string{"second@domain.com"},
+		},
+		{
+			testName: "invalid batch error",
+			emails:   nil,
+			userRes:  (*userpb.CheckEmailsResponse)(nil),
+			userErr:  status.Error(codes.FailedPrecondition, "Invalid batch, expected between 1 and 100 ids"),
+			err:      status.Error(codes.FailedPrecondition, "Invalid batch, expected between 1 and 100 ids"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			//  prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+
+			// act
+			userMock.On("CheckEmails", mock.Anything, &userpb.CheckEmailsRequest{Emails: tc.emails}).Return(tc.userRes, tc.userErr)
+			res, err := httpRepository.TakenEmails(ctx, tc.emails)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.True(repository.TestErrors(err, tc.err))
 		})
 	}
 }
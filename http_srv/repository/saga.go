@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/saga"
+	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
+	"github.com/mauricioww/user_microsrv/user_srv/userpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Types of the sagas used by the writes which span both gRPC servers
+const (
+	createUserSaga = "create_user"
+	updateUserSaga = "update_user"
+	deleteUserSaga = "delete_user"
+)
+
+// registerSagas tells the coordinator how to rebuild the sagas found within the log after a crash, creations
+// are undone while updates and deletions are completed once their pivot, the write of the user, has started
+func (r *HTTPRepository) registerSagas() {
+	r.sagas.Register(createUserSaga, func(s *saga.Saga) []saga.Step {
+		return r.createUserSteps(entities.User{}, new(bool))
+	})
+
+	r.sagas.Register(updateUserSaga, func(s *saga.Saga) []saga.Step {
+		return r.updateUserSteps(entities.UserUpdate{}, new(bool), true)
+	})
+
+	r.sagas.Register(deleteUserSaga, func(s *saga.Saga) []saga.Step {
+		return r.deleteUserSteps(new(bool), true)
+	})
+}
+
+// updateSnapshot stores what the update saga needs after a crash, the previous details to restore them and
+// the new email and date of birth to run the update of the user again
+type updateSnapshot struct {
+	Details     *entities.Details `json:"details,omitempty"`
+	Email       string            `json:"email"`
+	DateOfBirth string            `json:"date_of_birth"`
+}
+
+// ResumeSagas finishes the sagas left unfinished by a crash or by a failed compensation
+func (r *HTTPRepository) ResumeSagas(ctx context.Context) error {
+	return r.sagas.Resume(ctx)
+}
+
+// createUserSteps creates the user and then its details, a created user is purged if its details can not be set
+func (r *HTTPRepository) createUserSteps(user entities.User, success *bool) []saga.Step {
+	return []saga.Step{
+		{
+			Name: "create_user",
+			Do: func(ctx context.Context, s *saga.Saga) error {
+				userReq := userpb.CreateUserRequest{
+					Email:       user.Email,
+					Password:    user.Password,
+					DateOfBirth: user.DateOfBirth,
+				}
+
+				userRes, err := r.userClient.CreateUser(ctx, &userReq)
+				if err != nil {
+					return err
+				}
+
+				s.UserID = int(userRes.GetId())
+				return nil
+			},
+			Compensate: func(ctx context.Context, s *saga.Saga) error {
+				if s.UserID <= 0 {
+					level.Warn(r.logger).Log("saga", s.ID, "msg", "the outcome of the creation is unknown, the user may be orphaned")
+					return nil
+				}
+
+				_, err := r.userClient.PurgeUser(ctx, &userpb.PurgeUserRequest{Id: uint32(s.UserID)})
+				return ignoreNotFound(err)
+			},
+		},
+		{
+			Name: "set_details",
+			Do: func(ctx context.Context, s *saga.Saga) error {
+				detailsRes, err := r.detailsClient.SetUserDetails(ctx, setDetailsRequest(s.UserID, user.Details))
+				if err != nil {
+					return err
+				}
+
+				*success = detailsRes.GetSuccess()
+				return nil
+			},
+			Compensate: func(ctx context.Context, s *saga.Saga) error {
+				_, err := r.detailsClient.DeleteUserDetails(ctx, &detailspb.DeleteUserDetailsRequest{UserId: uint32(s.UserID)})
+				return ignoreNotFound(err)
+			},
+		},
+	}
+}
+
+// updateUserSteps keeps a snapshot of the details, replaces them and then updates the user, the update of the
+// user is the pivot: the snapshot is restored if it is rejected and it is run again if its outcome is unknown.
+// Passwords never reach the saga log so an update run again by a resumed saga keeps the current password
+func (r *HTTPRepository) updateUserSteps(user entities.UserUpdate, success *bool, resumed bool) []saga.Step {
+	return []saga.Step{
+		{
+			Name: "snapshot_details",
+			Do: func(ctx context.Context, s *saga.Saga) error {
+				snapshot := updateSnapshot{Email: user.Email, DateOfBirth: user.DateOfBirth}
+
+				detailsRes, err := r.detailsClient.GetUserDetails(ctx, &detailspb.GetUserDetailsRequest{UserId: uint32(s.UserID)})
+				if err != nil && status.Code(err) != codes.NotFound {
+					return err
+				}
+				if err == nil {
+					previous := detailsFromProto(detailsRes)
+					snapshot.Details = &previous
+				}
+
+				s.Snapshot, err = json.Marshal(snapshot)
+				return err
+			},
+		},
+		{
+			Name: "set_details",
+			Do: func(ctx context.Context, s *saga.Saga) error {
+				detailsRes, err := r.detailsClient.SetUserDetails(ctx, setDetailsRequest(s.UserID, user.Details))
+				if err != nil {
+					return err
+				}
+
+				*success = detailsRes.GetSuccess()
+				return nil
+			},
+			Compensate: func(ctx context.Context, s *saga.Saga) error {
+				var snapshot updateSnapshot
+				if err := json.Unmarshal(s.Snapshot, &snapshot); err != nil {
+					return err
+				}
+
+				if snapshot.Details == nil {
+					_, err := r.detailsClient.DeleteUserDetails(ctx, &detailspb.DeleteUserDetailsRequest{UserId: uint32(s.UserID)})
+					return ignoreNotFound(err)
+				}
+
+				_, err := r.detailsClient.SetUserDetails(ctx, setDetailsRequest(s.UserID, *snapshot.Details))
+				return err
+			},
+		},
+		{
+			Name:  "update_user",
+			Pivot: true,
+			Do: func(ctx context.Context, s *saga.Saga) error {
+				userReq := userpb.UpdateUserRequest{
+					Id:          uint32(s.UserID),
+					Email:       user.Email,
+					Password:    user.Password,
+					DateOfBirth: user.DateOfBirth,
+				}
+
+				if resumed {
+					var snapshot updateSnapshot
+					if err := json.Unmarshal(s.Snapshot, &snapshot); err != nil {
+						return err
+					}
+
+					current, err := r.userClient.GetUser(ctx, &userpb.GetUserRequest{Id: uint32(s.UserID)})
+					if err != nil {
+						return err
+					}
+
+					userReq.Email, userReq.DateOfBirth, userReq.Password = snapshot.Email, snapshot.DateOfBirth, current.GetPassword()
+				}
+
+				userRes, err := r.userClient.UpdateUser(ctx, &userReq)
+				if err != nil {
+					return err
+				}
+
+				*success = *success && userRes.GetSuccess()
+				return nil
+			},
+		},
+	}
+}
+
+// deleteUserSteps deletes the user and then its details, the deletion of the user is the pivot so once it
+// has started the details are deleted eventually, missing details count as deleted so the steps can be retried
+// and on resumed sagas a missing user counts as deleted as well
+func (r *HTTPRepository) deleteUserSteps(success *bool, resumed bool) []saga.Step {
+	return []saga.Step{
+		{
+			Name:  "delete_user",
+			Pivot: true,
+			Do: func(ctx context.Context, s *saga.Saga) error {
+				userRes, err := r.userClient.DeleteUser(ctx, &userpb.DeleteUserRequest{Id: uint32(s.UserID)})
+				if resumed && status.Code(err) == codes.NotFound {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				*success = userRes.GetSuccess()
+				return nil
+			},
+		},
+		{
+			Name: "delete_details",
+			Do: func(ctx context.Context, s *saga.Saga) error {
+				detailsRes, err := r.detailsClient.DeleteUserDetails(ctx, &detailspb.DeleteUserDetailsRequest{UserId: uint32(s.UserID)})
+				if status.Code(err) == codes.NotFound {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				*success = *success && detailsRes.GetSuccess()
+				return nil
+			},
+		},
+	}
+}
+
+func setDetailsRequest(userID int, d entities.Details) *detailspb.SetUserDetailsRequest {
+	return &detailspb.SetUserDetailsRequest{
+		UserId:       uint32(userID),
+		Country:      d.Country,
+		City:         d.City,
+		MobileNumber: d.MobileNumber,
+		Married:      d.Married,
+		Height:       d.Height,
+		Weight:       d.Weight,
+		Attributes:   attributesToProto(d.Attributes),
+	}
+}
+
+// ignoreNotFound treats the records which are already gone as undone
+func ignoreNotFound(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
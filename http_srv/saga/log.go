@@ -0,0 +1,224 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when the saga is not within the log
+var ErrNotFound = errors.New("saga not found")
+
+// ErrInvalidID is returned when the id of the saga can not be used as a file name
+var ErrInvalidID = errors.New("invalid saga id")
+
+var validID = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// Log describes the durable storage of the sagas, only the unfinished sagas are kept
+type Log interface {
+	Save(ctx context.Context, s Saga) error
+	Load(ctx context.Context, id string) (Saga, error)
+	Delete(ctx context.Context, id string) error
+	Pending(ctx context.Context) ([]Saga, error)
+}
+
+// FileLog implements the Log interface storing one JSON file per saga within a directory. The directory belongs
+// to a single process: the sagas are only seen by the process which writes them, so every instance of the HTTP
+// server needs its own persistent directory and the sagas of an instance are resumed once it starts again over
+// the same directory, sharing one directory between running instances would resume sagas still in progress
+type FileLog struct {
+	dir string
+}
+
+// NewFileLog returns a FileLog pointer type which saves the sagas under dir
+func NewFileLog(dir string) (*FileLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileLog{
+		dir: dir,
+	}, nil
+}
+
+// Save writes the saga and flushes it to disk, a crash leaves either the previous or the new version
+func (f *FileLog) Save(ctx context.Context, s Saga) error {
+	path, err := f.path(s.ID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".saga-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads the saga stored with the given id
+func (f *FileLog) Load(ctx context.Context, id string) (Saga, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return Saga{}, err
+	}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Saga{}, ErrNotFound
+	}
+	if err != nil {
+		return Saga{}, err
+	}
+
+	var s Saga
+	err = json.Unmarshal(raw, &s)
+	return s, err
+}
+
+// Delete removes the saga, missing sagas are ignored
+func (f *FileLog) Delete(ctx context.Context, id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Pending reads every saga within the directory ordered by start time
+func (f *FileLog) Pending(ctx context.Context) ([]Saga, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Saga
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		raw, err := os.ReadFile(filepath.Join(f.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var s Saga
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+
+	sortByStart(res)
+	return res, nil
+}
+
+func (f *FileLog) path(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", ErrInvalidID
+	}
+
+	return filepath.Join(f.dir, id+".json"), nil
+}
+
+// MemoryLog implements the Log interface in memory, it is not durable and it is meant for tests
+type MemoryLog struct {
+	mu    sync.Mutex
+	sagas map[string]Saga
+}
+
+// NewMemoryLog returns an empty MemoryLog pointer type
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{
+		sagas: make(map[string]Saga),
+	}
+}
+
+// Save stores a copy of the saga
+func (m *MemoryLog) Save(ctx context.Context, s Saga) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s.Done = append([]string(nil), s.Done...)
+	m.sagas[s.ID] = s
+	return nil
+}
+
+// Load returns a copy of the saga stored with the given id
+func (m *MemoryLog) Load(ctx context.Context, id string) (Saga, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sagas[id]
+	if !ok {
+		return Saga{}, ErrNotFound
+	}
+
+	s.Done = append([]string(nil), s.Done...)
+	return s, nil
+}
+
+// Delete removes the saga, missing sagas are ignored
+func (m *MemoryLog) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sagas, id)
+	return nil
+}
+
+// Pending returns every saga ordered by start time
+func (m *MemoryLog) Pending(ctx context.Context) ([]Saga, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]Saga, 0, len(m.sagas))
+	for _, s := range m.sagas {
+		s.Done = append([]string(nil), s.Done...)
+		res = append(res, s)
+	}
+
+	sortByStart(res)
+	return res, nil
+}
+
+func sortByStart(sagas []Saga) {
+	sort.Slice(sagas, func(i, j int) bool {
+		if sagas[i].Started.Equal(sagas[j].Started) {
+			return sagas[i].ID < sagas[j].ID
+		}
+		return sagas[i].Started.Before(sagas[j].Started)
+	})
+}
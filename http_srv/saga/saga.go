@@ -0,0 +1,365 @@
+package saga
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// States of a saga within the log
+const (
+	Running      = "running"
+	Compensating = "compensating"
+)
+
+// DefaultTimeout bounds the calls done outside of a request to undo or to finish a saga
+const DefaultTimeout = 30 * time.Second
+
+// Saga struct stores the progress of one write that spans both gRPC servers,
+// Snapshot keeps whatever the compensations need to restore the previous state
+type Saga struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Tenant   string          `json:"tenant,omitempty"`
+	UserID   int             `json:"user_id,omitempty"`
+	State    string          `json:"state"`
+	Done     []string        `json:"done"`
+	Current  string          `json:"current,omitempty"`
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Started  time.Time       `json:"started"`
+}
+
+// New returns a Saga pointer type of the given type bound to the tenant of the context
+func New(ctx context.Context, sagaType string, userID int) *Saga {
+	t, _ := tenant.FromContext(ctx)
+
+	return &Saga{
+		ID:      newID(),
+		Type:    sagaType,
+		Tenant:  t,
+		UserID:  userID,
+		Started: time.Now().UTC(),
+	}
+}
+
+// Step struct describes one action of a saga and the action which undoes it, both may run more than once
+// after a crash so they must be idempotent. Pivot marks the step which can not be undone: once it is done,
+// or once it failed without a known outcome, the saga is only completed forward and the later steps are
+// retried until they succeed, a pivot which is rejected undoes the steps done before it
+type Step struct {
+	Name       string
+	Pivot      bool
+	Do         func(ctx context.Context, s *Saga) error
+	Compensate func(ctx context.Context, s *Saga) error
+}
+
+// Definition returns the steps of a saga type, Resume calls it with the saga read from the log
+type Definition func(s *Saga) []Step
+
+// Coordinator runs the sagas recording every step within the log and undoes the completed steps on failure
+type Coordinator struct {
+	log     Log
+	timeout time.Duration
+	types   map[string]Definition
+	mu      sync.Mutex
+	active  map[string]bool
+	logger  log.Logger
+}
+
+// NewCoordinator returns a Coordinator pointer type which stores the sagas within l
+func NewCoordinator(l Log, logger log.Logger) *Coordinator {
+	return &Coordinator{
+		log:     l,
+		timeout: DefaultTimeout,
+		types:   make(map[string]Definition),
+		active:  make(map[string]bool),
+		logger:  log.With(logger, "component", "saga"),
+	}
+}
+
+// Register sets how the unfinished sagas of the type are rebuilt by Resume
+func (c *Coordinator) Register(sagaType string, d Definition) {
+	c.types[sagaType] = d
+}
+
+// Run executes the steps in order, when one of them fails the completed steps are undone and the error
+// of the step is returned, the sagas past their pivot or whose pivot has an unknown outcome are left for Resume
+func (c *Coordinator) Run(ctx context.Context, s *Saga, steps []Step) error {
+	logger := log.With(c.logger, "saga", s.ID, "type", s.Type)
+
+	c.acquire(s.ID)
+	defer c.release(s.ID)
+
+	s.State = Running
+	for _, step := range steps {
+		s.Current = step.Name
+
+		if err := c.log.Save(ctx, *s); err != nil {
+			level.Error(logger).Log("step", step.Name, "err_log", err)
+			s.Current = ""
+			c.compensate(logger, s, steps)
+			return errors.LocalizedStatus(ctx, errors.NewInternalError()).Err()
+		}
+
+		if err := step.Do(ctx, s); err != nil {
+			level.Error(logger).Log("step", step.Name, "err", err)
+			s.Error = err.Error()
+
+			// the saga can not be undone once its pivot is done, Resume finishes it
+			if passed(s, steps) {
+				c.log.Save(ctx, *s)
+				return nil
+			}
+
+			// the pivot may have been applied, Resume runs it again instead of undoing the saga
+			if step.Pivot && uncertain(err) {
+				c.log.Save(ctx, *s)
+				return err
+			}
+
+			if !uncertain(err) {
+				s.Current = ""
+			}
+			c.compensate(logger, s, steps)
+			return err
+		}
+
+		s.Done = append(s.Done, step.Name)
+		s.Current = ""
+	}
+
+	if err := c.log.Delete(ctx, s.ID); err != nil {
+		level.Error(logger).Log("err_log", err)
+	}
+
+	return nil
+}
+
+// Resume completes the sagas left within the log by a crash or by a failed compensation,
+// the sagas still running within this process are skipped
+func (c *Coordinator) Resume(ctx context.Context) error {
+	pending, err := c.log.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pending {
+		if !c.acquire(p.ID) {
+			continue
+		}
+
+		// the saga may have finished between the listing and the acquisition
+		s, err := c.log.Load(ctx, p.ID)
+		if err == nil {
+			c.resume(&s)
+		}
+
+		c.release(p.ID)
+	}
+
+	return nil
+}
+
+func (c *Coordinator) resume(s *Saga) {
+	logger := log.With(c.logger, "saga", s.ID, "type", s.Type)
+
+	definition, ok := c.types[s.Type]
+	if !ok {
+		level.Error(logger).Log("err", "unknown saga type")
+		return
+	}
+
+	steps := definition(s)
+	if s.State == Running && (passed(s, steps) || pivotRunning(s, steps)) {
+		c.forward(logger, s, steps)
+		return
+	}
+
+	c.compensate(logger, s, steps)
+}
+
+// forward runs the steps which are not done, the failures past the pivot or without a known outcome leave the
+// saga for the next Resume while a rejected pivot undoes the steps done before it
+func (c *Coordinator) forward(logger log.Logger, s *Saga, steps []Step) error {
+	ctx, cancel := c.detached(s)
+	defer cancel()
+
+	for _, step := range steps {
+		if contains(s.Done, step.Name) {
+			continue
+		}
+
+		s.Current = step.Name
+		if err := c.log.Save(ctx, *s); err != nil {
+			level.Error(logger).Log("step", step.Name, "err_log", err)
+			return err
+		}
+
+		if err := step.Do(ctx, s); err != nil {
+			level.Error(logger).Log("step", step.Name, "err", err)
+			s.Error = err.Error()
+			if uncertain(err) || passed(s, steps) {
+				c.log.Save(ctx, *s)
+				return err
+			}
+
+			s.Current = ""
+			c.compensate(logger, s, steps)
+			return err
+		}
+
+		s.Done = append(s.Done, step.Name)
+		s.Current = ""
+	}
+
+	if err := c.log.Delete(ctx, s.ID); err != nil {
+		level.Error(logger).Log("err_log", err)
+		return err
+	}
+
+	logger.Log("action", "completed")
+	return nil
+}
+
+// compensate undoes the done steps and the current one in reverse order, failures leave the saga for the next Resume
+func (c *Coordinator) compensate(logger log.Logger, s *Saga, steps []Step) error {
+	ctx, cancel := c.detached(s)
+	defer cancel()
+
+	s.State = Compensating
+	if err := c.log.Save(ctx, *s); err != nil {
+		level.Error(logger).Log("err_log", err)
+	}
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		if step.Name != s.Current && !contains(s.Done, step.Name) {
+			continue
+		}
+
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, s); err != nil {
+				level.Error(logger).Log("compensation", step.Name, "err", err)
+				s.Error = err.Error()
+				c.log.Save(ctx, *s)
+				return err
+			}
+		}
+
+		if step.Name == s.Current {
+			s.Current = ""
+		}
+		s.Done = remove(s.Done, step.Name)
+
+		if err := c.log.Save(ctx, *s); err != nil {
+			level.Error(logger).Log("err_log", err)
+		}
+	}
+
+	if err := c.log.Delete(ctx, s.ID); err != nil {
+		level.Error(logger).Log("err_log", err)
+		return err
+	}
+
+	logger.Log("action", "compensated")
+	return nil
+}
+
+// detached returns a context bound to the tenant of the saga which is not canceled along with the request
+func (c *Coordinator) detached(s *Saga) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if s.Tenant != "" {
+		ctx = tenant.NewContext(ctx, s.Tenant)
+	}
+
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// acquire marks the saga as running within this process, it returns false if it already was
+func (c *Coordinator) acquire(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.active[id] {
+		return false
+	}
+
+	c.active[id] = true
+	return true
+}
+
+func (c *Coordinator) release(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.active, id)
+}
+
+// uncertain reports whether the failed call may have been applied by the server anyway
+func uncertain(err error) bool {
+	switch status.Code(err) {
+	case codes.Unknown, codes.Canceled, codes.DeadlineExceeded, codes.Unavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// passed reports whether the pivot of the saga is done
+func passed(s *Saga, steps []Step) bool {
+	for _, step := range steps {
+		if step.Pivot && contains(s.Done, step.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// pivotRunning reports whether the saga stopped while its pivot was running
+func pivotRunning(s *Saga, steps []Step) bool {
+	for _, step := range steps {
+		if step.Pivot && step.Name == s.Current {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(names []string, name string) []string {
+	res := names[:0]
+	for _, n := range names {
+		if n != name {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,244 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/mauricioww/user_microsrv/http_srv/saga"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recorder builds steps which append their calls, failing the actions listed in fail
+type recorder struct {
+	calls []string
+	fail  map[string]error
+}
+
+func (r *recorder) step(name string) saga.Step {
+	return saga.Step{
+		Name: name,
+		Do: func(ctx context.Context, s *saga.Saga) error {
+			r.calls = append(r.calls, "do "+name)
+			return r.fail["do "+name]
+		},
+		Compensate: func(ctx context.Context, s *saga.Saga) error {
+			r.calls = append(r.calls, "undo "+name)
+			return r.fail["undo "+name]
+		},
+	}
+}
+
+// steps returns the steps of the saga, the one named pivot is marked as its pivot
+func (r *recorder) steps(pivot string) []saga.Step {
+	steps := []saga.Step{r.step("first"), r.step("second"), r.step("third")}
+	for i := range steps {
+		steps[i].Pivot = steps[i].Name == pivot
+	}
+	return steps
+}
+
+func TestRun(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+	rejected := status.Error(codes.FailedPrecondition, "rejected")
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	testCases := []struct {
+		testName string
+		pivot    string
+		fail     map[string]error
+		calls    []string
+		err      error
+		pending  bool
+	}{
+		{
+			testName: "every step done",
+			calls:    []string{"do first", "do second", "do third"},
+		},
+		{
+			testName: "rejected step undoes the done steps",
+			fail:     map[string]error{"do third": rejected},
+			calls:    []string{"do first", "do second", "do third", "undo second", "undo first"},
+			err:      rejected,
+		},
+		{
+			testName: "uncertain step is undone as well",
+			fail:     map[string]error{"do second": unavailable},
+			calls:    []string{"do first", "do second", "undo second", "undo first"},
+			err:      unavailable,
+		},
+		{
+			testName: "failed compensation keeps the saga",
+			fail:     map[string]error{"do third": rejected, "undo second": unavailable},
+			calls:    []string{"do first", "do second", "do third", "undo second"},
+			err:      rejected,
+			pending:  true,
+		},
+		{
+			testName: "saga past its pivot is left for resume",
+			pivot:    "first",
+			fail:     map[string]error{"do second": unavailable},
+			calls:    []string{"do first", "do second"},
+			err:      nil,
+			pending:  true,
+		},
+		{
+			testName: "rejected pivot undoes the done steps",
+			pivot:    "second",
+			fail:     map[string]error{"do second": rejected},
+			calls:    []string{"do first", "do second", "undo first"},
+			err:      rejected,
+		},
+		{
+			testName: "uncertain pivot is left for resume",
+			pivot:    "second",
+			fail:     map[string]error{"do second": unavailable},
+			calls:    []string{"do first", "do second"},
+			err:      unavailable,
+			pending:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			sagaLog := saga.NewMemoryLog()
+			coordinator := saga.NewCoordinator(sagaLog, logger)
+			rec := &recorder{fail: tc.fail}
+			coordinator.Register("test", func(s *saga.Saga) []saga.Step { return rec.steps(tc.pivot) })
+
+			// act
+			err := coordinator.Run(ctx, saga.New(ctx, "test", 1), rec.steps(tc.pivot))
+			pending, _ := sagaLog.Pending(ctx)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Equal(tc.calls, rec.calls)
+			assert.Equal(tc.pending, len(pending) == 1)
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+
+	testCases := []struct {
+		testName string
+		pivot    string
+		saved    saga.Saga
+		fail     map[string]error
+		calls    []string
+		pending  bool
+	}{
+		{
+			testName: "running saga before its pivot is undone including the current step",
+			pivot:    "third",
+			saved:    saga.Saga{State: saga.Running, Done: []string{"first"}, Current: "second"},
+			calls:    []string{"undo second", "undo first"},
+		},
+		{
+			testName: "compensating saga continues its compensation",
+			pivot:    "first",
+			saved:    saga.Saga{State: saga.Compensating, Done: []string{"first"}},
+			calls:    []string{"undo first"},
+		},
+		{
+			testName: "running saga past its pivot runs the pending steps",
+			pivot:    "first",
+			saved:    saga.Saga{State: saga.Running, Done: []string{"first"}, Current: "second"},
+			calls:    []string{"do second", "do third"},
+		},
+		{
+			testName: "failed step past the pivot is retried later",
+			pivot:    "first",
+			saved:    saga.Saga{State: saga.Running, Done: []string{"first"}, Current: "second"},
+			fail:     map[string]error{"do third": status.Error(codes.Internal, "internal")},
+			calls:    []string{"do second", "do third"},
+			pending:  true,
+		},
+		{
+			testName: "failed compensation is retried later",
+			saved:    saga.Saga{State: saga.Compensating, Done: []string{"first", "second"}},
+			fail:     map[string]error{"undo first": errors.New("unreachable")},
+			calls:    []string{"undo second", "undo first"},
+			pending:  true,
+		},
+		{
+			testName: "running pivot is run again",
+			pivot:    "second",
+			saved:    saga.Saga{State: saga.Running, Done: []string{"first"}, Current: "second"},
+			calls:    []string{"do second", "do third"},
+		},
+		{
+			testName: "running pivot rejected undoes the done steps",
+			pivot:    "second",
+			saved:    saga.Saga{State: saga.Running, Done: []string{"first"}, Current: "second"},
+			fail:     map[string]error{"do second": status.Error(codes.FailedPrecondition, "rejected")},
+			calls:    []string{"do second", "undo first"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			sagaLog := saga.NewMemoryLog()
+			coordinator := saga.NewCoordinator(sagaLog, logger)
+			rec := &recorder{fail: tc.fail}
+			coordinator.Register("test", func(s *saga.Saga) []saga.Step { return rec.steps(tc.pivot) })
+
+			s := saga.New(ctx, "test", 1)
+			s.State, s.Done, s.Current = tc.saved.State, tc.saved.Done, tc.saved.Current
+			sagaLog.Save(ctx, *s)
+
+			// act
+			err := coordinator.Resume(ctx)
+			pending, _ := sagaLog.Pending(ctx)
+
+			// assert
+			assert.Nil(err)
+			assert.Equal(tc.calls, rec.calls)
+			assert.Equal(tc.pending, len(pending) == 1)
+		})
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	sagaLog, err := saga.NewFileLog(t.TempDir())
+	assert.Nil(err)
+
+	first := saga.New(ctx, "test", 1)
+	first.Started = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	first.Done = []string{"first"}
+	second := saga.New(ctx, "test", 2)
+	second.Started = first.Started.Add(time.Minute)
+
+	assert.Nil(sagaLog.Save(ctx, *second))
+	assert.Nil(sagaLog.Save(ctx, *first))
+
+	loaded, err := sagaLog.Load(ctx, first.ID)
+	assert.Nil(err)
+	assert.Equal(*first, loaded)
+
+	pending, err := sagaLog.Pending(ctx)
+	assert.Nil(err)
+	assert.Equal([]saga.Saga{*first, *second}, pending)
+
+	assert.Nil(sagaLog.Delete(ctx, first.ID))
+	assert.Nil(sagaLog.Delete(ctx, first.ID))
+
+	_, err = sagaLog.Load(ctx, first.ID)
+	assert.Equal(saga.ErrNotFound, err)
+
+	assert.Equal(saga.ErrInvalidID, sagaLog.Save(ctx, saga.Saga{ID: "../escape"}))
+}
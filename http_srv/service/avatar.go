@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	// decoders of the supported avatar formats
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/mauricioww/user_microsrv/errors"
+)
+
+const (
+	// MaxAvatarSize is the maximum size in bytes of an uploaded avatar
+	MaxAvatarSize = 5 << 20
+	// maxAvatarPixels protects the service against images which are small files but huge once decoded, an RGBA
+	// image of 4 megapixels takes 16 MB
+	maxAvatarPixels = 4000000
+)
+
+// ThumbnailSizes stores the side in pixels of the square thumbnails generated for every avatar
+var ThumbnailSizes = []int{64, 256}
+
+// avatarTypes maps the supported content types to the extension used to store them
+var avatarTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// decodeAvatar validates the content type of the raw avatar and decodes it, returns the extension to store it
+func decodeAvatar(raw []byte) (image.Image, string, error) {
+	ext, ok := avatarTypes[http.DetectContentType(raw)]
+	if !ok {
+		return nil, "", errors.NewInvalidAvatarError()
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || cfg.Width*cfg.Height > maxAvatarPixels {
+		return nil, "", errors.NewInvalidAvatarError()
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, "", errors.NewInvalidAvatarError()
+	}
+
+	return img, ext, nil
+}
+
+// avatarRef returns the key of the original avatar, the content hash makes every upload a new key
+func avatarRef(tenantID string, userID int, raw []byte, ext string) string {
+	sum := sha256.Sum256(raw)
+	return fmt.Sprintf("avatars/%v/%v/%v%v", tenantID, userID, hex.EncodeToString(sum[:8]), ext)
+}
+
+// thumbnailKey returns the key of the thumbnail with the given size for the avatar reference
+func thumbnailKey(ref string, size int) string {
+	return fmt.Sprintf("%v_%v.png", strings.TrimSuffix(ref, path.Ext(ref)), size)
+}
+
+// avatarKey returns the smallest thumbnail that covers the requested size, or the original avatar
+func avatarKey(ref string, size int) string {
+	if size <= 0 {
+		return ref
+	}
+
+	for _, s := range ThumbnailSizes {
+		if size <= s {
+			return thumbnailKey(ref, s)
+		}
+	}
+
+	return ref
+}
+
+// avatarContentType returns the content type of the stored avatar using its extension
+func avatarContentType(key string) string {
+	return mime.TypeByExtension(path.Ext(key))
+}
+
+// AvatarURL returns the public URL of the avatar, the version query changes on every upload
+func AvatarURL(userID int, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	version := strings.TrimSuffix(path.Base(ref), path.Ext(ref))
+	return fmt.Sprintf("/users/%v/avatar?v=%v", userID, version)
+}
+
+// encodeThumbnail center crops the image to a square and scales it down to size x size pixels
+func encodeThumbnail(img image.Image, size int) ([]byte, error) {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	crop := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := crop.Min.Y + y*side/size
+		y1 := crop.Min.Y + (y+1)*side/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < size; x++ {
+			x0 := crop.Min.X + x*side/size
+			x1 := crop.Min.X + (x+1)*side/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			dst.Set(x, y, average(img, image.Rect(x0, y0, x1, y1)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// average returns the mean color of the pixels within the rectangle
+func average(img image.Image, r image.Rectangle) color.Color {
+	var red, green, blue, alpha, n uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			red += uint64(cr)
+			green += uint64(cg)
+			blue += uint64(cb)
+			alpha += uint64(ca)
+			n++
+		}
+	}
+
+	return color.RGBA64{
+		R: uint16(red / n),
+		G: uint16(green / n),
+		B: uint16(blue / n),
+		A: uint16(alpha / n),
+	}
+}
+
+// statusError translates the custom errors raised within this service to the status errors sent by the gRPC servers,
+// localized to the language stored within the context
+func statusError(ctx context.Context, e errors.ErrorResolver) error {
+	return errors.LocalizedStatus(ctx, e.(error)).Err()
+}
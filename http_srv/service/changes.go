@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+)
+
+const (
+	defaultChangesPageSize = 100
+	maxChangesPageSize     = 1000
+)
+
+// feedCursor stores the cursor of each gRPC server, the cursor of the feed is its base64 JSON encoding
+type feedCursor struct {
+	Version int    `json:"v"`
+	User    string `json:"u,omitempty"`
+	Details string `json:"d,omitempty"`
+}
+
+// feedCursorVersion 2 points to the positions the relays assign on publication, the cursors of the version 1
+// pointed to the outbox rows of the users so they are rejected
+const feedCursorVersion = 2
+
+func (c feedCursor) encode() string {
+	c.Version = feedCursorVersion
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeFeedCursor(cursor string) (feedCursor, error) {
+	var c feedCursor
+	if cursor == "" {
+		return c, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, errors.NewInvalidCursorError()
+	}
+
+	if err := json.Unmarshal(raw, &c); err != nil || c.Version != feedCursorVersion {
+		return c, errors.NewInvalidCursorError()
+	}
+
+	return c, nil
+}
+
+// ListChanges returns one page of the changes of the users and their details after the cursor and the cursor
+// to request the next page. The changes of both gRPC servers are merged by the time they occurred while each one
+// keeps the order of its server, so resuming from any cursor neither skips nor repeats a change
+func (s *HTTPService) ListChanges(ctx context.Context, cursor string, pageSize int) ([]entities.Change, string, error) {
+	logger := log.With(s.logger, "method", "list_changes")
+
+	position, err := decodeFeedCursor(cursor)
+	if e, ok := err.(errors.ErrorResolver); ok {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, "", statusError(ctx, e)
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultChangesPageSize
+	}
+
+	if pageSize > maxChangesPageSize {
+		pageSize = maxChangesPageSize
+	}
+
+	userChanges, _, err := s.repository.ListUserChanges(ctx, position.User, pageSize)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, "", err
+	}
+
+	detailsChanges, _, err := s.repository.ListDetailsChanges(ctx, position.Details, pageSize)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, "", err
+	}
+
+	res := make([]entities.Change, 0, pageSize)
+	for len(res) < pageSize && (len(userChanges) > 0 || len(detailsChanges) > 0) {
+		var c entities.Change
+		if len(detailsChanges) == 0 || (len(userChanges) > 0 && !detailsChanges[0].OccurredAt.Before(userChanges[0].OccurredAt)) {
+			c, userChanges = userChanges[0], userChanges[1:]
+			position.User = c.Cursor
+		} else {
+			c, detailsChanges = detailsChanges[0], detailsChanges[1:]
+			position.Details = c.Cursor
+		}
+
+		c.Cursor = position.encode()
+		res = append(res, c)
+	}
+
+	next := position.encode()
+	if len(res) == 0 && cursor != "" {
+		next = cursor
+	}
+
+	logger.Log("action", "success")
+	return res, next, nil
+}
+
+// WatchUser returns the changes of the user and its details after the Last-Event-ID, which is a cursor of the feed,
+// the empty one only returns the changes made from now on. Both gRPC streams are merged in the order they arrive and
+// every change carries the cursor which resumes the watch after it. The channel is closed when the context is done
+// or either stream ends, the client is expected to reconnect with the last cursor it got
+func (s *HTTPService) WatchUser(ctx context.Context, userID int, lastEventID string) (<-chan entities.Change, error) {
+	logger := log.With(s.logger, "method", "watch_user")
+
+	position, err := decodeFeedCursor(lastEventID)
+	if e, ok := err.(errors.ErrorResolver); ok {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, statusError(ctx, e)
+	}
+
+	if _, err := s.repository.GetUser(ctx, userID); err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	received := make(chan entities.Change)
+	forward := func(c entities.Change) error {
+		select {
+		case received <- c:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var wg sync.WaitGroup
+	watch := func(w func(context.Context, int, string, func(entities.Change) error) error, cursor string) {
+		defer wg.Done()
+		defer cancel()
+		if err := w(ctx, userID, cursor, forward); err != nil && ctx.Err() == nil {
+			level.Error(logger).Log("ERROR: ", err)
+		}
+	}
+
+	wg.Add(2)
+	go watch(s.repository.WatchUserChanges, position.User)
+	go watch(s.repository.WatchDetailsChanges, position.Details)
+
+	go func() {
+		wg.Wait()
+		close(received)
+	}()
+
+	res := make(chan entities.Change)
+	go func() {
+		defer close(res)
+		for c := range received {
+			if c.Source == entities.UserSource {
+				position.User = c.Cursor
+			} else {
+				position.Details = c.Cursor
+			}
+			c.Cursor = position.encode()
+
+			select {
+			case res <- c:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	logger.Log("action", "success")
+	return res, nil
+}
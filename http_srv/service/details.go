@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizeUser checks the credentials belong to the user, the missing or wrong credentials are unauthenticated and
+// the credentials of another user are forbidden
+func (s *HTTPService) AuthorizeUser(ctx context.Context, userID int, email string, pwd string) error {
+	logger := log.With(s.logger, "method", "authorize_user")
+
+	if email == "" || pwd == "" {
+		return statusError(ctx, errors.NewUnauthenticatedError())
+	}
+
+	ok, err := s.repository.Authenticate(ctx, entities.Session{Email: email, Password: pwd})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound, codes.Unauthenticated, codes.FailedPrecondition:
+		ok = false
+	default:
+		level.Error(logger).Log("ERROR: ", err)
+		return err
+	}
+	if !ok {
+		return statusError(ctx, errors.NewUnauthenticatedError())
+	}
+
+	user, err := s.repository.GetUser(ctx, userID)
+	if status.Code(err) == codes.NotFound || (err == nil && !strings.EqualFold(user.Email, email)) {
+		level.Error(logger).Log("forbidden", userID)
+		return statusError(ctx, errors.NewForbiddenError())
+	}
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetUserDetails receives one ID and fetchs the details of the user from the repository
+func (s *HTTPService) GetUserDetails(ctx context.Context, userID int) (entities.Details, error) {
+	logger := log.With(s.logger, "method", "get_user_details")
+
+	res, err := s.repository.GetUserDetails(ctx, userID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return entities.Details{}, err
+	}
+
+	res.AvatarURL = AvatarURL(userID, res.Avatar)
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// SetUserDetails receives the details which replace the current details of the user and send them to repository
+func (s *HTTPService) SetUserDetails(ctx context.Context, userID int, details entities.Details) (bool, error) {
+	logger := log.With(s.logger, "method", "set_user_details")
+
+	res, err := s.repository.SetUserDetails(ctx, userID, details, entities.AnyRevision)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// maxPatchAttempts bounds how many times a patch is applied again to the details changed by another write
+const maxPatchAttempts = 3
+
+// PatchUserDetails applies the patch to the current details of the user, the missing details are created from the
+// patch, and returns the patched details. The details are only replaced while they are at the revision the patch
+// was applied to, so a concurrent write is never lost, the patch is applied again to the new details unless the
+// client expected a revision other than entities.AnyRevision
+func (s *HTTPService) PatchUserDetails(ctx context.Context, userID int, patch entities.DetailsPatch, revision int64) (entities.Details, error) {
+	logger := log.With(s.logger, "method", "patch_user_details")
+
+	for attempt := 1; ; attempt++ {
+		current, err := s.repository.GetUserDetails(ctx, userID)
+		if err != nil && status.Code(err) != codes.NotFound {
+			level.Error(logger).Log("ERROR: ", err)
+			return entities.Details{}, err
+		}
+		if err != nil {
+			current = entities.Details{}
+		}
+
+		if revision != entities.AnyRevision && current.Revision != revision {
+			level.Error(logger).Log("modified", userID)
+			return entities.Details{}, statusError(ctx, errors.NewDetailsModifiedError())
+		}
+
+		res := patch.Apply(current)
+		_, err = s.repository.SetUserDetails(ctx, userID, res, current.Revision)
+		if status.Code(err) == codes.Aborted && revision == entities.AnyRevision && attempt < maxPatchAttempts {
+			continue
+		}
+		if err != nil {
+			level.Error(logger).Log("ERROR: ", err)
+			return entities.Details{}, err
+		}
+
+		// the number is verified again once it changes
+		res.MobileVerified = current.MobileVerified && current.MobileNumber == res.MobileNumber
+		res.AvatarURL = AvatarURL(userID, res.Avatar)
+		// the revision of the patched details is only known once they are read again
+		res.Revision = 0
+
+		logger.Log("action", "success")
+		return res, nil
+	}
+}
+
+// DeleteUserDetails receives one ID and removes the details of the user, the user is kept
+func (s *HTTPService) DeleteUserDetails(ctx context.Context, userID int) (bool, error) {
+	logger := log.With(s.logger, "method", "delete_user_details")
+
+	res, err := s.repository.DeleteUserDetails(ctx, userID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
@@ -1,46 +1,84 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"io"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/blob"
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
 	"github.com/mauricioww/user_microsrv/http_srv/entities"
 	"github.com/mauricioww/user_microsrv/http_srv/repository"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
+	"github.com/mauricioww/user_microsrv/tenant"
 )
 
+// maxBatchSize is the most users requested by one batch, it matches the limit of both gRPC servers
+const maxBatchSize = 100
+
 // HTTPServicer describes the logic business of the services
 type HTTPServicer interface {
-	CreateUser(ctx context.Context, email string, pwd string, age int, details entities.Details) (int, error)
+	CreateUser(ctx context.Context, email string, pwd string, dateOfBirth string, details entities.Details) (int, error)
 	Authenticate(ctx context.Context, email string, pwd string) (bool, error)
-	UpdateUser(ctx context.Context, userID int, email string, pwd string, age int, details entities.Details) (bool, error)
+	UpdateUser(ctx context.Context, userID int, email string, pwd string, dateOfBirth string, details entities.Details) (bool, error)
 	GetUser(ctx context.Context, userID int) (entities.User, error)
+	BatchGetUsers(ctx context.Context, userIDs []int) ([]entities.UserResult, error)
 	DeleteUser(ctx context.Context, userID int) (bool, error)
+	AuthorizeUser(ctx context.Context, userID int, email string, pwd string) error
+	GetUserDetails(ctx context.Context, userID int) (entities.Details, error)
+	SetUserDetails(ctx context.Context, userID int, details entities.Details) (bool, error)
+	PatchUserDetails(ctx context.Context, userID int, patch entities.DetailsPatch, revision int64) (entities.Details, error)
+	DeleteUserDetails(ctx context.Context, userID int) (bool, error)
+	AddAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error)
+	ListAddresses(ctx context.Context, userID int) ([]entities.Address, error)
+	GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error)
+	UpdateAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error)
+	DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error)
+	SendPhoneVerification(ctx context.Context, userID int) (bool, error)
+	VerifyPhone(ctx context.Context, userID int, code string) (bool, error)
+	UploadAvatar(ctx context.Context, userID int, data io.Reader) (string, error)
+	GetAvatar(ctx context.Context, userID int, size int) (io.ReadCloser, string, error)
+	ImportUsers(ctx context.Context, format string, data io.Reader, dryRun bool) (bulk.Report, error)
+	ExportUsers(ctx context.Context, format string, w io.Writer) (int, error)
+	CreateWebhook(ctx context.Context, subscription webhook.Subscription) (webhook.Subscription, error)
+	ListWebhooks(ctx context.Context) ([]webhook.Subscription, error)
+	DeleteWebhook(ctx context.Context, webhookID string) (bool, error)
+	ListDeliveries(ctx context.Context, webhookID string, deliveryStatus string) ([]webhook.Delivery, error)
+	ReplayDelivery(ctx context.Context, webhookID string, deliveryID string) (webhook.Delivery, error)
+	ListChanges(ctx context.Context, cursor string, pageSize int) ([]entities.Change, string, error)
+	WatchUser(ctx context.Context, userID int, lastEventID string) (<-chan entities.Change, error)
 }
 
 // HTTPService type implement the HTTPServicer interface
 type HTTPService struct {
 	repository repository.HTTPRepositorier
+	store      blob.Store
+	webhooks   webhook.Manager
 	logger     log.Logger
 }
 
 // NewHTTPService returns a HTTPService pointer type
-func NewHTTPService(r repository.HTTPRepositorier, l log.Logger) *HTTPService {
+func NewHTTPService(r repository.HTTPRepositorier, store blob.Store, webhooks webhook.Manager, l log.Logger) *HTTPService {
 	return &HTTPService{
 		logger:     l,
 		repository: r,
+		store:      store,
+		webhooks:   webhooks,
 	}
 }
 
 // CreateUser receives data for a new user and send it to the repository
-func (s *HTTPService) CreateUser(ctx context.Context, email string, pwd string, age int, details entities.Details) (int, error) {
+func (s *HTTPService) CreateUser(ctx context.Context, email string, pwd string, dateOfBirth string, details entities.Details) (int, error) {
 	logger := log.With(s.logger, "method", "create_user")
 
 	user := entities.User{
-		Email:    email,
-		Password: pwd,
-		Age:      age,
-		Details:  details,
+		Email:       email,
+		Password:    pwd,
+		DateOfBirth: dateOfBirth,
+		Details:     details,
 	}
 
 	res, err := s.repository.CreateUser(ctx, user)
@@ -75,15 +113,15 @@ func (s *HTTPService) Authenticate(ctx context.Context, email string, pwd string
 }
 
 // UpdateUser receives new data to replace the old data of a user and send it to repository
-func (s *HTTPService) UpdateUser(ctx context.Context, userID int, email string, pwd string, age int, details entities.Details) (bool, error) {
+func (s *HTTPService) UpdateUser(ctx context.Context, userID int, email string, pwd string, dateOfBirth string, details entities.Details) (bool, error) {
 	logger := log.With(s.logger, "method", "update_user")
 	info := entities.UserUpdate{
 		UserID: userID,
 		User: entities.User{
-			Email:    email,
-			Password: pwd,
-			Age:      age,
-			Details:  details,
+			Email:       email,
+			Password:    pwd,
+			DateOfBirth: dateOfBirth,
+			Details:     details,
 		},
 	}
 
@@ -109,6 +147,35 @@ func (s *HTTPService) GetUser(ctx context.Context, userID int) (entities.User, e
 		return entities.User{}, err
 	}
 
+	res.AvatarURL = AvatarURL(userID, res.Avatar)
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// BatchGetUsers receives up to maxBatchSize IDs and returns one result for each of them, in the same order
+func (s *HTTPService) BatchGetUsers(ctx context.Context, userIDs []int) ([]entities.UserResult, error) {
+	logger := log.With(s.logger, "method", "batch_get_users")
+
+	if len(userIDs) == 0 || len(userIDs) > maxBatchSize {
+		e := errors.NewInvalidBatchError()
+		level.Error(logger).Log("validation: ", e)
+		return nil, statusError(ctx, e)
+	}
+
+	res, err := s.repository.BatchGetUsers(ctx, userIDs)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, err
+	}
+
+	for i := range res {
+		if res[i].Found {
+			res[i].User.AvatarURL = AvatarURL(res[i].ID, res[i].User.Avatar)
+		}
+	}
+
 	logger.Log("action", "success")
 	return res, nil
 }
@@ -127,3 +194,275 @@ func (s *HTTPService) DeleteUser(ctx context.Context, userID int) (bool, error)
 	logger.Log("action", "success")
 	return res, nil
 }
+
+// AddAddress receives a new address of a user and send it to repository
+func (s *HTTPService) AddAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	logger := log.With(s.logger, "method", "add_address")
+
+	res, err := s.repository.AddAddress(ctx, userID, address)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return entities.Address{}, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// ListAddresses receives one ID and send it to repository
+func (s *HTTPService) ListAddresses(ctx context.Context, userID int) ([]entities.Address, error) {
+	logger := log.With(s.logger, "method", "list_addresses")
+
+	res, err := s.repository.ListAddresses(ctx, userID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// GetAddress receives the user and address IDs and send them to repository
+func (s *HTTPService) GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error) {
+	logger := log.With(s.logger, "method", "get_address")
+
+	res, err := s.repository.GetAddress(ctx, userID, addressID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return entities.Address{}, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// UpdateAddress receives new data to replace an address of a user and send it to repository
+func (s *HTTPService) UpdateAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	logger := log.With(s.logger, "method", "update_address")
+
+	res, err := s.repository.UpdateAddress(ctx, userID, address)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return entities.Address{}, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// DeleteAddress receives the user and address IDs and send them to repository
+func (s *HTTPService) DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error) {
+	logger := log.With(s.logger, "method", "delete_address")
+
+	res, err := s.repository.DeleteAddress(ctx, userID, addressID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// SendPhoneVerification receives one ID and send it to repository
+func (s *HTTPService) SendPhoneVerification(ctx context.Context, userID int) (bool, error) {
+	logger := log.With(s.logger, "method", "send_phone_verification")
+
+	res, err := s.repository.SendPhoneVerification(ctx, userID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// VerifyPhone receives the user ID and the one-time code and send them to repository
+func (s *HTTPService) VerifyPhone(ctx context.Context, userID int, code string) (bool, error) {
+	logger := log.With(s.logger, "method", "verify_phone")
+
+	res, err := s.repository.VerifyPhone(ctx, userID, code)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// UploadAvatar validates the image, stores it along with its thumbnails and replaces the previous avatar of the user
+func (s *HTTPService) UploadAvatar(ctx context.Context, userID int, data io.Reader) (string, error) {
+	logger := log.With(s.logger, "method", "upload_avatar")
+
+	raw, err := io.ReadAll(io.LimitReader(data, MaxAvatarSize+1))
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return "", statusError(ctx, errors.NewInvalidAvatarError())
+	}
+
+	if len(raw) > MaxAvatarSize {
+		e := errors.NewAvatarTooLargeError()
+		level.Error(logger).Log("ERROR: ", e)
+		return "", statusError(ctx, e)
+	}
+
+	img, ext, err := decodeAvatar(raw)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return "", statusError(ctx, err.(errors.ErrorResolver))
+	}
+
+	previous, err := s.repository.GetAvatar(ctx, userID)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return "", err
+	}
+
+	tenantID, _ := tenant.FromContext(ctx)
+	ref := avatarRef(tenantID, userID, raw, ext)
+
+	objects := map[string][]byte{ref: raw}
+	for _, size := range ThumbnailSizes {
+		thumb, err := encodeThumbnail(img, size)
+		if err != nil {
+			level.Error(logger).Log("ERROR: ", err)
+			return "", statusError(ctx, errors.NewInternalError())
+		}
+		objects[thumbnailKey(ref, size)] = thumb
+	}
+
+	for key, obj := range objects {
+		if err := s.store.Put(ctx, key, bytes.NewReader(obj)); err != nil {
+			level.Error(logger).Log("ERROR: ", err)
+			if ref != previous {
+				s.deleteAvatar(ctx, logger, ref)
+			}
+			return "", statusError(ctx, errors.NewInternalError())
+		}
+	}
+
+	if _, err := s.repository.SetAvatar(ctx, userID, ref); err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		if ref != previous {
+			s.deleteAvatar(ctx, logger, ref)
+		}
+		return "", err
+	}
+
+	if previous != "" && previous != ref {
+		s.deleteAvatar(ctx, logger, previous)
+	}
+
+	logger.Log("action", "success")
+	return AvatarURL(userID, ref), nil
+}
+
+// GetAvatar opens the avatar of the user, a positive size selects the smallest thumbnail which covers it
+func (s *HTTPService) GetAvatar(ctx context.Context, userID int, size int) (io.ReadCloser, string, error) {
+	logger := log.With(s.logger, "method", "get_avatar")
+
+	ref, err := s.repository.GetAvatar(ctx, userID)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, "", err
+	}
+
+	if ref == "" {
+		e := errors.NewAvatarNotFoundError()
+		level.Error(logger).Log("ERROR: ", e)
+		return nil, "", statusError(ctx, e)
+	}
+
+	key := avatarKey(ref, size)
+	res, err := s.store.Get(ctx, key)
+	if err == blob.ErrNotFound {
+		e := errors.NewAvatarNotFoundError()
+		level.Error(logger).Log("ERROR: ", e)
+		return nil, "", statusError(ctx, e)
+	}
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, "", statusError(ctx, errors.NewInternalError())
+	}
+
+	logger.Log("action", "success")
+	return res, avatarContentType(key), nil
+}
+
+// deleteAvatar removes the original avatar and its thumbnails, failures only leave orphan objects so they are logged
+func (s *HTTPService) deleteAvatar(ctx context.Context, logger log.Logger, ref string) {
+	keys := []string{ref}
+	for _, size := range ThumbnailSizes {
+		keys = append(keys, thumbnailKey(ref, size))
+	}
+
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			level.Error(logger).Log("ERROR: ", err, "key", key)
+		}
+	}
+}
+
+// ImportUsers reads the CSV or JSONL rows and creates the valid users in batches, dryRun only validates the rows
+func (s *HTTPService) ImportUsers(ctx context.Context, format string, data io.Reader, dryRun bool) (bulk.Report, error) {
+	logger := log.With(s.logger, "method", "import_users")
+
+	rows, err := bulk.NewReader(format, data)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return bulk.Report{}, statusError(ctx, err.(errors.ErrorResolver))
+	}
+
+	importer := bulk.NewImporter(s.repository, bulk.DefaultBatchSize, bulk.DefaultWorkers)
+	res, err := importer.Import(ctx, rows, dryRun)
+
+	if e, ok := err.(errors.ErrorResolver); ok {
+		level.Error(logger).Log("ERROR: ", err)
+		return bulk.Report{}, statusError(ctx, e)
+	}
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return bulk.Report{}, statusError(ctx, errors.NewInternalError())
+	}
+
+	logger.Log("action", "success", "total", res.Total, "failed", res.Failed, "dry_run", dryRun)
+	return res, nil
+}
+
+// ExportUsers streams every user of the tenant, along with its details, to the writer
+func (s *HTTPService) ExportUsers(ctx context.Context, format string, w io.Writer) (int, error) {
+	logger := log.With(s.logger, "method", "export_users")
+
+	rows, err := bulk.NewWriter(format, w)
+	if e, ok := err.(errors.ErrorResolver); ok {
+		level.Error(logger).Log("ERROR: ", err)
+		return 0, statusError(ctx, e)
+	}
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return 0, statusError(ctx, errors.NewInternalError())
+	}
+
+	res, err := bulk.Export(ctx, s.repository, rows, bulk.DefaultPageSize)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err, "exported", res)
+		return res, err
+	}
+
+	logger.Log("action", "success", "total", res)
+	return res, nil
+}
@@ -41,6 +41,13 @@ func (r *RepoMock) GetUser(ctx context.Context, id int) (entities.User, error) {
 	return args.Get(0).(entities.User), args.Error(1)
 }
 
+// BatchGetUsers is a mock of the real method
+func (r *RepoMock) BatchGetUsers(ctx context.Context, ids []int) ([]entities.UserResult, error) {
+	args := r.Called(ctx, ids)
+
+	return args.Get(0).([]entities.UserResult), args.Error(1)
+}
+
 // DeleteUser is a mock of the real method
 func (r *RepoMock) DeleteUser(ctx context.Context, id int) (bool, error) {
 	args := r.Called(ctx, id)
@@ -48,6 +55,132 @@ func (r *RepoMock) DeleteUser(ctx context.Context, id int) (bool, error) {
 	return args.Bool(0), args.Error(1)
 }
 
+// AddAddress is a mock of the real method
+func (r *RepoMock) AddAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	args := r.Called(ctx, userID, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// ListAddresses is a mock of the real method
+func (r *RepoMock) ListAddresses(ctx context.Context, userID int) ([]entities.Address, error) {
+	args := r.Called(ctx, userID)
+
+	return args.Get(0).([]entities.Address), args.Error(1)
+}
+
+// GetAddress is a mock of the real method
+func (r *RepoMock) GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error) {
+	args := r.Called(ctx, userID, addressID)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// UpdateAddress is a mock of the real method
+func (r *RepoMock) UpdateAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	args := r.Called(ctx, userID, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// DeleteAddress is a mock of the real method
+func (r *RepoMock) DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error) {
+	args := r.Called(ctx, userID, addressID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// SendPhoneVerification is a mock of the real method
+func (r *RepoMock) SendPhoneVerification(ctx context.Context, userID int) (bool, error) {
+	args := r.Called(ctx, userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// VerifyPhone is a mock of the real method
+func (r *RepoMock) VerifyPhone(ctx context.Context, userID int, code string) (bool, error) {
+	args := r.Called(ctx, userID, code)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// GetUserDetails is a mock of the real method
+func (r *RepoMock) GetUserDetails(ctx context.Context, userID int) (entities.Details, error) {
+	args := r.Called(ctx, userID)
+
+	return args.Get(0).(entities.Details), args.Error(1)
+}
+
+// SetUserDetails is a mock of the real method
+func (r *RepoMock) SetUserDetails(ctx context.Context, userID int, details entities.Details, revision int64) (bool, error) {
+	args := r.Called(ctx, userID, details, revision)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// DeleteUserDetails is a mock of the real method
+func (r *RepoMock) DeleteUserDetails(ctx context.Context, userID int) (bool, error) {
+	args := r.Called(ctx, userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// TakenEmails is a mock of the real method
+func (r *RepoMock) TakenEmails(ctx context.Context, emails []string) ([]string, error) {
+	args := r.Called(ctx, emails)
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// ListUsers is a mock of the real method
+func (r *RepoMock) ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, int, error) {
+	args := r.Called(ctx, afterID, limit)
+
+	return args.Get(0).([]entities.User), args.Int(1), args.Error(2)
+}
+
+// SetAvatar is a mock of the real method
+func (r *RepoMock) SetAvatar(ctx context.Context, userID int, ref string) (bool, error) {
+	args := r.Called(ctx, userID, ref)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// GetAvatar is a mock of the real method
+func (r *RepoMock) GetAvatar(ctx context.Context, userID int) (string, error) {
+	args := r.Called(ctx, userID)
+
+	return args.String(0), args.Error(1)
+}
+
+// ListUserChanges is a mock of the real method
+func (r *RepoMock) ListUserChanges(ctx context.Context, cursor string, limit int) ([]entities.Change, string, error) {
+	args := r.Called(ctx, cursor, limit)
+
+	return args.Get(0).([]entities.Change), args.String(1), args.Error(2)
+}
+
+// ListDetailsChanges is a mock of the real method
+func (r *RepoMock) ListDetailsChanges(ctx context.Context, cursor string, limit int) ([]entities.Change, string, error) {
+	args := r.Called(ctx, cursor, limit)
+
+	return args.Get(0).([]entities.Change), args.String(1), args.Error(2)
+}
+
+// WatchUserChanges is a mock of the real method
+func (r *RepoMock) WatchUserChanges(ctx context.Context, userID int, cursor string, send func(entities.Change) error) error {
+	args := r.Called(ctx, userID, cursor, send)
+
+	return args.Error(0)
+}
+
+// WatchDetailsChanges is a mock of the real method
+func (r *RepoMock) WatchDetailsChanges(ctx context.Context, userID int, cursor string, send func(entities.Change) error) error {
+	args := r.Called(ctx, userID, cursor, send)
+
+	return args.Error(0)
+}
+
 // GenenerateDetails returns mock data to use in tests
 func GenenerateDetails() entities.Details {
 	return entities.Details{
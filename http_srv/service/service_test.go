@@ -1,14 +1,24 @@
 package service_test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/mauricioww/user_microsrv/http_srv/blob"
 	"github.com/mauricioww/user_microsrv/http_srv/entities"
 	"github.com/mauricioww/user_microsrv/http_srv/service"
+	"github.com/mauricioww/user_microsrv/tenant"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -17,7 +27,7 @@ func TestCreateUser(t *testing.T) {
 	var http_service service.HTTPServicer
 	repository_mock := new(service.RepoMock)
 	logger := log.NewLogfmtLogger(os.Stderr)
-	http_service = service.NewHTTPService(repository_mock, logger)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
 
 	testCases := []struct {
 		testName string
@@ -28,10 +38,10 @@ func TestCreateUser(t *testing.T) {
 		{
 			testName: "user created successfully",
 			data: entities.User{
-				Email:    "success@email.com",
-				Password: "qwerty",
-				Age:      23,
-				Details:  service.GenenerateDetails(),
+				Email:       "success@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
+				Details:     service.GenenerateDetails(),
 			},
 			res: 1,
 			err: nil,
@@ -39,8 +49,8 @@ func TestCreateUser(t *testing.T) {
 		{
 			testName: "no email error",
 			data: entities.User{
-				Password: "qwerty",
-				Age:      23,
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
 			},
 			res: -1,
 			err: status.Error(codes.FailedPrecondition, "Missing field 'password'"),
@@ -48,8 +58,8 @@ func TestCreateUser(t *testing.T) {
 		{
 			testName: "no password error",
 			data: entities.User{
-				Email: "success@email.com",
-				Age:   23,
+				Email:       "success@email.com",
+				DateOfBirth: "1998-05-10",
 			},
 			res: -1,
 			err: status.Error(codes.FailedPrecondition, "Missing field 'email'"),
@@ -64,7 +74,7 @@ func TestCreateUser(t *testing.T) {
 
 			// act
 			repository_mock.On("CreateUser", ctx, tc.data).Return(tc.res, tc.err)
-			res, err := http_service.CreateUser(ctx, tc.data.Email, tc.data.Password, tc.data.Age, tc.data.Details)
+			res, err := http_service.CreateUser(ctx, tc.data.Email, tc.data.Password, tc.data.DateOfBirth, tc.data.Details)
 
 			// assert
 			assert.True(service.TestErrors(err, tc.err))
@@ -77,7 +87,7 @@ func TestAuthenticate(t *testing.T) {
 	var http_service service.HTTPServicer
 	repository_mock := new(service.RepoMock)
 	logger := log.NewLogfmtLogger(os.Stderr)
-	http_service = service.NewHTTPService(repository_mock, logger)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
 
 	testCases := []struct {
 		testName string
@@ -147,7 +157,7 @@ func TestUpdateUser(t *testing.T) {
 	var http_service service.HTTPServicer
 	repository_mock := new(service.RepoMock)
 	logger := log.NewLogfmtLogger(os.Stderr)
-	http_service = service.NewHTTPService(repository_mock, logger)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
 
 	testCases := []struct {
 		testName string
@@ -160,10 +170,10 @@ func TestUpdateUser(t *testing.T) {
 			data: entities.UserUpdate{
 				UserID: 1,
 				User: entities.User{
-					Email:    "new_email@domain.com",
-					Password: "new_password",
-					Age:      23,
-					Details:  service.GenenerateDetails(),
+					Email:       "new_email@domain.com",
+					Password:    "new_password",
+					DateOfBirth: "1998-05-10",
+					Details:     service.GenenerateDetails(),
 				},
 			},
 			res: true,
@@ -174,9 +184,9 @@ func TestUpdateUser(t *testing.T) {
 			data: entities.UserUpdate{
 				UserID: 1,
 				User: entities.User{
-					Password: "new_password",
-					Age:      23,
-					Details:  service.GenenerateDetails(),
+					Password:    "new_password",
+					DateOfBirth: "1998-05-10",
+					Details:     service.GenenerateDetails(),
 				},
 			},
 			res: false,
@@ -187,9 +197,9 @@ func TestUpdateUser(t *testing.T) {
 			data: entities.UserUpdate{
 				UserID: 1,
 				User: entities.User{
-					Email:   "new_email@domain.com",
-					Age:     23,
-					Details: service.GenenerateDetails(),
+					Email:       "new_email@domain.com",
+					DateOfBirth: "1998-05-10",
+					Details:     service.GenenerateDetails(),
 				},
 			},
 			res: false,
@@ -200,10 +210,10 @@ func TestUpdateUser(t *testing.T) {
 			data: entities.UserUpdate{
 				UserID: 2,
 				User: entities.User{
-					Email:    "new_email@domain.com",
-					Password: "new_password",
-					Age:      23,
-					Details:  service.GenenerateDetails(),
+					Email:       "new_email@domain.com",
+					Password:    "new_password",
+					DateOfBirth: "1998-05-10",
+					Details:     service.GenenerateDetails(),
 				},
 			},
 			res: false,
@@ -218,7 +228,7 @@ func TestUpdateUser(t *testing.T) {
 
 		// act
 		repository_mock.On("UpdateUser", ctx, tc.data).Return(tc.res, tc.err)
-		res, err := http_service.UpdateUser(ctx, tc.data.UserID, tc.data.Email, tc.data.Password, tc.data.Age, tc.data.Details)
+		res, err := http_service.UpdateUser(ctx, tc.data.UserID, tc.data.Email, tc.data.Password, tc.data.DateOfBirth, tc.data.Details)
 
 		// assert
 		assert.Equal(tc.res, res)
@@ -230,7 +240,7 @@ func TestGetUser(t *testing.T) {
 	var http_service service.HTTPServicer
 	repository_mock := new(service.RepoMock)
 	logger := log.NewLogfmtLogger(os.Stderr)
-	http_service = service.NewHTTPService(repository_mock, logger)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
 
 	testCases := []struct {
 		testName string
@@ -242,9 +252,9 @@ func TestGetUser(t *testing.T) {
 			testName: "user found success",
 			data:     1,
 			res: entities.User{
-				Email:    "email@domain.com",
-				Password: "password",
-				Age:      23,
+				Email:       "email@domain.com",
+				Password:    "password",
+				DateOfBirth: "1998-05-10",
 			},
 			err: nil,
 		},
@@ -271,11 +281,72 @@ func TestGetUser(t *testing.T) {
 	}
 }
 
+func TestBatchGetUsers(t *testing.T) {
+	var http_service service.HTTPServicer
+	repository_mock := new(service.RepoMock)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
+
+	testCases := []struct {
+		testName string
+		data     []int
+		repoRes  []entities.UserResult
+		repoErr  error
+		res      []entities.UserResult
+		err      error
+	}{
+		{
+			testName: "batch get users success",
+			data:     []int{1, 2},
+			repoRes: []entities.UserResult{
+				{ID: 1, Found: true, User: entities.User{ID: 1, Email: "email@domain.com", Details: entities.Details{Avatar: "avatars/1/abc.png"}}},
+				{ID: 2, Found: false},
+			},
+			res: []entities.UserResult{
+				{ID: 1, Found: true, User: entities.User{ID: 1, Email: "email@domain.com", Details: entities.Details{Avatar: "avatars/1/abc.png", AvatarURL: "/users/1/avatar?v=abc"}}},
+				{ID: 2, Found: false},
+			},
+		},
+		{
+			testName: "empty batch error",
+			data:     []int{},
+			err:      status.Error(codes.FailedPrecondition, "Invalid batch, expected between 1 and 100 ids"),
+		},
+		{
+			testName: "too many ids error",
+			data:     make([]int, 101),
+			err:      status.Error(codes.FailedPrecondition, "Invalid batch, expected between 1 and 100 ids"),
+		},
+		{
+			testName: "repository error",
+			data:     []int{3},
+			repoErr:  status.Error(codes.Unavailable, "connection refused"),
+			err:      status.Error(codes.Unavailable, "connection refused"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repository_mock.On("BatchGetUsers", ctx, tc.data).Return(tc.repoRes, tc.repoErr)
+			res, err := http_service.BatchGetUsers(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.True(service.TestErrors(err, tc.err))
+		})
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	var http_service service.HTTPServicer
 	repository_mock := new(service.RepoMock)
 	logger := log.NewLogfmtLogger(os.Stderr)
-	http_service = service.NewHTTPService(repository_mock, logger)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
 
 	testCases := []struct {
 		testName string
@@ -311,3 +382,572 @@ func TestDeleteUser(t *testing.T) {
 		assert.True(service.TestErrors(err, tc.err))
 	}
 }
+
+func TestListAddresses(t *testing.T) {
+	var http_service service.HTTPServicer
+	repository_mock := new(service.RepoMock)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
+
+	testCases := []struct {
+		testName string
+		data     int
+		res      []entities.Address
+		err      error
+	}{
+		{
+			testName: "addresses found success",
+			data:     1,
+			res: []entities.Address{
+				{
+					ID:          "61b0c0f1e4b0a1a2b3c4d5e6",
+					Lines:       []string{"Av. Reforma 222"},
+					Locality:    "CDMX",
+					CountryCode: "MX",
+					Label:       "home",
+					Primary:     true,
+				},
+				{
+					ID:          "61b0c0f1e4b0a1a2b3c4d5e7",
+					Lines:       []string{"Insurgentes Sur 1602"},
+					Locality:    "CDMX",
+					CountryCode: "MX",
+					Label:       "work",
+				},
+			},
+			err: nil,
+		},
+		{
+			testName: "user not found error",
+			data:     2,
+			err:      status.Error(codes.NotFound, "User not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// prepare
+		ctx := context.Background()
+		assert := assert.New(t)
+
+		// act
+		repository_mock.On("ListAddresses", ctx, tc.data).Return(tc.res, tc.err)
+		res, err := http_service.ListAddresses(ctx, tc.data)
+
+		// assert
+		assert.Equal(tc.res, res)
+		assert.True(service.TestErrors(err, tc.err))
+	}
+}
+
+func TestUploadAvatar(t *testing.T) {
+	var http_service service.HTTPServicer
+	repository_mock := new(service.RepoMock)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	store, _ := blob.NewLocalStore(t.TempDir())
+	http_service = service.NewHTTPService(repository_mock, store, nil, logger)
+
+	testCases := []struct {
+		testName string
+		data     []byte
+		res      bool
+		err      error
+	}{
+		{
+			testName: "avatar uploaded success",
+			data:     generateImage(300, 200),
+			res:      true,
+			err:      nil,
+		},
+		{
+			testName: "not an image error",
+			data:     []byte("this is not an image"),
+			err:      status.Error(codes.FailedPrecondition, "Invalid avatar, expected a JPEG, PNG or GIF image"),
+		},
+		{
+			testName: "too many pixels error",
+			data:     generateImage(2500, 2000),
+			err:      status.Error(codes.FailedPrecondition, "Invalid avatar, expected a JPEG, PNG or GIF image"),
+		},
+		{
+			testName: "avatar too large error",
+			data:     make([]byte, service.MaxAvatarSize+1),
+			err:      status.Error(codes.ResourceExhausted, "Avatar exceeds the maximum size"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// prepare
+		ctx := tenant.NewContext(context.Background(), "acme")
+		assert := assert.New(t)
+		var ref string
+
+		// act
+		repository_mock.On("GetAvatar", ctx, 1).Return("", nil)
+		repository_mock.On("SetAvatar", ctx, 1, mock.AnythingOfType("string")).Return(true, nil).Run(func(args mock.Arguments) {
+			ref = args.String(2)
+		})
+		res, err := http_service.UploadAvatar(ctx, 1, bytes.NewReader(tc.data))
+
+		// assert
+		assert.True(service.TestErrors(err, tc.err))
+		assert.Equal(tc.res, strings.HasPrefix(res, "/users/1/avatar?v="))
+		if tc.res {
+			for _, size := range service.ThumbnailSizes {
+				f, err := store.Get(ctx, fmt.Sprintf("%v_%v.png", strings.TrimSuffix(ref, ".png"), size))
+				assert.Nil(err)
+				cfg, err := png.DecodeConfig(f)
+				f.Close()
+				assert.Nil(err)
+				assert.Equal(size, cfg.Width)
+				assert.Equal(size, cfg.Height)
+			}
+		}
+	}
+}
+
+func TestGetAvatar(t *testing.T) {
+	var http_service service.HTTPServicer
+	repository_mock := new(service.RepoMock)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	store, _ := blob.NewLocalStore(t.TempDir())
+	http_service = service.NewHTTPService(repository_mock, store, nil, logger)
+
+	ctx := tenant.NewContext(context.Background(), "acme")
+	store.Put(ctx, "avatars/acme/1/0a1b2c3d.png", bytes.NewReader(generateImage(10, 10)))
+	store.Put(ctx, "avatars/acme/1/0a1b2c3d_64.png", bytes.NewReader(generateImage(64, 64)))
+
+	testCases := []struct {
+		testName string
+		data     int
+		size     int
+		ref      string
+		res      string
+		err      error
+	}{
+		{
+			testName: "thumbnail found success",
+			data:     1,
+			size:     48,
+			ref:      "avatars/acme/1/0a1b2c3d.png",
+			res:      "image/png",
+			err:      nil,
+		},
+		{
+			testName: "user without avatar error",
+			data:     2,
+			err:      status.Error(codes.NotFound, "Avatar not found"),
+		},
+		{
+			testName: "stored avatar missing error",
+			data:     3,
+			ref:      "avatars/acme/3/0a1b2c3d.png",
+			err:      status.Error(codes.NotFound, "Avatar not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// prepare
+		assert := assert.New(t)
+
+		// act
+		repository_mock.On("GetAvatar", ctx, tc.data).Return(tc.ref, nil)
+		avatar, res, err := http_service.GetAvatar(ctx, tc.data, tc.size)
+
+		// assert
+		assert.Equal(tc.res, res)
+		assert.True(service.TestErrors(err, tc.err))
+		if avatar != nil {
+			cfg, err := png.DecodeConfig(avatar)
+			avatar.Close()
+			assert.Nil(err)
+			assert.Equal(64, cfg.Width)
+		}
+	}
+}
+
+func generateImage(width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestListChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	var http_service service.HTTPServicer
+	repository_mock := new(service.RepoMock)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
+
+	ctx := context.Background()
+	at := func(second int) time.Time {
+		return time.Date(2022, 3, 1, 10, 0, second, 0, time.UTC)
+	}
+
+	u1 := entities.Change{ID: "u1", Source: entities.UserSource, Type: "UserCreated", UserID: 1, OccurredAt: at(1), Cursor: "user-1"}
+	u2 := entities.Change{ID: "u2", Source: entities.UserSource, Type: "UserUpdated", UserID: 1, OccurredAt: at(3), Cursor: "user-2"}
+	d1 := entities.Change{ID: "d1", Source: entities.DetailsSource, Type: "DetailsChanged", UserID: 1, OccurredAt: at(2), Cursor: "details-1"}
+	d2 := entities.Change{ID: "d2", Source: entities.DetailsSource, Type: "DetailsChanged", UserID: 1, OccurredAt: at(4), Cursor: "details-2"}
+
+	repository_mock.On("ListUserChanges", ctx, "", 3).Return([]entities.Change{u1, u2}, "user-2", nil)
+	repository_mock.On("ListDetailsChanges", ctx, "", 3).Return([]entities.Change{d1, d2}, "details-2", nil)
+	repository_mock.On("ListUserChanges", ctx, "user-2", 3).Return([]entities.Change{}, "user-2", nil)
+	repository_mock.On("ListDetailsChanges", ctx, "details-1", 3).Return([]entities.Change{d2}, "details-2", nil)
+	repository_mock.On("ListDetailsChanges", ctx, "details-2", 3).Return([]entities.Change{}, "details-2", nil)
+
+	// act
+	first, next, err := http_service.ListChanges(ctx, "", 3)
+	second, last, secondErr := http_service.ListChanges(ctx, next, 3)
+	empty, same, emptyErr := http_service.ListChanges(ctx, last, 3)
+	_, _, invalidErr := http_service.ListChanges(ctx, "not-a-cursor", 3)
+
+	// assert
+	assert.NoError(err)
+	assert.NoError(secondErr)
+	assert.NoError(emptyErr)
+
+	ids := func(changes []entities.Change) []string {
+		var res []string
+		for _, c := range changes {
+			res = append(res, c.ID)
+		}
+		return res
+	}
+
+	assert.Equal([]string{"u1", "d1", "u2"}, ids(first))
+	assert.Equal(next, first[2].Cursor)
+	assert.Equal([]string{"d2"}, ids(second))
+	assert.Equal(last, second[0].Cursor)
+	assert.Empty(empty)
+	assert.Equal(last, same)
+	assert.True(service.TestErrors(invalidErr, status.Error(codes.FailedPrecondition, "Invalid or unknown cursor")))
+}
+
+func TestWatchUser(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	var http_service service.HTTPServicer
+	repository_mock := new(service.RepoMock)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
+
+	ctx := context.Background()
+	u1 := entities.Change{ID: "u1", Source: entities.UserSource, Type: "UserUpdated", UserID: 1, Cursor: "user-1"}
+	d1 := entities.Change{ID: "d1", Source: entities.DetailsSource, Type: "DetailsChanged", UserID: 1, Cursor: "details-1"}
+	released := make(chan struct{})
+
+	sendAll := func(changes ...entities.Change) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			send := args.Get(3).(func(entities.Change) error)
+			for _, c := range changes {
+				_ = send(c)
+			}
+			<-released
+		}
+	}
+
+	repository_mock.On("GetUser", mock.Anything, 1).Return(entities.User{ID: 1}, nil)
+	repository_mock.On("GetUser", mock.Anything, 2).Return(entities.User{}, status.Error(codes.NotFound, "User not found"))
+	repository_mock.On("WatchUserChanges", mock.Anything, 1, "user-0", mock.Anything).Run(sendAll(u1)).Return(nil)
+	repository_mock.On("WatchDetailsChanges", mock.Anything, 1, "", mock.Anything).Run(sendAll(d1)).Return(nil)
+	repository_mock.On("WatchUserChanges", mock.Anything, 1, "user-1", mock.Anything).Run(sendAll()).Return(nil)
+	repository_mock.On("WatchDetailsChanges", mock.Anything, 1, "details-1", mock.Anything).Run(sendAll()).Return(nil)
+
+	// act
+	changes, err := http_service.WatchUser(ctx, 1, "eyJ2IjoyLCJ1IjoidXNlci0wIn0")
+	var res []entities.Change
+	for c := range changes {
+		res = append(res, c)
+		if len(res) == 2 {
+			close(released)
+		}
+	}
+
+	resumed, resumeErr := http_service.WatchUser(ctx, 1, res[1].Cursor)
+	for range resumed {
+	}
+	_, notFoundErr := http_service.WatchUser(ctx, 2, "")
+	_, invalidErr := http_service.WatchUser(ctx, 1, "not-a-cursor")
+
+	// assert
+	assert.NoError(err)
+	assert.NoError(resumeErr)
+	assert.Len(res, 2)
+	repository_mock.AssertCalled(t, "WatchUserChanges", mock.Anything, 1, "user-1", mock.Anything)
+	repository_mock.AssertCalled(t, "WatchDetailsChanges", mock.Anything, 1, "details-1", mock.Anything)
+	assert.True(service.TestErrors(notFoundErr, status.Error(codes.NotFound, "User not found")))
+	assert.True(service.TestErrors(invalidErr, status.Error(codes.FailedPrecondition, "Invalid or unknown cursor")))
+}
+
+func TestAuthorizeUser(t *testing.T) {
+	var http_service service.HTTPServicer
+	repository_mock := new(service.RepoMock)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	http_service = service.NewHTTPService(repository_mock, nil, nil, logger)
+
+	repository_mock.On("Authenticate", mock.Anything, entities.Session{Email: "owner@email.com", Password: "qwerty"}).Return(true, nil)
+	repository_mock.On("Authenticate", mock.Anything, entities.Session{Email: "owner@email.com", Password: "wrong"}).Return(false, nil)
+	repository_mock.On("Authenticate", mock.Anything, entities.Session{Email: "ghost@email.com", Password: "qwerty"}).Return(false, status.Error(codes.NotFound, "User not found"))
+	repository_mock.On("GetUser", mock.Anything, 1).Return(entities.User{ID: 1, Email: "Owner@email.com"}, nil)
+	repository_mock.On("GetUser", mock.Anything, 2).Return(entities.User{ID: 2, Email: "other@email.com"}, nil)
+	repository_mock.On("GetUser", mock.Anything, 3).Return(entities.User{}, status.Error(codes.NotFound, "User not found"))
+
+	testCases := []struct {
+		testName string
+		userID   int
+		email    string
+		pwd      string
+		code     codes.Code
+	}{
+		{
+			testName: "owner authorized success",
+			userID:   1,
+			email:    "owner@email.com",
+			pwd:      "qwerty",
+			code:     codes.OK,
+		},
+		{
+			testName: "missing credentials error",
+			userID:   1,
+			code:     codes.Unauthenticated,
+		},
+		{
+			testName: "wrong password error",
+			userID:   1,
+			email:    "owner@email.com",
+			pwd:      "wrong",
+			code:     codes.Unauthenticated,
+		},
+		{
+			testName: "unknown email error",
+			userID:   1,
+			email:    "ghost@email.com",
+			pwd:      "qwerty",
+			code:     codes.Unauthenticated,
+		},
+		{
+			testName: "another user forbidden error",
+			userID:   2,
+			email:    "owner@email.com",
+			pwd:      "qwerty",
+			code:     codes.PermissionDenied,
+		},
+		{
+			testName: "user not found forbidden error",
+			userID:   3,
+			email:    "owner@email.com",
+			pwd:      "qwerty",
+			code:     codes.PermissionDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			err := http_service.AuthorizeUser(ctx, tc.userID, tc.email, tc.pwd)
+
+			// assert
+			assert.Equal(tc.code, status.Code(err))
+		})
+	}
+}
+
+func TestPatchUserDetails(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+
+	city := "Monterrey"
+	number := "+528112345678"
+	current := entities.Details{
+		Country:        "Mexico",
+		City:           "CDMX",
+		MobileNumber:   "+525512345678",
+		MobileVerified: true,
+		Attributes:     map[string]interface{}{"nickname": "mau", "team": "red"},
+		Revision:       5,
+	}
+	patched := entities.Details{
+		Country:        "Mexico",
+		City:           "Monterrey",
+		MobileNumber:   "+525512345678",
+		MobileVerified: true,
+		Attributes:     map[string]interface{}{"nickname": "mau", "team": "red"},
+	}
+	modified := status.Error(codes.Aborted, "User details were modified, fetch them again")
+
+	testCases := []struct {
+		testName string
+		userID   int
+		patch    entities.DetailsPatch
+		revision int64
+		prepare  func(r *service.RepoMock)
+		res      entities.Details
+		err      error
+	}{
+		{
+			testName: "details patched success",
+			userID:   1,
+			patch:    entities.DetailsPatch{City: &city, Attributes: map[string]interface{}{"team": nil}},
+			revision: entities.AnyRevision,
+			prepare: func(r *service.RepoMock) {
+				r.On("GetUserDetails", mock.Anything, 1).Return(current, nil)
+				r.On("SetUserDetails", mock.Anything, 1, entities.Details{
+					Country:        "Mexico",
+					City:           "Monterrey",
+					MobileNumber:   "+525512345678",
+					MobileVerified: true,
+					Attributes:     map[string]interface{}{"nickname": "mau"},
+					Revision:       5,
+				}, int64(5)).Return(true, nil)
+			},
+			res: entities.Details{
+				Country:        "Mexico",
+				City:           "Monterrey",
+				MobileNumber:   "+525512345678",
+				MobileVerified: true,
+				Attributes:     map[string]interface{}{"nickname": "mau"},
+			},
+			err: nil,
+		},
+		{
+			testName: "new mobile number unverified success",
+			userID:   2,
+			patch:    entities.DetailsPatch{MobileNumber: &number},
+			revision: entities.AnyRevision,
+			prepare: func(r *service.RepoMock) {
+				r.On("GetUserDetails", mock.Anything, 2).Return(current, nil)
+				r.On("SetUserDetails", mock.Anything, 2, entities.Details{
+					Country:        "Mexico",
+					City:           "CDMX",
+					MobileNumber:   "+528112345678",
+					MobileVerified: true,
+					Attributes:     map[string]interface{}{"nickname": "mau", "team": "red"},
+					Revision:       5,
+				}, int64(5)).Return(true, nil)
+			},
+			res: entities.Details{
+				Country:      "Mexico",
+				City:         "CDMX",
+				MobileNumber: "+528112345678",
+				Attributes:   map[string]interface{}{"nickname": "mau", "team": "red"},
+			},
+			err: nil,
+		},
+		{
+			testName: "missing details created success",
+			userID:   3,
+			patch:    entities.DetailsPatch{City: &city},
+			revision: entities.AnyRevision,
+			prepare: func(r *service.RepoMock) {
+				r.On("GetUserDetails", mock.Anything, 3).Return(entities.Details{}, status.Error(codes.NotFound, "Details not found"))
+				r.On("SetUserDetails", mock.Anything, 3, entities.Details{City: "Monterrey"}, int64(0)).Return(true, nil)
+			},
+			res: entities.Details{City: "Monterrey"},
+			err: nil,
+		},
+		{
+			testName: "expected revision success",
+			userID:   4,
+			patch:    entities.DetailsPatch{City: &city},
+			revision: 5,
+			prepare: func(r *service.RepoMock) {
+				saved := patched
+				saved.Revision = 5
+				r.On("GetUserDetails", mock.Anything, 4).Return(current, nil)
+				r.On("SetUserDetails", mock.Anything, 4, saved, int64(5)).Return(true, nil)
+			},
+			res: patched,
+			err: nil,
+		},
+		{
+			testName: "expected revision modified error",
+			userID:   5,
+			patch:    entities.DetailsPatch{City: &city},
+			revision: 4,
+			prepare: func(r *service.RepoMock) {
+				r.On("GetUserDetails", mock.Anything, 5).Return(current, nil)
+			},
+			err: modified,
+		},
+		{
+			testName: "concurrent write patched again success",
+			userID:   6,
+			patch:    entities.DetailsPatch{City: &city},
+			revision: entities.AnyRevision,
+			prepare: func(r *service.RepoMock) {
+				changed := current
+				changed.Married, changed.Revision = true, 6
+				saved := patched
+				saved.Revision = 5
+				savedAgain := patched
+				savedAgain.Married, savedAgain.Revision = true, 6
+
+				r.On("GetUserDetails", mock.Anything, 6).Return(current, nil).Once()
+				r.On("GetUserDetails", mock.Anything, 6).Return(changed, nil).Once()
+				r.On("SetUserDetails", mock.Anything, 6, saved, int64(5)).Return(false, modified)
+				r.On("SetUserDetails", mock.Anything, 6, savedAgain, int64(6)).Return(true, nil)
+			},
+			res: entities.Details{
+				Country:        "Mexico",
+				City:           "Monterrey",
+				MobileNumber:   "+525512345678",
+				MobileVerified: true,
+				Married:        true,
+				Attributes:     map[string]interface{}{"nickname": "mau", "team": "red"},
+			},
+			err: nil,
+		},
+		{
+			testName: "concurrent writes exhausted error",
+			userID:   7,
+			patch:    entities.DetailsPatch{City: &city},
+			revision: entities.AnyRevision,
+			prepare: func(r *service.RepoMock) {
+				r.On("GetUserDetails", mock.Anything, 7).Return(current, nil)
+				r.On("SetUserDetails", mock.Anything, 7, mock.Anything, int64(5)).Return(false, modified)
+			},
+			err: modified,
+		},
+		{
+			testName: "repository error",
+			userID:   8,
+			patch:    entities.DetailsPatch{City: &city},
+			revision: entities.AnyRevision,
+			prepare: func(r *service.RepoMock) {
+				r.On("GetUserDetails", mock.Anything, 8).Return(entities.Details{}, status.Error(codes.Unavailable, "connection refused"))
+			},
+			err: status.Error(codes.Unavailable, "connection refused"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+			repository_mock := new(service.RepoMock)
+			tc.prepare(repository_mock)
+			http_service := service.NewHTTPService(repository_mock, nil, nil, logger)
+
+			// act
+			res, err := http_service.PatchUserDetails(ctx, tc.userID, tc.patch, tc.revision)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.True(service.TestErrors(err, tc.err))
+			repository_mock.AssertExpectations(t)
+		})
+	}
+}
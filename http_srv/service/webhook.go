@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
+)
+
+// CreateWebhook subscribes the URL to the events of the tenant, the secret is generated when it is empty
+func (s *HTTPService) CreateWebhook(ctx context.Context, subscription webhook.Subscription) (webhook.Subscription, error) {
+	logger := log.With(s.logger, "method", "create_webhook")
+
+	res, err := s.webhooks.Subscribe(ctx, subscription)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return webhook.Subscription{}, webhookError(ctx, err)
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// ListWebhooks returns the webhook subscriptions of the tenant
+func (s *HTTPService) ListWebhooks(ctx context.Context) ([]webhook.Subscription, error) {
+	logger := log.With(s.logger, "method", "list_webhooks")
+
+	res, err := s.webhooks.Subscriptions(ctx)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, webhookError(ctx, err)
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// DeleteWebhook removes the webhook subscription, its past deliveries are kept
+func (s *HTTPService) DeleteWebhook(ctx context.Context, webhookID string) (bool, error) {
+	logger := log.With(s.logger, "method", "delete_webhook")
+
+	if err := s.webhooks.Unsubscribe(ctx, webhookID); err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return false, webhookError(ctx, err)
+	}
+
+	logger.Log("action", "success")
+	return true, nil
+}
+
+// ListDeliveries returns the deliveries of the webhook, an empty status returns every delivery
+func (s *HTTPService) ListDeliveries(ctx context.Context, webhookID string, deliveryStatus string) ([]webhook.Delivery, error) {
+	logger := log.With(s.logger, "method", "list_deliveries")
+
+	res, err := s.webhooks.Deliveries(ctx, webhookID, webhook.Status(deliveryStatus))
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return nil, webhookError(ctx, err)
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// ReplayDelivery sends the event of a past delivery again as a new delivery
+func (s *HTTPService) ReplayDelivery(ctx context.Context, webhookID string, deliveryID string) (webhook.Delivery, error) {
+	logger := log.With(s.logger, "method", "replay_delivery")
+
+	res, err := s.webhooks.Replay(ctx, webhookID, deliveryID)
+	if err != nil {
+		level.Error(logger).Log("ERROR: ", err)
+		return webhook.Delivery{}, webhookError(ctx, err)
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+func webhookError(ctx context.Context, err error) error {
+	if e, ok := err.(errors.ErrorResolver); ok {
+		return statusError(ctx, e)
+	}
+	return statusError(ctx, errors.NewInternalError())
+}
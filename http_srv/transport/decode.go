@@ -1,36 +1,216 @@
 package transport
 
-import "github.com/mauricioww/user_microsrv/http_srv/entities"
+import (
+	"io"
+
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+)
 
 // CreateUserRequest struct stores the data sent to users endpoint with POST action
 type CreateUserRequest struct {
-	Email            string `json:"email"`
-	Password         string `json:"password"`
-	Age              int    `json:"age"`
-	entities.Details `json:"information"`
+	entities.UserInput
 }
 
 // AuthenticateRequest struct stores the data sent to auth endpoint with POST action
 type AuthenticateRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 // UpdateUserRequest struct stores the data sent to users endpoint with PUT action
 type UpdateUserRequest struct {
-	UserID           int
-	Email            string `json:"email"`
-	Password         string `json:"password"`
-	Age              int    `json:"age"`
-	entities.Details `json:"information"`
+	UserID int `validate:"min=1"`
+	BasicAuth
+	entities.UserInput
 }
 
-// GetUserRequest struct stores the data sent to users endpoint with GET action
+// GetUserRequest struct stores the data sent to users endpoint with GET action, the details are only returned
+// along with the credentials of the user
 type GetUserRequest struct {
-	UserID int
+	UserID int `validate:"min=1"`
+	BasicAuth
+}
+
+// BasicAuth struct stores the HTTP basic credentials sent with the request, they never come from the body
+type BasicAuth struct {
+	Email    string `json:"-"`
+	Password string `json:"-"`
+}
+
+// ownedRequest is implemented by the requests which read or write the details, the addresses, the phone or the avatar,
+// only the owner of the resource can send them
+type ownedRequest interface {
+	owner() (int, BasicAuth)
+}
+
+// GetUserDetailsRequest struct stores the data sent to details endpoint with GET action
+type GetUserDetailsRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+}
+
+// SetUserDetailsRequest struct stores the data sent to details endpoint with PUT action
+type SetUserDetailsRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	entities.Details
+}
+
+// PatchUserDetailsRequest struct stores the data sent to details endpoint with PATCH action, Revision is the one
+// of the If-Match header and entities.AnyRevision without it
+type PatchUserDetailsRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	Revision int64 `json:"-"`
+	entities.DetailsPatch
+}
+
+// DeleteUserDetailsRequest struct stores the data sent to details endpoint with DELETE action
+type DeleteUserDetailsRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+}
+
+func (r GetUserRequest) owner() (int, BasicAuth)               { return r.UserID, r.BasicAuth }
+func (r UpdateUserRequest) owner() (int, BasicAuth)            { return r.UserID, r.BasicAuth }
+func (r DeleteUserRequest) owner() (int, BasicAuth)            { return r.UserID, r.BasicAuth }
+func (r GetUserDetailsRequest) owner() (int, BasicAuth)        { return r.UserID, r.BasicAuth }
+func (r SetUserDetailsRequest) owner() (int, BasicAuth)        { return r.UserID, r.BasicAuth }
+func (r PatchUserDetailsRequest) owner() (int, BasicAuth)      { return r.UserID, r.BasicAuth }
+func (r DeleteUserDetailsRequest) owner() (int, BasicAuth)     { return r.UserID, r.BasicAuth }
+func (r WatchUserRequest) owner() (int, BasicAuth)             { return r.UserID, r.BasicAuth }
+func (r AddAddressRequest) owner() (int, BasicAuth)            { return r.UserID, r.BasicAuth }
+func (r ListAddressesRequest) owner() (int, BasicAuth)         { return r.UserID, r.BasicAuth }
+func (r GetAddressRequest) owner() (int, BasicAuth)            { return r.UserID, r.BasicAuth }
+func (r UpdateAddressRequest) owner() (int, BasicAuth)         { return r.UserID, r.BasicAuth }
+func (r DeleteAddressRequest) owner() (int, BasicAuth)         { return r.UserID, r.BasicAuth }
+func (r SendPhoneVerificationRequest) owner() (int, BasicAuth) { return r.UserID, r.BasicAuth }
+func (r VerifyPhoneRequest) owner() (int, BasicAuth)           { return r.UserID, r.BasicAuth }
+func (r UploadAvatarRequest) owner() (int, BasicAuth)          { return r.UserID, r.BasicAuth }
+
+// BatchGetUsersRequest struct stores the data sent to users:batchGet endpoint with POST action, the details are only
+// returned for the user of the credentials
+type BatchGetUsersRequest struct {
+	UserIDs []int `json:"ids"`
+	BasicAuth
 }
 
 // DeleteUserRequest struct stores the data sent to users endpoint with DELETE action
 type DeleteUserRequest struct {
-	UserID int
+	UserID int `validate:"min=1"`
+	BasicAuth
+}
+
+// AddAddressRequest struct stores the data sent to addresses endpoint with POST action
+type AddAddressRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	entities.Address
+}
+
+// ListAddressesRequest struct stores the data sent to addresses endpoint with GET action
+type ListAddressesRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+}
+
+// GetAddressRequest struct stores the data sent to address endpoint with GET action
+type GetAddressRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	AddressID string `validate:"required"`
+}
+
+// UpdateAddressRequest struct stores the data sent to address endpoint with PUT action
+type UpdateAddressRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	entities.Address
+}
+
+// DeleteAddressRequest struct stores the data sent to address endpoint with DELETE action
+type DeleteAddressRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	AddressID string `validate:"required"`
+}
+
+// SendPhoneVerificationRequest struct stores the data sent to phone verification endpoint with POST action
+type SendPhoneVerificationRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+}
+
+// VerifyPhoneRequest struct stores the data sent to phone verification confirm endpoint with POST action
+type VerifyPhoneRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	Code string `json:"code" validate:"required"`
+}
+
+// UploadAvatarRequest struct stores the data sent to avatar endpoint with POST action
+type UploadAvatarRequest struct {
+	UserID int `validate:"min=1"`
+	BasicAuth
+	Avatar io.Reader
+}
+
+// GetAvatarRequest struct stores the data sent to avatar endpoint with GET action
+type GetAvatarRequest struct {
+	UserID int `validate:"min=1"`
+	Size   int
+}
+
+// ImportUsersRequest struct stores the data sent to users import endpoint with POST action
+type ImportUsersRequest struct {
+	Format string
+	DryRun bool
+	Data   io.Reader
+}
+
+// ExportUsersRequest struct stores the data sent to users export endpoint with GET action
+type ExportUsersRequest struct {
+	Format string
+}
+
+// CreateWebhookRequest struct stores the data sent to webhooks endpoint with POST action
+type CreateWebhookRequest struct {
+	URL    string        `json:"url"`
+	Events []events.Type `json:"events"`
+	Secret string        `json:"secret"`
+}
+
+// ListWebhooksRequest struct stores the data sent to webhooks endpoint with GET action
+type ListWebhooksRequest struct{}
+
+// DeleteWebhookRequest struct stores the data sent to webhook endpoint with DELETE action
+type DeleteWebhookRequest struct {
+	WebhookID string
+}
+
+// ListDeliveriesRequest struct stores the data sent to webhook deliveries endpoint with GET action
+type ListDeliveriesRequest struct {
+	WebhookID string
+	Status    string
+}
+
+// ReplayDeliveryRequest struct stores the data sent to delivery replay endpoint with POST action
+type ReplayDeliveryRequest struct {
+	WebhookID  string
+	DeliveryID string
+}
+
+// ListChangesRequest struct stores the data sent to changes endpoint with GET action, an invalid page size takes the default one
+type ListChangesRequest struct {
+	Cursor   string
+	PageSize int
+}
+
+// WatchUserRequest struct stores the data sent to user events endpoint with GET action, LastEventID resumes the stream
+// and the data of the details changes is only streamed along with the credentials of the user
+type WatchUserRequest struct {
+	UserID      int `validate:"min=1"`
+	LastEventID string
+	BasicAuth
 }
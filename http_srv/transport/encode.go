@@ -1,13 +1,22 @@
 package transport
 
-import "github.com/mauricioww/user_microsrv/http_srv/entities"
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
+)
 
 // CreateUserResponse struct stores the data that users endpoint, with POST action, will return
 type CreateUserResponse struct {
 	UserID           int    `json:"user_id"`
 	Email            string `json:"email"`
 	Password         string `json:"password"`
-	Age              int    `json:"age"`
+	DateOfBirth      string `json:"date_of_birth"`
 	entities.Details `json:"information"`
 }
 
@@ -26,11 +35,200 @@ type GetUserResponse struct {
 	UserID           int    `json:"user_id"`
 	Email            string `json:"email"`
 	Password         string `json:"password"`
+	DateOfBirth      string `json:"date_of_birth,omitempty"`
+	Age              int    `json:"age"`
+	entities.Details `json:"information"`
+	DetailsStatus    string `json:"details_status,omitempty"`
+}
+
+// Headers warns the clients when the user is returned without its details
+func (r GetUserResponse) Headers() http.Header {
+	h := http.Header{}
+	if r.DetailsStatus != "" {
+		h.Set("Warning", fmt.Sprintf(`199 - "user details %v"`, r.DetailsStatus))
+	}
+	return h
+}
+
+// CreateUserResponseV2 struct stores the data that v2 users endpoint, with POST action, will return
+type CreateUserResponseV2 struct {
+	UserID           int    `json:"user_id"`
+	Email            string `json:"email"`
+	DateOfBirth      string `json:"date_of_birth"`
+	entities.Details `json:"information"`
+}
+
+// GetUserResponseV2 struct stores the data that v2 users endpoint, with GET action, will return
+type GetUserResponseV2 struct {
+	UserID           int    `json:"user_id"`
+	Email            string `json:"email"`
+	DateOfBirth      string `json:"date_of_birth,omitempty"`
+	Age              int    `json:"age"`
+	entities.Details `json:"information"`
+	DetailsStatus    string `json:"details_status,omitempty"`
+}
+
+// Headers warns the clients when the user is returned without its details
+func (r GetUserResponseV2) Headers() http.Header {
+	return GetUserResponse{DetailsStatus: r.DetailsStatus}.Headers()
+}
+
+// BatchedUser struct stores one of the users that users:batchGet endpoint, with POST action, will return
+type BatchedUser struct {
+	Email            string `json:"email"`
+	DateOfBirth      string `json:"date_of_birth,omitempty"`
 	Age              int    `json:"age"`
 	entities.Details `json:"information"`
+	DetailsStatus    string `json:"details_status,omitempty"`
+}
+
+// BatchGetUserResult struct stores the result of one of the requested ids, User is omitted when it was not found
+type BatchGetUserResult struct {
+	UserID int          `json:"user_id"`
+	Found  bool         `json:"found"`
+	User   *BatchedUser `json:"user,omitempty"`
+}
+
+// BatchGetUsersResponse struct stores the data that users:batchGet endpoint, with POST action, will return
+type BatchGetUsersResponse struct {
+	Results []BatchGetUserResult `json:"results"`
 }
 
 // DeleteUserResponse struct stores the data that users endpoint, with DELETE action, will return
 type DeleteUserResponse struct {
 	Success bool `json:"success"`
 }
+
+// GetUserDetailsResponse struct stores the data that details endpoint, with GET action, will return
+type GetUserDetailsResponse struct {
+	entities.Details
+}
+
+// Headers tags the details with their revision, the patches send it back as If-Match
+func (r GetUserDetailsResponse) Headers() http.Header {
+	h := http.Header{}
+	h.Set("ETag", entityTag(r.Revision))
+	return h
+}
+
+// SetUserDetailsResponse struct stores the data that details endpoint, with PUT action, will return
+type SetUserDetailsResponse struct {
+	Success bool `json:"success"`
+}
+
+// PatchUserDetailsResponse struct stores the data that details endpoint, with PATCH action, will return
+type PatchUserDetailsResponse struct {
+	entities.Details
+}
+
+// DeleteUserDetailsResponse struct stores the data that details endpoint, with DELETE action, will return
+type DeleteUserDetailsResponse struct {
+	Success bool `json:"success"`
+}
+
+// AddAddressResponse struct stores the data that addresses endpoint, with POST action, will return
+type AddAddressResponse struct {
+	entities.Address
+}
+
+// ListAddressesResponse struct stores the data that addresses endpoint, with GET action, will return
+type ListAddressesResponse struct {
+	Addresses []entities.Address `json:"addresses"`
+}
+
+// GetAddressResponse struct stores the data that address endpoint, with GET action, will return
+type GetAddressResponse struct {
+	entities.Address
+}
+
+// UpdateAddressResponse struct stores the data that address endpoint, with PUT action, will return
+type UpdateAddressResponse struct {
+	entities.Address
+}
+
+// DeleteAddressResponse struct stores the data that address endpoint, with DELETE action, will return
+type DeleteAddressResponse struct {
+	Success bool `json:"success"`
+}
+
+// SendPhoneVerificationResponse struct stores the data that phone verification endpoint, with POST action, will return
+type SendPhoneVerificationResponse struct {
+	Success bool `json:"success"`
+}
+
+// VerifyPhoneResponse struct stores the data that phone verification confirm endpoint, with POST action, will return
+type VerifyPhoneResponse struct {
+	Success bool `json:"success"`
+}
+
+// UploadAvatarResponse struct stores the data that avatar endpoint, with POST action, will return
+type UploadAvatarResponse struct {
+	AvatarURL string `json:"avatar_url"`
+}
+
+// GetAvatarResponse struct stores the image that avatar endpoint, with GET action, will return
+type GetAvatarResponse struct {
+	ContentType string
+	Avatar      io.ReadCloser
+}
+
+// ImportUsersResponse struct stores the report that users import endpoint, with POST action, will return
+type ImportUsersResponse struct {
+	bulk.Report
+}
+
+// ExportUsersResponse struct stores the stream that users export endpoint, with GET action, will write
+type ExportUsersResponse struct {
+	Format string
+	Export func(w io.Writer) error
+}
+
+// CreateWebhookResponse struct stores the data that webhooks endpoint, with POST action, will return,
+// it is the only response which carries the secret
+type CreateWebhookResponse struct {
+	webhook.Subscription
+}
+
+// ListWebhooksResponse struct stores the data that webhooks endpoint, with GET action, will return
+type ListWebhooksResponse struct {
+	Webhooks []webhook.Subscription `json:"webhooks"`
+}
+
+// DeleteWebhookResponse struct stores the data that webhook endpoint, with DELETE action, will return
+type DeleteWebhookResponse struct {
+	Success bool `json:"success"`
+}
+
+// ListDeliveriesResponse struct stores the data that webhook deliveries endpoint, with GET action, will return
+type ListDeliveriesResponse struct {
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+// ReplayDeliveryResponse struct stores the data that delivery replay endpoint, with POST action, will return
+type ReplayDeliveryResponse struct {
+	webhook.Delivery
+}
+
+// ListChangesResponse struct stores the data that changes endpoint, with GET action, will return
+type ListChangesResponse struct {
+	Changes    []entities.Change `json:"changes"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+// WatchUserResponse struct stores the changes that user events endpoint, with GET action, will stream, the data of
+// the details changes is withheld from the anonymous clients
+type WatchUserResponse struct {
+	Changes   <-chan entities.Change
+	Anonymous bool
+}
+
+// ProblemResponse struct stores the RFC 7807 problem that every endpoint returns on error, Reason is stable so clients
+// can rely on it while Detail is meant for humans
+type ProblemResponse struct {
+	Type       string                  `json:"type"`
+	Title      string                  `json:"title"`
+	Status     int                     `json:"status"`
+	Detail     string                  `json:"detail"`
+	Reason     string                  `json:"reason,omitempty"`
+	Violations []errors.FieldViolation `json:"violations,omitempty"`
+}
@@ -2,9 +2,14 @@ package transport
 
 import (
 	"context"
+	"io"
+	"strings"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
 	"github.com/mauricioww/user_microsrv/http_srv/service"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
+	"github.com/mauricioww/user_microsrv/validation"
 )
 
 // HTTPEndpoints stores the endpoins of the current service
@@ -14,24 +19,92 @@ type HTTPEndpoints struct {
 	UpdateUser   endpoint.Endpoint
 	GetUser      endpoint.Endpoint
 	DeleteUser   endpoint.Endpoint
+
+	GetUserDetails    endpoint.Endpoint
+	SetUserDetails    endpoint.Endpoint
+	PatchUserDetails  endpoint.Endpoint
+	DeleteUserDetails endpoint.Endpoint
+
+	BatchGetUsers endpoint.Endpoint
+
+	AddAddress    endpoint.Endpoint
+	ListAddresses endpoint.Endpoint
+	GetAddress    endpoint.Endpoint
+	UpdateAddress endpoint.Endpoint
+	DeleteAddress endpoint.Endpoint
+
+	SendPhoneVerification endpoint.Endpoint
+	VerifyPhone           endpoint.Endpoint
+
+	UploadAvatar endpoint.Endpoint
+	GetAvatar    endpoint.Endpoint
+
+	ImportUsers endpoint.Endpoint
+	ExportUsers endpoint.Endpoint
+
+	CreateWebhook  endpoint.Endpoint
+	ListWebhooks   endpoint.Endpoint
+	DeleteWebhook  endpoint.Endpoint
+	ListDeliveries endpoint.Endpoint
+	ReplayDelivery endpoint.Endpoint
+
+	ListChanges endpoint.Endpoint
+	WatchUser   endpoint.Endpoint
 }
 
-// MakeHTTPEndpoints build the custom endpoints for the http service
+// MakeHTTPEndpoints build the custom endpoints for the http service, the requests are validated before reaching
+// the service and the details, the addresses, the phone and the avatar upload are only reached by their owner, whose
+// credentials are checked before anything else
 func MakeHTTPEndpoints(httpSrv service.HTTPServicer) HTTPEndpoints {
+	validate := validation.Middleware()
+	authorize := authorizeOwner(httpSrv, false)
+	authorizeReader := authorizeOwner(httpSrv, true)
+
 	return HTTPEndpoints{
-		CreateUser:   makeCreateUserEndpoint(httpSrv),
-		Authenticate: makeAuthenticateEndpoint(httpSrv),
-		UpdateUser:   makeUpdateUserEndpoint(httpSrv),
-		GetUser:      makeGetUserEndpoint(httpSrv),
-		DeleteUser:   makeDeleteUserEndpont(httpSrv),
+		CreateUser:   validate(makeCreateUserEndpoint(httpSrv)),
+		Authenticate: validate(makeAuthenticateEndpoint(httpSrv)),
+		UpdateUser:   authorize(validate(makeUpdateUserEndpoint(httpSrv))),
+		GetUser:      authorizeReader(validate(makeGetUserEndpoint(httpSrv))),
+		DeleteUser:   authorize(validate(makeDeleteUserEndpont(httpSrv))),
+
+		GetUserDetails:    authorize(validate(makeGetUserDetailsEndpoint(httpSrv))),
+		SetUserDetails:    authorize(validate(makeSetUserDetailsEndpoint(httpSrv))),
+		PatchUserDetails:  authorize(validate(makePatchUserDetailsEndpoint(httpSrv))),
+		DeleteUserDetails: authorize(validate(makeDeleteUserDetailsEndpoint(httpSrv))),
+
+		BatchGetUsers: validate(makeBatchGetUsersEndpoint(httpSrv)),
+
+		AddAddress:    authorize(validate(makeAddAddressEndpoint(httpSrv))),
+		ListAddresses: authorize(validate(makeListAddressesEndpoint(httpSrv))),
+		GetAddress:    authorize(validate(makeGetAddressEndpoint(httpSrv))),
+		UpdateAddress: authorize(validate(makeUpdateAddressEndpoint(httpSrv))),
+		DeleteAddress: authorize(validate(makeDeleteAddressEndpoint(httpSrv))),
+
+		SendPhoneVerification: authorize(validate(makeSendPhoneVerificationEndpoint(httpSrv))),
+		VerifyPhone:           authorize(validate(makeVerifyPhoneEndpoint(httpSrv))),
+
+		UploadAvatar: authorize(validate(makeUploadAvatarEndpoint(httpSrv))),
+		GetAvatar:    validate(makeGetAvatarEndpoint(httpSrv)),
+
+		ImportUsers: validate(makeImportUsersEndpoint(httpSrv)),
+		ExportUsers: validate(makeExportUsersEndpoint(httpSrv)),
+
+		CreateWebhook:  validate(makeCreateWebhookEndpoint(httpSrv)),
+		ListWebhooks:   validate(makeListWebhooksEndpoint(httpSrv)),
+		DeleteWebhook:  validate(makeDeleteWebhookEndpoint(httpSrv)),
+		ListDeliveries: validate(makeListDeliveriesEndpoint(httpSrv)),
+		ReplayDelivery: validate(makeReplayDeliveryEndpoint(httpSrv)),
+
+		ListChanges: validate(makeListChangesEndpoint(httpSrv)),
+		WatchUser:   authorizeReader(validate(makeWatchUserEndpoint(httpSrv))),
 	}
 }
 
 func makeCreateUserEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(CreateUserRequest)
-		res, err := httpSrv.CreateUser(ctx, req.Email, req.Password, req.Age, req.Details)
-		return CreateUserResponse{UserID: res, Email: req.Email, Password: req.Password, Age: req.Age, Details: req.Details}, err
+		res, err := httpSrv.CreateUser(ctx, req.Email, req.Password, req.DateOfBirth, req.Details)
+		return CreateUserResponse{UserID: res, Email: req.Email, Password: req.Password, DateOfBirth: req.DateOfBirth, Details: req.Details}, err
 	}
 }
 
@@ -46,7 +119,7 @@ func makeAuthenticateEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
 func makeUpdateUserEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(UpdateUserRequest)
-		res, err := httpSrv.UpdateUser(ctx, req.UserID, req.Email, req.Password, req.Age, req.Details)
+		res, err := httpSrv.UpdateUser(ctx, req.UserID, req.UserInput.Email, req.UserInput.Password, req.DateOfBirth, req.Details)
 		return UpdateUserResponse{Success: res}, err
 	}
 }
@@ -55,7 +128,108 @@ func makeGetUserEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(GetUserRequest)
 		res, err := httpSrv.GetUser(ctx, req.UserID)
-		return GetUserResponse{UserID: req.UserID, Email: res.Email, Password: res.Password, Age: res.Age, Details: res.Details}, err
+		if req.BasicAuth == (BasicAuth{}) {
+			res = withheld(res)
+		}
+		return GetUserResponse{UserID: req.UserID, Email: res.Email, Password: res.Password, DateOfBirth: res.DateOfBirth, Age: res.Age, Details: res.Details, DetailsStatus: res.DetailsStatus}, err
+	}
+}
+
+// authorizeOwner rejects the requests whose credentials do not belong to the user who owns the resource, the
+// anonymous requests are let through when they are allowed since their endpoints withhold the details from them
+func authorizeOwner(httpSrv service.HTTPServicer, allowAnonymous bool) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			userID, credentials := request.(ownedRequest).owner()
+			if allowAnonymous && credentials == (BasicAuth{}) {
+				return next(ctx, request)
+			}
+
+			if err := httpSrv.AuthorizeUser(ctx, userID, credentials.Email, credentials.Password); err != nil {
+				return nil, err
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// withheld returns the user without its details, they are only returned to their owner
+func withheld(user entities.User) entities.User {
+	user.Details = entities.Details{}
+	user.DetailsStatus = entities.DetailsWithheld
+	return user
+}
+
+// withheldChange returns the change without the data of the details, it is only sent to their owner and the admins
+func withheldChange(c entities.Change) entities.Change {
+	if c.Source == entities.DetailsSource {
+		c.Data = nil
+	}
+	return c
+}
+
+func makeGetUserDetailsEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetUserDetailsRequest)
+		res, err := httpSrv.GetUserDetails(ctx, req.UserID)
+		return GetUserDetailsResponse{Details: res}, err
+	}
+}
+
+func makeSetUserDetailsEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SetUserDetailsRequest)
+		res, err := httpSrv.SetUserDetails(ctx, req.UserID, req.Details)
+		return SetUserDetailsResponse{Success: res}, err
+	}
+}
+
+func makePatchUserDetailsEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PatchUserDetailsRequest)
+		res, err := httpSrv.PatchUserDetails(ctx, req.UserID, req.DetailsPatch, req.Revision)
+		return PatchUserDetailsResponse{Details: res}, err
+	}
+}
+
+func makeDeleteUserDetailsEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteUserDetailsRequest)
+		res, err := httpSrv.DeleteUserDetails(ctx, req.UserID)
+		return DeleteUserDetailsResponse{Success: res}, err
+	}
+}
+
+func makeBatchGetUsersEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(BatchGetUsersRequest)
+		res, err := httpSrv.BatchGetUsers(ctx, req.UserIDs)
+
+		// the details are only returned for the user of the credentials
+		owner := 0
+		for _, r := range res {
+			if req.BasicAuth != (BasicAuth{}) && r.Found && strings.EqualFold(r.User.Email, req.Email) {
+				if err := httpSrv.AuthorizeUser(ctx, r.ID, req.Email, req.Password); err != nil {
+					return nil, err
+				}
+				owner = r.ID
+				break
+			}
+		}
+
+		results := make([]BatchGetUserResult, len(res))
+		for i, r := range res {
+			results[i] = BatchGetUserResult{UserID: r.ID, Found: r.Found}
+			if r.ID != owner {
+				r.User = withheld(r.User)
+			}
+			if r.Found {
+				results[i].User = &BatchedUser{Email: r.User.Email, DateOfBirth: r.User.DateOfBirth, Age: r.User.Age, Details: r.User.Details, DetailsStatus: r.User.DetailsStatus}
+			}
+		}
+
+		return BatchGetUsersResponse{Results: results}, err
 	}
 }
 
@@ -66,3 +240,157 @@ func makeDeleteUserEndpont(httpSrv service.HTTPServicer) endpoint.Endpoint {
 		return DeleteUserResponse{Success: res}, err
 	}
 }
+
+func makeAddAddressEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(AddAddressRequest)
+		res, err := httpSrv.AddAddress(ctx, req.UserID, req.Address)
+		return AddAddressResponse{Address: res}, err
+	}
+}
+
+func makeListAddressesEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListAddressesRequest)
+		res, err := httpSrv.ListAddresses(ctx, req.UserID)
+		return ListAddressesResponse{Addresses: res}, err
+	}
+}
+
+func makeGetAddressEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetAddressRequest)
+		res, err := httpSrv.GetAddress(ctx, req.UserID, req.AddressID)
+		return GetAddressResponse{Address: res}, err
+	}
+}
+
+func makeUpdateAddressEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(UpdateAddressRequest)
+		res, err := httpSrv.UpdateAddress(ctx, req.UserID, req.Address)
+		return UpdateAddressResponse{Address: res}, err
+	}
+}
+
+func makeDeleteAddressEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(DeleteAddressRequest)
+		res, err := httpSrv.DeleteAddress(ctx, req.UserID, req.AddressID)
+		return DeleteAddressResponse{Success: res}, err
+	}
+}
+
+func makeSendPhoneVerificationEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(SendPhoneVerificationRequest)
+		res, err := httpSrv.SendPhoneVerification(ctx, req.UserID)
+		return SendPhoneVerificationResponse{Success: res}, err
+	}
+}
+
+func makeVerifyPhoneEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(VerifyPhoneRequest)
+		res, err := httpSrv.VerifyPhone(ctx, req.UserID, req.Code)
+		return VerifyPhoneResponse{Success: res}, err
+	}
+}
+
+func makeUploadAvatarEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(UploadAvatarRequest)
+		res, err := httpSrv.UploadAvatar(ctx, req.UserID, req.Avatar)
+		return UploadAvatarResponse{AvatarURL: res}, err
+	}
+}
+
+func makeGetAvatarEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetAvatarRequest)
+		res, contentType, err := httpSrv.GetAvatar(ctx, req.UserID, req.Size)
+		return GetAvatarResponse{ContentType: contentType, Avatar: res}, err
+	}
+}
+
+func makeImportUsersEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ImportUsersRequest)
+		res, err := httpSrv.ImportUsers(ctx, req.Format, req.Data, req.DryRun)
+		return ImportUsersResponse{Report: res}, err
+	}
+}
+
+// makeExportUsersEndpoint defers the export until the encoder has the response writer, so rows are streamed
+func makeExportUsersEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ExportUsersRequest)
+		export := func(w io.Writer) error {
+			_, err := httpSrv.ExportUsers(ctx, req.Format, w)
+			return err
+		}
+		return ExportUsersResponse{Format: req.Format, Export: export}, nil
+	}
+}
+
+func makeCreateWebhookEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(CreateWebhookRequest)
+		res, err := httpSrv.CreateWebhook(ctx, webhook.Subscription{URL: req.URL, Events: req.Events, Secret: req.Secret})
+		return CreateWebhookResponse{Subscription: res}, err
+	}
+}
+
+// makeListWebhooksEndpoint hides the secrets, they are only returned when the webhook is created
+func makeListWebhooksEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		res, err := httpSrv.ListWebhooks(ctx)
+		for i := range res {
+			res[i].Secret = ""
+		}
+		return ListWebhooksResponse{Webhooks: res}, err
+	}
+}
+
+func makeDeleteWebhookEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(DeleteWebhookRequest)
+		res, err := httpSrv.DeleteWebhook(ctx, req.WebhookID)
+		return DeleteWebhookResponse{Success: res}, err
+	}
+}
+
+func makeListDeliveriesEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListDeliveriesRequest)
+		res, err := httpSrv.ListDeliveries(ctx, req.WebhookID, req.Status)
+		return ListDeliveriesResponse{Deliveries: res}, err
+	}
+}
+
+func makeReplayDeliveryEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ReplayDeliveryRequest)
+		res, err := httpSrv.ReplayDelivery(ctx, req.WebhookID, req.DeliveryID)
+		return ReplayDeliveryResponse{Delivery: res}, err
+	}
+}
+
+func makeListChangesEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListChangesRequest)
+		res, next, err := httpSrv.ListChanges(ctx, req.Cursor, req.PageSize)
+		if res == nil {
+			res = []entities.Change{}
+		}
+		return ListChangesResponse{Changes: res, NextCursor: next}, err
+	}
+}
+
+func makeWatchUserEndpoint(httpSrv service.HTTPServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(WatchUserRequest)
+		res, err := httpSrv.WatchUser(ctx, req.UserID, req.LastEventID)
+		return WatchUserResponse{Changes: res, Anonymous: req.BasicAuth == (BasicAuth{})}, err
+	}
+}
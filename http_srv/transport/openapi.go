@@ -0,0 +1,201 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
+	"github.com/mauricioww/user_microsrv/http_srv/openapi"
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/mauricioww/user_microsrv/tenant"
+)
+
+// docsPage loads the Swagger UI which renders the documents of the versions, the latest is selected first
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>User API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ urls: %s, dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+// OpenAPI returns the document of every route of the version within NewHTTPServer, the bodies are reflected from the
+// request and response types of the transport so their schemas follow the types and their validate tags, it panics
+// on an unknown version
+func OpenAPI(version string) *openapi.Document {
+	v, ok := selectVersion(version)
+	if !ok || version == "" {
+		panic(fmt.Sprintf("transport: unknown API version %v", version))
+	}
+
+	min := 1.0
+	id := openapi.Parameter{Name: "id", In: "path", Description: "Id of the user", Required: true, Schema: &openapi.Schema{Type: "integer", Minimum: &min}}
+	addressID := openapi.Parameter{Name: "address_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+	webhookID := openapi.Parameter{Name: "webhook_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+	deliveryID := openapi.Parameter{Name: "delivery_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+	format := openapi.Parameter{Name: "format", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{bulk.CSV, bulk.JSONL}}}
+
+	doc := openapi.New("User API", v.name)
+	doc.Server("/"+v.name, "")
+	if !v.deprecation.IsZero() {
+		doc.Info.Description = fmt.Sprintf("Deprecated, it is removed on %v in favor of %v", v.sunset.Format("2006-01-02"), v.successor)
+	}
+	doc.Use(
+		openapi.Parameter{Name: tenant.HeaderKey, In: "header", Description: "Tenant of the request, the subdomain of the host otherwise", Schema: &openapi.Schema{Type: "string"}},
+		openapi.Parameter{Name: locale.HeaderKey, In: "header", Description: "Language of the error messages", Schema: &openapi.Schema{Type: "string"}},
+	)
+	doc.DefaultResponse("Problem", "application/problem+json", ProblemResponse{})
+	doc.SecurityScheme("admin", openapi.SecurityScheme{Type: "http", Scheme: "bearer"})
+	doc.SecurityScheme("user", openapi.SecurityScheme{Type: "http", Scheme: "basic"})
+
+	routes := []openapi.Route{
+		{Method: "POST", Path: "/users:batchGet", ID: "batchGetUsers", Summary: "Get several users by their ids", Tag: "users",
+			Request: BatchGetUsersRequest{}, Response: BatchGetUsersResponse{}},
+		{Method: "GET", Path: "/users/changes", ID: "listChanges", Summary: "List the changes of the users", Tag: "users", Security: "admin",
+			Parameters: []openapi.Parameter{
+				{Name: "cursor", In: "query", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "page_size", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+			},
+			Response: ListChangesResponse{}},
+		{Method: "GET", Path: "/users/{id}/events", ID: "watchUser", Summary: "Stream the changes of the user as server-sent events", Tag: "users",
+			Parameters: []openapi.Parameter{id,
+				{Name: "last_event_id", In: "query", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "Last-Event-ID", In: "header", Schema: &openapi.Schema{Type: "string"}},
+			},
+			Response: &openapi.Schema{Type: "string", Description: "Events whose data is a change"}, ResponseContent: []string{"text/event-stream"}},
+		{Method: "GET", Path: "/users/{id}", ID: "getUser", Summary: "Get the user", Tag: "users",
+			Parameters: []openapi.Parameter{id}, Response: v.response(GetUserResponse{})},
+		{Method: "POST", Path: "/users", ID: "createUser", Summary: "Create a user", Tag: "users",
+			Request: CreateUserRequest{}, Response: v.response(CreateUserResponse{})},
+		{Method: "PUT", Path: "/users/{id}", ID: "updateUser", Summary: "Update the user", Tag: "users", Security: "user",
+			Parameters: []openapi.Parameter{id}, Request: UpdateUserRequest{}, Response: UpdateUserResponse{}},
+		{Method: "DELETE", Path: "/users/{id}", ID: "deleteUser", Summary: "Delete the user", Tag: "users", Security: "user",
+			Parameters: []openapi.Parameter{id}, Response: DeleteUserResponse{}},
+		{Method: "GET", Path: "/users/{id}/details", ID: "getUserDetails", Summary: "Get the details of the user", Tag: "details", Security: "user",
+			Parameters: []openapi.Parameter{id}, Response: GetUserDetailsResponse{}},
+		{Method: "PUT", Path: "/users/{id}/details", ID: "setUserDetails", Summary: "Replace the details of the user", Tag: "details", Security: "user",
+			Parameters: []openapi.Parameter{id}, Request: SetUserDetailsRequest{}, Response: SetUserDetailsResponse{}},
+		{Method: "PATCH", Path: "/users/{id}/details", ID: "patchUserDetails", Summary: "Change some of the details of the user", Tag: "details", Security: "user",
+			Parameters: []openapi.Parameter{id, {Name: "If-Match", In: "header", Description: "ETag of the details the patch applies to", Schema: &openapi.Schema{Type: "string"}}},
+			Request:    PatchUserDetailsRequest{}, RequestContent: []string{"application/merge-patch+json", openapi.JSON},
+			Response: PatchUserDetailsResponse{}},
+		{Method: "DELETE", Path: "/users/{id}/details", ID: "deleteUserDetails", Summary: "Delete the details of the user", Tag: "details", Security: "user",
+			Parameters: []openapi.Parameter{id}, Response: DeleteUserDetailsResponse{}},
+		{Method: "GET", Path: "/users/{id}/addresses", ID: "listAddresses", Summary: "List the addresses of the user", Tag: "addresses", Security: "user",
+			Parameters: []openapi.Parameter{id}, Response: ListAddressesResponse{}},
+		{Method: "POST", Path: "/users/{id}/addresses", ID: "addAddress", Summary: "Add an address to the user", Tag: "addresses", Security: "user",
+			Parameters: []openapi.Parameter{id}, Request: AddAddressRequest{}, Response: AddAddressResponse{}},
+		{Method: "GET", Path: "/users/{id}/addresses/{address_id}", ID: "getAddress", Summary: "Get an address of the user", Tag: "addresses", Security: "user",
+			Parameters: []openapi.Parameter{id, addressID}, Response: GetAddressResponse{}},
+		{Method: "PUT", Path: "/users/{id}/addresses/{address_id}", ID: "updateAddress", Summary: "Update an address of the user", Tag: "addresses", Security: "user",
+			Parameters: []openapi.Parameter{id, addressID}, Request: UpdateAddressRequest{}, Response: UpdateAddressResponse{}},
+		{Method: "DELETE", Path: "/users/{id}/addresses/{address_id}", ID: "deleteAddress", Summary: "Delete an address of the user", Tag: "addresses", Security: "user",
+			Parameters: []openapi.Parameter{id, addressID}, Response: DeleteAddressResponse{}},
+		{Method: "POST", Path: "/users/{id}/phone/verification", ID: "sendPhoneVerification", Summary: "Send a verification code to the mobile number", Tag: "phone", Security: "user",
+			Parameters: []openapi.Parameter{id}, Response: SendPhoneVerificationResponse{}},
+		{Method: "POST", Path: "/users/{id}/phone/verification/confirm", ID: "verifyPhone", Summary: "Verify the mobile number with the code", Tag: "phone", Security: "user",
+			Parameters: []openapi.Parameter{id}, Request: VerifyPhoneRequest{}, Response: VerifyPhoneResponse{}},
+		{Method: "POST", Path: "/users/{id}/avatar", ID: "uploadAvatar", Summary: "Upload the avatar of the user", Tag: "avatar", Security: "user",
+			Parameters: []openapi.Parameter{id},
+			Request: &openapi.Schema{
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"avatar": {Type: "string", ContentMediaType: "application/octet-stream"}},
+				Required:   []string{"avatar"},
+			},
+			RequestContent: []string{"multipart/form-data"}, Response: UploadAvatarResponse{}},
+		{Method: "GET", Path: "/users/{id}/avatar", ID: "getAvatar", Summary: "Get the avatar of the user", Tag: "avatar",
+			Parameters: []openapi.Parameter{id, {Name: "size", In: "query", Description: "Side in pixels", Schema: &openapi.Schema{Type: "integer"}}},
+			Response:   &openapi.Schema{Type: "string", ContentMediaType: "image/*"}, ResponseContent: []string{"image/*"}},
+		{Method: "POST", Path: "/users:import", ID: "importUsers", Summary: "Create the users of a CSV or JSONL file one row at a time", Tag: "bulk", Security: "admin",
+			Parameters: []openapi.Parameter{format, {Name: "dry_run", In: "query", Schema: &openapi.Schema{Type: "boolean"}}},
+			Request:    &openapi.Schema{Type: "string"}, RequestContent: []string{"text/csv", "application/x-ndjson"},
+			Response: ImportUsersResponse{}},
+		{Method: "GET", Path: "/users:export", ID: "exportUsers", Summary: "Export the users as CSV or JSONL", Tag: "bulk", Security: "admin",
+			Parameters: []openapi.Parameter{format},
+			Response:   &openapi.Schema{Type: "string"}, ResponseContent: []string{"text/csv", "application/x-ndjson"}},
+		{Method: "POST", Path: "/auth", ID: "authenticate", Summary: "Check the credentials of a user", Tag: "auth",
+			Request: AuthenticateRequest{}, Response: AuthenticateResponse{}},
+		{Method: "POST", Path: "/admin/webhooks", ID: "createWebhook", Summary: "Subscribe a webhook", Tag: "webhooks", Security: "admin",
+			Request: CreateWebhookRequest{}, Response: CreateWebhookResponse{}},
+		{Method: "GET", Path: "/admin/webhooks", ID: "listWebhooks", Summary: "List the webhooks", Tag: "webhooks", Security: "admin",
+			Response: ListWebhooksResponse{}},
+		{Method: "DELETE", Path: "/admin/webhooks/{webhook_id}", ID: "deleteWebhook", Summary: "Delete a webhook", Tag: "webhooks", Security: "admin",
+			Parameters: []openapi.Parameter{webhookID}, Response: DeleteWebhookResponse{}},
+		{Method: "GET", Path: "/admin/webhooks/{webhook_id}/deliveries", ID: "listDeliveries", Summary: "List the deliveries of a webhook", Tag: "webhooks", Security: "admin",
+			Parameters: []openapi.Parameter{webhookID, {Name: "status", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"pending", "succeeded", "dead"}}}},
+			Response:   ListDeliveriesResponse{}},
+		{Method: "POST", Path: "/admin/webhooks/{webhook_id}/deliveries/{delivery_id}/replay", ID: "replayDelivery", Summary: "Deliver an event again", Tag: "webhooks", Security: "admin",
+			Parameters: []openapi.Parameter{webhookID, deliveryID}, Response: ReplayDeliveryResponse{}},
+	}
+
+	for _, r := range routes {
+		doc.Add(r)
+	}
+
+	return doc
+}
+
+// OpenAPIMiddleware rejects the requests which do not follow the OpenAPI document of their version before they reach
+// the handlers, every violation of the parameters and of the JSON body is returned within one problem
+func OpenAPIMiddleware() mux.MiddlewareFunc {
+	docs := map[string]*openapi.Document{}
+	for _, v := range versions {
+		docs[v.name] = OpenAPI(v.name)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			// the unknown versions are rejected by the server
+			v, ok := requestVersion(r)
+			if !ok {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			violations := docs[v.name].ValidateRequest(r)
+			if len(violations) == 0 {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			ctx := locale.NewContext(r.Context(), errors.MatchLanguage(r.Header.Get(locale.HeaderKey)))
+			writeProblem(rw, errors.LocalizedStatus(ctx, errors.NewInvalidRequestError(violations...)))
+		})
+	}
+}
+
+func openAPIHandler(doc *openapi.Document) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(rw).Encode(doc)
+	})
+}
+
+func docsHandler(rw http.ResponseWriter, r *http.Request) {
+	type docURL struct {
+		URL  string `json:"url"`
+		Name string `json:"name"`
+	}
+
+	urls := make([]docURL, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		urls = append(urls, docURL{URL: "/" + versions[i].name + "/openapi.json", Name: versions[i].name})
+	}
+	data, _ := json.Marshal(urls)
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(rw, docsPage, data)
+}
@@ -2,61 +2,289 @@ package transport
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"runtime/debug"
 	"strconv"
+	"strings"
+	"time"
 
 	gokitHttp "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
+	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-// NewHTTPServer returns the server with the endpoints and the specifications for each one
-func NewHTTPServer(ctx context.Context, endpoints HTTPEndpoints) http.Handler {
+// sseHeartbeat is how often an idle event stream gets a comment
+const sseHeartbeat = 15 * time.Second
+
+// NewHTTPServer returns the server with the endpoints and the specifications for each one,
+// the admin and bulk routes require the admin token of the tenant as a bearer token and they are closed for the tenants without one,
+// every route is served under /v1 and /v2 and without a prefix for the version selected by the API-Version header,
+// the OpenAPI document of each version is served at its /openapi.json and rendered at /docs
+func NewHTTPServer(ctx context.Context, endpoints HTTPEndpoints, adminTokens map[string]string) http.Handler {
 	root := mux.NewRouter()
 	root.Use(middleware)
+	root.Use(languageMiddleware)
+	root.Use(tenantMiddleware)
+
+	root.Methods("GET").Path("/docs").HandlerFunc(docsHandler)
+
+	for _, v := range versions {
+		router := root.PathPrefix("/" + v.name).Subrouter()
+		router.Use(versionMiddleware(v))
+		routes(router, endpoints, adminTokens, v)
+	}
+
+	// the paths without a version prefix serve the version selected by the header
+	for _, v := range versions[1:] {
+		router := root.MatcherFunc(selectsVersion(v)).Subrouter()
+		router.Use(versionMiddleware(v))
+		routes(router, endpoints, adminTokens, v)
+	}
+	router := root.NewRoute().Subrouter()
+	router.Use(defaultVersionMiddleware, versionMiddleware(versions[0]))
+	routes(router, endpoints, adminTokens, versions[0])
+
+	return root
+}
+
+// NewGraphQLServer serves the GraphQL API at /graphql and its schema at /graphql/schema.graphql, the requests go
+// through the language and tenant middlewares of the REST routes so the resolvers read the same context
+func NewGraphQLServer(api http.Handler, schema http.Handler) http.Handler {
+	root := mux.NewRouter()
+	root.Use(languageMiddleware)
+	root.Use(tenantMiddleware)
 
-	userRouter := root.PathPrefix("/users").Subrouter()
+	root.Methods("GET").Path("/graphql/schema.graphql").Handler(schema)
+	root.Methods("POST").Path("/graphql").Handler(api)
 
+	return root
+}
+
+// routes registers every route of the version, the bodies are encoded by the version over the same endpoints
+func routes(router *mux.Router, endpoints HTTPEndpoints, adminTokens map[string]string, v apiVersion) {
 	opt := gokitHttp.ServerOption(gokitHttp.ServerErrorEncoder(encodeError))
+	// the routes owned by a user answer a challenge with the unauthenticated errors so the clients send their basic
+	// credentials
+	challenge := gokitHttp.ServerOption(gokitHttp.ServerErrorEncoder(encodeChallengeError))
+
+	router.Methods("POST").Path("/users:batchGet").Handler(gokitHttp.NewServer(
+		endpoints.BatchGetUsers,
+		decodeBatchGetUsersRequest,
+		v.encode,
+		challenge,
+	))
+
+	// the bulk routes and the changes feed read and write the details of every user of the tenant
+	admin := adminMiddleware(adminTokens)
+
+	userRouter := router.PathPrefix("/users").Subrouter()
+
+	userRouter.Methods("GET").Path("/changes").Handler(admin(gokitHttp.NewServer(
+		endpoints.ListChanges,
+		decodeListChangesRequest,
+		v.encode,
+		opt,
+	)))
+
+	userRouter.Methods("GET").Path("/{id}/events").Handler(gokitHttp.NewServer(
+		endpoints.WatchUser,
+		decodeWatchUserRequest,
+		encodeWatchUserResponse,
+		challenge,
+	))
 
 	userRouter.Methods("GET").Path("/{id}").Handler(gokitHttp.NewServer(
 		endpoints.GetUser,
 		decodeGetUserRequest,
-		encodeResponse,
-		opt,
+		v.encode,
+		challenge,
 	))
 
-	userRouter.Methods("POST").Handler(gokitHttp.NewServer(
+	userRouter.Methods("POST").Path("").Handler(gokitHttp.NewServer(
 		endpoints.CreateUser,
 		decodeCreateUserRequest,
-		encodeResponse,
+		v.encode,
 		opt,
 	))
 
 	userRouter.Methods("PUT").Path("/{id}").Handler(gokitHttp.NewServer(
 		endpoints.UpdateUser,
 		decodeUpdateUserRequest,
-		encodeResponse,
-		opt,
+		v.encode,
+		challenge,
 	))
 
 	userRouter.Methods("DELETE").Path("/{id}").Handler(gokitHttp.NewServer(
 		endpoints.DeleteUser,
 		decodeDeleteUserRequest,
-		encodeResponse,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("GET").Path("/{id}/details").Handler(gokitHttp.NewServer(
+		endpoints.GetUserDetails,
+		decodeGetUserDetailsRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("PUT").Path("/{id}/details").Handler(gokitHttp.NewServer(
+		endpoints.SetUserDetails,
+		decodeSetUserDetailsRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("PATCH").Path("/{id}/details").Handler(gokitHttp.NewServer(
+		endpoints.PatchUserDetails,
+		decodePatchUserDetailsRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("DELETE").Path("/{id}/details").Handler(gokitHttp.NewServer(
+		endpoints.DeleteUserDetails,
+		decodeDeleteUserDetailsRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("GET").Path("/{id}/addresses").Handler(gokitHttp.NewServer(
+		endpoints.ListAddresses,
+		decodeListAddressesRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("POST").Path("/{id}/addresses").Handler(gokitHttp.NewServer(
+		endpoints.AddAddress,
+		decodeAddAddressRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("GET").Path("/{id}/addresses/{address_id}").Handler(gokitHttp.NewServer(
+		endpoints.GetAddress,
+		decodeGetAddressRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("PUT").Path("/{id}/addresses/{address_id}").Handler(gokitHttp.NewServer(
+		endpoints.UpdateAddress,
+		decodeUpdateAddressRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("DELETE").Path("/{id}/addresses/{address_id}").Handler(gokitHttp.NewServer(
+		endpoints.DeleteAddress,
+		decodeDeleteAddressRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("POST").Path("/{id}/phone/verification").Handler(gokitHttp.NewServer(
+		endpoints.SendPhoneVerification,
+		decodeSendPhoneVerificationRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("POST").Path("/{id}/phone/verification/confirm").Handler(gokitHttp.NewServer(
+		endpoints.VerifyPhone,
+		decodeVerifyPhoneRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("POST").Path("/{id}/avatar").Handler(gokitHttp.NewServer(
+		endpoints.UploadAvatar,
+		decodeUploadAvatarRequest,
+		v.encode,
+		challenge,
+	))
+
+	userRouter.Methods("GET").Path("/{id}/avatar").Handler(gokitHttp.NewServer(
+		endpoints.GetAvatar,
+		decodeGetAvatarRequest,
+		encodeAvatarResponse,
 		opt,
 	))
 
-	root.Methods("POST").Path("/auth").Handler(gokitHttp.NewServer(
+	router.Methods("POST").Path("/users:import").Handler(admin(gokitHttp.NewServer(
+		endpoints.ImportUsers,
+		decodeImportUsersRequest,
+		v.encode,
+		opt,
+	)))
+
+	router.Methods("GET").Path("/users:export").Handler(admin(gokitHttp.NewServer(
+		endpoints.ExportUsers,
+		decodeExportUsersRequest,
+		encodeExportUsersResponse,
+		opt,
+	)))
+
+	router.Methods("POST").Path("/auth").Handler(gokitHttp.NewServer(
 		endpoints.Authenticate,
 		decodeAuthenticateRequest,
-		encodeResponse,
+		v.encode,
 		opt,
 	))
 
-	return root
+	adminRouter := router.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(admin)
+
+	adminRouter.Methods("POST").Path("/webhooks").Handler(gokitHttp.NewServer(
+		endpoints.CreateWebhook,
+		decodeCreateWebhookRequest,
+		v.encode,
+		opt,
+	))
+
+	adminRouter.Methods("GET").Path("/webhooks").Handler(gokitHttp.NewServer(
+		endpoints.ListWebhooks,
+		decodeListWebhooksRequest,
+		v.encode,
+		opt,
+	))
+
+	adminRouter.Methods("DELETE").Path("/webhooks/{webhook_id}").Handler(gokitHttp.NewServer(
+		endpoints.DeleteWebhook,
+		decodeDeleteWebhookRequest,
+		v.encode,
+		opt,
+	))
+
+	adminRouter.Methods("GET").Path("/webhooks/{webhook_id}/deliveries").Handler(gokitHttp.NewServer(
+		endpoints.ListDeliveries,
+		decodeListDeliveriesRequest,
+		v.encode,
+		opt,
+	))
+
+	adminRouter.Methods("POST").Path("/webhooks/{webhook_id}/deliveries/{delivery_id}/replay").Handler(gokitHttp.NewServer(
+		endpoints.ReplayDelivery,
+		decodeReplayDeliveryRequest,
+		v.encode,
+		opt,
+	))
+
+	router.Methods("GET").Path("/openapi.json").Handler(openAPIHandler(OpenAPI(v.name)))
 }
 
 func middleware(next http.Handler) http.Handler {
@@ -66,9 +294,69 @@ func middleware(next http.Handler) http.Handler {
 	})
 }
 
+// languageMiddleware stores within the request context the language of the messages selected by the
+// Accept-Language header, English when none of the accepted languages has a translation
+func languageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		lang := errors.MatchLanguage(r.Header.Get(locale.HeaderKey))
+		next.ServeHTTP(rw, r.WithContext(locale.NewContext(r.Context(), lang)))
+	})
+}
+
+// tenantMiddleware resolves the tenant from the X-Tenant-ID header or the subdomain of the host,
+// falling back to the default tenant, and stores it within the request context
+func tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(tenant.HeaderKey)
+
+		if id == "" {
+			id = tenantFromHost(r.Host)
+		}
+
+		if !tenant.Valid(id) {
+			writeProblem(rw, errors.LocalizedStatus(r.Context(), errors.NewMissingTenantError()))
+			return
+		}
+
+		next.ServeHTTP(rw, r.WithContext(tenant.NewContext(r.Context(), id)))
+	})
+}
+
+// adminMiddleware rejects the requests whose Authorization header does not carry the admin token of their tenant,
+// a token only manages the tenant it belongs to
+func adminMiddleware(tokens map[string]string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			t, _ := tenant.FromContext(r.Context())
+			token := tokens[t]
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				writeProblem(rw, errors.LocalizedStatus(r.Context(), errors.NewUnauthorizedError()))
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func tenantFromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	labels := strings.Split(host, ".")
+	if net.ParseIP(host) != nil || len(labels) < 3 {
+		return tenant.Default
+	}
+
+	return strings.ToLower(labels[0])
+}
+
 func decodeCreateUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var request CreateUserRequest
-	err := json.NewDecoder(r.Body).Decode(&request)
+	err := decodeJSON(ctx, r.Body, &request)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +365,7 @@ func decodeCreateUserRequest(ctx context.Context, r *http.Request) (interface{},
 
 func decodeAuthenticateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var request AuthenticateRequest
-	err := json.NewDecoder(r.Body).Decode(&request)
+	err := decodeJSON(ctx, r.Body, &request)
 	if err != nil {
 		return nil, err
 	}
@@ -86,55 +374,576 @@ func decodeAuthenticateRequest(ctx context.Context, r *http.Request) (interface{
 
 func decodeUpdateUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var request UpdateUserRequest
-	idParam := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idParam)
+	id, err := pathInt(ctx, r, "id")
 
 	if err != nil {
 		return nil, err
 	}
-	err = json.NewDecoder(r.Body).Decode(&request)
+	err = decodeJSON(ctx, r.Body, &request)
 	if err != nil {
 		return nil, err
 	}
 
 	request.UserID = id
+	request.BasicAuth = basicAuth(r)
 	return request, nil
 }
 
 func decodeGetUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	idParam := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idParam)
+	id, err := pathInt(ctx, r, "id")
 
 	if err != nil {
 		return nil, err
 	}
 
-	request := GetUserRequest{UserID: id}
+	request := GetUserRequest{UserID: id, BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func decodeBatchGetUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var request BatchGetUsersRequest
+	if err := decodeJSON(ctx, r.Body, &request); err != nil {
+		return nil, err
+	}
+
+	request.BasicAuth = basicAuth(r)
 	return request, nil
 }
 
 func decodeDeleteUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	idParam := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idParam)
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := DeleteUserRequest{UserID: id, BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func decodeGetUserDetailsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := GetUserDetailsRequest{UserID: id, BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func decodeSetUserDetailsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := SetUserDetailsRequest{UserID: id, BasicAuth: basicAuth(r)}
+	if err := decodeJSON(ctx, r.Body, &request.Details); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// decodePatchUserDetailsRequest takes a JSON merge patch, sent as application/merge-patch+json or application/json
+func decodePatchUserDetailsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := ifMatch(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	request := PatchUserDetailsRequest{UserID: id, BasicAuth: basicAuth(r), Revision: revision}
+	if err := decodeJSON(ctx, r.Body, &request.DetailsPatch); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func decodeDeleteUserDetailsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := DeleteUserDetailsRequest{UserID: id, BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func basicAuth(r *http.Request) BasicAuth {
+	email, password, _ := r.BasicAuth()
+	return BasicAuth{Email: email, Password: password}
+}
+
+// entityTag is the ETag of the details at the revision
+func entityTag(revision int64) string {
+	return fmt.Sprintf(`"%d"`, revision)
+}
+
+// ifMatch parses the If-Match header into the revision the details are expected at, the missing header and * match
+// any revision
+func ifMatch(ctx context.Context, r *http.Request) (int64, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return entities.AnyRevision, nil
+	}
+
+	revision, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`), 10, 64)
+	if err != nil || revision < 0 || entityTag(revision) != tag {
+		e := errors.NewInvalidRequestError(errors.FieldViolation{Field: "If-Match", Description: "expected an entity tag of the details"})
+		return 0, errors.LocalizedStatus(ctx, e).Err()
+	}
+
+	return revision, nil
+}
+
+func decodeAddAddressRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var request AddAddressRequest
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+	err = decodeJSON(ctx, r.Body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	request.UserID = id
+	request.BasicAuth = basicAuth(r)
+	return request, nil
+}
+
+func decodeListAddressesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := ListAddressesRequest{UserID: id, BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func decodeGetAddressRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := GetAddressRequest{UserID: id, AddressID: vars["address_id"], BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func decodeUpdateAddressRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var request UpdateAddressRequest
+	vars := mux.Vars(r)
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+	err = decodeJSON(ctx, r.Body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	request.UserID = id
+	request.ID = vars["address_id"]
+	request.BasicAuth = basicAuth(r)
+	return request, nil
+}
+
+func decodeDeleteAddressRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := DeleteAddressRequest{UserID: id, AddressID: vars["address_id"], BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func decodeSendPhoneVerificationRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := SendPhoneVerificationRequest{UserID: id, BasicAuth: basicAuth(r)}
+	return request, nil
+}
+
+func decodeVerifyPhoneRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var request VerifyPhoneRequest
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+	err = decodeJSON(ctx, r.Body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	request.UserID = id
+	request.BasicAuth = basicAuth(r)
+	return request, nil
+}
+
+// decodeUploadAvatarRequest streams the "avatar" part of the multipart form, the service limits its size
+func decodeUploadAvatarRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	e := errors.NewInvalidAvatarError()
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.LocalizedStatus(ctx, e).Err()
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, errors.LocalizedStatus(ctx, e).Err()
+		}
+
+		if part.FormName() == "avatar" {
+			return UploadAvatarRequest{UserID: id, BasicAuth: basicAuth(r), Avatar: part}, nil
+		}
+	}
+}
+
+func decodeGetAvatarRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
 
 	if err != nil {
 		return nil, err
 	}
 
-	request := DeleteUserRequest{UserID: id}
+	request := GetAvatarRequest{UserID: id}
+	if size := r.URL.Query().Get("size"); size != "" {
+		request.Size, err = strconv.Atoi(size)
+		if err != nil {
+			e := errors.NewInvalidRequestError(errors.FieldViolation{Field: "size", Description: "expected the side in pixels"})
+			return nil, errors.LocalizedStatus(ctx, e).Err()
+		}
+	}
+
 	return request, nil
 }
 
+// decodeImportUsersRequest resolves the format from the format query or the Content-Type, the body is streamed
+func decodeImportUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	request := ImportUsersRequest{
+		Format: bulkFormat(r.URL.Query().Get("format"), r.Header.Get("Content-Type")),
+		Data:   r.Body,
+	}
+
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			e := errors.NewInvalidRequestError(errors.FieldViolation{Field: "dry_run", Description: "expected true or false"})
+			return nil, errors.LocalizedStatus(ctx, e).Err()
+		}
+		request.DryRun = dryRun
+	}
+
+	return request, nil
+}
+
+// decodeExportUsersRequest resolves the format from the format query or the Accept header, JSONL by default
+func decodeExportUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	format := bulkFormat(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if format == "" {
+		format = bulk.JSONL
+	}
+
+	if !bulk.ValidFormat(format) {
+		e := errors.NewInvalidFormatError()
+		return nil, errors.LocalizedStatus(ctx, e).Err()
+	}
+
+	return ExportUsersRequest{Format: format}, nil
+}
+
+func decodeCreateWebhookRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var request CreateWebhookRequest
+	err := decodeJSON(ctx, r.Body, &request)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func decodeListWebhooksRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return ListWebhooksRequest{}, nil
+}
+
+func decodeDeleteWebhookRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return DeleteWebhookRequest{WebhookID: mux.Vars(r)["webhook_id"]}, nil
+}
+
+func decodeListDeliveriesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	request := ListDeliveriesRequest{WebhookID: mux.Vars(r)["webhook_id"], Status: r.URL.Query().Get("status")}
+	return request, nil
+}
+
+func decodeReplayDeliveryRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	request := ReplayDeliveryRequest{WebhookID: vars["webhook_id"], DeliveryID: vars["delivery_id"]}
+	return request, nil
+}
+
+func decodeListChangesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	pageSize, _ := strconv.Atoi(query.Get("page_size"))
+
+	request := ListChangesRequest{Cursor: query.Get("cursor"), PageSize: pageSize}
+	return request, nil
+}
+
+// decodeWatchUserRequest takes the cursor from the Last-Event-ID header which the EventSource sends when it reconnects,
+// the last_event_id query lets a client resume the first connection too
+func decodeWatchUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := pathInt(ctx, r, "id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := WatchUserRequest{UserID: id, LastEventID: r.Header.Get("Last-Event-ID"), BasicAuth: basicAuth(r)}
+	if request.LastEventID == "" {
+		request.LastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	return request, nil
+}
+
+// pathInt parses the integer variable of the path, the violation names the variable
+func pathInt(ctx context.Context, r *http.Request, name string) (int, error) {
+	v, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		e := errors.NewInvalidRequestError(errors.FieldViolation{Field: name, Description: "expected an integer"})
+		return 0, errors.LocalizedStatus(ctx, e).Err()
+	}
+
+	return v, nil
+}
+
+func bulkFormat(query string, mediaType string) string {
+	if query != "" {
+		return strings.ToLower(query)
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/csv"):
+		return bulk.CSV
+	case strings.HasPrefix(mediaType, "application/x-ndjson"), strings.HasPrefix(mediaType, "application/jsonl"):
+		return bulk.JSONL
+	default:
+		return ""
+	}
+}
+
 func encodeResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	if h, ok := response.(gokitHttp.Headerer); ok {
+		for k, values := range h.Headers() {
+			for _, v := range values {
+				rw.Header().Add(k, v)
+			}
+		}
+	}
 	return json.NewEncoder(rw).Encode(response)
 }
 
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
-	e, ok := status.FromError(err)
+// encodeAvatarResponse writes the image instead of JSON, the URL of the avatar changes on every upload so it can be
+// cached, only by the client since the same URL serves the avatar of another user within every tenant
+func encodeAvatarResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	res := response.(GetAvatarResponse)
+	defer res.Avatar.Close()
+
+	rw.Header().Set("Content-Type", res.ContentType)
+	rw.Header().Set("Cache-Control", "private, max-age=86400")
+	rw.Header().Add("Vary", tenant.HeaderKey)
+	_, err := io.Copy(rw, res.Avatar)
+	return err
+}
+
+// encodeExportUsersResponse streams the rows, errors after the first page can only cut the stream short
+func encodeExportUsersResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	res := response.(ExportUsersResponse)
+
+	contentType := "application/x-ndjson"
+	if res.Format == bulk.CSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"users.%v\"", res.Format))
+	return res.Export(rw)
+}
+
+// encodeWatchUserResponse writes every change as a server-sent event whose id is the cursor of the change, a comment
+// is written when the stream is idle so the proxies keep the connection open
+func encodeWatchUserResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	res := response.(WatchUserResponse)
+
+	flusher, ok := rw.(http.Flusher)
 	if !ok {
-		panic("Unsupported error")
+		return status.Error(codes.Internal, "Streaming not supported")
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := io.WriteString(rw, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		case c, ok := <-res.Changes:
+			if !ok {
+				return nil
+			}
+			if res.Anonymous {
+				c = withheldChange(c)
+			}
+
+			data, err := json.Marshal(c)
+			if err != nil {
+				return nil
+			}
+			if _, err := fmt.Fprintf(rw, "id: %v\nevent: %v\ndata: %s\n\n", c.Cursor, c.Type, data); err != nil {
+				return nil
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	writeProblem(w, statusFromError(ctx, err))
+}
+
+// encodeChallengeError asks for the basic credentials along with the unauthenticated errors
+func encodeChallengeError(ctx context.Context, err error, w http.ResponseWriter) {
+	st := statusFromError(ctx, err)
+	if st.Code() == codes.Unauthenticated {
+		w.Header().Set("WWW-Authenticate", `Basic realm="users", charset="UTF-8"`)
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(errors.ResolveHTTP(e.Code()))
-	json.NewEncoder(w).Encode(map[string]string{"error": e.Message()})
+	writeProblem(w, st)
+}
+
+// statusFromError maps every error which reaches the error encoder to a status, the decoders return the errors of
+// the JSON bodies as they are, the validation returns custom errors and the errors of the other layers are already
+// statuses
+func statusFromError(ctx context.Context, err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	switch e := err.(type) {
+	case errors.ErrorResolver:
+		return errors.LocalizedStatus(ctx, err)
+	case *json.SyntaxError:
+		return errors.LocalizedStatus(ctx, errors.NewInvalidRequestError(errors.FieldViolation{Field: "body", Description: "malformed JSON"}))
+	case *json.UnmarshalTypeError:
+		field := e.Field
+		if field == "" {
+			field = "body"
+		}
+		return errors.LocalizedStatus(ctx, errors.NewInvalidRequestError(errors.FieldViolation{Field: field, Description: fmt.Sprintf("expected %v", e.Type)}))
+	}
+
+	switch err {
+	case io.EOF:
+		return errors.LocalizedStatus(ctx, errors.NewInvalidRequestError(errors.FieldViolation{Field: "body", Description: "is required"}))
+	case io.ErrUnexpectedEOF:
+		return errors.LocalizedStatus(ctx, errors.NewInvalidRequestError(errors.FieldViolation{Field: "body", Description: "malformed JSON"}))
+	case context.Canceled:
+		return status.New(codes.Canceled, "Request canceled")
+	case context.DeadlineExceeded:
+		return status.New(codes.DeadlineExceeded, "Request timed out")
+	default:
+		return errors.LocalizedStatus(ctx, errors.NewInternalError())
+	}
+}
+
+// RecoveryMiddleware turns a panic of a handler into an internal error problem, the panic and its stack are logged
+// and the server keeps serving the other requests
+func RecoveryMiddleware(logger log.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				level.Error(logger).Log("method", r.Method, "path", r.URL.Path, "panic", rec, "stack", string(debug.Stack()))
+				writeProblem(rw, errors.LocalizedStatus(r.Context(), errors.NewInternalError()))
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// writeProblem writes the status as an RFC 7807 problem, the reason and the field violations come from its details
+// and so does the detail when the status carries a localized message
+func writeProblem(w http.ResponseWriter, st *status.Status) {
+	code := errors.HTTPStatus(st)
+	reason, violations := errors.Describe(st)
+
+	detail := st.Message()
+	if lang, message := errors.Localized(st); message != "" {
+		detail = message
+		w.Header().Set("Content-Language", lang)
+	}
+
+	title := http.StatusText(code)
+	if title == "" {
+		title = st.Code().String()
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ProblemResponse{
+		Type:       "about:blank",
+		Title:      title,
+		Status:     code,
+		Detail:     detail,
+		Reason:     reason,
+		Violations: violations,
+	})
 }
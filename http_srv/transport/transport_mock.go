@@ -2,8 +2,11 @@ package transport
 
 import (
 	"context"
+	"io"
 
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
 	"github.com/mauricioww/user_microsrv/http_srv/entities"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -13,8 +16,8 @@ type ServiceMock struct {
 }
 
 // CreateUser is a mock of the real method
-func (s *ServiceMock) CreateUser(ctx context.Context, email string, pwd string, age int, details entities.Details) (int, error) {
-	args := s.Called(ctx, email, pwd, age, details)
+func (s *ServiceMock) CreateUser(ctx context.Context, email string, pwd string, dateOfBirth string, details entities.Details) (int, error) {
+	args := s.Called(ctx, email, pwd, dateOfBirth, details)
 
 	return args.Int(0), args.Error(1)
 }
@@ -27,8 +30,8 @@ func (s *ServiceMock) Authenticate(ctx context.Context, email string, pwd string
 }
 
 // UpdateUser is a mock of the real method
-func (s *ServiceMock) UpdateUser(ctx context.Context, userID int, email string, pwd string, age int, details entities.Details) (bool, error) {
-	args := s.Called(ctx, userID, email, pwd, age, details)
+func (s *ServiceMock) UpdateUser(ctx context.Context, userID int, email string, pwd string, dateOfBirth string, details entities.Details) (bool, error) {
+	args := s.Called(ctx, userID, email, pwd, dateOfBirth, details)
 
 	return args.Bool(0), args.Error(1)
 }
@@ -40,9 +43,181 @@ func (s *ServiceMock) GetUser(ctx context.Context, userID int) (entities.User, e
 	return args.Get(0).(entities.User), args.Error(1)
 }
 
+// BatchGetUsers is a mock of the real method
+func (s *ServiceMock) BatchGetUsers(ctx context.Context, userIDs []int) ([]entities.UserResult, error) {
+	args := s.Called(ctx, userIDs)
+
+	return args.Get(0).([]entities.UserResult), args.Error(1)
+}
+
 // DeleteUser is a mock of the real method
 func (s *ServiceMock) DeleteUser(ctx context.Context, userID int) (bool, error) {
 	args := s.Called(ctx, userID)
 
 	return args.Bool(0), args.Error(1)
 }
+
+// AuthorizeUser is a mock of the real method
+func (s *ServiceMock) AuthorizeUser(ctx context.Context, userID int, email string, pwd string) error {
+	args := s.Called(ctx, userID, email, pwd)
+
+	return args.Error(0)
+}
+
+// GetUserDetails is a mock of the real method
+func (s *ServiceMock) GetUserDetails(ctx context.Context, userID int) (entities.Details, error) {
+	args := s.Called(ctx, userID)
+
+	return args.Get(0).(entities.Details), args.Error(1)
+}
+
+// SetUserDetails is a mock of the real method
+func (s *ServiceMock) SetUserDetails(ctx context.Context, userID int, details entities.Details) (bool, error) {
+	args := s.Called(ctx, userID, details)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// PatchUserDetails is a mock of the real method
+func (s *ServiceMock) PatchUserDetails(ctx context.Context, userID int, patch entities.DetailsPatch, revision int64) (entities.Details, error) {
+	args := s.Called(ctx, userID, patch, revision)
+
+	return args.Get(0).(entities.Details), args.Error(1)
+}
+
+// DeleteUserDetails is a mock of the real method
+func (s *ServiceMock) DeleteUserDetails(ctx context.Context, userID int) (bool, error) {
+	args := s.Called(ctx, userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// AddAddress is a mock of the real method
+func (s *ServiceMock) AddAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	args := s.Called(ctx, userID, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// ListAddresses is a mock of the real method
+func (s *ServiceMock) ListAddresses(ctx context.Context, userID int) ([]entities.Address, error) {
+	args := s.Called(ctx, userID)
+
+	return args.Get(0).([]entities.Address), args.Error(1)
+}
+
+// GetAddress is a mock of the real method
+func (s *ServiceMock) GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error) {
+	args := s.Called(ctx, userID, addressID)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// UpdateAddress is a mock of the real method
+func (s *ServiceMock) UpdateAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	args := s.Called(ctx, userID, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// DeleteAddress is a mock of the real method
+func (s *ServiceMock) DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error) {
+	args := s.Called(ctx, userID, addressID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// SendPhoneVerification is a mock of the real method
+func (s *ServiceMock) SendPhoneVerification(ctx context.Context, userID int) (bool, error) {
+	args := s.Called(ctx, userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// VerifyPhone is a mock of the real method
+func (s *ServiceMock) VerifyPhone(ctx context.Context, userID int, code string) (bool, error) {
+	args := s.Called(ctx, userID, code)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// UploadAvatar is a mock of the real method
+func (s *ServiceMock) UploadAvatar(ctx context.Context, userID int, data io.Reader) (string, error) {
+	args := s.Called(ctx, userID, data)
+
+	return args.String(0), args.Error(1)
+}
+
+// GetAvatar is a mock of the real method
+func (s *ServiceMock) GetAvatar(ctx context.Context, userID int, size int) (io.ReadCloser, string, error) {
+	args := s.Called(ctx, userID, size)
+
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+
+	return args.Get(0).(io.ReadCloser), args.String(1), args.Error(2)
+}
+
+// ImportUsers is a mock of the real method
+func (s *ServiceMock) ImportUsers(ctx context.Context, format string, data io.Reader, dryRun bool) (bulk.Report, error) {
+	args := s.Called(ctx, format, data, dryRun)
+
+	return args.Get(0).(bulk.Report), args.Error(1)
+}
+
+// ExportUsers is a mock of the real method
+func (s *ServiceMock) ExportUsers(ctx context.Context, format string, w io.Writer) (int, error) {
+	args := s.Called(ctx, format, w)
+
+	return args.Int(0), args.Error(1)
+}
+
+// CreateWebhook is a mock of the real method
+func (s *ServiceMock) CreateWebhook(ctx context.Context, subscription webhook.Subscription) (webhook.Subscription, error) {
+	args := s.Called(ctx, subscription)
+
+	return args.Get(0).(webhook.Subscription), args.Error(1)
+}
+
+// ListWebhooks is a mock of the real method
+func (s *ServiceMock) ListWebhooks(ctx context.Context) ([]webhook.Subscription, error) {
+	args := s.Called(ctx)
+
+	return args.Get(0).([]webhook.Subscription), args.Error(1)
+}
+
+// DeleteWebhook is a mock of the real method
+func (s *ServiceMock) DeleteWebhook(ctx context.Context, webhookID string) (bool, error) {
+	args := s.Called(ctx, webhookID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// ListDeliveries is a mock of the real method
+func (s *ServiceMock) ListDeliveries(ctx context.Context, webhookID string, deliveryStatus string) ([]webhook.Delivery, error) {
+	args := s.Called(ctx, webhookID, deliveryStatus)
+
+	return args.Get(0).([]webhook.Delivery), args.Error(1)
+}
+
+// ReplayDelivery is a mock of the real method
+func (s *ServiceMock) ReplayDelivery(ctx context.Context, webhookID string, deliveryID string) (webhook.Delivery, error) {
+	args := s.Called(ctx, webhookID, deliveryID)
+
+	return args.Get(0).(webhook.Delivery), args.Error(1)
+}
+
+// ListChanges is a mock of the real method
+func (s *ServiceMock) ListChanges(ctx context.Context, cursor string, pageSize int) ([]entities.Change, string, error) {
+	args := s.Called(ctx, cursor, pageSize)
+
+	return args.Get(0).([]entities.Change), args.String(1), args.Error(2)
+}
+
+// WatchUser is a mock of the real method
+func (s *ServiceMock) WatchUser(ctx context.Context, userID int, lastEventID string) (<-chan entities.Change, error) {
+	args := s.Called(ctx, userID, lastEventID)
+
+	return args.Get(0).(<-chan entities.Change), args.Error(1)
+}
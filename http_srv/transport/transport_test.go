@@ -1,15 +1,27 @@
 package transport_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/http_srv/bulk"
 	"github.com/mauricioww/user_microsrv/http_srv/entities"
 	"github.com/mauricioww/user_microsrv/http_srv/transport"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/mauricioww/user_microsrv/tenant"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc/codes"
@@ -19,7 +31,7 @@ import (
 func TestCreateUser(t *testing.T) {
 	srvMock := new(transport.ServiceMock)
 	endpoints := transport.MakeHTTPEndpoints(srvMock)
-	s := transport.NewHTTPServer(context.Background(), endpoints)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
 	server := httptest.NewServer(s)
 
 	defer server.Close()
@@ -27,7 +39,7 @@ func TestCreateUser(t *testing.T) {
 	test_cases := []struct {
 		testName   string
 		body       string
-		data       transport.CreateUserRequest
+		data       entities.UserInput
 		res        int
 		err        error
 		httpStatus int
@@ -38,12 +50,12 @@ func TestCreateUser(t *testing.T) {
 				{
 					"email": "example@email.com",
 					"password": "querty", 
-					"age": 25
+					"date_of_birth": "1996-02-29"
 				}`,
-			data: transport.CreateUserRequest{
-				Email:    "example@email.com",
-				Password: "querty",
-				Age:      25,
+			data: entities.UserInput{
+				Email:       "example@email.com",
+				Password:    "querty",
+				DateOfBirth: "1996-02-29",
 			},
 			res:        1,
 			err:        nil,
@@ -54,12 +66,12 @@ func TestCreateUser(t *testing.T) {
 			body: `
 				{
 					"email": "example@email.com",
-					"age": 25
+					"date_of_birth": "1996-02-29"
 				}
 			`,
-			data: transport.CreateUserRequest{
-				Email: "example@email.com",
-				Age:   25,
+			data: entities.UserInput{
+				Email:       "example@email.com",
+				DateOfBirth: "1996-02-29",
 			},
 			res:        -1,
 			err:        status.Error(codes.FailedPrecondition, "Missing field 'password'"),
@@ -70,12 +82,12 @@ func TestCreateUser(t *testing.T) {
 			body: `
 				{
 					"password": "qwerty",
-					"age": 25
+					"date_of_birth": "1996-02-29"
 				}
 			`,
-			data: transport.CreateUserRequest{
-				Password: "qwerty",
-				Age:      25,
+			data: entities.UserInput{
+				Password:    "qwerty",
+				DateOfBirth: "1996-02-29",
 			},
 			res:        -1,
 			err:        status.Error(codes.FailedPrecondition, "Missing field 'email'"),
@@ -89,7 +101,7 @@ func TestCreateUser(t *testing.T) {
 			assert := assert.New(t)
 
 			// act
-			srvMock.On("CreateUser", mock.Anything, tc.data.Email, tc.data.Password, tc.data.Age, tc.data.Details).
+			srvMock.On("CreateUser", mock.Anything, tc.data.Email, tc.data.Password, tc.data.DateOfBirth, tc.data.Details).
 				Return(tc.res, tc.err)
 			res, _ := http.Post(server.URL+"/users", "application/json", strings.NewReader(tc.body))
 
@@ -102,7 +114,7 @@ func TestCreateUser(t *testing.T) {
 func TestAuthenticate(t *testing.T) {
 	srvMock := new(transport.ServiceMock)
 	endpoints := transport.MakeHTTPEndpoints(srvMock)
-	s := transport.NewHTTPServer(context.Background(), endpoints)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
 	server := httptest.NewServer(s)
 
 	defer server.Close()
@@ -193,33 +205,49 @@ func TestAuthenticate(t *testing.T) {
 func TestUpdateUser(t *testing.T) {
 	srvMock := new(transport.ServiceMock)
 	endpoints := transport.MakeHTTPEndpoints(srvMock)
-	s := transport.NewHTTPServer(context.Background(), endpoints)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
 	server := httptest.NewServer(s)
 
 	defer server.Close()
 
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "", "").Return(status.Error(codes.Unauthenticated, "Unauthenticated"))
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "owner@email.com", "qwerty").Return(nil)
+
 	test_cases := []struct {
 		testName   string
 		userID     int
+		email      string
 		body       string
-		data       transport.UpdateUserRequest
+		data       entities.UserInput
 		res        bool
 		err        error
 		httpStatus int
 	}{
+		{
+			testName: "missing credentials error",
+			userID:   3,
+			body: `
+				{
+					"email": "example@email.com",
+					"password": "querty",
+					"date_of_birth": "1996-02-29"
+				}`,
+			httpStatus: 401,
+		},
 		{
 			testName: "user update succes",
-			userID:   0,
+			email:    "owner@email.com",
+			userID:   3,
 			body: `
 				{
 					"email": "example@email.com",
 					"password": "querty", 
-					"age": 25
+					"date_of_birth": "1996-02-29"
 				}`,
-			data: transport.UpdateUserRequest{
-				Email:    "example@email.com",
-				Password: "querty",
-				Age:      25,
+			data: entities.UserInput{
+				Email:       "example@email.com",
+				Password:    "querty",
+				DateOfBirth: "1996-02-29",
 			},
 			res:        true,
 			err:        nil,
@@ -227,50 +255,53 @@ func TestUpdateUser(t *testing.T) {
 		},
 		{
 			testName: "no password error",
+			email:    "owner@email.com",
 			userID:   1,
 			body: `
 				{
 					"email": "example@email.com",
-					"age": 25
+					"date_of_birth": "1996-02-29"
 				}
 			`,
-			data: transport.UpdateUserRequest{
-				Email: "example@email.com",
-				Age:   25,
+			data: entities.UserInput{
+				Email:       "example@email.com",
+				DateOfBirth: "1996-02-29",
 			},
 			err:        status.Error(codes.FailedPrecondition, "Missing field 'password'"),
 			httpStatus: 400,
 		},
 		{
 			testName: "no email error",
+			email:    "owner@email.com",
 			userID:   2,
 			body: `
 				{
 					"password": "qwerty",
-					"age": 25
+					"date_of_birth": "1996-02-29"
 				}
 			`,
-			data: transport.UpdateUserRequest{
-				Password: "qwerty",
-				Age:      25,
+			data: entities.UserInput{
+				Password:    "qwerty",
+				DateOfBirth: "1996-02-29",
 			},
 			err:        status.Error(codes.FailedPrecondition, "Missing field 'email'"),
 			httpStatus: 400,
 		},
 		{
 			testName: "user not found",
+			email:    "owner@email.com",
 			userID:   100,
 			body: `
 				{
 					"email": "example@email.com",
 					"password": "qwerty",
-					"age": 25
+					"date_of_birth": "1996-02-29"
 				}
 			`,
-			data: transport.UpdateUserRequest{
-				Email:    "example@email.com",
-				Password: "qwerty",
-				Age:      25,
+			data: entities.UserInput{
+				Email:       "example@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1996-02-29",
 			},
 			err:        status.Error(codes.NotFound, "User not found"),
 			httpStatus: 404,
@@ -283,10 +314,13 @@ func TestUpdateUser(t *testing.T) {
 			assert := assert.New(t)
 
 			// act
-			srvMock.On("UpdateUser", mock.Anything, tc.userID, tc.data.Email, tc.data.Password, tc.data.Age, tc.data.Details).Return(tc.res, tc.err)
+			srvMock.On("UpdateUser", mock.Anything, tc.userID, tc.data.Email, tc.data.Password, tc.data.DateOfBirth, tc.data.Details).Return(tc.res, tc.err)
 
 			uri := fmt.Sprintf("%v/users/%v", server.URL, tc.userID)
 			req, _ := http.NewRequest("PUT", uri, strings.NewReader(tc.body))
+			if tc.email != "" {
+				req.SetBasicAuth(tc.email, "qwerty")
+			}
 			res, _ := http.DefaultClient.Do(req)
 
 			// assert
@@ -298,27 +332,68 @@ func TestUpdateUser(t *testing.T) {
 func TestGetUser(t *testing.T) {
 	srvMock := new(transport.ServiceMock)
 	endpoints := transport.MakeHTTPEndpoints(srvMock)
-	s := transport.NewHTTPServer(context.Background(), endpoints)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
 	server := httptest.NewServer(s)
 
 	defer server.Close()
 
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "owner@email.com", "qwerty").Return(nil)
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "other@email.com", "qwerty").Return(status.Error(codes.PermissionDenied, "Forbidden"))
+
 	test_cases := []struct {
 		testName   string
 		userID     int
+		email      string
 		res        entities.User
 		err        error
 		httpStatus int
+		warning    string
+		details    string
 	}{
 		{
 			testName: "user found success",
-			userID:   0,
+			userID:   3,
+			email:    "owner@email.com",
+			res: entities.User{
+				Email:    "owner@email.com",
+				Password: "passsword",
+				Details:  entities.Details{Country: "Mexico"},
+			},
+			err:        nil,
+			httpStatus: 200,
+			details:    `"country":"Mexico"`,
+		},
+		{
+			testName: "details withheld from anonymous success",
+			userID:   4,
 			res: entities.User{
-				Email:    "email@domain.com",
+				Email:    "owner@email.com",
 				Password: "passsword",
+				Details:  entities.Details{Country: "Mexico"},
+			},
+			err:        nil,
+			httpStatus: 200,
+			warning:    `199 - "user details withheld"`,
+			details:    `"details_status":"withheld"`,
+		},
+		{
+			testName:   "another user forbidden error",
+			userID:     5,
+			email:      "other@email.com",
+			httpStatus: 403,
+		},
+		{
+			testName: "user details unavailable",
+			userID:   2,
+			email:    "owner@email.com",
+			res: entities.User{
+				Email:         "owner@email.com",
+				Password:      "passsword",
+				DetailsStatus: entities.DetailsUnavailable,
 			},
 			err:        nil,
 			httpStatus: 200,
+			warning:    `199 - "user details unavailable"`,
 		},
 		{
 			testName:   "user not found error",
@@ -337,10 +412,94 @@ func TestGetUser(t *testing.T) {
 			srvMock.On("GetUser", mock.Anything, tc.userID).Return(tc.res, tc.err)
 
 			uri := fmt.Sprintf("%v/users/%v", server.URL, tc.userID)
-			res, _ := http.Get(uri)
+			req, _ := http.NewRequest("GET", uri, http.NoBody)
+			if tc.email != "" {
+				req.SetBasicAuth(tc.email, "qwerty")
+			}
+			res, _ := http.DefaultClient.Do(req)
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
 
 			// assert
 			assert.Equal(res.StatusCode, tc.httpStatus)
+			assert.Equal(tc.warning, res.Header.Get("Warning"))
+			assert.Contains(string(body), tc.details)
+		})
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	srvMock.On("AuthorizeUser", mock.Anything, 3, "owner@email.com", "qwerty").Return(nil)
+
+	test_cases := []struct {
+		testName   string
+		email      string
+		body       string
+		data       []int
+		res        []entities.UserResult
+		err        error
+		httpStatus int
+		resBody    string
+	}{
+		{
+			testName: "batch get users success",
+			body:     `{"ids": [1, 2]}`,
+			data:     []int{1, 2},
+			res: []entities.UserResult{
+				{ID: 1, Found: true, User: entities.User{ID: 1, Email: "email@domain.com", DetailsStatus: entities.DetailsMissing}},
+				{ID: 2, Found: false},
+			},
+			err:        nil,
+			httpStatus: 200,
+			resBody:    `"results":[{"user_id":1,"found":true,"user":{"email":"email@domain.com"`,
+		},
+		{
+			testName: "details of the owner success",
+			email:    "owner@email.com",
+			body:     `{"ids": [3, 4]}`,
+			data:     []int{3, 4},
+			res: []entities.UserResult{
+				{ID: 3, Found: true, User: entities.User{ID: 3, Email: "Owner@email.com", Details: entities.Details{Country: "Mexico"}}},
+				{ID: 4, Found: true, User: entities.User{ID: 4, Email: "other@email.com", Details: entities.Details{Country: "Peru"}}},
+			},
+			httpStatus: 200,
+			resBody:    `"information":{"country":"Mexico"`,
+		},
+		{
+			testName:   "invalid batch error",
+			body:       `{"ids": []}`,
+			data:       []int{},
+			err:        status.Error(codes.FailedPrecondition, "Invalid batch, expected between 1 and 100 ids"),
+			httpStatus: 400,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("BatchGetUsers", mock.Anything, tc.data).Return(tc.res, tc.err)
+			req, _ := http.NewRequest("POST", server.URL+"/users:batchGet", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.email != "" {
+				req.SetBasicAuth(tc.email, "qwerty")
+			}
+			res, _ := http.DefaultClient.Do(req)
+			body, _ := io.ReadAll(res.Body)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Contains(string(body), tc.resBody)
+			assert.NotContains(string(body), "Peru")
 		})
 	}
 }
@@ -348,21 +507,32 @@ func TestGetUser(t *testing.T) {
 func TestDeleteUser(t *testing.T) {
 	srvMock := new(transport.ServiceMock)
 	endpoints := transport.MakeHTTPEndpoints(srvMock)
-	s := transport.NewHTTPServer(context.Background(), endpoints)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
 	server := httptest.NewServer(s)
 
 	defer server.Close()
 
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "", "").Return(status.Error(codes.Unauthenticated, "Unauthenticated"))
+	srvMock.On("AuthorizeUser", mock.Anything, 2, "owner@email.com", "qwerty").Return(nil)
+	srvMock.On("AuthorizeUser", mock.Anything, 1, "owner@email.com", "qwerty").Return(nil)
+
 	test_cases := []struct {
 		testName   string
 		userID     int
+		email      string
 		res        bool
 		err        error
 		httpStatus int
 	}{
+		{
+			testName:   "missing credentials error",
+			userID:     2,
+			httpStatus: 401,
+		},
 		{
 			testName:   "user deleted success",
-			userID:     0,
+			email:      "owner@email.com",
+			userID:     2,
 			res:        true,
 			err:        nil,
 			httpStatus: 200,
@@ -370,6 +540,7 @@ func TestDeleteUser(t *testing.T) {
 		{
 			testName:   "user not found error",
 			userID:     1,
+			email:      "owner@email.com",
 			err:        status.Error(codes.NotFound, "User not found"),
 			httpStatus: 404,
 		},
@@ -385,6 +556,9 @@ func TestDeleteUser(t *testing.T) {
 
 			uri := fmt.Sprintf("%v/users/%v", server.URL, tc.userID)
 			req, _ := http.NewRequest("DELETE", uri, http.NoBody)
+			if tc.email != "" {
+				req.SetBasicAuth(tc.email, "qwerty")
+			}
 			res, _ := http.DefaultClient.Do(req)
 
 			// assert
@@ -392,3 +566,1584 @@ func TestDeleteUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUserDetails(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	city := "Monterrey"
+	details := entities.Details{Country: "Mexico", City: "Monterrey", MobileNumber: "+528112345678"}
+
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "", "").Return(status.Error(codes.Unauthenticated, "Unauthenticated"))
+	srvMock.On("AuthorizeUser", mock.Anything, 1, "owner@email.com", "qwerty").Return(nil)
+	srvMock.On("AuthorizeUser", mock.Anything, 2, "owner@email.com", "qwerty").Return(status.Error(codes.PermissionDenied, "Forbidden"))
+	current := details
+	current.Revision = 4
+	modified := errors.LocalizedStatus(context.Background(), errors.NewDetailsModifiedError()).Err()
+	srvMock.On("GetUserDetails", mock.Anything, 1).Return(current, nil)
+	srvMock.On("SetUserDetails", mock.Anything, 1, details).Return(true, nil)
+	srvMock.On("PatchUserDetails", mock.Anything, 1, entities.DetailsPatch{City: &city}, entities.AnyRevision).Return(details, nil)
+	srvMock.On("PatchUserDetails", mock.Anything, 1, entities.DetailsPatch{City: &city}, int64(4)).Return(details, nil)
+	srvMock.On("PatchUserDetails", mock.Anything, 1, entities.DetailsPatch{City: &city}, int64(3)).Return(entities.Details{}, modified)
+	srvMock.On("DeleteUserDetails", mock.Anything, 1).Return(true, nil)
+
+	test_cases := []struct {
+		testName    string
+		method      string
+		userID      int
+		email       string
+		pwd         string
+		contentType string
+		ifMatch     string
+		body        string
+		httpStatus  int
+		challenge   string
+		etag        string
+		res         string
+	}{
+		{
+			testName:   "missing credentials error",
+			method:     "GET",
+			userID:     1,
+			httpStatus: 401,
+			challenge:  `Basic realm="users", charset="UTF-8"`,
+		},
+		{
+			testName:   "another user forbidden error",
+			method:     "GET",
+			userID:     2,
+			email:      "owner@email.com",
+			pwd:        "qwerty",
+			httpStatus: 403,
+		},
+		{
+			testName:   "details found success",
+			method:     "GET",
+			userID:     1,
+			email:      "owner@email.com",
+			pwd:        "qwerty",
+			httpStatus: 200,
+			etag:       `"4"`,
+			res:        `{"country":"Mexico","city":"Monterrey","mobile_number":"+528112345678","mobile_verified":false,"married":false,"height_m":0,"weight_kg":0}`,
+		},
+		{
+			testName:    "details replaced success",
+			method:      "PUT",
+			userID:      1,
+			email:       "owner@email.com",
+			pwd:         "qwerty",
+			contentType: "application/json",
+			body:        `{"country":"Mexico","city":"Monterrey","mobile_number":"+528112345678"}`,
+			httpStatus:  200,
+			res:         `{"success":true}`,
+		},
+		{
+			testName:    "details patched success",
+			method:      "PATCH",
+			userID:      1,
+			email:       "owner@email.com",
+			pwd:         "qwerty",
+			contentType: "application/merge-patch+json",
+			body:        `{"city":"Monterrey"}`,
+			httpStatus:  200,
+			res:         `{"country":"Mexico","city":"Monterrey","mobile_number":"+528112345678","mobile_verified":false,"married":false,"height_m":0,"weight_kg":0}`,
+		},
+		{
+			testName:    "details patched at revision success",
+			method:      "PATCH",
+			userID:      1,
+			email:       "owner@email.com",
+			pwd:         "qwerty",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"4"`,
+			body:        `{"city":"Monterrey"}`,
+			httpStatus:  200,
+		},
+		{
+			testName:    "details modified error",
+			method:      "PATCH",
+			userID:      1,
+			email:       "owner@email.com",
+			pwd:         "qwerty",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"3"`,
+			body:        `{"city":"Monterrey"}`,
+			httpStatus:  412,
+		},
+		{
+			testName:    "invalid entity tag error",
+			method:      "PATCH",
+			userID:      1,
+			email:       "owner@email.com",
+			pwd:         "qwerty",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `W/"4"`,
+			body:        `{"city":"Monterrey"}`,
+			httpStatus:  400,
+		},
+		{
+			testName:    "patch validation error",
+			method:      "PATCH",
+			userID:      1,
+			email:       "owner@email.com",
+			pwd:         "qwerty",
+			contentType: "application/merge-patch+json",
+			body:        `{"height_m":3}`,
+			httpStatus:  400,
+		},
+		{
+			testName:   "details deleted success",
+			method:     "DELETE",
+			userID:     1,
+			email:      "owner@email.com",
+			pwd:        "qwerty",
+			httpStatus: 200,
+			res:        `{"success":true}`,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			uri := fmt.Sprintf("%v/users/%v/details", server.URL, tc.userID)
+			req, _ := http.NewRequest(tc.method, uri, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			if tc.email != "" {
+				req.SetBasicAuth(tc.email, tc.pwd)
+			}
+			res, _ := http.DefaultClient.Do(req)
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Equal(tc.challenge, res.Header.Get("WWW-Authenticate"))
+			assert.Equal(tc.etag, res.Header.Get("ETag"))
+			if tc.res != "" {
+				assert.JSONEq(tc.res, string(body))
+			}
+		})
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	srvMock.On("GetUser", mock.Anything, 1).Return(entities.User{Email: "email@domain.com", Password: "passsword"}, nil)
+
+	test_cases := []struct {
+		testName    string
+		path        string
+		version     string
+		httpStatus  int
+		password    bool
+		deprecation bool
+	}{
+		{
+			testName:    "v1 path",
+			path:        "/v1/users/1",
+			httpStatus:  200,
+			password:    true,
+			deprecation: true,
+		},
+		{
+			testName:    "v2 path",
+			path:        "/v2/users/1",
+			httpStatus:  200,
+			password:    false,
+			deprecation: false,
+		},
+		{
+			testName:    "default version without prefix",
+			path:        "/users/1",
+			httpStatus:  200,
+			password:    true,
+			deprecation: true,
+		},
+		{
+			testName:    "version selected by header",
+			path:        "/users/1",
+			version:     "2",
+			httpStatus:  200,
+			password:    false,
+			deprecation: false,
+		},
+		{
+			testName:    "unknown version selected by header",
+			path:        "/users/1",
+			version:     "v3",
+			httpStatus:  400,
+			password:    false,
+			deprecation: false,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var body map[string]interface{}
+
+			// act
+			req, _ := http.NewRequest("GET", server.URL+tc.path, http.NoBody)
+			req.Header.Set(transport.VersionHeader, tc.version)
+			res, _ := http.DefaultClient.Do(req)
+			json.NewDecoder(res.Body).Decode(&body)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			_, password := body["password"]
+			assert.Equal(tc.password, password)
+			assert.Equal(tc.deprecation, res.Header.Get("Deprecation") != "")
+			assert.Equal(tc.deprecation, res.Header.Get("Sunset") != "")
+		})
+	}
+}
+
+func TestStrictVersion(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	server := httptest.NewServer(transport.NewHTTPServer(context.Background(), endpoints, nil))
+	defer server.Close()
+	var problem transport.ProblemResponse
+
+	// act
+	body := `{"email":"email@domain.com","password":"qwerty","date_of_birth":"1996-02-29","nickname":"user"}`
+	res, _ := http.Post(server.URL+"/v2/users", "application/json", strings.NewReader(body))
+	json.NewDecoder(res.Body).Decode(&problem)
+
+	// assert
+	assert.Equal(400, res.StatusCode)
+	assert.Equal([]errors.FieldViolation{{Field: "nickname", Description: "is not a known field"}}, problem.Violations)
+	srvMock.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTenantResolution(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName   string
+		userID     int
+		header     string
+		host       string
+		tenant     string
+		httpStatus int
+	}{
+		{
+			testName:   "tenant from header success",
+			userID:     10,
+			header:     "acme",
+			tenant:     "acme",
+			httpStatus: 200,
+		},
+		{
+			testName:   "tenant from host success",
+			userID:     11,
+			host:       "globex.users.example.com",
+			tenant:     "globex",
+			httpStatus: 200,
+		},
+		{
+			testName:   "default tenant success",
+			userID:     12,
+			tenant:     tenant.Default,
+			httpStatus: 200,
+		},
+		{
+			testName:   "invalid tenant error",
+			userID:     13,
+			header:     "Acme Corp",
+			httpStatus: 400,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			expected := tc.tenant
+			scoped := mock.MatchedBy(func(ctx context.Context) bool {
+				id, _ := tenant.FromContext(ctx)
+				return id == expected
+			})
+
+			// act
+			srvMock.On("GetUser", scoped, tc.userID).Return(entities.User{}, nil)
+
+			uri := fmt.Sprintf("%v/users/%v", server.URL, tc.userID)
+			req, _ := http.NewRequest("GET", uri, http.NoBody)
+			if tc.header != "" {
+				req.Header.Set(tenant.HeaderKey, tc.header)
+			}
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestAddAddress(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "owner@email.com", "qwerty").Return(nil)
+
+	test_cases := []struct {
+		testName   string
+		userID     int
+		body       string
+		data       entities.Address
+		res        entities.Address
+		err        error
+		httpStatus int
+	}{
+		{
+			testName: "address added success",
+			userID:   1,
+			body: `
+				{
+					"lines": ["Av. Reforma 222"],
+					"locality": "CDMX",
+					"country_code": "MX",
+					"label": "home"
+				}`,
+			data: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+				Label:       "home",
+			},
+			res: entities.Address{
+				ID:          "61b0c0f1e4b0a1a2b3c4d5e6",
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+				Label:       "home",
+				Primary:     true,
+			},
+			err:        nil,
+			httpStatus: 200,
+		},
+		{
+			testName: "invalid country code error",
+			userID:   2,
+			body: `
+				{
+					"lines": ["Av. Reforma 222"],
+					"locality": "CDMX",
+					"country_code": "MEX"
+				}`,
+			data: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MEX",
+			},
+			err:        status.Error(codes.FailedPrecondition, "Invalid address 'country_code': not an ISO 3166-1 alpha-2 code"),
+			httpStatus: 400,
+		},
+		{
+			testName: "user not found error",
+			userID:   3,
+			body: `
+				{
+					"lines": ["Av. Reforma 222"],
+					"locality": "CDMX",
+					"country_code": "MX"
+				}`,
+			data: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+			},
+			err:        status.Error(codes.NotFound, "User not found"),
+			httpStatus: 404,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("AddAddress", mock.Anything, tc.userID, tc.data).Return(tc.res, tc.err)
+
+			uri := fmt.Sprintf("%v/users/%v/addresses", server.URL, tc.userID)
+			req, _ := http.NewRequest("POST", uri, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetBasicAuth("owner@email.com", "qwerty")
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestDeleteAddress(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "owner@email.com", "qwerty").Return(nil)
+
+	test_cases := []struct {
+		testName   string
+		userID     int
+		addressID  string
+		res        bool
+		err        error
+		httpStatus int
+	}{
+		{
+			testName:   "address deleted success",
+			userID:     1,
+			addressID:  "61b0c0f1e4b0a1a2b3c4d5e6",
+			res:        true,
+			err:        nil,
+			httpStatus: 200,
+		},
+		{
+			testName:   "address not found error",
+			userID:     1,
+			addressID:  "61b0c0f1e4b0a1a2b3c4d5e7",
+			err:        status.Error(codes.NotFound, "Address not found"),
+			httpStatus: 404,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("DeleteAddress", mock.Anything, tc.userID, tc.addressID).Return(tc.res, tc.err)
+
+			uri := fmt.Sprintf("%v/users/%v/addresses/%v", server.URL, tc.userID, tc.addressID)
+			req, _ := http.NewRequest("DELETE", uri, http.NoBody)
+			req.SetBasicAuth("owner@email.com", "qwerty")
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestVerifyPhone(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "owner@email.com", "qwerty").Return(nil)
+
+	test_cases := []struct {
+		testName   string
+		userID     int
+		body       string
+		code       string
+		res        bool
+		err        error
+		httpStatus int
+	}{
+		{
+			testName:   "phone verified success",
+			userID:     1,
+			body:       `{"code": "123456"}`,
+			code:       "123456",
+			res:        true,
+			err:        nil,
+			httpStatus: 200,
+		},
+		{
+			testName:   "invalid code error",
+			userID:     2,
+			body:       `{"code": "000000"}`,
+			code:       "000000",
+			err:        status.Error(codes.FailedPrecondition, "Invalid or expired verification code"),
+			httpStatus: 400,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("VerifyPhone", mock.Anything, tc.userID, tc.code).Return(tc.res, tc.err)
+
+			uri := fmt.Sprintf("%v/users/%v/phone/verification/confirm", server.URL, tc.userID)
+			req, _ := http.NewRequest("POST", uri, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetBasicAuth("owner@email.com", "qwerty")
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestUploadAvatar(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "owner@email.com", "qwerty").Return(nil)
+
+	test_cases := []struct {
+		testName   string
+		userID     int
+		field      string
+		res        string
+		err        error
+		httpStatus int
+	}{
+		{
+			testName:   "avatar uploaded success",
+			userID:     1,
+			field:      "avatar",
+			res:        "/users/1/avatar?v=0a1b2c3d",
+			err:        nil,
+			httpStatus: 200,
+		},
+		{
+			testName:   "missing avatar field error",
+			userID:     2,
+			field:      "picture",
+			httpStatus: 400,
+		},
+		{
+			testName:   "avatar too large error",
+			userID:     3,
+			field:      "avatar",
+			err:        errors.Status(errors.NewAvatarTooLargeError()).Err(),
+			httpStatus: 413,
+		},
+		{
+			testName:   "upload quota exhausted error",
+			userID:     4,
+			field:      "avatar",
+			err:        status.Error(codes.ResourceExhausted, "Upload quota exhausted"),
+			httpStatus: 429,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			part, _ := form.CreateFormFile(tc.field, "avatar.png")
+			part.Write([]byte("image"))
+			form.Close()
+
+			// act
+			srvMock.On("UploadAvatar", mock.Anything, tc.userID, mock.Anything).Return(tc.res, tc.err)
+
+			uri := fmt.Sprintf("%v/users/%v/avatar", server.URL, tc.userID)
+			req, _ := http.NewRequest("POST", uri, &body)
+			req.Header.Set("Content-Type", form.FormDataContentType())
+			req.SetBasicAuth("owner@email.com", "qwerty")
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestOwnedRoutes(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	srvMock.On("AuthorizeUser", mock.Anything, mock.Anything, "", "").Return(status.Error(codes.Unauthenticated, "Unauthenticated"))
+	srvMock.On("AuthorizeUser", mock.Anything, 2, "owner@email.com", "qwerty").Return(status.Error(codes.PermissionDenied, "Forbidden"))
+
+	var avatar bytes.Buffer
+	form := multipart.NewWriter(&avatar)
+	part, _ := form.CreateFormFile("avatar", "avatar.png")
+	part.Write([]byte("image"))
+	form.Close()
+
+	routes := []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+	}{
+		{method: "GET", path: "/users/%v/addresses"},
+		{method: "POST", path: "/users/%v/addresses", contentType: "application/json", body: `{"lines": ["Av. Reforma 222"], "locality": "CDMX", "country_code": "MX"}`},
+		{method: "GET", path: "/users/%v/addresses/61b0c0f1e4b0a1a2b3c4d5e6"},
+		{method: "PUT", path: "/users/%v/addresses/61b0c0f1e4b0a1a2b3c4d5e6", contentType: "application/json", body: `{"lines": ["Av. Reforma 222"], "locality": "CDMX", "country_code": "MX"}`},
+		{method: "DELETE", path: "/users/%v/addresses/61b0c0f1e4b0a1a2b3c4d5e6"},
+		{method: "POST", path: "/users/%v/phone/verification"},
+		{method: "POST", path: "/users/%v/phone/verification/confirm", contentType: "application/json", body: `{"code": "123456"}`},
+		{method: "POST", path: "/users/%v/avatar", contentType: form.FormDataContentType(), body: avatar.String()},
+	}
+
+	test_cases := []struct {
+		testName   string
+		userID     int
+		email      string
+		pwd        string
+		httpStatus int
+		challenge  string
+	}{
+		{
+			testName:   "missing credentials error",
+			userID:     1,
+			httpStatus: 401,
+			challenge:  `Basic realm="users", charset="UTF-8"`,
+		},
+		{
+			testName:   "another user forbidden error",
+			userID:     2,
+			email:      "owner@email.com",
+			pwd:        "qwerty",
+			httpStatus: 403,
+		},
+	}
+
+	for _, tc := range test_cases {
+		for _, route := range routes {
+			t.Run(tc.testName+" "+route.method+" "+route.path, func(t *testing.T) {
+				// prepare
+				assert := assert.New(t)
+
+				// act
+				uri := server.URL + fmt.Sprintf(route.path, tc.userID)
+				req, _ := http.NewRequest(route.method, uri, strings.NewReader(route.body))
+				if route.contentType != "" {
+					req.Header.Set("Content-Type", route.contentType)
+				}
+				if tc.email != "" {
+					req.SetBasicAuth(tc.email, tc.pwd)
+				}
+				res, _ := http.DefaultClient.Do(req)
+				res.Body.Close()
+
+				// assert
+				assert.Equal(tc.httpStatus, res.StatusCode)
+				assert.Equal(tc.challenge, res.Header.Get("WWW-Authenticate"))
+			})
+		}
+	}
+}
+
+func TestGetAvatar(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName    string
+		userID      int
+		size        int
+		contentType string
+		err         error
+		httpStatus  int
+	}{
+		{
+			testName:    "avatar found success",
+			userID:      1,
+			size:        64,
+			contentType: "image/png",
+			err:         nil,
+			httpStatus:  200,
+		},
+		{
+			testName:    "avatar not found error",
+			userID:      2,
+			contentType: "application/problem+json",
+			err:         status.Error(codes.NotFound, "Avatar not found"),
+			httpStatus:  404,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var avatar io.ReadCloser
+			if tc.err == nil {
+				avatar = io.NopCloser(strings.NewReader("image"))
+			}
+
+			// act
+			srvMock.On("GetAvatar", mock.Anything, tc.userID, tc.size).Return(avatar, tc.contentType, tc.err)
+
+			uri := fmt.Sprintf("%v/users/%v/avatar?size=%v", server.URL, tc.userID, tc.size)
+			res, _ := http.Get(uri)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Equal(tc.contentType, res.Header.Get("Content-Type"))
+			if tc.err == nil {
+				assert.Equal("private, max-age=86400", res.Header.Get("Cache-Control"))
+				assert.Contains(res.Header.Values("Vary"), "X-Tenant-ID")
+			}
+		})
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, adminTokens)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName    string
+		query       string
+		contentType string
+		format      string
+		dryRun      bool
+		res         bulk.Report
+		err         error
+		httpStatus  int
+	}{
+		{
+			testName:    "csv import success",
+			query:       "",
+			contentType: "text/csv",
+			format:      bulk.CSV,
+			res:         bulk.Report{Total: 1, Succeeded: 1, Rows: []bulk.RowResult{{Line: 2, Email: "email@domain.com", UserID: 1}}},
+			err:         nil,
+			httpStatus:  200,
+		},
+		{
+			testName:    "jsonl dry run success",
+			query:       "?format=jsonl&dry_run=true",
+			contentType: "application/octet-stream",
+			format:      bulk.JSONL,
+			dryRun:      true,
+			res:         bulk.Report{DryRun: true, Total: 1, Succeeded: 1, Rows: []bulk.RowResult{{Line: 1, Email: "email@domain.com"}}},
+			err:         nil,
+			httpStatus:  200,
+		},
+		{
+			testName:    "invalid dry run error",
+			query:       "?format=csv&dry_run=maybe",
+			contentType: "text/csv",
+			httpStatus:  400,
+		},
+		{
+			testName:    "unsupported format error",
+			query:       "?format=xml",
+			contentType: "text/xml",
+			format:      "xml",
+			err:         status.Error(codes.FailedPrecondition, "Invalid format, expected 'csv' or 'jsonl'"),
+			httpStatus:  400,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("ImportUsers", mock.Anything, tc.format, mock.Anything, tc.dryRun).Return(tc.res, tc.err)
+
+			uri := fmt.Sprintf("%v/users:import%v", server.URL, tc.query)
+			req, _ := http.NewRequest("POST", uri, strings.NewReader("rows"))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Authorization", "Bearer admin-token")
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, adminTokens)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName    string
+		token       string
+		query       string
+		format      string
+		contentType string
+		httpStatus  int
+	}{
+		{
+			testName:    "jsonl export by default",
+			token:       "admin-token",
+			query:       "",
+			format:      bulk.JSONL,
+			contentType: "application/x-ndjson",
+			httpStatus:  200,
+		},
+		{
+			testName:    "csv export success",
+			token:       "admin-token",
+			query:       "?format=csv",
+			format:      bulk.CSV,
+			contentType: "text/csv; charset=utf-8",
+			httpStatus:  200,
+		},
+		{
+			testName:    "unsupported format error",
+			token:       "admin-token",
+			query:       "?format=xml",
+			contentType: "application/problem+json",
+			httpStatus:  400,
+		},
+		{
+			testName:    "missing admin token error",
+			query:       "?format=csv",
+			contentType: "application/problem+json",
+			httpStatus:  401,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("ExportUsers", mock.Anything, tc.format, mock.Anything).Return(0, nil)
+
+			req, _ := http.NewRequest("GET", fmt.Sprintf("%v/users:export%v", server.URL, tc.query), http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Equal(tc.contentType, res.Header.Get("Content-Type"))
+		})
+	}
+}
+
+// adminTokens only opens the admin routes of the default tenant
+var adminTokens = map[string]string{tenant.Default: "admin-token"}
+
+func TestCreateWebhook(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, adminTokens)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName      string
+		tenant        string
+		authorization string
+		body          string
+		data          webhook.Subscription
+		res           webhook.Subscription
+		err           error
+		httpStatus    int
+	}{
+		{
+			testName:      "webhook created success",
+			authorization: "Bearer admin-token",
+			body:          `{"url": "https://crm.example.com/hooks", "events": ["UserCreated"]}`,
+			data:          webhook.Subscription{URL: "https://crm.example.com/hooks", Events: []events.Type{events.UserCreated}},
+			res:           webhook.Subscription{ID: "0123456789abcdef0123456789abcdef", URL: "https://crm.example.com/hooks", Secret: "secret"},
+			httpStatus:    200,
+		},
+		{
+			testName:      "invalid webhook error",
+			authorization: "Bearer admin-token",
+			body:          `{"url": "/hooks"}`,
+			data:          webhook.Subscription{URL: "/hooks"},
+			err:           status.Error(codes.FailedPrecondition, "Invalid webhook 'url': expected an absolute http or https URL"),
+			httpStatus:    400,
+		},
+		{
+			testName:      "wrong admin token error",
+			authorization: "Bearer other-token",
+			body:          `{"url": "https://crm.example.com/hooks"}`,
+			httpStatus:    401,
+		},
+		{
+			testName:      "admin token of another tenant error",
+			tenant:        "acme",
+			authorization: "Bearer admin-token",
+			body:          `{"url": "https://crm.example.com/hooks"}`,
+			httpStatus:    401,
+		},
+		{
+			testName:   "missing admin token error",
+			body:       `{"url": "https://crm.example.com/hooks"}`,
+			httpStatus: 401,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("CreateWebhook", mock.Anything, tc.data).Return(tc.res, tc.err)
+
+			req, _ := http.NewRequest("POST", server.URL+"/admin/webhooks", strings.NewReader(tc.body))
+			if tc.tenant != "" {
+				req.Header.Set(tenant.HeaderKey, tc.tenant)
+			}
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestListWebhooks(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, adminTokens)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	subscriptions := []webhook.Subscription{{ID: "0123456789abcdef0123456789abcdef", URL: "https://crm.example.com/hooks", Secret: "secret"}}
+	srvMock.On("ListWebhooks", mock.Anything).Return(subscriptions, nil)
+
+	// act
+	req, _ := http.NewRequest("GET", server.URL+"/admin/webhooks", http.NoBody)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	res, _ := http.DefaultClient.Do(req)
+	body, _ := io.ReadAll(res.Body)
+
+	// assert
+	assert.Equal(200, res.StatusCode)
+	assert.Contains(string(body), "https://crm.example.com/hooks")
+	assert.NotContains(string(body), "secret")
+}
+
+func TestReplayDelivery(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, adminTokens)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName   string
+		webhookID  string
+		deliveryID string
+		res        webhook.Delivery
+		err        error
+		httpStatus int
+	}{
+		{
+			testName:   "delivery replayed success",
+			webhookID:  "0123456789abcdef0123456789abcdef",
+			deliveryID: "1123456789abcdef0123456789abcdef",
+			res:        webhook.Delivery{ID: "2123456789abcdef0123456789abcdef", ReplayOf: "1123456789abcdef0123456789abcdef", Status: webhook.Pending},
+			httpStatus: 200,
+		},
+		{
+			testName:   "delivery not found error",
+			webhookID:  "0123456789abcdef0123456789abcdef",
+			deliveryID: "3123456789abcdef0123456789abcdef",
+			err:        status.Error(codes.NotFound, "Delivery not found"),
+			httpStatus: 404,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("ReplayDelivery", mock.Anything, tc.webhookID, tc.deliveryID).Return(tc.res, tc.err)
+
+			uri := fmt.Sprintf("%v/admin/webhooks/%v/deliveries/%v/replay", server.URL, tc.webhookID, tc.deliveryID)
+			req, _ := http.NewRequest("POST", uri, http.NoBody)
+			req.Header.Set("Authorization", "Bearer admin-token")
+			res, _ := http.DefaultClient.Do(req)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestListChanges(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, adminTokens)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName   string
+		token      string
+		query      string
+		cursor     string
+		pageSize   int
+		res        []entities.Change
+		next       string
+		err        error
+		httpStatus int
+	}{
+		{
+			testName: "list changes success",
+			token:    "admin-token",
+			query:    "?cursor=abc&page_size=2",
+			cursor:   "abc",
+			pageSize: 2,
+			res: []entities.Change{
+				{ID: "u1", Source: entities.UserSource, Type: "UserCreated", UserID: 1, Cursor: "cde"},
+				{ID: "d1", Source: entities.DetailsSource, Type: "DetailsSet", UserID: 1, Data: map[string]interface{}{"country": "Mexico"}, Cursor: "def"},
+			},
+			next:       "def",
+			httpStatus: 200,
+		},
+		{
+			testName:   "invalid cursor error",
+			token:      "admin-token",
+			query:      "?cursor=unknown&page_size=none",
+			cursor:     "unknown",
+			res:        []entities.Change{},
+			err:        status.Error(codes.FailedPrecondition, "Invalid or unknown cursor"),
+			httpStatus: 400,
+		},
+		{
+			testName:   "missing admin token error",
+			query:      "?cursor=abc&page_size=2",
+			httpStatus: 401,
+		},
+		{
+			testName:   "wrong admin token error",
+			token:      "qwerty",
+			query:      "?cursor=abc&page_size=2",
+			httpStatus: 401,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			srvMock.On("ListChanges", mock.Anything, tc.cursor, tc.pageSize).Return(tc.res, tc.next, tc.err)
+			req, _ := http.NewRequest("GET", server.URL+"/users/changes"+tc.query, http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			res, _ := http.DefaultClient.Do(req)
+			body, _ := io.ReadAll(res.Body)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			if tc.httpStatus == 200 {
+				assert.Contains(string(body), `"next_cursor":"def"`)
+				assert.Contains(string(body), `"source":"user"`)
+				assert.Contains(string(body), `"data":{"country":"Mexico"}`)
+			}
+		})
+	}
+}
+
+func TestWatchUser(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName    string
+		userID      int
+		lastEventID string
+		changes     []entities.Change
+		events      string
+		err         error
+		httpStatus  int
+	}{
+		{
+			testName:    "watch user resumes after the last event id",
+			userID:      1,
+			lastEventID: "abc",
+			changes: []entities.Change{
+				{ID: "u1", Source: entities.UserSource, Type: "UserUpdated", UserID: 1, Cursor: "def"},
+			},
+			events:     "id: def\nevent: UserUpdated\ndata: {\"id\":\"u1\",\"source\":\"user\",\"type\":\"UserUpdated\",\"user_id\":1,\"occurred_at\":\"0001-01-01T00:00:00Z\",\"cursor\":\"def\"}\n\n",
+			httpStatus: 200,
+		},
+		{
+			testName: "details withheld from anonymous watchers",
+			userID:   3,
+			changes: []entities.Change{
+				{ID: "d1", Source: entities.DetailsSource, Type: "DetailsSet", UserID: 3, Data: map[string]interface{}{"country": "Mexico"}, Cursor: "def"},
+			},
+			events:     "id: def\nevent: DetailsSet\ndata: {\"id\":\"d1\",\"source\":\"details\",\"type\":\"DetailsSet\",\"user_id\":3,\"occurred_at\":\"0001-01-01T00:00:00Z\",\"cursor\":\"def\"}\n\n",
+			httpStatus: 200,
+		},
+		{
+			testName:   "user not found error",
+			userID:     2,
+			err:        status.Error(codes.NotFound, "User not found"),
+			httpStatus: 404,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			changes := make(chan entities.Change, len(tc.changes))
+			for _, c := range tc.changes {
+				changes <- c
+			}
+			close(changes)
+
+			var stream <-chan entities.Change = changes
+			if tc.err != nil {
+				stream = nil
+			}
+
+			// act
+			srvMock.On("WatchUser", mock.Anything, tc.userID, tc.lastEventID).Return(stream, tc.err)
+			req, _ := http.NewRequest("GET", fmt.Sprintf("%v/users/%v/events", server.URL, tc.userID), nil)
+			req.Header.Set("Last-Event-ID", tc.lastEventID)
+			res, _ := http.DefaultClient.Do(req)
+			body, _ := io.ReadAll(res.Body)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			if tc.err == nil {
+				assert.Equal("text/event-stream", res.Header.Get("Content-Type"))
+				assert.Equal(tc.events, string(body))
+			}
+		})
+	}
+}
+
+func TestProblemResponse(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName string
+		userID   int
+		header   string
+		language string
+		err      error
+		content  string
+		problem  transport.ProblemResponse
+	}{
+		{
+			testName: "field violations from the details",
+			userID:   1,
+			err:      errors.Status(errors.NewInvalidDateOfBirthError()).Err(),
+			problem: transport.ProblemResponse{
+				Type:       "about:blank",
+				Title:      "Bad Request",
+				Status:     400,
+				Detail:     "Invalid field 'date_of_birth', expected a past date as YYYY-MM-DD",
+				Reason:     "INVALID_DATE_OF_BIRTH",
+				Violations: []errors.FieldViolation{{Field: "date_of_birth", Description: "expected a past date as YYYY-MM-DD"}},
+			},
+		},
+		{
+			testName: "status without details",
+			userID:   2,
+			err:      status.Error(codes.NotFound, "User not found"),
+			problem: transport.ProblemResponse{
+				Type:   "about:blank",
+				Title:  "Not Found",
+				Status: 404,
+				Detail: "User not found",
+			},
+		},
+		{
+			testName: "problem from middleware",
+			userID:   3,
+			header:   "Acme Corp",
+			content:  "en",
+			problem: transport.ProblemResponse{
+				Type:   "about:blank",
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: "Missing or invalid tenant",
+				Reason: "MISSING_TENANT",
+			},
+		},
+		{
+			testName: "message localized by the backend",
+			userID:   4,
+			language: "es-MX, en;q=0.8",
+			err:      errors.LocalizedStatus(locale.NewContext(context.Background(), "es"), errors.NewUserNotFoundError()).Err(),
+			content:  "es",
+			problem: transport.ProblemResponse{
+				Type:   "about:blank",
+				Title:  "Not Found",
+				Status: 404,
+				Detail: "Usuario no encontrado",
+				Reason: "USER_NOT_FOUND",
+			},
+		},
+		{
+			testName: "problem from middleware localized",
+			userID:   5,
+			header:   "Acme Corp",
+			language: "pt-BR",
+			content:  "pt",
+			problem: transport.ProblemResponse{
+				Type:   "about:blank",
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: "Inquilino ausente ou inválido",
+				Reason: "MISSING_TENANT",
+			},
+		},
+		{
+			testName: "unsupported language falls back to english",
+			userID:   6,
+			header:   "Acme Corp",
+			language: "fr",
+			content:  "en",
+			problem: transport.ProblemResponse{
+				Type:   "about:blank",
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: "Missing or invalid tenant",
+				Reason: "MISSING_TENANT",
+			},
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var problem transport.ProblemResponse
+
+			// act
+			srvMock.On("GetUser", mock.Anything, tc.userID).Return(entities.User{}, tc.err)
+			req, _ := http.NewRequest("GET", fmt.Sprintf("%v/users/%v", server.URL, tc.userID), nil)
+			req.Header.Set(tenant.HeaderKey, tc.header)
+			req.Header.Set(locale.HeaderKey, tc.language)
+			res, _ := http.DefaultClient.Do(req)
+			json.NewDecoder(res.Body).Decode(&problem)
+
+			// assert
+			assert.Equal("application/problem+json", res.Header.Get("Content-Type"))
+			assert.Equal(tc.content, res.Header.Get("Content-Language"))
+			assert.Equal(tc.problem.Status, res.StatusCode)
+			assert.Equal(tc.problem, problem)
+		})
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	srvMock := new(transport.ServiceMock)
+	endpoints := transport.MakeHTTPEndpoints(srvMock)
+	s := transport.NewHTTPServer(context.Background(), endpoints, nil)
+	server := httptest.NewServer(s)
+
+	defer server.Close()
+
+	test_cases := []struct {
+		testName   string
+		method     string
+		path       string
+		userID     int
+		body       string
+		err        error
+		reason     string
+		violations []errors.FieldViolation
+		httpStatus int
+	}{
+		{
+			testName:   "malformed JSON body",
+			method:     "POST",
+			path:       "/users",
+			body:       `{"email": "user@email.com",`,
+			reason:     "INVALID_REQUEST",
+			violations: []errors.FieldViolation{{Field: "body", Description: "malformed JSON"}},
+			httpStatus: 400,
+		},
+		{
+			testName:   "empty JSON body",
+			method:     "POST",
+			path:       "/auth",
+			reason:     "INVALID_REQUEST",
+			violations: []errors.FieldViolation{{Field: "body", Description: "is required"}},
+			httpStatus: 400,
+		},
+		{
+			testName:   "wrong JSON type",
+			method:     "POST",
+			path:       "/auth",
+			body:       `{"email": 10}`,
+			reason:     "INVALID_REQUEST",
+			violations: []errors.FieldViolation{{Field: "email", Description: "expected string"}},
+			httpStatus: 400,
+		},
+		{
+			testName:   "non-numeric id",
+			method:     "GET",
+			path:       "/users/abc",
+			reason:     "INVALID_REQUEST",
+			violations: []errors.FieldViolation{{Field: "id", Description: "expected an integer"}},
+			httpStatus: 400,
+		},
+		{
+			testName:   "deadline exceeded",
+			method:     "GET",
+			path:       "/users/1",
+			userID:     1,
+			err:        context.DeadlineExceeded,
+			httpStatus: 504,
+		},
+		{
+			testName:   "unavailable backend",
+			method:     "GET",
+			path:       "/users/2",
+			userID:     2,
+			err:        status.Error(codes.Unavailable, "connection refused"),
+			httpStatus: 503,
+		},
+		{
+			testName:   "plain error",
+			method:     "GET",
+			path:       "/users/3",
+			userID:     3,
+			err:        fmt.Errorf("unexpected failure"),
+			reason:     "INTERNAL",
+			httpStatus: 500,
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var problem transport.ProblemResponse
+
+			// act
+			if tc.err != nil {
+				srvMock.On("GetUser", mock.Anything, tc.userID).Return(entities.User{}, tc.err)
+			}
+			req, _ := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+			res, _ := http.DefaultClient.Do(req)
+			json.NewDecoder(res.Body).Decode(&problem)
+
+			// assert
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Equal("application/problem+json", res.Header.Get("Content-Type"))
+			assert.Equal(tc.httpStatus, problem.Status)
+			assert.Equal(tc.reason, problem.Reason)
+			assert.Equal(tc.violations, problem.Violations)
+		})
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	handler := transport.RecoveryMiddleware(log.NewNopLogger())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	var problem transport.ProblemResponse
+
+	// act
+	res, err := http.Get(server.URL + "/users/1")
+	json.NewDecoder(res.Body).Decode(&problem)
+
+	// assert
+	assert.NoError(err)
+	assert.Equal(500, res.StatusCode)
+	assert.Equal("application/problem+json", res.Header.Get("Content-Type"))
+	assert.Equal("INTERNAL", problem.Reason)
+}
+
+func TestOpenAPI(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	router := transport.NewHTTPServer(context.Background(), transport.HTTPEndpoints{}, nil).(*mux.Router)
+	routes := map[string]bool{}
+	operations := map[string]bool{"GET /docs": true}
+
+	// act
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, errPath := route.GetPathTemplate()
+		methods, errMethods := route.GetMethods()
+		if errPath != nil || errMethods != nil {
+			return nil
+		}
+		for _, m := range methods {
+			routes[m+" "+path] = true
+		}
+		return nil
+	})
+	for _, version := range []string{"v1", "v2"} {
+		doc := transport.OpenAPI(version)
+		for _, prefix := range []string{"", doc.Servers[0].URL} {
+			operations["GET "+prefix+"/openapi.json"] = true
+			for path, item := range doc.Paths {
+				for method := range item {
+					operations[strings.ToUpper(method)+" "+prefix+path] = true
+				}
+			}
+		}
+	}
+
+	// assert
+	assert.Equal(operations, routes)
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	server := httptest.NewServer(transport.NewHTTPServer(context.Background(), transport.HTTPEndpoints{}, nil))
+	defer server.Close()
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+
+	// act
+	res, err := http.Get(server.URL + "/v2/openapi.json")
+	json.NewDecoder(res.Body).Decode(&doc)
+	page, _ := http.Get(server.URL + "/docs")
+
+	// assert
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("3.1.0", doc.OpenAPI)
+	assert.Contains(doc.Paths, "/users/{id}")
+	assert.Equal(200, page.StatusCode)
+	assert.Equal("text/html; charset=utf-8", page.Header.Get("Content-Type"))
+}
+
+func TestOpenAPIMiddleware(t *testing.T) {
+	test_cases := []struct {
+		testName   string
+		body       string
+		calls      int
+		httpStatus int
+		violations []errors.FieldViolation
+	}{
+		{
+			testName:   "valid request reaches the handler",
+			body:       `{"email":"user@email.com","password":"qwerty","date_of_birth":"1996-02-29"}`,
+			calls:      1,
+			httpStatus: 200,
+		},
+		{
+			testName:   "invalid request rejected",
+			body:       `{"email":"user","password":"qwerty","information":{"height_m":"tall"}}`,
+			calls:      0,
+			httpStatus: 400,
+			violations: []errors.FieldViolation{
+				{Field: "date_of_birth", Description: "is required"},
+				{Field: "email", Description: "must be a valid email address"},
+				{Field: "information.height_m", Description: "must be a number"},
+			},
+		},
+	}
+
+	for _, tc := range test_cases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var calls int
+			handler := transport.OpenAPIMiddleware()(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				calls++
+			}))
+			server := httptest.NewServer(handler)
+			defer server.Close()
+			var problem transport.ProblemResponse
+
+			// act
+			res, err := http.Post(server.URL+"/users", "application/json", strings.NewReader(tc.body))
+			json.NewDecoder(res.Body).Decode(&problem)
+
+			// assert
+			assert.NoError(err)
+			assert.Equal(tc.calls, calls)
+			assert.Equal(tc.httpStatus, res.StatusCode)
+			assert.Equal(tc.violations, problem.Violations)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mauricioww/user_microsrv/errors"
+)
+
+// VersionHeader selects the version of the API served on the paths without a version prefix
+const VersionHeader = "API-Version"
+
+// apiVersion stores how one version of the API encodes its bodies, every version is served by the same endpoints
+type apiVersion struct {
+	name string
+	// strict rejects the JSON bodies with fields the request does not declare
+	strict bool
+	// response converts the response of an endpoint into the body of the version
+	response func(response interface{}) interface{}
+
+	deprecation time.Time
+	sunset      time.Time
+	successor   string
+}
+
+var (
+	v1 = apiVersion{
+		name:        "v1",
+		response:    func(response interface{}) interface{} { return response },
+		deprecation: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		sunset:      time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+		successor:   "v2",
+	}
+	v2 = apiVersion{
+		name:     "v2",
+		strict:   true,
+		response: responseV2,
+	}
+
+	// versions are ordered from the default version, the one served when no version is selected, to the latest
+	versions = []apiVersion{v1, v2}
+)
+
+type versionKey struct{}
+
+// encode writes the response as the JSON body of the version
+func (v apiVersion) encode(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, rw, v.response(response))
+}
+
+// versionMiddleware stores the version within the request context and warns the clients of the deprecated versions
+// with the Deprecation, Sunset and Link headers
+func versionMiddleware(v apiVersion) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if !v.deprecation.IsZero() {
+				rw.Header().Set("Deprecation", fmt.Sprintf("@%v", v.deprecation.Unix()))
+				rw.Header().Set("Sunset", v.sunset.Format(http.TimeFormat))
+				rw.Header().Set("Link", fmt.Sprintf(`</%v>; rel="successor-version"`, v.successor))
+			}
+
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), versionKey{}, v)))
+		})
+	}
+}
+
+// defaultVersionMiddleware rejects the unknown versions selected by the header on the paths without a version prefix
+func defaultVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if _, ok := selectVersion(r.Header.Get(VersionHeader)); !ok {
+			names := make([]string, len(versions))
+			for i, v := range versions {
+				names[i] = v.name
+			}
+
+			e := errors.NewInvalidRequestError(errors.FieldViolation{Field: VersionHeader, Description: fmt.Sprintf("must be one of %v", strings.Join(names, ", "))})
+			writeProblem(rw, errors.LocalizedStatus(r.Context(), e))
+			return
+		}
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// selectsVersion matches the requests whose header selects the version
+func selectsVersion(v apiVersion) mux.MatcherFunc {
+	return func(r *http.Request, rm *mux.RouteMatch) bool {
+		selected, ok := selectVersion(r.Header.Get(VersionHeader))
+		return ok && selected.name == v.name
+	}
+}
+
+// selectVersion returns the version named by the header, "2" and "v2" both select v2, and the default version when
+// the header is empty
+func selectVersion(header string) (apiVersion, bool) {
+	header = strings.ToLower(strings.TrimSpace(header))
+	if header == "" {
+		return versions[0], true
+	}
+
+	for _, v := range versions {
+		if header == v.name || "v"+header == v.name {
+			return v, true
+		}
+	}
+	return apiVersion{}, false
+}
+
+// requestVersion returns the version of the request from its path prefix or its header
+func requestVersion(r *http.Request) (apiVersion, bool) {
+	for _, v := range versions {
+		if strings.HasPrefix(r.URL.Path, "/"+v.name+"/") {
+			return v, true
+		}
+	}
+
+	return selectVersion(r.Header.Get(VersionHeader))
+}
+
+// decodeJSON decodes the body by the rules of the version of the request, the unknown fields of the strict versions
+// are returned as field violations
+func decodeJSON(ctx context.Context, body io.Reader, request interface{}) error {
+	decoder := json.NewDecoder(body)
+	if v, ok := ctx.Value(versionKey{}).(apiVersion); ok && v.strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(request)
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return errors.NewInvalidRequestError(errors.FieldViolation{Field: field, Description: "is not a known field"})
+	}
+	return err
+}
+
+// responseV2 drops the password of the users from the responses
+func responseV2(response interface{}) interface{} {
+	switch res := response.(type) {
+	case CreateUserResponse:
+		return CreateUserResponseV2{UserID: res.UserID, Email: res.Email, DateOfBirth: res.DateOfBirth, Details: res.Details}
+	case GetUserResponse:
+		return GetUserResponseV2{UserID: res.UserID, Email: res.Email, DateOfBirth: res.DateOfBirth, Age: res.Age, Details: res.Details, DetailsStatus: res.DetailsStatus}
+	default:
+		return response
+	}
+}
@@ -0,0 +1,440 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/tenant"
+)
+
+// Default settings of the dispatcher
+const (
+	DefaultMaxAttempts = 8
+	DefaultBaseDelay   = 10 * time.Second
+	DefaultMaxDelay    = time.Hour
+	DefaultTimeout     = 10 * time.Second
+	DefaultRetention   = 30 * 24 * time.Hour
+	DefaultWorkers     = 4
+)
+
+// pollInterval is how often the dispatcher looks for deliveries whose back-off expired
+const pollInterval = time.Second
+
+// Options stores the settings of a Dispatcher, zero values fall back to the defaults
+type Options struct {
+	// MaxAttempts is the number of failed attempts after which a delivery is dead
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure, it doubles after every other failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Timeout bounds every request sent to a subscriber
+	Timeout time.Duration
+	// Retention is how long the completed deliveries are kept to be listed and replayed
+	Retention time.Duration
+	// Workers is the number of deliveries sent at the same time
+	Workers int
+	// AllowPrivateNetworks lets the subscriptions reach the loopback and private addresses, it is only
+	// meant for the tests and the local setups
+	AllowPrivateNetworks bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = DefaultBaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = DefaultMaxDelay
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.Retention <= 0 {
+		o.Retention = DefaultRetention
+	}
+	if o.Workers <= 0 {
+		o.Workers = DefaultWorkers
+	}
+	return o
+}
+
+// Manager describes the management of the subscriptions of the tenant stored within the context
+type Manager interface {
+	Subscribe(ctx context.Context, s Subscription) (Subscription, error)
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	Unsubscribe(ctx context.Context, id string) error
+	Deliveries(ctx context.Context, subscriptionID string, status Status) ([]Delivery, error)
+	Replay(ctx context.Context, subscriptionID string, deliveryID string) (Delivery, error)
+}
+
+// Dispatcher implements the Manager interface and sends the events received from the bus to the subscriptions,
+// every delivery is stored before it is sent so a restart resumes the pending ones
+type Dispatcher struct {
+	store   Store
+	client  *http.Client
+	options Options
+	wake    chan struct{}
+	now     func() time.Time
+	logger  log.Logger
+}
+
+// NewDispatcher returns a Dispatcher pointer type
+func NewDispatcher(store Store, options Options, logger log.Logger) *Dispatcher {
+	options = options.withDefaults()
+
+	return &Dispatcher{
+		store:   store,
+		client:  newClient(options.Timeout, options.AllowPrivateNetworks),
+		options: options,
+		wake:    make(chan struct{}, 1),
+		now:     time.Now,
+		logger:  log.With(logger, "component", "webhooks"),
+	}
+}
+
+// Subscribe validates and stores the subscription, a secret is generated when none is given
+func (d *Dispatcher) Subscribe(ctx context.Context, s Subscription) (Subscription, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return Subscription{}, errors.NewMissingTenantError()
+	}
+
+	if err := validate(s, d.options.AllowPrivateNetworks); err != nil {
+		return Subscription{}, err
+	}
+
+	if s.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return Subscription{}, errors.NewInternalError()
+		}
+		s.Secret = secret
+	}
+
+	s.ID = newID()
+	s.Tenant = t
+	s.CreatedAt = d.now().UTC()
+
+	if err := d.store.SaveSubscription(ctx, s); err != nil {
+		return Subscription{}, errors.NewInternalError()
+	}
+
+	return s, nil
+}
+
+// Subscriptions returns the subscriptions of the tenant
+func (d *Dispatcher) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	res, err := d.store.Subscriptions(ctx, t)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return res, nil
+}
+
+// Unsubscribe removes the subscription, its pending deliveries die on their next attempt
+func (d *Dispatcher) Unsubscribe(ctx context.Context, id string) error {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return errors.NewMissingTenantError()
+	}
+
+	if err := d.store.DeleteSubscription(ctx, t, id); err != nil {
+		return storeError(err, errors.NewWebhookNotFoundError())
+	}
+
+	return nil
+}
+
+// Deliveries returns the deliveries of the subscription with the given status, newest first
+func (d *Dispatcher) Deliveries(ctx context.Context, subscriptionID string, status Status) ([]Delivery, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	if !ValidStatus(status) {
+		return nil, errors.NewInvalidWebhookError("status", fmt.Sprintf("unknown status %q", status))
+	}
+
+	if _, err := d.store.Subscription(ctx, t, subscriptionID); err != nil {
+		return nil, storeError(err, errors.NewWebhookNotFoundError())
+	}
+
+	all, err := d.store.Deliveries(ctx, t, subscriptionID)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	res := make([]Delivery, 0, len(all))
+	for _, delivery := range all {
+		if status == "" || delivery.Status == status {
+			res = append(res, delivery)
+		}
+	}
+
+	return res, nil
+}
+
+// Replay sends again the event of a past delivery as a new delivery, the original one is kept as it was
+func (d *Dispatcher) Replay(ctx context.Context, subscriptionID string, deliveryID string) (Delivery, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return Delivery{}, errors.NewMissingTenantError()
+	}
+
+	if _, err := d.store.Subscription(ctx, t, subscriptionID); err != nil {
+		return Delivery{}, storeError(err, errors.NewWebhookNotFoundError())
+	}
+
+	original, err := d.store.Delivery(ctx, t, deliveryID)
+	if err == nil && original.SubscriptionID != subscriptionID {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return Delivery{}, storeError(err, errors.NewDeliveryNotFoundError())
+	}
+
+	replay := d.newDelivery(subscriptionID, original.Event)
+	replay.ReplayOf = original.ID
+
+	if err := d.store.SaveDelivery(ctx, replay); err != nil {
+		return Delivery{}, errors.NewInternalError()
+	}
+
+	d.notify()
+	return replay, nil
+}
+
+// Handle queues a delivery of the event for every subscription of its tenant whose filter accepts it,
+// it is meant to be subscribed to the event bus
+func (d *Dispatcher) Handle(e events.Event) {
+	ctx := context.Background()
+
+	subscriptions, err := d.store.Subscriptions(ctx, e.Tenant)
+	if err != nil {
+		level.Error(d.logger).Log("event", e.ID, "ERROR", err)
+		return
+	}
+
+	queued := false
+	for _, s := range subscriptions {
+		if !s.Accepts(e.Type) {
+			continue
+		}
+
+		if err := d.store.SaveDelivery(ctx, d.newDelivery(s.ID, e)); err != nil {
+			level.Error(d.logger).Log("event", e.ID, "webhook", s.ID, "ERROR", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		d.notify()
+	}
+}
+
+// Run sends the due deliveries until the context is canceled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		full := d.Flush(ctx) == d.batch()
+
+		if d.now().Sub(lastPrune) > time.Hour {
+			lastPrune = d.now()
+			if _, err := d.store.Prune(ctx, lastPrune.Add(-d.options.Retention)); err != nil {
+				level.Error(d.logger).Log("prune", err)
+			}
+		}
+
+		if full && ctx.Err() == nil {
+			// more deliveries may be due already
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// Flush sends one batch of the deliveries which are due now and waits for the attempts,
+// it returns the number of attempts
+func (d *Dispatcher) Flush(ctx context.Context) int {
+	due, err := d.store.Due(ctx, d.now(), d.batch())
+	if err != nil {
+		level.Error(d.logger).Log("due", err)
+		return 0
+	}
+
+	sem := make(chan struct{}, d.options.Workers)
+	var wg sync.WaitGroup
+	for _, delivery := range due {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery Delivery) {
+			defer func() { <-sem; wg.Done() }()
+			d.attempt(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(due)
+}
+
+// batch is the number of deliveries read per flush
+func (d *Dispatcher) batch() int {
+	return d.options.Workers * 10
+}
+
+// attempt sends the delivery once and stores its outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) {
+	logger := log.With(d.logger, "delivery", delivery.ID, "webhook", delivery.SubscriptionID)
+
+	s, err := d.store.Subscription(ctx, delivery.Tenant, delivery.SubscriptionID)
+	if err == ErrNotFound {
+		d.complete(&delivery, Dead)
+		delivery.LastError = "webhook deleted"
+		d.save(ctx, logger, delivery)
+		return
+	}
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return
+	}
+
+	delivery.Attempts++
+	code, err := d.send(ctx, s, delivery)
+	delivery.LastStatusCode = code
+
+	switch {
+	case err == nil:
+		delivery.LastError = ""
+		d.complete(&delivery, Succeeded)
+	case delivery.Attempts >= d.options.MaxAttempts:
+		delivery.LastError = err.Error()
+		d.complete(&delivery, Dead)
+		level.Error(logger).Log("dead", err, "attempts", delivery.Attempts)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttempt = d.now().UTC().Add(d.backoff(delivery.Attempts))
+	}
+
+	d.save(ctx, logger, delivery)
+}
+
+// send posts the signed event to the subscriber, any answer out of the 2xx range is a failure
+func (d *Dispatcher) send(ctx context.Context, s Subscription, delivery Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, delivery.ID)
+	req.Header.Set(EventHeader, string(delivery.Event.Type))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(s.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %v", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts, the base delay doubles after every
+// failure up to the maximum and half of it is random so the retries of many deliveries spread out
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.options.MaxDelay
+	if attempts < 32 {
+		if exp := d.options.BaseDelay << uint(attempts-1); exp > 0 && exp < delay {
+			delay = exp
+		}
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (d *Dispatcher) newDelivery(subscriptionID string, e events.Event) Delivery {
+	now := d.now().UTC()
+
+	return Delivery{
+		ID:             newID(),
+		SubscriptionID: subscriptionID,
+		Tenant:         e.Tenant,
+		Event:          e,
+		Status:         Pending,
+		NextAttempt:    now,
+		CreatedAt:      now,
+	}
+}
+
+func (d *Dispatcher) complete(delivery *Delivery, status Status) {
+	now := d.now().UTC()
+	delivery.Status = status
+	delivery.CompletedAt = &now
+}
+
+func (d *Dispatcher) save(ctx context.Context, logger log.Logger, delivery Delivery) {
+	if err := d.store.SaveDelivery(ctx, delivery); err != nil {
+		level.Error(logger).Log("ERROR", err)
+	}
+}
+
+// notify wakes Run up without blocking, a pending wake up already covers the new deliveries
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// storeError returns notFound for ErrNotFound and an internal error otherwise
+func storeError(err error, notFound error) error {
+	if err == ErrNotFound {
+		return notFound
+	}
+	return errors.NewInternalError()
+}
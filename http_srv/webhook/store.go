@@ -0,0 +1,358 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when the subscription or the delivery is not within the store
+var ErrNotFound = errors.New("webhook record not found")
+
+// ErrInvalidID is returned when the id can not be used as a file name
+var ErrInvalidID = errors.New("invalid webhook record id")
+
+var validID = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// Store describes the storage of the subscriptions and their deliveries, every lookup is scoped to a tenant
+// except Due, which feeds the dispatcher with the pending deliveries of every tenant
+type Store interface {
+	SaveSubscription(ctx context.Context, s Subscription) error
+	Subscription(ctx context.Context, tenantID string, id string) (Subscription, error)
+	Subscriptions(ctx context.Context, tenantID string) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, tenantID string, id string) error
+	SaveDelivery(ctx context.Context, d Delivery) error
+	Delivery(ctx context.Context, tenantID string, id string) (Delivery, error)
+	Deliveries(ctx context.Context, tenantID string, subscriptionID string) ([]Delivery, error)
+	Due(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	Prune(ctx context.Context, before time.Time) (int, error)
+}
+
+// MemoryStore implements the Store interface in memory, it is not durable and it is meant for tests
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+}
+
+// NewMemoryStore returns an empty MemoryStore pointer type
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		subscriptions: make(map[string]Subscription),
+		deliveries:    make(map[string]Delivery),
+	}
+}
+
+// SaveSubscription stores a copy of the subscription
+func (m *MemoryStore) SaveSubscription(ctx context.Context, s Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.subscriptions[s.ID] = copySubscription(s)
+	return nil
+}
+
+// Subscription returns a copy of the subscription of the tenant
+func (m *MemoryStore) Subscription(ctx context.Context, tenantID string, id string) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.subscriptions[id]
+	if !ok || s.Tenant != tenantID {
+		return Subscription{}, ErrNotFound
+	}
+
+	return copySubscription(s), nil
+}
+
+// Subscriptions returns the subscriptions of the tenant ordered by creation
+func (m *MemoryStore) Subscriptions(ctx context.Context, tenantID string) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []Subscription
+	for _, s := range m.subscriptions {
+		if s.Tenant == tenantID {
+			res = append(res, copySubscription(s))
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].ID < res[j].ID
+		}
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+// DeleteSubscription removes the subscription of the tenant, its deliveries are kept
+func (m *MemoryStore) DeleteSubscription(ctx context.Context, tenantID string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.subscriptions[id]
+	if !ok || s.Tenant != tenantID {
+		return ErrNotFound
+	}
+
+	delete(m.subscriptions, id)
+	return nil
+}
+
+// SaveDelivery stores a copy of the delivery
+func (m *MemoryStore) SaveDelivery(ctx context.Context, d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries[d.ID] = d
+	return nil
+}
+
+// Delivery returns the delivery of the tenant
+func (m *MemoryStore) Delivery(ctx context.Context, tenantID string, id string) (Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.deliveries[id]
+	if !ok || d.Tenant != tenantID {
+		return Delivery{}, ErrNotFound
+	}
+
+	return d, nil
+}
+
+// Deliveries returns the deliveries of the subscription, newest first
+func (m *MemoryStore) Deliveries(ctx context.Context, tenantID string, subscriptionID string) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []Delivery
+	for _, d := range m.deliveries {
+		if d.Tenant == tenantID && d.SubscriptionID == subscriptionID {
+			res = append(res, d)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].ID > res[j].ID
+		}
+		return res[i].CreatedAt.After(res[j].CreatedAt)
+	})
+	return res, nil
+}
+
+// Due returns the pending deliveries whose next attempt is not after now, oldest first
+func (m *MemoryStore) Due(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []Delivery
+	for _, d := range m.deliveries {
+		if d.Status == Pending && !d.NextAttempt.After(now) {
+			res = append(res, d)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].ID < res[j].ID
+		}
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// Prune removes the completed deliveries created before the given time, it returns how many were removed
+func (m *MemoryStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	ids := m.prune(before)
+	return len(ids), nil
+}
+
+func (m *MemoryStore) prune(before time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []string
+	for id, d := range m.deliveries {
+		if d.Status != Pending && d.CreatedAt.Before(before) {
+			delete(m.deliveries, id)
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func copySubscription(s Subscription) Subscription {
+	s.Events = append(s.Events[:0:0], s.Events...)
+	return s
+}
+
+// FileStore implements the Store interface keeping every record in memory and writing each one to
+// a JSON file, the records are loaded again when the store is opened
+type FileStore struct {
+	*MemoryStore
+	dir string
+}
+
+// NewFileStore returns a FileStore pointer type which saves the records under dir
+func NewFileStore(dir string) (*FileStore, error) {
+	f := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		dir:         dir,
+	}
+
+	for _, sub := range []string{"subscriptions", "deliveries"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	err := readAll(filepath.Join(dir, "subscriptions"), func(raw []byte) error {
+		var s Subscription
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		f.subscriptions[s.ID] = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readAll(filepath.Join(dir, "deliveries"), func(raw []byte) error {
+		var d Delivery
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return err
+		}
+		f.deliveries[d.ID] = d
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// SaveSubscription writes the subscription to disk before keeping it in memory
+func (f *FileStore) SaveSubscription(ctx context.Context, s Subscription) error {
+	if err := writeJSON(filepath.Join(f.dir, "subscriptions"), s.ID, s); err != nil {
+		return err
+	}
+	return f.MemoryStore.SaveSubscription(ctx, s)
+}
+
+// DeleteSubscription removes the subscription of the tenant from disk and memory
+func (f *FileStore) DeleteSubscription(ctx context.Context, tenantID string, id string) error {
+	if _, err := f.MemoryStore.Subscription(ctx, tenantID, id); err != nil {
+		return err
+	}
+
+	if err := removeJSON(filepath.Join(f.dir, "subscriptions"), id); err != nil {
+		return err
+	}
+	return f.MemoryStore.DeleteSubscription(ctx, tenantID, id)
+}
+
+// SaveDelivery writes the delivery to disk before keeping it in memory
+func (f *FileStore) SaveDelivery(ctx context.Context, d Delivery) error {
+	if err := writeJSON(filepath.Join(f.dir, "deliveries"), d.ID, d); err != nil {
+		return err
+	}
+	return f.MemoryStore.SaveDelivery(ctx, d)
+}
+
+// Prune removes the completed deliveries created before the given time from memory and disk
+func (f *FileStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	ids := f.prune(before)
+	for _, id := range ids {
+		if err := removeJSON(filepath.Join(f.dir, "deliveries"), id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// writeJSON writes the record and flushes it to disk, a crash leaves either the previous or the new version
+func writeJSON(dir string, id string, v interface{}) error {
+	if !validID.MatchString(id) {
+		return ErrInvalidID
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".webhook-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, id+".json"))
+}
+
+func removeJSON(dir string, id string) error {
+	if !validID.MatchString(id) {
+		return ErrInvalidID
+	}
+
+	if err := os.Remove(filepath.Join(dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func readAll(dir string, load func(raw []byte) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		raw, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+
+		if err := load(raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
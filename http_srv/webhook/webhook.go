@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
+)
+
+// Headers sent with every delivery
+const (
+	IDHeader        = "X-Webhook-ID"
+	EventHeader     = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// signaturePrefix names the algorithm of the signature header, it looks like sha256=<hex>
+const signaturePrefix = "sha256="
+
+// Status describes the state of a delivery
+type Status string
+
+// Status of the deliveries, dead deliveries form the dead-letter store and are only sent again by a replay
+const (
+	Pending   Status = "pending"
+	Succeeded Status = "succeeded"
+	Dead      Status = "dead"
+)
+
+// ValidStatus reports whether the status is known, the empty status matches every delivery
+func ValidStatus(s Status) bool {
+	return s == "" || s == Pending || s == Succeeded || s == Dead
+}
+
+// Subscription struct stores an endpoint of a tenant and the events sent to it, no events means every event
+type Subscription struct {
+	ID        string        `json:"id"`
+	Tenant    string        `json:"tenant"`
+	URL       string        `json:"url"`
+	Secret    string        `json:"secret,omitempty"`
+	Events    []events.Type `json:"events,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// Accepts reports whether the event type passes the filter of the subscription
+func (s Subscription) Accepts(t events.Type) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delivery struct stores one event sent to one subscription, replays are new deliveries pointing to the original one
+type Delivery struct {
+	ID             string       `json:"id"`
+	SubscriptionID string       `json:"webhook_id"`
+	Tenant         string       `json:"tenant"`
+	Event          events.Event `json:"event"`
+	Status         Status       `json:"status"`
+	Attempts       int          `json:"attempts"`
+	LastStatusCode int          `json:"last_status_code,omitempty"`
+	LastError      string       `json:"last_error,omitempty"`
+	NextAttempt    time.Time    `json:"next_attempt"`
+	CreatedAt      time.Time    `json:"created_at"`
+	CompletedAt    *time.Time   `json:"completed_at,omitempty"`
+	ReplayOf       string       `json:"replay_of,omitempty"`
+}
+
+// knownEvents lists the event types accepted by the filters
+var knownEvents = map[events.Type]bool{
+	events.UserCreated:    true,
+	events.UserUpdated:    true,
+	events.UserDeleted:    true,
+	events.DetailsChanged: true,
+}
+
+// validate checks the URL and the event filter of the subscription, the hosts which are not public are rejected
+// unless the private networks are allowed
+func validate(s Subscription, allowPrivate bool) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.NewInvalidWebhookError("url", "expected an absolute http or https URL")
+	}
+
+	if !allowPrivate && !publicHost(u.Hostname()) {
+		return errors.NewInvalidWebhookError("url", "expected a public host")
+	}
+
+	for _, e := range s.Events {
+		if !knownEvents[e] {
+			return errors.NewInvalidWebhookError("events", fmt.Sprintf("unknown event %q", e))
+		}
+	}
+
+	return nil
+}
+
+// Sign returns the signature header of the body sent at the given unix timestamp,
+// the HMAC-SHA256 covers "<timestamp>.<body>" so a captured delivery can not be sent again later
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a delivery, the tolerance bounds the age of the timestamp,
+// it is meant for the receivers of the webhooks
+func Verify(secret string, timestamp string, signature string, body []byte, tolerance time.Duration) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return false
+	}
+
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
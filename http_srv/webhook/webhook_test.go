@@ -0,0 +1,407 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/http_srv/webhook"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiver records the deliveries it accepts, it answers with the statuses in order and 200 afterwards
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	r.received = append(r.received, req)
+	r.bodies = append(r.bodies, body)
+
+	code := http.StatusOK
+	if len(r.statuses) > 0 {
+		code, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	rw.WriteHeader(code)
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+func fastOptions() webhook.Options {
+	return webhook.Options{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, AllowPrivateNetworks: true}
+}
+
+// flushUntil flushes the dispatcher until no delivery is pending
+func flushUntil(t *testing.T, d *webhook.Dispatcher, store webhook.Store) {
+	for i := 0; i < 100; i++ {
+		d.Flush(context.Background())
+		due, _ := store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+		if len(due) == 0 {
+			return
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	t.Fatal("deliveries still pending")
+}
+
+func TestSign(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	body := []byte(`{"id":"1"}`)
+	now := time.Now().Unix()
+	signature := webhook.Sign("secret", now, body)
+	ts := strconv.FormatInt(now, 10)
+
+	// act & assert
+	assert.True(webhook.Verify("secret", ts, signature, body, time.Minute))
+	assert.False(webhook.Verify("other", ts, signature, body, time.Minute))
+	assert.False(webhook.Verify("secret", ts, signature, []byte(`{"id":"2"}`), time.Minute))
+	assert.False(webhook.Verify("secret", strconv.FormatInt(now-3600, 10), webhook.Sign("secret", now-3600, body), body, time.Minute))
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "acme")
+
+	testCases := []struct {
+		testName     string
+		subscription webhook.Subscription
+		err          error
+	}{
+		{
+			testName:     "every event",
+			subscription: webhook.Subscription{URL: "https://crm.example.com/hooks"},
+		},
+		{
+			testName:     "filtered events",
+			subscription: webhook.Subscription{URL: "http://billing:8080/users", Events: []events.Type{events.UserCreated, events.UserDeleted}},
+		},
+		{
+			testName:     "relative url",
+			subscription: webhook.Subscription{URL: "/hooks"},
+			err:          errors.NewInvalidWebhookError("url", "expected an absolute http or https URL"),
+		},
+		{
+			testName:     "loopback address",
+			subscription: webhook.Subscription{URL: "http://127.0.0.1:8080/hooks"},
+			err:          errors.NewInvalidWebhookError("url", "expected a public host"),
+		},
+		{
+			testName:     "metadata address",
+			subscription: webhook.Subscription{URL: "http://169.254.169.254/latest/meta-data"},
+			err:          errors.NewInvalidWebhookError("url", "expected a public host"),
+		},
+		{
+			testName:     "localhost",
+			subscription: webhook.Subscription{URL: "http://localhost/hooks"},
+			err:          errors.NewInvalidWebhookError("url", "expected a public host"),
+		},
+		{
+			testName:     "unknown event",
+			subscription: webhook.Subscription{URL: "https://crm.example.com/hooks", Events: []events.Type{"UserRenamed"}},
+			err:          errors.NewInvalidWebhookError("events", `unknown event "UserRenamed"`),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// prepare
+			d := webhook.NewDispatcher(webhook.NewMemoryStore(), webhook.Options{}, log.NewNopLogger())
+
+			// act
+			res, err := d.Subscribe(ctx, tc.subscription)
+
+			// assert
+			assert.Equal(tc.err, err)
+			if tc.err == nil {
+				assert.Len(res.ID, 32)
+				assert.Len(res.Secret, 64)
+				assert.Equal("acme", res.Tenant)
+				assert.Equal(tc.subscription.Events, res.Events)
+			}
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	store := webhook.NewMemoryStore()
+	d := webhook.NewDispatcher(store, fastOptions(), log.NewNopLogger())
+	ctx := tenant.NewContext(context.Background(), "acme")
+
+	created, err := d.Subscribe(ctx, webhook.Subscription{URL: server.URL, Events: []events.Type{events.UserCreated}, Secret: "secret"})
+	assert.NoError(err)
+	_, err = d.Subscribe(tenant.NewContext(context.Background(), "other"), webhook.Subscription{URL: server.URL})
+	assert.NoError(err)
+
+	// act
+	d.Handle(events.New(events.UserCreated, "acme", 1, map[string]interface{}{"email": "user@email.com"}))
+	d.Handle(events.New(events.UserDeleted, "acme", 1, nil))
+	flushUntil(t, d, store)
+
+	// assert
+	if assert.Equal(1, rec.count()) {
+		req := rec.received[0]
+		assert.Equal(string(events.UserCreated), req.Header.Get(webhook.EventHeader))
+		assert.True(webhook.Verify("secret", req.Header.Get(webhook.TimestampHeader), req.Header.Get(webhook.SignatureHeader), rec.bodies[0], time.Minute))
+	}
+
+	deliveries, err := d.Deliveries(ctx, created.ID, "")
+	assert.NoError(err)
+	if assert.Len(deliveries, 1) {
+		assert.Equal(webhook.Succeeded, deliveries[0].Status)
+		assert.Equal(1, deliveries[0].Attempts)
+		assert.Equal(deliveries[0].ID, rec.received[0].Header.Get(webhook.IDHeader))
+	}
+}
+
+func TestRetries(t *testing.T) {
+	testCases := []struct {
+		testName string
+		statuses []int
+		status   webhook.Status
+		attempts int
+	}{
+		{
+			testName: "succeeds after failures",
+			statuses: []int{500, 503},
+			status:   webhook.Succeeded,
+			attempts: 3,
+		},
+		{
+			testName: "dead after the maximum attempts",
+			statuses: []int{500, 500, 500},
+			status:   webhook.Dead,
+			attempts: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// prepare
+			rec := &receiver{statuses: tc.statuses}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			store := webhook.NewMemoryStore()
+			d := webhook.NewDispatcher(store, fastOptions(), log.NewNopLogger())
+			ctx := tenant.NewContext(context.Background(), "acme")
+			s, _ := d.Subscribe(ctx, webhook.Subscription{URL: server.URL})
+
+			// act
+			d.Handle(events.New(events.UserUpdated, "acme", 1, nil))
+			flushUntil(t, d, store)
+
+			// assert
+			deliveries, err := d.Deliveries(ctx, s.ID, tc.status)
+			assert.NoError(err)
+			if assert.Len(deliveries, 1) {
+				assert.Equal(tc.attempts, deliveries[0].Attempts)
+				assert.NotNil(deliveries[0].CompletedAt)
+			}
+			assert.Equal(tc.attempts, rec.count())
+		})
+	}
+}
+
+func TestUnreachableAddresses(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		allowPrivate bool
+		redirect     bool
+		code         int
+	}{
+		{
+			testName:     "private address resolved after the subscription",
+			allowPrivate: false,
+			code:         0,
+		},
+		{
+			testName:     "redirect not followed",
+			allowPrivate: true,
+			redirect:     true,
+			code:         http.StatusFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// prepare
+			rec := &receiver{}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			url := server.URL
+			if tc.redirect {
+				redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusFound))
+				defer redirect.Close()
+				url = redirect.URL
+			}
+
+			store := webhook.NewMemoryStore()
+			options := fastOptions()
+			options.AllowPrivateNetworks = tc.allowPrivate
+			d := webhook.NewDispatcher(store, options, log.NewNopLogger())
+			ctx := tenant.NewContext(context.Background(), "acme")
+			s := webhook.Subscription{ID: "0123456789abcdef0123456789abcdef", Tenant: "acme", URL: url, Secret: "secret"}
+			assert.NoError(store.SaveSubscription(ctx, s))
+
+			// act
+			d.Handle(events.New(events.UserUpdated, "acme", 1, nil))
+			flushUntil(t, d, store)
+
+			// assert
+			deliveries, err := d.Deliveries(ctx, s.ID, webhook.Dead)
+			assert.NoError(err)
+			if assert.Len(deliveries, 1) {
+				assert.Equal(tc.code, deliveries[0].LastStatusCode)
+			}
+			assert.Equal(0, rec.count())
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	rec := &receiver{statuses: []int{500}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	store := webhook.NewMemoryStore()
+	options := fastOptions()
+	options.MaxAttempts = 1
+	d := webhook.NewDispatcher(store, options, log.NewNopLogger())
+	ctx := tenant.NewContext(context.Background(), "acme")
+	s, _ := d.Subscribe(ctx, webhook.Subscription{URL: server.URL})
+
+	d.Handle(events.New(events.UserCreated, "acme", 1, nil))
+	flushUntil(t, d, store)
+	dead, _ := d.Deliveries(ctx, s.ID, webhook.Dead)
+
+	// act
+	replay, err := d.Replay(ctx, s.ID, dead[0].ID)
+	flushUntil(t, d, store)
+	_, missingErr := d.Replay(ctx, s.ID, "00000000000000000000000000000000")
+	_, otherTenantErr := d.Replay(tenant.NewContext(context.Background(), "other"), s.ID, dead[0].ID)
+
+	// assert
+	assert.NoError(err)
+	assert.Equal(dead[0].ID, replay.ReplayOf)
+	assert.Equal(dead[0].Event, replay.Event)
+	assert.Equal(errors.NewDeliveryNotFoundError(), missingErr)
+	assert.Equal(errors.NewWebhookNotFoundError(), otherTenantErr)
+
+	all, _ := d.Deliveries(ctx, s.ID, "")
+	assert.Len(all, 2)
+	succeeded, _ := d.Deliveries(ctx, s.ID, webhook.Succeeded)
+	if assert.Len(succeeded, 1) {
+		assert.Equal(replay.ID, succeeded[0].ID)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	store := webhook.NewMemoryStore()
+	d := webhook.NewDispatcher(store, fastOptions(), log.NewNopLogger())
+	ctx := tenant.NewContext(context.Background(), "acme")
+	s, _ := d.Subscribe(ctx, webhook.Subscription{URL: server.URL})
+	d.Handle(events.New(events.UserCreated, "acme", 1, nil))
+
+	// act
+	err := d.Unsubscribe(ctx, s.ID)
+	flushUntil(t, d, store)
+	againErr := d.Unsubscribe(ctx, s.ID)
+
+	// assert
+	assert.NoError(err)
+	assert.Equal(errors.NewWebhookNotFoundError(), againErr)
+	assert.Equal(0, rec.count())
+
+	due, _ := store.Due(context.Background(), time.Now().Add(time.Hour), 0)
+	assert.Empty(due)
+}
+
+func TestFileStore(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	dir := t.TempDir()
+	ctx := context.Background()
+	store, err := webhook.NewFileStore(dir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	s := webhook.Subscription{ID: "0123456789abcdef0123456789abcdef", Tenant: "acme", URL: "https://crm.example.com", Secret: "secret", CreatedAt: now}
+	old := webhook.Delivery{ID: "1123456789abcdef0123456789abcdef", SubscriptionID: s.ID, Tenant: "acme", Status: webhook.Succeeded, CreatedAt: now.Add(-48 * time.Hour)}
+	pending := webhook.Delivery{ID: "2123456789abcdef0123456789abcdef", SubscriptionID: s.ID, Tenant: "acme", Status: webhook.Pending, CreatedAt: now, NextAttempt: now}
+
+	// act
+	assert.NoError(store.SaveSubscription(ctx, s))
+	assert.NoError(store.SaveDelivery(ctx, old))
+	assert.NoError(store.SaveDelivery(ctx, pending))
+	pruned, pruneErr := store.Prune(ctx, now.Add(-24*time.Hour))
+	invalidErr := store.SaveDelivery(ctx, webhook.Delivery{ID: "../escape"})
+
+	reopened, reopenErr := webhook.NewFileStore(dir)
+
+	// assert
+	assert.NoError(pruneErr)
+	assert.Equal(1, pruned)
+	assert.Equal(webhook.ErrInvalidID, invalidErr)
+	if !assert.NoError(reopenErr) {
+		return
+	}
+
+	subscriptions, _ := reopened.Subscriptions(ctx, "acme")
+	assert.Equal([]webhook.Subscription{s}, subscriptions)
+
+	deliveries, _ := reopened.Deliveries(ctx, "acme", s.ID)
+	if assert.Len(deliveries, 1) {
+		assert.Equal(pending.ID, deliveries[0].ID)
+	}
+
+	_, err = reopened.Subscription(ctx, "other", s.ID)
+	assert.Equal(webhook.ErrNotFound, err)
+}
@@ -0,0 +1,140 @@
+package locale
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// MetadataKey is the gRPC metadata key used to carry the language between services
+	MetadataKey = "accept-language"
+	// HeaderKey is the HTTP header used by clients to select a language
+	HeaderKey = "Accept-Language"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context which carries the given language
+func NewContext(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// FromContext returns the language stored within the context
+func FromContext(ctx context.Context) (string, bool) {
+	lang, ok := ctx.Value(contextKey{}).(string)
+	return lang, ok && lang != ""
+}
+
+// Match returns the first of the supported languages accepted by the Accept-Language header value, the ranges are
+// tried by their quality and only their primary subtag is compared, so es-MX selects es. An empty string is returned
+// when no supported language is accepted
+func Match(acceptLanguage string, supported []string) string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, weighted{tag: tag, quality: quality})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" && len(supported) > 0 {
+			return supported[0]
+		}
+
+		primary := strings.SplitN(r.tag, "-", 2)[0]
+		for _, lang := range supported {
+			if primary == lang {
+				return lang
+			}
+		}
+	}
+
+	return ""
+}
+
+// UnaryServerInterceptor reads the language from the incoming metadata, the calls without one keep the default
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(incomingContext(ctx), req)
+	}
+}
+
+// StreamServerInterceptor is the UnaryServerInterceptor of the streaming calls
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: incomingContext(ss.Context())})
+	}
+}
+
+// serverStream replaces the context of the stream with the one which carries the language
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func incomingContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+
+	if len(values) == 0 || values[0] == "" {
+		return ctx
+	}
+
+	return NewContext(ctx, values[0])
+}
+
+// UnaryClientInterceptor sends the language stored within the context as outgoing metadata
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if lang, ok := FromContext(ctx); ok {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, lang)
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is the UnaryClientInterceptor of the streaming calls
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if lang, ok := FromContext(ctx); ok {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, lang)
+		}
+
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,199 @@
+package locale_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMatch(t *testing.T) {
+	supported := []string{"en", "es", "pt"}
+
+	testCases := []struct {
+		testName string
+		header   string
+		res      string
+	}{
+		{
+			testName: "exact language",
+			header:   "es",
+			res:      "es",
+		},
+		{
+			testName: "regional language",
+			header:   "pt-BR",
+			res:      "pt",
+		},
+		{
+			testName: "highest quality wins",
+			header:   "en;q=0.5, es-MX;q=0.9, fr",
+			res:      "es",
+		},
+		{
+			testName: "rejected language skipped",
+			header:   "es;q=0, pt;q=0.1",
+			res:      "pt",
+		},
+		{
+			testName: "wildcard selects the first supported",
+			header:   "fr, *;q=0.5",
+			res:      "en",
+		},
+		{
+			testName: "unsupported language",
+			header:   "fr-CA, de",
+			res:      "",
+		},
+		{
+			testName: "empty header",
+			header:   "",
+			res:      "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			res := locale.Match(tc.header, supported)
+
+			// assert
+			assert.Equal(tc.res, res)
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := locale.UnaryServerInterceptor()
+
+	testCases := []struct {
+		testName string
+		md       metadata.MD
+		res      interface{}
+	}{
+		{
+			testName: "language stored within context",
+			md:       metadata.Pairs(locale.MetadataKey, "es"),
+			res:      "es",
+		},
+		{
+			testName: "no language",
+			md:       metadata.MD{},
+			res:      "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				lang, _ := locale.FromContext(ctx)
+				return lang, nil
+			}
+
+			// act
+			res, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+
+			// assert
+			assert.Nil(err)
+			assert.Equal(tc.res, res)
+		})
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	interceptor := locale.UnaryClientInterceptor()
+
+	testCases := []struct {
+		testName string
+		ctx      context.Context
+		res      []string
+	}{
+		{
+			testName: "language sent as metadata",
+			ctx:      locale.NewContext(context.Background(), "pt"),
+			res:      []string{"pt"},
+		},
+		{
+			testName: "no language within context",
+			ctx:      context.Background(),
+			res:      nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var sent []string
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				sent = md.Get(locale.MetadataKey)
+				return nil
+			}
+
+			// act
+			err := interceptor(tc.ctx, "/UserService/GetUser", nil, nil, nil, invoker)
+
+			// assert
+			assert.Nil(err)
+			assert.Equal(tc.res, sent)
+		})
+	}
+}
+
+// fakeServerStream only carries the context of the stream
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	interceptor := locale.StreamServerInterceptor()
+	ss := fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(locale.MetadataKey, "es"))}
+	var res string
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		res, _ = locale.FromContext(stream.Context())
+		return nil
+	}
+
+	// act
+	err := interceptor(nil, ss, &grpc.StreamServerInfo{}, handler)
+
+	// assert
+	assert.Nil(err)
+	assert.Equal("es", res)
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	interceptor := locale.StreamClientInterceptor()
+	var sent []string
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get(locale.MetadataKey)
+		return nil, nil
+	}
+
+	// act
+	_, err := interceptor(locale.NewContext(context.Background(), "es"), &grpc.StreamDesc{}, nil, "/UserService/WatchUser", streamer)
+
+	// assert
+	assert.Nil(err)
+	assert.Equal([]string{"es"}, sent)
+}
@@ -0,0 +1,90 @@
+package reconcile
+
+import (
+	"context"
+
+	"github.com/mauricioww/user_microsrv/tenant"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+	"github.com/mauricioww/user_microsrv/user_details_srv/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDetails implements the Details interface, the documents are read directly from the information
+// collection and changed through the details repository so the change reaches the outbox
+type MongoDetails struct {
+	db     *mongo.Database
+	repo   repository.UserDetailsRepositorier
+	tenant string
+}
+
+// NewMongoDetails returns a MongoDetails pointer type, an empty tenant scans every tenant
+func NewMongoDetails(mongoDb *mongo.Database, repo repository.UserDetailsRepositorier, tenantID string) *MongoDetails {
+	return &MongoDetails{
+		db:     mongoDb,
+		repo:   repo,
+		tenant: tenantID,
+	}
+}
+
+type detailsRecord struct {
+	ID     int    `bson:"_id"`
+	Tenant string `bson:"tenant_id"`
+	Active bool   `bson:"active"`
+}
+
+var detailsProjection = bson.D{{"_id", 1}, {"tenant_id", 1}, {"active", 1}}
+
+// Scan fetchs the documents, of every status, whose id is greater than afterID
+func (s *MongoDetails) Scan(ctx context.Context, afterID int, limit int) ([]Record, error) {
+	collection := s.db.Collection("information")
+
+	filter := bson.D{{"_id", bson.D{{"$gt", afterID}}}}
+	if s.tenant != "" {
+		filter = append(filter, bson.E{"tenant_id", s.tenant})
+	}
+
+	opts := options.Find().SetProjection(detailsProjection).SetSort(bson.D{{"_id", 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []detailsRecord
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	res := make([]Record, len(docs))
+	for i, d := range docs {
+		res[i] = Record{ID: d.ID, Tenant: d.Tenant, Active: d.Active}
+	}
+
+	return res, nil
+}
+
+// Lookup fetchs the document with the given id whatever its tenant is
+func (s *MongoDetails) Lookup(ctx context.Context, id int) (Record, bool, error) {
+	collection := s.db.Collection("information")
+
+	var d detailsRecord
+	err := collection.FindOne(ctx, bson.D{{"_id", id}}, options.FindOne().SetProjection(detailsProjection)).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return Record{}, false, nil
+	}
+
+	return Record{ID: d.ID, Tenant: d.Tenant, Active: d.Active}, err == nil, err
+}
+
+// Create stores an empty details document for the user
+func (s *MongoDetails) Create(ctx context.Context, r Record) error {
+	_, err := s.repo.SetUserDetails(tenant.NewContext(ctx, r.Tenant), entities.UserDetails{UserID: r.ID}, entities.AnyRevision)
+	return err
+}
+
+// Deactivate soft deletes the details document
+func (s *MongoDetails) Deactivate(ctx context.Context, r Record) error {
+	_, err := s.repo.DeleteUserDetails(tenant.NewContext(ctx, r.Tenant), r.ID)
+	return err
+}
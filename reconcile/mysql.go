@@ -0,0 +1,77 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mauricioww/user_microsrv/tenant"
+	"github.com/mauricioww/user_microsrv/user_srv/repository"
+)
+
+const (
+	scanUsersSQL = `
+		SELECT u.id, u.tenant_id, u.active FROM USERS u
+			WHERE u.id > ? AND (? = '' OR u.tenant_id = ?)
+			ORDER BY u.id LIMIT ?
+	`
+
+	lookupUserSQL = `
+		SELECT u.id, u.tenant_id, u.active FROM USERS u
+			WHERE u.id = ?
+	`
+)
+
+// MySQLUsers implements the Users interface, the users are read directly from the USERS table
+// and deactivated through the user repository so the change reaches the outbox
+type MySQLUsers struct {
+	db     *sql.DB
+	repo   repository.UserRepositorier
+	tenant string
+}
+
+// NewMySQLUsers returns a MySQLUsers pointer type, an empty tenant scans every tenant
+func NewMySQLUsers(mysqlDb *sql.DB, repo repository.UserRepositorier, tenantID string) *MySQLUsers {
+	return &MySQLUsers{
+		db:     mysqlDb,
+		repo:   repo,
+		tenant: tenantID,
+	}
+}
+
+// Scan fetchs the users, of every status, whose id is greater than afterID
+func (s *MySQLUsers) Scan(ctx context.Context, afterID int, limit int) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, scanUsersSQL, afterID, s.tenant, s.tenant, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []Record
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.ID, &r.Tenant, &r.Active); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+// Lookup fetchs the user with the given id whatever its tenant is
+func (s *MySQLUsers) Lookup(ctx context.Context, id int) (Record, bool, error) {
+	var r Record
+
+	err := s.db.QueryRowContext(ctx, lookupUserSQL, id).Scan(&r.ID, &r.Tenant, &r.Active)
+	if err == sql.ErrNoRows {
+		return r, false, nil
+	}
+
+	return r, err == nil, err
+}
+
+// Deactivate soft deletes the user
+func (s *MySQLUsers) Deactivate(ctx context.Context, r Record) error {
+	_, err := s.repo.DeleteUser(tenant.NewContext(ctx, r.Tenant), r.ID)
+	return err
+}
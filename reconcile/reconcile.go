@@ -0,0 +1,375 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Class names a kind of inconsistency between the users stored in MySQL and the details stored in MongoDB
+type Class string
+
+// Classes of inconsistency found by the reconciler
+const (
+	// MissingDetails is an active user without an active details document
+	MissingDetails Class = "missing_details"
+	// OrphanDetails is an active details document whose user was never stored
+	OrphanDetails Class = "orphan_details"
+	// ActiveMismatch is a user and its details where only one of them is active
+	ActiveMismatch Class = "active_mismatch"
+	// TenantMismatch is a user and a details document with the same id but different tenants
+	TenantMismatch Class = "tenant_mismatch"
+)
+
+// Classes lists every class in the order they are reported
+var Classes = []Class{MissingDetails, OrphanDetails, ActiveMismatch, TenantMismatch}
+
+// Action describes how an inconsistency is repaired
+type Action string
+
+// Actions which may be configured for the classes
+const (
+	// Report only reports the inconsistency
+	Report Action = "report"
+	// CreateDetails stores an empty details document for the user
+	CreateDetails Action = "create_details"
+	// DeactivateUser soft deletes the user
+	DeactivateUser Action = "deactivate_user"
+	// DeactivateDetails soft deletes the details document
+	DeactivateDetails Action = "deactivate_details"
+	// Deactivate soft deletes whichever side is still active, deletions always win
+	Deactivate Action = "deactivate"
+)
+
+// allowed lists the actions accepted by every class
+var allowed = map[Class][]Action{
+	MissingDetails: {Report, CreateDetails, DeactivateUser},
+	OrphanDetails:  {Report, DeactivateDetails},
+	ActiveMismatch: {Report, Deactivate},
+	TenantMismatch: {Report},
+}
+
+// AllowedActions returns the actions accepted by the class
+func AllowedActions(c Class) []Action {
+	return allowed[c]
+}
+
+// Rules maps every class to the action used to repair it, missing classes are only reported
+type Rules map[Class]Action
+
+// Validate returns an error when an action is not accepted by its class
+func (r Rules) Validate() error {
+	for c, a := range r {
+		actions, ok := allowed[c]
+		if !ok {
+			return fmt.Errorf("unknown class %q", c)
+		}
+
+		valid := false
+		for _, candidate := range actions {
+			valid = valid || candidate == a
+		}
+
+		if !valid {
+			return fmt.Errorf("action %q is not allowed for %v, expected one of %v", a, c, actions)
+		}
+	}
+
+	return nil
+}
+
+func (r Rules) action(c Class) Action {
+	if a, ok := r[c]; ok {
+		return a
+	}
+	return Report
+}
+
+// Record stores the fields of a user or a details document compared by the reconciler
+type Record struct {
+	ID     int
+	Tenant string
+	Active bool
+}
+
+// Scanner describes a store which is read in pages ordered by id
+type Scanner interface {
+	Scan(ctx context.Context, afterID int, limit int) ([]Record, error)
+	Lookup(ctx context.Context, id int) (Record, bool, error)
+}
+
+// Users describes the store of the users
+type Users interface {
+	Scanner
+	Deactivate(ctx context.Context, r Record) error
+}
+
+// Details describes the store of the details
+type Details interface {
+	Scanner
+	Create(ctx context.Context, r Record) error
+	Deactivate(ctx context.Context, r Record) error
+}
+
+// Finding stores one inconsistency, the user or the details fields are empty when the record is missing
+type Finding struct {
+	Class         Class  `json:"class"`
+	ID            int    `json:"id"`
+	UserTenant    string `json:"user_tenant,omitempty"`
+	UserActive    *bool  `json:"user_active,omitempty"`
+	DetailsTenant string `json:"details_tenant,omitempty"`
+	DetailsActive *bool  `json:"details_active,omitempty"`
+	Action        Action `json:"action"`
+	Repaired      bool   `json:"repaired"`
+	Stale         bool   `json:"stale,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Summary stores the totals of one run, it is meant to feed the alerts
+type Summary struct {
+	StartedAt      time.Time     `json:"started_at"`
+	Duration       time.Duration `json:"duration_ns"`
+	ScannedUsers   int           `json:"scanned_users"`
+	ScannedDetails int           `json:"scanned_details"`
+	Found          map[Class]int `json:"found"`
+	Repaired       map[Class]int `json:"repaired"`
+	Stale          int           `json:"stale"`
+	Failed         int           `json:"failed"`
+	Remaining      int           `json:"remaining"`
+}
+
+// DefaultPageSize is the number of records read per page of every store
+const DefaultPageSize = 500
+
+// Reconciler compares both stores and repairs the inconsistencies following its rules
+type Reconciler struct {
+	users    Users
+	details  Details
+	rules    Rules
+	pageSize int
+	logger   log.Logger
+}
+
+// NewReconciler returns a Reconciler pointer type, a non positive page size falls back to DefaultPageSize
+func NewReconciler(users Users, details Details, rules Rules, pageSize int, logger log.Logger) *Reconciler {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	return &Reconciler{
+		users:    users,
+		details:  details,
+		rules:    rules,
+		pageSize: pageSize,
+		logger:   log.With(logger, "component", "reconciler"),
+	}
+}
+
+// Run scans both stores merging them by id, every finding is passed to report once its repair was attempted.
+// Before a repair both records are read again so a change done since the scan, such as a user whose
+// creation is still running, is reported as stale instead of being repaired
+func (r *Reconciler) Run(ctx context.Context, report func(Finding)) (summary Summary, err error) {
+	summary = Summary{
+		StartedAt: time.Now().UTC(),
+		Found:     make(map[Class]int),
+		Repaired:  make(map[Class]int),
+	}
+	defer func() { summary.Duration = time.Since(summary.StartedAt) }()
+
+	users := &pager{store: r.users, limit: r.pageSize}
+	details := &pager{store: r.details, limit: r.pageSize}
+
+	for {
+		var u, d *Record
+		if u, err = users.peek(ctx); err != nil {
+			return summary, err
+		}
+
+		if d, err = details.peek(ctx); err != nil {
+			return summary, err
+		}
+
+		if u == nil && d == nil {
+			return summary, nil
+		}
+
+		switch {
+		case d == nil || (u != nil && u.ID < d.ID):
+			d = nil
+			users.next()
+		case u == nil || d.ID < u.ID:
+			u = nil
+			details.next()
+		default:
+			users.next()
+			details.next()
+		}
+
+		if u != nil {
+			summary.ScannedUsers++
+		}
+		if d != nil {
+			summary.ScannedDetails++
+		}
+
+		class, ok := classify(u, d)
+		if !ok {
+			continue
+		}
+
+		f := r.repair(ctx, newFinding(class, u, d))
+		summary.Found[class]++
+		switch {
+		case f.Repaired:
+			summary.Repaired[class]++
+		case f.Stale:
+			summary.Stale++
+		case f.Error != "":
+			summary.Failed++
+			summary.Remaining++
+		default:
+			summary.Remaining++
+		}
+
+		if report != nil {
+			report(f)
+		}
+	}
+}
+
+// repair applies the rule of the class after checking the records did not change since the scan
+func (r *Reconciler) repair(ctx context.Context, f Finding) Finding {
+	f.Action = r.rules.action(f.Class)
+	if f.Action == Report {
+		return f
+	}
+
+	u, d, err := r.lookup(ctx, f.ID)
+	if err != nil {
+		f.Error = err.Error()
+		return f
+	}
+
+	if current, ok := classify(u, d); !ok || current != f.Class {
+		f.Stale = true
+		return f
+	}
+
+	switch f.Action {
+	case CreateDetails:
+		err = r.details.Create(ctx, *u)
+	case DeactivateUser:
+		err = r.users.Deactivate(ctx, *u)
+	case DeactivateDetails:
+		err = r.details.Deactivate(ctx, *d)
+	case Deactivate:
+		if u.Active {
+			err = r.users.Deactivate(ctx, *u)
+		} else {
+			err = r.details.Deactivate(ctx, *d)
+		}
+	}
+
+	if err != nil {
+		level.Error(r.logger).Log("class", f.Class, "id", f.ID, "action", f.Action, "ERROR", err)
+		f.Error = err.Error()
+		return f
+	}
+
+	f.Repaired = true
+	return f
+}
+
+func (r *Reconciler) lookup(ctx context.Context, id int) (*Record, *Record, error) {
+	var u, d *Record
+
+	user, ok, err := r.users.Lookup(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		u = &user
+	}
+
+	details, ok, err := r.details.Lookup(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		d = &details
+	}
+
+	return u, d, nil
+}
+
+// classify returns the class of inconsistency between the user and the details with the same id, nil means missing
+func classify(u *Record, d *Record) (Class, bool) {
+	switch {
+	case u == nil && d == nil:
+		return "", false
+	case d == nil:
+		return MissingDetails, u.Active
+	case u == nil:
+		return OrphanDetails, d.Active
+	case u.Tenant != d.Tenant:
+		return TenantMismatch, u.Active || d.Active
+	case u.Active != d.Active:
+		return ActiveMismatch, true
+	default:
+		return "", false
+	}
+}
+
+func newFinding(c Class, u *Record, d *Record) Finding {
+	f := Finding{Class: c}
+
+	if u != nil {
+		active := u.Active
+		f.ID, f.UserTenant, f.UserActive = u.ID, u.Tenant, &active
+	}
+
+	if d != nil {
+		active := d.Active
+		f.ID, f.DetailsTenant, f.DetailsActive = d.ID, d.Tenant, &active
+	}
+
+	return f
+}
+
+// pager reads a store one page at a time
+type pager struct {
+	store  Scanner
+	limit  int
+	page   []Record
+	last   int
+	ending bool
+}
+
+// peek returns the current record, nil once the store was read completely
+func (p *pager) peek(ctx context.Context) (*Record, error) {
+	if len(p.page) == 0 && !p.ending {
+		page, err := p.store.Scan(ctx, p.last, p.limit)
+		if err != nil {
+			return nil, err
+		}
+
+		p.page = page
+		p.ending = len(page) < p.limit
+		if len(page) > 0 {
+			p.last = page[len(page)-1].ID
+		}
+	}
+
+	if len(p.page) == 0 {
+		return nil, nil
+	}
+
+	return &p.page[0], nil
+}
+
+func (p *pager) next() {
+	p.page = p.page[1:]
+}
@@ -0,0 +1,301 @@
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/mauricioww/user_microsrv/reconcile"
+	"github.com/stretchr/testify/assert"
+)
+
+// store keeps the records in memory and counts the pages read
+type store struct {
+	records map[int]reconcile.Record
+	pages   int
+	fail    error
+	// changes are applied after every scan, they simulate writes done while the reconciler runs
+	changes map[int]*reconcile.Record
+}
+
+func newStore(records ...reconcile.Record) *store {
+	s := &store{records: make(map[int]reconcile.Record)}
+	for _, r := range records {
+		s.records[r.ID] = r
+	}
+	return s
+}
+
+func (s *store) Scan(ctx context.Context, afterID int, limit int) ([]reconcile.Record, error) {
+	s.pages++
+	ids := make([]int, 0, len(s.records))
+	for id := range s.records {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	res := make([]reconcile.Record, len(ids))
+	for i, id := range ids {
+		res[i] = s.records[id]
+	}
+
+	for id, r := range s.changes {
+		if r == nil {
+			delete(s.records, id)
+		} else {
+			s.records[id] = *r
+		}
+	}
+	s.changes = nil
+
+	return res, nil
+}
+
+func (s *store) Lookup(ctx context.Context, id int) (reconcile.Record, bool, error) {
+	r, ok := s.records[id]
+	return r, ok, nil
+}
+
+func (s *store) Create(ctx context.Context, r reconcile.Record) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	s.records[r.ID] = reconcile.Record{ID: r.ID, Tenant: r.Tenant, Active: true}
+	return nil
+}
+
+func (s *store) Deactivate(ctx context.Context, r reconcile.Record) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	r.Active = false
+	s.records[r.ID] = r
+	return nil
+}
+
+func record(id int, tenantID string, active bool) reconcile.Record {
+	return reconcile.Record{ID: id, Tenant: tenantID, Active: active}
+}
+
+func TestRun(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+
+	testCases := []struct {
+		testName  string
+		users     []reconcile.Record
+		details   []reconcile.Record
+		rules     reconcile.Rules
+		changes   map[int]*reconcile.Record
+		fail      error
+		found     map[reconcile.Class]int
+		repaired  map[reconcile.Class]int
+		remaining int
+		stale     int
+		failed    int
+		usersRes  map[int]bool
+		detailRes map[int]bool
+	}{
+		{
+			testName:  "consistent stores",
+			users:     []reconcile.Record{record(1, "acme", true), record(2, "acme", false), record(3, "acme", false)},
+			details:   []reconcile.Record{record(1, "acme", true), record(2, "acme", false), record(4, "acme", false)},
+			found:     map[reconcile.Class]int{},
+			repaired:  map[reconcile.Class]int{},
+			usersRes:  map[int]bool{1: true, 2: false, 3: false},
+			detailRes: map[int]bool{1: true, 2: false, 4: false},
+		},
+		{
+			testName: "every class is reported",
+			users:    []reconcile.Record{record(1, "acme", true), record(2, "acme", false), record(3, "acme", true), record(5, "acme", true)},
+			details:  []reconcile.Record{record(2, "acme", true), record(3, "acme", false), record(4, "acme", true), record(5, "other", true)},
+			found: map[reconcile.Class]int{
+				reconcile.MissingDetails: 1,
+				reconcile.OrphanDetails:  1,
+				reconcile.ActiveMismatch: 2,
+				reconcile.TenantMismatch: 1,
+			},
+			repaired:  map[reconcile.Class]int{},
+			remaining: 5,
+			usersRes:  map[int]bool{1: true, 2: false, 3: true, 5: true},
+			detailRes: map[int]bool{2: true, 3: false, 4: true, 5: true},
+		},
+		{
+			testName: "repairs follow the rules",
+			users:    []reconcile.Record{record(1, "acme", true), record(2, "acme", false), record(3, "acme", true), record(5, "acme", true)},
+			details:  []reconcile.Record{record(2, "acme", true), record(3, "acme", false), record(4, "acme", true), record(5, "other", true)},
+			rules: reconcile.Rules{
+				reconcile.MissingDetails: reconcile.CreateDetails,
+				reconcile.OrphanDetails:  reconcile.DeactivateDetails,
+				reconcile.ActiveMismatch: reconcile.Deactivate,
+			},
+			found: map[reconcile.Class]int{
+				reconcile.MissingDetails: 1,
+				reconcile.OrphanDetails:  1,
+				reconcile.ActiveMismatch: 2,
+				reconcile.TenantMismatch: 1,
+			},
+			repaired: map[reconcile.Class]int{
+				reconcile.MissingDetails: 1,
+				reconcile.OrphanDetails:  1,
+				reconcile.ActiveMismatch: 2,
+			},
+			remaining: 1,
+			usersRes:  map[int]bool{1: true, 2: false, 3: false, 5: true},
+			detailRes: map[int]bool{1: true, 2: false, 3: false, 4: false, 5: true},
+		},
+		{
+			testName:  "missing details deactivate the user",
+			users:     []reconcile.Record{record(1, "acme", true)},
+			rules:     reconcile.Rules{reconcile.MissingDetails: reconcile.DeactivateUser},
+			found:     map[reconcile.Class]int{reconcile.MissingDetails: 1},
+			repaired:  map[reconcile.Class]int{reconcile.MissingDetails: 1},
+			usersRes:  map[int]bool{1: false},
+			detailRes: map[int]bool{},
+		},
+		{
+			testName:  "records changed since the scan are not repaired",
+			users:     []reconcile.Record{record(1, "acme", true)},
+			rules:     reconcile.Rules{reconcile.MissingDetails: reconcile.DeactivateUser},
+			changes:   map[int]*reconcile.Record{1: {ID: 1, Tenant: "acme", Active: true}},
+			found:     map[reconcile.Class]int{reconcile.MissingDetails: 1},
+			repaired:  map[reconcile.Class]int{},
+			stale:     1,
+			usersRes:  map[int]bool{1: true},
+			detailRes: map[int]bool{1: true},
+		},
+		{
+			testName:  "failed repair",
+			details:   []reconcile.Record{record(1, "acme", true)},
+			rules:     reconcile.Rules{reconcile.OrphanDetails: reconcile.DeactivateDetails},
+			fail:      errors.New("store unavailable"),
+			found:     map[reconcile.Class]int{reconcile.OrphanDetails: 1},
+			repaired:  map[reconcile.Class]int{},
+			remaining: 1,
+			failed:    1,
+			usersRes:  map[int]bool{},
+			detailRes: map[int]bool{1: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// prepare
+			users := newStore(tc.users...)
+			details := newStore(tc.details...)
+			details.changes = tc.changes
+			details.fail = tc.fail
+			r := reconcile.NewReconciler(users, details, tc.rules, 2, logger)
+			var findings []reconcile.Finding
+
+			// act
+			summary, err := r.Run(context.Background(), func(f reconcile.Finding) { findings = append(findings, f) })
+
+			// assert
+			assert.NoError(err)
+			assert.Equal(tc.found, summary.Found)
+			assert.Equal(tc.repaired, summary.Repaired)
+			assert.Equal(tc.remaining, summary.Remaining)
+			assert.Equal(tc.stale, summary.Stale)
+			assert.Equal(tc.failed, summary.Failed)
+			assert.Equal(len(tc.users), summary.ScannedUsers)
+			assert.Equal(len(tc.details), summary.ScannedDetails)
+
+			total := 0
+			for _, n := range tc.found {
+				total += n
+			}
+			assert.Len(findings, total)
+
+			for id, active := range tc.usersRes {
+				assert.Equal(active, users.records[id].Active, "user %v", id)
+			}
+			for id, active := range tc.detailRes {
+				assert.Equal(active, details.records[id].Active, "details %v", id)
+			}
+		})
+	}
+}
+
+func TestRunPages(t *testing.T) {
+	assert := assert.New(t)
+
+	// prepare
+	users := newStore()
+	details := newStore()
+	for id := 1; id <= 10; id++ {
+		users.records[id] = record(id, "acme", true)
+		details.records[id] = record(id, "acme", true)
+	}
+	r := reconcile.NewReconciler(users, details, nil, 3, log.NewNopLogger())
+
+	// act
+	summary, err := r.Run(context.Background(), nil)
+
+	// assert
+	assert.NoError(err)
+	assert.Equal(10, summary.ScannedUsers)
+	assert.Equal(10, summary.ScannedDetails)
+	assert.Equal(0, summary.Remaining)
+	assert.Equal(4, users.pages)
+	assert.Equal(4, details.pages)
+}
+
+func TestRulesValidate(t *testing.T) {
+	testCases := []struct {
+		testName string
+		rules    reconcile.Rules
+		err      bool
+	}{
+		{
+			testName: "default rules",
+			rules:    reconcile.Rules{},
+		},
+		{
+			testName: "allowed actions",
+			rules: reconcile.Rules{
+				reconcile.MissingDetails: reconcile.DeactivateUser,
+				reconcile.OrphanDetails:  reconcile.DeactivateDetails,
+				reconcile.ActiveMismatch: reconcile.Deactivate,
+				reconcile.TenantMismatch: reconcile.Report,
+			},
+		},
+		{
+			testName: "action not allowed for the class",
+			rules:    reconcile.Rules{reconcile.OrphanDetails: reconcile.CreateDetails},
+			err:      true,
+		},
+		{
+			testName: "tenant mismatch is only reported",
+			rules:    reconcile.Rules{reconcile.TenantMismatch: reconcile.Deactivate},
+			err:      true,
+		},
+		{
+			testName: "unknown action",
+			rules:    reconcile.Rules{reconcile.MissingDetails: "delete"},
+			err:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// act
+			err := tc.rules.Validate()
+
+			// assert
+			assert.Equal(tc.err, err != nil)
+		})
+	}
+}
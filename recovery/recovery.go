@@ -0,0 +1,40 @@
+package recovery
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor turns a panic of the handler into an internal error, the panic and its stack are logged
+// and the server keeps serving the other calls
+func UnaryServerInterceptor(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				level.Error(logger).Log("method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+				res, err = nil, errors.Status(errors.NewInternalError()).Err()
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the UnaryServerInterceptor of the streaming calls
+func StreamServerInterceptor(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				level.Error(logger).Log("method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+				err = errors.Status(errors.NewInternalError()).Err()
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
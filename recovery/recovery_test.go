@@ -0,0 +1,76 @@
+package recovery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/recovery"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := recovery.UnaryServerInterceptor(log.NewNopLogger())
+
+	testCases := []struct {
+		testName string
+		handler  grpc.UnaryHandler
+		res      interface{}
+		err      error
+	}{
+		{
+			testName: "handler response success",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return "done", nil
+			},
+			res: "done",
+		},
+		{
+			testName: "handler error kept",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, errors.Status(errors.NewUserNotFoundError()).Err()
+			},
+			err: errors.Status(errors.NewUserNotFoundError()).Err(),
+		},
+		{
+			testName: "panic internal error",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				var m map[string]int
+				m["boom"]++
+				return "unreachable", nil
+			},
+			err: errors.Status(errors.NewInternalError()).Err(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			res, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/UserService/GetUser"}, tc.handler)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	interceptor := recovery.StreamServerInterceptor(log.NewNopLogger())
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		panic("stream handler failed")
+	}
+
+	// act
+	err := interceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: "/UserService/WatchUser"}, handler)
+
+	// assert
+	assert.Equal(errors.Status(errors.NewInternalError()).Err(), err)
+}
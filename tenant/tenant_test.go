@@ -0,0 +1,179 @@
+package tenant_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := tenant.UnaryServerInterceptor()
+	missing := errors.NewMissingTenantError()
+
+	testCases := []struct {
+		testName string
+		md       metadata.MD
+		res      interface{}
+		err      error
+	}{
+		{
+			testName: "tenant stored within context",
+			md:       metadata.Pairs(tenant.MetadataKey, "acme"),
+			res:      "acme",
+			err:      nil,
+		},
+		{
+			testName: "no tenant error",
+			md:       metadata.MD{},
+			err:      errors.Status(missing).Err(),
+		},
+		{
+			testName: "invalid tenant error",
+			md:       metadata.Pairs(tenant.MetadataKey, "Acme Corp"),
+			err:      errors.Status(missing).Err(),
+		},
+		{
+			testName: "several tenants error",
+			md:       metadata.Pairs(tenant.MetadataKey, "acme", tenant.MetadataKey, "globex"),
+			err:      errors.Status(missing).Err(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				id, _ := tenant.FromContext(ctx)
+				return id, nil
+			}
+
+			// act
+			res, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	interceptor := tenant.UnaryClientInterceptor()
+
+	testCases := []struct {
+		testName string
+		ctx      context.Context
+		res      []string
+	}{
+		{
+			testName: "tenant sent as metadata",
+			ctx:      tenant.NewContext(context.Background(), "acme"),
+			res:      []string{"acme"},
+		},
+		{
+			testName: "no tenant within context",
+			ctx:      context.Background(),
+			res:      nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var sent []string
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				sent = md.Get(tenant.MetadataKey)
+				return nil
+			}
+
+			// act
+			err := interceptor(tc.ctx, "/UserService/GetUser", nil, nil, nil, invoker)
+
+			// assert
+			assert.Nil(err)
+			assert.Equal(tc.res, sent)
+		})
+	}
+}
+
+// fakeServerStream only carries the context of the stream
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := tenant.StreamServerInterceptor()
+	missing := errors.NewMissingTenantError()
+
+	testCases := []struct {
+		testName string
+		md       metadata.MD
+		res      string
+		err      error
+	}{
+		{
+			testName: "tenant stored within stream context",
+			md:       metadata.Pairs(tenant.MetadataKey, "acme"),
+			res:      "acme",
+			err:      nil,
+		},
+		{
+			testName: "no tenant error",
+			md:       metadata.MD{},
+			err:      errors.Status(missing).Err(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ss := fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), tc.md)}
+			var res string
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				res, _ = tenant.FromContext(stream.Context())
+				return nil
+			}
+
+			// act
+			err := interceptor(nil, ss, &grpc.StreamServerInfo{}, handler)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	// prepare
+	assert := assert.New(t)
+	interceptor := tenant.StreamClientInterceptor()
+	var sent []string
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get(tenant.MetadataKey)
+		return nil, nil
+	}
+
+	// act
+	_, err := interceptor(tenant.NewContext(context.Background(), "acme"), &grpc.StreamDesc{}, nil, "/UserService/WatchUser", streamer)
+
+	// assert
+	assert.Nil(err)
+	assert.Equal([]string{"acme"}, sent)
+}
@@ -21,6 +21,20 @@ type UserDetailsServiceClient interface {
 	SetUserDetails(ctx context.Context, in *SetUserDetailsRequest, opts ...grpc.CallOption) (*SetUserDetailsResponse, error)
 	GetUserDetails(ctx context.Context, in *GetUserDetailsRequest, opts ...grpc.CallOption) (*GetUserDetailsResponse, error)
 	DeleteUserDetails(ctx context.Context, in *DeleteUserDetailsRequest, opts ...grpc.CallOption) (*DeleteUserDetailsResponse, error)
+	SetAttributeDefinition(ctx context.Context, in *SetAttributeDefinitionRequest, opts ...grpc.CallOption) (*SetAttributeDefinitionResponse, error)
+	GetAttributeSchema(ctx context.Context, in *GetAttributeSchemaRequest, opts ...grpc.CallOption) (*GetAttributeSchemaResponse, error)
+	DeleteAttributeDefinition(ctx context.Context, in *DeleteAttributeDefinitionRequest, opts ...grpc.CallOption) (*DeleteAttributeDefinitionResponse, error)
+	AddAddress(ctx context.Context, in *AddAddressRequest, opts ...grpc.CallOption) (*AddAddressResponse, error)
+	ListAddresses(ctx context.Context, in *ListAddressesRequest, opts ...grpc.CallOption) (*ListAddressesResponse, error)
+	GetAddress(ctx context.Context, in *GetAddressRequest, opts ...grpc.CallOption) (*GetAddressResponse, error)
+	UpdateAddress(ctx context.Context, in *UpdateAddressRequest, opts ...grpc.CallOption) (*UpdateAddressResponse, error)
+	DeleteAddress(ctx context.Context, in *DeleteAddressRequest, opts ...grpc.CallOption) (*DeleteAddressResponse, error)
+	SendPhoneVerification(ctx context.Context, in *SendPhoneVerificationRequest, opts ...grpc.CallOption) (*SendPhoneVerificationResponse, error)
+	VerifyPhone(ctx context.Context, in *VerifyPhoneRequest, opts ...grpc.CallOption) (*VerifyPhoneResponse, error)
+	SetAvatar(ctx context.Context, in *SetAvatarRequest, opts ...grpc.CallOption) (*SetAvatarResponse, error)
+	ListChanges(ctx context.Context, in *ListDetailsChangesRequest, opts ...grpc.CallOption) (*ListDetailsChangesResponse, error)
+	BatchGetUserDetails(ctx context.Context, in *BatchGetUserDetailsRequest, opts ...grpc.CallOption) (*BatchGetUserDetailsResponse, error)
+	WatchUserDetails(ctx context.Context, in *WatchUserDetailsRequest, opts ...grpc.CallOption) (UserDetailsService_WatchUserDetailsClient, error)
 }
 
 type userDetailsServiceClient struct {
@@ -58,6 +72,155 @@ func (c *userDetailsServiceClient) DeleteUserDetails(ctx context.Context, in *De
 	return out, nil
 }
 
+func (c *userDetailsServiceClient) SetAttributeDefinition(ctx context.Context, in *SetAttributeDefinitionRequest, opts ...grpc.CallOption) (*SetAttributeDefinitionResponse, error) {
+	out := new(SetAttributeDefinitionResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/SetAttributeDefinition", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) GetAttributeSchema(ctx context.Context, in *GetAttributeSchemaRequest, opts ...grpc.CallOption) (*GetAttributeSchemaResponse, error) {
+	out := new(GetAttributeSchemaResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/GetAttributeSchema", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) DeleteAttributeDefinition(ctx context.Context, in *DeleteAttributeDefinitionRequest, opts ...grpc.CallOption) (*DeleteAttributeDefinitionResponse, error) {
+	out := new(DeleteAttributeDefinitionResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/DeleteAttributeDefinition", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) AddAddress(ctx context.Context, in *AddAddressRequest, opts ...grpc.CallOption) (*AddAddressResponse, error) {
+	out := new(AddAddressResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/AddAddress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) ListAddresses(ctx context.Context, in *ListAddressesRequest, opts ...grpc.CallOption) (*ListAddressesResponse, error) {
+	out := new(ListAddressesResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/ListAddresses", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) GetAddress(ctx context.Context, in *GetAddressRequest, opts ...grpc.CallOption) (*GetAddressResponse, error) {
+	out := new(GetAddressResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/GetAddress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) UpdateAddress(ctx context.Context, in *UpdateAddressRequest, opts ...grpc.CallOption) (*UpdateAddressResponse, error) {
+	out := new(UpdateAddressResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/UpdateAddress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) DeleteAddress(ctx context.Context, in *DeleteAddressRequest, opts ...grpc.CallOption) (*DeleteAddressResponse, error) {
+	out := new(DeleteAddressResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/DeleteAddress", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) SendPhoneVerification(ctx context.Context, in *SendPhoneVerificationRequest, opts ...grpc.CallOption) (*SendPhoneVerificationResponse, error) {
+	out := new(SendPhoneVerificationResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/SendPhoneVerification", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) VerifyPhone(ctx context.Context, in *VerifyPhoneRequest, opts ...grpc.CallOption) (*VerifyPhoneResponse, error) {
+	out := new(VerifyPhoneResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/VerifyPhone", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) SetAvatar(ctx context.Context, in *SetAvatarRequest, opts ...grpc.CallOption) (*SetAvatarResponse, error) {
+	out := new(SetAvatarResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/SetAvatar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) ListChanges(ctx context.Context, in *ListDetailsChangesRequest, opts ...grpc.CallOption) (*ListDetailsChangesResponse, error) {
+	out := new(ListDetailsChangesResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/ListChanges", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) BatchGetUserDetails(ctx context.Context, in *BatchGetUserDetailsRequest, opts ...grpc.CallOption) (*BatchGetUserDetailsResponse, error) {
+	out := new(BatchGetUserDetailsResponse)
+	err := c.cc.Invoke(ctx, "/UserDetailsService/BatchGetUserDetails", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userDetailsServiceClient) WatchUserDetails(ctx context.Context, in *WatchUserDetailsRequest, opts ...grpc.CallOption) (UserDetailsService_WatchUserDetailsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &UserDetailsService_ServiceDesc.Streams[0], "/UserDetailsService/WatchUserDetails", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &userDetailsServiceWatchUserDetailsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type UserDetailsService_WatchUserDetailsClient interface {
+	Recv() (*DetailsChange, error)
+	grpc.ClientStream
+}
+
+type userDetailsServiceWatchUserDetailsClient struct {
+	grpc.ClientStream
+}
+
+func (x *userDetailsServiceWatchUserDetailsClient) Recv() (*DetailsChange, error) {
+	m := new(DetailsChange)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // UserDetailsServiceServer is the server API for UserDetailsService service.
 // All implementations must embed UnimplementedUserDetailsServiceServer
 // for forward compatibility
@@ -65,6 +228,20 @@ type UserDetailsServiceServer interface {
 	SetUserDetails(context.Context, *SetUserDetailsRequest) (*SetUserDetailsResponse, error)
 	GetUserDetails(context.Context, *GetUserDetailsRequest) (*GetUserDetailsResponse, error)
 	DeleteUserDetails(context.Context, *DeleteUserDetailsRequest) (*DeleteUserDetailsResponse, error)
+	SetAttributeDefinition(context.Context, *SetAttributeDefinitionRequest) (*SetAttributeDefinitionResponse, error)
+	GetAttributeSchema(context.Context, *GetAttributeSchemaRequest) (*GetAttributeSchemaResponse, error)
+	DeleteAttributeDefinition(context.Context, *DeleteAttributeDefinitionRequest) (*DeleteAttributeDefinitionResponse, error)
+	AddAddress(context.Context, *AddAddressRequest) (*AddAddressResponse, error)
+	ListAddresses(context.Context, *ListAddressesRequest) (*ListAddressesResponse, error)
+	GetAddress(context.Context, *GetAddressRequest) (*GetAddressResponse, error)
+	UpdateAddress(context.Context, *UpdateAddressRequest) (*UpdateAddressResponse, error)
+	DeleteAddress(context.Context, *DeleteAddressRequest) (*DeleteAddressResponse, error)
+	SendPhoneVerification(context.Context, *SendPhoneVerificationRequest) (*SendPhoneVerificationResponse, error)
+	VerifyPhone(context.Context, *VerifyPhoneRequest) (*VerifyPhoneResponse, error)
+	SetAvatar(context.Context, *SetAvatarRequest) (*SetAvatarResponse, error)
+	ListChanges(context.Context, *ListDetailsChangesRequest) (*ListDetailsChangesResponse, error)
+	BatchGetUserDetails(context.Context, *BatchGetUserDetailsRequest) (*BatchGetUserDetailsResponse, error)
+	WatchUserDetails(*WatchUserDetailsRequest, UserDetailsService_WatchUserDetailsServer) error
 	mustEmbedUnimplementedUserDetailsServiceServer()
 }
 
@@ -81,6 +258,48 @@ func (UnimplementedUserDetailsServiceServer) GetUserDetails(context.Context, *Ge
 func (UnimplementedUserDetailsServiceServer) DeleteUserDetails(context.Context, *DeleteUserDetailsRequest) (*DeleteUserDetailsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserDetails not implemented")
 }
+func (UnimplementedUserDetailsServiceServer) SetAttributeDefinition(context.Context, *SetAttributeDefinitionRequest) (*SetAttributeDefinitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAttributeDefinition not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) GetAttributeSchema(context.Context, *GetAttributeSchemaRequest) (*GetAttributeSchemaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAttributeSchema not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) DeleteAttributeDefinition(context.Context, *DeleteAttributeDefinitionRequest) (*DeleteAttributeDefinitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAttributeDefinition not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) AddAddress(context.Context, *AddAddressRequest) (*AddAddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddAddress not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) ListAddresses(context.Context, *ListAddressesRequest) (*ListAddressesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAddresses not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) GetAddress(context.Context, *GetAddressRequest) (*GetAddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAddress not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) UpdateAddress(context.Context, *UpdateAddressRequest) (*UpdateAddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateAddress not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) DeleteAddress(context.Context, *DeleteAddressRequest) (*DeleteAddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAddress not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) SendPhoneVerification(context.Context, *SendPhoneVerificationRequest) (*SendPhoneVerificationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendPhoneVerification not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) VerifyPhone(context.Context, *VerifyPhoneRequest) (*VerifyPhoneResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyPhone not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) SetAvatar(context.Context, *SetAvatarRequest) (*SetAvatarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAvatar not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) ListChanges(context.Context, *ListDetailsChangesRequest) (*ListDetailsChangesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChanges not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) BatchGetUserDetails(context.Context, *BatchGetUserDetailsRequest) (*BatchGetUserDetailsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetUserDetails not implemented")
+}
+func (UnimplementedUserDetailsServiceServer) WatchUserDetails(*WatchUserDetailsRequest, UserDetailsService_WatchUserDetailsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchUserDetails not implemented")
+}
 func (UnimplementedUserDetailsServiceServer) mustEmbedUnimplementedUserDetailsServiceServer() {}
 
 // UnsafeUserDetailsServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -148,6 +367,261 @@ func _UserDetailsService_DeleteUserDetails_Handler(srv interface{}, ctx context.
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserDetailsService_SetAttributeDefinition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAttributeDefinitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).SetAttributeDefinition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/SetAttributeDefinition",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).SetAttributeDefinition(ctx, req.(*SetAttributeDefinitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_GetAttributeSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAttributeSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).GetAttributeSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/GetAttributeSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).GetAttributeSchema(ctx, req.(*GetAttributeSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_DeleteAttributeDefinition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAttributeDefinitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).DeleteAttributeDefinition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/DeleteAttributeDefinition",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).DeleteAttributeDefinition(ctx, req.(*DeleteAttributeDefinitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_AddAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddAddressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).AddAddress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/AddAddress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).AddAddress(ctx, req.(*AddAddressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_ListAddresses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAddressesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).ListAddresses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/ListAddresses",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).ListAddresses(ctx, req.(*ListAddressesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_GetAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAddressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).GetAddress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/GetAddress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).GetAddress(ctx, req.(*GetAddressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_UpdateAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateAddressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).UpdateAddress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/UpdateAddress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).UpdateAddress(ctx, req.(*UpdateAddressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_DeleteAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAddressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).DeleteAddress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/DeleteAddress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).DeleteAddress(ctx, req.(*DeleteAddressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_SendPhoneVerification_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendPhoneVerificationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).SendPhoneVerification(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/SendPhoneVerification",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).SendPhoneVerification(ctx, req.(*SendPhoneVerificationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_VerifyPhone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyPhoneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).VerifyPhone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/VerifyPhone",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).VerifyPhone(ctx, req.(*VerifyPhoneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_SetAvatar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAvatarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).SetAvatar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/SetAvatar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).SetAvatar(ctx, req.(*SetAvatarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_ListChanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDetailsChangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).ListChanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/ListChanges",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).ListChanges(ctx, req.(*ListDetailsChangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_BatchGetUserDetails_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetUserDetailsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserDetailsServiceServer).BatchGetUserDetails(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UserDetailsService/BatchGetUserDetails",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserDetailsServiceServer).BatchGetUserDetails(ctx, req.(*BatchGetUserDetailsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserDetailsService_WatchUserDetails_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchUserDetailsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserDetailsServiceServer).WatchUserDetails(m, &userDetailsServiceWatchUserDetailsServer{stream})
+}
+
+type UserDetailsService_WatchUserDetailsServer interface {
+	Send(*DetailsChange) error
+	grpc.ServerStream
+}
+
+type userDetailsServiceWatchUserDetailsServer struct {
+	grpc.ServerStream
+}
+
+func (x *userDetailsServiceWatchUserDetailsServer) Send(m *DetailsChange) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // UserDetailsService_ServiceDesc is the grpc.ServiceDesc for UserDetailsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -167,7 +641,65 @@ var UserDetailsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteUserDetails",
 			Handler:    _UserDetailsService_DeleteUserDetails_Handler,
 		},
+		{
+			MethodName: "SetAttributeDefinition",
+			Handler:    _UserDetailsService_SetAttributeDefinition_Handler,
+		},
+		{
+			MethodName: "GetAttributeSchema",
+			Handler:    _UserDetailsService_GetAttributeSchema_Handler,
+		},
+		{
+			MethodName: "DeleteAttributeDefinition",
+			Handler:    _UserDetailsService_DeleteAttributeDefinition_Handler,
+		},
+		{
+			MethodName: "AddAddress",
+			Handler:    _UserDetailsService_AddAddress_Handler,
+		},
+		{
+			MethodName: "ListAddresses",
+			Handler:    _UserDetailsService_ListAddresses_Handler,
+		},
+		{
+			MethodName: "GetAddress",
+			Handler:    _UserDetailsService_GetAddress_Handler,
+		},
+		{
+			MethodName: "UpdateAddress",
+			Handler:    _UserDetailsService_UpdateAddress_Handler,
+		},
+		{
+			MethodName: "DeleteAddress",
+			Handler:    _UserDetailsService_DeleteAddress_Handler,
+		},
+		{
+			MethodName: "SendPhoneVerification",
+			Handler:    _UserDetailsService_SendPhoneVerification_Handler,
+		},
+		{
+			MethodName: "VerifyPhone",
+			Handler:    _UserDetailsService_VerifyPhone_Handler,
+		},
+		{
+			MethodName: "SetAvatar",
+			Handler:    _UserDetailsService_SetAvatar_Handler,
+		},
+		{
+			MethodName: "ListChanges",
+			Handler:    _UserDetailsService_ListChanges_Handler,
+		},
+		{
+			MethodName: "BatchGetUserDetails",
+			Handler:    _UserDetailsService_BatchGetUserDetails_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchUserDetails",
+			Handler:       _UserDetailsService_WatchUserDetails_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "details.proto",
 }
@@ -1,13 +1,89 @@
 package entities
 
+import "time"
+
 // UserDetails stores the user's information
 type UserDetails struct {
-	UserID       int     `bson:"_id"`
-	Country      string  `bson:"country"`
-	City         string  `bson:"city"`
-	MobileNumber string  `bson:"mobile_number"`
-	Married      bool    `bson:"married"`
-	Height       float32 `bson:"height"`
-	Weight       float32 `bson:"weight"`
-	Active       bool    `bson:"active"`
+	UserID         int                    `bson:"_id"`
+	TenantID       string                 `bson:"tenant_id"`
+	Country        string                 `bson:"country"`
+	City           string                 `bson:"city"`
+	MobileNumber   string                 `bson:"mobile_number"`
+	MobileVerified bool                   `bson:"mobile_verified"`
+	Married        bool                   `bson:"married"`
+	Height         float32                `bson:"height"`
+	Weight         float32                `bson:"weight"`
+	Attributes     map[string]interface{} `bson:"attributes,omitempty"`
+	Avatar         string                 `bson:"avatar,omitempty"`
+	Active         bool                   `bson:"active"`
+	Revision       int64                  `bson:"revision"`
+}
+
+// AnyRevision replaces the details whatever their revision is, the revision counts the writes of the details and
+// it is 0 while they are missing
+const AnyRevision int64 = -1
+
+// UserDetailsResult stores the details of one of the ids of a batch, Found is false when the details do not exist
+type UserDetailsResult struct {
+	UserID  int
+	Found   bool
+	Details UserDetails
+}
+
+// AttributeType describes the kind of value a custom attribute stores
+type AttributeType string
+
+const (
+	// StringAttribute stores text values
+	StringAttribute AttributeType = "string"
+	// IntAttribute stores integer values
+	IntAttribute AttributeType = "int"
+	// FloatAttribute stores decimal values
+	FloatAttribute AttributeType = "float"
+	// BoolAttribute stores true or false values
+	BoolAttribute AttributeType = "bool"
+)
+
+// AttributeConstraints stores the rules that a custom attribute value must follow
+type AttributeConstraints struct {
+	Min       *float64 `bson:"min,omitempty"`
+	Max       *float64 `bson:"max,omitempty"`
+	MinLength int      `bson:"min_length,omitempty"`
+	MaxLength int      `bson:"max_length,omitempty"`
+	Pattern   string   `bson:"pattern,omitempty"`
+	Enum      []string `bson:"enum,omitempty"`
+}
+
+// AttributeDefinition stores the schema of an admin-defined custom attribute
+type AttributeDefinition struct {
+	Name        string               `bson:"name"`
+	TenantID    string               `bson:"tenant_id"`
+	Type        AttributeType        `bson:"type"`
+	Required    bool                 `bson:"required"`
+	Constraints AttributeConstraints `bson:"constraints"`
+}
+
+// Address stores one of the postal addresses of the user
+type Address struct {
+	ID          string   `bson:"_id"`
+	UserID      int      `bson:"user_id"`
+	TenantID    string   `bson:"tenant_id"`
+	Lines       []string `bson:"lines"`
+	Locality    string   `bson:"locality"`
+	Region      string   `bson:"region"`
+	PostalCode  string   `bson:"postal_code"`
+	CountryCode string   `bson:"country_code"`
+	Label       string   `bson:"label"`
+	Primary     bool     `bson:"primary"`
+}
+
+// PhoneVerification stores the pending one-time code sent to the mobile number of the user
+type PhoneVerification struct {
+	UserID       int       `bson:"user_id"`
+	TenantID     string    `bson:"tenant_id"`
+	Number       string    `bson:"number"`
+	CodeHash     string    `bson:"code_hash"`
+	Attempts     int       `bson:"attempts"`
+	ExpiresAt    time.Time `bson:"expires_at"`
+	WindowEndsAt time.Time `bson:"window_ends_at"`
 }
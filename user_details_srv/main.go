@@ -4,16 +4,24 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/grpcweb"
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/mauricioww/user_microsrv/recovery"
+	"github.com/mauricioww/user_microsrv/tenant"
 	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
 	"github.com/mauricioww/user_microsrv/user_details_srv/repository"
 	"github.com/mauricioww/user_microsrv/user_details_srv/service"
+	"github.com/mauricioww/user_microsrv/user_details_srv/sms"
 	"github.com/mauricioww/user_microsrv/user_details_srv/transport"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -43,6 +51,12 @@ func main() {
 	}
 	level.Info(logger).Log("mesg", "service started")
 
+	// the codes are hashed with the key, an empty key would make them guessable from the stored hashes
+	if cts.CodeKey == "" {
+		level.Error(logger).Log("exit", "CODE_KEY is required")
+		os.Exit(-1)
+	}
+
 	defer level.Info(logger).Log("msg", "service ended")
 
 	var db *mongo.Database
@@ -62,12 +76,29 @@ func main() {
 		}
 
 		db = client.Database(cts.DbName)
+
+		if err := repository.EnsureChangeIndexes(context.Background(), db); err != nil {
+			level.Error(logger).Log("exit", err)
+			os.Exit(-1)
+		}
 	}
 
 	var srv service.GrpcUserDetailsServicer
 	{
 		mongoRepository := repository.NewUserDetailsRepository(db, logger)
-		srv = service.NewGrpcUserDetailsService(mongoRepository, logger)
+		srv = service.NewGrpcUserDetailsService(mongoRepository, sms.NewLogSender(logger), []byte(cts.CodeKey), logger)
+	}
+
+	{
+		bus, err := events.NewBus(cts.EventBus, cts.NatsURL)
+		if err != nil {
+			level.Error(logger).Log("exit", err)
+			os.Exit(-1)
+		}
+		defer bus.Close()
+
+		relay := events.NewRelay(repository.NewOutbox(db), bus, cts.OutboxInterval, 0, cts.OutboxRetention, logger)
+		go relay.Run(context.Background())
 	}
 
 	errs := make(chan error)
@@ -87,15 +118,26 @@ func main() {
 		os.Exit(-1)
 	}
 
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(recovery.UnaryServerInterceptor(logger), locale.UnaryServerInterceptor(), tenant.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(recovery.StreamServerInterceptor(logger), locale.StreamServerInterceptor(), tenant.StreamServerInterceptor()),
+	)
+	detailspb.RegisterUserDetailsServiceServer(server, grpcServer)
+
 	go func() {
-		server := grpc.NewServer()
-		detailspb.RegisterUserDetailsServiceServer(server, grpcServer)
 		if err := server.Serve(listener); err != nil {
 			logger.Log("Error serving", err)
 		}
 		level.Info(logger).Log("info", "grpc server started")
 	}()
 
+	// the browsers and curl call the exposed methods over gRPC-Web and Connect, the port stays closed unless it is set
+	if cts.WebPort != "" {
+		go func() {
+			errs <- http.ListenAndServe(":"+cts.WebPort, grpcweb.NewHandler(server, cts.WebOrigins, cts.WebMethods))
+		}()
+	}
+
 	level.Error(logger).Log("exit: ", <-errs)
 }
 
@@ -105,4 +147,17 @@ type constants struct {
 	DbHost string `env:"DB_HOST,required"`
 	DbPort int    `env:"DB_PORT" envDefault:"27017"`
 	DbName string `env:"DB_NAME" envDefault:"grpc_details"`
+
+	CodeKey string `env:"CODE_KEY,required"`
+
+	EventBus        string        `env:"EVENT_BUS,required"`
+	NatsURL         string        `env:"NATS_URL" envDefault:"nats://nats:4222"`
+	OutboxInterval  time.Duration `env:"OUTBOX_INTERVAL" envDefault:"1s"`
+	OutboxRetention time.Duration `env:"OUTBOX_RETENTION" envDefault:"720h"`
+
+	// the gRPC-Web server has no authentication of its own, it only starts when WEB_PORT is set and serves the
+	// methods of WEB_METHODS, e.g. /UserService/GetUser, to the browsers of WEB_ORIGINS
+	WebPort    string   `env:"WEB_PORT"`
+	WebOrigins []string `env:"WEB_ORIGINS" envSeparator:","`
+	WebMethods []string `env:"WEB_METHODS" envSeparator:","`
 }
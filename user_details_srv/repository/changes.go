@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The outbox arrays only hold the events until they are published, so the published events are copied to the
+// changes collection, which keeps the history of changes of the details until the relay prunes it. Every change
+// gets the next value of the changes counter, the sequence orders the feed and the cursors point to it.
+//
+// The server has no transactions, so a change could become visible before a lower sequence allocated by another
+// relay. The relays take the writer lease before they record changes, which makes them write one after the other,
+// and they move the committed sequence of the counter once their changes are inserted: the feeds stop at the
+// committed sequence, so once a sequence is visible every lower sequence is visible too. A relay gives up its
+// changes before its lease expires, the sequences it allocated are then left as gaps
+
+// changesCounter is the id of the document of the counters collection which allocates the sequences
+const changesCounter = "changes"
+
+// writerLease is the id of the document of the counters collection held by the relay recording changes
+const writerLease = "changes_writer"
+
+// leaseTTL is how long a relay holds the writer lease, a relay which stops keeps the other ones waiting that long
+const leaseTTL = 30 * time.Second
+
+// changeEntry stores one published event within the changes collection
+type changeEntry struct {
+	Seq        int64     `bson:"seq"`
+	Tenant     string    `bson:"tenant"`
+	UserID     int       `bson:"user_id"`
+	EventID    string    `bson:"event_id"`
+	Payload    string    `bson:"payload"`
+	RecordedAt time.Time `bson:"recorded_at"`
+}
+
+// EnsureChangeIndexes creates the indexes of the changes collection, the unique event id keeps an event
+// recorded once when the outbox is flushed again after a failure
+func EnsureChangeIndexes(ctx context.Context, mongoDb *mongo.Database) error {
+	_, err := mongoDb.Collection("changes").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"event_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"tenant", 1}, {"seq", 1}}},
+		{Keys: bson.D{{"tenant", 1}, {"user_id", 1}, {"seq", 1}}},
+		{Keys: bson.D{{"recorded_at", 1}}},
+	})
+	return err
+}
+
+// acquireLease takes the writer lease for the owner, the lease of the same owner is extended
+func acquireLease(ctx context.Context, mongoDb *mongo.Database, owner string) error {
+	now := time.Now().UTC()
+	filter := bson.D{{"_id", writerLease}, {"$or", bson.A{
+		bson.D{{"until", bson.D{{"$lt", now}}}},
+		bson.D{{"owner", owner}},
+	}}}
+	update := bson.D{{"$set", bson.D{{"owner", owner}, {"until", now.Add(leaseTTL)}}}}
+
+	// the upsert only inserts the lease when nobody holds it, otherwise the id is duplicated and the
+	// events are published again by the next flush
+	_, err := mongoDb.Collection("counters").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("the changes are being recorded by another relay")
+	}
+
+	return err
+}
+
+// releaseLease gives up the writer lease of the owner
+func releaseLease(ctx context.Context, mongoDb *mongo.Database, owner string) error {
+	_, err := mongoDb.Collection("counters").DeleteOne(ctx, bson.D{{"_id", writerLease}, {"owner", owner}})
+	return err
+}
+
+// recordChanges copies the outbox entries with the given ids to the changes collection, in the order of the ids
+func recordChanges(ctx context.Context, mongoDb *mongo.Database, ids []string) error {
+	opts := options.Find().SetProjection(bson.D{{"outbox", 1}})
+	cursor, err := mongoDb.Collection("information").Find(ctx, bson.D{{"outbox.id", bson.D{{"$in", ids}}}}, opts)
+	if err != nil {
+		return err
+	}
+
+	var docs []struct {
+		Outbox []outboxEntry `bson:"outbox"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return err
+	}
+
+	entries := make(map[string]outboxEntry)
+	for _, d := range docs {
+		for _, entry := range d.Outbox {
+			entries[entry.ID] = entry
+		}
+	}
+
+	var found []outboxEntry
+	for _, id := range ids {
+		if entry, ok := entries[id]; ok {
+			found = append(found, entry)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	after := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err = mongoDb.Collection("counters").FindOneAndUpdate(ctx, bson.D{{"_id", changesCounter}},
+		bson.D{{"$inc", bson.D{{"seq", int64(len(found))}}}}, after).Decode(&counter)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	first := counter.Seq - int64(len(found)) + 1
+	changes := make([]interface{}, len(found))
+	for i, entry := range found {
+		var e events.Event
+		if err := json.Unmarshal([]byte(entry.Payload), &e); err != nil {
+			return err
+		}
+
+		changes[i] = changeEntry{Seq: first + int64(i), Tenant: e.Tenant, UserID: e.UserID, EventID: e.ID, Payload: entry.Payload, RecordedAt: now}
+	}
+
+	_, err = mongoDb.Collection("changes").InsertMany(ctx, changes, options.InsertMany().SetOrdered(false))
+	if err != nil && !onlyDuplicates(err) {
+		return err
+	}
+
+	_, err = mongoDb.Collection("counters").UpdateOne(ctx, bson.D{{"_id", changesCounter}},
+		bson.D{{"$max", bson.D{{"committed", counter.Seq}}}})
+	return err
+}
+
+// onlyDuplicates reports whether every failed write of the bulk insert is a duplicated event
+func onlyDuplicates(err error) bool {
+	bulk, ok := err.(mongo.BulkWriteException)
+	if !ok || bulk.WriteConcernError != nil {
+		return false
+	}
+
+	for _, e := range bulk.WriteErrors {
+		if !mongo.IsDuplicateKeyError(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ListChanges fetchs the published events of the details of the tenant recorded after the given sequence
+func (r *UserDetailsRepository) ListChanges(ctx context.Context, after int64, limit int) ([]events.Change, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	return r.findChanges(ctx, bson.D{{"tenant", t}}, after, limit)
+}
+
+// ListUserChanges fetchs the published events of the details of one user recorded after the given sequence
+func (r *UserDetailsRepository) ListUserChanges(ctx context.Context, UserID int, after int64, limit int) ([]events.Change, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	return r.findChanges(ctx, bson.D{{"tenant", t}, {"user_id", UserID}}, after, limit)
+}
+
+// LastChangePosition returns the committed sequence of the changes, zero when nothing was recorded,
+// the counter is shared by the tenants so it is a valid starting point for any of them
+func (r *UserDetailsRepository) LastChangePosition(ctx context.Context) (int64, error) {
+	committed, err := r.committed(ctx)
+	if err != nil {
+		return 0, errors.NewInternalError()
+	}
+
+	return committed, nil
+}
+
+// committed returns the sequence up to which every change is recorded
+func (r *UserDetailsRepository) committed(ctx context.Context) (int64, error) {
+	var counter struct {
+		Committed int64 `bson:"committed"`
+	}
+
+	err := r.db.Collection("counters").FindOne(ctx, bson.D{{"_id", changesCounter}}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+
+	return counter.Committed, err
+}
+
+// findChanges fetchs the committed changes which match the filter recorded after the given sequence
+func (r *UserDetailsRepository) findChanges(ctx context.Context, filter bson.D, after int64, limit int) ([]events.Change, error) {
+	collection := r.db.Collection("changes")
+
+	committed, err := r.committed(ctx)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	filter = append(filter, bson.E{"seq", bson.D{{"$gt", after}, {"$lte", committed}}})
+
+	opts := options.Find().SetSort(bson.D{{"seq", 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	var entries []changeEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	res := make([]events.Change, len(entries))
+	for i, entry := range entries {
+		var e events.Event
+		if err := json.Unmarshal([]byte(entry.Payload), &e); err != nil {
+			return nil, errors.NewInternalError()
+		}
+
+		res[i] = events.Change{Event: e, Cursor: events.EncodeCursor(entry.Seq)}
+	}
+
+	return res, nil
+}
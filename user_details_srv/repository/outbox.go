@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The MongoDB server runs standalone, which has no multi-document transactions, so the outbox of the
+// details is an array within the document of each user: the event is pushed by the same update which
+// changes the details and single document updates are atomic
+
+// outboxEntry stores one pending event, the event is kept as JSON so its data survives the round trip
+type outboxEntry struct {
+	ID      string `bson:"id"`
+	Payload string `bson:"payload"`
+}
+
+// withEvent appends the event to the outbox of the document changed by the update
+func withEvent(update bson.D, e events.Event) (bson.D, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := outboxEntry{ID: e.ID, Payload: string(payload)}
+	return append(update, bson.E{"$push", bson.D{{"outbox", entry}}}), nil
+}
+
+// detailsEventData returns the fields of the details sent within the DetailsChanged events
+func detailsEventData(d entities.UserDetails) map[string]interface{} {
+	data := map[string]interface{}{
+		"country":         d.Country,
+		"city":            d.City,
+		"mobile_number":   d.MobileNumber,
+		"mobile_verified": d.MobileVerified,
+		"married":         d.Married,
+		"height":          d.Height,
+		"weight":          d.Weight,
+		"active":          d.Active,
+	}
+
+	if len(d.Attributes) > 0 {
+		data["attributes"] = d.Attributes
+	}
+
+	return data
+}
+
+// Outbox implements the events.Outbox interface over the outbox arrays of the information collection,
+// the owner names its relay within the writer lease of the changes
+type Outbox struct {
+	db    *mongo.Database
+	owner string
+}
+
+// NewOutbox returns an Outbox pointer type
+func NewOutbox(mongoDb *mongo.Database) *Outbox {
+	return &Outbox{
+		db:    mongoDb,
+		owner: primitive.NewObjectID().Hex(),
+	}
+}
+
+// Pending fetchs the events which were not published yet, of every tenant, the events of one user keep their order
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]events.Event, error) {
+	collection := o.db.Collection("information")
+
+	opts := options.Find().SetProjection(bson.D{{"outbox", 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, bson.D{{"outbox.0", bson.D{{"$exists", true}}}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		Outbox []outboxEntry `bson:"outbox"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var res []events.Event
+	for _, d := range docs {
+		for _, entry := range d.Outbox {
+			var e events.Event
+			if err := json.Unmarshal([]byte(entry.Payload), &e); err != nil {
+				return nil, err
+			}
+			res = append(res, e)
+		}
+	}
+
+	return res, nil
+}
+
+// MarkPublished records the events within the changes collection and removes them from the outbox of their documents
+func (o *Outbox) MarkPublished(ctx context.Context, ids []string) error {
+	collection := o.db.Collection("information")
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := o.record(ctx, ids); err != nil {
+		return err
+	}
+
+	filter := bson.D{{"outbox.id", bson.D{{"$in", ids}}}}
+	update := bson.D{{"$pull", bson.D{{"outbox", bson.D{{"id", bson.D{{"$in", ids}}}}}}}}
+
+	_, err := collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// record copies the events to the changes collection while holding the writer lease, the changes are given up
+// before the lease expires so no other relay records changes at the same time
+func (o *Outbox) record(ctx context.Context, ids []string) error {
+	if err := acquireLease(ctx, o.db, o.owner); err != nil {
+		return err
+	}
+	defer releaseLease(context.Background(), o.db, o.owner)
+
+	ctx, cancel := context.WithTimeout(ctx, leaseTTL/2)
+	defer cancel()
+
+	return recordChanges(ctx, o.db, ids)
+}
+
+// Prune removes the changes recorded before the given time, the outbox arrays only hold the pending events
+func (o *Outbox) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := o.db.Collection("changes").DeleteMany(ctx, bson.D{{"recorded_at", bson.D{{"$lt", before}}}})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
@@ -5,17 +5,39 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/helpers"
+	"github.com/mauricioww/user_microsrv/tenant"
 	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // UserDetailsRepositorier describes the methods used to do DB operations
 type UserDetailsRepositorier interface {
-	SetUserDetails(ctx context.Context, info entities.UserDetails) (bool, error)
+	SetUserDetails(ctx context.Context, info entities.UserDetails, revision int64) (bool, error)
 	GetUserDetails(ctx context.Context, UserID int) (entities.UserDetails, error)
+	BatchGetUserDetails(ctx context.Context, UserIDs []int) ([]entities.UserDetails, error)
 	DeleteUserDetails(ctx context.Context, UserID int) (bool, error)
+	SetAttributeDefinition(ctx context.Context, definition entities.AttributeDefinition) (bool, error)
+	GetAttributeSchema(ctx context.Context) ([]entities.AttributeDefinition, error)
+	DeleteAttributeDefinition(ctx context.Context, name string) (bool, error)
+	AddAddress(ctx context.Context, address entities.Address) (entities.Address, error)
+	ListAddresses(ctx context.Context, UserID int) ([]entities.Address, error)
+	GetAddress(ctx context.Context, UserID int, addressID string) (entities.Address, error)
+	UpdateAddress(ctx context.Context, address entities.Address) (entities.Address, error)
+	DeleteAddress(ctx context.Context, UserID int, addressID string) (bool, error)
+	SavePhoneVerification(ctx context.Context, verification entities.PhoneVerification) (bool, error)
+	GetPhoneVerification(ctx context.Context, UserID int) (entities.PhoneVerification, error)
+	CountCodeAttempt(ctx context.Context, UserID int, maxAttempts int) (entities.PhoneVerification, error)
+	DeletePhoneVerification(ctx context.Context, UserID int) (bool, error)
+	MarkPhoneVerified(ctx context.Context, UserID int, number string) (bool, error)
+	SetAvatar(ctx context.Context, UserID int, avatar string) (bool, error)
+	ListChanges(ctx context.Context, after int64, limit int) ([]events.Change, error)
+	ListUserChanges(ctx context.Context, UserID int, after int64, limit int) ([]events.Change, error)
+	LastChangePosition(ctx context.Context) (int64, error)
 }
 
 // UserDetailsRepository implements the UserDetailsRepositorier interface
@@ -32,16 +54,54 @@ func NewUserDetailsRepository(mongoDb *mongo.Database, l log.Logger) *UserDetail
 	}
 }
 
-// SetUserDetails does the DB operation to insert or update information for a specific user
-func (r *UserDetailsRepository) SetUserDetails(ctx context.Context, details entities.UserDetails) (bool, error) {
+// SetUserDetails does the DB operation to insert or update information for a specific user,
+// the DetailsChanged event is written to the outbox by the same update. Unless the revision is AnyRevision the
+// details are only replaced while they are still at the revision, the inactive details are at the revision 0
+func (r *UserDetailsRepository) SetUserDetails(ctx context.Context, details entities.UserDetails, revision int64) (bool, error) {
 	collection := r.db.Collection("information")
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	details.TenantID = t
 	details.Active = true
+	filter := helpers.TenantFilter(ctx, details.UserID)
 	var err error
 
 	if helpers.NoExists(ctx, collection, details.UserID) {
-		_, err = collection.InsertOne(ctx, helpers.BuildInsertBson(details))
+		if revision > 0 {
+			return false, errors.NewDetailsModifiedError()
+		}
+		if revision == 0 {
+			filter = append(filter, bson.E{"active", bson.D{{"$ne", true}}})
+		}
+
+		// an inactive document is reset as if it was inserted again but its pending events are kept
+		update := append(helpers.BuildUpdateBson(details), bson.E{"$unset", bson.D{{"avatar", ""}}}, incRevision)
+		if update, err = withEvent(update, events.New(events.DetailsChanged, t, details.UserID, detailsEventData(details))); err == nil {
+			_, err = collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+			if mongo.IsDuplicateKeyError(err) {
+				// the details were activated by another write
+				return false, errors.NewDetailsModifiedError()
+			}
+		}
 	} else {
-		_, err = collection.UpdateByID(ctx, details.UserID, helpers.BuildUpdateBson(details))
+		var current entities.UserDetails
+		if err = collection.FindOne(ctx, filter).Decode(&current); err == nil {
+			details.MobileVerified = current.MobileVerified && current.MobileNumber == details.MobileNumber
+			if revision != entities.AnyRevision {
+				filter = append(filter, revisionFilter(revision))
+			}
+
+			var update bson.D
+			var res *mongo.UpdateResult
+			if update, err = withEvent(append(helpers.BuildUpdateBson(details), incRevision), events.New(events.DetailsChanged, t, details.UserID, detailsEventData(details))); err == nil {
+				if res, err = collection.UpdateOne(ctx, filter, update); err == nil && res.MatchedCount == 0 {
+					return false, errors.NewDetailsModifiedError()
+				}
+			}
+		}
 	}
 
 	if err != nil {
@@ -51,38 +111,485 @@ func (r *UserDetailsRepository) SetUserDetails(ctx context.Context, details enti
 	return true, nil
 }
 
+// incRevision counts one more write of the details within their update
+var incRevision = bson.E{"$inc", bson.D{{"revision", 1}}}
+
+// revisionFilter matches the details at the revision, the documents written before the revisions were counted have
+// none so they are at the revision 0
+func revisionFilter(revision int64) bson.E {
+	if revision == 0 {
+		return bson.E{"revision", bson.D{{"$in", bson.A{0, nil}}}}
+	}
+	return bson.E{"revision", revision}
+}
+
 // GetUserDetails fetchs the information within the DB about a specific user
 func (r *UserDetailsRepository) GetUserDetails(ctx context.Context, UserID int) (entities.UserDetails, error) {
 	collection := r.db.Collection("information")
 	var res entities.UserDetails
 
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return res, errors.NewMissingTenantError()
+	}
+
 	if helpers.NoExists(ctx, collection, UserID) {
 		return res, errors.NewUserNotFoundError()
 	}
 
-	if err := collection.FindOne(ctx, bson.D{{"_id", UserID}}).Decode(&res); err != nil {
+	if err := collection.FindOne(ctx, helpers.TenantFilter(ctx, UserID)).Decode(&res); err != nil {
 		return res, errors.NewInternalError()
 	}
 
 	return res, nil
 }
 
-// DeleteUserDetails does a soft delete operation over a specific user
+// BatchGetUserDetails fetchs the active details of the given users in one query, the missing users are left out
+func (r *UserDetailsRepository) BatchGetUserDetails(ctx context.Context, UserIDs []int) ([]entities.UserDetails, error) {
+	collection := r.db.Collection("information")
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	filter := bson.D{
+		{"_id", bson.D{{"$in", UserIDs}}},
+		{"tenant_id", t},
+		{"active", true},
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	var res []entities.UserDetails
+	if err := cursor.All(ctx, &res); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return res, nil
+}
+
+// DeleteUserDetails does a soft delete operation over a specific user, the DetailsChanged event is written to the outbox by the same update
 func (r *UserDetailsRepository) DeleteUserDetails(ctx context.Context, UserID int) (bool, error) {
 	collection := r.db.Collection("information")
 	var data entities.UserDetails
 
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
 	if helpers.NoExists(ctx, collection, UserID) {
 		return false, errors.NewUserNotFoundError()
 	}
 
-	if err := collection.FindOne(ctx, bson.D{{"_id", UserID}}).Decode(&data); err != nil {
+	if err := collection.FindOne(ctx, helpers.TenantFilter(ctx, UserID)).Decode(&data); err != nil {
 		return false, errors.NewInternalError()
 	}
 
 	data.Active = false
 
-	if _, err := collection.UpdateByID(ctx, UserID, helpers.BuildUpdateBson(data)); err != nil {
+	update, err := withEvent(append(helpers.BuildUpdateBson(data), incRevision), events.New(events.DetailsChanged, data.TenantID, UserID, map[string]interface{}{"active": false}))
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if _, err := collection.UpdateOne(ctx, helpers.TenantFilter(ctx, UserID), update); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	return true, nil
+}
+
+// SetAttributeDefinition inserts or replaces a custom attribute definition within the schema registry
+func (r *UserDetailsRepository) SetAttributeDefinition(ctx context.Context, definition entities.AttributeDefinition) (bool, error) {
+	collection := r.db.Collection("attributes_schema")
+	opts := options.Replace().SetUpsert(true)
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	definition.TenantID = t
+	if _, err := collection.ReplaceOne(ctx, bson.D{{"tenant_id", t}, {"name", definition.Name}}, definition, opts); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	return true, nil
+}
+
+// GetAttributeSchema fetchs every custom attribute definition within the schema registry
+func (r *UserDetailsRepository) GetAttributeSchema(ctx context.Context) ([]entities.AttributeDefinition, error) {
+	collection := r.db.Collection("attributes_schema")
+	schema := []entities.AttributeDefinition{}
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	cursor, err := collection.Find(ctx, bson.D{{"tenant_id", t}})
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	if err := cursor.All(ctx, &schema); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return schema, nil
+}
+
+// DeleteAttributeDefinition removes a custom attribute definition from the schema registry
+func (r *UserDetailsRepository) DeleteAttributeDefinition(ctx context.Context, name string) (bool, error) {
+	collection := r.db.Collection("attributes_schema")
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	res, err := collection.DeleteOne(ctx, bson.D{{"tenant_id", t}, {"name", name}})
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if res.DeletedCount == 0 {
+		return false, errors.NewInvalidAttributeError(name, "attribute is not defined")
+	}
+
+	return true, nil
+}
+
+// AddAddress stores a new address for the user, the first address or the flagged one becomes the primary address
+func (r *UserDetailsRepository) AddAddress(ctx context.Context, address entities.Address) (entities.Address, error) {
+	collection := r.db.Collection("addresses")
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return entities.Address{}, errors.NewMissingTenantError()
+	}
+
+	if helpers.NoExists(ctx, r.db.Collection("information"), address.UserID) {
+		return entities.Address{}, errors.NewUserNotFoundError()
+	}
+
+	address.ID = primitive.NewObjectID().Hex()
+	address.TenantID = t
+	primary := address.Primary
+	address.Primary = false
+
+	if _, err := collection.InsertOne(ctx, address); err != nil {
+		return entities.Address{}, errors.NewInternalError()
+	}
+
+	if err := r.addressChanged(ctx, address.UserID, address.ID, "added", primary); err != nil {
+		return entities.Address{}, err
+	}
+
+	return r.GetAddress(ctx, address.UserID, address.ID)
+}
+
+// ListAddresses fetchs every address of the user ordered by creation
+func (r *UserDetailsRepository) ListAddresses(ctx context.Context, UserID int) ([]entities.Address, error) {
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	if helpers.NoExists(ctx, r.db.Collection("information"), UserID) {
+		return nil, errors.NewUserNotFoundError()
+	}
+
+	return r.addresses(ctx, UserID)
+}
+
+// GetAddress fetchs a specific address of the user
+func (r *UserDetailsRepository) GetAddress(ctx context.Context, UserID int, addressID string) (entities.Address, error) {
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return entities.Address{}, errors.NewMissingTenantError()
+	}
+
+	addresses, err := r.addresses(ctx, UserID)
+	if err != nil {
+		return entities.Address{}, err
+	}
+
+	for _, a := range addresses {
+		if a.ID == addressID {
+			return a, nil
+		}
+	}
+
+	return entities.Address{}, errors.NewAddressNotFoundError()
+}
+
+// UpdateAddress replaces an address of the user, the primary flag can only be moved to another address
+func (r *UserDetailsRepository) UpdateAddress(ctx context.Context, address entities.Address) (entities.Address, error) {
+	collection := r.db.Collection("addresses")
+
+	current, err := r.GetAddress(ctx, address.UserID, address.ID)
+	if err != nil {
+		return entities.Address{}, err
+	}
+
+	address.TenantID = current.TenantID
+	primary := address.Primary || current.Primary
+	address.Primary = false
+
+	if _, err := collection.ReplaceOne(ctx, bson.D{{"_id", address.ID}, {"tenant_id", address.TenantID}}, address); err != nil {
+		return entities.Address{}, errors.NewInternalError()
+	}
+
+	if err := r.addressChanged(ctx, address.UserID, address.ID, "updated", primary); err != nil {
+		return entities.Address{}, err
+	}
+
+	address.Primary = primary
+	return address, nil
+}
+
+// DeleteAddress removes an address of the user, the oldest remaining address becomes primary if needed
+func (r *UserDetailsRepository) DeleteAddress(ctx context.Context, UserID int, addressID string) (bool, error) {
+	collection := r.db.Collection("addresses")
+
+	current, err := r.GetAddress(ctx, UserID, addressID)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := collection.DeleteOne(ctx, bson.D{{"_id", current.ID}, {"tenant_id", current.TenantID}}); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if err := r.addressChanged(ctx, UserID, current.ID, "deleted", false); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// The addresses live in their own collection but the primary one is referenced by the details of the user, the
+// reference is set by the same update which writes the event of the address to the outbox so there is only ever
+// one primary address and every change of the addresses is published like the changes of the details
+
+// addressChanged records the change of the address within the details of the user, the address becomes the primary
+// one when primary is set
+func (r *UserDetailsRepository) addressChanged(ctx context.Context, UserID int, addressID string, change string, primary bool) error {
+	t, _ := tenant.FromContext(ctx)
+	data := map[string]interface{}{"address_id": addressID, "address": change}
+
+	var update bson.D
+	if primary {
+		update = bson.D{{"$set", bson.D{{"primary_address", addressID}}}}
+		data["primary_address"] = addressID
+	}
+
+	update, err := withEvent(update, events.New(events.DetailsChanged, t, UserID, data))
+	if err != nil {
+		return errors.NewInternalError()
+	}
+
+	if _, err := r.db.Collection("information").UpdateOne(ctx, helpers.TenantFilter(ctx, UserID), update); err != nil {
+		return errors.NewInternalError()
+	}
+
+	if primary {
+		// the addresses flagged before the reference existed are not primary anymore
+		filter := bson.D{{"tenant_id", t}, {"user_id", UserID}, {"primary", true}}
+		if _, err := r.db.Collection("addresses").UpdateMany(ctx, filter, bson.D{{"$set", bson.D{{"primary", false}}}}); err != nil {
+			return errors.NewInternalError()
+		}
+	}
+
+	return nil
+}
+
+// addresses fetchs every address of the user ordered by creation along with the primary flag: the address referenced
+// by the details, else the one flagged before the reference existed, else the oldest one
+func (r *UserDetailsRepository) addresses(ctx context.Context, UserID int) ([]entities.Address, error) {
+	t, _ := tenant.FromContext(ctx)
+	addresses := []entities.Address{}
+
+	opts := options.Find().SetSort(bson.D{{"_id", 1}})
+	cursor, err := r.db.Collection("addresses").Find(ctx, bson.D{{"tenant_id", t}, {"user_id", UserID}}, opts)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	if err := cursor.All(ctx, &addresses); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	if len(addresses) == 0 {
+		return addresses, nil
+	}
+
+	var reference struct {
+		PrimaryAddress string `bson:"primary_address"`
+	}
+	projection := options.FindOne().SetProjection(bson.D{{"primary_address", 1}})
+	err = r.db.Collection("information").FindOne(ctx, helpers.TenantFilter(ctx, UserID), projection).Decode(&reference)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, errors.NewInternalError()
+	}
+
+	primary := addresses[0].ID
+	for _, a := range addresses {
+		if a.Primary {
+			primary = a.ID
+		}
+	}
+	for _, a := range addresses {
+		if a.ID == reference.PrimaryAddress {
+			primary = a.ID
+		}
+	}
+
+	for i := range addresses {
+		addresses[i].Primary = addresses[i].ID == primary
+	}
+
+	return addresses, nil
+}
+
+// SavePhoneVerification inserts or replaces the pending phone verification of the user
+func (r *UserDetailsRepository) SavePhoneVerification(ctx context.Context, verification entities.PhoneVerification) (bool, error) {
+	collection := r.db.Collection("phone_verifications")
+	opts := options.Replace().SetUpsert(true)
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	verification.TenantID = t
+	filter := bson.D{{"tenant_id", t}, {"user_id", verification.UserID}}
+	if _, err := collection.ReplaceOne(ctx, filter, verification, opts); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	return true, nil
+}
+
+// GetPhoneVerification fetchs the pending phone verification of the user
+func (r *UserDetailsRepository) GetPhoneVerification(ctx context.Context, UserID int) (entities.PhoneVerification, error) {
+	collection := r.db.Collection("phone_verifications")
+	var res entities.PhoneVerification
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return res, errors.NewMissingTenantError()
+	}
+
+	err := collection.FindOne(ctx, bson.D{{"tenant_id", t}, {"user_id", UserID}}).Decode(&res)
+
+	if err == mongo.ErrNoDocuments {
+		return res, errors.NewInvalidCodeError()
+	}
+
+	if err != nil {
+		return res, errors.NewInternalError()
+	}
+
+	return res, nil
+}
+
+// CountCodeAttempt counts one more attempt of the pending phone verification of the user and returns it, the attempt
+// is only counted while fewer than maxAttempts were made so the concurrent guesses can not go over them, the missing
+// and the exhausted verifications are an InvalidCodeError
+func (r *UserDetailsRepository) CountCodeAttempt(ctx context.Context, UserID int, maxAttempts int) (entities.PhoneVerification, error) {
+	collection := r.db.Collection("phone_verifications")
+	var res entities.PhoneVerification
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return res, errors.NewMissingTenantError()
+	}
+
+	filter := bson.D{{"tenant_id", t}, {"user_id", UserID}, {"attempts", bson.D{{"$lt", maxAttempts}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, filter, bson.D{{"$inc", bson.D{{"attempts", 1}}}}, opts).Decode(&res)
+
+	if err == mongo.ErrNoDocuments {
+		return res, errors.NewInvalidCodeError()
+	}
+
+	if err != nil {
+		return res, errors.NewInternalError()
+	}
+
+	return res, nil
+}
+
+// DeletePhoneVerification removes the pending phone verification of the user
+func (r *UserDetailsRepository) DeletePhoneVerification(ctx context.Context, UserID int) (bool, error) {
+	collection := r.db.Collection("phone_verifications")
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	if _, err := collection.DeleteOne(ctx, bson.D{{"tenant_id", t}, {"user_id", UserID}}); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	return true, nil
+}
+
+// MarkPhoneVerified flags the mobile number of the user as verified if it was not changed meanwhile
+func (r *UserDetailsRepository) MarkPhoneVerified(ctx context.Context, UserID int, number string) (bool, error) {
+	collection := r.db.Collection("information")
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	update, err := withEvent(bson.D{{"$set", bson.D{{"mobile_verified", true}}}, incRevision}, events.New(events.DetailsChanged, t, UserID, map[string]interface{}{"mobile_verified": true}))
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	filter := append(helpers.TenantFilter(ctx, UserID), bson.E{"mobile_number", number})
+	res, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if res.MatchedCount == 0 {
+		return false, errors.NewInvalidCodeError()
+	}
+
+	return true, nil
+}
+
+// SetAvatar stores the reference to the avatar of the user, an empty reference removes it
+func (r *UserDetailsRepository) SetAvatar(ctx context.Context, UserID int, avatar string) (bool, error) {
+	collection := r.db.Collection("information")
+
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	if helpers.NoExists(ctx, collection, UserID) {
+		return false, errors.NewUserNotFoundError()
+	}
+
+	update := bson.D{{"$set", bson.D{{"avatar", avatar}}}, incRevision}
+	if avatar == "" {
+		update = bson.D{{"$unset", bson.D{{"avatar", ""}}}, incRevision}
+	}
+
+	update, err := withEvent(update, events.New(events.DetailsChanged, t, UserID, map[string]interface{}{"avatar": avatar}))
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if _, err := collection.UpdateOne(ctx, helpers.TenantFilter(ctx, UserID), update); err != nil {
 		return false, errors.NewInternalError()
 	}
 
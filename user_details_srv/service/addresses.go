@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+)
+
+const (
+	maxAddressLines = 3
+	maxFieldLength  = 100
+)
+
+// countryCodes stores the ISO 3166-1 alpha-2 officially assigned codes
+var countryCodes = toSet(`AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ
+	BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES
+	ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM IN IO
+	IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK
+	ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR
+	PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK
+	TL TM TN TO TR TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW`)
+
+func toSet(list string) map[string]bool {
+	set := map[string]bool{}
+	for _, e := range strings.Fields(list) {
+		set[e] = true
+	}
+	return set
+}
+
+// normalizeAddress trims the fields of the address, validates them and returns the normalized address
+func normalizeAddress(a entities.Address) (entities.Address, error) {
+	lines := make([]string, 0, len(a.Lines))
+	for _, l := range a.Lines {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	a.Lines = lines
+	a.Locality = strings.TrimSpace(a.Locality)
+	a.Region = strings.TrimSpace(a.Region)
+	a.PostalCode = strings.ToUpper(strings.TrimSpace(a.PostalCode))
+	a.CountryCode = strings.ToUpper(strings.TrimSpace(a.CountryCode))
+	a.Label = strings.ToLower(strings.TrimSpace(a.Label))
+
+	if len(a.Lines) == 0 {
+		return a, errors.NewInvalidAddressError("lines", "at least one line is required")
+	}
+
+	if len(a.Lines) > maxAddressLines {
+		return a, errors.NewInvalidAddressError("lines", "too many lines")
+	}
+
+	for i, l := range a.Lines {
+		if utf8.RuneCountInString(l) > maxFieldLength {
+			return a, errors.NewInvalidAddressError(fmt.Sprintf("lines[%v]", i), "value is too long")
+		}
+	}
+
+	fields := []struct{ name, value string }{
+		{"locality", a.Locality},
+		{"region", a.Region},
+		{"postal_code", a.PostalCode},
+		{"label", a.Label},
+	}
+	for _, f := range fields {
+		if utf8.RuneCountInString(f.value) > maxFieldLength {
+			return a, errors.NewInvalidAddressError(f.name, "value is too long")
+		}
+	}
+
+	if a.Locality == "" {
+		return a, errors.NewInvalidAddressError("locality", "field is required")
+	}
+
+	if !countryCodes[a.CountryCode] {
+		return a, errors.NewInvalidAddressError("country_code", "not an ISO 3166-1 alpha-2 code")
+	}
+
+	return a, nil
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+)
+
+// maxPatterns bounds the compiled patterns kept in memory, the tenants define as many attributes as they want so
+// the least recently used patterns are compiled again when they are needed
+const maxPatterns = 1000
+
+// patterns caches the compiled patterns of the definitions, a pattern is compiled when its definition is saved or,
+// for the definitions stored by another instance, the first time a value is checked against it
+var patterns = struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}{entries: map[string]*list.Element{}, order: list.New()}
+
+type compiled struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patterns.mu.Lock()
+	if e, ok := patterns.entries[pattern]; ok {
+		patterns.order.MoveToFront(e)
+		patterns.mu.Unlock()
+		return e.Value.(*compiled).re, nil
+	}
+	patterns.mu.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns.mu.Lock()
+	defer patterns.mu.Unlock()
+	if _, ok := patterns.entries[pattern]; !ok {
+		patterns.entries[pattern] = patterns.order.PushFront(&compiled{pattern: pattern, re: re})
+		if patterns.order.Len() > maxPatterns {
+			oldest := patterns.order.Back()
+			patterns.order.Remove(oldest)
+			delete(patterns.entries, oldest.Value.(*compiled).pattern)
+		}
+	}
+	return re, nil
+}
+
+// validateDefinition checks that a custom attribute definition is consistent before storing it
+func validateDefinition(d entities.AttributeDefinition) error {
+	if d.Name == "" {
+		return errors.NewInvalidAttributeError(d.Name, "missing name")
+	}
+
+	switch d.Type {
+	case entities.StringAttribute, entities.IntAttribute, entities.FloatAttribute, entities.BoolAttribute:
+	default:
+		return errors.NewInvalidAttributeError(d.Name, fmt.Sprintf("unsupported type '%v'", d.Type))
+	}
+
+	c := d.Constraints
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return errors.NewInvalidAttributeError(d.Name, "min is greater than max")
+	}
+
+	if c.MaxLength > 0 && c.MinLength > c.MaxLength {
+		return errors.NewInvalidAttributeError(d.Name, "min_length is greater than max_length")
+	}
+
+	if c.Pattern != "" {
+		if _, err := compilePattern(c.Pattern); err != nil {
+			return errors.NewInvalidAttributeError(d.Name, "invalid pattern")
+		}
+	}
+
+	return nil
+}
+
+// validateAttributes checks the given values against the schema and returns them normalized to their declared type
+func validateAttributes(schema []entities.AttributeDefinition, values map[string]interface{}) (map[string]interface{}, error) {
+	definitions := make(map[string]entities.AttributeDefinition, len(schema))
+	for _, d := range schema {
+		definitions[d.Name] = d
+	}
+
+	for name := range values {
+		if _, ok := definitions[name]; !ok {
+			return nil, errors.NewInvalidAttributeError(name, "attribute is not defined")
+		}
+	}
+
+	res := make(map[string]interface{}, len(values))
+	for _, d := range schema {
+		v, ok := values[d.Name]
+		if !ok {
+			if d.Required {
+				return nil, errors.NewInvalidAttributeError(d.Name, "attribute is required")
+			}
+			continue
+		}
+
+		value, err := validateValue(d, v)
+		if err != nil {
+			return nil, err
+		}
+		res[d.Name] = value
+	}
+
+	return res, nil
+}
+
+func validateValue(d entities.AttributeDefinition, v interface{}) (interface{}, error) {
+	c := d.Constraints
+
+	switch d.Type {
+	case entities.StringAttribute:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.NewInvalidAttributeError(d.Name, "expected a string value")
+		}
+		if n := utf8.RuneCountInString(s); n < c.MinLength || (c.MaxLength > 0 && n > c.MaxLength) {
+			return nil, errors.NewInvalidAttributeError(d.Name, "length out of range")
+		}
+		if c.Pattern != "" {
+			// a pattern stored before the definitions were validated is reported instead of trusted
+			re, err := compilePattern(c.Pattern)
+			if err != nil {
+				return nil, errors.NewInvalidAttributeError(d.Name, "invalid pattern")
+			}
+			if !re.MatchString(s) {
+				return nil, errors.NewInvalidAttributeError(d.Name, "value does not match pattern")
+			}
+		}
+		if len(c.Enum) > 0 && !contains(c.Enum, s) {
+			return nil, errors.NewInvalidAttributeError(d.Name, "value is not allowed")
+		}
+		return s, nil
+	case entities.IntAttribute:
+		i, ok := v.(int64)
+		if !ok {
+			return nil, errors.NewInvalidAttributeError(d.Name, "expected an int value")
+		}
+		if err := checkRange(d.Name, c, float64(i)); err != nil {
+			return nil, err
+		}
+		return i, nil
+	case entities.FloatAttribute:
+		var f float64
+		switch n := v.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		default:
+			return nil, errors.NewInvalidAttributeError(d.Name, "expected a float value")
+		}
+		if err := checkRange(d.Name, c, f); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case entities.BoolAttribute:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.NewInvalidAttributeError(d.Name, "expected a bool value")
+		}
+		return b, nil
+	default:
+		return nil, errors.NewInvalidAttributeError(d.Name, fmt.Sprintf("unsupported type '%v'", d.Type))
+	}
+}
+
+func checkRange(name string, c entities.AttributeConstraints, n float64) error {
+	if (c.Min != nil && n < *c.Min) || (c.Max != nil && n > *c.Max) {
+		return errors.NewInvalidAttributeError(name, "value out of range")
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
+)
+
+const (
+	defaultChangesPageSize = 100
+	maxChangesPageSize     = 1000
+
+	// watchInterval is how often a watch looks for new changes of the details
+	watchInterval = time.Second
+	watchPageSize = 100
+)
+
+// ListChanges returns one page of the changes of the details after the cursor and the cursor to request the next page,
+// the same cursor is returned when there are no new changes so it can be polled
+func (g *GrpcUserDetailsService) ListChanges(ctx context.Context, cursor string, pageSize int) ([]events.Change, string, error) {
+	logger := log.With(g.logger, "method", "list_changes")
+
+	after, err := events.DecodeCursor(cursor)
+	if err != nil {
+		e := errors.NewInvalidCursorError()
+		level.Error(logger).Log("validation: ", e)
+		return nil, "", e
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultChangesPageSize
+	}
+
+	if pageSize > maxChangesPageSize {
+		pageSize = maxChangesPageSize
+	}
+
+	res, err := g.repository.ListChanges(ctx, after, pageSize)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, "", err
+	}
+
+	next := cursor
+	if len(res) > 0 {
+		next = res[len(res)-1].Cursor
+	}
+
+	logger.Log("action", "success")
+	return res, next, nil
+}
+
+// WatchUserDetails sends the changes of the details of the user after the cursor until the context is done, the empty
+// cursor only sends the changes made from now on. The details may not exist yet so the user is not checked
+func (g *GrpcUserDetailsService) WatchUserDetails(ctx context.Context, UserID int, cursor string, send func(events.Change) error) error {
+	logger := log.With(g.logger, "method", "watch_user_details")
+
+	after, err := events.DecodeCursor(cursor)
+	if err != nil {
+		e := errors.NewInvalidCursorError()
+		level.Error(logger).Log("validation: ", e)
+		return e
+	}
+
+	if cursor == "" {
+		if after, err = g.repository.LastChangePosition(ctx); err != nil {
+			level.Error(logger).Log("ERROR", err)
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		changes, err := g.repository.ListUserChanges(ctx, UserID, after, watchPageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			level.Error(logger).Log("ERROR", err)
+			return err
+		}
+
+		for _, c := range changes {
+			if err := send(c); err != nil {
+				level.Error(logger).Log("ERROR", err)
+				return err
+			}
+			after, _ = events.DecodeCursor(c.Cursor)
+		}
+
+		if len(changes) == watchPageSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Log("action", "success")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
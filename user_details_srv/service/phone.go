@@ -0,0 +1,189 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/mauricioww/user_microsrv/errors"
+)
+
+const (
+	minE164Digits = 8
+	maxE164Digits = 15
+)
+
+// phoneRegion stores the dialing rules of a country
+type phoneRegion struct {
+	callingCode string
+	trunk       string
+	lengths     []int
+}
+
+// phoneRegions stores the dialing rules by ISO 3166-1 alpha-2 code, lengths are the
+// valid national significant number lengths
+var phoneRegions = map[string]phoneRegion{
+	"AR": {"54", "0", []int{10, 11}},
+	"AU": {"61", "0", []int{9}},
+	"BO": {"591", "0", []int{8}},
+	"BR": {"55", "0", []int{10, 11}},
+	"CA": {"1", "1", []int{10}},
+	"CL": {"56", "", []int{9}},
+	"CN": {"86", "0", []int{10, 11}},
+	"CO": {"57", "", []int{10}},
+	"CR": {"506", "", []int{8}},
+	"DE": {"49", "0", []int{7, 8, 9, 10, 11}},
+	"DO": {"1", "1", []int{10}},
+	"EC": {"593", "0", []int{8, 9}},
+	"ES": {"34", "", []int{9}},
+	"FR": {"33", "0", []int{9}},
+	"GB": {"44", "0", []int{9, 10}},
+	"GT": {"502", "", []int{8}},
+	"IN": {"91", "0", []int{10}},
+	"IT": {"39", "", []int{6, 7, 8, 9, 10, 11}},
+	"JP": {"81", "0", []int{9, 10}},
+	"MX": {"52", "", []int{10}},
+	"PA": {"507", "", []int{7, 8}},
+	"PE": {"51", "0", []int{8, 9}},
+	"PT": {"351", "", []int{9}},
+	"PY": {"595", "0", []int{9}},
+	"US": {"1", "1", []int{10}},
+	"UY": {"598", "0", []int{8}},
+	"VE": {"58", "0", []int{10}},
+}
+
+// countryNames maps the country names accepted within the details to their ISO 3166-1 alpha-2 code
+var countryNames = map[string]string{
+	"argentina":            "AR",
+	"australia":            "AU",
+	"bolivia":              "BO",
+	"brasil":               "BR",
+	"brazil":               "BR",
+	"canada":               "CA",
+	"chile":                "CL",
+	"china":                "CN",
+	"colombia":             "CO",
+	"costa rica":           "CR",
+	"alemania":             "DE",
+	"germany":              "DE",
+	"dominican republic":   "DO",
+	"republica dominicana": "DO",
+	"ecuador":              "EC",
+	"espana":               "ES",
+	"spain":                "ES",
+	"francia":              "FR",
+	"france":               "FR",
+	"reino unido":          "GB",
+	"united kingdom":       "GB",
+	"guatemala":            "GT",
+	"india":                "IN",
+	"italia":               "IT",
+	"italy":                "IT",
+	"japan":                "JP",
+	"japon":                "JP",
+	"mexico":               "MX",
+	"panama":               "PA",
+	"peru":                 "PE",
+	"portugal":             "PT",
+	"paraguay":             "PY",
+	"estados unidos":       "US",
+	"united states":        "US",
+	"usa":                  "US",
+	"uruguay":              "UY",
+	"venezuela":            "VE",
+}
+
+var accents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ñ", "n", "ã", "a", "ç", "c")
+
+// regionFromCountry resolves the country of the details, either a name or an ISO code, to its dialing rules
+func regionFromCountry(country string) (phoneRegion, bool) {
+	country = strings.TrimSpace(country)
+
+	if code := strings.ToUpper(country); len(code) == 2 {
+		r, ok := phoneRegions[code]
+		return r, ok
+	}
+
+	r, ok := phoneRegions[countryNames[accents.Replace(strings.ToLower(country))]]
+	return r, ok
+}
+
+// normalizePhoneNumber returns the number in E.164 format, national numbers are resolved using the country as hint
+func normalizePhoneNumber(number string, country string) (string, error) {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return "", nil
+	}
+
+	international := strings.HasPrefix(number, "+")
+	if international {
+		number = number[1:]
+	}
+
+	digits := make([]rune, 0, len(number))
+	for _, c := range number {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", errors.NewInvalidPhoneNumberError("mobile_number", "unexpected character")
+		}
+	}
+	number = string(digits)
+
+	if !international && strings.HasPrefix(number, "00") {
+		international = true
+		number = number[2:]
+	}
+
+	if international {
+		return normalizeInternational(number)
+	}
+
+	region, ok := regionFromCountry(country)
+	if !ok {
+		return "", errors.NewInvalidPhoneNumberError("mobile_number", "national number without a known country, use the +<country code> format")
+	}
+
+	if region.trunk != "" && !validLength(region, len(number)) {
+		number = strings.TrimPrefix(number, region.trunk)
+	}
+
+	if !validLength(region, len(number)) {
+		return "", errors.NewInvalidPhoneNumberError("mobile_number", "invalid length for the country")
+	}
+
+	return "+" + region.callingCode + number, nil
+}
+
+// normalizeInternational validates a number which already starts with the calling code
+func normalizeInternational(number string) (string, error) {
+	if len(number) < minE164Digits || len(number) > maxE164Digits || number[0] == '0' {
+		return "", errors.NewInvalidPhoneNumberError("mobile_number", "not a valid E.164 number")
+	}
+
+	known := false
+	for _, r := range phoneRegions {
+		if !strings.HasPrefix(number, r.callingCode) {
+			continue
+		}
+		known = true
+		if validLength(r, len(number)-len(r.callingCode)) {
+			return "+" + number, nil
+		}
+	}
+
+	if known {
+		return "", errors.NewInvalidPhoneNumberError("mobile_number", "invalid length for the country")
+	}
+
+	return "+" + number, nil
+}
+
+func validLength(r phoneRegion, n int) bool {
+	for _, l := range r.lengths {
+		if l == n {
+			return true
+		}
+	}
+	return false
+}
@@ -2,38 +2,95 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 	"github.com/mauricioww/user_microsrv/user_details_srv/repository"
+	"github.com/mauricioww/user_microsrv/user_details_srv/sms"
+)
+
+const (
+	codeDigits      = 6
+	codeTTL         = 10 * time.Minute
+	maxCodeAttempts = 5
+	attemptsWindow  = time.Hour
+	maxBatchSize    = 100
 )
 
 // GrpcUserDetailsServicer describe the business logic used to do validations and operations
 type GrpcUserDetailsServicer interface {
-	SetUserDetails(ctx context.Context, UserID int, country string, city string, number string, married bool, height float32, weigth float32) (bool, error)
+	SetUserDetails(ctx context.Context, UserID int, country string, city string, number string, married bool, height float32, weigth float32, attributes map[string]interface{}, revision int64) (bool, error)
 	GetUserDetails(ctx context.Context, UserID int) (entities.UserDetails, error)
+	BatchGetUserDetails(ctx context.Context, UserIDs []int) ([]entities.UserDetailsResult, error)
 	DeleteUserDetails(ctx context.Context, UserID int) (bool, error)
+	SetAttributeDefinition(ctx context.Context, definition entities.AttributeDefinition) (bool, error)
+	GetAttributeSchema(ctx context.Context) ([]entities.AttributeDefinition, error)
+	DeleteAttributeDefinition(ctx context.Context, name string) (bool, error)
+	AddAddress(ctx context.Context, UserID int, address entities.Address) (entities.Address, error)
+	ListAddresses(ctx context.Context, UserID int) ([]entities.Address, error)
+	GetAddress(ctx context.Context, UserID int, addressID string) (entities.Address, error)
+	UpdateAddress(ctx context.Context, UserID int, address entities.Address) (entities.Address, error)
+	DeleteAddress(ctx context.Context, UserID int, addressID string) (bool, error)
+	SendPhoneVerification(ctx context.Context, UserID int) (bool, error)
+	VerifyPhone(ctx context.Context, UserID int, code string) (bool, error)
+	SetAvatar(ctx context.Context, UserID int, avatar string) (bool, error)
+	ListChanges(ctx context.Context, cursor string, pageSize int) ([]events.Change, string, error)
+	WatchUserDetails(ctx context.Context, UserID int, cursor string, send func(events.Change) error) error
 }
 
 // GrpcUserDetailsService implements the GrpcUserDetailsServicer interface
 type GrpcUserDetailsService struct {
 	repository repository.UserDetailsRepositorier
+	sender     sms.Sender
+	codeKey    []byte
 	logger     log.Logger
 }
 
-// NewGrpcUserDetailsService returns a GrpcUserDetailsService pointer type
-func NewGrpcUserDetailsService(r repository.UserDetailsRepositorier, l log.Logger) *GrpcUserDetailsService {
+// NewGrpcUserDetailsService returns a GrpcUserDetailsService pointer type, codeKey is the secret the verification
+// codes are hashed with
+func NewGrpcUserDetailsService(r repository.UserDetailsRepositorier, s sms.Sender, codeKey []byte, l log.Logger) *GrpcUserDetailsService {
 	return &GrpcUserDetailsService{
 		repository: r,
+		sender:     s,
+		codeKey:    codeKey,
 		logger:     l,
 	}
 }
 
-// SetUserDetails receives data for user and send it to the repository
-func (g *GrpcUserDetailsService) SetUserDetails(ctx context.Context, UserID int, country string, city string, number string, married bool, height float32, weight float32) (bool, error) {
+// SetUserDetails normalizes the mobile number, validates the custom attributes against the schema and send the data to the repository,
+// the details are only replaced while they are at the revision unless it is entities.AnyRevision
+func (g *GrpcUserDetailsService) SetUserDetails(ctx context.Context, UserID int, country string, city string, number string, married bool, height float32, weight float32, attributes map[string]interface{}, revision int64) (bool, error) {
 	logger := log.With(g.logger, "method", "set_user_details")
 
+	number, err := normalizePhoneNumber(number, country)
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return false, err
+	}
+
+	schema, err := g.repository.GetAttributeSchema(ctx)
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	attributes, err = validateAttributes(schema, attributes)
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return false, err
+	}
+
 	information := entities.UserDetails{
 		UserID:       UserID,
 		Country:      country,
@@ -42,9 +99,10 @@ func (g *GrpcUserDetailsService) SetUserDetails(ctx context.Context, UserID int,
 		Married:      married,
 		Height:       height,
 		Weight:       weight,
+		Attributes:   attributes,
 	}
 
-	res, err := g.repository.SetUserDetails(ctx, information)
+	res, err := g.repository.SetUserDetails(ctx, information, revision)
 
 	if err != nil {
 		level.Error(logger).Log("ERROR", err)
@@ -69,6 +127,39 @@ func (g *GrpcUserDetailsService) GetUserDetails(ctx context.Context, UserID int)
 	return res, nil
 }
 
+// BatchGetUserDetails returns one result for each of the given ids, in the same order, the ids
+// without active details are marked as not found instead of failing the batch
+func (g *GrpcUserDetailsService) BatchGetUserDetails(ctx context.Context, UserIDs []int) ([]entities.UserDetailsResult, error) {
+	logger := log.With(g.logger, "method", "batch_get_user_details")
+
+	if len(UserIDs) == 0 || len(UserIDs) > maxBatchSize {
+		e := errors.NewInvalidBatchError()
+		level.Error(logger).Log("validation: ", e)
+		return nil, e
+	}
+
+	details, err := g.repository.BatchGetUserDetails(ctx, UserIDs)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, err
+	}
+
+	found := make(map[int]entities.UserDetails, len(details))
+	for _, d := range details {
+		found[d.UserID] = d
+	}
+
+	res := make([]entities.UserDetailsResult, len(UserIDs))
+	for i, id := range UserIDs {
+		d, ok := found[id]
+		res[i] = entities.UserDetailsResult{UserID: id, Found: ok, Details: d}
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
 // DeleteUserDetails receives one ID and send it to the repository
 func (g *GrpcUserDetailsService) DeleteUserDetails(ctx context.Context, UserID int) (bool, error) {
 	logger := log.With(g.logger, "method", "delete_user_details")
@@ -82,3 +173,264 @@ func (g *GrpcUserDetailsService) DeleteUserDetails(ctx context.Context, UserID i
 	logger.Log("action", "success")
 	return res, nil
 }
+
+// SetAttributeDefinition validates a custom attribute definition and send it to the repository
+func (g *GrpcUserDetailsService) SetAttributeDefinition(ctx context.Context, definition entities.AttributeDefinition) (bool, error) {
+	logger := log.With(g.logger, "method", "set_attribute_definition")
+
+	if err := validateDefinition(definition); err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return false, err
+	}
+
+	res, err := g.repository.SetAttributeDefinition(ctx, definition)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// GetAttributeSchema fetchs every custom attribute definition from the repository
+func (g *GrpcUserDetailsService) GetAttributeSchema(ctx context.Context) ([]entities.AttributeDefinition, error) {
+	logger := log.With(g.logger, "method", "get_attribute_schema")
+	res, err := g.repository.GetAttributeSchema(ctx)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// DeleteAttributeDefinition receives one attribute name and send it to the repository
+func (g *GrpcUserDetailsService) DeleteAttributeDefinition(ctx context.Context, name string) (bool, error) {
+	logger := log.With(g.logger, "method", "delete_attribute_definition")
+	res, err := g.repository.DeleteAttributeDefinition(ctx, name)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// AddAddress validates the new address and send it to the repository
+func (g *GrpcUserDetailsService) AddAddress(ctx context.Context, UserID int, address entities.Address) (entities.Address, error) {
+	logger := log.With(g.logger, "method", "add_address")
+
+	address, err := normalizeAddress(address)
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return entities.Address{}, err
+	}
+
+	address.UserID = UserID
+	res, err := g.repository.AddAddress(ctx, address)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return entities.Address{}, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// ListAddresses receives one ID and send it to the repository
+func (g *GrpcUserDetailsService) ListAddresses(ctx context.Context, UserID int) ([]entities.Address, error) {
+	logger := log.With(g.logger, "method", "list_addresses")
+	res, err := g.repository.ListAddresses(ctx, UserID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// GetAddress receives the user and address IDs and send them to the repository
+func (g *GrpcUserDetailsService) GetAddress(ctx context.Context, UserID int, addressID string) (entities.Address, error) {
+	logger := log.With(g.logger, "method", "get_address")
+	res, err := g.repository.GetAddress(ctx, UserID, addressID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return entities.Address{}, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// UpdateAddress validates the new data of the address and send it to the repository
+func (g *GrpcUserDetailsService) UpdateAddress(ctx context.Context, UserID int, address entities.Address) (entities.Address, error) {
+	logger := log.With(g.logger, "method", "update_address")
+
+	address, err := normalizeAddress(address)
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return entities.Address{}, err
+	}
+
+	address.UserID = UserID
+	res, err := g.repository.UpdateAddress(ctx, address)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return entities.Address{}, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// DeleteAddress receives the user and address IDs and send them to the repository
+func (g *GrpcUserDetailsService) DeleteAddress(ctx context.Context, UserID int, addressID string) (bool, error) {
+	logger := log.With(g.logger, "method", "delete_address")
+	res, err := g.repository.DeleteAddress(ctx, UserID, addressID)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// SendPhoneVerification generates a one-time code and sends it to the mobile number of the user
+func (g *GrpcUserDetailsService) SendPhoneVerification(ctx context.Context, UserID int) (bool, error) {
+	logger := log.With(g.logger, "method", "send_phone_verification")
+
+	details, err := g.repository.GetUserDetails(ctx, UserID)
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	if details.MobileNumber == "" {
+		err := errors.NewInvalidPhoneNumberError("mobile_number", "the user does not have a mobile number")
+		level.Error(logger).Log("validation: ", err)
+		return false, err
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, errors.NewInternalError()
+	}
+
+	now := time.Now()
+	verification := entities.PhoneVerification{
+		UserID:       UserID,
+		Number:       details.MobileNumber,
+		CodeHash:     g.hashCode(UserID, details.MobileNumber, code),
+		ExpiresAt:    now.Add(codeTTL),
+		WindowEndsAt: now.Add(attemptsWindow),
+	}
+
+	// the failed attempts count across the resends until the window ends, a new code gives no new guesses
+	if previous, err := g.repository.GetPhoneVerification(ctx, UserID); err == nil && now.Before(previous.WindowEndsAt) {
+		if previous.Attempts >= maxCodeAttempts {
+			err := errors.NewInvalidCodeError()
+			level.Error(logger).Log("validation: ", err)
+			return false, err
+		}
+		verification.Attempts, verification.WindowEndsAt = previous.Attempts, previous.WindowEndsAt
+	}
+
+	if _, err := g.repository.SavePhoneVerification(ctx, verification); err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	message := fmt.Sprintf("Your verification code is %v", code)
+	if err := g.sender.Send(ctx, details.MobileNumber, message); err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, errors.NewInternalError()
+	}
+
+	logger.Log("action", "success")
+	return true, nil
+}
+
+// VerifyPhone checks the one-time code and marks the mobile number of the user as verified
+func (g *GrpcUserDetailsService) VerifyPhone(ctx context.Context, UserID int, code string) (bool, error) {
+	logger := log.With(g.logger, "method", "verify_phone")
+
+	// the attempt is counted before the code is compared so the concurrent guesses are bounded as well, the
+	// verification is kept so its failed attempts still count when a new code is sent
+	verification, err := g.repository.CountCodeAttempt(ctx, UserID, maxCodeAttempts)
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	if time.Now().After(verification.ExpiresAt) {
+		err := errors.NewInvalidCodeError()
+		level.Error(logger).Log("validation: ", err)
+		return false, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(g.hashCode(UserID, verification.Number, code)), []byte(verification.CodeHash)) != 1 {
+		err := errors.NewInvalidCodeError()
+		level.Error(logger).Log("validation: ", err)
+		return false, err
+	}
+
+	res, err := g.repository.MarkPhoneVerified(ctx, UserID, verification.Number)
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	g.repository.DeletePhoneVerification(ctx, UserID)
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// SetAvatar receives the user ID and the avatar reference and send them to the repository
+func (g *GrpcUserDetailsService) SetAvatar(ctx context.Context, UserID int, avatar string) (bool, error) {
+	logger := log.With(g.logger, "method", "set_avatar")
+	res, err := g.repository.SetAvatar(ctx, UserID, avatar)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+func generateCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < codeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", codeDigits, n), nil
+}
+
+// hashCode keys the hash with the secret of the service, a bare hash of a 6 digit code is reversed by trying them all
+func (g *GrpcUserDetailsService) hashCode(UserID int, number string, code string) string {
+	mac := hmac.New(sha256.New, g.codeKey)
+	fmt.Fprintf(mac, "%v:%v:%v", UserID, number, code)
+	return hex.EncodeToString(mac.Sum(nil))
+}
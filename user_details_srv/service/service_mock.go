@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,8 +14,8 @@ type UserDetailsRepositoryMock struct {
 }
 
 // SetUserDetails is a mock of the real method
-func (r *UserDetailsRepositoryMock) SetUserDetails(ctx context.Context, information entities.UserDetails) (bool, error) {
-	args := r.Called(ctx, information)
+func (r *UserDetailsRepositoryMock) SetUserDetails(ctx context.Context, information entities.UserDetails, revision int64) (bool, error) {
+	args := r.Called(ctx, information, revision)
 
 	return args.Bool(0), args.Error(1)
 }
@@ -26,9 +27,147 @@ func (r *UserDetailsRepositoryMock) GetUserDetails(ctx context.Context, userID i
 	return args.Get(0).(entities.UserDetails), args.Error(1)
 }
 
+// BatchGetUserDetails is a mock of the real method
+func (r *UserDetailsRepositoryMock) BatchGetUserDetails(ctx context.Context, userIDs []int) ([]entities.UserDetails, error) {
+	args := r.Called(ctx, userIDs)
+
+	return args.Get(0).([]entities.UserDetails), args.Error(1)
+}
+
 // DeleteUserDetails is amock of the real method
 func (r *UserDetailsRepositoryMock) DeleteUserDetails(ctx context.Context, userID int) (bool, error) {
 	args := r.Called(ctx, userID)
 
 	return args.Bool(0), args.Error(1)
 }
+
+// SetAttributeDefinition is a mock of the real method
+func (r *UserDetailsRepositoryMock) SetAttributeDefinition(ctx context.Context, definition entities.AttributeDefinition) (bool, error) {
+	args := r.Called(ctx, definition)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// GetAttributeSchema is a mock of the real method
+func (r *UserDetailsRepositoryMock) GetAttributeSchema(ctx context.Context) ([]entities.AttributeDefinition, error) {
+	args := r.Called(ctx)
+
+	return args.Get(0).([]entities.AttributeDefinition), args.Error(1)
+}
+
+// DeleteAttributeDefinition is a mock of the real method
+func (r *UserDetailsRepositoryMock) DeleteAttributeDefinition(ctx context.Context, name string) (bool, error) {
+	args := r.Called(ctx, name)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// AddAddress is a mock of the real method
+func (r *UserDetailsRepositoryMock) AddAddress(ctx context.Context, address entities.Address) (entities.Address, error) {
+	args := r.Called(ctx, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// ListAddresses is a mock of the real method
+func (r *UserDetailsRepositoryMock) ListAddresses(ctx context.Context, userID int) ([]entities.Address, error) {
+	args := r.Called(ctx, userID)
+
+	return args.Get(0).([]entities.Address), args.Error(1)
+}
+
+// GetAddress is a mock of the real method
+func (r *UserDetailsRepositoryMock) GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error) {
+	args := r.Called(ctx, userID, addressID)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// UpdateAddress is a mock of the real method
+func (r *UserDetailsRepositoryMock) UpdateAddress(ctx context.Context, address entities.Address) (entities.Address, error) {
+	args := r.Called(ctx, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// DeleteAddress is a mock of the real method
+func (r *UserDetailsRepositoryMock) DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error) {
+	args := r.Called(ctx, userID, addressID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// SavePhoneVerification is a mock of the real method
+func (r *UserDetailsRepositoryMock) SavePhoneVerification(ctx context.Context, verification entities.PhoneVerification) (bool, error) {
+	args := r.Called(ctx, verification)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// GetPhoneVerification is a mock of the real method
+func (r *UserDetailsRepositoryMock) GetPhoneVerification(ctx context.Context, userID int) (entities.PhoneVerification, error) {
+	args := r.Called(ctx, userID)
+
+	return args.Get(0).(entities.PhoneVerification), args.Error(1)
+}
+
+// CountCodeAttempt is a mock of the real method
+func (r *UserDetailsRepositoryMock) CountCodeAttempt(ctx context.Context, userID int, maxAttempts int) (entities.PhoneVerification, error) {
+	args := r.Called(ctx, userID, maxAttempts)
+
+	return args.Get(0).(entities.PhoneVerification), args.Error(1)
+}
+
+// DeletePhoneVerification is a mock of the real method
+func (r *UserDetailsRepositoryMock) DeletePhoneVerification(ctx context.Context, userID int) (bool, error) {
+	args := r.Called(ctx, userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// MarkPhoneVerified is a mock of the real method
+func (r *UserDetailsRepositoryMock) MarkPhoneVerified(ctx context.Context, userID int, number string) (bool, error) {
+	args := r.Called(ctx, userID, number)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// SetAvatar is a mock of the real method
+func (r *UserDetailsRepositoryMock) SetAvatar(ctx context.Context, userID int, avatar string) (bool, error) {
+	args := r.Called(ctx, userID, avatar)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// ListChanges is a mock of the real method
+func (r *UserDetailsRepositoryMock) ListChanges(ctx context.Context, after int64, limit int) ([]events.Change, error) {
+	args := r.Called(ctx, after, limit)
+
+	return args.Get(0).([]events.Change), args.Error(1)
+}
+
+// ListUserChanges is a mock of the real method
+func (r *UserDetailsRepositoryMock) ListUserChanges(ctx context.Context, userID int, after int64, limit int) ([]events.Change, error) {
+	args := r.Called(ctx, userID, after, limit)
+
+	return args.Get(0).([]events.Change), args.Error(1)
+}
+
+// LastChangePosition is a mock of the real method
+func (r *UserDetailsRepositoryMock) LastChangePosition(ctx context.Context) (int64, error) {
+	args := r.Called(ctx)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// SenderMock type is used to mock the performance of the SMS gateway
+type SenderMock struct {
+	mock.Mock
+}
+
+// Send is a mock of the real method
+func (s *SenderMock) Send(ctx context.Context, to string, message string) error {
+	args := s.Called(ctx, to, message)
+
+	return args.Error(0)
+}
@@ -2,21 +2,68 @@ package service_test
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 	"github.com/mauricioww/user_microsrv/user_details_srv/service"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestSetUserDetails(t *testing.T) {
 	var srv service.GrpcUserDetailsServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserDetailsRepositoryMock)
-	srv = service.NewGrpcUserDetailsService(repoMock, logger)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	minAge := 18.0
+	schema := []entities.AttributeDefinition{
+		{
+			Name:     "nickname",
+			Type:     entities.StringAttribute,
+			Required: true,
+			Constraints: entities.AttributeConstraints{
+				MaxLength: 10,
+			},
+		},
+		{
+			Name: "legal_age",
+			Type: entities.IntAttribute,
+			Constraints: entities.AttributeConstraints{
+				Min: &minAge,
+			},
+		},
+		{
+			Name: "plan",
+			Type: entities.StringAttribute,
+			Constraints: entities.AttributeConstraints{
+				Enum: []string{"free", "premium"},
+			},
+		},
+		{
+			Name: "code",
+			Type: entities.StringAttribute,
+			Constraints: entities.AttributeConstraints{
+				Pattern: "^[A-Z]{3}$",
+			},
+		},
+		{
+			Name: "legacy",
+			Type: entities.StringAttribute,
+			Constraints: entities.AttributeConstraints{
+				Pattern: "[a-z",
+			},
+		},
+	}
+	repoMock.On("GetAttributeSchema", context.Background()).Return(schema, nil)
 
 	testCases := []struct {
 		testName string
@@ -30,14 +77,93 @@ func TestSetUserDetails(t *testing.T) {
 				UserID:       1,
 				Country:      "Mexico",
 				City:         "CDMX",
-				MobileNumber: "11223344",
+				MobileNumber: "+525511223344",
 				Married:      false,
 				Height:       1.75,
 				Weight:       76.0,
+				Attributes: map[string]interface{}{
+					"nickname":  "mau",
+					"legal_age": int64(21),
+					"plan":      "premium",
+				},
 			},
 			res: true,
 			err: nil,
 		},
+		{
+			testName: "missing required attribute error",
+			data: entities.UserDetails{
+				UserID:     2,
+				Attributes: map[string]interface{}{},
+			},
+			err: errors.NewInvalidAttributeError("nickname", "attribute is required"),
+		},
+		{
+			testName: "undefined attribute error",
+			data: entities.UserDetails{
+				UserID: 3,
+				Attributes: map[string]interface{}{
+					"nickname": "mau",
+					"shoe":     int64(9),
+				},
+			},
+			err: errors.NewInvalidAttributeError("shoe", "attribute is not defined"),
+		},
+		{
+			testName: "attribute with wrong type error",
+			data: entities.UserDetails{
+				UserID: 4,
+				Attributes: map[string]interface{}{
+					"nickname":  "mau",
+					"legal_age": "twenty",
+				},
+			},
+			err: errors.NewInvalidAttributeError("legal_age", "expected an int value"),
+		},
+		{
+			testName: "attribute out of range error",
+			data: entities.UserDetails{
+				UserID: 5,
+				Attributes: map[string]interface{}{
+					"nickname":  "mau",
+					"legal_age": int64(15),
+				},
+			},
+			err: errors.NewInvalidAttributeError("legal_age", "value out of range"),
+		},
+		{
+			testName: "attribute not in enum error",
+			data: entities.UserDetails{
+				UserID: 6,
+				Attributes: map[string]interface{}{
+					"nickname": "mau",
+					"plan":     "gold",
+				},
+			},
+			err: errors.NewInvalidAttributeError("plan", "value is not allowed"),
+		},
+		{
+			testName: "attribute not matching pattern error",
+			data: entities.UserDetails{
+				UserID: 7,
+				Attributes: map[string]interface{}{
+					"nickname": "mau",
+					"code":     "mx",
+				},
+			},
+			err: errors.NewInvalidAttributeError("code", "value does not match pattern"),
+		},
+		{
+			testName: "stored invalid pattern error",
+			data: entities.UserDetails{
+				UserID: 8,
+				Attributes: map[string]interface{}{
+					"nickname": "mau",
+					"legacy":   "value",
+				},
+			},
+			err: errors.NewInvalidAttributeError("legacy", "invalid pattern"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -47,9 +173,9 @@ func TestSetUserDetails(t *testing.T) {
 			assert := assert.New(t)
 
 			// act
-			repoMock.On("SetUserDetails", ctx, tc.data).Return(tc.res, tc.err)
+			repoMock.On("SetUserDetails", ctx, tc.data, entities.AnyRevision).Return(tc.res, tc.err)
 			res, err := srv.SetUserDetails(ctx, tc.data.UserID, tc.data.Country, tc.data.City,
-				tc.data.MobileNumber, tc.data.Married, tc.data.Height, tc.data.Weight)
+				tc.data.MobileNumber, tc.data.Married, tc.data.Height, tc.data.Weight, tc.data.Attributes, entities.AnyRevision)
 
 			// assert
 			assert.Equal(tc.res, res)
@@ -62,7 +188,7 @@ func TestGetUserDetails(t *testing.T) {
 	var srv service.GrpcUserDetailsServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserDetailsRepositoryMock)
-	srv = service.NewGrpcUserDetailsService(repoMock, logger)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
 
 	testCases := []struct {
 		testName string
@@ -77,7 +203,7 @@ func TestGetUserDetails(t *testing.T) {
 				UserID:       0,
 				Country:      "Mexico",
 				City:         "CDMX",
-				MobileNumber: "11223344",
+				MobileNumber: "+525511223344",
 				Married:      false,
 				Height:       1.75,
 				Weight:       76.0,
@@ -108,11 +234,71 @@ func TestGetUserDetails(t *testing.T) {
 	}
 }
 
+func TestBatchGetUserDetails(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	testCases := []struct {
+		testName string
+		data     []int
+		repoRes  []entities.UserDetails
+		repoErr  error
+		found    []bool
+		err      error
+	}{
+		{
+			testName: "batch with missing details",
+			data:     []int{7, 3, 5},
+			repoRes: []entities.UserDetails{
+				{UserID: 3, Country: "Mexico"},
+				{UserID: 7, Country: "Chile"},
+			},
+			found: []bool{true, true, false},
+		},
+		{
+			testName: "empty batch error",
+			data:     []int{},
+			err:      errors.NewInvalidBatchError(),
+		},
+		{
+			testName: "missing tenant error",
+			data:     []int{1},
+			repoErr:  errors.NewMissingTenantError(),
+			err:      errors.NewMissingTenantError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("BatchGetUserDetails", ctx, tc.data).Return(tc.repoRes, tc.repoErr)
+			res, err := srv.BatchGetUserDetails(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Len(res, len(tc.found))
+			for i, r := range res {
+				assert.Equal(tc.data[i], r.UserID)
+				assert.Equal(tc.found[i], r.Found)
+				if r.Found {
+					assert.Equal(tc.data[i], r.Details.UserID)
+				}
+			}
+		})
+	}
+}
+
 func TestDeleteUserDetails(t *testing.T) {
 	var srv service.GrpcUserDetailsServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserDetailsRepositoryMock)
-	srv = service.NewGrpcUserDetailsService(repoMock, logger)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
 
 	testCases := []struct {
 		testName string
@@ -149,3 +335,657 @@ func TestDeleteUserDetails(t *testing.T) {
 		})
 	}
 }
+
+func TestSetAttributeDefinition(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	min, max := 10.0, 1.0
+
+	testCases := []struct {
+		testName string
+		data     entities.AttributeDefinition
+		res      bool
+		err      error
+	}{
+		{
+			testName: "set attribute definition success",
+			data: entities.AttributeDefinition{
+				Name:     "nickname",
+				Type:     entities.StringAttribute,
+				Required: true,
+			},
+			res: true,
+			err: nil,
+		},
+		{
+			testName: "unsupported type error",
+			data: entities.AttributeDefinition{
+				Name: "birthday",
+				Type: "date",
+			},
+			err: errors.NewInvalidAttributeError("birthday", "unsupported type 'date'"),
+		},
+		{
+			testName: "inconsistent range error",
+			data: entities.AttributeDefinition{
+				Name: "score",
+				Type: entities.FloatAttribute,
+				Constraints: entities.AttributeConstraints{
+					Min: &min,
+					Max: &max,
+				},
+			},
+			err: errors.NewInvalidAttributeError("score", "min is greater than max"),
+		},
+		{
+			testName: "invalid pattern error",
+			data: entities.AttributeDefinition{
+				Name: "code",
+				Type: entities.StringAttribute,
+				Constraints: entities.AttributeConstraints{
+					Pattern: "[a-z",
+				},
+			},
+			err: errors.NewInvalidAttributeError("code", "invalid pattern"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("SetAttributeDefinition", ctx, tc.data).Return(tc.res, tc.err)
+			res, err := srv.SetAttributeDefinition(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestDeleteAttributeDefinition(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	testCases := []struct {
+		testName string
+		data     string
+		res      bool
+		err      error
+	}{
+		{
+			testName: "delete attribute definition success",
+			data:     "nickname",
+			res:      true,
+			err:      nil,
+		},
+		{
+			testName: "delete attribute definition which does not exist error",
+			data:     "shoe",
+			err:      errors.NewInvalidAttributeError("shoe", "attribute is not defined"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("DeleteAttributeDefinition", ctx, tc.data).Return(tc.res, tc.err)
+			res, err := srv.DeleteAttributeDefinition(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestAddAddress(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	testCases := []struct {
+		testName   string
+		userID     int
+		data       entities.Address
+		normalized entities.Address
+		res        entities.Address
+		err        error
+	}{
+		{
+			testName: "add address success",
+			userID:   1,
+			data: entities.Address{
+				Lines:       []string{" Av. Reforma 222 ", ""},
+				Locality:    "CDMX",
+				PostalCode:  "06600",
+				CountryCode: "mx",
+				Label:       "Home",
+			},
+			normalized: entities.Address{
+				UserID:      1,
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				PostalCode:  "06600",
+				CountryCode: "MX",
+				Label:       "home",
+			},
+			res: entities.Address{
+				ID:          "61b0c0f1e4b0a1a2b3c4d5e6",
+				UserID:      1,
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				PostalCode:  "06600",
+				CountryCode: "MX",
+				Label:       "home",
+				Primary:     true,
+			},
+			err: nil,
+		},
+		{
+			testName: "no lines error",
+			userID:   2,
+			data: entities.Address{
+				Locality:    "CDMX",
+				CountryCode: "MX",
+			},
+			err: errors.NewInvalidAddressError("lines", "at least one line is required"),
+		},
+		{
+			testName: "no locality error",
+			userID:   3,
+			data: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				CountryCode: "MX",
+			},
+			err: errors.NewInvalidAddressError("locality", "field is required"),
+		},
+		{
+			testName: "invalid country code error",
+			userID:   4,
+			data: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MEX",
+			},
+			err: errors.NewInvalidAddressError("country_code", "not an ISO 3166-1 alpha-2 code"),
+		},
+		{
+			testName: "user not found error",
+			userID:   5,
+			data: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+			},
+			normalized: entities.Address{
+				UserID:      5,
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+			},
+			err: errors.NewUserNotFoundError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("AddAddress", ctx, tc.normalized).Return(tc.res, tc.err)
+			res, err := srv.AddAddress(ctx, tc.userID, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestDeleteAddress(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	testCases := []struct {
+		testName  string
+		userID    int
+		addressID string
+		res       bool
+		err       error
+	}{
+		{
+			testName:  "delete address success",
+			userID:    1,
+			addressID: "61b0c0f1e4b0a1a2b3c4d5e6",
+			res:       true,
+			err:       nil,
+		},
+		{
+			testName:  "delete address which does not exist error",
+			userID:    1,
+			addressID: "61b0c0f1e4b0a1a2b3c4d5e7",
+			err:       errors.NewAddressNotFoundError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("DeleteAddress", ctx, tc.userID, tc.addressID).Return(tc.res, tc.err)
+			res, err := srv.DeleteAddress(ctx, tc.userID, tc.addressID)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestSetUserDetailsMobileNumber(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+	repoMock.On("GetAttributeSchema", context.Background()).Return([]entities.AttributeDefinition{}, nil)
+
+	testCases := []struct {
+		testName string
+		userID   int
+		country  string
+		number   string
+		res      string
+		err      error
+	}{
+		{
+			testName: "national number normalized with country name success",
+			userID:   1,
+			country:  "México",
+			number:   "(55) 1122-3344",
+			res:      "+525511223344",
+			err:      nil,
+		},
+		{
+			testName: "national number with trunk prefix success",
+			userID:   2,
+			country:  "GB",
+			number:   "07911 123456",
+			res:      "+447911123456",
+			err:      nil,
+		},
+		{
+			testName: "international number without country success",
+			userID:   3,
+			number:   "0034 612 345 678",
+			res:      "+34612345678",
+			err:      nil,
+		},
+		{
+			testName: "national number with unknown country error",
+			userID:   4,
+			country:  "Atlantis",
+			number:   "5511223344",
+			err:      errors.NewInvalidPhoneNumberError("mobile_number", "national number without a known country, use the +<country code> format"),
+		},
+		{
+			testName: "wrong length for the country error",
+			userID:   5,
+			country:  "Mexico",
+			number:   "11223344",
+			err:      errors.NewInvalidPhoneNumberError("mobile_number", "invalid length for the country"),
+		},
+		{
+			testName: "letters within the number error",
+			userID:   6,
+			number:   "+52 55 CALL ME",
+			err:      errors.NewInvalidPhoneNumberError("mobile_number", "unexpected character"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+			data := entities.UserDetails{
+				UserID:       tc.userID,
+				Country:      tc.country,
+				MobileNumber: tc.res,
+				Attributes:   map[string]interface{}{},
+			}
+
+			// act
+			repoMock.On("SetUserDetails", ctx, data, entities.AnyRevision).Return(tc.err == nil, nil)
+			res, err := srv.SetUserDetails(ctx, tc.userID, tc.country, "", tc.number, false, 0, 0, nil, entities.AnyRevision)
+
+			// assert
+			assert.Equal(tc.err == nil, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestSendPhoneVerification(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	senderMock := new(service.SenderMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, senderMock, []byte("code-key"), logger)
+
+	testCases := []struct {
+		testName string
+		userID   int
+		details  entities.UserDetails
+		detErr   error
+		previous entities.PhoneVerification
+		attempts int
+		res      bool
+		err      error
+	}{
+		{
+			testName: "code sent success",
+			userID:   1,
+			details:  entities.UserDetails{UserID: 1, MobileNumber: "+525511223344"},
+			res:      true,
+			err:      nil,
+		},
+		{
+			testName: "user without mobile number error",
+			userID:   2,
+			details:  entities.UserDetails{UserID: 2},
+			err:      errors.NewInvalidPhoneNumberError("mobile_number", "the user does not have a mobile number"),
+		},
+		{
+			testName: "user not found error",
+			userID:   3,
+			detErr:   errors.NewUserNotFoundError(),
+			err:      errors.NewUserNotFoundError(),
+		},
+		{
+			testName: "resend keeps the failed attempts success",
+			userID:   4,
+			details:  entities.UserDetails{UserID: 4, MobileNumber: "+525511223344"},
+			previous: entities.PhoneVerification{UserID: 4, Attempts: 3, WindowEndsAt: time.Now().Add(time.Minute)},
+			attempts: 3,
+			res:      true,
+		},
+		{
+			testName: "resend after the window resets the attempts success",
+			userID:   5,
+			details:  entities.UserDetails{UserID: 5, MobileNumber: "+525511223344"},
+			previous: entities.PhoneVerification{UserID: 5, Attempts: 5, WindowEndsAt: time.Now().Add(-time.Minute)},
+			res:      true,
+		},
+		{
+			testName: "resend with the attempts exhausted error",
+			userID:   6,
+			details:  entities.UserDetails{UserID: 6, MobileNumber: "+525511223344"},
+			previous: entities.PhoneVerification{UserID: 6, Attempts: 5, WindowEndsAt: time.Now().Add(time.Minute)},
+			err:      errors.NewInvalidCodeError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+			isVerification := mock.MatchedBy(func(v entities.PhoneVerification) bool {
+				return v.UserID == tc.userID && v.Number == tc.details.MobileNumber && v.ExpiresAt.After(time.Now()) &&
+					v.Attempts == tc.attempts && v.WindowEndsAt.After(time.Now())
+			})
+			prevErr := error(nil)
+			if tc.previous.UserID == 0 {
+				prevErr = errors.NewInvalidCodeError()
+			}
+
+			// act
+			repoMock.On("GetUserDetails", ctx, tc.userID).Return(tc.details, tc.detErr)
+			repoMock.On("GetPhoneVerification", ctx, tc.userID).Return(tc.previous, prevErr)
+			repoMock.On("SavePhoneVerification", ctx, isVerification).Return(true, nil)
+			senderMock.On("Send", ctx, tc.details.MobileNumber, mock.AnythingOfType("string")).Return(nil)
+			res, err := srv.SendPhoneVerification(ctx, tc.userID)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+			if tc.res {
+				repoMock.AssertCalled(t, "SavePhoneVerification", ctx, isVerification)
+			}
+		})
+	}
+}
+
+func TestVerifyPhone(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	mac := hmac.New(sha256.New, []byte("code-key"))
+	mac.Write([]byte("1:+525511223344:123456"))
+	hash := hex.EncodeToString(mac.Sum(nil))
+
+	testCases := []struct {
+		testName     string
+		userID       int
+		code         string
+		verification entities.PhoneVerification
+		countErr     error
+		res          bool
+		err          error
+	}{
+		{
+			testName:     "phone verified success",
+			userID:       1,
+			code:         "123456",
+			verification: entities.PhoneVerification{UserID: 1, Number: "+525511223344", CodeHash: hash, ExpiresAt: time.Now().Add(time.Minute)},
+			res:          true,
+			err:          nil,
+		},
+		{
+			testName:     "wrong code error",
+			userID:       2,
+			code:         "654321",
+			verification: entities.PhoneVerification{UserID: 2, Number: "+525511223344", CodeHash: hash, ExpiresAt: time.Now().Add(time.Minute)},
+			err:          errors.NewInvalidCodeError(),
+		},
+		{
+			testName:     "expired code error",
+			userID:       3,
+			code:         "123456",
+			verification: entities.PhoneVerification{UserID: 3, Number: "+525511223344", CodeHash: hash, ExpiresAt: time.Now().Add(-time.Minute)},
+			err:          errors.NewInvalidCodeError(),
+		},
+		{
+			testName: "too many attempts error",
+			userID:   4,
+			code:     "123456",
+			countErr: errors.NewInvalidCodeError(),
+			err:      errors.NewInvalidCodeError(),
+		},
+		{
+			testName: "attempt not counted error",
+			userID:   6,
+			code:     "123456",
+			countErr: errors.NewInternalError(),
+			err:      errors.NewInternalError(),
+		},
+		{
+			testName:     "code of another user error",
+			userID:       5,
+			code:         "123456",
+			verification: entities.PhoneVerification{UserID: 5, Number: "+525511223344", CodeHash: hash, ExpiresAt: time.Now().Add(time.Minute)},
+			err:          errors.NewInvalidCodeError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("CountCodeAttempt", ctx, tc.userID, 5).Return(tc.verification, tc.countErr)
+			repoMock.On("DeletePhoneVerification", ctx, tc.userID).Return(true, nil)
+			repoMock.On("MarkPhoneVerified", ctx, tc.userID, tc.verification.Number).Return(true, nil)
+			res, err := srv.VerifyPhone(ctx, tc.userID, tc.code)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+			repoMock.AssertNotCalled(t, "SavePhoneVerification", ctx, mock.Anything)
+		})
+	}
+}
+
+func TestListChanges(t *testing.T) {
+	var srv service.GrpcUserDetailsServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserDetailsRepositoryMock)
+	srv = service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+
+	testCases := []struct {
+		testName string
+		cursor   string
+		after    int64
+		pageSize int
+		limit    int
+		res      []events.Change
+		next     string
+		err      error
+	}{
+		{
+			testName: "page returns the cursor of the last change",
+			cursor:   events.EncodeCursor(2),
+			after:    2,
+			pageSize: 0,
+			limit:    100,
+			res: []events.Change{
+				{Event: events.Event{ID: "a", Type: events.DetailsChanged, UserID: 1}, Cursor: events.EncodeCursor(5)},
+			},
+			next: events.EncodeCursor(5),
+		},
+		{
+			testName: "no new changes keep the cursor",
+			cursor:   events.EncodeCursor(5),
+			after:    5,
+			pageSize: 5000,
+			limit:    1000,
+			res:      []events.Change{},
+			next:     events.EncodeCursor(5),
+		},
+		{
+			testName: "invalid cursor error",
+			cursor:   "djIuMTI",
+			err:      errors.NewInvalidCursorError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("ListChanges", ctx, tc.after, tc.limit).Return(tc.res, tc.err)
+			res, next, err := srv.ListChanges(ctx, tc.cursor, tc.pageSize)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Equal(tc.next, next)
+			if tc.err == nil {
+				assert.Equal(tc.res, res)
+			}
+		})
+	}
+}
+
+func TestWatchUserDetails(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+	changes := []events.Change{
+		{Event: events.Event{ID: "a", Type: events.DetailsChanged, UserID: 1}, Cursor: events.EncodeCursor(8)},
+		{Event: events.Event{ID: "b", Type: events.DetailsChanged, UserID: 1}, Cursor: events.EncodeCursor(9)},
+	}
+
+	testCases := []struct {
+		testName string
+		cursor   string
+		last     int64
+		after    int64
+		res      []events.Change
+		err      error
+	}{
+		{
+			testName: "resume sends the changes after the cursor",
+			cursor:   events.EncodeCursor(7),
+			after:    7,
+			res:      changes,
+		},
+		{
+			testName: "empty cursor starts after the last change",
+			cursor:   "",
+			last:     7,
+			after:    7,
+			res:      changes,
+		},
+		{
+			testName: "invalid cursor error",
+			cursor:   "djIuMTI",
+			err:      errors.NewInvalidCursorError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			repoMock := new(service.UserDetailsRepositoryMock)
+			srv := service.NewGrpcUserDetailsService(repoMock, new(service.SenderMock), []byte("code-key"), logger)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var sent []events.Change
+			send := func(c events.Change) error {
+				sent = append(sent, c)
+				if len(sent) == len(tc.res) {
+					cancel()
+				}
+				return nil
+			}
+
+			// act
+			repoMock.On("LastChangePosition", mock.Anything).Return(tc.last, nil)
+			repoMock.On("ListUserChanges", mock.Anything, 1, tc.after, 100).Return(tc.res, nil)
+			err := srv.WatchUserDetails(ctx, 1, tc.cursor, send)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Equal(tc.res, sent)
+		})
+	}
+}
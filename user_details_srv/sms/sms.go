@@ -0,0 +1,30 @@
+package sms
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Sender describes the gateway used to deliver text messages to a mobile number
+type Sender interface {
+	Send(ctx context.Context, to string, message string) error
+}
+
+// LogSender implements the Sender interface writing the messages to the logger, useful for local environments
+type LogSender struct {
+	logger log.Logger
+}
+
+// NewLogSender returns a LogSender pointer type
+func NewLogSender(l log.Logger) *LogSender {
+	return &LogSender{
+		logger: log.With(l, "sender", "log"),
+	}
+}
+
+// Send writes the message and its recipient to the logger
+func (s *LogSender) Send(ctx context.Context, to string, message string) error {
+	return level.Info(s.logger).Log("to", to, "sms", message)
+}
@@ -0,0 +1,32 @@
+package transport
+
+import (
+	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+)
+
+func addressFromProto(a *detailspb.Address) entities.Address {
+	return entities.Address{
+		ID:          a.GetId(),
+		Lines:       a.GetLines(),
+		Locality:    a.GetLocality(),
+		Region:      a.GetRegion(),
+		PostalCode:  a.GetPostalCode(),
+		CountryCode: a.GetCountryCode(),
+		Label:       a.GetLabel(),
+		Primary:     a.GetPrimary(),
+	}
+}
+
+func addressToProto(a entities.Address) *detailspb.Address {
+	return &detailspb.Address{
+		Id:          a.ID,
+		Lines:       a.Lines,
+		Locality:    a.Locality,
+		Region:      a.Region,
+		PostalCode:  a.PostalCode,
+		CountryCode: a.CountryCode,
+		Label:       a.Label,
+		Primary:     a.Primary,
+	}
+}
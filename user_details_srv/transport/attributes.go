@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+)
+
+var attributeTypes = map[detailspb.AttributeType]entities.AttributeType{
+	detailspb.AttributeType_STRING: entities.StringAttribute,
+	detailspb.AttributeType_INT:    entities.IntAttribute,
+	detailspb.AttributeType_FLOAT:  entities.FloatAttribute,
+	detailspb.AttributeType_BOOL:   entities.BoolAttribute,
+}
+
+func attributesFromProto(values map[string]*detailspb.Value) map[string]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	res := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		switch k := v.GetKind().(type) {
+		case *detailspb.Value_StringValue:
+			res[name] = k.StringValue
+		case *detailspb.Value_IntValue:
+			res[name] = k.IntValue
+		case *detailspb.Value_FloatValue:
+			res[name] = k.FloatValue
+		case *detailspb.Value_BoolValue:
+			res[name] = k.BoolValue
+		default:
+			res[name] = nil
+		}
+	}
+	return res
+}
+
+func attributesToProto(values map[string]interface{}) map[string]*detailspb.Value {
+	if len(values) == 0 {
+		return nil
+	}
+
+	res := make(map[string]*detailspb.Value, len(values))
+	for name, v := range values {
+		switch t := v.(type) {
+		case string:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_StringValue{StringValue: t}}
+		case int64:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_IntValue{IntValue: t}}
+		case int32:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_IntValue{IntValue: int64(t)}}
+		case float64:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_FloatValue{FloatValue: t}}
+		case bool:
+			res[name] = &detailspb.Value{Kind: &detailspb.Value_BoolValue{BoolValue: t}}
+		}
+	}
+	return res
+}
+
+func definitionFromProto(d *detailspb.AttributeDefinition) entities.AttributeDefinition {
+	c := d.GetConstraints()
+	if c == nil {
+		c = &detailspb.AttributeConstraints{}
+	}
+
+	return entities.AttributeDefinition{
+		Name:     d.GetName(),
+		Type:     attributeTypes[d.GetType()],
+		Required: d.GetRequired(),
+		Constraints: entities.AttributeConstraints{
+			Min:       c.Min,
+			Max:       c.Max,
+			MinLength: int(c.GetMinLength()),
+			MaxLength: int(c.GetMaxLength()),
+			Pattern:   c.GetPattern(),
+			Enum:      c.GetEnum(),
+		},
+	}
+}
+
+func definitionToProto(d entities.AttributeDefinition) *detailspb.AttributeDefinition {
+	var t detailspb.AttributeType
+	for k, v := range attributeTypes {
+		if v == d.Type {
+			t = k
+		}
+	}
+
+	return &detailspb.AttributeDefinition{
+		Name:     d.Name,
+		Type:     t,
+		Required: d.Required,
+		Constraints: &detailspb.AttributeConstraints{
+			Min:       d.Constraints.Min,
+			Max:       d.Constraints.Max,
+			MinLength: uint32(d.Constraints.MinLength),
+			MaxLength: uint32(d.Constraints.MaxLength),
+			Pattern:   d.Constraints.Pattern,
+			Enum:      d.Constraints.Enum,
+		},
+	}
+}
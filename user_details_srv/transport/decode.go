@@ -1,22 +1,106 @@
 package transport
 
+import (
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+)
+
 // SetUserDetailsRequest stores the data sent to gRPC SetUserDetails method
 type SetUserDetailsRequest struct {
-	UserID       int
-	Country      string
-	City         string
-	MobileNumber string
+	UserID       int    `validate:"min=1"`
+	Country      string `validate:"max=56"`
+	City         string `validate:"max=85"`
+	MobileNumber string `validate:"max=32"`
 	Married      bool
-	Height       float32
-	Weigth       float32
+	Height       float32 `validate:"omitempty,min=0.5,max=2.5"`
+	Weight       float32 `validate:"omitempty,min=20,max=350"`
+	Attributes   map[string]interface{}
+	Revision     int64
 }
 
 // GetUserDetailsRequest stores the data sent to gRPC GetUserDetails method
 type GetUserDetailsRequest struct {
-	UserID int
+	UserID int `validate:"min=1"`
+}
+
+// BatchGetUserDetailsRequest stores the data sent to gRPC BatchGetUserDetails method
+type BatchGetUserDetailsRequest struct {
+	UserIDs []int
 }
 
 // DeleteUserDetailsRequest stores the data sent to gRPC DeleteUserDetails method
 type DeleteUserDetailsRequest struct {
-	UserID int
+	UserID int `validate:"min=1"`
+}
+
+// SetAttributeDefinitionRequest stores the data sent to gRPC SetAttributeDefinition method
+type SetAttributeDefinitionRequest struct {
+	Definition entities.AttributeDefinition
+}
+
+// GetAttributeSchemaRequest stores the data sent to gRPC GetAttributeSchema method
+type GetAttributeSchemaRequest struct{}
+
+// DeleteAttributeDefinitionRequest stores the data sent to gRPC DeleteAttributeDefinition method
+type DeleteAttributeDefinitionRequest struct {
+	Name string `validate:"required"`
+}
+
+// AddAddressRequest stores the data sent to gRPC AddAddress method
+type AddAddressRequest struct {
+	UserID  int `validate:"min=1"`
+	Address entities.Address
+}
+
+// ListAddressesRequest stores the data sent to gRPC ListAddresses method
+type ListAddressesRequest struct {
+	UserID int `validate:"min=1"`
+}
+
+// GetAddressRequest stores the data sent to gRPC GetAddress method
+type GetAddressRequest struct {
+	UserID    int    `validate:"min=1"`
+	AddressID string `validate:"required"`
+}
+
+// UpdateAddressRequest stores the data sent to gRPC UpdateAddress method
+type UpdateAddressRequest struct {
+	UserID  int `validate:"min=1"`
+	Address entities.Address
+}
+
+// DeleteAddressRequest stores the data sent to gRPC DeleteAddress method
+type DeleteAddressRequest struct {
+	UserID    int    `validate:"min=1"`
+	AddressID string `validate:"required"`
+}
+
+// SendPhoneVerificationRequest stores the data sent to gRPC SendPhoneVerification method
+type SendPhoneVerificationRequest struct {
+	UserID int `validate:"min=1"`
+}
+
+// VerifyPhoneRequest stores the data sent to gRPC VerifyPhone method
+type VerifyPhoneRequest struct {
+	UserID int    `validate:"min=1"`
+	Code   string `validate:"required"`
+}
+
+// SetAvatarRequest stores the data sent to gRPC SetAvatar method
+type SetAvatarRequest struct {
+	UserID int `validate:"min=1"`
+	Avatar string
+}
+
+// ListChangesRequest stores the data sent to gRPC ListChanges method
+type ListChangesRequest struct {
+	Cursor   string
+	PageSize int
+}
+
+// WatchUserDetailsRequest stores the data sent to gRPC WatchUserDetails method, Send writes one change to the stream
+type WatchUserDetailsRequest struct {
+	UserID int `validate:"min=1"`
+	Cursor string
+	Send   func(events.Change) error
 }
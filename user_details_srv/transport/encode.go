@@ -1,5 +1,10 @@
 package transport
 
+import (
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
+)
+
 // SetUserDetailsResponse stores the data that gRPC GetUserDetails method will return
 type SetUserDetailsResponse struct {
 	Success bool
@@ -7,15 +12,92 @@ type SetUserDetailsResponse struct {
 
 // GetUserDetailsResponse stores the data that gRPC GetUserDetails method will return
 type GetUserDetailsResponse struct {
-	Country      string
-	City         string
-	MobileNumber string
-	Married      bool
-	Height       float32
-	Weight       float32
+	Country        string
+	City           string
+	MobileNumber   string
+	MobileVerified bool
+	Married        bool
+	Height         float32
+	Weight         float32
+	Attributes     map[string]interface{}
+	Avatar         string
+	Revision       int64
+}
+
+// UserDetailsResult stores the details of one of the ids that gRPC BatchGetUserDetails method will return
+type UserDetailsResult struct {
+	UserID  int
+	Found   bool
+	Details GetUserDetailsResponse
+}
+
+// BatchGetUserDetailsResponse stores the data that gRPC BatchGetUserDetails method will return
+type BatchGetUserDetailsResponse struct {
+	Results []UserDetailsResult
 }
 
 // DeleteUserDetailsResponse stores the data sent that gRPC DeleteUserDetails method will return
 type DeleteUserDetailsResponse struct {
 	Success bool
 }
+
+// SetAttributeDefinitionResponse stores the data that gRPC SetAttributeDefinition method will return
+type SetAttributeDefinitionResponse struct {
+	Success bool
+}
+
+// GetAttributeSchemaResponse stores the data that gRPC GetAttributeSchema method will return
+type GetAttributeSchemaResponse struct {
+	Definitions []entities.AttributeDefinition
+}
+
+// DeleteAttributeDefinitionResponse stores the data that gRPC DeleteAttributeDefinition method will return
+type DeleteAttributeDefinitionResponse struct {
+	Success bool
+}
+
+// AddAddressResponse stores the data that gRPC AddAddress method will return
+type AddAddressResponse struct {
+	Address entities.Address
+}
+
+// ListAddressesResponse stores the data that gRPC ListAddresses method will return
+type ListAddressesResponse struct {
+	Addresses []entities.Address
+}
+
+// GetAddressResponse stores the data that gRPC GetAddress method will return
+type GetAddressResponse struct {
+	Address entities.Address
+}
+
+// UpdateAddressResponse stores the data that gRPC UpdateAddress method will return
+type UpdateAddressResponse struct {
+	Address entities.Address
+}
+
+// DeleteAddressResponse stores the data that gRPC DeleteAddress method will return
+type DeleteAddressResponse struct {
+	Success bool
+}
+
+// SendPhoneVerificationResponse stores the data that gRPC SendPhoneVerification method will return
+type SendPhoneVerificationResponse struct {
+	Success bool
+}
+
+// VerifyPhoneResponse stores the data that gRPC VerifyPhone method will return
+type VerifyPhoneResponse struct {
+	Success bool
+}
+
+// SetAvatarResponse stores the data that gRPC SetAvatar method will return
+type SetAvatarResponse struct {
+	Success bool
+}
+
+// ListChangesResponse stores the data that gRPC ListChanges method will return
+type ListChangesResponse struct {
+	Changes    []events.Change
+	NextCursor string
+}
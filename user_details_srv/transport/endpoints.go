@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/mauricioww/user_microsrv/user_details_srv/service"
+	"github.com/mauricioww/user_microsrv/validation"
 )
 
 // GrpcEndpoints stores the endpoints for the current service
@@ -12,21 +13,64 @@ type GrpcEndpoints struct {
 	SetUserDetails    endpoint.Endpoint
 	GetUserDetails    endpoint.Endpoint
 	DeleteUserDetails endpoint.Endpoint
+
+	BatchGetUserDetails endpoint.Endpoint
+
+	SetAttributeDefinition    endpoint.Endpoint
+	GetAttributeSchema        endpoint.Endpoint
+	DeleteAttributeDefinition endpoint.Endpoint
+
+	AddAddress    endpoint.Endpoint
+	ListAddresses endpoint.Endpoint
+	GetAddress    endpoint.Endpoint
+	UpdateAddress endpoint.Endpoint
+	DeleteAddress endpoint.Endpoint
+
+	SendPhoneVerification endpoint.Endpoint
+	VerifyPhone           endpoint.Endpoint
+
+	SetAvatar endpoint.Endpoint
+
+	ListChanges      endpoint.Endpoint
+	WatchUserDetails endpoint.Endpoint
 }
 
-// MakeGrpcEndpoints returns a struct that stores the endpoints of the current service
+// MakeGrpcEndpoints returns a struct that stores the endpoints of the current service, the requests are validated
+// before reaching the service
 func MakeGrpcEndpoints(srv service.GrpcUserDetailsServicer) GrpcEndpoints {
+	validate := validation.Middleware()
+
 	return GrpcEndpoints{
-		SetUserDetails:    makeSetUserDetailsEndpoint(srv),
-		GetUserDetails:    makeGetUserDetailsEndpoint(srv),
-		DeleteUserDetails: makeDeleteUserDetailsEndpoint(srv),
+		SetUserDetails:    validate(makeSetUserDetailsEndpoint(srv)),
+		GetUserDetails:    validate(makeGetUserDetailsEndpoint(srv)),
+		DeleteUserDetails: validate(makeDeleteUserDetailsEndpoint(srv)),
+
+		BatchGetUserDetails: validate(makeBatchGetUserDetailsEndpoint(srv)),
+
+		SetAttributeDefinition:    validate(makeSetAttributeDefinitionEndpoint(srv)),
+		GetAttributeSchema:        validate(makeGetAttributeSchemaEndpoint(srv)),
+		DeleteAttributeDefinition: validate(makeDeleteAttributeDefinitionEndpoint(srv)),
+
+		AddAddress:    validate(makeAddAddressEndpoint(srv)),
+		ListAddresses: validate(makeListAddressesEndpoint(srv)),
+		GetAddress:    validate(makeGetAddressEndpoint(srv)),
+		UpdateAddress: validate(makeUpdateAddressEndpoint(srv)),
+		DeleteAddress: validate(makeDeleteAddressEndpoint(srv)),
+
+		SendPhoneVerification: validate(makeSendPhoneVerificationEndpoint(srv)),
+		VerifyPhone:           validate(makeVerifyPhoneEndpoint(srv)),
+
+		SetAvatar: validate(makeSetAvatarEndpoint(srv)),
+
+		ListChanges:      validate(makeListChangesEndpoint(srv)),
+		WatchUserDetails: validate(makeWatchUserDetailsEndpoint(srv)),
 	}
 }
 
 func makeSetUserDetailsEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(SetUserDetailsRequest)
-		res, err := srv.SetUserDetails(ctx, req.UserID, req.Country, req.City, req.MobileNumber, req.Married, req.Height, req.Weigth)
+		res, err := srv.SetUserDetails(ctx, req.UserID, req.Country, req.City, req.MobileNumber, req.Married, req.Height, req.Weight, req.Attributes, req.Revision)
 		return SetUserDetailsResponse{Success: res}, err
 	}
 }
@@ -35,7 +79,23 @@ func makeGetUserDetailsEndpoint(srv service.GrpcUserDetailsServicer) endpoint.En
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(GetUserDetailsRequest)
 		res, err := srv.GetUserDetails(ctx, req.UserID)
-		return GetUserDetailsResponse{Country: res.Country, City: res.City, MobileNumber: res.MobileNumber, Married: res.Married, Height: res.Height, Weight: res.Weight}, err
+		return GetUserDetailsResponse{Country: res.Country, City: res.City, MobileNumber: res.MobileNumber, MobileVerified: res.MobileVerified, Married: res.Married, Height: res.Height, Weight: res.Weight, Attributes: res.Attributes, Avatar: res.Avatar, Revision: res.Revision}, err
+	}
+}
+
+func makeBatchGetUserDetailsEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(BatchGetUserDetailsRequest)
+		res, err := srv.BatchGetUserDetails(ctx, req.UserIDs)
+
+		results := make([]UserDetailsResult, len(res))
+		for i, r := range res {
+			d := r.Details
+			results[i] = UserDetailsResult{UserID: r.UserID, Found: r.Found, Details: GetUserDetailsResponse{Country: d.Country, City: d.City, MobileNumber: d.MobileNumber,
+				MobileVerified: d.MobileVerified, Married: d.Married, Height: d.Height, Weight: d.Weight, Attributes: d.Attributes, Avatar: d.Avatar}}
+		}
+
+		return BatchGetUserDetailsResponse{Results: results}, err
 	}
 }
 
@@ -46,3 +106,106 @@ func makeDeleteUserDetailsEndpoint(srv service.GrpcUserDetailsServicer) endpoint
 		return DeleteUserDetailsResponse{Success: res}, err
 	}
 }
+
+func makeSetAttributeDefinitionEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(SetAttributeDefinitionRequest)
+		res, err := srv.SetAttributeDefinition(ctx, req.Definition)
+		return SetAttributeDefinitionResponse{Success: res}, err
+	}
+}
+
+func makeGetAttributeSchemaEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		res, err := srv.GetAttributeSchema(ctx)
+		return GetAttributeSchemaResponse{Definitions: res}, err
+	}
+}
+
+func makeDeleteAttributeDefinitionEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(DeleteAttributeDefinitionRequest)
+		res, err := srv.DeleteAttributeDefinition(ctx, req.Name)
+		return DeleteAttributeDefinitionResponse{Success: res}, err
+	}
+}
+
+func makeAddAddressEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(AddAddressRequest)
+		res, err := srv.AddAddress(ctx, req.UserID, req.Address)
+		return AddAddressResponse{Address: res}, err
+	}
+}
+
+func makeListAddressesEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListAddressesRequest)
+		res, err := srv.ListAddresses(ctx, req.UserID)
+		return ListAddressesResponse{Addresses: res}, err
+	}
+}
+
+func makeGetAddressEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetAddressRequest)
+		res, err := srv.GetAddress(ctx, req.UserID, req.AddressID)
+		return GetAddressResponse{Address: res}, err
+	}
+}
+
+func makeUpdateAddressEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(UpdateAddressRequest)
+		res, err := srv.UpdateAddress(ctx, req.UserID, req.Address)
+		return UpdateAddressResponse{Address: res}, err
+	}
+}
+
+func makeDeleteAddressEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(DeleteAddressRequest)
+		res, err := srv.DeleteAddress(ctx, req.UserID, req.AddressID)
+		return DeleteAddressResponse{Success: res}, err
+	}
+}
+
+func makeSendPhoneVerificationEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(SendPhoneVerificationRequest)
+		res, err := srv.SendPhoneVerification(ctx, req.UserID)
+		return SendPhoneVerificationResponse{Success: res}, err
+	}
+}
+
+func makeVerifyPhoneEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(VerifyPhoneRequest)
+		res, err := srv.VerifyPhone(ctx, req.UserID, req.Code)
+		return VerifyPhoneResponse{Success: res}, err
+	}
+}
+
+func makeSetAvatarEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(SetAvatarRequest)
+		res, err := srv.SetAvatar(ctx, req.UserID, req.Avatar)
+		return SetAvatarResponse{Success: res}, err
+	}
+}
+
+func makeListChangesEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListChangesRequest)
+		res, next, err := srv.ListChanges(ctx, req.Cursor, req.PageSize)
+		return ListChangesResponse{Changes: res, NextCursor: next}, err
+	}
+}
+
+// makeWatchUserDetailsEndpoint returns once the watch ends, the changes are written by the Send function of the request
+func makeWatchUserDetailsEndpoint(srv service.GrpcUserDetailsServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(WatchUserDetailsRequest)
+		return nil, srv.WatchUserDetails(ctx, req.UserID, req.Cursor, req.Send)
+	}
+}
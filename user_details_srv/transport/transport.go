@@ -2,12 +2,16 @@ package transport
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
+	"github.com/go-kit/kit/endpoint"
 	grpcGokit "github.com/go-kit/kit/transport/grpc"
 	grpcError "github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
-	"google.golang.org/grpc/status"
+	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 )
 
 type gRPCServer struct {
@@ -15,6 +19,28 @@ type gRPCServer struct {
 	getUserDetails    grpcGokit.Handler
 	deleteUserDetails grpcGokit.Handler
 
+	batchGetUserDetails grpcGokit.Handler
+
+	setAttributeDefinition    grpcGokit.Handler
+	getAttributeSchema        grpcGokit.Handler
+	deleteAttributeDefinition grpcGokit.Handler
+
+	addAddress    grpcGokit.Handler
+	listAddresses grpcGokit.Handler
+	getAddress    grpcGokit.Handler
+	updateAddress grpcGokit.Handler
+	deleteAddress grpcGokit.Handler
+
+	sendPhoneVerification grpcGokit.Handler
+	verifyPhone           grpcGokit.Handler
+
+	setAvatar grpcGokit.Handler
+
+	listChanges grpcGokit.Handler
+
+	// watchUserDetails is called directly since go-kit has no server for streaming calls
+	watchUserDetails endpoint.Endpoint
+
 	detailspb.UnimplementedUserDetailsServiceServer
 }
 
@@ -38,6 +64,86 @@ func NewGrpcUserDetailsServer(endpoints GrpcEndpoints) detailspb.UserDetailsServ
 			decodeDeleteUserDetails,
 			encodeDeleteUserDetails,
 		),
+
+		batchGetUserDetails: grpcGokit.NewServer(
+			endpoints.BatchGetUserDetails,
+			decodeBatchGetUserDetailsRequest,
+			encodeBatchGetUserDetailsResponse,
+		),
+
+		setAttributeDefinition: grpcGokit.NewServer(
+			endpoints.SetAttributeDefinition,
+			decodeSetAttributeDefinitionRequest,
+			encodeSetAttributeDefinitionResponse,
+		),
+
+		getAttributeSchema: grpcGokit.NewServer(
+			endpoints.GetAttributeSchema,
+			decodeGetAttributeSchemaRequest,
+			encodeGetAttributeSchemaResponse,
+		),
+
+		deleteAttributeDefinition: grpcGokit.NewServer(
+			endpoints.DeleteAttributeDefinition,
+			decodeDeleteAttributeDefinitionRequest,
+			encodeDeleteAttributeDefinitionResponse,
+		),
+
+		addAddress: grpcGokit.NewServer(
+			endpoints.AddAddress,
+			decodeAddAddressRequest,
+			encodeAddAddressResponse,
+		),
+
+		listAddresses: grpcGokit.NewServer(
+			endpoints.ListAddresses,
+			decodeListAddressesRequest,
+			encodeListAddressesResponse,
+		),
+
+		getAddress: grpcGokit.NewServer(
+			endpoints.GetAddress,
+			decodeGetAddressRequest,
+			encodeGetAddressResponse,
+		),
+
+		updateAddress: grpcGokit.NewServer(
+			endpoints.UpdateAddress,
+			decodeUpdateAddressRequest,
+			encodeUpdateAddressResponse,
+		),
+
+		deleteAddress: grpcGokit.NewServer(
+			endpoints.DeleteAddress,
+			decodeDeleteAddressRequest,
+			encodeDeleteAddressResponse,
+		),
+
+		sendPhoneVerification: grpcGokit.NewServer(
+			endpoints.SendPhoneVerification,
+			decodeSendPhoneVerificationRequest,
+			encodeSendPhoneVerificationResponse,
+		),
+
+		verifyPhone: grpcGokit.NewServer(
+			endpoints.VerifyPhone,
+			decodeVerifyPhoneRequest,
+			encodeVerifyPhoneResponse,
+		),
+
+		setAvatar: grpcGokit.NewServer(
+			endpoints.SetAvatar,
+			decodeSetAvatarRequest,
+			encodeSetAvatarResponse,
+		),
+
+		listChanges: grpcGokit.NewServer(
+			endpoints.ListChanges,
+			decodeListChangesRequest,
+			encodeListChangesResponse,
+		),
+
+		watchUserDetails: endpoints.WatchUserDetails,
 	}
 }
 
@@ -55,7 +161,13 @@ func decodeSetUserDetailsRequest(_ context.Context, request interface{}) (interf
 		MobileNumber: setDetails.GetMobileNumber(),
 		Married:      setDetails.GetMarried(),
 		Height:       setDetails.GetHeight(),
-		Weigth:       setDetails.GetWeight(),
+		Weight:       setDetails.GetWeight(),
+		Attributes:   attributesFromProto(setDetails.GetAttributes()),
+		Revision:     entities.AnyRevision,
+	}
+
+	if setDetails.GetConditional() {
+		req.Revision = setDetails.GetRevision()
 	}
 
 	return req, nil
@@ -82,8 +194,39 @@ func decodeGetUserDetailsRequest(_ context.Context, request interface{}) (interf
 
 func encodeGetUserDetailsResponse(_ context.Context, response interface{}) (interface{}, error) {
 	res := response.(GetUserDetailsResponse)
-	return &detailspb.GetUserDetailsResponse{Country: res.Country, City: res.City, MobileNumber: res.MobileNumber,
-		Married: res.Married, Height: res.Height, Weight: res.Weight}, nil
+	return &detailspb.GetUserDetailsResponse{Country: res.Country, City: res.City, MobileNumber: res.MobileNumber, MobileVerified: res.MobileVerified,
+		Married: res.Married, Height: res.Height, Weight: res.Weight, Attributes: attributesToProto(res.Attributes), Avatar: res.Avatar, Revision: res.Revision}, nil
+}
+
+func decodeBatchGetUserDetailsRequest(_ context.Context, request interface{}) (interface{}, error) {
+	batchDetails, ok := request.(*detailspb.BatchGetUserDetailsRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'BatchGetUserDetailsRequest'")
+	}
+
+	ids := make([]int, len(batchDetails.GetUserIds()))
+	for i, id := range batchDetails.GetUserIds() {
+		ids[i] = int(id)
+	}
+
+	return BatchGetUserDetailsRequest{UserIDs: ids}, nil
+}
+
+func encodeBatchGetUserDetailsResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(BatchGetUserDetailsResponse)
+
+	results := make([]*detailspb.UserDetailsResult, len(res.Results))
+	for i, r := range res.Results {
+		results[i] = &detailspb.UserDetailsResult{UserId: uint32(r.UserID), Found: r.Found}
+		if r.Found {
+			d := r.Details
+			results[i].Details = &detailspb.GetUserDetailsResponse{Country: d.Country, City: d.City, MobileNumber: d.MobileNumber, MobileVerified: d.MobileVerified,
+				Married: d.Married, Height: d.Height, Weight: d.Weight, Attributes: attributesToProto(d.Attributes), Avatar: d.Avatar}
+		}
+	}
+
+	return &detailspb.BatchGetUserDetailsResponse{Results: results}, nil
 }
 
 func decodeDeleteUserDetails(_ context.Context, request interface{}) (interface{}, error) {
@@ -105,16 +248,281 @@ func encodeDeleteUserDetails(_ context.Context, response interface{}) (interface
 	return &detailspb.DeleteUserDetailsResponse{Success: res.Success}, nil
 }
 
+func decodeSetAttributeDefinitionRequest(_ context.Context, request interface{}) (interface{}, error) {
+	setDefinition, ok := request.(*detailspb.SetAttributeDefinitionRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'SetAttributeDefinitionRequest'")
+	}
+
+	req := SetAttributeDefinitionRequest{
+		Definition: definitionFromProto(setDefinition.GetDefinition()),
+	}
+
+	return req, nil
+}
+
+func encodeSetAttributeDefinitionResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(SetAttributeDefinitionResponse)
+	return &detailspb.SetAttributeDefinitionResponse{Success: res.Success}, nil
+}
+
+func decodeGetAttributeSchemaRequest(_ context.Context, request interface{}) (interface{}, error) {
+	if _, ok := request.(*detailspb.GetAttributeSchemaRequest); !ok {
+		return nil, errors.New("no proto message 'GetAttributeSchemaRequest'")
+	}
+
+	return GetAttributeSchemaRequest{}, nil
+}
+
+func encodeGetAttributeSchemaResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(GetAttributeSchemaResponse)
+	definitions := make([]*detailspb.AttributeDefinition, len(res.Definitions))
+
+	for i, d := range res.Definitions {
+		definitions[i] = definitionToProto(d)
+	}
+
+	return &detailspb.GetAttributeSchemaResponse{Definitions: definitions}, nil
+}
+
+func decodeDeleteAttributeDefinitionRequest(_ context.Context, request interface{}) (interface{}, error) {
+	deleteDefinition, ok := request.(*detailspb.DeleteAttributeDefinitionRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'DeleteAttributeDefinitionRequest'")
+	}
+
+	req := DeleteAttributeDefinitionRequest{
+		Name: deleteDefinition.GetName(),
+	}
+
+	return req, nil
+}
+
+func encodeDeleteAttributeDefinitionResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(DeleteAttributeDefinitionResponse)
+	return &detailspb.DeleteAttributeDefinitionResponse{Success: res.Success}, nil
+}
+
+func decodeAddAddressRequest(_ context.Context, request interface{}) (interface{}, error) {
+	addAddress, ok := request.(*detailspb.AddAddressRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'AddAddressRequest'")
+	}
+
+	req := AddAddressRequest{
+		UserID:  int(addAddress.GetUserId()),
+		Address: addressFromProto(addAddress.GetAddress()),
+	}
+
+	return req, nil
+}
+
+func encodeAddAddressResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(AddAddressResponse)
+	return &detailspb.AddAddressResponse{Address: addressToProto(res.Address)}, nil
+}
+
+func decodeListAddressesRequest(_ context.Context, request interface{}) (interface{}, error) {
+	listAddresses, ok := request.(*detailspb.ListAddressesRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'ListAddressesRequest'")
+	}
+
+	req := ListAddressesRequest{
+		UserID: int(listAddresses.GetUserId()),
+	}
+
+	return req, nil
+}
+
+func encodeListAddressesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(ListAddressesResponse)
+	addresses := make([]*detailspb.Address, len(res.Addresses))
+
+	for i, a := range res.Addresses {
+		addresses[i] = addressToProto(a)
+	}
+
+	return &detailspb.ListAddressesResponse{Addresses: addresses}, nil
+}
+
+func decodeGetAddressRequest(_ context.Context, request interface{}) (interface{}, error) {
+	getAddress, ok := request.(*detailspb.GetAddressRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'GetAddressRequest'")
+	}
+
+	req := GetAddressRequest{
+		UserID:    int(getAddress.GetUserId()),
+		AddressID: getAddress.GetAddressId(),
+	}
+
+	return req, nil
+}
+
+func encodeGetAddressResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(GetAddressResponse)
+	return &detailspb.GetAddressResponse{Address: addressToProto(res.Address)}, nil
+}
+
+func decodeUpdateAddressRequest(_ context.Context, request interface{}) (interface{}, error) {
+	updateAddress, ok := request.(*detailspb.UpdateAddressRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'UpdateAddressRequest'")
+	}
+
+	req := UpdateAddressRequest{
+		UserID:  int(updateAddress.GetUserId()),
+		Address: addressFromProto(updateAddress.GetAddress()),
+	}
+
+	return req, nil
+}
+
+func encodeUpdateAddressResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(UpdateAddressResponse)
+	return &detailspb.UpdateAddressResponse{Address: addressToProto(res.Address)}, nil
+}
+
+func decodeDeleteAddressRequest(_ context.Context, request interface{}) (interface{}, error) {
+	deleteAddress, ok := request.(*detailspb.DeleteAddressRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'DeleteAddressRequest'")
+	}
+
+	req := DeleteAddressRequest{
+		UserID:    int(deleteAddress.GetUserId()),
+		AddressID: deleteAddress.GetAddressId(),
+	}
+
+	return req, nil
+}
+
+func encodeDeleteAddressResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(DeleteAddressResponse)
+	return &detailspb.DeleteAddressResponse{Success: res.Success}, nil
+}
+
+func decodeSendPhoneVerificationRequest(_ context.Context, request interface{}) (interface{}, error) {
+	sendVerification, ok := request.(*detailspb.SendPhoneVerificationRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'SendPhoneVerificationRequest'")
+	}
+
+	req := SendPhoneVerificationRequest{
+		UserID: int(sendVerification.GetUserId()),
+	}
+
+	return req, nil
+}
+
+func encodeSendPhoneVerificationResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(SendPhoneVerificationResponse)
+	return &detailspb.SendPhoneVerificationResponse{Success: res.Success}, nil
+}
+
+func decodeVerifyPhoneRequest(_ context.Context, request interface{}) (interface{}, error) {
+	verifyPhone, ok := request.(*detailspb.VerifyPhoneRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'VerifyPhoneRequest'")
+	}
+
+	req := VerifyPhoneRequest{
+		UserID: int(verifyPhone.GetUserId()),
+		Code:   verifyPhone.GetCode(),
+	}
+
+	return req, nil
+}
+
+func encodeVerifyPhoneResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(VerifyPhoneResponse)
+	return &detailspb.VerifyPhoneResponse{Success: res.Success}, nil
+}
+
+func decodeSetAvatarRequest(_ context.Context, request interface{}) (interface{}, error) {
+	setAvatar, ok := request.(*detailspb.SetAvatarRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'SetAvatarRequest'")
+	}
+
+	req := SetAvatarRequest{
+		UserID: int(setAvatar.GetUserId()),
+		Avatar: setAvatar.GetAvatar(),
+	}
+
+	return req, nil
+}
+
+func encodeSetAvatarResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(SetAvatarResponse)
+	return &detailspb.SetAvatarResponse{Success: res.Success}, nil
+}
+
+func decodeListChangesRequest(_ context.Context, request interface{}) (interface{}, error) {
+	listChanges, ok := request.(*detailspb.ListDetailsChangesRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'ListDetailsChangesRequest'")
+	}
+
+	req := ListChangesRequest{
+		Cursor:   listChanges.GetCursor(),
+		PageSize: int(listChanges.GetPageSize()),
+	}
+
+	return req, nil
+}
+
+func encodeListChangesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(ListChangesResponse)
+
+	changes := make([]*detailspb.DetailsChange, len(res.Changes))
+	for i, c := range res.Changes {
+		change, err := detailsChangeToProto(c)
+		if err != nil {
+			return nil, err
+		}
+		changes[i] = change
+	}
+
+	return &detailspb.ListDetailsChangesResponse{Changes: changes, NextCursor: res.NextCursor}, nil
+}
+
+func detailsChangeToProto(c events.Change) (*detailspb.DetailsChange, error) {
+	var data []byte
+	if len(c.Data) > 0 {
+		var err error
+		if data, err = json.Marshal(c.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	return &detailspb.DetailsChange{
+		Id:         c.ID,
+		Type:       string(c.Type),
+		UserId:     uint32(c.UserID),
+		OccurredAt: c.OccurredAt.UTC().Format(time.RFC3339Nano),
+		Data:       string(data),
+		Cursor:     c.Cursor,
+	}, nil
+}
+
 func (g *gRPCServer) SetUserDetails(ctx context.Context, req *detailspb.SetUserDetailsRequest) (*detailspb.SetUserDetailsResponse, error) {
 	_, res, err := g.setUserDetails.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*detailspb.SetUserDetailsResponse), nil
@@ -124,28 +532,166 @@ func (g *gRPCServer) GetUserDetails(ctx context.Context, req *detailspb.GetUserD
 	_, res, err := g.getUserDetails.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*detailspb.GetUserDetailsResponse), nil
 }
 
+func (g *gRPCServer) BatchGetUserDetails(ctx context.Context, req *detailspb.BatchGetUserDetailsRequest) (*detailspb.BatchGetUserDetailsResponse, error) {
+	_, res, err := g.batchGetUserDetails.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.BatchGetUserDetailsResponse), nil
+}
+
 func (g *gRPCServer) DeleteUserDetails(ctx context.Context, req *detailspb.DeleteUserDetailsRequest) (*detailspb.DeleteUserDetailsResponse, error) {
 	_, res, err := g.deleteUserDetails.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*detailspb.DeleteUserDetailsResponse), nil
 }
+
+func (g *gRPCServer) SetAttributeDefinition(ctx context.Context, req *detailspb.SetAttributeDefinitionRequest) (*detailspb.SetAttributeDefinitionResponse, error) {
+	_, res, err := g.setAttributeDefinition.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.SetAttributeDefinitionResponse), nil
+}
+
+func (g *gRPCServer) GetAttributeSchema(ctx context.Context, req *detailspb.GetAttributeSchemaRequest) (*detailspb.GetAttributeSchemaResponse, error) {
+	_, res, err := g.getAttributeSchema.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.GetAttributeSchemaResponse), nil
+}
+
+func (g *gRPCServer) DeleteAttributeDefinition(ctx context.Context, req *detailspb.DeleteAttributeDefinitionRequest) (*detailspb.DeleteAttributeDefinitionResponse, error) {
+	_, res, err := g.deleteAttributeDefinition.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.DeleteAttributeDefinitionResponse), nil
+}
+
+func (g *gRPCServer) AddAddress(ctx context.Context, req *detailspb.AddAddressRequest) (*detailspb.AddAddressResponse, error) {
+	_, res, err := g.addAddress.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.AddAddressResponse), nil
+}
+
+func (g *gRPCServer) ListAddresses(ctx context.Context, req *detailspb.ListAddressesRequest) (*detailspb.ListAddressesResponse, error) {
+	_, res, err := g.listAddresses.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.ListAddressesResponse), nil
+}
+
+func (g *gRPCServer) GetAddress(ctx context.Context, req *detailspb.GetAddressRequest) (*detailspb.GetAddressResponse, error) {
+	_, res, err := g.getAddress.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.GetAddressResponse), nil
+}
+
+func (g *gRPCServer) UpdateAddress(ctx context.Context, req *detailspb.UpdateAddressRequest) (*detailspb.UpdateAddressResponse, error) {
+	_, res, err := g.updateAddress.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.UpdateAddressResponse), nil
+}
+
+func (g *gRPCServer) DeleteAddress(ctx context.Context, req *detailspb.DeleteAddressRequest) (*detailspb.DeleteAddressResponse, error) {
+	_, res, err := g.deleteAddress.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.DeleteAddressResponse), nil
+}
+
+func (g *gRPCServer) SendPhoneVerification(ctx context.Context, req *detailspb.SendPhoneVerificationRequest) (*detailspb.SendPhoneVerificationResponse, error) {
+	_, res, err := g.sendPhoneVerification.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.SendPhoneVerificationResponse), nil
+}
+
+func (g *gRPCServer) VerifyPhone(ctx context.Context, req *detailspb.VerifyPhoneRequest) (*detailspb.VerifyPhoneResponse, error) {
+	_, res, err := g.verifyPhone.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.VerifyPhoneResponse), nil
+}
+
+func (g *gRPCServer) SetAvatar(ctx context.Context, req *detailspb.SetAvatarRequest) (*detailspb.SetAvatarResponse, error) {
+	_, res, err := g.setAvatar.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.SetAvatarResponse), nil
+}
+
+func (g *gRPCServer) ListChanges(ctx context.Context, req *detailspb.ListDetailsChangesRequest) (*detailspb.ListDetailsChangesResponse, error) {
+	_, res, err := g.listChanges.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*detailspb.ListDetailsChangesResponse), nil
+}
+
+func (g *gRPCServer) WatchUserDetails(req *detailspb.WatchUserDetailsRequest, stream detailspb.UserDetailsService_WatchUserDetailsServer) error {
+	send := func(c events.Change) error {
+		change, err := detailsChangeToProto(c)
+		if err != nil {
+			return err
+		}
+		return stream.Send(change)
+	}
+
+	_, err := g.watchUserDetails(stream.Context(), WatchUserDetailsRequest{UserID: int(req.GetUserId()), Cursor: req.GetCursor(), Send: send})
+
+	if err != nil {
+		return grpcError.LocalizedStatus(stream.Context(), err).Err()
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package transport
 import (
 	"context"
 
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,8 +14,8 @@ type GrpcUserDetailsSrvMock struct {
 }
 
 // SetUserDetails is a mock of the real method
-func (g *GrpcUserDetailsSrvMock) SetUserDetails(ctx context.Context, userID int, country string, city string, number string, married bool, height float32, weigth float32) (bool, error) {
-	args := g.Called(ctx, userID, country, city, number, married, height, weigth)
+func (g *GrpcUserDetailsSrvMock) SetUserDetails(ctx context.Context, userID int, country string, city string, number string, married bool, height float32, weigth float32, attributes map[string]interface{}, revision int64) (bool, error) {
+	args := g.Called(ctx, userID, country, city, number, married, height, weigth, attributes, revision)
 
 	return args.Bool(0), args.Error(1)
 }
@@ -26,9 +27,107 @@ func (g *GrpcUserDetailsSrvMock) GetUserDetails(ctx context.Context, userID int)
 	return args.Get(0).(entities.UserDetails), args.Error(1)
 }
 
+// BatchGetUserDetails is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) BatchGetUserDetails(ctx context.Context, userIDs []int) ([]entities.UserDetailsResult, error) {
+	args := g.Called(ctx, userIDs)
+
+	return args.Get(0).([]entities.UserDetailsResult), args.Error(1)
+}
+
 // DeleteUserDetails is a mock of the real method
 func (g *GrpcUserDetailsSrvMock) DeleteUserDetails(ctx context.Context, userID int) (bool, error) {
 	args := g.Called(ctx, userID)
 
 	return args.Bool(0), args.Error(1)
 }
+
+// SetAttributeDefinition is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) SetAttributeDefinition(ctx context.Context, definition entities.AttributeDefinition) (bool, error) {
+	args := g.Called(ctx, definition)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// GetAttributeSchema is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) GetAttributeSchema(ctx context.Context) ([]entities.AttributeDefinition, error) {
+	args := g.Called(ctx)
+
+	return args.Get(0).([]entities.AttributeDefinition), args.Error(1)
+}
+
+// DeleteAttributeDefinition is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) DeleteAttributeDefinition(ctx context.Context, name string) (bool, error) {
+	args := g.Called(ctx, name)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// AddAddress is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) AddAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	args := g.Called(ctx, userID, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// ListAddresses is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) ListAddresses(ctx context.Context, userID int) ([]entities.Address, error) {
+	args := g.Called(ctx, userID)
+
+	return args.Get(0).([]entities.Address), args.Error(1)
+}
+
+// GetAddress is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) GetAddress(ctx context.Context, userID int, addressID string) (entities.Address, error) {
+	args := g.Called(ctx, userID, addressID)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// UpdateAddress is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) UpdateAddress(ctx context.Context, userID int, address entities.Address) (entities.Address, error) {
+	args := g.Called(ctx, userID, address)
+
+	return args.Get(0).(entities.Address), args.Error(1)
+}
+
+// DeleteAddress is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) DeleteAddress(ctx context.Context, userID int, addressID string) (bool, error) {
+	args := g.Called(ctx, userID, addressID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// SendPhoneVerification is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) SendPhoneVerification(ctx context.Context, userID int) (bool, error) {
+	args := g.Called(ctx, userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// VerifyPhone is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) VerifyPhone(ctx context.Context, userID int, code string) (bool, error) {
+	args := g.Called(ctx, userID, code)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// SetAvatar is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) SetAvatar(ctx context.Context, userID int, avatar string) (bool, error) {
+	args := g.Called(ctx, userID, avatar)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// ListChanges is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) ListChanges(ctx context.Context, cursor string, pageSize int) ([]events.Change, string, error) {
+	args := g.Called(ctx, cursor, pageSize)
+
+	return args.Get(0).([]events.Change), args.String(1), args.Error(2)
+}
+
+// WatchUserDetails is a mock of the real method
+func (g *GrpcUserDetailsSrvMock) WatchUserDetails(ctx context.Context, userID int, cursor string, send func(events.Change) error) error {
+	args := g.Called(ctx, userID, cursor, send)
+
+	return args.Error(0)
+}
@@ -3,26 +3,92 @@ package transport_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_details_srv/detailspb"
 	"github.com/mauricioww/user_microsrv/user_details_srv/entities"
 	"github.com/mauricioww/user_microsrv/user_details_srv/transport"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// fakeWatchStream collects the changes sent over a WatchUserDetails stream
+type fakeWatchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*detailspb.DetailsChange
+}
+
+func (f *fakeWatchStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeWatchStream) Send(c *detailspb.DetailsChange) error {
+	f.sent = append(f.sent, c)
+	return nil
+}
+
+// grpcStatus stores the literal code, message, reason and field violations expected from a failed call
+type grpcStatus struct {
+	code       codes.Code
+	message    string
+	reason     string
+	violations map[string]string
+}
+
+// assertStatus checks the error against the expected status, a nil status expects no error
+func assertStatus(assert *assert.Assertions, expected *grpcStatus, err error) {
+	if expected == nil {
+		assert.NoError(err)
+		return
+	}
+
+	st, ok := status.FromError(err)
+	if !assert.True(ok, "expected a status error, got %v", err) {
+		return
+	}
+
+	var reason string
+	var violations map[string]string
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			assert.Equal("user_microsrv", d.Domain)
+			reason = d.Reason
+		case *errdetails.BadRequest:
+			violations = make(map[string]string)
+			for _, v := range d.FieldViolations {
+				violations[v.Field] = v.Description
+			}
+		}
+	}
+
+	assert.Equal(expected.code, st.Code())
+	assert.Equal(expected.message, st.Message())
+	assert.Equal(expected.reason, reason)
+	assert.Equal(expected.violations, violations)
+}
+
 func TestSetUserDetails(t *testing.T) {
 	srv := new(transport.GrpcUserDetailsSrvMock)
 	endpoints := transport.MakeGrpcEndpoints(srv)
 	service := transport.NewGrpcUserDetailsServer(endpoints)
 
 	testCases := []struct {
-		testName string
-		data     *detailspb.SetUserDetailsRequest
-		res      *detailspb.SetUserDetailsResponse
-		srvRes   bool
-		err      error
+		testName   string
+		data       *detailspb.SetUserDetailsRequest
+		attributes map[string]interface{}
+		revision   int64
+		res        *detailspb.SetUserDetailsResponse
+		srvRes     bool
+		srvErr     error
+		err        *grpcStatus
 	}{
 		{
 			testName: "set details success",
@@ -35,8 +101,26 @@ func TestSetUserDetails(t *testing.T) {
 				Height:       1.75,
 				Weight:       76.0,
 			},
-			srvRes: true,
-			err:    nil,
+			revision: entities.AnyRevision,
+			srvRes:   true,
+			err:      nil,
+		},
+		{
+			testName: "set details with attributes success",
+			data: &detailspb.SetUserDetailsRequest{
+				UserId: 1,
+				Attributes: map[string]*detailspb.Value{
+					"nickname":  {Kind: &detailspb.Value_StringValue{StringValue: "mau"}},
+					"legal_age": {Kind: &detailspb.Value_IntValue{IntValue: 21}},
+				},
+			},
+			attributes: map[string]interface{}{
+				"nickname":  "mau",
+				"legal_age": int64(21),
+			},
+			revision: entities.AnyRevision,
+			srvRes:   true,
+			err:      nil,
 		},
 		{
 			testName: "update details success",
@@ -46,8 +130,50 @@ func TestSetUserDetails(t *testing.T) {
 				Married:      false,
 				Height:       1.75,
 			},
-			srvRes: true,
-			err:    nil,
+			revision: entities.AnyRevision,
+			srvRes:   true,
+			err:      nil,
+		}, {
+			testName: "invalid details error",
+			data: &detailspb.SetUserDetailsRequest{
+				UserId: 1,
+				Height: -1.8,
+				Weight: 10,
+			},
+			revision: entities.AnyRevision,
+			srvErr: errors.NewInvalidRequestError(
+				errors.FieldViolation{Field: "height", Description: "must be at least 0.5"},
+				errors.FieldViolation{Field: "weight", Description: "must be at least 20"},
+			),
+			err: &grpcStatus{
+				code:       codes.FailedPrecondition,
+				message:    "Invalid request 'height': must be at least 0.5, 'weight': must be at least 20",
+				reason:     "INVALID_REQUEST",
+				violations: map[string]string{"height": "must be at least 0.5", "weight": "must be at least 20"},
+			},
+		},
+		{
+			testName: "conditional set details success",
+			data: &detailspb.SetUserDetailsRequest{
+				UserId:      1,
+				Country:     "Mexico",
+				Conditional: true,
+				Revision:    3,
+			},
+			revision: 3,
+			srvRes:   true,
+			err:      nil,
+		},
+		{
+			testName: "modified details error",
+			data: &detailspb.SetUserDetailsRequest{
+				UserId:      1,
+				Country:     "Chile",
+				Conditional: true,
+			},
+			revision: 0,
+			srvErr:   errors.NewDetailsModifiedError(),
+			err:      &grpcStatus{code: codes.Aborted, message: "User details were modified, fetch them again", reason: "DETAILS_MODIFIED"},
 		},
 	}
 	for _, tc := range testCases {
@@ -65,12 +191,12 @@ func TestSetUserDetails(t *testing.T) {
 
 			// act
 			srv.On("SetUserDetails", ctx, int(tc.data.GetUserId()), tc.data.GetCountry(), tc.data.GetCity(),
-				tc.data.GetMobileNumber(), tc.data.GetMarried(), tc.data.GetHeight(), tc.data.GetWeight()).Return(tc.srvRes, tc.err)
+				tc.data.GetMobileNumber(), tc.data.GetMarried(), tc.data.GetHeight(), tc.data.GetWeight(), tc.attributes, tc.revision).Return(tc.srvRes, tc.srvErr)
 			res, err := service.SetUserDetails(ctx, tc.data)
 
 			// assert
 			assert.Equal(tc.res, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
@@ -86,12 +212,12 @@ func TestGetUserDetails(t *testing.T) {
 		res      *detailspb.GetUserDetailsResponse
 		srvRes   entities.UserDetails
 		srvErr   error
-		err      error
+		err      *grpcStatus
 	}{
 		{
 			testName: "get details success",
 			data: &detailspb.GetUserDetailsRequest{
-				UserId: 0,
+				UserId: 3,
 			},
 			srvRes: entities.UserDetails{
 				Country:      "Mexico",
@@ -100,6 +226,7 @@ func TestGetUserDetails(t *testing.T) {
 				Married:      false,
 				Height:       1.75,
 				Weight:       76.0,
+				Revision:     4,
 			},
 			srvErr: nil,
 		},
@@ -109,6 +236,7 @@ func TestGetUserDetails(t *testing.T) {
 				UserId: 1,
 			},
 			srvErr: errors.NewUserNotFoundError(),
+			err:    &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
 		},
 	}
 	for _, tc := range testCases {
@@ -118,11 +246,9 @@ func TestGetUserDetails(t *testing.T) {
 			ctx := context.Background()
 			if tc.srvErr != nil {
 				tc.res = nil
-				e, _ := tc.srvErr.(errors.ErrorResolver)
-				tc.err = status.Error(e.GrpcCode(), tc.srvErr.Error())
 			} else {
 				tc.res = &detailspb.GetUserDetailsResponse{Country: tc.srvRes.Country, City: tc.srvRes.City, MobileNumber: tc.srvRes.MobileNumber,
-					Married: tc.srvRes.Married, Height: tc.srvRes.Height, Weight: tc.srvRes.Weight}
+					Married: tc.srvRes.Married, Height: tc.srvRes.Height, Weight: tc.srvRes.Weight, Revision: tc.srvRes.Revision}
 			}
 
 			// act
@@ -131,7 +257,71 @@ func TestGetUserDetails(t *testing.T) {
 
 			// assert
 			assert.Equal(tc.res, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestBatchGetUserDetails(t *testing.T) {
+	srv := new(transport.GrpcUserDetailsSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srv)
+	service := transport.NewGrpcUserDetailsServer(endpoints)
+
+	testCases := []struct {
+		testName string
+		data     *detailspb.BatchGetUserDetailsRequest
+		res      *detailspb.BatchGetUserDetailsResponse
+		srvRes   []entities.UserDetailsResult
+		srvErr   error
+		err      *grpcStatus
+	}{
+		{
+			testName: "batch get details success",
+			data: &detailspb.BatchGetUserDetailsRequest{
+				UserIds: []uint32{2, 4},
+			},
+			srvRes: []entities.UserDetailsResult{
+				{UserID: 2, Found: true, Details: entities.UserDetails{UserID: 2, Country: "Mexico", City: "CDMX", Height: 1.75}},
+				{UserID: 4, Found: false},
+			},
+			srvErr: nil,
+		},
+		{
+			testName: "invalid batch error",
+			data: &detailspb.BatchGetUserDetailsRequest{
+				UserIds: []uint32{},
+			},
+			srvErr: errors.NewInvalidBatchError(),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid batch, expected between 1 and 100 ids", reason: "INVALID_BATCH"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			ids := make([]int, len(tc.data.GetUserIds()))
+			for i, id := range tc.data.GetUserIds() {
+				ids[i] = int(id)
+			}
+			if tc.srvErr != nil {
+				tc.res = nil
+			} else {
+				tc.res = &detailspb.BatchGetUserDetailsResponse{
+					Results: []*detailspb.UserDetailsResult{
+						{UserId: 2, Found: true, Details: &detailspb.GetUserDetailsResponse{Country: "Mexico", City: "CDMX", Height: 1.75}},
+						{UserId: 4, Found: false},
+					},
+				}
+			}
+
+			// act
+			srv.On("BatchGetUserDetails", ctx, ids).Return(tc.srvRes, tc.srvErr)
+			res, err := service.BatchGetUserDetails(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
@@ -147,12 +337,12 @@ func TestDeleteUserDetails(t *testing.T) {
 		res      *detailspb.DeleteUserDetailsResponse
 		srvRes   bool
 		srvErr   error
-		err      error
+		err      *grpcStatus
 	}{
 		{
 			testName: "delete details success",
 			data: &detailspb.DeleteUserDetailsRequest{
-				UserId: 0,
+				UserId: 3,
 			},
 			srvRes: true,
 			srvErr: nil,
@@ -163,6 +353,7 @@ func TestDeleteUserDetails(t *testing.T) {
 				UserId: 1,
 			},
 			srvErr: errors.NewUserNotFoundError(),
+			err:    &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
 		},
 	}
 	for _, tc := range testCases {
@@ -172,8 +363,6 @@ func TestDeleteUserDetails(t *testing.T) {
 			ctx := context.Background()
 			if tc.srvErr != nil {
 				tc.res = nil
-				c, _ := tc.srvErr.(errors.ErrorResolver)
-				tc.err = status.Error(c.GrpcCode(), tc.srvErr.Error())
 			} else {
 				tc.res = &detailspb.DeleteUserDetailsResponse{Success: tc.srvRes}
 			}
@@ -184,7 +373,277 @@ func TestDeleteUserDetails(t *testing.T) {
 
 			// assert
 			assert.Equal(tc.res, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestSetAttributeDefinition(t *testing.T) {
+	srv := new(transport.GrpcUserDetailsSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srv)
+	service := transport.NewGrpcUserDetailsServer(endpoints)
+
+	max := 120.0
+
+	testCases := []struct {
+		testName   string
+		data       *detailspb.SetAttributeDefinitionRequest
+		definition entities.AttributeDefinition
+		res        *detailspb.SetAttributeDefinitionResponse
+		srvRes     bool
+		srvErr     error
+		err        *grpcStatus
+	}{
+		{
+			testName: "set attribute definition success",
+			data: &detailspb.SetAttributeDefinitionRequest{
+				Definition: &detailspb.AttributeDefinition{
+					Name:     "legal_age",
+					Type:     detailspb.AttributeType_INT,
+					Required: true,
+					Constraints: &detailspb.AttributeConstraints{
+						Max: &max,
+					},
+				},
+			},
+			definition: entities.AttributeDefinition{
+				Name:     "legal_age",
+				Type:     entities.IntAttribute,
+				Required: true,
+				Constraints: entities.AttributeConstraints{
+					Max: &max,
+				},
+			},
+			srvRes: true,
+			srvErr: nil,
+		},
+		{
+			testName: "unsupported type error",
+			data: &detailspb.SetAttributeDefinitionRequest{
+				Definition: &detailspb.AttributeDefinition{
+					Name: "nickname",
+				},
+			},
+			definition: entities.AttributeDefinition{
+				Name: "nickname",
+			},
+			srvErr: errors.NewInvalidAttributeError("nickname", "unsupported type ''"),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid custom attribute 'nickname': unsupported type ''", reason: "INVALID_ATTRIBUTE", violations: map[string]string{"attributes.nickname": "unsupported type ''"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			if tc.srvErr != nil {
+				tc.res = nil
+			} else {
+				tc.res = &detailspb.SetAttributeDefinitionResponse{Success: tc.srvRes}
+			}
+
+			// act
+			srv.On("SetAttributeDefinition", ctx, tc.definition).Return(tc.srvRes, tc.srvErr)
+			res, err := service.SetAttributeDefinition(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestAddAddress(t *testing.T) {
+	srv := new(transport.GrpcUserDetailsSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srv)
+	service := transport.NewGrpcUserDetailsServer(endpoints)
+
+	testCases := []struct {
+		testName string
+		data     *detailspb.AddAddressRequest
+		address  entities.Address
+		res      *detailspb.AddAddressResponse
+		srvRes   entities.Address
+		srvErr   error
+		err      *grpcStatus
+	}{
+		{
+			testName: "add address success",
+			data: &detailspb.AddAddressRequest{
+				UserId: 1,
+				Address: &detailspb.Address{
+					Lines:       []string{"Av. Reforma 222"},
+					Locality:    "CDMX",
+					CountryCode: "MX",
+					Label:       "work",
+				},
+			},
+			address: entities.Address{
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+				Label:       "work",
+			},
+			srvRes: entities.Address{
+				ID:          "61b0c0f1e4b0a1a2b3c4d5e6",
+				UserID:      1,
+				Lines:       []string{"Av. Reforma 222"},
+				Locality:    "CDMX",
+				CountryCode: "MX",
+				Label:       "work",
+				Primary:     true,
+			},
+			srvErr: nil,
+		},
+		{
+			testName: "user not found error",
+			data: &detailspb.AddAddressRequest{
+				UserId: 2,
+			},
+			srvErr: errors.NewUserNotFoundError(),
+			err:    &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			if tc.srvErr != nil {
+				tc.res = nil
+			} else {
+				tc.res = &detailspb.AddAddressResponse{
+					Address: &detailspb.Address{
+						Id:          tc.srvRes.ID,
+						Lines:       tc.srvRes.Lines,
+						Locality:    tc.srvRes.Locality,
+						CountryCode: tc.srvRes.CountryCode,
+						Label:       tc.srvRes.Label,
+						Primary:     tc.srvRes.Primary,
+					},
+				}
+			}
+
+			// act
+			srv.On("AddAddress", ctx, int(tc.data.GetUserId()), tc.address).Return(tc.srvRes, tc.srvErr)
+			res, err := service.AddAddress(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestListChanges(t *testing.T) {
+	srv := new(transport.GrpcUserDetailsSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srv)
+	service := transport.NewGrpcUserDetailsServer(endpoints)
+
+	occurredAt := time.Date(2022, 3, 1, 10, 30, 0, 250000000, time.UTC)
+
+	testCases := []struct {
+		testName string
+		data     *detailspb.ListDetailsChangesRequest
+		res      *detailspb.ListDetailsChangesResponse
+		err      *grpcStatus
+		srvRes   []events.Change
+		srvNext  string
+		srvErr   error
+	}{
+		{
+			testName: "list changes success",
+			data: &detailspb.ListDetailsChangesRequest{
+				Cursor:   "c0",
+				PageSize: 10,
+			},
+			srvRes: []events.Change{
+				{Event: events.Event{ID: "a", Type: events.DetailsChanged, UserID: 3, OccurredAt: occurredAt, Data: map[string]interface{}{"active": false}}, Cursor: "c1"},
+			},
+			srvNext: "c1",
+			res: &detailspb.ListDetailsChangesResponse{
+				Changes: []*detailspb.DetailsChange{
+					{Id: "a", Type: "DetailsChanged", UserId: 3, OccurredAt: "2022-03-01T10:30:00.25Z", Data: `{"active":false}`, Cursor: "c1"},
+				},
+				NextCursor: "c1",
+			},
+		},
+		{
+			testName: "missing tenant error",
+			data:     &detailspb.ListDetailsChangesRequest{},
+			srvRes:   []events.Change{},
+			srvErr:   errors.NewMissingTenantError(),
+			err:      &grpcStatus{code: codes.FailedPrecondition, message: "Missing or invalid tenant", reason: "MISSING_TENANT"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+
+			// act
+			srv.On("ListChanges", ctx, tc.data.GetCursor(), int(tc.data.GetPageSize())).Return(tc.srvRes, tc.srvNext, tc.srvErr)
+			res, err := service.ListChanges(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestWatchUserDetails(t *testing.T) {
+	occurredAt := time.Date(2022, 3, 1, 10, 30, 0, 250000000, time.UTC)
+
+	testCases := []struct {
+		testName string
+		data     *detailspb.WatchUserDetailsRequest
+		res      []*detailspb.DetailsChange
+		err      *grpcStatus
+		srvRes   []events.Change
+		srvErr   error
+	}{
+		{
+			testName: "watch user details success",
+			data:     &detailspb.WatchUserDetailsRequest{UserId: 3, Cursor: "c1"},
+			srvRes: []events.Change{
+				{Event: events.Event{ID: "a", Type: events.DetailsChanged, UserID: 3, OccurredAt: occurredAt, Data: map[string]interface{}{"active": false}}, Cursor: "c2"},
+			},
+			res: []*detailspb.DetailsChange{
+				{Id: "a", Type: "DetailsChanged", UserId: 3, OccurredAt: "2022-03-01T10:30:00.25Z", Data: `{"active":false}`, Cursor: "c2"},
+			},
+		},
+		{
+			testName: "invalid cursor error",
+			data:     &detailspb.WatchUserDetailsRequest{UserId: 3, Cursor: "unknown"},
+			srvErr:   errors.NewInvalidCursorError(),
+			err:      &grpcStatus{code: codes.FailedPrecondition, message: "Invalid or unknown cursor", reason: "INVALID_CURSOR"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			srv := new(transport.GrpcUserDetailsSrvMock)
+			endpoints := transport.MakeGrpcEndpoints(srv)
+			service := transport.NewGrpcUserDetailsServer(endpoints)
+			stream := &fakeWatchStream{ctx: context.Background()}
+
+			// act
+			srv.On("WatchUserDetails", stream.ctx, int(tc.data.GetUserId()), tc.data.GetCursor(), mock.Anything).Run(func(args mock.Arguments) {
+				send := args.Get(3).(func(events.Change) error)
+				for _, c := range tc.srvRes {
+					_ = send(c)
+				}
+			}).Return(tc.srvErr)
+			err := service.WatchUserDetails(tc.data, stream)
+
+			// assert
+			assert.Equal(tc.res, stream.sent)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
@@ -1,10 +1,21 @@
 package entities
 
-// User struct stores the basic information
+import "time"
+
+// User struct stores the basic information, Age is computed from DateOfBirth at read time
 type User struct {
-	Email    string
-	Password string
-	Age      int
+	ID          int
+	Email       string
+	Password    string
+	DateOfBirth time.Time
+	Age         int
+}
+
+// UserResult struct stores the user of one of the ids of a batch, Found is false when the user does not exist
+type UserResult struct {
+	ID    int
+	Found bool
+	User  User
 }
 
 // Session struct stores credentials to do a login
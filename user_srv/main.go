@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/grpcweb"
+	"github.com/mauricioww/user_microsrv/guard"
+	"github.com/mauricioww/user_microsrv/locale"
+	"github.com/mauricioww/user_microsrv/recovery"
+	"github.com/mauricioww/user_microsrv/tenant"
 	"github.com/mauricioww/user_microsrv/user_srv/repository"
 	"github.com/mauricioww/user_microsrv/user_srv/service"
 	"github.com/mauricioww/user_microsrv/user_srv/transport"
@@ -48,7 +57,7 @@ func main() {
 	var db *sql.DB
 	{
 		var err error
-		mysqlAddr := fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", cts.DbUser, cts.DbPwd, cts.DbHost, cts.DbPort, cts.DbName)
+		mysqlAddr := fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=true", cts.DbUser, cts.DbPwd, cts.DbHost, cts.DbPort, cts.DbName)
 		db, err = sql.Open("mysql", mysqlAddr)
 		if err != nil {
 			level.Error(logger).Log("exit", err)
@@ -59,7 +68,19 @@ func main() {
 	var srv service.GrpcUserServicer
 	{
 		mysqlRepo := repository.NewUserRepository(db, logger)
-		srv = service.NewGrpcUserService(mysqlRepo, logger)
+		srv = service.NewGrpcUserService(mysqlRepo, cts.MinimumAge, logger)
+	}
+
+	{
+		bus, err := events.NewBus(cts.EventBus, cts.NatsURL)
+		if err != nil {
+			level.Error(logger).Log("exit", err)
+			os.Exit(-1)
+		}
+		defer bus.Close()
+
+		relay := events.NewRelay(repository.NewOutbox(db), bus, cts.OutboxInterval, 0, cts.OutboxRetention, logger)
+		go relay.Run(context.Background())
 	}
 
 	errs := make(chan error)
@@ -79,15 +100,27 @@ func main() {
 		os.Exit(-1)
 	}
 
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(recovery.UnaryServerInterceptor(logger), locale.UnaryServerInterceptor(), tenant.UnaryServerInterceptor(),
+			guard.UnaryServerInterceptor(cts.InternalToken, "/UserService/PurgeUser")),
+		grpc.ChainStreamInterceptor(recovery.StreamServerInterceptor(logger), locale.StreamServerInterceptor(), tenant.StreamServerInterceptor()),
+	)
+	userpb.RegisterUserServiceServer(server, grpcServer)
+
 	go func() {
-		server := grpc.NewServer()
-		userpb.RegisterUserServiceServer(server, grpcServer)
 		if err := server.Serve(listener); err != nil {
 			logger.Log("Error serving", err)
 		}
 		level.Info(logger).Log("info", "grpc server started")
 	}()
 
+	// the browsers and curl call the exposed methods over gRPC-Web and Connect, the port stays closed unless it is set
+	if cts.WebPort != "" {
+		go func() {
+			errs <- http.ListenAndServe(":"+cts.WebPort, grpcweb.NewHandler(server, cts.WebOrigins, cts.WebMethods))
+		}()
+	}
+
 	level.Error(logger).Log("exit: ", <-errs)
 }
 
@@ -97,4 +130,20 @@ type constants struct {
 	DbHost string `env:"DB_HOST,required"`
 	DbPort int    `env:"DB_PORT" envDefault:"3306"`
 	DbName string `env:"DB_NAME" envDefault:"grpc_user"`
+
+	MinimumAge int `env:"MINIMUM_AGE" envDefault:"13"`
+
+	// InternalToken is shared with the HTTP server, the purges of users are rejected without it
+	InternalToken string `env:"INTERNAL_TOKEN,required"`
+
+	EventBus        string        `env:"EVENT_BUS,required"`
+	NatsURL         string        `env:"NATS_URL" envDefault:"nats://nats:4222"`
+	OutboxInterval  time.Duration `env:"OUTBOX_INTERVAL" envDefault:"1s"`
+	OutboxRetention time.Duration `env:"OUTBOX_RETENTION" envDefault:"720h"`
+
+	// the gRPC-Web server has no authentication of its own, it only starts when WEB_PORT is set and serves the
+	// methods of WEB_METHODS, e.g. /UserService/GetUser, to the browsers of WEB_ORIGINS
+	WebPort    string   `env:"WEB_PORT"`
+	WebOrigins []string `env:"WEB_ORIGINS" envSeparator:","`
+	WebMethods []string `env:"WEB_METHODS" envSeparator:","`
 }
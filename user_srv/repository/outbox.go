@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/user_srv/entities"
+)
+
+const (
+	insertEventSQL = `
+		INSERT INTO OUTBOX(event_id, tenant_id, type, user_id, payload, occurred_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	pendingEventsSQL = `
+		SELECT o.payload FROM OUTBOX o
+			WHERE o.published_at IS NULL
+			ORDER BY o.id LIMIT ?
+	`
+
+	lockSequenceSQL = `
+		SELECT s.seq FROM FEED_SEQUENCE s
+			WHERE s.id = 1 FOR UPDATE
+	`
+
+	markPublishedSQL = `
+		UPDATE OUTBOX SET published_at = CURRENT_TIMESTAMP(3), position = ?
+			WHERE event_id = ? AND position IS NULL
+	`
+
+	updateSequenceSQL = `
+		UPDATE FEED_SEQUENCE SET seq = ?
+			WHERE id = 1
+	`
+
+	pruneEventsSQL = `
+		DELETE FROM OUTBOX
+			WHERE published_at IS NOT NULL AND published_at < ?
+			LIMIT ?
+	`
+)
+
+// pruneBatch is the number of rows removed by each delete of Prune, which keeps the locks short
+const pruneBatch = 1000
+
+// eventDateLayout is the layout of the dates sent within the events
+const eventDateLayout = "2006-01-02"
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertEvent writes the event to the outbox, it must run within the transaction of the data change
+func insertEvent(ctx context.Context, tx execer, e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, insertEventSQL, e.ID, e.Tenant, e.Type, e.UserID, payload, e.OccurredAt)
+	return err
+}
+
+// userEventData returns the public fields of the user sent within the events, the password is never sent
+func userEventData(user entities.User) map[string]interface{} {
+	data := map[string]interface{}{
+		"email": user.Email,
+	}
+
+	if !user.DateOfBirth.IsZero() {
+		data["date_of_birth"] = user.DateOfBirth.Format(eventDateLayout)
+	}
+
+	return data
+}
+
+// Outbox implements the events.Outbox interface over the OUTBOX table
+type Outbox struct {
+	db *sql.DB
+}
+
+// NewOutbox returns an Outbox pointer type
+func NewOutbox(mysqlDb *sql.DB) *Outbox {
+	return &Outbox{
+		db: mysqlDb,
+	}
+}
+
+// Pending fetchs the oldest events which were not published yet, of every tenant
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]events.Event, error) {
+	rows, err := o.db.QueryContext(ctx, pendingEventsSQL, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []events.Event
+	for rows.Next() {
+		var payload []byte
+		if err := rows.Scan(&payload); err != nil {
+			return nil, err
+		}
+
+		var e events.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
+
+// MarkPublished flags the events as published and gives them the next positions of the changes feed, they are
+// kept as the history of changes until they are pruned. The positions are assigned while the sequence row is
+// locked, so the relays of every instance write them one transaction after the other, and only to events whose
+// data change already committed: once a position is visible every lower position is visible too
+func (o *Outbox) MarkPublished(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var seq int64
+	if err := tx.QueryRowContext(ctx, lockSequenceSQL).Scan(&seq); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		res, err := tx.ExecContext(ctx, markPublishedSQL, seq+1, id)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		// the events published again after a failure keep their first position
+		seq += n
+	}
+
+	if _, err := tx.ExecContext(ctx, updateSequenceSQL, seq); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Prune removes the events published before the given time, they leave the history of changes
+func (o *Outbox) Prune(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	for {
+		res, err := o.db.ExecContext(ctx, pruneEventsSQL, before, pruneBatch)
+		if err != nil {
+			return total, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if n < pruneBatch {
+			return total, nil
+		}
+	}
+}
@@ -3,37 +3,85 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/go-kit/log"
+	"github.com/go-sql-driver/mysql"
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
+	"github.com/mauricioww/user_microsrv/tenant"
 	"github.com/mauricioww/user_microsrv/user_srv/entities"
 )
 
 const (
 	createUserSQL = `
-		INSERT INTO USERS(email, pwd_hash, age)
-			VALUES (?, ?, ?)
+		INSERT INTO USERS(tenant_id, email, pwd_hash, date_of_birth)
+			VALUES (?, ?, ?, ?)
 	`
 
 	authenticateSQL = `
-		SELECT u.pwd_hash FROM USERS u WHERE u.email = ?
+		SELECT u.pwd_hash FROM USERS u
+			WHERE u.tenant_id = ? AND u.email = ?
 	`
 
 	updateUserSQL = `
-		UPDATE USERS SET email = ?, pwd_hash = ?, age = ?
-			WHERE id = ? 
+		UPDATE USERS SET email = ?, pwd_hash = ?, date_of_birth = ?
+			WHERE tenant_id = ? AND id = ?
 	`
 
 	getUserByIDSQL = `
-		SELECT u.email, u.pwd_hash, u.age
-			FROM USERS u WHERE u.id = ? AND u.active = true
+		SELECT u.email, u.pwd_hash, u.date_of_birth
+			FROM USERS u WHERE u.tenant_id = ? AND u.id = ? AND u.active = true
+	`
+
+	batchGetUsersSQL = `
+		SELECT u.id, u.email, u.date_of_birth
+			FROM USERS u WHERE u.tenant_id = ? AND u.active = true AND u.id IN (%v)
+	`
+
+	takenEmailsSQL = `
+		SELECT u.email FROM USERS u WHERE u.tenant_id = ? AND u.email IN (%v)
+	`
+
+	listUsersSQL = `
+		SELECT u.id, u.email, u.date_of_birth
+			FROM USERS u WHERE u.tenant_id = ? AND u.id > ? AND u.active = true
+			ORDER BY u.id LIMIT ?
+	`
+
+	listChangesSQL = `
+		SELECT o.position, o.payload FROM OUTBOX o
+			WHERE o.tenant_id = ? AND o.position > ?
+			ORDER BY o.position LIMIT ?
+	`
+
+	listUserChangesSQL = `
+		SELECT o.position, o.payload FROM OUTBOX o
+			WHERE o.tenant_id = ? AND o.user_id = ? AND o.position > ?
+			ORDER BY o.position LIMIT ?
+	`
+
+	lastChangeSQL = `
+		SELECT COALESCE(MAX(o.position), 0) FROM OUTBOX o
+			WHERE o.tenant_id = ?
 	`
 
 	softDeleteUserSQL = `
 		UPDATE USERS SET active = false
-			WHERE id = ?	`
+			WHERE tenant_id = ? AND id = ?
+	`
+
+	purgeUserSQL = `
+		DELETE FROM USERS
+			WHERE tenant_id = ? AND id = ?
+	`
 )
 
+// duplicateEntry is the MySQL error number raised when the unique (tenant_id, email) key is violated
+const duplicateEntry = 1062
+
 // UserRepositorier describes the methods used to do DB operations
 type UserRepositorier interface {
 	CreateUser(ctx context.Context, user entities.User) (int, error)
@@ -41,6 +89,13 @@ type UserRepositorier interface {
 	UpdateUser(ctx context.Context, information entities.Update) (entities.User, error)
 	GetUser(ctx context.Context, id int) (entities.User, error)
 	DeleteUser(ctx context.Context, id int) (bool, error)
+	ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, error)
+	BatchGetUsers(ctx context.Context, ids []int) ([]entities.User, error)
+	TakenEmails(ctx context.Context, emails []string) ([]string, error)
+	PurgeUser(ctx context.Context, id int) (bool, error)
+	ListChanges(ctx context.Context, after int64, limit int) ([]events.Change, error)
+	ListUserChanges(ctx context.Context, id int, after int64, limit int) ([]events.Change, error)
+	LastChangePosition(ctx context.Context) (int64, error)
 }
 
 // UserRepository implements the UserRepositorier interface
@@ -57,15 +112,40 @@ func NewUserRepository(mysqlDb *sql.DB, l log.Logger) *UserRepository {
 	}
 }
 
-// CreateUser does the DB operation to create a new user with the given data
+// CreateUser does the DB operation to create a new user with the given data, the UserCreated event
+// is written to the outbox within the same transaction
 func (r *UserRepository) CreateUser(ctx context.Context, user entities.User) (int, error) {
-	id, err := r.db.ExecContext(ctx, createUserSQL, user.Email, user.Password, user.Age)
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return -1, errors.NewMissingTenantError()
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, errors.NewInternalError()
+	}
+	defer tx.Rollback()
+
+	id, err := tx.ExecContext(ctx, createUserSQL, t, user.Email, user.Password, user.DateOfBirth)
+
+	if e, ok := err.(*mysql.MySQLError); ok && e.Number == duplicateEntry {
+		return -1, errors.NewUserAlreadyExistsError()
+	}
 
 	if err != nil {
 		return -1, errors.NewInternalError()
 	}
 
 	n, _ := id.LastInsertId()
+
+	if err := insertEvent(ctx, tx, events.New(events.UserCreated, t, int(n), userEventData(user))); err != nil {
+		return -1, errors.NewInternalError()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return -1, errors.NewInternalError()
+	}
+
 	return int(n), nil
 }
 
@@ -73,7 +153,12 @@ func (r *UserRepository) CreateUser(ctx context.Context, user entities.User) (in
 func (r *UserRepository) Authenticate(ctx context.Context, session *entities.Session) (string, error) {
 	var hash string
 
-	err := r.db.QueryRow(authenticateSQL, session.Email).Scan(&hash)
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", errors.NewMissingTenantError()
+	}
+
+	err := r.db.QueryRowContext(ctx, authenticateSQL, t, session.Email).Scan(&hash)
 
 	if err == sql.ErrNoRows {
 		return "", errors.NewUserNotFoundError()
@@ -86,19 +171,47 @@ func (r *UserRepository) Authenticate(ctx context.Context, session *entities.Ses
 	return hash, nil
 }
 
-// UpdateUser replaces the current information within DB with the given data
+// UpdateUser replaces the current information within DB with the given data, the UserUpdated event
+// is written to the outbox within the same transaction
 func (r *UserRepository) UpdateUser(ctx context.Context, update entities.Update) (entities.User, error) {
 	var u entities.User
 
-	if err := r.db.QueryRow(getUserByIDSQL, update.UserID).Scan(); err == sql.ErrNoRows {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return u, errors.NewMissingTenantError()
+	}
+
+	if err := r.db.QueryRowContext(ctx, getUserByIDSQL, t, update.UserID).Scan(); err == sql.ErrNoRows {
 		return u, errors.NewUserNotFoundError()
 	}
 
-	if _, err := r.db.ExecContext(ctx, updateUserSQL, update.Email, update.Password, update.Age, update.UserID); err != nil {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return u, errors.NewInternalError()
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, updateUserSQL, update.Email, update.Password, update.DateOfBirth, t, update.UserID)
+
+	if e, ok := err.(*mysql.MySQLError); ok && e.Number == duplicateEntry {
+		return u, errors.NewUserAlreadyExistsError()
+	}
+
+	if err != nil {
+		return u, errors.NewInternalError()
+	}
+
+	if err := insertEvent(ctx, tx, events.New(events.UserUpdated, t, update.UserID, userEventData(update.User))); err != nil {
+		return u, errors.NewInternalError()
+	}
+
+	if err := tx.Commit(); err != nil {
 		return u, errors.NewInternalError()
 	}
 
-	_ = r.db.QueryRow(getUserByIDSQL, update.UserID).Scan(&u.Email, &u.Password, &u.Age)
+	var dob sql.NullTime
+	_ = r.db.QueryRowContext(ctx, getUserByIDSQL, t, update.UserID).Scan(&u.Email, &u.Password, &dob)
+	u.DateOfBirth = dob.Time
 	return u, nil
 }
 
@@ -106,7 +219,13 @@ func (r *UserRepository) UpdateUser(ctx context.Context, update entities.Update)
 func (r *UserRepository) GetUser(ctx context.Context, id int) (entities.User, error) {
 	var u entities.User
 
-	err := r.db.QueryRow(getUserByIDSQL, id).Scan(&u.Email, &u.Password, &u.Age)
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return u, errors.NewMissingTenantError()
+	}
+
+	var dob sql.NullTime
+	err := r.db.QueryRowContext(ctx, getUserByIDSQL, t, id).Scan(&u.Email, &u.Password, &dob)
 
 	if err == sql.ErrNoRows {
 		return entities.User{}, errors.NewUserNotFoundError()
@@ -116,19 +235,256 @@ func (r *UserRepository) GetUser(ctx context.Context, id int) (entities.User, er
 		return entities.User{}, errors.NewInternalError()
 	}
 
+	u.DateOfBirth = dob.Time
 	return u, nil
 }
 
-// DeleteUser does a soft delete operation over a specific user
+// DeleteUser does a soft delete operation over a specific user, the UserDeleted event
+// is written to the outbox within the same transaction
 func (r *UserRepository) DeleteUser(ctx context.Context, id int) (bool, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
 
-	if err := r.db.QueryRow(getUserByIDSQL, id).Scan(); err == sql.ErrNoRows {
+	if err := r.db.QueryRowContext(ctx, getUserByIDSQL, t, id).Scan(); err == sql.ErrNoRows {
 		return false, errors.NewUserNotFoundError()
 	}
 
-	if _, err := r.db.ExecContext(ctx, softDeleteUserSQL, id); err != nil {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, softDeleteUserSQL, t, id); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if err := insertEvent(ctx, tx, events.New(events.UserDeleted, t, id, nil)); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if err := tx.Commit(); err != nil {
 		return false, errors.NewInternalError()
 	}
 
 	return true, nil
 }
+
+// ListUsers fetchs the active users of the tenant whose ID is greater than afterID, ordered by ID
+func (r *UserRepository) ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	rows, err := r.db.QueryContext(ctx, listUsersSQL, t, afterID, limit)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+	defer rows.Close()
+
+	var res []entities.User
+	for rows.Next() {
+		var u entities.User
+		var dob sql.NullTime
+
+		if err := rows.Scan(&u.ID, &u.Email, &dob); err != nil {
+			return nil, errors.NewInternalError()
+		}
+
+		u.DateOfBirth = dob.Time
+		res = append(res, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return res, nil
+}
+
+// BatchGetUsers fetchs the active users with the given ids in one query, the missing ids are left out
+func (r *UserRepository) BatchGetUsers(ctx context.Context, ids []int) ([]entities.User, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, t)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	query := fmt.Sprintf(batchGetUsersSQL, strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+	defer rows.Close()
+
+	var res []entities.User
+	for rows.Next() {
+		var u entities.User
+		var dob sql.NullTime
+
+		if err := rows.Scan(&u.ID, &u.Email, &dob); err != nil {
+			return nil, errors.NewInternalError()
+		}
+
+		u.DateOfBirth = dob.Time
+		res = append(res, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return res, nil
+}
+
+// TakenEmails returns the given emails which already belong to a user of the tenant, the inactive users
+// keep their email since the unique key covers every row
+func (r *UserRepository) TakenEmails(ctx context.Context, emails []string) ([]string, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	args := make([]interface{}, 0, len(emails)+1)
+	args = append(args, t)
+	for _, e := range emails {
+		args = append(args, e)
+	}
+
+	query := fmt.Sprintf(takenEmailsSQL, strings.TrimSuffix(strings.Repeat("?, ", len(emails)), ", "))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+	defer rows.Close()
+
+	var res []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, errors.NewInternalError()
+		}
+		res = append(res, email)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return res, nil
+}
+
+// PurgeUser removes the row of a specific user, active or not, it is used to undo a creation,
+// the UserDeleted event is written to the outbox within the same transaction
+func (r *UserRepository) PurgeUser(ctx context.Context, id int) (bool, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return false, errors.NewMissingTenantError()
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, purgeUserSQL, t, id)
+	if err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, errors.NewUserNotFoundError()
+	}
+
+	if err := insertEvent(ctx, tx, events.New(events.UserDeleted, t, id, map[string]interface{}{"purged": true})); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.NewInternalError()
+	}
+
+	return true, nil
+}
+
+// ListChanges fetchs the published events of the tenant after the given position, the outbox keeps the
+// published events so it is the history of changes of the users
+func (r *UserRepository) ListChanges(ctx context.Context, after int64, limit int) ([]events.Change, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	rows, err := r.db.QueryContext(ctx, listChangesSQL, t, after, limit)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return scanChanges(rows)
+}
+
+// ListUserChanges fetchs the published events of one user of the tenant after the given position
+func (r *UserRepository) ListUserChanges(ctx context.Context, id int, after int64, limit int) ([]events.Change, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, errors.NewMissingTenantError()
+	}
+
+	rows, err := r.db.QueryContext(ctx, listUserChangesSQL, t, id, after, limit)
+	if err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return scanChanges(rows)
+}
+
+// LastChangePosition fetchs the position of the newest published event of the tenant, zero when there is none
+func (r *UserRepository) LastChangePosition(ctx context.Context) (int64, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return 0, errors.NewMissingTenantError()
+	}
+
+	var position int64
+	if err := r.db.QueryRowContext(ctx, lastChangeSQL, t).Scan(&position); err != nil {
+		return 0, errors.NewInternalError()
+	}
+
+	return position, nil
+}
+
+func scanChanges(rows *sql.Rows) ([]events.Change, error) {
+	defer rows.Close()
+
+	var res []events.Change
+	for rows.Next() {
+		var position int64
+		var payload []byte
+
+		if err := rows.Scan(&position, &payload); err != nil {
+			return nil, errors.NewInternalError()
+		}
+
+		var e events.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, errors.NewInternalError()
+		}
+
+		res = append(res, events.Change{Event: e, Cursor: events.EncodeCursor(position)})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError()
+	}
+
+	return res, nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mauricioww/user_microsrv/errors"
+)
+
+// DateLayout is the format used to exchange the date of birth
+const DateLayout = "2006-01-02"
+
+// parseDateOfBirth returns the date of birth as UTC midnight, dates in the future are rejected
+func parseDateOfBirth(value string, now time.Time) (time.Time, error) {
+	dob, err := time.Parse(DateLayout, strings.TrimSpace(value))
+	if err != nil || dob.After(now) {
+		return time.Time{}, errors.NewInvalidDateOfBirthError()
+	}
+
+	return dob, nil
+}
+
+// ageAt returns the complete years lived from the date of birth until the given moment
+func ageAt(dob time.Time, now time.Time) int {
+	if dob.IsZero() {
+		return 0
+	}
+
+	now = now.UTC()
+	age := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		age--
+	}
+
+	return age
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/helpers"
 	"github.com/mauricioww/user_microsrv/user_srv/entities"
 	"github.com/mauricioww/user_microsrv/user_srv/repository"
@@ -13,29 +15,49 @@ import (
 
 // GrpcUserServicer describe the business logic used to do validations and operations
 type GrpcUserServicer interface {
-	CreateUser(ctx context.Context, email string, pwd string, age int) (int, error)
+	CreateUser(ctx context.Context, email string, pwd string, dateOfBirth string) (int, error)
 	Authenticate(ctx context.Context, email string, pwd string) (bool, error)
-	UpdateUser(ctx context.Context, id int, email string, pwd string, age int) (bool, error)
+	UpdateUser(ctx context.Context, id int, email string, pwd string, dateOfBirth string) (bool, error)
 	GetUser(ctx context.Context, id int) (entities.User, error)
 	DeleteUser(ctx context.Context, id int) (bool, error)
+	ListUsers(ctx context.Context, afterID int, pageSize int) ([]entities.User, int, error)
+	BatchGetUsers(ctx context.Context, ids []int) ([]entities.UserResult, error)
+	CheckEmails(ctx context.Context, emails []string) ([]string, error)
+	PurgeUser(ctx context.Context, id int) (bool, error)
+	ListChanges(ctx context.Context, cursor string, pageSize int) ([]events.Change, string, error)
+	WatchUser(ctx context.Context, id int, cursor string, send func(events.Change) error) error
 }
 
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	maxBatchSize    = 100
+)
+
+const (
+	// watchInterval is how often a watch looks for new changes of the user
+	watchInterval = time.Second
+	watchPageSize = 100
+)
+
 // GrpcUserService implements the GrpcUserServicer interface
 type GrpcUserService struct {
 	repository repository.UserRepositorier
+	minimumAge int
 	logger     log.Logger
 }
 
-// NewGrpcUserService returns a GrpcUserService pointer type
-func NewGrpcUserService(r repository.UserRepositorier, l log.Logger) *GrpcUserService {
+// NewGrpcUserService returns a GrpcUserService pointer type, users under minimumAge are not allowed to sign up
+func NewGrpcUserService(r repository.UserRepositorier, minimumAge int, l log.Logger) *GrpcUserService {
 	return &GrpcUserService{
 		repository: r,
+		minimumAge: minimumAge,
 		logger:     l,
 	}
 }
 
-// CreateUser does the both email and password validations and send the data to repository layer
-func (g *GrpcUserService) CreateUser(ctx context.Context, email string, pwd string, age int) (int, error) {
+// CreateUser does the email, password and date of birth validations and send the data to repository layer
+func (g *GrpcUserService) CreateUser(ctx context.Context, email string, pwd string, dateOfBirth string) (int, error) {
 	logger := log.With(g.logger, "method", "create_user")
 
 	if email == "" {
@@ -50,12 +72,25 @@ func (g *GrpcUserService) CreateUser(ctx context.Context, email string, pwd stri
 		return -1, e
 	}
 
+	now := time.Now()
+	dob, err := parseDateOfBirth(dateOfBirth, now)
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return -1, err
+	}
+
+	if ageAt(dob, now) < g.minimumAge {
+		e := errors.NewUnderMinimumAgeError(g.minimumAge)
+		level.Error(logger).Log("validation: ", e)
+		return -1, e
+	}
+
 	cipheredPwd := helpers.Cipher(pwd)
 
 	user := entities.User{
-		Email:    email,
-		Password: cipheredPwd,
-		Age:      age,
+		Email:       email,
+		Password:    cipheredPwd,
+		DateOfBirth: dob,
 	}
 
 	res, err := g.repository.CreateUser(ctx, user)
@@ -107,8 +142,8 @@ func (g *GrpcUserService) Authenticate(ctx context.Context, email string, pwd st
 	return true, nil
 }
 
-// UpdateUser does the both email and password validations and send the data to repository layer
-func (g *GrpcUserService) UpdateUser(ctx context.Context, id int, email string, pwd string, age int) (bool, error) {
+// UpdateUser does the email, password and date of birth validations and send the data to repository layer
+func (g *GrpcUserService) UpdateUser(ctx context.Context, id int, email string, pwd string, dateOfBirth string) (bool, error) {
 	logger := log.With(g.logger, "method", "update_user")
 
 	if email == "" {
@@ -123,14 +158,27 @@ func (g *GrpcUserService) UpdateUser(ctx context.Context, id int, email string,
 		return false, e
 	}
 
+	now := time.Now()
+	dob, err := parseDateOfBirth(dateOfBirth, now)
+	if err != nil {
+		level.Error(logger).Log("validation: ", err)
+		return false, err
+	}
+
+	if ageAt(dob, now) < g.minimumAge {
+		e := errors.NewUnderMinimumAgeError(g.minimumAge)
+		level.Error(logger).Log("validation: ", e)
+		return false, e
+	}
+
 	cipheredPwd := helpers.Cipher(pwd)
 
 	updateInfo := entities.Update{
 		UserID: id,
 		User: entities.User{
-			Email:    email,
-			Password: cipheredPwd,
-			Age:      age,
+			Email:       email,
+			Password:    cipheredPwd,
+			DateOfBirth: dob,
 		},
 	}
 
@@ -142,7 +190,7 @@ func (g *GrpcUserService) UpdateUser(ctx context.Context, id int, email string,
 	}
 
 	logger.Log("action", "success")
-	return u.Email == email && helpers.Decipher(u.Password) == pwd && u.Age == age, err
+	return u.Email == email && helpers.Decipher(u.Password) == pwd && u.DateOfBirth.Equal(dob), err
 }
 
 // GetUser receives one ID and send it to repository layer
@@ -158,6 +206,7 @@ func (g *GrpcUserService) GetUser(ctx context.Context, id int) (entities.User, e
 
 	logger.Log("action", "success")
 	res.Password = helpers.Decipher(res.Password)
+	res.Age = ageAt(res.DateOfBirth, time.Now())
 	return res, err
 }
 
@@ -175,3 +224,202 @@ func (g *GrpcUserService) DeleteUser(ctx context.Context, id int) (bool, error)
 	logger.Log("action", "success")
 	return res, err
 }
+
+// ListUsers returns one page of users after the given ID and the ID to request the next page, zero on the last page
+func (g *GrpcUserService) ListUsers(ctx context.Context, afterID int, pageSize int) ([]entities.User, int, error) {
+	logger := log.With(g.logger, "method", "list_users")
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	res, err := g.repository.ListUsers(ctx, afterID, pageSize)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	for i := range res {
+		res[i].Age = ageAt(res[i].DateOfBirth, now)
+	}
+
+	next := 0
+	if len(res) == pageSize {
+		next = res[len(res)-1].ID
+	}
+
+	logger.Log("action", "success")
+	return res, next, nil
+}
+
+// BatchGetUsers returns one result for each of the given ids, in the same order, the ids
+// without an active user are marked as not found instead of failing the batch
+func (g *GrpcUserService) BatchGetUsers(ctx context.Context, ids []int) ([]entities.UserResult, error) {
+	logger := log.With(g.logger, "method", "batch_get_users")
+
+	if len(ids) == 0 || len(ids) > maxBatchSize {
+		e := errors.NewInvalidBatchError()
+		level.Error(logger).Log("validation: ", e)
+		return nil, e
+	}
+
+	users, err := g.repository.BatchGetUsers(ctx, ids)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, err
+	}
+
+	now := time.Now()
+	found := make(map[int]entities.User, len(users))
+	for _, u := range users {
+		u.Age = ageAt(u.DateOfBirth, now)
+		found[u.ID] = u
+	}
+
+	res := make([]entities.UserResult, len(ids))
+	for i, id := range ids {
+		u, ok := found[id]
+		res[i] = entities.UserResult{ID: id, Found: ok, User: u}
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// CheckEmails receives up to maxBatchSize emails and returns the ones already taken within the tenant, it lets
+// an import report the conflicts of its rows without creating them
+func (g *GrpcUserService) CheckEmails(ctx context.Context, emails []string) ([]string, error) {
+	logger := log.With(g.logger, "method", "check_emails")
+
+	if len(emails) == 0 || len(emails) > maxBatchSize {
+		e := errors.NewInvalidBatchError()
+		level.Error(logger).Log("validation: ", e)
+		return nil, e
+	}
+
+	res, err := g.repository.TakenEmails(ctx, emails)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, err
+	}
+
+	logger.Log("action", "success")
+	return res, nil
+}
+
+// PurgeUser receives one ID and send it to repository layer in order to remove the user permanently
+func (g *GrpcUserService) PurgeUser(ctx context.Context, id int) (bool, error) {
+	logger := log.With(g.logger, "method", "purge_user")
+
+	res, err := g.repository.PurgeUser(ctx, id)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return false, err
+	}
+
+	logger.Log("action", "success")
+	return res, err
+}
+
+// ListChanges returns one page of the changes of the users after the cursor and the cursor to request the next page,
+// the same cursor is returned when there are no new changes so it can be polled
+func (g *GrpcUserService) ListChanges(ctx context.Context, cursor string, pageSize int) ([]events.Change, string, error) {
+	logger := log.With(g.logger, "method", "list_changes")
+
+	after, err := events.DecodeCursor(cursor)
+	if err != nil {
+		e := errors.NewInvalidCursorError()
+		level.Error(logger).Log("validation: ", e)
+		return nil, "", e
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	res, err := g.repository.ListChanges(ctx, after, pageSize)
+
+	if err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return nil, "", err
+	}
+
+	next := cursor
+	if len(res) > 0 {
+		next = res[len(res)-1].Cursor
+	}
+
+	logger.Log("action", "success")
+	return res, next, nil
+}
+
+// WatchUser sends the changes of the user after the cursor until the context is done, the empty cursor
+// only sends the changes made from now on. Each change carries the cursor which resumes the watch after it
+func (g *GrpcUserService) WatchUser(ctx context.Context, id int, cursor string, send func(events.Change) error) error {
+	logger := log.With(g.logger, "method", "watch_user")
+
+	after, err := events.DecodeCursor(cursor)
+	if err != nil {
+		e := errors.NewInvalidCursorError()
+		level.Error(logger).Log("validation: ", e)
+		return e
+	}
+
+	if _, err := g.repository.GetUser(ctx, id); err != nil {
+		level.Error(logger).Log("ERROR", err)
+		return err
+	}
+
+	if cursor == "" {
+		if after, err = g.repository.LastChangePosition(ctx); err != nil {
+			level.Error(logger).Log("ERROR", err)
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		changes, err := g.repository.ListUserChanges(ctx, id, after, watchPageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			level.Error(logger).Log("ERROR", err)
+			return err
+		}
+
+		for _, c := range changes {
+			if err := send(c); err != nil {
+				level.Error(logger).Log("ERROR", err)
+				return err
+			}
+			after, _ = events.DecodeCursor(c.Cursor)
+		}
+
+		if len(changes) == watchPageSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Log("action", "success")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
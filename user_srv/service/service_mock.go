@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_srv/entities"
 	"github.com/stretchr/testify/mock"
 )
@@ -46,3 +47,52 @@ func (r *UserRepositoryMock) DeleteUser(ctx context.Context, id int) (bool, erro
 
 	return args.Bool(0), args.Error(1)
 }
+
+// ListUsers is a mock of the real method
+func (r *UserRepositoryMock) ListUsers(ctx context.Context, afterID int, limit int) ([]entities.User, error) {
+	args := r.Called(ctx, afterID, limit)
+
+	return args.Get(0).([]entities.User), args.Error(1)
+}
+
+// BatchGetUsers is a mock of the real method
+func (r *UserRepositoryMock) BatchGetUsers(ctx context.Context, ids []int) ([]entities.User, error) {
+	args := r.Called(ctx, ids)
+
+	return args.Get(0).([]entities.User), args.Error(1)
+}
+
+// TakenEmails is a mock of the real method
+func (r *UserRepositoryMock) TakenEmails(ctx context.Context, emails []string) ([]string, error) {
+	args := r.Called(ctx, emails)
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// PurgeUser is a mock of the real method
+func (r *UserRepositoryMock) PurgeUser(ctx context.Context, id int) (bool, error) {
+	args := r.Called(ctx, id)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// ListChanges is a mock of the real method
+func (r *UserRepositoryMock) ListChanges(ctx context.Context, after int64, limit int) ([]events.Change, error) {
+	args := r.Called(ctx, after, limit)
+
+	return args.Get(0).([]events.Change), args.Error(1)
+}
+
+// ListUserChanges is a mock of the real method
+func (r *UserRepositoryMock) ListUserChanges(ctx context.Context, id int, after int64, limit int) ([]events.Change, error) {
+	args := r.Called(ctx, id, after, limit)
+
+	return args.Get(0).([]events.Change), args.Error(1)
+}
+
+// LastChangePosition is a mock of the real method
+func (r *UserRepositoryMock) LastChangePosition(ctx context.Context) (int64, error) {
+	args := r.Called(ctx)
+
+	return args.Get(0).(int64), args.Error(1)
+}
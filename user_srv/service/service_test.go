@@ -4,9 +4,11 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/helpers"
 	"github.com/mauricioww/user_microsrv/user_srv/entities"
 	"github.com/mauricioww/user_microsrv/user_srv/service"
@@ -18,40 +20,71 @@ func TestCreateUser(t *testing.T) {
 	var srv service.GrpcUserServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserRepositoryMock)
-	srv = service.NewGrpcUserService(repoMock, logger)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
 
 	testCases := []struct {
-		testName string
-		data     entities.User
-		res      int
-		err      error
+		testName    string
+		data        entities.User
+		dateOfBirth string
+		res         int
+		err         error
 	}{
 		{
 			testName: "create user successfully",
 			data: entities.User{
 				Email:    "email@domain.com",
 				Password: "qwerty",
-				Age:      23,
 			},
-			err: nil,
+			dateOfBirth: "1998-05-10",
+			err:         nil,
 		},
 		{
 			testName: "empty email error",
 			data: entities.User{
 				Password: "qwerty",
-				Age:      23,
 			},
-			res: -1,
-			err: errors.NewBadRequestEmailError(),
+			dateOfBirth: "1998-05-10",
+			res:         -1,
+			err:         errors.NewBadRequestEmailError(),
 		},
 		{
 			testName: "empty password error",
 			data: entities.User{
 				Email: "email@domain.com",
-				Age:   23,
 			},
-			res: -1,
-			err: errors.NewBadRequestPasswordError(),
+			dateOfBirth: "1998-05-10",
+			res:         -1,
+			err:         errors.NewBadRequestPasswordError(),
+		},
+		{
+			testName: "malformed date of birth error",
+			data: entities.User{
+				Email:    "email@domain.com",
+				Password: "qwerty",
+			},
+			dateOfBirth: "10/05/1998",
+			res:         -1,
+			err:         errors.NewInvalidDateOfBirthError(),
+		},
+		{
+			testName: "date of birth in the future error",
+			data: entities.User{
+				Email:    "email@domain.com",
+				Password: "qwerty",
+			},
+			dateOfBirth: time.Now().AddDate(0, 0, 2).Format(service.DateLayout),
+			res:         -1,
+			err:         errors.NewInvalidDateOfBirthError(),
+		},
+		{
+			testName: "under minimum age error",
+			data: entities.User{
+				Email:    "email@domain.com",
+				Password: "qwerty",
+			},
+			dateOfBirth: time.Now().AddDate(-18, 0, 1).Format(service.DateLayout),
+			res:         -1,
+			err:         errors.NewUnderMinimumAgeError(18),
 		},
 	}
 
@@ -63,7 +96,7 @@ func TestCreateUser(t *testing.T) {
 
 			// act
 			repoMock.On("CreateUser", ctx, mock.AnythingOfType("entities.User")).Return(tc.res, nil)
-			res, err := srv.CreateUser(ctx, tc.data.Email, tc.data.Password, tc.data.Age)
+			res, err := srv.CreateUser(ctx, tc.data.Email, tc.data.Password, tc.dateOfBirth)
 
 			// assert
 			assert.Equal(tc.res, res)
@@ -76,7 +109,7 @@ func TestAuthenticate(t *testing.T) {
 	var srv service.GrpcUserServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserRepositoryMock)
-	srv = service.NewGrpcUserService(repoMock, logger)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
 
 	testCases := []struct {
 		testName string
@@ -152,7 +185,8 @@ func TestUpdateUser(t *testing.T) {
 	var srv service.GrpcUserServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserRepositoryMock)
-	srv = service.NewGrpcUserService(repoMock, logger)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
+	dob := time.Date(2001, time.May, 10, 0, 0, 0, 0, time.UTC)
 
 	testCases := []struct {
 		testName string
@@ -166,15 +200,15 @@ func TestUpdateUser(t *testing.T) {
 			data: entities.Update{
 				UserID: 0,
 				User: entities.User{
-					Email:    "new_email@domain.com",
-					Password: "new_password",
-					Age:      20,
+					Email:       "new_email@domain.com",
+					Password:    "new_password",
+					DateOfBirth: dob,
 				},
 			},
 			repo_res: entities.User{
-				Email:    "new_email@domain.com",
-				Password: "new_password",
-				Age:      20,
+				Email:       "new_email@domain.com",
+				Password:    "new_password",
+				DateOfBirth: dob,
 			},
 			res: true,
 			err: nil,
@@ -184,8 +218,8 @@ func TestUpdateUser(t *testing.T) {
 			data: entities.Update{
 				UserID: 0,
 				User: entities.User{
-					Email: "new_email@domain.com",
-					Age:   20,
+					Email:       "new_email@domain.com",
+					DateOfBirth: dob,
 				},
 			},
 			res: false,
@@ -196,13 +230,26 @@ func TestUpdateUser(t *testing.T) {
 			data: entities.Update{
 				UserID: 0,
 				User: entities.User{
-					Password: "new_password",
-					Age:      20,
+					Password:    "new_password",
+					DateOfBirth: dob,
 				},
 			},
 			res: false,
 			err: errors.NewBadRequestEmailError(),
 		},
+		{
+			testName: "under minimum age error",
+			data: entities.Update{
+				UserID: 0,
+				User: entities.User{
+					Email:       "new_email@domain.com",
+					Password:    "new_password",
+					DateOfBirth: time.Now().AddDate(-10, 0, 0),
+				},
+			},
+			res: false,
+			err: errors.NewUnderMinimumAgeError(18),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -214,7 +261,7 @@ func TestUpdateUser(t *testing.T) {
 
 			// act
 			repoMock.On("UpdateUser", ctx, mock.Anything).Return(tc.repo_res, tc.err)
-			res, err := srv.UpdateUser(ctx, tc.data.UserID, tc.data.Email, tc.data.Password, tc.data.Age)
+			res, err := srv.UpdateUser(ctx, tc.data.UserID, tc.data.Email, tc.data.Password, tc.data.DateOfBirth.Format(service.DateLayout))
 
 			// assert
 			assert.Equal(tc.err, err)
@@ -227,22 +274,24 @@ func TestGetUser(t *testing.T) {
 	var srv service.GrpcUserServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserRepositoryMock)
-	srv = service.NewGrpcUserService(repoMock, logger)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
 
 	testCases := []struct {
 		testName string
 		data     int
 		res      entities.User
+		age      int
 		err      error
 	}{
 		{
-			testName: "user found",
+			testName: "user found with age computed from date of birth",
 			data:     0,
 			res: entities.User{
-				Email:    "user@email.com",
-				Password: "password",
-				Age:      20,
+				Email:       "user@email.com",
+				Password:    "password",
+				DateOfBirth: time.Now().AddDate(-20, 0, -1),
 			},
+			age: 20,
 			err: nil,
 		},
 		{
@@ -263,6 +312,7 @@ func TestGetUser(t *testing.T) {
 			repoMock.On("GetUser", ctx, tc.data).Return(tc.res, tc.err)
 			res, err := srv.GetUser(ctx, tc.data)
 			tc.res.Password = helpers.Decipher(tc.res.Password)
+			tc.res.Age = tc.age
 
 			// assert
 			assert.Equal(tc.res, res)
@@ -275,7 +325,7 @@ func TestDeleteUser(t *testing.T) {
 	var srv service.GrpcUserServicer
 	logger := log.NewLogfmtLogger(os.Stderr)
 	repoMock := new(service.UserRepositoryMock)
-	srv = service.NewGrpcUserService(repoMock, logger)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
 
 	testCases := []struct {
 		testName string
@@ -313,3 +363,385 @@ func TestDeleteUser(t *testing.T) {
 		})
 	}
 }
+
+func TestListUsers(t *testing.T) {
+	var srv service.GrpcUserServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserRepositoryMock)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
+
+	dob := time.Now().AddDate(-20, 0, -1)
+
+	testCases := []struct {
+		testName string
+		afterID  int
+		pageSize int
+		limit    int
+		res      []entities.User
+		next     int
+		err      error
+	}{
+		{
+			testName: "full page returns the next id",
+			afterID:  0,
+			pageSize: 2,
+			limit:    2,
+			res: []entities.User{
+				{ID: 1, Email: "first@email.com", DateOfBirth: dob},
+				{ID: 4, Email: "second@email.com", DateOfBirth: dob},
+			},
+			next: 4,
+			err:  nil,
+		},
+		{
+			testName: "last page with default page size",
+			afterID:  4,
+			pageSize: 0,
+			limit:    100,
+			res: []entities.User{
+				{ID: 7, Email: "third@email.com", DateOfBirth: dob},
+			},
+			next: 0,
+			err:  nil,
+		},
+		{
+			testName: "missing tenant error",
+			afterID:  7,
+			pageSize: 5000,
+			limit:    1000,
+			err:      errors.NewMissingTenantError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("ListUsers", ctx, tc.afterID, tc.limit).Return(tc.res, tc.err)
+			res, next, err := srv.ListUsers(ctx, tc.afterID, tc.pageSize)
+
+			// assert
+			assert.Equal(tc.next, next)
+			assert.Equal(tc.err, err)
+			assert.Len(res, len(tc.res))
+			for _, u := range res {
+				assert.Equal(20, u.Age)
+			}
+		})
+	}
+}
+
+func TestPurgeUser(t *testing.T) {
+	var srv service.GrpcUserServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserRepositoryMock)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
+
+	testCases := []struct {
+		testName string
+		data     int
+		res      bool
+		err      error
+	}{
+		{
+			testName: "purge user success",
+			data:     3,
+			res:      true,
+			err:      nil,
+		},
+		{
+			testName: "user does not exist error",
+			data:     -3,
+			res:      false,
+			err:      errors.NewUserNotFoundError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("PurgeUser", ctx, tc.data).Return(tc.res, tc.err)
+			res, err := srv.PurgeUser(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestListChanges(t *testing.T) {
+	var srv service.GrpcUserServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserRepositoryMock)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
+
+	testCases := []struct {
+		testName string
+		cursor   string
+		after    int64
+		pageSize int
+		limit    int
+		res      []events.Change
+		next     string
+		err      error
+	}{
+		{
+			testName: "page returns the cursor of the last change",
+			cursor:   "",
+			after:    0,
+			pageSize: 2,
+			limit:    2,
+			res: []events.Change{
+				{Event: events.Event{ID: "a", Type: events.UserCreated, UserID: 1}, Cursor: events.EncodeCursor(3)},
+				{Event: events.Event{ID: "b", Type: events.UserUpdated, UserID: 1}, Cursor: events.EncodeCursor(8)},
+			},
+			next: events.EncodeCursor(8),
+		},
+		{
+			testName: "no new changes keep the cursor",
+			cursor:   events.EncodeCursor(8),
+			after:    8,
+			pageSize: 0,
+			limit:    100,
+			res:      []events.Change{},
+			next:     events.EncodeCursor(8),
+		},
+		{
+			testName: "invalid cursor error",
+			cursor:   "not-a-cursor",
+			err:      errors.NewInvalidCursorError(),
+		},
+		{
+			testName: "missing tenant error",
+			cursor:   events.EncodeCursor(9),
+			after:    9,
+			pageSize: 5000,
+			limit:    1000,
+			res:      []events.Change{},
+			err:      errors.NewMissingTenantError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("ListChanges", ctx, tc.after, tc.limit).Return(tc.res, tc.err)
+			res, next, err := srv.ListChanges(ctx, tc.cursor, tc.pageSize)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Equal(tc.next, next)
+			if tc.err == nil {
+				assert.Equal(tc.res, res)
+			}
+		})
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	var srv service.GrpcUserServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserRepositoryMock)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
+
+	dob := time.Now().AddDate(-20, 0, -1)
+
+	testCases := []struct {
+		testName string
+		ids      []int
+		repoRes  []entities.User
+		repoErr  error
+		found    []bool
+		err      error
+	}{
+		{
+			testName: "batch with a missing user",
+			ids:      []int{4, 2, 9},
+			repoRes: []entities.User{
+				{ID: 2, Email: "second@email.com", DateOfBirth: dob},
+				{ID: 4, Email: "fourth@email.com", DateOfBirth: dob},
+			},
+			found: []bool{true, true, false},
+		},
+		{
+			testName: "empty batch error",
+			ids:      []int{},
+			err:      errors.NewInvalidBatchError(),
+		},
+		{
+			testName: "too many ids error",
+			ids:      make([]int, 101),
+			err:      errors.NewInvalidBatchError(),
+		},
+		{
+			testName: "missing tenant error",
+			ids:      []int{1},
+			repoErr:  errors.NewMissingTenantError(),
+			err:      errors.NewMissingTenantError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("BatchGetUsers", ctx, tc.ids).Return(tc.repoRes, tc.repoErr)
+			res, err := srv.BatchGetUsers(ctx, tc.ids)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Len(res, len(tc.found))
+			for i, r := range res {
+				assert.Equal(tc.ids[i], r.ID)
+				assert.Equal(tc.found[i], r.Found)
+				if r.Found {
+					assert.Equal(tc.ids[i], r.User.ID)
+					assert.Equal(20, r.User.Age)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckEmails(t *testing.T) {
+	var srv service.GrpcUserServicer
+	logger := log.NewLogfmtLogger(os.Stderr)
+	repoMock := new(service.UserRepositoryMock)
+	srv = service.NewGrpcUserService(repoMock, 18, logger)
+
+	testCases := []struct {
+		testName string
+		emails   []string
+		repoRes  []string
+		repoErr  error
+		res      []string
+		err      error
+	}{
+		{
+			testName: "batch with a taken email",
+			emails:   []string{"first@email.com", "second@email.com"},
+			repoRes:  []string{"second@email.com"},
+			res:      []string{"second@email.com"},
+		},
+		{
+			testName: "empty batch error",
+			emails:   []string{},
+			err:      errors.NewInvalidBatchError(),
+		},
+		{
+			testName: "too many emails error",
+			emails:   make([]string, 101),
+			err:      errors.NewInvalidBatchError(),
+		},
+		{
+			testName: "missing tenant error",
+			emails:   []string{"user@email.com"},
+			repoErr:  errors.NewMissingTenantError(),
+			err:      errors.NewMissingTenantError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			ctx := context.Background()
+			assert := assert.New(t)
+
+			// act
+			repoMock.On("TakenEmails", ctx, tc.emails).Return(tc.repoRes, tc.repoErr)
+			res, err := srv.CheckEmails(ctx, tc.emails)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Equal(tc.res, res)
+		})
+	}
+}
+
+func TestWatchUser(t *testing.T) {
+	logger := log.NewLogfmtLogger(os.Stderr)
+	changes := []events.Change{
+		{Event: events.Event{ID: "a", Type: events.UserUpdated, UserID: 1}, Cursor: events.EncodeCursor(13)},
+		{Event: events.Event{ID: "b", Type: events.UserDeleted, UserID: 1}, Cursor: events.EncodeCursor(15)},
+	}
+
+	testCases := []struct {
+		testName string
+		cursor   string
+		last     int64
+		after    int64
+		userErr  error
+		res      []events.Change
+		err      error
+	}{
+		{
+			testName: "resume sends the changes after the cursor",
+			cursor:   events.EncodeCursor(12),
+			after:    12,
+			res:      changes,
+		},
+		{
+			testName: "empty cursor starts after the last change",
+			cursor:   "",
+			last:     12,
+			after:    12,
+			res:      changes,
+		},
+		{
+			testName: "invalid cursor error",
+			cursor:   "not-a-cursor",
+			err:      errors.NewInvalidCursorError(),
+		},
+		{
+			testName: "user not found error",
+			cursor:   "",
+			userErr:  errors.NewUserNotFoundError(),
+			err:      errors.NewUserNotFoundError(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			repoMock := new(service.UserRepositoryMock)
+			srv := service.NewGrpcUserService(repoMock, 18, logger)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var sent []events.Change
+			send := func(c events.Change) error {
+				sent = append(sent, c)
+				if len(sent) == len(tc.res) {
+					cancel()
+				}
+				return nil
+			}
+
+			// act
+			repoMock.On("GetUser", mock.Anything, 1).Return(entities.User{}, tc.userErr)
+			repoMock.On("LastChangePosition", mock.Anything).Return(tc.last, nil)
+			repoMock.On("ListUserChanges", mock.Anything, 1, tc.after, 100).Return(tc.res, nil)
+			err := srv.WatchUser(ctx, 1, tc.cursor, send)
+
+			// assert
+			assert.Equal(tc.err, err)
+			assert.Equal(tc.res, sent)
+		})
+	}
+}
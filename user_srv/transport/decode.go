@@ -1,32 +1,68 @@
 package transport
 
+import "github.com/mauricioww/user_microsrv/events"
+
 // CreateUserRequest stores the data sent to gRPC CreateUser method
 type CreateUserRequest struct {
-	Email    string
-	Password string
-	Age      int
+	Email       string `validate:"required,email,max=254"`
+	Password    string `validate:"required,max=72"`
+	DateOfBirth string `validate:"required,date"`
 }
 
 // AuthenticateRequest stores the data sent to gRPC Authenticate method
 type AuthenticateRequest struct {
-	Email    string
-	Password string
+	Email    string `validate:"required"`
+	Password string `validate:"required"`
 }
 
 // UpdateUserRequest stores the data sent to gRPC UpdateUser method
 type UpdateUserRequest struct {
-	UserID   int
-	Email    string
-	Password string
-	Age      int
+	UserID      int    `validate:"min=1"`
+	Email       string `validate:"required,email,max=254"`
+	Password    string `validate:"required,max=72"`
+	DateOfBirth string `validate:"required,date"`
 }
 
 // GetUserRequest stores the data sent to gRPC GetUser method
 type GetUserRequest struct {
-	UserID int
+	UserID int `validate:"min=1"`
 }
 
 // DeleteUserRequest stores the data sent to gRPC DeleteUser method
 type DeleteUserRequest struct {
-	UserID int
+	UserID int `validate:"min=1"`
+}
+
+// ListUsersRequest stores the data sent to gRPC ListUsers method
+type ListUsersRequest struct {
+	AfterID  int
+	PageSize int
+}
+
+// PurgeUserRequest stores the data sent to gRPC PurgeUser method
+type PurgeUserRequest struct {
+	UserID int `validate:"min=1"`
+}
+
+// BatchGetUsersRequest stores the data sent to gRPC BatchGetUsers method
+type BatchGetUsersRequest struct {
+	UserIDs []int
+}
+
+// CheckEmailsRequest stores the data sent to gRPC CheckEmails method
+type CheckEmailsRequest struct {
+	Emails []string
+}
+
+// WatchUserRequest stores the data sent to gRPC WatchUser method, Send writes one change to the stream
+type WatchUserRequest struct {
+	UserID int `validate:"min=1"`
+	Cursor string
+	Send   func(events.Change) error
+}
+
+// ListChangesRequest stores the data sent to gRPC ListChanges method
+type ListChangesRequest struct {
+	Cursor   string
+	PageSize int
 }
@@ -1,5 +1,7 @@
 package transport
 
+import "github.com/mauricioww/user_microsrv/events"
+
 // CreateUserResponse stores the data that gRPC CreateUser method will return
 type CreateUserResponse struct {
 	UserID int
@@ -17,12 +19,55 @@ type UpdateUserResponse struct {
 
 // GetUserResponse stores the data that gRPC GetUser method will return
 type GetUserResponse struct {
-	Email    string
-	Password string
-	Age      int
+	Email       string
+	Password    string
+	DateOfBirth string
+	Age         int
 }
 
 // DeleteUserResponse stores the data that gRPC DeleteUser method will return
 type DeleteUserResponse struct {
 	Success bool
 }
+
+// ListedUser stores one of the users that gRPC ListUsers method will return
+type ListedUser struct {
+	UserID      int
+	Email       string
+	DateOfBirth string
+	Age         int
+}
+
+// ListUsersResponse stores the data that gRPC ListUsers method will return
+type ListUsersResponse struct {
+	Users       []ListedUser
+	NextAfterID int
+}
+
+// PurgeUserResponse stores the data that gRPC PurgeUser method will return
+type PurgeUserResponse struct {
+	Success bool
+}
+
+// UserResult stores the user of one of the ids that gRPC BatchGetUsers method will return
+type UserResult struct {
+	UserID int
+	Found  bool
+	User   ListedUser
+}
+
+// BatchGetUsersResponse stores the data that gRPC BatchGetUsers method will return
+type BatchGetUsersResponse struct {
+	Results []UserResult
+}
+
+// CheckEmailsResponse stores the data that gRPC CheckEmails method will return
+type CheckEmailsResponse struct {
+	Taken []string
+}
+
+// ListChangesResponse stores the data that gRPC ListChanges method will return
+type ListChangesResponse struct {
+	Changes    []events.Change
+	NextCursor string
+}
@@ -2,9 +2,11 @@ package transport
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/mauricioww/user_microsrv/user_srv/service"
+	"github.com/mauricioww/user_microsrv/validation"
 )
 
 // GrpcEndpoints stores the endpoinst for the current service
@@ -14,23 +16,40 @@ type GrpcEndpoints struct {
 	UpdateUser   endpoint.Endpoint
 	GetUser      endpoint.Endpoint
 	DeleteUser   endpoint.Endpoint
+	ListUsers    endpoint.Endpoint
+	PurgeUser    endpoint.Endpoint
+	ListChanges  endpoint.Endpoint
+
+	BatchGetUsers endpoint.Endpoint
+	WatchUser     endpoint.Endpoint
+	CheckEmails   endpoint.Endpoint
 }
 
-// MakeGrpcEndpoints returns a truct that stores the endpoints of the current service
+// MakeGrpcEndpoints returns a truct that stores the endpoints of the current service, the requests are validated
+// before reaching the service
 func MakeGrpcEndpoints(srv service.GrpcUserServicer) GrpcEndpoints {
+	validate := validation.Middleware()
+
 	return GrpcEndpoints{
-		CreateUser:   makeCreateUserEndpoint(srv),
-		Authenticate: makeAuthenticateEndpoint(srv),
-		UpdateUser:   makeUpdateUserEndpoint(srv),
-		GetUser:      makeGetUserEndpoint(srv),
-		DeleteUser:   makeDeleteUserEndpoint(srv),
+		CreateUser:   validate(makeCreateUserEndpoint(srv)),
+		Authenticate: validate(makeAuthenticateEndpoint(srv)),
+		UpdateUser:   validate(makeUpdateUserEndpoint(srv)),
+		GetUser:      validate(makeGetUserEndpoint(srv)),
+		DeleteUser:   validate(makeDeleteUserEndpoint(srv)),
+		ListUsers:    validate(makeListUsersEndpoint(srv)),
+		PurgeUser:    validate(makePurgeUserEndpoint(srv)),
+		ListChanges:  validate(makeListChangesEndpoint(srv)),
+
+		BatchGetUsers: validate(makeBatchGetUsersEndpoint(srv)),
+		WatchUser:     validate(makeWatchUserEndpoint(srv)),
+		CheckEmails:   validate(makeCheckEmailsEndpoint(srv)),
 	}
 }
 
 func makeCreateUserEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(CreateUserRequest)
-		res, err := srv.CreateUser(ctx, req.Email, req.Password, req.Age)
+		res, err := srv.CreateUser(ctx, req.Email, req.Password, req.DateOfBirth)
 		return CreateUserResponse{UserID: res}, err
 	}
 }
@@ -46,7 +65,7 @@ func makeAuthenticateEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
 func makeUpdateUserEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(UpdateUserRequest)
-		res, err := srv.UpdateUser(ctx, req.UserID, req.Email, req.Password, req.Age)
+		res, err := srv.UpdateUser(ctx, req.UserID, req.Email, req.Password, req.DateOfBirth)
 		return UpdateUserResponse{Success: res}, err
 	}
 }
@@ -55,7 +74,7 @@ func makeGetUserEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(GetUserRequest)
 		res, err := srv.GetUser(ctx, req.UserID)
-		return GetUserResponse{Email: res.Email, Password: res.Password, Age: res.Age}, err
+		return GetUserResponse{Email: res.Email, Password: res.Password, DateOfBirth: formatDate(res.DateOfBirth), Age: res.Age}, err
 	}
 }
 
@@ -66,3 +85,74 @@ func makeDeleteUserEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
 		return DeleteUserResponse{Success: res}, err
 	}
 }
+
+func makeListUsersEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListUsersRequest)
+		res, next, err := srv.ListUsers(ctx, req.AfterID, req.PageSize)
+
+		users := make([]ListedUser, len(res))
+		for i, u := range res {
+			users[i] = ListedUser{UserID: u.ID, Email: u.Email, DateOfBirth: formatDate(u.DateOfBirth), Age: u.Age}
+		}
+
+		return ListUsersResponse{Users: users, NextAfterID: next}, err
+	}
+}
+
+func makePurgeUserEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(PurgeUserRequest)
+		res, err := srv.PurgeUser(ctx, req.UserID)
+		return PurgeUserResponse{Success: res}, err
+	}
+}
+
+func makeListChangesEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListChangesRequest)
+		res, next, err := srv.ListChanges(ctx, req.Cursor, req.PageSize)
+		return ListChangesResponse{Changes: res, NextCursor: next}, err
+	}
+}
+
+func makeBatchGetUsersEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(BatchGetUsersRequest)
+		res, err := srv.BatchGetUsers(ctx, req.UserIDs)
+
+		results := make([]UserResult, len(res))
+		for i, r := range res {
+			results[i] = UserResult{UserID: r.ID, Found: r.Found}
+			if r.Found {
+				results[i].User = ListedUser{UserID: r.User.ID, Email: r.User.Email, DateOfBirth: formatDate(r.User.DateOfBirth), Age: r.User.Age}
+			}
+		}
+
+		return BatchGetUsersResponse{Results: results}, err
+	}
+}
+
+func makeCheckEmailsEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(CheckEmailsRequest)
+		res, err := srv.CheckEmails(ctx, req.Emails)
+		return CheckEmailsResponse{Taken: res}, err
+	}
+}
+
+// makeWatchUserEndpoint returns once the watch ends, the changes are written by the Send function of the request
+func makeWatchUserEndpoint(srv service.GrpcUserServicer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(WatchUserRequest)
+		return nil, srv.WatchUser(ctx, req.UserID, req.Cursor, req.Send)
+	}
+}
+
+// formatDate returns the date using the service layout, unknown dates are sent as an empty string
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(service.DateLayout)
+}
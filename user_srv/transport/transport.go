@@ -2,11 +2,14 @@ package transport
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
+	"github.com/go-kit/kit/endpoint"
 	grpcGokit "github.com/go-kit/kit/transport/grpc"
 	grpcError "github.com/mauricioww/user_microsrv/errors"
-	"google.golang.org/grpc/status"
+	"github.com/mauricioww/user_microsrv/events"
 
 	"github.com/mauricioww/user_microsrv/user_srv/userpb"
 )
@@ -17,6 +20,15 @@ type gRPCServer struct {
 	updateUser   grpcGokit.Handler
 	getUser      grpcGokit.Handler
 	deleteUser   grpcGokit.Handler
+	listUsers    grpcGokit.Handler
+	purgeUser    grpcGokit.Handler
+	listChanges  grpcGokit.Handler
+
+	batchGetUsers grpcGokit.Handler
+	checkEmails   grpcGokit.Handler
+
+	// watchUser is called directly since go-kit has no server for streaming calls
+	watchUser endpoint.Endpoint
 
 	userpb.UnimplementedUserServiceServer
 }
@@ -53,6 +65,38 @@ func NewGrpcUserServer(endpoints GrpcEndpoints) userpb.UserServiceServer {
 			decodeDeleteUserRequest,
 			encondeDeleteUserResponse,
 		),
+
+		listUsers: grpcGokit.NewServer(
+			endpoints.ListUsers,
+			decodeListUsersRequest,
+			encodeListUsersResponse,
+		),
+
+		purgeUser: grpcGokit.NewServer(
+			endpoints.PurgeUser,
+			decodePurgeUserRequest,
+			encodePurgeUserResponse,
+		),
+
+		listChanges: grpcGokit.NewServer(
+			endpoints.ListChanges,
+			decodeListChangesRequest,
+			encodeListChangesResponse,
+		),
+
+		batchGetUsers: grpcGokit.NewServer(
+			endpoints.BatchGetUsers,
+			decodeBatchGetUsersRequest,
+			encodeBatchGetUsersResponse,
+		),
+
+		checkEmails: grpcGokit.NewServer(
+			endpoints.CheckEmails,
+			decodeCheckEmailsRequest,
+			encodeCheckEmailsResponse,
+		),
+
+		watchUser: endpoints.WatchUser,
 	}
 }
 
@@ -64,9 +108,9 @@ func decodeCreateUserRequest(_ context.Context, request interface{}) (interface{
 	}
 
 	req := CreateUserRequest{
-		Email:    createPb.GetEmail(),
-		Password: createPb.GetPassword(),
-		Age:      int(createPb.GetAge()),
+		Email:       createPb.GetEmail(),
+		Password:    createPb.GetPassword(),
+		DateOfBirth: createPb.GetDateOfBirth(),
 	}
 
 	return req, nil
@@ -106,10 +150,10 @@ func decodeUpdateUserRequest(_ context.Context, request interface{}) (interface{
 	}
 
 	req := UpdateUserRequest{
-		UserID:   int(updatePb.GetId()),
-		Email:    updatePb.GetEmail(),
-		Password: updatePb.GetPassword(),
-		Age:      int(updatePb.GetAge()),
+		UserID:      int(updatePb.GetId()),
+		Email:       updatePb.GetEmail(),
+		Password:    updatePb.GetPassword(),
+		DateOfBirth: updatePb.GetDateOfBirth(),
 	}
 
 	return req, nil
@@ -139,9 +183,10 @@ func encodeGetUserResponse(_ context.Context, response interface{}) (interface{}
 	res := response.(GetUserResponse)
 
 	return &userpb.GetUserResponse{
-		Email:    res.Email,
-		Password: res.Password,
-		Age:      uint32(res.Age),
+		Email:       res.Email,
+		Password:    res.Password,
+		Age:         uint32(res.Age),
+		DateOfBirth: res.DateOfBirth,
 	}, nil
 }
 
@@ -165,16 +210,62 @@ func encondeDeleteUserResponse(_ context.Context, response interface{}) (interfa
 	return &userpb.DeleteUserResponse{Success: res.Success}, nil
 }
 
+func decodeListUsersRequest(_ context.Context, request interface{}) (interface{}, error) {
+	listPb, ok := request.(*userpb.ListUsersRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'ListUsersRequest'")
+	}
+
+	req := ListUsersRequest{
+		AfterID:  int(listPb.GetAfterId()),
+		PageSize: int(listPb.GetPageSize()),
+	}
+
+	return req, nil
+}
+
+func encodeListUsersResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(ListUsersResponse)
+
+	users := make([]*userpb.User, len(res.Users))
+	for i, u := range res.Users {
+		users[i] = &userpb.User{
+			Id:          uint32(u.UserID),
+			Email:       u.Email,
+			DateOfBirth: u.DateOfBirth,
+			Age:         uint32(u.Age),
+		}
+	}
+
+	return &userpb.ListUsersResponse{Users: users, NextAfterId: uint32(res.NextAfterID)}, nil
+}
+
+func decodePurgeUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	purgePb, ok := request.(*userpb.PurgeUserRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'PurgeUserRequest'")
+	}
+
+	req := PurgeUserRequest{
+		UserID: int(purgePb.GetId()),
+	}
+
+	return req, nil
+}
+
+func encodePurgeUserResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(PurgeUserResponse)
+
+	return &userpb.PurgeUserResponse{Success: res.Success}, nil
+}
+
 func (g *gRPCServer) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*userpb.CreateUserResponse, error) {
 	_, res, err := g.createUser.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*userpb.CreateUserResponse), err
@@ -184,12 +275,7 @@ func (g *gRPCServer) Authenticate(ctx context.Context, req *userpb.AuthenticateR
 	_, res, err := g.authenticate.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*userpb.AuthenticateResponse), nil
@@ -199,12 +285,7 @@ func (g *gRPCServer) UpdateUser(ctx context.Context, req *userpb.UpdateUserReque
 	_, res, err := g.updateUser.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*userpb.UpdateUserResponse), nil
@@ -214,12 +295,7 @@ func (g *gRPCServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*
 	_, res, err := g.getUser.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*userpb.GetUserResponse), nil
@@ -229,13 +305,174 @@ func (g *gRPCServer) DeleteUser(ctx context.Context, req *userpb.DeleteUserReque
 	_, res, err := g.deleteUser.ServeGRPC(ctx, req)
 
 	if err != nil {
-		e, ok := err.(grpcError.ErrorResolver)
-		if !ok {
-			u := grpcError.NewUnknownError()
-			return nil, status.Error(u.GrpcCode(), u.Error())
-		}
-		return nil, status.Error(e.GrpcCode(), err.Error())
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
 	}
 
 	return res.(*userpb.DeleteUserResponse), nil
 }
+
+func decodeListChangesRequest(_ context.Context, request interface{}) (interface{}, error) {
+	listPb, ok := request.(*userpb.ListChangesRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'ListChangesRequest'")
+	}
+
+	req := ListChangesRequest{
+		Cursor:   listPb.GetCursor(),
+		PageSize: int(listPb.GetPageSize()),
+	}
+
+	return req, nil
+}
+
+func encodeListChangesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(ListChangesResponse)
+
+	changes := make([]*userpb.Change, len(res.Changes))
+	for i, c := range res.Changes {
+		change, err := changeToProto(c)
+		if err != nil {
+			return nil, err
+		}
+		changes[i] = change
+	}
+
+	return &userpb.ListChangesResponse{Changes: changes, NextCursor: res.NextCursor}, nil
+}
+
+func changeToProto(c events.Change) (*userpb.Change, error) {
+	var data []byte
+	if len(c.Data) > 0 {
+		var err error
+		if data, err = json.Marshal(c.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	return &userpb.Change{
+		Id:         c.ID,
+		Type:       string(c.Type),
+		UserId:     uint32(c.UserID),
+		OccurredAt: c.OccurredAt.UTC().Format(time.RFC3339Nano),
+		Data:       string(data),
+		Cursor:     c.Cursor,
+	}, nil
+}
+
+func (g *gRPCServer) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	_, res, err := g.listUsers.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*userpb.ListUsersResponse), nil
+}
+
+func (g *gRPCServer) PurgeUser(ctx context.Context, req *userpb.PurgeUserRequest) (*userpb.PurgeUserResponse, error) {
+	_, res, err := g.purgeUser.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*userpb.PurgeUserResponse), nil
+}
+
+func (g *gRPCServer) ListChanges(ctx context.Context, req *userpb.ListChangesRequest) (*userpb.ListChangesResponse, error) {
+	_, res, err := g.listChanges.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*userpb.ListChangesResponse), nil
+}
+
+func decodeBatchGetUsersRequest(_ context.Context, request interface{}) (interface{}, error) {
+	batchPb, ok := request.(*userpb.BatchGetUsersRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'BatchGetUsersRequest'")
+	}
+
+	ids := make([]int, len(batchPb.GetIds()))
+	for i, id := range batchPb.GetIds() {
+		ids[i] = int(id)
+	}
+
+	return BatchGetUsersRequest{UserIDs: ids}, nil
+}
+
+func encodeBatchGetUsersResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(BatchGetUsersResponse)
+
+	results := make([]*userpb.UserResult, len(res.Results))
+	for i, r := range res.Results {
+		results[i] = &userpb.UserResult{Id: uint32(r.UserID), Found: r.Found}
+		if r.Found {
+			results[i].User = &userpb.User{
+				Id:          uint32(r.User.UserID),
+				Email:       r.User.Email,
+				DateOfBirth: r.User.DateOfBirth,
+				Age:         uint32(r.User.Age),
+			}
+		}
+	}
+
+	return &userpb.BatchGetUsersResponse{Results: results}, nil
+}
+
+func (g *gRPCServer) BatchGetUsers(ctx context.Context, req *userpb.BatchGetUsersRequest) (*userpb.BatchGetUsersResponse, error) {
+	_, res, err := g.batchGetUsers.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*userpb.BatchGetUsersResponse), nil
+}
+
+func decodeCheckEmailsRequest(_ context.Context, request interface{}) (interface{}, error) {
+	checkPb, ok := request.(*userpb.CheckEmailsRequest)
+
+	if !ok {
+		return nil, errors.New("no proto message 'CheckEmailsRequest'")
+	}
+
+	return CheckEmailsRequest{Emails: checkPb.GetEmails()}, nil
+}
+
+func encodeCheckEmailsResponse(_ context.Context, response interface{}) (interface{}, error) {
+	res := response.(CheckEmailsResponse)
+	return &userpb.CheckEmailsResponse{Taken: res.Taken}, nil
+}
+
+func (g *gRPCServer) CheckEmails(ctx context.Context, req *userpb.CheckEmailsRequest) (*userpb.CheckEmailsResponse, error) {
+	_, res, err := g.checkEmails.ServeGRPC(ctx, req)
+
+	if err != nil {
+		return nil, grpcError.LocalizedStatus(ctx, err).Err()
+	}
+
+	return res.(*userpb.CheckEmailsResponse), nil
+}
+
+func (g *gRPCServer) WatchUser(req *userpb.WatchUserRequest, stream userpb.UserService_WatchUserServer) error {
+	send := func(c events.Change) error {
+		change, err := changeToProto(c)
+		if err != nil {
+			return err
+		}
+		return stream.Send(change)
+	}
+
+	_, err := g.watchUser(stream.Context(), WatchUserRequest{UserID: int(req.GetId()), Cursor: req.GetCursor(), Send: send})
+
+	if err != nil {
+		return grpcError.LocalizedStatus(stream.Context(), err).Err()
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package transport
 import (
 	"context"
 
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_srv/entities"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,8 +14,8 @@ type GrpcUserSrvMock struct {
 }
 
 // CreateUser is a mock of the real method
-func (s *GrpcUserSrvMock) CreateUser(ctx context.Context, email string, pwd string, age int) (int, error) {
-	args := s.Called(ctx, email, pwd, age)
+func (s *GrpcUserSrvMock) CreateUser(ctx context.Context, email string, pwd string, dateOfBirth string) (int, error) {
+	args := s.Called(ctx, email, pwd, dateOfBirth)
 
 	return args.Int(0), args.Error(1)
 }
@@ -27,8 +28,8 @@ func (s *GrpcUserSrvMock) Authenticate(ctx context.Context, email string, pwd st
 }
 
 // UpdateUser is a mock of the real method
-func (s *GrpcUserSrvMock) UpdateUser(ctx context.Context, id int, email string, pwd string, age int) (bool, error) {
-	args := s.Called(ctx, id, email, pwd, age)
+func (s *GrpcUserSrvMock) UpdateUser(ctx context.Context, id int, email string, pwd string, dateOfBirth string) (bool, error) {
+	args := s.Called(ctx, id, email, pwd, dateOfBirth)
 
 	return args.Bool(0), args.Error(1)
 }
@@ -46,3 +47,45 @@ func (s *GrpcUserSrvMock) DeleteUser(ctx context.Context, id int) (bool, error)
 
 	return args.Bool(0), args.Error(1)
 }
+
+// ListUsers is a mock of the real method
+func (s *GrpcUserSrvMock) ListUsers(ctx context.Context, afterID int, pageSize int) ([]entities.User, int, error) {
+	args := s.Called(ctx, afterID, pageSize)
+
+	return args.Get(0).([]entities.User), args.Int(1), args.Error(2)
+}
+
+// BatchGetUsers is a mock of the real method
+func (s *GrpcUserSrvMock) BatchGetUsers(ctx context.Context, ids []int) ([]entities.UserResult, error) {
+	args := s.Called(ctx, ids)
+
+	return args.Get(0).([]entities.UserResult), args.Error(1)
+}
+
+// CheckEmails is a mock of the real method
+func (s *GrpcUserSrvMock) CheckEmails(ctx context.Context, emails []string) ([]string, error) {
+	args := s.Called(ctx, emails)
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// PurgeUser is a mock of the real method
+func (s *GrpcUserSrvMock) PurgeUser(ctx context.Context, id int) (bool, error) {
+	args := s.Called(ctx, id)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// ListChanges is a mock of the real method
+func (s *GrpcUserSrvMock) ListChanges(ctx context.Context, cursor string, pageSize int) ([]events.Change, string, error) {
+	args := s.Called(ctx, cursor, pageSize)
+
+	return args.Get(0).([]events.Change), args.String(1), args.Error(2)
+}
+
+// WatchUser is a mock of the real method
+func (s *GrpcUserSrvMock) WatchUser(ctx context.Context, id int, cursor string, send func(events.Change) error) error {
+	args := s.Called(ctx, id, cursor, send)
+
+	return args.Error(0)
+}
@@ -3,15 +3,78 @@ package transport_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/events"
 	"github.com/mauricioww/user_microsrv/user_srv/entities"
 	"github.com/mauricioww/user_microsrv/user_srv/transport"
 	"github.com/mauricioww/user_microsrv/user_srv/userpb"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// fakeWatchStream collects the changes sent over a WatchUser stream
+type fakeWatchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*userpb.Change
+}
+
+func (f *fakeWatchStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeWatchStream) Send(c *userpb.Change) error {
+	f.sent = append(f.sent, c)
+	return nil
+}
+
+// grpcStatus stores the literal code, message, reason and field violations expected from a failed call
+type grpcStatus struct {
+	code       codes.Code
+	message    string
+	reason     string
+	violations map[string]string
+}
+
+// assertStatus checks the error against the expected status, a nil status expects no error
+func assertStatus(assert *assert.Assertions, expected *grpcStatus, err error) {
+	if expected == nil {
+		assert.NoError(err)
+		return
+	}
+
+	st, ok := status.FromError(err)
+	if !assert.True(ok, "expected a status error, got %v", err) {
+		return
+	}
+
+	var reason string
+	var violations map[string]string
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			assert.Equal("user_microsrv", d.Domain)
+			reason = d.Reason
+		case *errdetails.BadRequest:
+			violations = make(map[string]string)
+			for _, v := range d.FieldViolations {
+				violations[v.Field] = v.Description
+			}
+		}
+	}
+
+	assert.Equal(expected.code, st.Code())
+	assert.Equal(expected.message, st.Message())
+	assert.Equal(expected.reason, reason)
+	assert.Equal(expected.violations, violations)
+}
+
 func TestCreateUser(t *testing.T) {
 	srvMock := new(transport.GrpcUserSrvMock)
 	endpoints := transport.MakeGrpcEndpoints(srvMock)
@@ -21,16 +84,16 @@ func TestCreateUser(t *testing.T) {
 		testName string
 		userReq  *userpb.CreateUserRequest
 		userRes  *userpb.CreateUserResponse
-		err      error
+		err      *grpcStatus
 		srvRes   int
 		srvErr   error
 	}{
 		{
 			testName: "user created successfully",
 			userReq: &userpb.CreateUserRequest{
-				Email:    "success@email.com",
-				Password: "qwerty",
-				Age:      23,
+				Email:       "success@email.com",
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
 			},
 			srvRes: 0,
 			srvErr: nil,
@@ -38,20 +101,22 @@ func TestCreateUser(t *testing.T) {
 		{
 			testName: "no password error",
 			userReq: &userpb.CreateUserRequest{
-				Email: "success@email.com",
-				Age:   23,
+				Email:       "success@email.com",
+				DateOfBirth: "1998-05-10",
 			},
 			srvRes: -1,
-			srvErr: errors.NewBadRequestPasswordError(),
+			srvErr: errors.NewInvalidRequestError(errors.FieldViolation{Field: "password", Description: "is required"}),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid request 'password': is required", reason: "INVALID_REQUEST", violations: map[string]string{"password": "is required"}},
 		},
 		{
 			testName: "no email error",
 			userReq: &userpb.CreateUserRequest{
-				Password: "qwerty",
-				Age:      23,
+				Password:    "qwerty",
+				DateOfBirth: "1998-05-10",
 			},
 			srvRes: -1,
-			srvErr: errors.NewBadRequestEmailError(),
+			srvErr: errors.NewInvalidRequestError(errors.FieldViolation{Field: "email", Description: "is required"}),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid request 'email': is required", reason: "INVALID_REQUEST", violations: map[string]string{"email": "is required"}},
 		},
 	}
 	for _, tc := range testCases {
@@ -61,19 +126,17 @@ func TestCreateUser(t *testing.T) {
 			ctx := context.Background()
 			if tc.srvErr != nil {
 				tc.userRes = nil
-				e, _ := tc.srvErr.(errors.ErrorResolver)
-				tc.err = status.Error(e.GrpcCode(), tc.srvErr.Error())
 			} else {
 				tc.userRes = &userpb.CreateUserResponse{Id: int32(tc.srvRes)}
 			}
 
 			// act
-			srvMock.On("CreateUser", ctx, tc.userReq.GetEmail(), tc.userReq.GetPassword(), int(tc.userReq.GetAge())).Return(tc.srvRes, tc.srvErr)
+			srvMock.On("CreateUser", ctx, tc.userReq.GetEmail(), tc.userReq.GetPassword(), tc.userReq.GetDateOfBirth()).Return(tc.srvRes, tc.srvErr)
 			res, err := grpcService.CreateUser(ctx, tc.userReq)
 
 			// assert
 			assert.Equal(tc.userRes, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
@@ -87,7 +150,7 @@ func TestAuthenticate(t *testing.T) {
 		testName string
 		data     *userpb.AuthenticateRequest
 		res      *userpb.AuthenticateResponse
-		err      error
+		err      *grpcStatus
 		srvRes   bool
 		srvErr   error
 	}{
@@ -105,14 +168,16 @@ func TestAuthenticate(t *testing.T) {
 			data: &userpb.AuthenticateRequest{
 				Email: "user@email.com",
 			},
-			srvErr: errors.NewBadRequestPasswordError(),
+			srvErr: errors.NewInvalidRequestError(errors.FieldViolation{Field: "password", Description: "is required"}),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid request 'password': is required", reason: "INVALID_REQUEST", violations: map[string]string{"password": "is required"}},
 		},
 		{
 			testName: "no email error",
 			data: &userpb.AuthenticateRequest{
 				Password: "invalid_password",
 			},
-			srvErr: errors.NewBadRequestEmailError(),
+			srvErr: errors.NewInvalidRequestError(errors.FieldViolation{Field: "email", Description: "is required"}),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid request 'email': is required", reason: "INVALID_REQUEST", violations: map[string]string{"email": "is required"}},
 		},
 		{
 			testName: "invalid password error",
@@ -121,6 +186,7 @@ func TestAuthenticate(t *testing.T) {
 				Password: "invalid_password",
 			},
 			srvErr: errors.NewUnauthenticatedError(),
+			err:    &grpcStatus{code: codes.Unauthenticated, message: "Password or email error", reason: "INVALID_CREDENTIALS"},
 		},
 	}
 
@@ -131,9 +197,6 @@ func TestAuthenticate(t *testing.T) {
 			ctx := context.Background()
 			if tc.srvErr != nil {
 				tc.res = nil
-				e, _ := tc.srvErr.(errors.ErrorResolver)
-				tc.err = status.Error(e.GrpcCode(), tc.srvErr.Error())
-
 			} else {
 				tc.res = &userpb.AuthenticateResponse{Success: tc.srvRes}
 			}
@@ -144,7 +207,7 @@ func TestAuthenticate(t *testing.T) {
 
 			// assert
 			assert.Equal(tc.res, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
@@ -158,17 +221,17 @@ func TestUpdateUser(t *testing.T) {
 		testName string
 		data     *userpb.UpdateUserRequest
 		res      *userpb.UpdateUserResponse
-		err      error
+		err      *grpcStatus
 		srvRes   bool
 		srvErr   error
 	}{
 		{
 			testName: "update user successfully",
 			data: &userpb.UpdateUserRequest{
-				Id:       1,
-				Email:    "new_email@domain.com",
-				Password: "new_password",
-				Age:      25,
+				Id:          1,
+				Email:       "new_email@domain.com",
+				Password:    "new_password",
+				DateOfBirth: "1996-02-29",
 			},
 			srvRes: true,
 			srvErr: nil,
@@ -176,29 +239,33 @@ func TestUpdateUser(t *testing.T) {
 		{
 			testName: "no password error",
 			data: &userpb.UpdateUserRequest{
-				Id:    1,
-				Email: "new_email@domain.com",
-				Age:   25,
+				Id:          1,
+				Email:       "new_email@domain.com",
+				DateOfBirth: "1996-02-29",
 			},
-			srvErr: errors.NewBadRequestPasswordError(),
+			srvErr: errors.NewInvalidRequestError(errors.FieldViolation{Field: "password", Description: "is required"}),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid request 'password': is required", reason: "INVALID_REQUEST", violations: map[string]string{"password": "is required"}},
 		},
 		{
 			testName: "no email error",
 			data: &userpb.UpdateUserRequest{
-				Id:       1,
-				Password: "new_password",
-				Age:      25,
+				Id:          1,
+				Password:    "new_password",
+				DateOfBirth: "1996-02-29",
 			},
-			srvErr: errors.NewBadRequestEmailError(),
+			srvErr: errors.NewInvalidRequestError(errors.FieldViolation{Field: "email", Description: "is required"}),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid request 'email': is required", reason: "INVALID_REQUEST", violations: map[string]string{"email": "is required"}},
 		},
 		{
 			testName: "user not found error",
 			data: &userpb.UpdateUserRequest{
-				Id:       1,
-				Email:    "new_email@domain.com",
-				Password: "new_password",
+				Id:          2,
+				Email:       "new_email@domain.com",
+				Password:    "new_password",
+				DateOfBirth: "1996-02-29",
 			},
 			srvErr: errors.NewUserNotFoundError(),
+			err:    &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
 		},
 	}
 
@@ -209,19 +276,17 @@ func TestUpdateUser(t *testing.T) {
 			ctx := context.Background()
 			if tc.srvErr != nil {
 				tc.res = nil
-				e, _ := tc.srvErr.(errors.ErrorResolver)
-				tc.err = status.Error(e.GrpcCode(), tc.srvErr.Error())
 			} else {
 				tc.res = &userpb.UpdateUserResponse{Success: tc.srvRes}
 			}
 
 			// act
-			srvMock.On("UpdateUser", ctx, int(tc.data.GetId()), tc.data.GetEmail(), tc.data.GetPassword(), int(tc.data.GetAge())).Return(tc.srvRes, tc.srvErr)
+			srvMock.On("UpdateUser", ctx, int(tc.data.GetId()), tc.data.GetEmail(), tc.data.GetPassword(), tc.data.GetDateOfBirth()).Return(tc.srvRes, tc.srvErr)
 			res, err := grpcService.UpdateUser(ctx, tc.data)
 
 			// assert
 			assert.Equal(tc.res, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
@@ -235,19 +300,20 @@ func TestGetUser(t *testing.T) {
 		testName string
 		data     *userpb.GetUserRequest
 		res      *userpb.GetUserResponse
-		err      error
+		err      *grpcStatus
 		srvRes   entities.User
 		srvErr   error
 	}{
 		{
 			testName: "user found",
 			data: &userpb.GetUserRequest{
-				Id: 0,
+				Id: 2,
 			},
 			srvRes: entities.User{
-				Email:    "user@email.com",
-				Password: "password",
-				Age:      20,
+				Email:       "user@email.com",
+				Password:    "password",
+				DateOfBirth: time.Date(2001, time.May, 10, 0, 0, 0, 0, time.UTC),
+				Age:         20,
 			},
 			srvErr: nil,
 		},
@@ -257,6 +323,7 @@ func TestGetUser(t *testing.T) {
 				Id: 1,
 			},
 			srvErr: errors.NewUserNotFoundError(),
+			err:    &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
 		},
 	}
 
@@ -267,13 +334,12 @@ func TestGetUser(t *testing.T) {
 			ctx := context.Background()
 			if tc.srvErr != nil {
 				tc.res = nil
-				e, _ := tc.srvErr.(errors.ErrorResolver)
-				tc.err = status.Error(e.GrpcCode(), tc.srvErr.Error())
 			} else {
 				tc.res = &userpb.GetUserResponse{
-					Email:    tc.srvRes.Email,
-					Password: tc.srvRes.Password,
-					Age:      uint32(tc.srvRes.Age),
+					Email:       tc.srvRes.Email,
+					Password:    tc.srvRes.Password,
+					Age:         uint32(tc.srvRes.Age),
+					DateOfBirth: "2001-05-10",
 				}
 			}
 
@@ -283,7 +349,7 @@ func TestGetUser(t *testing.T) {
 
 			// assert
 			assert.Equal(tc.res, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
@@ -297,14 +363,14 @@ func TestDeleteUser(t *testing.T) {
 		testName string
 		data     *userpb.DeleteUserRequest
 		res      *userpb.DeleteUserResponse
-		err      error
+		err      *grpcStatus
 		srvRes   bool
 		srvErr   error
 	}{
 		{
 			testName: "delete user success",
 			data: &userpb.DeleteUserRequest{
-				Id: 0,
+				Id: 2,
 			},
 			srvRes: true,
 			srvErr: nil,
@@ -315,6 +381,7 @@ func TestDeleteUser(t *testing.T) {
 				Id: 1,
 			},
 			srvErr: errors.NewUserNotFoundError(),
+			err:    &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
 		},
 	}
 
@@ -325,8 +392,6 @@ func TestDeleteUser(t *testing.T) {
 			ctx := context.Background()
 			if tc.srvErr != nil {
 				tc.res = nil
-				e, _ := tc.srvErr.(errors.ErrorResolver)
-				tc.err = status.Error(e.GrpcCode(), tc.srvErr.Error())
 			} else {
 				tc.res = &userpb.DeleteUserResponse{Success: tc.srvRes}
 			}
@@ -337,7 +402,346 @@ func TestDeleteUser(t *testing.T) {
 
 			// assert
 			assert.Equal(tc.res, res)
-			assert.Equal(tc.err, err)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	srvMock := new(transport.GrpcUserSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srvMock)
+	grpcService := transport.NewGrpcUserServer(endpoints)
+
+	testCases := []struct {
+		testName string
+		data     *userpb.ListUsersRequest
+		res      *userpb.ListUsersResponse
+		err      *grpcStatus
+		srvRes   []entities.User
+		srvNext  int
+		srvErr   error
+	}{
+		{
+			testName: "list users success",
+			data: &userpb.ListUsersRequest{
+				AfterId:  0,
+				PageSize: 1,
+			},
+			srvRes: []entities.User{
+				{ID: 3, Email: "user@email.com", DateOfBirth: time.Date(1998, 5, 10, 0, 0, 0, 0, time.UTC), Age: 24},
+			},
+			srvNext: 3,
+			srvErr:  nil,
+		},
+		{
+			testName: "missing tenant error",
+			data: &userpb.ListUsersRequest{
+				AfterId: 3,
+			},
+			srvErr: errors.NewMissingTenantError(),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Missing or invalid tenant", reason: "MISSING_TENANT"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			if tc.srvErr != nil {
+				tc.res = nil
+			} else {
+				tc.res = &userpb.ListUsersResponse{
+					Users:       []*userpb.User{{Id: 3, Email: "user@email.com", DateOfBirth: "1998-05-10", Age: 24}},
+					NextAfterId: uint32(tc.srvNext),
+				}
+			}
+
+			// act
+			srvMock.On("ListUsers", ctx, int(tc.data.GetAfterId()), int(tc.data.GetPageSize())).Return(tc.srvRes, tc.srvNext, tc.srvErr)
+			res, err := grpcService.ListUsers(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestPurgeUser(t *testing.T) {
+	srvMock := new(transport.GrpcUserSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srvMock)
+	grpcService := transport.NewGrpcUserServer(endpoints)
+
+	testCases := []struct {
+		testName string
+		data     *userpb.PurgeUserRequest
+		res      *userpb.PurgeUserResponse
+		err      *grpcStatus
+		srvRes   bool
+		srvErr   error
+	}{
+		{
+			testName: "purge user success",
+			data: &userpb.PurgeUserRequest{
+				Id: 2,
+			},
+			srvRes: true,
+			srvErr: nil,
+		},
+		{
+			testName: "user not found error",
+			data: &userpb.PurgeUserRequest{
+				Id: 5,
+			},
+			srvErr: errors.NewUserNotFoundError(),
+			err:    &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			if tc.srvErr != nil {
+				tc.res = nil
+			} else {
+				tc.res = &userpb.PurgeUserResponse{Success: tc.srvRes}
+			}
+
+			// act
+			srvMock.On("PurgeUser", ctx, int(tc.data.GetId())).Return(tc.srvRes, tc.srvErr)
+			res, err := grpcService.PurgeUser(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestListChanges(t *testing.T) {
+	srvMock := new(transport.GrpcUserSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srvMock)
+	grpcService := transport.NewGrpcUserServer(endpoints)
+
+	occurredAt := time.Date(2022, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		testName string
+		data     *userpb.ListChangesRequest
+		res      *userpb.ListChangesResponse
+		err      *grpcStatus
+		srvRes   []events.Change
+		srvNext  string
+		srvErr   error
+	}{
+		{
+			testName: "list changes success",
+			data: &userpb.ListChangesRequest{
+				PageSize: 2,
+			},
+			srvRes: []events.Change{
+				{Event: events.Event{ID: "a", Type: events.UserCreated, UserID: 3, OccurredAt: occurredAt, Data: map[string]interface{}{"email": "user@email.com"}}, Cursor: "c1"},
+				{Event: events.Event{ID: "b", Type: events.UserDeleted, UserID: 3, OccurredAt: occurredAt}, Cursor: "c2"},
+			},
+			srvNext: "c2",
+			res: &userpb.ListChangesResponse{
+				Changes: []*userpb.Change{
+					{Id: "a", Type: "UserCreated", UserId: 3, OccurredAt: "2022-03-01T10:30:00Z", Data: `{"email":"user@email.com"}`, Cursor: "c1"},
+					{Id: "b", Type: "UserDeleted", UserId: 3, OccurredAt: "2022-03-01T10:30:00Z", Cursor: "c2"},
+				},
+				NextCursor: "c2",
+			},
+		},
+		{
+			testName: "invalid cursor error",
+			data: &userpb.ListChangesRequest{
+				Cursor: "unknown",
+			},
+			srvRes: []events.Change{},
+			srvErr: errors.NewInvalidCursorError(),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid or unknown cursor", reason: "INVALID_CURSOR"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+
+			// act
+			srvMock.On("ListChanges", ctx, tc.data.GetCursor(), int(tc.data.GetPageSize())).Return(tc.srvRes, tc.srvNext, tc.srvErr)
+			res, err := grpcService.ListChanges(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	srvMock := new(transport.GrpcUserSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srvMock)
+	grpcService := transport.NewGrpcUserServer(endpoints)
+
+	testCases := []struct {
+		testName string
+		data     *userpb.BatchGetUsersRequest
+		res      *userpb.BatchGetUsersResponse
+		err      *grpcStatus
+		srvRes   []entities.UserResult
+		srvErr   error
+	}{
+		{
+			testName: "batch get users success",
+			data: &userpb.BatchGetUsersRequest{
+				Ids: []uint32{3, 5},
+			},
+			srvRes: []entities.UserResult{
+				{ID: 3, Found: true, User: entities.User{ID: 3, Email: "user@email.com", DateOfBirth: time.Date(1998, 5, 10, 0, 0, 0, 0, time.UTC), Age: 24}},
+				{ID: 5, Found: false},
+			},
+			srvErr: nil,
+		},
+		{
+			testName: "invalid batch error",
+			data: &userpb.BatchGetUsersRequest{
+				Ids: []uint32{},
+			},
+			srvErr: errors.NewInvalidBatchError(),
+			err:    &grpcStatus{code: codes.FailedPrecondition, message: "Invalid batch, expected between 1 and 100 ids", reason: "INVALID_BATCH"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+			ids := make([]int, len(tc.data.GetIds()))
+			for i, id := range tc.data.GetIds() {
+				ids[i] = int(id)
+			}
+			if tc.srvErr != nil {
+				tc.res = nil
+			} else {
+				tc.res = &userpb.BatchGetUsersResponse{
+					Results: []*userpb.UserResult{
+						{Id: 3, Found: true, User: &userpb.User{Id: 3, Email: "user@email.com", DateOfBirth: "1998-05-10", Age: 24}},
+						{Id: 5, Found: false},
+					},
+				}
+			}
+
+			// act
+			srvMock.On("BatchGetUsers", ctx, ids).Return(tc.srvRes, tc.srvErr)
+			res, err := grpcService.BatchGetUsers(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestCheckEmails(t *testing.T) {
+	srvMock := new(transport.GrpcUserSrvMock)
+	endpoints := transport.MakeGrpcEndpoints(srvMock)
+	grpcService := transport.NewGrpcUserServer(endpoints)
+
+	testCases := []struct {
+		testName string
+		data     *userpb.CheckEmailsRequest
+		res      *userpb.CheckEmailsResponse
+		err      *grpcStatus
+		srvRes   []string
+		srvErr   error
+	}{
+		{
+			testName: "check emails success",
+			data:     &userpb.CheckEmailsRequest{Emails: []string{"first@email.com", "second@email.com"}},
+			res:      &userpb.CheckEmailsResponse{Taken: []string{"second@email.com"}},
+			srvRes:   []string{"second@email.com"},
+		},
+		{
+			testName: "invalid batch error",
+			data:     &userpb.CheckEmailsRequest{Emails: []string{}},
+			srvErr:   errors.NewInvalidBatchError(),
+			err:      &grpcStatus{code: codes.FailedPrecondition, message: "Invalid batch, expected between 1 and 100 ids", reason: "INVALID_BATCH"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			ctx := context.Background()
+
+			// act
+			srvMock.On("CheckEmails", ctx, tc.data.GetEmails()).Return(tc.srvRes, tc.srvErr)
+			res, err := grpcService.CheckEmails(ctx, tc.data)
+
+			// assert
+			assert.Equal(tc.res, res)
+			assertStatus(assert, tc.err, err)
+		})
+	}
+}
+
+func TestWatchUser(t *testing.T) {
+	occurredAt := time.Date(2022, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		testName string
+		data     *userpb.WatchUserRequest
+		res      []*userpb.Change
+		err      *grpcStatus
+		srvRes   []events.Change
+		srvErr   error
+	}{
+		{
+			testName: "watch user success",
+			data:     &userpb.WatchUserRequest{Id: 3, Cursor: "c1"},
+			srvRes: []events.Change{
+				{Event: events.Event{ID: "b", Type: events.UserUpdated, UserID: 3, OccurredAt: occurredAt}, Cursor: "c2"},
+			},
+			res: []*userpb.Change{
+				{Id: "b", Type: "UserUpdated", UserId: 3, OccurredAt: "2022-03-01T10:30:00Z", Cursor: "c2"},
+			},
+		},
+		{
+			testName: "user not found error",
+			data:     &userpb.WatchUserRequest{Id: 5},
+			srvErr:   errors.NewUserNotFoundError(),
+			err:      &grpcStatus{code: codes.NotFound, message: "User not found", reason: "USER_NOT_FOUND"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			srvMock := new(transport.GrpcUserSrvMock)
+			endpoints := transport.MakeGrpcEndpoints(srvMock)
+			grpcService := transport.NewGrpcUserServer(endpoints)
+			stream := &fakeWatchStream{ctx: context.Background()}
+
+			// act
+			srvMock.On("WatchUser", stream.ctx, int(tc.data.GetId()), tc.data.GetCursor(), mock.Anything).Run(func(args mock.Arguments) {
+				send := args.Get(3).(func(events.Change) error)
+				for _, c := range tc.srvRes {
+					_ = send(c)
+				}
+			}).Return(tc.srvErr)
+			err := grpcService.WatchUser(tc.data, stream)
+
+			// assert
+			assert.Equal(tc.res, stream.sent)
+			assertStatus(assert, tc.err, err)
 		})
 	}
 }
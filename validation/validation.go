@@ -0,0 +1,304 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/mauricioww/user_microsrv/errors"
+)
+
+// TagKey is the struct tag which declares the rules of a field, e.g. `validate:"required,max=254"`
+const TagKey = "validate"
+
+// dateLayout is the layout of the values checked by the date rule
+const dateLayout = "2006-01-02"
+
+type rule struct {
+	name string
+	arg  string
+}
+
+// field stores how one struct field is validated, embedded fields without a name are validated as part of the
+// struct which embeds them
+type field struct {
+	index     int
+	name      string
+	omitEmpty bool
+	rules     []rule
+	flatten   bool
+}
+
+var (
+	mu     sync.RWMutex
+	fields = map[reflect.Type][]field{}
+)
+
+// Validate checks every rule of the request and its nested structs, the violations of all the fields are returned
+// within one InvalidRequestError, the requests which are not structs are always valid
+func Validate(request interface{}) error {
+	violations := check(reflect.ValueOf(request), "")
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return errors.NewInvalidRequestError(violations...)
+}
+
+// Middleware validates the requests before they reach the endpoint, the invalid ones are rejected with the error
+// returned by Validate
+func Middleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if err := Validate(request); err != nil {
+				return nil, err
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+func check(v reflect.Value, prefix string) []errors.FieldViolation {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var violations []errors.FieldViolation
+	for _, f := range fieldsOf(v.Type()) {
+		fv := v.Field(f.index)
+
+		if f.flatten {
+			violations = append(violations, check(fv, prefix)...)
+			continue
+		}
+
+		// the rules of the optional fields apply to their value once it is set
+		value := fv
+		if value.Kind() == reflect.Ptr && !value.IsNil() && structType(value.Type()) == nil {
+			value = value.Elem()
+		}
+
+		name := prefix + f.name
+		if !f.omitEmpty || !value.IsZero() {
+			for _, r := range f.rules {
+				if description := apply(r, value); description != "" {
+					violations = append(violations, errors.FieldViolation{Field: name, Description: description})
+					break
+				}
+			}
+		}
+
+		if structType(fv.Type()) != nil {
+			violations = append(violations, check(fv, name+".")...)
+		}
+	}
+
+	return violations
+}
+
+// fieldsOf returns the fields of the struct type, they are parsed once and kept for the next requests
+func fieldsOf(t reflect.Type) []field {
+	mu.RLock()
+	parsed, ok := fields[t]
+	mu.RUnlock()
+	if ok {
+		return parsed
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	parsed = []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name, named := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+
+		f := field{index: i, name: name}
+		if sf.Anonymous && !named {
+			f.flatten = true
+		}
+		f.omitEmpty, f.rules = parseRules(t, sf.Name, sf.Tag.Get(TagKey))
+
+		if f.flatten || len(f.rules) > 0 || structType(sf.Type) != nil {
+			parsed = append(parsed, f)
+		}
+	}
+
+	fields[t] = parsed
+	return parsed
+}
+
+// parseRules panics on an unknown rule since the rules are declared within the code
+func parseRules(t reflect.Type, name string, tag string) (bool, []rule) {
+	var omitEmpty bool
+	var rules []rule
+
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r := rule{name: part}
+		if i := strings.Index(part, "="); i >= 0 {
+			r.name, r.arg = part[:i], part[i+1:]
+		}
+
+		switch r.name {
+		case "omitempty":
+			omitEmpty = true
+			continue
+		case "min", "max":
+			if _, err := strconv.ParseFloat(r.arg, 64); err != nil {
+				panic(fmt.Sprintf("validation: invalid %v of %v.%v: %v", r.name, t, name, r.arg))
+			}
+		case "required", "email", "url", "date", "oneof":
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %v of %v.%v", r.name, t, name))
+		}
+
+		rules = append(rules, r)
+	}
+
+	return omitEmpty, rules
+}
+
+// apply returns the description of the violation of the rule, empty when the value follows it
+func apply(r rule, v reflect.Value) string {
+	switch r.name {
+	case "required":
+		if v.IsZero() {
+			return "is required"
+		}
+	case "min", "max":
+		return bound(r, v)
+	case "email":
+		s := fmt.Sprint(v.Interface())
+		if a, err := mail.ParseAddress(s); err != nil || a.Address != s {
+			return "must be a valid email address"
+		}
+	case "url":
+		u, err := url.ParseRequestURI(fmt.Sprint(v.Interface()))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an http or https URL"
+		}
+	case "date":
+		if _, err := time.Parse(dateLayout, fmt.Sprint(v.Interface())); err != nil {
+			return "expected a date as YYYY-MM-DD"
+		}
+	case "oneof":
+		options := strings.Fields(r.arg)
+		if !oneOf(v, options) {
+			return fmt.Sprintf("must be one of %v", strings.Join(options, ", "))
+		}
+	}
+
+	return ""
+}
+
+// bound compares the numbers by their value, the strings by their characters and the collections by their items
+func bound(r rule, v reflect.Value) string {
+	limit, _ := strconv.ParseFloat(r.arg, 64)
+
+	var value float64
+	var unit string
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = v.Float()
+	case reflect.String:
+		value, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		value, unit = float64(v.Len()), " items"
+	default:
+		return ""
+	}
+
+	if r.name == "min" && value < limit {
+		return fmt.Sprintf("must be at least %v%v", r.arg, unit)
+	}
+	if r.name == "max" && value > limit {
+		return fmt.Sprintf("must be at most %v%v", r.arg, unit)
+	}
+	return ""
+}
+
+// oneOf checks every item of the collections
+func oneOf(v reflect.Value, options []string) bool {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			if !oneOf(v.Index(i), options) {
+				return false
+			}
+		}
+		return true
+	}
+
+	s := fmt.Sprint(v.Interface())
+	for _, o := range options {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonName returns the name of the field within the violations, the JSON name or the Go name in snake case
+func jsonName(sf reflect.StructField) (string, bool) {
+	if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" {
+		return name, true
+	}
+
+	var b strings.Builder
+	runes := []rune(sf.Name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && !unicode.IsUpper(runes[i-1])
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String(), false
+}
+
+func structType(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
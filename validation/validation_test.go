@@ -0,0 +1,133 @@
+package validation_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mauricioww/user_microsrv/errors"
+	"github.com/mauricioww/user_microsrv/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+// Profile is embedded by signUp and nested as its extra field
+type Profile struct {
+	Height float32  `json:"height_m" validate:"omitempty,min=0.5,max=2.5"`
+	Tags   []string `validate:"max=2,oneof=red green blue"`
+}
+
+type signUp struct {
+	UserID      int     `validate:"min=1"`
+	Email       string  `json:"email" validate:"required,email,max=254"`
+	DateOfBirth string  `validate:"omitempty,date"`
+	Website     string  `validate:"omitempty,url"`
+	Nickname    *string `json:"nickname" validate:"max=4"`
+	Profile
+	Extra Profile `json:"extra"`
+}
+
+func TestValidate(t *testing.T) {
+	nickname := "nickname"
+
+	testCases := []struct {
+		testName string
+		request  interface{}
+		err      error
+	}{
+		{
+			testName: "valid request",
+			request: signUp{
+				UserID:      1,
+				Email:       "user@email.com",
+				DateOfBirth: "1996-02-29",
+				Website:     "https://example.com",
+				Profile:     Profile{Height: 1.75, Tags: []string{"red"}},
+			},
+			err: nil,
+		},
+		{
+			testName: "every violation collected",
+			request: &signUp{
+				Email:       "not an email",
+				DateOfBirth: "29/02/1996",
+				Website:     "ftp://example.com",
+				Nickname:    &nickname,
+				Profile:     Profile{Height: -1, Tags: []string{"red", "green", "blue"}},
+				Extra:       Profile{Tags: []string{"pink"}},
+			},
+			err: errors.NewInvalidRequestError(
+				errors.FieldViolation{Field: "user_id", Description: "must be at least 1"},
+				errors.FieldViolation{Field: "email", Description: "must be a valid email address"},
+				errors.FieldViolation{Field: "date_of_birth", Description: "expected a date as YYYY-MM-DD"},
+				errors.FieldViolation{Field: "website", Description: "must be an http or https URL"},
+				errors.FieldViolation{Field: "nickname", Description: "must be at most 4 characters"},
+				errors.FieldViolation{Field: "height_m", Description: "must be at least 0.5"},
+				errors.FieldViolation{Field: "tags", Description: "must be at most 2 items"},
+				errors.FieldViolation{Field: "extra.tags", Description: "must be one of red, green, blue"},
+			),
+		},
+		{
+			testName: "first failed rule of a field",
+			request:  signUp{UserID: 1},
+			err:      errors.NewInvalidRequestError(errors.FieldViolation{Field: "email", Description: "is required"}),
+		},
+		{
+			testName: "requests which are not structs",
+			request:  42,
+			err:      nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+
+			// act
+			err := validation.Validate(tc.request)
+
+			// assert
+			assert.Equal(tc.err, err)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		testName string
+		request  signUp
+		calls    int
+		err      error
+	}{
+		{
+			testName: "valid request reaches the endpoint",
+			request:  signUp{UserID: 1, Email: "user@email.com"},
+			calls:    1,
+			err:      nil,
+		},
+		{
+			testName: "invalid request rejected",
+			request:  signUp{Email: "user@email.com"},
+			calls:    0,
+			err:      errors.NewInvalidRequestError(errors.FieldViolation{Field: "user_id", Description: "must be at least 1"}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// prepare
+			assert := assert.New(t)
+			var calls int
+			next := func(ctx context.Context, request interface{}) (interface{}, error) {
+				calls++
+				return request, nil
+			}
+
+			// act
+			_, err := validation.Middleware()(next)(context.Background(), tc.request)
+
+			// assert
+			assert.Equal(tc.calls, calls)
+			assert.Equal(tc.err, err)
+		})
+	}
+}